@@ -1,149 +1,779 @@
 package main
 
 import (
+	"errors"
 	"flag"
 	"fmt"
 	"github.com/dspasibenko/pargus/pkg/generator"
 	"github.com/dspasibenko/pargus/pkg/parser"
+	"io"
 	"os"
 	"path/filepath"
+	"sort"
+	"strings"
+	"text/tabwriter"
+)
+
+// stdio is the file name standing for the standard input or output
+const stdio = "-"
+
+// overwriteMode tells how the existing output files are treated
+type overwriteMode int
+
+const (
+	overwrite      overwriteMode = iota // the writable files are overwritten, the read-only ones are errors
+	noOverwrite                         // any existing file with other content is an error
+	forceOverwrite                      // the read-only files are overwritten as well
 )
 
 func main() {
+	os.Exit(run(os.Args[0], os.Args[1:], os.Stdin, os.Stdout, os.Stderr))
+}
+
+// run executes the generator with the command line arguments and returns the process exit code
+func run(name string, args []string, stdin io.Reader, stdout, stderr io.Writer) int {
+	if len(args) > 0 && args[0] == "fmt" {
+		return formatFiles(name, args[1:], stdin, stdout, stderr)
+	}
+
+	flags := flag.NewFlagSet(name, flag.ContinueOnError)
+	flags.SetOutput(stderr)
 	var (
-		output    = flag.String("o", "", "Output file (default: input.h for C++, input.go for Go)")
-		namespace = flag.String("n", "", "C++ namespace name (required for C++)")
-		pkg       = flag.String("p", "", "Go package name (required for Go)")
-		genType   = flag.String("t", "cpp", "Generator type: cpp or go")
-		help      = flag.Bool("help", false, "Show help")
+		output    = flags.String("o", "", "Output file, - for the standard output (default: input.h for C++, input.go for Go, input.py for Python, input.rs for Rust, input.ts for TypeScript, the base name for all)")
+		namespace = flags.String("n", "", "C++ namespace name, the nested namespaces are separated by :: (required for C++)")
+		pkg       = flags.String("p", "", "Go package name (required for Go)")
+		pkgPath   = flags.String("package-path", "", "Write the Go files into the package directory <package-path>/<package>, creating it")
+		genType   = flags.String("t", "cpp", "Generator type: cpp, c, go, py, rust, ts, jsonschema, ksy, fbs or all (cpp and go)")
+		part      = flags.String("part", "h", "C++ or C part written to the standard output with -o -: h, cpp or c")
+		decoder   = flags.Bool("decoder", false, "Generate functions decoding a register by its ID")
+		bfStrings = flags.Bool("bitfield-strings", false, "Generate Go methods formatting bit fields as strings")
+		plain     = flags.Bool("plain", false, "Generate C++ code for a regular C++ compiler instead of Arduino")
+		vectors   = flags.Bool("vectors", false, "Keep the C++ variable-length arrays in std::vector, requires -plain")
+		printer   = flags.Bool("printer", false, "Generate the C++ std::ostream operator<< of the registers for the debug output, requires -plain")
+		reuse     = flags.Bool("reuse-slices", false, "Reuse the Go variable arrays capacity when deserializing")
+		jsonCodec = flags.Bool("json", false, "Generate Go methods encoding registers to JSON")
+		framing   = flags.Bool("framing", false, "Generate Go functions writing and reading registers as length-prefixed frames")
+		frameCRC  = flags.Bool("frame-crc", false, "Append the CRC-16/CCITT checksum to the Go frames, requires -framing")
+		pool      = flags.Bool("pool", false, "Generate Go functions reusing the registers via sync.Pool")
+		unexport  = flags.Bool("unexported", false, "Generate unexported Go registers, enums, constants and accessors")
+		goTest    = flags.Bool("go-test", false, "Generate Go round-trip tests of the registers into the output_test.go file")
+		goBench   = flags.Bool("go-bench", false, "Generate Go benchmarks of the registers encoding into the output_bench_test.go file")
+		check     = flags.Bool("check", false, "Only validate the input files, nothing is generated")
+		strict    = flags.Bool("strict", false, "Report the bit field bits not covered by members and the oversized bit fields as errors")
+		dumpAST   = flags.Bool("dump-ast", false, "Write the parsed device as JSON instead of generating code")
+		listRegs  = flags.Bool("list-registers", false, "Print the ID, name, access and field count of the registers sorted by ID")
+		stats     = flags.Bool("stats", false, "Print the constant, variable and worst case wire sizes of the registers in both directions sorted by ID")
+		keepFiles = flags.Bool("no-overwrite", false, "Fail if an output file exists instead of overwriting it")
+		force     = flags.Bool("force", false, "Overwrite the read-only output files, they stay read-only")
+		help      = flags.Bool("help", false, "Show help")
 	)
 
-	flag.Usage = func() {
-		fmt.Fprintf(os.Stderr, "Usage: %s [options] input.pa\n\n", os.Args[0])
-		fmt.Fprintf(os.Stderr, "Options:\n")
-		flag.PrintDefaults()
-		fmt.Fprintf(os.Stderr, "\nExamples:\n")
-		fmt.Fprintf(os.Stderr, "  # Generate C++ code:\n")
-		fmt.Fprintf(os.Stderr, "  %s -t cpp -n MyNamespace -o output.h input.pa\n", os.Args[0])
-		fmt.Fprintf(os.Stderr, "  # Generate Go code:\n")
-		fmt.Fprintf(os.Stderr, "  %s -t go -p mypackage -o output.go input.pa\n", os.Args[0])
+	flags.Usage = func() {
+		fmt.Fprintf(stderr, "Usage: %s [options] input.pa\n", name)
+		fmt.Fprintf(stderr, "       %s fmt input.pa...\n\n", name)
+		fmt.Fprintf(stderr, "The input file - reads the device from the standard input.\n\n")
+		fmt.Fprintf(stderr, "Options:\n")
+		flags.PrintDefaults()
+		fmt.Fprintf(stderr, "\nExamples:\n")
+		fmt.Fprintf(stderr, "  # Generate C++ code:\n")
+		fmt.Fprintf(stderr, "  %s -t cpp -n MyNamespace -o output.h input.pa\n", name)
+		fmt.Fprintf(stderr, "  # Generate C99 code into output.h and output.c:\n")
+		fmt.Fprintf(stderr, "  %s -t c -o output.h input.pa\n", name)
+		fmt.Fprintf(stderr, "  # Generate Go code:\n")
+		fmt.Fprintf(stderr, "  %s -t go -p mypackage -o output.go input.pa\n", name)
+		fmt.Fprintf(stderr, "  # Generate Python dataclasses:\n")
+		fmt.Fprintf(stderr, "  %s -t py -o output.py input.pa\n", name)
+		fmt.Fprintf(stderr, "  # Generate the Rust module:\n")
+		fmt.Fprintf(stderr, "  %s -t rust -o output.rs input.pa\n", name)
+		fmt.Fprintf(stderr, "  # Generate the TypeScript module:\n")
+		fmt.Fprintf(stderr, "  %s -t ts -o output.ts input.pa\n", name)
+		fmt.Fprintf(stderr, "  # Generate the JSON Schema of the Go JSON form of the registers:\n")
+		fmt.Fprintf(stderr, "  %s -t jsonschema -o output.schema.json input.pa\n", name)
+		fmt.Fprintf(stderr, "  # Generate the Kaitai Struct definition of the registers:\n")
+		fmt.Fprintf(stderr, "  %s -t ksy -o output.ksy input.pa\n", name)
+		fmt.Fprintf(stderr, "  # Generate the FlatBuffers schema of the register payloads:\n")
+		fmt.Fprintf(stderr, "  %s -t fbs -o output.fbs input.pa\n", name)
+		fmt.Fprintf(stderr, "  # Generate internal/mypackage/output.go:\n")
+		fmt.Fprintf(stderr, "  %s -t go -p mypackage -package-path internal -o output.go input.pa\n", name)
+		fmt.Fprintf(stderr, "  # Generate output.go and the round-trip tests in output_test.go:\n")
+		fmt.Fprintf(stderr, "  %s -t go -p mypackage -go-test -o output.go input.pa\n", name)
+		fmt.Fprintf(stderr, "  # Generate output.go and the benchmarks in output_bench_test.go:\n")
+		fmt.Fprintf(stderr, "  %s -t go -p mypackage -go-bench -o output.go input.pa\n", name)
+		fmt.Fprintf(stderr, "  # Generate output.go, output.h and output.cpp:\n")
+		fmt.Fprintf(stderr, "  %s -t all -n MyNamespace -p mypackage -o output input.pa\n", name)
+		fmt.Fprintf(stderr, "  # Generate Go code, but keep the existing output.go:\n")
+		fmt.Fprintf(stderr, "  %s -t go -p mypackage -no-overwrite -o output.go input.pa\n", name)
+		fmt.Fprintf(stderr, "  # Validate the input files:\n")
+		fmt.Fprintf(stderr, "  %s -check input.pa other.pa\n", name)
+		fmt.Fprintf(stderr, "  # Validate the input files, the unused bit field bits are errors:\n")
+		fmt.Fprintf(stderr, "  %s -check -strict input.pa\n", name)
+		fmt.Fprintf(stderr, "  # Print the parsed device as JSON:\n")
+		fmt.Fprintf(stderr, "  %s -dump-ast -o - input.pa\n", name)
+		fmt.Fprintf(stderr, "  # List the registers of the device:\n")
+		fmt.Fprintf(stderr, "  %s -list-registers input.pa\n", name)
+		fmt.Fprintf(stderr, "  # Print the wire sizes of the registers:\n")
+		fmt.Fprintf(stderr, "  %s -stats input.pa\n", name)
+		fmt.Fprintf(stderr, "  # Rewrite the input files in the canonical form:\n")
+		fmt.Fprintf(stderr, "  %s fmt input.pa other.pa\n", name)
+		fmt.Fprintf(stderr, "  # Generate Go code in a pipeline:\n")
+		fmt.Fprintf(stderr, "  cat input.pa | %s -t go -p mypackage -o - - > output.go\n", name)
+	}
+
+	if err := flags.Parse(args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return 0
+		}
+		return 2
 	}
 
-	flag.Parse()
+	parseOpts := parser.ParseOptions{Strict: *strict}
+	mode := overwrite
+	switch {
+	case *keepFiles && *force:
+		fmt.Fprintf(stderr, "Error: -no-overwrite and -force cannot be used together\n")
+		flags.Usage()
+		return 1
+	case *keepFiles:
+		mode = noOverwrite
+	case *force:
+		mode = forceOverwrite
+	}
 
 	if *help {
-		flag.Usage()
-		os.Exit(0)
+		flags.Usage()
+		return 0
+	}
+
+	if *check {
+		if flags.NArg() == 0 {
+			fmt.Fprintf(stderr, "Error: input file is required\n")
+			flags.Usage()
+			return 1
+		}
+		return checkFiles(flags.Args(), parseOpts, stdin, stdout, stderr)
+	}
+
+	if *dumpAST {
+		if flags.NArg() != 1 {
+			fmt.Fprintf(stderr, "Error: exactly one input file is required\n")
+			flags.Usage()
+			return 1
+		}
+		return dumpFile(flags.Arg(0), *output, parseOpts, mode, stdin, stdout, stderr)
+	}
+
+	if *listRegs {
+		if flags.NArg() != 1 {
+			fmt.Fprintf(stderr, "Error: exactly one input file is required\n")
+			flags.Usage()
+			return 1
+		}
+		return listRegisters(flags.Arg(0), parseOpts, stdin, stdout, stderr)
+	}
+
+	if *stats {
+		if flags.NArg() != 1 {
+			fmt.Fprintf(stderr, "Error: exactly one input file is required\n")
+			flags.Usage()
+			return 1
+		}
+		return printStats(flags.Arg(0), parseOpts, stdin, stdout, stderr)
 	}
 
 	// Validate generator type
-	if *genType != "cpp" && *genType != "go" {
-		fmt.Fprintf(os.Stderr, "Error: generator type must be 'cpp' or 'go'\n")
-		flag.Usage()
-		os.Exit(1)
+	if *genType != "cpp" && *genType != "c" && *genType != "go" && *genType != "py" && *genType != "rust" &&
+		*genType != "ts" && *genType != "jsonschema" && *genType != "ksy" && *genType != "fbs" && *genType != "all" {
+		fmt.Fprintf(stderr, "Error: generator type must be 'cpp', 'c', 'go', 'py', 'rust', 'ts', 'jsonschema', 'ksy', 'fbs' or 'all'\n")
+		flags.Usage()
+		return 1
+	}
+
+	if *genType == "c" && *part != "h" && *part != "c" {
+		fmt.Fprintf(stderr, "Error: C part must be 'h' or 'c'\n")
+		flags.Usage()
+		return 1
+	}
+	if *genType != "c" && *part != "h" && *part != "cpp" {
+		fmt.Fprintf(stderr, "Error: C++ part must be 'h' or 'cpp'\n")
+		flags.Usage()
+		return 1
 	}
 
 	// Check for required parameters based on generator type
-	if *genType == "cpp" && *namespace == "" {
-		fmt.Fprintf(os.Stderr, "Error: -n (namespace) parameter is required for C++ generator\n")
-		flag.Usage()
-		os.Exit(1)
+	if (*genType == "cpp" || *genType == "all") && *namespace == "" {
+		fmt.Fprintf(stderr, "Error: -n (namespace) parameter is required for C++ generator\n")
+		flags.Usage()
+		return 1
 	}
 
-	if *genType == "go" && *pkg == "" {
-		fmt.Fprintf(os.Stderr, "Error: -p (package) parameter is required for Go generator\n")
-		flag.Usage()
-		os.Exit(1)
+	if (*genType == "go" || *genType == "all") && *pkg == "" {
+		fmt.Fprintf(stderr, "Error: -p (package) parameter is required for Go generator\n")
+		flags.Usage()
+		return 1
 	}
 
 	// Get input file from command line arguments
-	args := flag.Args()
-	if len(args) == 0 {
-		fmt.Fprintf(os.Stderr, "Error: input file is required\n")
-		flag.Usage()
-		os.Exit(1)
+	if flags.NArg() == 0 {
+		fmt.Fprintf(stderr, "Error: input file is required\n")
+		flags.Usage()
+		return 1
 	}
-	if len(args) > 1 {
-		fmt.Fprintf(os.Stderr, "Error: only one input file is allowed\n")
-		flag.Usage()
-		os.Exit(1)
+	if flags.NArg() > 1 {
+		fmt.Fprintf(stderr, "Error: only one input file is allowed\n")
+		flags.Usage()
+		return 1
+	}
+
+	inputFile := flags.Arg(0)
+
+	// Read input file
+	inputData, err := readInput(inputFile, stdin)
+	if err != nil {
+		fmt.Fprintf(stderr, "Error reading input file %s: %v\n", inputFile, err)
+		return 1
 	}
 
-	inputFile := args[0]
+	// Parse the input
+	device, err := parser.ParseWithOptions(string(inputData), parseOpts)
+	if err != nil {
+		fmt.Fprintf(stderr, "Error parsing input: %v\n", err)
+		return 1
+	}
 
-	// Set default output file if not specified
-	if *output == "" {
-		ext := filepath.Ext(inputFile)
-		base := filepath.Base(inputFile)
-		if ext != "" {
-			base = base[:len(base)-len(ext)]
+	// The device read from the standard input is written to the standard output by default
+	base := filepath.Base(inputFile)
+	if inputFile == stdio {
+		base = device.Name
+	} else if ext := filepath.Ext(inputFile); ext != "" {
+		base = base[:len(base)-len(ext)]
+	}
+	if *output == "" && inputFile == stdio {
+		*output = stdio
+	}
+	if *output == stdio && *genType == "all" {
+		fmt.Fprintf(stderr, "Error: -t all cannot write to the standard output\n")
+		flags.Usage()
+		return 1
+	}
+	if *output == stdio && *pkgPath != "" {
+		fmt.Fprintf(stderr, "Error: -package-path cannot write to the standard output\n")
+		flags.Usage()
+		return 1
+	}
+	if *output == stdio && *goTest {
+		fmt.Fprintf(stderr, "Error: -go-test cannot write to the standard output\n")
+		flags.Usage()
+		return 1
+	}
+	if *output == stdio && *goBench {
+		fmt.Fprintf(stderr, "Error: -go-bench cannot write to the standard output\n")
+		flags.Usage()
+		return 1
+	}
+
+	// The output file name for -t all is the base name of all the generated files
+	outputBase := base
+	if *output != "" && *output != stdio {
+		outputBase = *output
+		if ext := filepath.Ext(outputBase); ext == ".h" || ext == ".hpp" || ext == ".cpp" || ext == ".c" || ext == ".py" || ext == ".rs" || ext == ".ts" ||
+			(ext == ".go" && *genType == "all") {
+			outputBase = outputBase[:len(outputBase)-len(ext)]
+		}
+	}
+
+	// Generate code
+	if *genType == "cpp" || *genType == "all" {
+		opts := generator.CppOptions{Decoder: *decoder, Plain: *plain, Vectors: *vectors, Printer: *printer}
+		var err error
+		if *output == stdio {
+			err = writeCppPart(device, *namespace, outputBase, *part, opts, stdout)
+		} else {
+			err = writeCpp(device, *namespace, outputBase, opts, mode, stdout)
+		}
+		if err != nil {
+			fmt.Fprintf(stderr, "Error %v\n", err)
+			return 1
 		}
-		if *genType == "cpp" {
-			*output = base
+	}
+	if *genType == "c" {
+		var err error
+		if *output == stdio {
+			err = writeCPart(device, outputBase, *part, stdout)
 		} else {
-			*output = base + ".go"
+			err = writeC(device, outputBase, mode, stdout)
+		}
+		if err != nil {
+			fmt.Fprintf(stderr, "Error %v\n", err)
+			return 1
+		}
+	}
+	if *genType == "py" {
+		pyFileName := outputBase + ".py"
+		if *output == stdio {
+			pyFileName = stdio
+		}
+		if err := writePython(device, pyFileName, mode, stdout); err != nil {
+			fmt.Fprintf(stderr, "Error %v\n", err)
+			return 1
+		}
+	}
+	if *genType == "rust" {
+		rsFileName := outputBase + ".rs"
+		if *output == stdio {
+			rsFileName = stdio
+		}
+		if err := writeRust(device, rsFileName, mode, stdout); err != nil {
+			fmt.Fprintf(stderr, "Error %v\n", err)
+			return 1
+		}
+	}
+	if *genType == "ts" {
+		tsFileName := outputBase + ".ts"
+		if *output == stdio {
+			tsFileName = stdio
+		}
+		if err := writeTypeScript(device, tsFileName, mode, stdout); err != nil {
+			fmt.Fprintf(stderr, "Error %v\n", err)
+			return 1
+		}
+	}
+	if *genType == "jsonschema" {
+		schemaFileName := *output
+		if *output == "" {
+			schemaFileName = outputBase + ".schema.json"
+		}
+		if err := writeJSONSchema(device, schemaFileName, mode, stdout); err != nil {
+			fmt.Fprintf(stderr, "Error %v\n", err)
+			return 1
+		}
+	}
+	if *genType == "ksy" {
+		ksyFileName := *output
+		if *output == "" {
+			ksyFileName = outputBase + ".ksy"
+		}
+		if err := writeKaitai(device, ksyFileName, mode, stdout); err != nil {
+			fmt.Fprintf(stderr, "Error %v\n", err)
+			return 1
+		}
+	}
+	if *genType == "fbs" {
+		fbsFileName := *output
+		if *output == "" {
+			fbsFileName = outputBase + ".fbs"
+		}
+		if err := writeFlatBuffers(device, fbsFileName, mode, stdout); err != nil {
+			fmt.Fprintf(stderr, "Error %v\n", err)
+			return 1
 		}
 	}
+	if *genType == "go" || *genType == "all" {
+		goFileName := *output
+		if *output == "" || *genType == "all" {
+			goFileName = outputBase + ".go"
+		}
+		if *pkgPath != "" {
+			dir := filepath.Join(*pkgPath, *pkg)
+			if err := os.MkdirAll(dir, 0755); err != nil {
+				fmt.Fprintf(stderr, "Error creating the package directory: %v\n", err)
+				return 1
+			}
+			goFileName = filepath.Join(dir, filepath.Base(goFileName))
+		}
+		goOpts := generator.GoOptions{
+			Decoder:         *decoder,
+			BitfieldStrings: *bfStrings,
+			ReuseSlices:     *reuse,
+			JSON:            *jsonCodec,
+			Framing:         *framing,
+			FrameCRC:        *frameCRC,
+			Pool:            *pool,
+			Unexported:      *unexport,
+		}
+		err := writeGo(device, *pkg, goFileName, goOpts, mode, stdout)
+		if err != nil {
+			fmt.Fprintf(stderr, "Error %v\n", err)
+			return 1
+		}
+		if *goTest {
+			if err := writeGoTest(device, *pkg, strings.TrimSuffix(goFileName, ".go")+"_test.go",
+				generator.GoOptions{Unexported: *unexport}, mode, stdout); err != nil {
+				fmt.Fprintf(stderr, "Error %v\n", err)
+				return 1
+			}
+		}
+		if *goBench {
+			if err := writeGoBench(device, *pkg, strings.TrimSuffix(goFileName, ".go")+"_bench_test.go", goOpts, mode, stdout); err != nil {
+				fmt.Fprintf(stderr, "Error %v\n", err)
+				return 1
+			}
+		}
+	}
+	return 0
+}
 
-	// Read input file
-	inputData, err := os.ReadFile(inputFile)
+// checkFiles parses and validates the input files, the unused bit field bits and the oversized
+// bit fields are reported as warnings in the non-strict mode. It returns the process exit code.
+func checkFiles(inputFiles []string, opts parser.ParseOptions, stdin io.Reader, stdout, stderr io.Writer) int {
+	res := 0
+	for _, inputFile := range inputFiles {
+		inputData, err := readInput(inputFile, stdin)
+		if err != nil {
+			fmt.Fprintf(stderr, "Error reading input file %s: %v\n", inputFile, err)
+			res = 1
+			continue
+		}
+		device, err := parser.ParseWithOptions(string(inputData), opts)
+		if err != nil {
+			fmt.Fprintf(stderr, "Error in %s: %v\n", inputFile, err)
+			res = 1
+			continue
+		}
+		for _, gap := range parser.AnalyzeBitfields(device) {
+			fmt.Fprintf(stderr, "Warning in %s: %s: bit field '%s' in register '%s': bits %s are not used\n",
+				inputFile, gap.Pos, gap.Field, gap.Register, parser.FormatBitRanges(gap.Bits))
+		}
+		for _, o := range parser.AnalyzeBitfieldSizes(device) {
+			fmt.Fprintf(stderr, "Warning in %s: %s: bit field '%s' in register '%s': base type '%s' is oversized, the members fit '%s'\n",
+				inputFile, o.Pos, o.Field, o.Register, o.Base, o.Fit)
+		}
+		fmt.Fprintf(stdout, "%s is valid\n", inputFile)
+	}
+	return res
+}
+
+// dumpFile writes the parsed device as JSON to the output file, the standard output is
+// used by default. It returns the process exit code.
+func dumpFile(inputFile, output string, opts parser.ParseOptions, mode overwriteMode, stdin io.Reader, stdout, stderr io.Writer) int {
+	inputData, err := readInput(inputFile, stdin)
+	if err != nil {
+		fmt.Fprintf(stderr, "Error reading input file %s: %v\n", inputFile, err)
+		return 1
+	}
+	device, err := parser.ParseWithOptions(string(inputData), opts)
+	if err != nil {
+		fmt.Fprintf(stderr, "Error parsing input: %v\n", err)
+		return 1
+	}
+	data, err := parser.DumpJSON(device)
 	if err != nil {
-		fmt.Fprintf(os.Stderr, "Error reading input file %s: %v\n", inputFile, err)
-		os.Exit(1)
+		fmt.Fprintf(stderr, "Error encoding the device: %v\n", err)
+		return 1
 	}
+	if output == "" {
+		output = stdio
+	}
+	if err := writeFile(output, string(data)+"\n", mode, stdout); err != nil {
+		fmt.Fprintf(stderr, "Error %v\n", err)
+		return 1
+	}
+	return 0
+}
 
-	// Parse the input
-	device, err := parser.Parse(string(inputData))
+// listRegisters prints the registers of the device one per line sorted by ID: the ID, the name,
+// the access and the number of the fields. It returns the process exit code.
+func listRegisters(inputFile string, opts parser.ParseOptions, stdin io.Reader, stdout, stderr io.Writer) int {
+	inputData, err := readInput(inputFile, stdin)
+	if err != nil {
+		fmt.Fprintf(stderr, "Error reading input file %s: %v\n", inputFile, err)
+		return 1
+	}
+	device, err := parser.ParseWithOptions(string(inputData), opts)
 	if err != nil {
-		fmt.Fprintf(os.Stderr, "Error parsing input: %v\n", err)
-		os.Exit(1)
+		fmt.Fprintf(stderr, "Error parsing input: %v\n", err)
+		return 1
 	}
+	registers := append([]*parser.Register(nil), device.Registers...)
+	sort.SliceStable(registers, func(i, j int) bool { return registers[i].Number() < registers[j].Number() })
 
-	// Generate code
-	if *genType == "cpp" {
-		// Remove extension from output if it was specified
-		outputBase := *output
-		if ext := filepath.Ext(outputBase); ext == ".h" || ext == ".hpp" || ext == ".cpp" {
-			outputBase = outputBase[:len(outputBase)-len(ext)]
+	w := tabwriter.NewWriter(stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "ID\tNAME\tACCESS\tFIELDS")
+	for _, reg := range registers {
+		access := reg.Specifier
+		if access == "" {
+			access = "rw"
 		}
+		fmt.Fprintf(w, "%d\t%s\t%s\t%d\n", reg.Number(), reg.Name, access, len(reg.Body.Fields()))
+	}
+	if err := w.Flush(); err != nil {
+		fmt.Fprintf(stderr, "Error %v\n", err)
+		return 1
+	}
+	return 0
+}
+
+// printStats prints the read and the write wire sizes of the registers sorted by ID: the constant
+// bytes, the terms of the variable bytes and the worst case. It returns the process exit code.
+func printStats(inputFile string, opts parser.ParseOptions, stdin io.Reader, stdout, stderr io.Writer) int {
+	inputData, err := readInput(inputFile, stdin)
+	if err != nil {
+		fmt.Fprintf(stderr, "Error reading input file %s: %v\n", inputFile, err)
+		return 1
+	}
+	device, err := parser.ParseWithOptions(string(inputData), opts)
+	if err != nil {
+		fmt.Fprintf(stderr, "Error parsing input: %v\n", err)
+		return 1
+	}
+	stats, err := generator.Stats(device)
+	if err != nil {
+		fmt.Fprintf(stderr, "Error calculating the sizes: %v\n", err)
+		return 1
+	}
+	sort.SliceStable(stats, func(i, j int) bool { return stats[i].ID < stats[j].ID })
 
-		hppFileName := outputBase + ".h"
-		cppFileName := outputBase + ".cpp"
+	w := tabwriter.NewWriter(stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "ID\tNAME\tDIR\tCONST\tVARIABLE\tMAX")
+	for _, rs := range stats {
+		for _, d := range []struct {
+			name string
+			size generator.WireSize
+		}{{"read", rs.Read}, {"write", rs.Write}} {
+			formula := d.size.Formula()
+			if formula == "" {
+				formula = "-"
+			}
+			fmt.Fprintf(w, "%d\t%s\t%s\t%d\t%s\t%d\n", rs.ID, rs.Name, d.name, d.size.Const, formula, d.size.Max)
+		}
+	}
+	if err := w.Flush(); err != nil {
+		fmt.Fprintf(stderr, "Error %v\n", err)
+		return 1
+	}
+	return 0
+}
 
-		// Use only the base filename (without directory path) for includes and guards
-		baseHppFileName := filepath.Base(hppFileName)
-		hpp, cpp, err := generator.GenerateHppCpp(device, *namespace, baseHppFileName)
+// formatFiles rewrites the input files in the canonical form, the device read from the standard
+// input is written to the standard output. It returns the process exit code.
+func formatFiles(name string, inputFiles []string, stdin io.Reader, stdout, stderr io.Writer) int {
+	if len(inputFiles) == 0 {
+		fmt.Fprintf(stderr, "Usage: %s fmt input.pa...\n", name)
+		return 1
+	}
+	res := 0
+	for _, inputFile := range inputFiles {
+		inputData, err := readInput(inputFile, stdin)
 		if err != nil {
-			fmt.Fprintf(os.Stderr, "Error generating code: %v\n", err)
-			os.Exit(1)
+			fmt.Fprintf(stderr, "Error reading input file %s: %v\n", inputFile, err)
+			res = 1
+			continue
 		}
-		// Write output file
-		err = os.WriteFile(hppFileName, []byte(hpp), 0644)
+		formatted, err := parser.Format(string(inputData))
 		if err != nil {
-			fmt.Fprintf(os.Stderr, "Error writing output file %s: %v\n", hppFileName, err)
-			os.Exit(1)
+			fmt.Fprintf(stderr, "Error in %s: %v\n", inputFile, err)
+			res = 1
+			continue
 		}
-		fmt.Printf("Successfully generated %s\n", hppFileName)
-		err = os.WriteFile(cppFileName, []byte(cpp), 0644)
-		if err != nil {
-			fmt.Fprintf(os.Stderr, "Error writing output file %s: %v\n", cppFileName, err)
-			os.Exit(1)
+		if inputFile == stdio {
+			io.WriteString(stdout, formatted)
+			continue
 		}
-		fmt.Printf("Successfully generated %s\n", cppFileName)
-		return
+		if formatted == string(inputData) {
+			continue
+		}
+		if err := os.WriteFile(inputFile, []byte(formatted), 0644); err != nil {
+			fmt.Fprintf(stderr, "Error writing file %s: %v\n", inputFile, err)
+			res = 1
+			continue
+		}
+		fmt.Fprintf(stdout, "Formatted %s\n", inputFile)
+	}
+	return res
+}
+
+// readInput reads the inputFile file or the standard input if the inputFile is -
+func readInput(inputFile string, stdin io.Reader) ([]byte, error) {
+	if inputFile == stdio {
+		return io.ReadAll(stdin)
 	}
-	code, err := generator.GenerateGo(device, *pkg)
+	return os.ReadFile(inputFile)
+}
+
+// writeCpp generates the .h and .cpp files named by outputBase and the runtime
+// headers the generated code includes next to them
+func writeCpp(device *parser.Device, namespace, outputBase string, opts generator.CppOptions, mode overwriteMode, stdout io.Writer) error {
+	hppFileName := outputBase + ".h"
+	cppFileName := outputBase + ".cpp"
+
+	// Use only the base filename (without directory path) for includes and guards
+	hpp, cpp, err := generator.GenerateHppCppWithOptions(device, namespace, filepath.Base(hppFileName), opts)
 	if err != nil {
-		fmt.Fprintf(os.Stderr, "Error generating code: %v\n", err)
-		os.Exit(1)
+		return fmt.Errorf("generating code: %w", err)
 	}
 
-	// Write output file
-	err = os.WriteFile(*output, []byte(code), 0644)
+	files := []struct{ name, content string }{
+		{hppFileName, hpp},
+		{cppFileName, cpp},
+		{filepath.Join(filepath.Dir(hppFileName), "bigendian.h"), generator.GenerateBigEndianHeader()},
+	}
+	if device.HasLittleEndianFields() {
+		files = append(files, struct{ name, content string }{
+			filepath.Join(filepath.Dir(hppFileName), "littleendian.h"), generator.GenerateLittleEndianHeader()})
+	}
+	for _, f := range files {
+		if err := writeFile(f.name, f.content, mode, stdout); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// writeCppPart writes only one part of the C++ code to the standard output, the
+// runtime headers are not written
+func writeCppPart(device *parser.Device, namespace, outputBase, part string, opts generator.CppOptions, stdout io.Writer) error {
+	hpp, cpp, err := generator.GenerateHppCppWithOptions(device, namespace, filepath.Base(outputBase)+".h", opts)
 	if err != nil {
-		fmt.Fprintf(os.Stderr, "Error writing output file %s: %v\n", *output, err)
-		os.Exit(1)
+		return fmt.Errorf("generating code: %w", err)
+	}
+	content := hpp
+	if part == "cpp" {
+		content = cpp
 	}
+	return writeFile(stdio, content, overwrite, stdout)
+}
 
-	fmt.Printf("Successfully generated %s\n", *output)
+// writeC generates the .h and .c files named by outputBase, the C code needs no runtime headers
+func writeC(device *parser.Device, outputBase string, mode overwriteMode, stdout io.Writer) error {
+	hFileName := outputBase + ".h"
+	h, c, err := generator.GenerateHC(device, filepath.Base(hFileName))
+	if err != nil {
+		return fmt.Errorf("generating code: %w", err)
+	}
+	if err := writeFile(hFileName, h, mode, stdout); err != nil {
+		return err
+	}
+	return writeFile(outputBase+".c", c, mode, stdout)
+}
+
+// writeCPart writes only one part of the C code to the standard output
+func writeCPart(device *parser.Device, outputBase, part string, stdout io.Writer) error {
+	h, c, err := generator.GenerateHC(device, filepath.Base(outputBase)+".h")
+	if err != nil {
+		return fmt.Errorf("generating code: %w", err)
+	}
+	content := h
+	if part == "c" {
+		content = c
+	}
+	return writeFile(stdio, content, overwrite, stdout)
+}
+
+// writeGo generates the Go code into the fileName file
+func writeGo(device *parser.Device, pkg, fileName string, opts generator.GoOptions, mode overwriteMode, stdout io.Writer) error {
+	code, err := generator.GenerateGoWithOptions(device, pkg, opts)
+	if err != nil {
+		return fmt.Errorf("generating code: %w", err)
+	}
+	return writeFile(fileName, code, mode, stdout)
+}
+
+// writePython generates the Python module into the fileName file
+func writePython(device *parser.Device, fileName string, mode overwriteMode, stdout io.Writer) error {
+	code, err := generator.GeneratePython(device)
+	if err != nil {
+		return fmt.Errorf("generating code: %w", err)
+	}
+	return writeFile(fileName, code, mode, stdout)
+}
+
+// writeRust generates the Rust module into the fileName file
+func writeRust(device *parser.Device, fileName string, mode overwriteMode, stdout io.Writer) error {
+	code, err := generator.GenerateRust(device)
+	if err != nil {
+		return fmt.Errorf("generating code: %w", err)
+	}
+	return writeFile(fileName, code, mode, stdout)
+}
+
+// writeTypeScript generates the TypeScript module into the fileName file
+func writeTypeScript(device *parser.Device, fileName string, mode overwriteMode, stdout io.Writer) error {
+	code, err := generator.GenerateTypeScript(device)
+	if err != nil {
+		return fmt.Errorf("generating code: %w", err)
+	}
+	return writeFile(fileName, code, mode, stdout)
+}
+
+// writeJSONSchema generates the JSON Schema of the registers into the fileName file
+func writeJSONSchema(device *parser.Device, fileName string, mode overwriteMode, stdout io.Writer) error {
+	schema, err := generator.GenerateJSONSchema(device)
+	if err != nil {
+		return fmt.Errorf("generating schema: %w", err)
+	}
+	return writeFile(fileName, schema, mode, stdout)
+}
+
+// writeKaitai generates the Kaitai Struct definition of the registers into the fileName file
+func writeKaitai(device *parser.Device, fileName string, mode overwriteMode, stdout io.Writer) error {
+	ksy, err := generator.GenerateKaitai(device)
+	if err != nil {
+		return fmt.Errorf("generating definition: %w", err)
+	}
+	return writeFile(fileName, ksy, mode, stdout)
+}
+
+// writeFlatBuffers generates the FlatBuffers schema of the registers into the fileName file
+func writeFlatBuffers(device *parser.Device, fileName string, mode overwriteMode, stdout io.Writer) error {
+	fbs, err := generator.GenerateFlatBuffers(device)
+	if err != nil {
+		return fmt.Errorf("generating schema: %w", err)
+	}
+	return writeFile(fileName, fbs, mode, stdout)
+}
+
+// writeGoTest generates the Go round-trip tests of the registers into the fileName file
+func writeGoTest(device *parser.Device, pkg, fileName string, opts generator.GoOptions, mode overwriteMode, stdout io.Writer) error {
+	code, err := generator.GenerateGoTestWithOptions(device, pkg, opts)
+	if err != nil {
+		return fmt.Errorf("generating tests: %w", err)
+	}
+	return writeFile(fileName, code, mode, stdout)
+}
+
+// writeGoBench generates the Go benchmarks of the registers into the fileName file
+func writeGoBench(device *parser.Device, pkg, fileName string, opts generator.GoOptions, mode overwriteMode, stdout io.Writer) error {
+	code, err := generator.GenerateGoBench(device, pkg, opts)
+	if err != nil {
+		return fmt.Errorf("generating benchmarks: %w", err)
+	}
+	return writeFile(fileName, code, mode, stdout)
+}
+
+// writeFile writes the content to the fileName file or to the standard output
+// if the fileName is -. The file is not touched if it already has the content,
+// the missing directories of the file are created. The mode tells if an existing
+// file may be overwritten
+func writeFile(fileName, content string, mode overwriteMode, stdout io.Writer) error {
+	if fileName == stdio {
+		if _, err := io.WriteString(stdout, content); err != nil {
+			return fmt.Errorf("writing the standard output: %w", err)
+		}
+		return nil
+	}
+	// Skip writing the same content to not bump the file modification time
+	if existing, err := os.ReadFile(fileName); err == nil && string(existing) == content {
+		fmt.Fprintf(stdout, "Unchanged %s\n", fileName)
+		return nil
+	}
+	info, err := os.Stat(fileName)
+	switch {
+	case err == nil && mode == noOverwrite:
+		return fmt.Errorf("output file %s already exists, remove it or omit -no-overwrite", fileName)
+	case err == nil && info.Mode().Perm()&0200 == 0 && mode != forceOverwrite:
+		return fmt.Errorf("output file %s is read-only, use -force to overwrite it", fileName)
+	case err == nil && info.Mode().Perm()&0200 == 0:
+		// the file is made writable for the time of writing only
+		if err := os.Chmod(fileName, info.Mode().Perm()|0200); err != nil {
+			return fmt.Errorf("making output file %s writable: %w", fileName, err)
+		}
+		defer os.Chmod(fileName, info.Mode().Perm())
+	}
+	if err := os.MkdirAll(filepath.Dir(fileName), 0755); err != nil {
+		return fmt.Errorf("creating output directory for %s: %w", fileName, err)
+	}
+	if err := os.WriteFile(fileName, []byte(content), 0644); err != nil {
+		return fmt.Errorf("writing output file %s: %w", fileName, err)
+	}
+	fmt.Fprintf(stdout, "Successfully generated %s\n", fileName)
+	return nil
 }
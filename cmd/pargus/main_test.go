@@ -0,0 +1,626 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const testDevice = `device sensor
+
+register Status(1) {
+    value uint16;
+};
+`
+
+func TestStdinToStdout(t *testing.T) {
+	var stdout, stderr bytes.Buffer
+	code := run("pargus", []string{"-t", "go", "-p", "sensor", "-o", "-", "-"}, strings.NewReader(testDevice), &stdout, &stderr)
+	require.Equal(t, 0, code, stderr.String())
+	assert.Contains(t, stdout.String(), "package sensor")
+	assert.Contains(t, stdout.String(), "type Status struct")
+	assert.NotContains(t, stdout.String(), "Successfully generated")
+
+	// the standard output is the default for the standard input
+	stdout.Reset()
+	code = run("pargus", []string{"-t", "go", "-p", "sensor", "-"}, strings.NewReader(testDevice), &stdout, &stderr)
+	require.Equal(t, 0, code, stderr.String())
+	assert.Contains(t, stdout.String(), "package sensor")
+
+	stdout.Reset()
+	code = run("pargus", []string{"-t", "cpp", "-n", "sensor", "-"}, strings.NewReader(testDevice), &stdout, &stderr)
+	require.Equal(t, 0, code, stderr.String())
+	assert.Contains(t, stdout.String(), "#pragma once")
+
+	stdout.Reset()
+	code = run("pargus", []string{"-t", "cpp", "-n", "sensor", "-part", "cpp", "-"}, strings.NewReader(testDevice), &stdout, &stderr)
+	require.Equal(t, 0, code, stderr.String())
+	assert.Contains(t, stdout.String(), `#include "sensor.h"`)
+}
+
+func TestStdinToFile(t *testing.T) {
+	output := filepath.Join(t.TempDir(), "sensor.go")
+	var stdout, stderr bytes.Buffer
+	code := run("pargus", []string{"-t", "go", "-p", "sensor", "-o", output, "-"}, strings.NewReader(testDevice), &stdout, &stderr)
+	require.Equal(t, 0, code, stderr.String())
+	assert.Contains(t, stdout.String(), "Successfully generated "+output)
+
+	data, err := os.ReadFile(output)
+	require.NoError(t, err)
+	assert.Contains(t, string(data), "package sensor")
+}
+
+func TestInvalidArguments(t *testing.T) {
+	var stdout, stderr bytes.Buffer
+	code := run("pargus", []string{"-t", "cpp", "-n", "sensor", "-part", "x", "-"}, strings.NewReader(testDevice), &stdout, &stderr)
+	assert.Equal(t, 1, code)
+	assert.Contains(t, stderr.String(), "C++ part must be 'h' or 'cpp'")
+
+	stderr.Reset()
+	code = run("pargus", []string{"-t", "go", "-p", "sensor", "-"}, strings.NewReader("device"), &stdout, &stderr)
+	assert.Equal(t, 1, code)
+	assert.Contains(t, stderr.String(), "Error parsing input")
+}
+
+func TestGenerateAll(t *testing.T) {
+	dir := t.TempDir()
+	input := filepath.Join(dir, "sensor.pa")
+	require.NoError(t, os.WriteFile(input, []byte(testDevice), 0644))
+
+	var stdout, stderr bytes.Buffer
+	code := run("pargus", []string{"-t", "all", "-n", "sensor", "-p", "sensor", "-o", filepath.Join(dir, "out", "dev.go"), input},
+		nil, &stdout, &stderr)
+	require.Equal(t, 0, code, stderr.String())
+	assert.FileExists(t, filepath.Join(dir, "out", "dev.go"), "the output directory is created")
+
+	stdout.Reset()
+	stderr.Reset()
+	code = run("pargus", []string{"-t", "all", "-n", "sensor", "-p", "sensor", "-o", filepath.Join(dir, "dev.go"), input},
+		nil, &stdout, &stderr)
+	require.Equal(t, 0, code, stderr.String())
+	for _, name := range []string{"dev.go", "dev.h", "dev.cpp", "bigendian.h"} {
+		assert.FileExists(t, filepath.Join(dir, name))
+	}
+
+	// the output names are derived from the input file name by default
+	t.Chdir(dir)
+	code = run("pargus", []string{"-t", "all", "-n", "sensor", "-p", "sensor", input}, nil, &stdout, &stderr)
+	require.Equal(t, 0, code, stderr.String())
+	for _, name := range []string{"sensor.go", "sensor.h", "sensor.cpp"} {
+		assert.FileExists(t, filepath.Join(dir, name))
+	}
+
+	// both the namespace and the package are required
+	stderr.Reset()
+	code = run("pargus", []string{"-t", "all", "-n", "sensor", input}, nil, &stdout, &stderr)
+	assert.Equal(t, 1, code)
+	assert.Contains(t, stderr.String(), "-p (package) parameter is required")
+	stderr.Reset()
+	code = run("pargus", []string{"-t", "all", "-p", "sensor", input}, nil, &stdout, &stderr)
+	assert.Equal(t, 1, code)
+	assert.Contains(t, stderr.String(), "-n (namespace) parameter is required")
+	stderr.Reset()
+	code = run("pargus", []string{"-t", "all", "-n", "sensor", "-p", "sensor", "-o", "-", input}, nil, &stdout, &stderr)
+	assert.Equal(t, 1, code)
+	assert.Contains(t, stderr.String(), "-t all cannot write to the standard output")
+}
+
+func TestGenerateC(t *testing.T) {
+	dir := t.TempDir()
+	input := filepath.Join(dir, "sensor.pa")
+	require.NoError(t, os.WriteFile(input, []byte(testDevice), 0644))
+
+	var stdout, stderr bytes.Buffer
+	code := run("pargus", []string{"-t", "c", "-o", filepath.Join(dir, "dev.h"), input}, nil, &stdout, &stderr)
+	require.Equal(t, 0, code, stderr.String())
+	data, err := os.ReadFile(filepath.Join(dir, "dev.c"))
+	require.NoError(t, err)
+	assert.Contains(t, string(data), `#include "dev.h"`)
+	assert.FileExists(t, filepath.Join(dir, "dev.h"))
+	assert.NoFileExists(t, filepath.Join(dir, "bigendian.h"))
+
+	stdout.Reset()
+	code = run("pargus", []string{"-t", "c", "-part", "c", "-"}, strings.NewReader(testDevice), &stdout, &stderr)
+	require.Equal(t, 0, code, stderr.String())
+	assert.Contains(t, stdout.String(), `#include "sensor.h"`)
+
+	stderr.Reset()
+	code = run("pargus", []string{"-t", "c", "-part", "cpp", "-"}, strings.NewReader(testDevice), &stdout, &stderr)
+	assert.Equal(t, 1, code)
+	assert.Contains(t, stderr.String(), "C part must be 'h' or 'c'")
+}
+
+func TestGenerateCppIntoSubdirectory(t *testing.T) {
+	dir := t.TempDir()
+	input := filepath.Join(dir, "sensor.pa")
+	require.NoError(t, os.WriteFile(input, []byte(testDevice), 0644))
+	out := filepath.Join(dir, "gen", "cpp")
+
+	var stdout, stderr bytes.Buffer
+	code := run("pargus", []string{"-t", "cpp", "-n", "sensor", "-o", filepath.Join(out, "dev.h"), input}, nil, &stdout, &stderr)
+	require.Equal(t, 0, code, stderr.String())
+	for _, name := range []string{"dev.h", "dev.cpp", "bigendian.h"} {
+		assert.FileExists(t, filepath.Join(out, name))
+	}
+	data, err := os.ReadFile(filepath.Join(out, "dev.cpp"))
+	require.NoError(t, err)
+	assert.Contains(t, string(data), `#include "dev.h"`)
+	assert.NotContains(t, string(data), "gen/cpp")
+}
+
+func TestGeneratePython(t *testing.T) {
+	dir := t.TempDir()
+	input := filepath.Join(dir, "sensor.pa")
+	require.NoError(t, os.WriteFile(input, []byte(testDevice), 0644))
+
+	var stdout, stderr bytes.Buffer
+	code := run("pargus", []string{"-t", "py", "-o", filepath.Join(dir, "dev.py"), input}, nil, &stdout, &stderr)
+	require.Equal(t, 0, code, stderr.String())
+	data, err := os.ReadFile(filepath.Join(dir, "dev.py"))
+	require.NoError(t, err)
+	assert.Contains(t, string(data), "@dataclasses.dataclass")
+
+	stdout.Reset()
+	code = run("pargus", []string{"-t", "py", "-"}, strings.NewReader(testDevice), &stdout, &stderr)
+	require.Equal(t, 0, code, stderr.String())
+	assert.Contains(t, stdout.String(), "import struct")
+}
+
+func TestGenerateRust(t *testing.T) {
+	dir := t.TempDir()
+	input := filepath.Join(dir, "sensor.pa")
+	require.NoError(t, os.WriteFile(input, []byte(testDevice), 0644))
+
+	var stdout, stderr bytes.Buffer
+	code := run("pargus", []string{"-t", "rust", "-o", filepath.Join(dir, "dev.rs"), input}, nil, &stdout, &stderr)
+	require.Equal(t, 0, code, stderr.String())
+	data, err := os.ReadFile(filepath.Join(dir, "dev.rs"))
+	require.NoError(t, err)
+	assert.Contains(t, string(data), "pub struct Status {")
+
+	stdout.Reset()
+	code = run("pargus", []string{"-t", "rust", "-"}, strings.NewReader(testDevice), &stdout, &stderr)
+	require.Equal(t, 0, code, stderr.String())
+	assert.Contains(t, stdout.String(), "pub fn to_bytes(&self) -> Result<Vec<u8>, Error> {")
+}
+
+func TestGenerateTypeScript(t *testing.T) {
+	dir := t.TempDir()
+	input := filepath.Join(dir, "sensor.pa")
+	require.NoError(t, os.WriteFile(input, []byte(testDevice), 0644))
+
+	var stdout, stderr bytes.Buffer
+	code := run("pargus", []string{"-t", "ts", "-o", filepath.Join(dir, "dev.ts"), input}, nil, &stdout, &stderr)
+	require.Equal(t, 0, code, stderr.String())
+	data, err := os.ReadFile(filepath.Join(dir, "dev.ts"))
+	require.NoError(t, err)
+	assert.Contains(t, string(data), "export interface Status {")
+
+	stdout.Reset()
+	code = run("pargus", []string{"-t", "ts", "-"}, strings.NewReader(testDevice), &stdout, &stderr)
+	require.Equal(t, 0, code, stderr.String())
+	assert.Contains(t, stdout.String(), "export function encodeStatus(c: Status): Uint8Array {")
+}
+
+func TestGenerateJSONSchema(t *testing.T) {
+	dir := t.TempDir()
+	input := filepath.Join(dir, "sensor.pa")
+	require.NoError(t, os.WriteFile(input, []byte(testDevice), 0644))
+
+	var stdout, stderr bytes.Buffer
+	code := run("pargus", []string{"-t", "jsonschema", "-o", filepath.Join(dir, "sensor.schema.json"), input}, nil, &stdout, &stderr)
+	require.Equal(t, 0, code, stderr.String())
+	data, err := os.ReadFile(filepath.Join(dir, "sensor.schema.json"))
+	require.NoError(t, err)
+	assert.Contains(t, string(data), `"$ref": "#/$defs/Status"`)
+
+	stdout.Reset()
+	code = run("pargus", []string{"-t", "jsonschema", "-"}, strings.NewReader(testDevice), &stdout, &stderr)
+	require.Equal(t, 0, code, stderr.String())
+	assert.True(t, json.Valid(stdout.Bytes()))
+}
+
+func TestGenerateKaitai(t *testing.T) {
+	dir := t.TempDir()
+	input := filepath.Join(dir, "sensor.pa")
+	require.NoError(t, os.WriteFile(input, []byte(testDevice), 0644))
+
+	var stdout, stderr bytes.Buffer
+	code := run("pargus", []string{"-t", "ksy", "-o", filepath.Join(dir, "sensor.ksy"), input}, nil, &stdout, &stderr)
+	require.Equal(t, 0, code, stderr.String())
+	data, err := os.ReadFile(filepath.Join(dir, "sensor.ksy"))
+	require.NoError(t, err)
+	assert.Contains(t, string(data), "types:\n  status:\n")
+
+	stdout.Reset()
+	code = run("pargus", []string{"-t", "ksy", "-"}, strings.NewReader(testDevice), &stdout, &stderr)
+	require.Equal(t, 0, code, stderr.String())
+	assert.Equal(t, string(data), stdout.String())
+}
+
+func TestGenerateFlatBuffers(t *testing.T) {
+	dir := t.TempDir()
+	input := filepath.Join(dir, "sensor.pa")
+	require.NoError(t, os.WriteFile(input, []byte(testDevice), 0644))
+
+	var stdout, stderr bytes.Buffer
+	code := run("pargus", []string{"-t", "fbs", "-o", filepath.Join(dir, "sensor.fbs"), input}, nil, &stdout, &stderr)
+	require.Equal(t, 0, code, stderr.String())
+	data, err := os.ReadFile(filepath.Join(dir, "sensor.fbs"))
+	require.NoError(t, err)
+	assert.Contains(t, string(data), "table Status {\n")
+
+	stdout.Reset()
+	code = run("pargus", []string{"-t", "fbs", "-"}, strings.NewReader(testDevice), &stdout, &stderr)
+	require.Equal(t, 0, code, stderr.String())
+	assert.Equal(t, string(data), stdout.String())
+}
+
+func TestUnchangedOutput(t *testing.T) {
+	dir := t.TempDir()
+	input := filepath.Join(dir, "sensor.pa")
+	require.NoError(t, os.WriteFile(input, []byte(testDevice), 0644))
+	args := []string{"-t", "all", "-n", "sensor", "-p", "sensor", "-o", filepath.Join(dir, "sensor"), input}
+
+	var stdout, stderr bytes.Buffer
+	require.Equal(t, 0, run("pargus", args, nil, &stdout, &stderr), stderr.String())
+	assert.Contains(t, stdout.String(), "Successfully generated")
+
+	files := []string{"sensor.go", "sensor.h", "sensor.cpp", "bigendian.h"}
+	past := time.Now().Add(-time.Hour).Truncate(time.Second)
+	for _, name := range files {
+		require.NoError(t, os.Chtimes(filepath.Join(dir, name), past, past))
+	}
+
+	stdout.Reset()
+	require.Equal(t, 0, run("pargus", args, nil, &stdout, &stderr), stderr.String())
+	assert.NotContains(t, stdout.String(), "Successfully generated")
+	for _, name := range files {
+		assert.Contains(t, stdout.String(), "Unchanged "+filepath.Join(dir, name))
+		fi, err := os.Stat(filepath.Join(dir, name))
+		require.NoError(t, err)
+		assert.True(t, fi.ModTime().Equal(past), name)
+	}
+
+	// a changed input rewrites the files
+	require.NoError(t, os.WriteFile(input, []byte(testDevice+"register Other(2) {\n    v uint8;\n};\n"), 0644))
+	stdout.Reset()
+	require.Equal(t, 0, run("pargus", args, nil, &stdout, &stderr), stderr.String())
+	assert.Contains(t, stdout.String(), "Successfully generated "+filepath.Join(dir, "sensor.go"))
+	assert.Contains(t, stdout.String(), "Unchanged "+filepath.Join(dir, "bigendian.h"))
+}
+
+func TestNoOverwrite(t *testing.T) {
+	dir := t.TempDir()
+	input := filepath.Join(dir, "sensor.pa")
+	require.NoError(t, os.WriteFile(input, []byte(testDevice), 0644))
+	output := filepath.Join(dir, "sensor")
+	args := []string{"-t", "all", "-n", "sensor", "-p", "sensor", "-no-overwrite", "-o", output, input}
+
+	var stdout, stderr bytes.Buffer
+	require.Equal(t, 0, run("pargus", args, nil, &stdout, &stderr), stderr.String())
+
+	// the files with the same content are not overwritten, so they are not errors
+	stdout.Reset()
+	require.Equal(t, 0, run("pargus", args, nil, &stdout, &stderr), stderr.String())
+	assert.Contains(t, stdout.String(), "Unchanged "+output+".go")
+
+	for _, name := range []string{"sensor.h", "sensor.cpp", "sensor.go"} {
+		require.NoError(t, os.WriteFile(filepath.Join(dir, name), []byte("// hand-tweaked\n"), 0644))
+	}
+	stderr.Reset()
+	assert.Equal(t, 1, run("pargus", args, nil, &stdout, &stderr))
+	assert.Contains(t, stderr.String(), "output file "+output+".h already exists")
+	for _, name := range []string{"sensor.h", "sensor.cpp", "sensor.go"} {
+		data, err := os.ReadFile(filepath.Join(dir, name))
+		require.NoError(t, err)
+		assert.Equal(t, "// hand-tweaked\n", string(data), name)
+	}
+
+	stderr.Reset()
+	goArgs := []string{"-t", "go", "-p", "sensor", "-no-overwrite", "-o", output + ".go", input}
+	assert.Equal(t, 1, run("pargus", goArgs, nil, &stdout, &stderr))
+	assert.Contains(t, stderr.String(), "output file "+output+".go already exists")
+
+	// overwriting is the default
+	stdout.Reset()
+	require.Equal(t, 0, run("pargus", []string{"-t", "go", "-p", "sensor", "-o", output + ".go", input}, nil, &stdout, &stderr), stderr.String())
+	assert.Contains(t, stdout.String(), "Successfully generated "+output+".go")
+
+	stderr.Reset()
+	assert.Equal(t, 1, run("pargus", []string{"-no-overwrite", "-force", input}, nil, &stdout, &stderr))
+	assert.Contains(t, stderr.String(), "-no-overwrite and -force cannot be used together")
+}
+
+func TestForceOverwrite(t *testing.T) {
+	dir := t.TempDir()
+	input := filepath.Join(dir, "sensor.pa")
+	require.NoError(t, os.WriteFile(input, []byte(testDevice), 0644))
+	output := filepath.Join(dir, "sensor")
+	for _, name := range []string{"sensor.h", "sensor.cpp", "sensor.go"} {
+		require.NoError(t, os.WriteFile(filepath.Join(dir, name), []byte("// read-only\n"), 0444))
+	}
+
+	var stdout, stderr bytes.Buffer
+	args := []string{"-t", "all", "-n", "sensor", "-p", "sensor", "-o", output, input}
+	assert.Equal(t, 1, run("pargus", args, nil, &stdout, &stderr))
+	assert.Contains(t, stderr.String(), "output file "+output+".h is read-only, use -force to overwrite it")
+
+	stderr.Reset()
+	require.Equal(t, 0, run("pargus", append([]string{"-force"}, args...), nil, &stdout, &stderr), stderr.String())
+	for _, name := range []string{"sensor.h", "sensor.cpp", "sensor.go"} {
+		fi, err := os.Stat(filepath.Join(dir, name))
+		require.NoError(t, err)
+		assert.Equal(t, os.FileMode(0444), fi.Mode().Perm(), "the file stays read-only: %s", name)
+		data, err := os.ReadFile(filepath.Join(dir, name))
+		require.NoError(t, err)
+		assert.NotContains(t, string(data), "// read-only", name)
+	}
+}
+
+func TestGoTest(t *testing.T) {
+	output := filepath.Join(t.TempDir(), "sensor.go")
+	var stdout, stderr bytes.Buffer
+	code := run("pargus", []string{"-t", "go", "-p", "sensor", "-go-test", "-o", output, "-"}, strings.NewReader(testDevice), &stdout, &stderr)
+	require.Equal(t, 0, code, stderr.String())
+	testFile := filepath.Join(filepath.Dir(output), "sensor_test.go")
+	assert.Contains(t, stdout.String(), "Successfully generated "+testFile)
+
+	data, err := os.ReadFile(testFile)
+	require.NoError(t, err)
+	assert.Contains(t, string(data), "func TestStatusRoundTrip(t *testing.T) {")
+
+	stderr.Reset()
+	code = run("pargus", []string{"-t", "go", "-p", "sensor", "-go-test", "-o", "-", "-"}, strings.NewReader(testDevice), &stdout, &stderr)
+	assert.Equal(t, 1, code)
+	assert.Contains(t, stderr.String(), "-go-test cannot write to the standard output")
+}
+
+func TestGoBench(t *testing.T) {
+	output := filepath.Join(t.TempDir(), "sensor.go")
+	var stdout, stderr bytes.Buffer
+	code := run("pargus", []string{"-t", "go", "-p", "sensor", "-go-test", "-go-bench", "-o", output, "-"},
+		strings.NewReader(testDevice), &stdout, &stderr)
+	require.Equal(t, 0, code, stderr.String())
+	benchFile := filepath.Join(filepath.Dir(output), "sensor_bench_test.go")
+	assert.Contains(t, stdout.String(), "Successfully generated "+benchFile)
+
+	data, err := os.ReadFile(benchFile)
+	require.NoError(t, err)
+	assert.Contains(t, string(data), "func BenchmarkStatusDeserializeWrite(b *testing.B) {")
+
+	stderr.Reset()
+	code = run("pargus", []string{"-t", "go", "-p", "sensor", "-go-bench", "-o", "-", "-"}, strings.NewReader(testDevice), &stdout, &stderr)
+	assert.Equal(t, 1, code)
+	assert.Contains(t, stderr.String(), "-go-bench cannot write to the standard output")
+}
+
+func TestUnexported(t *testing.T) {
+	output := filepath.Join(t.TempDir(), "sensor.go")
+	var stdout, stderr bytes.Buffer
+	code := run("pargus", []string{"-t", "go", "-p", "sensor", "-unexported", "-go-test", "-o", output, "-"},
+		strings.NewReader(testDevice), &stdout, &stderr)
+	require.Equal(t, 0, code, stderr.String())
+
+	data, err := os.ReadFile(output)
+	require.NoError(t, err)
+	assert.Contains(t, string(data), "type status struct {")
+	assert.Contains(t, string(data), "func (r *status) getValue() uint16 {")
+	data, err = os.ReadFile(filepath.Join(filepath.Dir(output), "sensor_test.go"))
+	require.NoError(t, err)
+	assert.Contains(t, string(data), "r := newStatus()")
+}
+
+func TestPackagePath(t *testing.T) {
+	dir := t.TempDir()
+	input := filepath.Join(dir, "sensor.pa")
+	require.NoError(t, os.WriteFile(input, []byte(testDevice), 0644))
+	pkgPath := filepath.Join(dir, "internal")
+
+	var stdout, stderr bytes.Buffer
+	code := run("pargus", []string{"-t", "go", "-p", "registers", "-package-path", pkgPath, "-go-test", input}, nil, &stdout, &stderr)
+	require.Equal(t, 0, code, stderr.String())
+	goFile := filepath.Join(pkgPath, "registers", "sensor.go")
+	assert.Contains(t, stdout.String(), "Successfully generated "+goFile)
+
+	data, err := os.ReadFile(goFile)
+	require.NoError(t, err)
+	assert.Contains(t, string(data), "\npackage registers\n")
+	data, err = os.ReadFile(filepath.Join(pkgPath, "registers", "sensor_test.go"))
+	require.NoError(t, err)
+	assert.Contains(t, string(data), "\npackage registers\n")
+
+	// only the base name of the output file is used, the C++ files are not moved
+	require.NoError(t, os.MkdirAll(filepath.Join(dir, "out"), 0755))
+	code = run("pargus", []string{"-t", "all", "-n", "ns", "-p", "registers", "-package-path", pkgPath,
+		"-o", filepath.Join(dir, "out", "device"), input}, nil, &stdout, &stderr)
+	require.Equal(t, 0, code, stderr.String())
+	assert.FileExists(t, filepath.Join(pkgPath, "registers", "device.go"))
+	assert.FileExists(t, filepath.Join(dir, "out", "device.h"))
+	assert.NoFileExists(t, filepath.Join(dir, "out", "device.go"))
+
+	stderr.Reset()
+	code = run("pargus", []string{"-t", "go", "-p", "registers", "-package-path", pkgPath, "-o", "-", "-"},
+		strings.NewReader(testDevice), &stdout, &stderr)
+	assert.Equal(t, 1, code)
+	assert.Contains(t, stderr.String(), "-package-path cannot write to the standard output")
+}
+
+func TestCheck(t *testing.T) {
+	dir := t.TempDir()
+	valid := filepath.Join(dir, "valid.pa")
+	require.NoError(t, os.WriteFile(valid, []byte(testDevice), 0644))
+	broken := filepath.Join(dir, "broken.pa")
+	require.NoError(t, os.WriteFile(broken, []byte(testDevice+"register Dup(1) {\n    v uint8;\n};\n"), 0644))
+
+	var stdout, stderr bytes.Buffer
+	require.Equal(t, 0, run("pargus", []string{"-check", valid}, nil, &stdout, &stderr), stderr.String())
+	assert.Equal(t, valid+" is valid\n", stdout.String())
+
+	stdout.Reset()
+	assert.Equal(t, 1, run("pargus", []string{"-check", valid, broken}, nil, &stdout, &stderr))
+	assert.Equal(t, valid+" is valid\n", stdout.String())
+	assert.Contains(t, stderr.String(), "Error in "+broken+": 6:1: duplicate register number 1\nregister Dup(1) {\n^\n")
+
+	stdout.Reset()
+	require.Equal(t, 0, run("pargus", []string{"-check", "-"}, strings.NewReader(testDevice), &stdout, &stderr))
+	assert.Equal(t, "- is valid\n", stdout.String())
+
+	entries, err := os.ReadDir(dir)
+	require.NoError(t, err)
+	assert.Len(t, entries, 2, "nothing is generated")
+}
+
+func TestCheckStrict(t *testing.T) {
+	input := "device sensor\nregister Status(1) {\n    flags uint8{a: 0, b: 4};\n};\n"
+
+	var stdout, stderr bytes.Buffer
+	require.Equal(t, 0, run("pargus", []string{"-check", "-"}, strings.NewReader(input), &stdout, &stderr), stderr.String())
+	assert.Equal(t, "- is valid\n", stdout.String())
+	assert.Equal(t, "Warning in -: 3:5: bit field 'flags' in register 'Status': bits 1-3, 5-7 are not used\n", stderr.String())
+
+	stdout.Reset()
+	stderr.Reset()
+	assert.Equal(t, 1, run("pargus", []string{"-check", "-strict", "-"}, strings.NewReader(input), &stdout, &stderr))
+	assert.Contains(t, stderr.String(), "Error in -: 3:5: bit field 'flags' in register 'Status': bits 1-3, 5-7 are not used, cover them with a reserved member")
+
+	covered := "device sensor\nregister Status(1) {\n    flags uint8{a: 0, reserved: 1-3, b: 4, reserved: 5-7};\n};\n"
+	stdout.Reset()
+	stderr.Reset()
+	require.Equal(t, 0, run("pargus", []string{"-check", "-strict", "-"}, strings.NewReader(covered), &stdout, &stderr), stderr.String())
+	assert.Empty(t, stderr.String())
+}
+
+func TestCheckOversizedBitfield(t *testing.T) {
+	input := "device sensor\nregister Status(1) {\n    flags uint32{a: 0, reserved: 1-31};\n};\n"
+
+	var stdout, stderr bytes.Buffer
+	require.Equal(t, 0, run("pargus", []string{"-check", "-"}, strings.NewReader(input), &stdout, &stderr), stderr.String())
+	assert.Equal(t, "- is valid\n", stdout.String())
+	assert.Equal(t, "Warning in -: 3:5: bit field 'flags' in register 'Status': base type 'uint32' is oversized, the members fit 'uint8'\n", stderr.String())
+
+	stdout.Reset()
+	stderr.Reset()
+	assert.Equal(t, 1, run("pargus", []string{"-check", "-strict", "-"}, strings.NewReader(input), &stdout, &stderr))
+	assert.Contains(t, stderr.String(), "Error in -: 3:5: bit field 'flags' in register 'Status': base type 'uint32' is oversized, the members fit 'uint8'")
+
+	fit := "device sensor\nregister Status(1) {\n    flags uint16{a: 0, reserved: 1-15};\n};\n"
+	stdout.Reset()
+	stderr.Reset()
+	require.Equal(t, 0, run("pargus", []string{"-check", "-strict", "-"}, strings.NewReader(fit), &stdout, &stderr), stderr.String())
+	assert.Empty(t, stderr.String())
+}
+
+func TestDumpAST(t *testing.T) {
+	var stdout, stderr bytes.Buffer
+	require.Equal(t, 0, run("pargus", []string{"-dump-ast", "-"}, strings.NewReader(testDevice), &stdout, &stderr), stderr.String())
+	var dump struct {
+		Name      string
+		Registers []struct {
+			Name   string
+			Number int
+			Fields []struct{ Name, Type, Access string }
+		}
+	}
+	require.NoError(t, json.Unmarshal(stdout.Bytes(), &dump))
+	assert.Equal(t, "sensor", dump.Name)
+	require.Len(t, dump.Registers, 1)
+	assert.Equal(t, "Status", dump.Registers[0].Name)
+	assert.Equal(t, 1, dump.Registers[0].Number)
+	require.Len(t, dump.Registers[0].Fields, 1)
+	assert.Equal(t, "uint16", dump.Registers[0].Fields[0].Type)
+	assert.Equal(t, "rw", dump.Registers[0].Fields[0].Access)
+
+	output := filepath.Join(t.TempDir(), "sensor.json")
+	stdout.Reset()
+	require.Equal(t, 0, run("pargus", []string{"-dump-ast", "-o", output, "-"}, strings.NewReader(testDevice), &stdout, &stderr), stderr.String())
+	assert.Contains(t, stdout.String(), "Successfully generated "+output)
+
+	stderr.Reset()
+	assert.Equal(t, 1, run("pargus", []string{"-dump-ast", "-"}, strings.NewReader("device sensor\nregister A(1) {\n    v foo;\n};\n"), &stdout, &stderr))
+	assert.Contains(t, stderr.String(), "Error parsing input")
+}
+
+func TestListRegisters(t *testing.T) {
+	var stdout, stderr bytes.Buffer
+	input := filepath.Join("..", "..", "pkg", "generator", "testdata", "example.pa")
+	require.Equal(t, 0, run("pargus", []string{"-list-registers", input}, nil, &stdout, &stderr), stderr.String())
+	assert.Equal(t, `ID  NAME       ACCESS  FIELDS
+0   Config     rw      3
+1   Status     r       4
+2   Point      rw      2
+3   DataFrame  rw      3
+`, stdout.String())
+
+	// the registers are sorted by ID regardless of the declaration order
+	stdout.Reset()
+	input2 := "device sensor\nregister B(0x10): w {\n    v uint8;\n};\nregister A(2) {\n};\n"
+	require.Equal(t, 0, run("pargus", []string{"-list-registers", "-"}, strings.NewReader(input2), &stdout, &stderr), stderr.String())
+	assert.Equal(t, "ID  NAME  ACCESS  FIELDS\n2   A     rw      0\n16  B     w       1\n", stdout.String())
+
+	stderr.Reset()
+	assert.Equal(t, 1, run("pargus", []string{"-list-registers"}, nil, &stdout, &stderr))
+	assert.Contains(t, stderr.String(), "exactly one input file is required")
+	assert.Equal(t, 1, run("pargus", []string{"-list-registers", "-"}, strings.NewReader("device sensor\nregister A(1) {\n    v foo;\n};\n"), &stdout, &stderr))
+	assert.Contains(t, stderr.String(), "Error parsing input")
+}
+
+func TestStats(t *testing.T) {
+	var stdout, stderr bytes.Buffer
+	input := filepath.Join("..", "..", "pkg", "generator", "testdata", "example.pa")
+	require.Equal(t, 0, run("pargus", []string{"-stats", input}, nil, &stdout, &stderr), stderr.String())
+	assert.Equal(t, `ID  NAME       DIR    CONST  VARIABLE       MAX
+0   Config     read   3      len(name)      19
+0   Config     write  3      len(name)      19
+1   Status     read   7      flags_count*2  37
+1   Status     write  0      -              0
+2   Point      read   7      -              7
+2   Point      write  7      -              7
+3   DataFrame  read   8      size(points)   22
+3   DataFrame  write  8      size(points)   22
+`, stdout.String())
+
+	stderr.Reset()
+	assert.Equal(t, 1, run("pargus", []string{"-stats"}, nil, &stdout, &stderr))
+	assert.Contains(t, stderr.String(), "exactly one input file is required")
+	assert.Equal(t, 1, run("pargus", []string{"-stats", "-"}, strings.NewReader("device sensor\nregister A(1) {\n    v foo;\n};\n"), &stdout, &stderr))
+	assert.Contains(t, stderr.String(), "Error parsing input")
+
+	// the registers of the same name are reported instead of mixing their fields
+	stderr.Reset()
+	input2 := "device sensor\nregister A(1) {\n    v uint8;\n};\nregister A(2) {\n    v uint8;\n    w uint8;\n};\n"
+	assert.Equal(t, 1, run("pargus", []string{"-stats", "-"}, strings.NewReader(input2), &stdout, &stderr))
+	assert.Contains(t, stderr.String(), "duplicate register 'A'")
+}
+
+func TestFmt(t *testing.T) {
+	dir := t.TempDir()
+	input := filepath.Join(dir, "sensor.pa")
+	require.NoError(t, os.WriteFile(input, []byte("device sensor\nregister Status(1){\nvalue   uint16;   // the value\n};"), 0644))
+
+	var stdout, stderr bytes.Buffer
+	require.Equal(t, 0, run("pargus", []string{"fmt", input}, nil, &stdout, &stderr), stderr.String())
+	assert.Equal(t, "Formatted "+input+"\n", stdout.String())
+	data, err := os.ReadFile(input)
+	require.NoError(t, err)
+	assert.Equal(t, "device sensor\nregister Status(1) {\n    value uint16; // the value\n};\n", string(data))
+
+	// the formatted file is not rewritten
+	stdout.Reset()
+	require.Equal(t, 0, run("pargus", []string{"fmt", input}, nil, &stdout, &stderr), stderr.String())
+	assert.Empty(t, stdout.String())
+
+	stdout.Reset()
+	require.Equal(t, 0, run("pargus", []string{"fmt", "-"}, strings.NewReader("device  sensor"), &stdout, &stderr))
+	assert.Equal(t, "device sensor\n", stdout.String())
+
+	assert.Equal(t, 1, run("pargus", []string{"fmt", "-"}, strings.NewReader("device"), &stdout, &stderr))
+	assert.Contains(t, stderr.String(), "Error in -:")
+}
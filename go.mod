@@ -8,10 +8,10 @@ require (
 	github.com/alecthomas/participle/v2 v2.1.4
 	github.com/stretchr/testify v1.11.1
 	golang.org/x/text v0.30.0
+	gopkg.in/yaml.v3 v3.0.1
 )
 
 require (
 	github.com/davecgh/go-spew v1.1.1 // indirect
 	github.com/pmezard/go-difflib v1.0.0 // indirect
-	gopkg.in/yaml.v3 v3.0.1 // indirect
 )
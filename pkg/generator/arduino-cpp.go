@@ -3,6 +3,8 @@ package generator
 import (
 	"bytes"
 	"fmt"
+	"regexp"
+	"slices"
 	"strconv"
 	"strings"
 	"text/template"
@@ -18,48 +20,201 @@ const hppTemplate = `
 // This is auto-generated file. DO NOT EDIT. Use pargus compiler to regenerate it. 
 
 #pragma once
-
+{{if .Plain}}
+#include <cstddef>
+#include <cstdint>
+#include <cstring>
+{{- if .Vectors}}
+#include <vector>
+{{- end}}
+{{- if .Printer}}
+#include <ostream>
+{{- end}}
+{{- else}}
 #include <Arduino.h>
+{{- end}}
+{{- if .Float16}}
+#include "bigendian.h"
+{{- end}}
  
 {{- range .Doc}}
 {{.}}
 {{- end}}
-namespace {{.Namespace}} {
+{{- range .Namespaces}}
+namespace {{.}} {
+{{- end}}
 
 // Register IDs
 {{- range .Registers}}
-static constexpr uint8_t Reg_{{.Name}}_ID = {{.Number}};
+static constexpr {{$.Std}}uint8_t Reg_{{.Name}}_ID = {{.Number}};
+{{- end}}
+
+static constexpr {{$.Std}}uint8_t Max_Reg_ID = {{.MaxRegisterId}};
+{{- if .Versions}}
+
+// Register wire versions, the version byte precedes the register fields on the wire
+{{- range .Registers}}{{- if .HasVersion}}
+static constexpr {{$.Std}}uint8_t Reg_{{.Name}}_Version = {{.Version}};
+{{- end}}{{- end}}
+{{- end}}
+
+{{- range .Constants}}
+{{range .Doc}}{{.}}
+{{end -}}
+static constexpr {{.Type}} {{.Name}} = {{.Value}};
 {{- end}}
 
-static constexpr uint8_t Max_Reg_ID = {{.MaxRegisterId}};
+{{- range .Enums}}
+{{range .Doc}}{{.}}
+{{end -}}
+enum class {{.Name}} : {{.Base}} {
+{{- range .Members}}
+    {{- range .Doc}}
+    {{.}}
+    {{- end}}
+    {{.Name}} = {{.Value}},
+{{- end}}
+};
+{{- end}}
 
 {{- range .Registers}}
 {{range .Doc}}{{.}}
 {{end -}}
 struct {{.Name}} {
-{{- range .Constants}}
+{{- range .Members}}
+{{- with .Constant}}
     {{- range .Doc}}
-    {{.}}
+{{if .}}    {{.}}{{end}}
     {{- end}}
     static constexpr {{.Type}} {{.Name}} = {{.Value}};
 {{- end}}
-{{- range .Fields}}
+{{- with .Field}}
 	{{- range .BitMasks}}
     {{.}}
     {{- end}}
     {{- range .Doc}}
-    {{.}}
+{{if .}}    {{.}}{{end}}
     {{- end}}
     {{.Decl}}{{if .Trailing}} {{.Trailing}}{{end}}
 {{- end}}
+{{- end}}
+{{- range .Fields}}{{- if .Accessors}}
+{{range .Accessors}}
+    {{.}}
+{{- end}}
+{{- end}}{{- end}}
 
-	int serialize_read(uint8_t* buf, size_t size) const;
-	int serialize_write(uint8_t* buf, size_t size) const;
-	int deserialize_read(const uint8_t* buf, size_t size);
-	int deserialize_write(const uint8_t* buf, size_t size);
+	int serialize_read({{$.Std}}uint8_t* buf, {{$.Std}}size_t size) const;
+	int serialize_write({{$.Std}}uint8_t* buf, {{$.Std}}size_t size) const;
+	int deserialize_read(const {{$.Std}}uint8_t* buf, {{$.Std}}size_t size);
+	int deserialize_write(const {{$.Std}}uint8_t* buf, {{$.Std}}size_t size);
+{{- if .WriteFrom}}
+	static int serialize_write_from({{$.Std}}uint8_t* buf, {{$.Std}}size_t size{{range .WriteFromParams}}, {{.}}{{end}});
+{{- end}}
+	{{$.Std}}size_t buf_size_read() const;
+	{{$.Std}}size_t buf_size_write() const;
+{{- if .HasSize}}
+	{{$.Std}}size_t size() const;
+{{- end}}
+	int check() const;
 };
+{{- if .SizeAsserts}}
+
+// the serializer relies on the members taking their wire size, e.g. double is 4 bytes on AVR
+{{- range .SizeAsserts}}
+{{.}}
+{{- end}}
+{{- end}}
+{{- end}}
+{{- range .Messages}}
+{{range .Doc}}{{.}}
+{{end -}}
+struct {{.Name}} {
+{{- range .Members}}
+    {{- range .Doc}}
+    {{.}}
+    {{- end}}
+    {{.Type}} {{.Name}};{{if .Trailing}} {{.Trailing}}{{end}}
+{{- end}}
+
+	int serialize_read({{$.Std}}uint8_t* buf, {{$.Std}}size_t size) const;
+	int serialize_write({{$.Std}}uint8_t* buf, {{$.Std}}size_t size) const;
+	int deserialize_read(const {{$.Std}}uint8_t* buf, {{$.Std}}size_t size);
+	int deserialize_write(const {{$.Std}}uint8_t* buf, {{$.Std}}size_t size);
+	{{$.Std}}size_t buf_size_read() const;
+	{{$.Std}}size_t buf_size_write() const;
+{{- if .HasSize}}
+	{{$.Std}}size_t size() const;
+{{- end}}
+	int check() const;
+};
+{{- end}}
+{{- if .Printer}}
+
+// Print the enum member names, the register fields and the message registers for the debug output
+{{- range .Enums}}
+std::ostream& operator<<(std::ostream& os, {{.Name}} v);
+{{- end}}
+{{- range .Registers}}
+std::ostream& operator<<(std::ostream& os, const {{.Name}}& r);
+{{- end}}
+{{- range .Messages}}
+std::ostream& operator<<(std::ostream& os, const {{.Name}}& m);
+{{- end}}
+{{- end}}
+{{- if .Decoder}}
+
+// Decodes the write fields of the register with the id from the wire and passes the decoded
+// register to the handler, which must be callable with every register type.
+// The register is decoded into a local instance, so the raw pointer arrays have no buffers
+// and such a register is decoded only if the arrays are empty, the -vectors flag keeps them
+// in the vectors decoded here.
+// Returns the number of bytes read, -1 if the buffer is too small, -2 if a received array
+// has no buffer, -3 if the id is unknown, -4 if the register checksum does not match, -5 if
+// a magic field does not match or -6 if the register version does not match
+template <typename Handler>
+int decode_register({{$.Std}}uint8_t id, const {{$.Std}}uint8_t* buf, {{$.Std}}size_t size, Handler&& handler) {
+	switch (id) {
+{{- range .Registers}}
+	case Reg_{{.Name}}_ID: {
+		{{.Name}} reg{};
+		int res = reg.deserialize_write(buf, size);
+		if (res >= 0) handler(reg);
+		return res;
+	}
+{{- end}}
+	default:
+		return -3;
+	}
+}
+
+// Decodes the read fields of the register with the id from the wire and passes the decoded
+// register to the handler, which must be callable with every register type.
+// The register is decoded into a local instance, so the raw pointer arrays have no buffers
+// and such a register is decoded only if the arrays are empty, the -vectors flag keeps them
+// in the vectors decoded here.
+// Returns the number of bytes read, -1 if the buffer is too small, -2 if a received array
+// has no buffer, -3 if the id is unknown, -4 if the register checksum does not match, -5 if
+// a magic field does not match or -6 if the register version does not match
+template <typename Handler>
+int decode_read_register({{$.Std}}uint8_t id, const {{$.Std}}uint8_t* buf, {{$.Std}}size_t size, Handler&& handler) {
+	switch (id) {
+{{- range .Registers}}
+	case Reg_{{.Name}}_ID: {
+		{{.Name}} reg{};
+		int res = reg.deserialize_read(buf, size);
+		if (res >= 0) handler(reg);
+		return res;
+	}
+{{- end}}
+	default:
+		return -3;
+	}
+}
+{{- end}}
+{{- range .NamespacesEnd}}
+} // namespace {{.}}
 {{- end}}
-} // namespace {{.Namespace}}
 `
 
 const cppTemplate = `
@@ -67,14 +222,126 @@ const cppTemplate = `
 
 #include "{{.HppFileName}}"
 #include "bigendian.h"
+{{- if .LittleEndian}}
+#include "littleendian.h"
+{{- end}}
  
-namespace {{.Namespace}} {
+{{- range .Namespaces}}
+namespace {{.}} {
+{{- end}}
+{{- if .RefArrays}}
+
+// Returns the sum of the registers buffer sizes
+template <typename T>
+static {{$.Std}}size_t sum_buf_size(const T* regs, {{$.Std}}size_t n, {{$.Std}}size_t (T::*buf_size)() const) {
+	{{$.Std}}size_t size = 0;
+	for ({{$.Std}}size_t i = 0; regs != nullptr && i < n; i++) {
+		size += (regs[i].*buf_size)();
+	}
+	return size;
+}
+{{- end}}
+{{- if index .CRCs "ccitt"}}
+
+// Returns the CRC-16/CCITT-FALSE checksum of the data
+static {{$.Std}}uint16_t crc16_ccitt(const {{$.Std}}uint8_t* data, {{$.Std}}size_t size) {
+	{{$.Std}}uint16_t crc = 0xFFFF;
+	for ({{$.Std}}size_t i = 0; i < size; i++) {
+		crc ^= static_cast<{{$.Std}}uint16_t>(data[i] << 8);
+		for (int b = 0; b < 8; b++) {
+			crc = (crc & 0x8000) ? static_cast<{{$.Std}}uint16_t>((crc << 1) ^ 0x1021) : static_cast<{{$.Std}}uint16_t>(crc << 1);
+		}
+	}
+	return crc;
+}
+{{- end}}
+{{- if index .CRCs "modbus"}}
+
+// Returns the CRC-16/MODBUS checksum of the data
+static {{$.Std}}uint16_t crc16_modbus(const {{$.Std}}uint8_t* data, {{$.Std}}size_t size) {
+	{{$.Std}}uint16_t crc = 0xFFFF;
+	for ({{$.Std}}size_t i = 0; i < size; i++) {
+		crc ^= data[i];
+		for (int b = 0; b < 8; b++) {
+			crc = (crc & 1) ? static_cast<{{$.Std}}uint16_t>((crc >> 1) ^ 0xA001) : static_cast<{{$.Std}}uint16_t>(crc >> 1);
+		}
+	}
+	return crc;
+}
+{{- end}}
+{{- if index .CRCs "ieee"}}
+
+// Returns the CRC-32/IEEE checksum of the data
+static {{$.Std}}uint32_t crc32_ieee(const {{$.Std}}uint8_t* data, {{$.Std}}size_t size) {
+	{{$.Std}}uint32_t crc = 0xFFFFFFFF;
+	for ({{$.Std}}size_t i = 0; i < size; i++) {
+		crc ^= data[i];
+		for (int b = 0; b < 8; b++) {
+			crc = (crc & 1) ? (crc >> 1) ^ 0xEDB88320 : crc >> 1;
+		}
+	}
+	return ~crc;
+}
+{{- end}}
 {{- range .Registers}}
 
 // ================= {{.Name}} implementation =================
+// Returns the buffer size required for read fields serialization
+{{$.Std}}size_t {{.Name}}::buf_size_read() const {
+	{{$.Std}}size_t size = {{.BufSize4ReadConst}};
+{{- range .Fields}}{{- if and .BufSize4ReadExpr .Condition}}
+	if ({{.Condition}}) size += {{.BufSize4ReadExpr}};
+{{- else if .BufSize4ReadExpr}}
+	size += {{.BufSize4ReadExpr}};
+{{- end}}{{- end}}
+	return size;
+}
+
+// Returns the buffer size required for write fields serialization
+{{$.Std}}size_t {{.Name}}::buf_size_write() const {
+	{{$.Std}}size_t size = {{.BufSize4WriteConst}};
+{{- range .Fields}}{{- if and .BufSize4WriteExpr .Condition}}
+	if ({{.Condition}}) size += {{.BufSize4WriteExpr}};
+{{- else if .BufSize4WriteExpr}}
+	size += {{.BufSize4WriteExpr}};
+{{- end}}{{- end}}
+	return size;
+}
+{{- if .HasSize}}
+
+// Returns the size of the read and the write fields together, the fields without the r or w
+// specifier are sent in both directions, but they are counted once
+{{$.Std}}size_t {{.Name}}::size() const {
+	{{$.Std}}size_t size = {{.SizeConst}};
+{{- range .Fields}}{{- if and .SizeExpr .Condition}}
+	if ({{.Condition}}) size += {{.SizeExpr}};
+{{- else if .SizeExpr}}
+	size += {{.SizeExpr}};
+{{- end}}{{- end}}
+	return size;
+}
+{{- end}}
+
+// Validates the consistency of variable-length arrays with their size fields and the strings length,
+// returns -2 if an array is not set, but its size field is not zero, a vector size differs from
+// its size field, a string is too long or a field is out of its range
+int {{.Name}}::check() const {
+{{- range .Fields}}
+{{- range .ConsistencyChecks}}
+	{{.}}
+{{- end}}
+{{- end}}
+	return 0;
+}
+
 // Send read-only fields to wire (register read fields -> wire)
-int {{.Name}}::serialize_read(uint8_t* buf, size_t size) const {
+int {{.Name}}::serialize_read({{$.Std}}uint8_t* buf, {{$.Std}}size_t size) const {
+	{int res = this->check(); if (res < 0) return res;}
 	int offset = 0;
+{{- if .VersionRead}}
+	if (size < 1) return -1;
+	buf[0] = Reg_{{.Name}}_Version; offset = 1;
+{{- end}}
 {{- range .Fields}}{{- if .SerializeReadData}}
 	{{range .SerializeReadData}}{{.}}
 	{{end -}}
@@ -83,17 +350,46 @@ int {{.Name}}::serialize_read(uint8_t* buf, size_t size) const {
 }
 
 // Send write-only fields to wire (register write fields -> wire)
-int {{.Name}}::serialize_write(uint8_t* buf, size_t size) const{
+int {{.Name}}::serialize_write({{$.Std}}uint8_t* buf, {{$.Std}}size_t size) const{
+	{int res = this->check(); if (res < 0) return res;}
 	int offset = 0;
+{{- if .VersionWrite}}
+	if (size < 1) return -1;
+	buf[0] = Reg_{{.Name}}_Version; offset = 1;
+{{- end}}
 {{- range .Fields}}{{- if .SerializeWriteData}}
 	{{range .SerializeWriteData}}{{.}}{{end -}}
 {{- end}}{{- end}}
 	return offset;
 }
+{{- if .WriteFrom}}
+
+// Send the write fields passed in their declaration order to wire without the register instance,
+// the bytes and the result are the same as the ones of serialize_write of these fields
+int {{.Name}}::serialize_write_from({{$.Std}}uint8_t* buf, {{$.Std}}size_t size{{range .WriteFromParams}}, {{.}}{{end}}) {
+{{- range .WriteFromChecks}}
+	{{.}}
+{{- end}}
+	int offset = 0;
+{{- if .VersionWrite}}
+	if (size < 1) return -1;
+	buf[0] = Reg_{{.Name}}_Version; offset = 1;
+{{- end}}
+{{- range .WriteFromData}}
+	{{.}}
+{{- end}}
+	return offset;
+}
+{{- end}}
 
 // Get read-only fields from wire (wire -> the register read fields)
-int {{.Name}}::deserialize_read(const uint8_t* buf, size_t size) {
+int {{.Name}}::deserialize_read(const {{$.Std}}uint8_t* buf, {{$.Std}}size_t size) {
 	int offset = 0;
+{{- if .VersionRead}}
+	if (size < 1) return -1;
+	if (buf[0] != Reg_{{.Name}}_Version) return -6;
+	offset = 1;
+{{- end}}
 {{- range .Fields}}{{- if .DeserializeReadData}}
 	{{range .DeserializeReadData}}{{.}}
 	{{end -}}
@@ -102,8 +398,13 @@ int {{.Name}}::deserialize_read(const uint8_t* buf, size_t size) {
 }
 
 // Get write-only fields from wire (wire -> the register writable fields)
-int {{.Name}}::deserialize_write(const uint8_t* buf, size_t size) {
+int {{.Name}}::deserialize_write(const {{$.Std}}uint8_t* buf, {{$.Std}}size_t size) {
 	int offset = 0;
+{{- if .VersionWrite}}
+	if (size < 1) return -1;
+	if (buf[0] != Reg_{{.Name}}_Version) return -6;
+	offset = 1;
+{{- end}}
 {{- range .Fields}}{{- if .DeserializeWriteData}}
 	{{range .DeserializeWriteData}}{{.}}{{end -}}
 {{- end}}{{- end}}
@@ -111,27 +412,236 @@ int {{.Name}}::deserialize_write(const uint8_t* buf, size_t size) {
 }
 
 {{- end}}
-} // namespace {{.Namespace}}
+{{- range .Messages}}
+
+// ================= {{.Name}} message =================
+// Returns the buffer size required for the read fields serialization of the registers
+{{$.Std}}size_t {{.Name}}::buf_size_read() const {
+	return {{range $i, $m := .Members}}{{if $i}} + {{end}}this->{{.Name}}.buf_size_read(){{end}};
+}
+
+// Returns the buffer size required for the write fields serialization of the registers
+{{$.Std}}size_t {{.Name}}::buf_size_write() const {
+	return {{range $i, $m := .Members}}{{if $i}} + {{end}}this->{{.Name}}.buf_size_write(){{end}};
+}
+{{- if .HasSize}}
+
+// Returns the size of the read and the write fields of the registers together
+{{$.Std}}size_t {{.Name}}::size() const {
+	return {{range $i, $m := .Members}}{{if $i}} + {{end}}this->{{.Name}}.size(){{end}};
+}
+{{- end}}
+
+// Validates the registers of the message, returns the first error of them
+int {{.Name}}::check() const {
+{{- range .Members}}
+	{int res = this->{{.Name}}.check(); if (res < 0) return res;}
+{{- end}}
+	return 0;
+}
+
+// Send the read fields of the registers to wire one after another
+int {{.Name}}::serialize_read({{$.Std}}uint8_t* buf, {{$.Std}}size_t size) const {
+	int offset = 0;
+{{- range .Members}}
+	{auto res = this->{{.Name}}.serialize_read(buf + offset, size - offset); if (res < 0) return res; offset += res;}
+{{- end}}
+	return offset;
+}
+
+// Send the write fields of the registers to wire one after another
+int {{.Name}}::serialize_write({{$.Std}}uint8_t* buf, {{$.Std}}size_t size) const {
+	int offset = 0;
+{{- range .Members}}
+	{auto res = this->{{.Name}}.serialize_write(buf + offset, size - offset); if (res < 0) return res; offset += res;}
+{{- end}}
+	return offset;
+}
+
+// Get the read fields of the registers from wire one after another
+int {{.Name}}::deserialize_read(const {{$.Std}}uint8_t* buf, {{$.Std}}size_t size) {
+	int offset = 0;
+{{- range .Members}}
+	{auto res = this->{{.Name}}.deserialize_read(buf + offset, size - offset); if (res < 0) return res; offset += res;}
+{{- end}}
+	return offset;
+}
+
+// Get the write fields of the registers from wire one after another
+int {{.Name}}::deserialize_write(const {{$.Std}}uint8_t* buf, {{$.Std}}size_t size) {
+	int offset = 0;
+{{- range .Members}}
+	{auto res = this->{{.Name}}.deserialize_write(buf + offset, size - offset); if (res < 0) return res; offset += res;}
+{{- end}}
+	return offset;
+}
+{{- end}}
+{{- if .Printer}}
+
+// ================= debug printing =================
+// Prints the value, the 8-bit integers are printed as numbers instead of characters
+static inline void print_value(std::ostream& os, std::int8_t v) { os << static_cast<int>(v); }
+static inline void print_value(std::ostream& os, std::uint8_t v) { os << static_cast<unsigned>(v); }
+static inline void print_value(std::ostream& os, bool v) { os << (v ? "true" : "false"); }
+template <typename T>
+static void print_value(std::ostream& os, const T& v) { os << v; }
+template <typename T>
+static void print_array(std::ostream& os, const T* items, std::size_t n);
+template <typename T, std::size_t N>
+static void print_value(std::ostream& os, const T (&v)[N]) { print_array(os, v, N); }
+
+// Prints the array items in the brackets separated by the commas, the rows of the
+// multi-dimensional arrays are printed as the nested arrays
+template <typename T>
+static void print_array(std::ostream& os, const T* items, std::size_t n) {
+	os << '[';
+	for (std::size_t i = 0; items != nullptr && i < n; i++) {
+		if (i > 0) os << ", ";
+		print_value(os, items[i]);
+	}
+	os << ']';
+}
+{{- range .Enums}}
+{{- $enumName := .Name}}
+
+// Prints the name of the {{.Name}} member, the unknown value is printed as the number
+std::ostream& operator<<(std::ostream& os, {{.Name}} v) {
+	switch (v) {
+{{- range .Members}}
+	case {{$enumName}}::{{.Name}}: return os << "{{.Name}}";
+{{- end}}
+	}
+	return os << "{{.Name}}(" << +static_cast<{{.Base}}>(v) << ')';
+}
+{{- end}}
+{{- range .Registers}}
+
+// Prints the {{.Name}} fields with their names, the bit fields are followed by their members
+std::ostream& operator<<(std::ostream& os, const {{.Name}}& r) {
+	os << "{{.Name}}{";
+{{- range .Fields}}
+{{- range .Print}}
+	{{.}}
+{{- end}}
+{{- end}}
+	return os << '}';
+}
+{{- end}}
+{{- range .Messages}}
+
+// Prints the registers of the {{.Name}} message
+std::ostream& operator<<(std::ostream& os, const {{.Name}}& m) {
+	os << "{{.Name}}{";
+{{- range $i, $m := .Members}}
+	os << "{{if $i}}, {{end}}{{.Name}}: "; print_value(os, m.{{.Name}});
+{{- end}}
+	return os << '}';
+}
+{{- end}}
+{{- end}}
+{{- range .NamespacesEnd}}
+} // namespace {{.}}
+{{- end}}
 `
 
+var (
+	hppTpl = template.Must(template.New("hpp").Parse(hppTemplate))
+	cppTpl = template.Must(template.New("cpp").Parse(cppTemplate))
+)
+
 //
 // Intermediate representation for template
 //
 
+// CppOptions contains the optional features of the C++ generator
+type CppOptions struct {
+	// Decoder enables decode_register and decode_read_register functions, which
+	// decode a register by its ID
+	Decoder bool
+	// Plain generates the code for a regular C++ compiler instead of Arduino, the standard
+	// headers are included instead of <Arduino.h> and the std:: integer types are used
+	Plain bool
+	// Vectors keeps the variable-length arrays in std::vector instead of the raw pointers, the
+	// vectors are resized to the size field value when deserializing. It requires Plain
+	Vectors bool
+	// Printer generates the std::ostream operator<< of the enums, registers and messages for the
+	// debug printing. It requires Plain, because <ostream> is not available on the most MCUs
+	Printer bool
+}
+
 type CppDevice struct {
+	CppOptions
 	Doc           []string
-	Namespace     string
+	Namespaces    []string // nested namespaces, the outermost first
+	NamespacesEnd []string // the same namespaces in the closing order, the innermost first
 	HppFileName   string
+	Constants     []CppConstant
+	Enums         []CppEnum
 	Registers     []CppRegister
+	Messages      []CppMessage
 	MaxRegisterId int
+	LittleEndian  bool            // true if any field is encoded in little-endian byte order
+	RefArrays     bool            // true if any field is an array of registers
+	Float16       bool            // true if any field is a float16, its accessors use the runtime conversions
+	CRCs          map[string]bool // checksum algorithms the registers use
+	Versions      bool            // true if any register has the wire version
+	Std           string          // prefix of the integer types, "std::" in the plain C++ mode
+}
+
+type CppEnum struct {
+	Doc     []string
+	Name    string
+	Base    string
+	Members []CppEnumMember
+}
+
+type CppEnumMember struct {
+	Doc   []string
+	Name  string
+	Value string
 }
 
 type CppRegister struct {
-	Name      string
-	Number    int
-	Doc       []string
-	Constants []CppConstant
-	Fields    []CppField
+	Name               string
+	Number             int
+	Doc                []string
+	Constants          []CppConstant
+	Fields             []CppField
+	Members            []CppMember // the constants and the fields in the declaration order
+	BufSize4ReadConst  int
+	BufSize4WriteConst int
+	SizeConst          int      // constant part of size(), the read-write fields are counted once
+	HasSize            bool     // the register has the size() method, a member named size prevents it
+	SizeAsserts        []string // static_assert checks of the members sizes the serializer relies on
+	HasVersion         bool     // the version byte precedes the fields in the directions the register is sent in
+	Version            int
+	VersionRead        bool
+	VersionWrite       bool
+	WriteFrom          bool     // the write-only register has the static serialize_write_from method
+	WriteFromParams    []string // serialize_write_from parameters, the field values in the declaration order
+	WriteFromChecks    []string // check() code of serialize_write_from using the parameters
+	WriteFromData      []string // serialize_write code of serialize_write_from using the parameters
+}
+
+// CppMember is the constant or the field of the register struct
+type CppMember struct {
+	Constant *CppConstant
+	Field    *CppField
+}
+
+// CppMessage is the struct of the registers sent one after another
+type CppMessage struct {
+	Name    string
+	Doc     []string
+	Members []CppMessageMember
+	HasSize bool // every register of the message has the size() method
+}
+
+type CppMessageMember struct {
+	Doc      []string
+	Name     string // the struct member name, the snake case register name
+	Type     string
+	Trailing string
 }
 
 type CppConstant struct {
@@ -153,6 +663,14 @@ type CppField struct {
 	DeserializeReadData  []string // Code for deserialize_read function
 	DeserializeWriteData []string // Code for deserialize_write function
 	Trailing             string
+	BufSize4ReadExpr     string   // Expression for variable size (empty if constant)
+	BufSize4WriteExpr    string   // Expression for variable size (empty if constant)
+	SizeExpr             string   // Expression for variable size in size() (empty if constant)
+	ConsistencyChecks    []string // Checks for variable-length arrays
+	Condition            string   // Expression which is true if the conditional field is sent, empty for the other fields
+	Accessors            []string // Inline getters and setters of the bit field members
+	Print                []string // Code printing the field in operator<<, empty for the fields without value
+	Params               []string // Parameters of serialize_write_from passing the field, empty for the fields without value
 }
 
 //
@@ -160,37 +678,87 @@ type CppField struct {
 //
 
 func GenerateHppCpp(dev *parser.Device, namespace, hppFileName string) (string, string, error) {
-	tplHpp, err := template.New("hpp").Parse(hppTemplate)
-	if err != nil {
-		return "", "", err
+	return GenerateHppCppWithOptions(dev, namespace, hppFileName, CppOptions{})
+}
+
+// GenerateHppCppWithOptions generates the C++ header and source for the device with the optional features enabled
+func GenerateHppCppWithOptions(dev *parser.Device, namespace, hppFileName string, opts CppOptions) (string, string, error) {
+	if opts.Vectors && !opts.Plain {
+		return "", "", fmt.Errorf("std::vector arrays require the plain C++ mode")
 	}
-	tplCpp, err := template.New("cpp").Parse(cppTemplate)
+	if opts.Printer && !opts.Plain {
+		return "", "", fmt.Errorf("std::ostream printing requires the plain C++ mode")
+	}
+	namespaces, err := cppNamespaces(namespace)
 	if err != nil {
 		return "", "", err
 	}
-
-	out := CppDevice{Namespace: namespace, HppFileName: hppFileName}
-	out.Doc = flattenComments(dev.Doc)
+	out := CppDevice{CppOptions: opts, Namespaces: namespaces, HppFileName: hppFileName}
+	for i := len(namespaces) - 1; i >= 0; i-- {
+		out.NamespacesEnd = append(out.NamespacesEnd, namespaces[i])
+	}
+	if opts.Plain {
+		out.Std = "std::"
+	}
+	out.Doc = declComments(dev.Doc, dev.TrailingComment)
+	for _, c := range dev.Constants {
+		out.Constants = append(out.Constants, CppConstant{
+			Doc:   declComments(c.Doc, c.TrailingComment),
+			Name:  c.Name,
+			Type:  out.cppType(c.Type.Name),
+			Value: cppIntLiteral(c.ValueStr),
+		})
+	}
+	for _, e := range dev.Enums {
+		ce := CppEnum{
+			Doc:  declComments(e.Doc, e.TrailingComment),
+			Name: e.Name,
+			Base: out.cppType(e.Base),
+		}
+		for _, m := range e.Members {
+			ce.Members = append(ce.Members, CppEnumMember{
+				Doc:   declComments(m.Doc, m.TrailingComment),
+				Name:  m.Name,
+				Value: cppIntLiteral(m.ValueStr),
+			})
+		}
+		out.Enums = append(out.Enums, ce)
+	}
 	for _, reg := range dev.Registers {
 		num, _ := strconv.ParseInt(reg.NumberStr, 0, 64)
 		out.MaxRegisterId = max(out.MaxRegisterId, int(num))
 		cr := CppRegister{
-			Name:   reg.Name,
-			Number: int(num),
-			Doc:    flattenComments(reg.Doc),
+			Name:    reg.Name,
+			Number:  int(num),
+			Doc:     declComments(reg.Doc, reg.TrailingComment),
+			HasSize: cppHasSize(dev, reg),
+		}
+		if reg.HasVersion() {
+			cr.HasVersion, cr.Version = true, int(reg.Version())
+			cr.VersionRead, cr.VersionWrite = reg.Specifier != "w", reg.Specifier != "r"
+			if cr.VersionRead {
+				cr.BufSize4ReadConst = 1
+			}
+			if cr.VersionWrite {
+				cr.BufSize4WriteConst = 1
+			}
+			cr.SizeConst = 1
+			out.Versions = true
 		}
 
 		// Process constants
 		for _, c := range reg.Body.Constants() {
 			cc := CppConstant{
-				Doc:   flattenComments(c.Doc),
+				Doc:   declComments(c.Doc, c.TrailingComment),
 				Name:  c.Name,
-				Type:  toCppTypes(c.Type.Name),
-				Value: c.ValueStr,
+				Type:  out.cppType(c.Type.Name),
+				Value: cppIntLiteral(c.ValueStr),
 			}
 			cr.Constants = append(cr.Constants, cc)
 		}
 
+		printed := 0 // the number of the fields operator<< prints
+
 		for _, f := range reg.Body.Fields() {
 			cf := CppField{
 				Doc:        flattenComments(f.Doc),
@@ -199,11 +767,103 @@ func GenerateHppCpp(dev *parser.Device, namespace, hppFileName string) (string,
 				IsReadable: f.Specifier == "r" || f.Specifier == "",
 				IsWritable: f.Specifier == "w" || f.Specifier == "",
 			}
+			ns := cppCodecNamespace(f)
+			if f.IsLittleEndian() {
+				out.LittleEndian = true
+			}
+
+			readConst, writeConst := cr.BufSize4ReadConst, cr.BufSize4WriteConst
+
+			// The field placed at the offset is preceded by the zero bytes filling the gap
+			if pad := reg.Padding(f, true); pad > 0 {
+				serCode, deserCode := cppPaddingCode(out.Std, pad)
+				cf.SerializeReadData = append(cf.SerializeReadData, serCode...)
+				cf.DeserializeReadData = append(cf.DeserializeReadData, deserCode...)
+				cr.BufSize4ReadConst += pad
+			}
+			if pad := reg.Padding(f, false); pad > 0 {
+				serCode, deserCode := cppPaddingCode(out.Std, pad)
+				cf.SerializeWriteData = append(cf.SerializeWriteData, serCode...)
+				cf.DeserializeWriteData = append(cf.DeserializeWriteData, deserCode...)
+				cr.BufSize4WriteConst += pad
+			}
 
 			switch {
+			case f.Reserved:
+				size := reservedSize(f)
+				cf.Decl = fmt.Sprintf("// reserved %d byte(s)", size)
+				serCode, deserCode := cppPaddingCode(out.Std, size)
+				if cf.IsReadable {
+					cf.SerializeReadData = append(cf.SerializeReadData, serCode...)
+					cf.DeserializeReadData = append(cf.DeserializeReadData, deserCode...)
+					cr.BufSize4ReadConst += size
+				}
+				if cf.IsWritable {
+					cf.SerializeWriteData = append(cf.SerializeWriteData, serCode...)
+					cf.DeserializeWriteData = append(cf.DeserializeWriteData, deserCode...)
+					cr.BufSize4WriteConst += size
+				}
+
+			case f.Type.CRC != nil:
+				// the checksum is calculated over the bytes preceding it, so it has no value to keep
+				base := out.cppType(f.Type.CRC.BaseType())
+				crcFn := out.cppCRCFunc(f.Type.CRC)
+				size := wireTypeSize(f.Type.CRC.BaseType())
+				cf.Decl = fmt.Sprintf("// %s: %s checksum of the preceding bytes", f.Name, f.Type.CRC.Kind)
+				serCode := []string{
+					fmt.Sprintf("if ((%ssize_t)offset + %d > size) return -1;", out.Std, size),
+					fmt.Sprintf("offset += %s::encode(buf + offset, %s(buf, (%ssize_t)offset));", ns, crcFn, out.Std),
+				}
+				deserCode := []string{
+					fmt.Sprintf("if ((%ssize_t)offset + %d > size) return -1;", out.Std, size),
+					fmt.Sprintf("{%s crc; %s::decode(crc, buf + offset); if (crc != %s(buf, (%ssize_t)offset)) return -4; offset += %d;}",
+						base, ns, crcFn, out.Std, size),
+				}
+				if cf.IsReadable {
+					cf.SerializeReadData = append(cf.SerializeReadData, serCode...)
+					cf.DeserializeReadData = append(cf.DeserializeReadData, deserCode...)
+					cr.BufSize4ReadConst += size
+				}
+				if cf.IsWritable {
+					cf.SerializeWriteData = append(cf.SerializeWriteData, serCode...)
+					cf.DeserializeWriteData = append(cf.DeserializeWriteData, deserCode...)
+					cr.BufSize4WriteConst += size
+				}
+
+			case f.IsMagic():
+				// the magic value is always the same, so it has no value to keep
+				typ := f.Type.Simple.Name
+				elem := out.cppType(typ)
+				size := wireTypeSize(typ)
+				magic := fmt.Sprintf("static_cast<%s>(%s)", elem, magicLiteral(f))
+				encode, decode := "encode", "decode"
+				if is24BitType(typ) {
+					encode, decode = "encode24", "decode24"
+				}
+				cf.Decl = fmt.Sprintf("// %s: magic %s", f.Name, magicLiteral(f))
+				serCode := []string{
+					fmt.Sprintf("if ((%ssize_t)offset + %d > size) return -1;", out.Std, size),
+					fmt.Sprintf("offset += %s::%s(buf + offset, %s);", ns, encode, magic),
+				}
+				deserCode := []string{
+					fmt.Sprintf("if ((%ssize_t)offset + %d > size) return -1;", out.Std, size),
+					fmt.Sprintf("{%s magic; offset += %s::%s(magic, buf + offset); if (magic != %s) return -5;}", elem, ns, decode, magic),
+				}
+				if cf.IsReadable {
+					cf.SerializeReadData = append(cf.SerializeReadData, serCode...)
+					cf.DeserializeReadData = append(cf.DeserializeReadData, deserCode...)
+					cr.BufSize4ReadConst += size
+				}
+				if cf.IsWritable {
+					cf.SerializeWriteData = append(cf.SerializeWriteData, serCode...)
+					cf.DeserializeWriteData = append(cf.DeserializeWriteData, deserCode...)
+					cr.BufSize4WriteConst += size
+				}
+
 			case f.Type.Simple != nil && f.Type.Simple.IsRegisterRef():
 				refRegName := f.Type.Simple.Name
 				cf.Decl = fmt.Sprintf("%s %s;", refRegName, f.Name)
+				cf.Params = []string{fmt.Sprintf("const %s& %s", refRegName, cppArgName(f.Name))}
 
 				// For RegisterRef, populate the appropriate contexts
 				if cf.IsReadable {
@@ -211,18 +871,56 @@ func GenerateHppCpp(dev *parser.Device, namespace, hppFileName string) (string,
 						fmt.Sprintf("{auto res = this->%s.serialize_read(buf + offset, size - offset); if (res < 0) return res; offset += res;}", f.Name))
 					cf.DeserializeReadData = append(cf.DeserializeReadData,
 						fmt.Sprintf("{auto res = this->%s.deserialize_read(buf + offset, size - offset); if (res < 0) return res; offset += res;}", f.Name))
+					cf.BufSize4ReadExpr = fmt.Sprintf("this->%s.buf_size_read()", f.Name)
 				}
 				if cf.IsWritable {
 					cf.SerializeWriteData = append(cf.SerializeWriteData,
 						fmt.Sprintf("{auto res = this->%s.serialize_write(buf + offset, size - offset); if (res < 0) return res; offset += res;}", f.Name))
 					cf.DeserializeWriteData = append(cf.DeserializeWriteData,
 						fmt.Sprintf("{auto res = this->%s.deserialize_write(buf + offset, size - offset); if (res < 0) return res; offset += res;}", f.Name))
+					cf.BufSize4WriteExpr = fmt.Sprintf("this->%s.buf_size_write()", f.Name)
+				}
+				if cf.IsReadable && cf.IsWritable {
+					// the nested register fields may be sent in one direction only
+					cf.SizeExpr = fmt.Sprintf("this->%s.size()", f.Name)
+				}
+
+			case f.Type.Simple != nil && f.Type.Simple.IsEnum():
+				base := out.cppType(f.Type.Simple.Enum.Base)
+				cf.Decl = fmt.Sprintf("%s %s%s;", f.Type.Simple.Name, f.Name, cppDefault(f))
+				cf.Params = []string{fmt.Sprintf("%s %s", f.Type.Simple.Name, cppArgName(f.Name))}
+				// the enum is sent over the wire as its base integer type
+				serCode := []string{
+					fmt.Sprintf("if (offset + sizeof(this->%s) > size) return -1;", f.Name),
+					fmt.Sprintf("offset += %s::encode(buf + offset, static_cast<%s>(this->%s));", ns, base, f.Name),
+				}
+				deserCode := []string{
+					fmt.Sprintf("if (offset + sizeof(this->%s) > size) return -1;", f.Name),
+					fmt.Sprintf("{%s v; offset += %s::decode(v, buf + offset); this->%s = static_cast<%s>(v);}",
+						base, ns, f.Name, f.Type.Simple.Name),
+				}
+				size := wireTypeSize(f.Type.Simple.Enum.Base)
+				cr.addSizeAssert(reg.Name+"::"+f.Name, size)
+				if cf.IsReadable {
+					cf.SerializeReadData = append(cf.SerializeReadData, serCode...)
+					cf.DeserializeReadData = append(cf.DeserializeReadData, deserCode...)
+					cr.BufSize4ReadConst += size
+				}
+				if cf.IsWritable {
+					cf.SerializeWriteData = append(cf.SerializeWriteData, serCode...)
+					cf.DeserializeWriteData = append(cf.DeserializeWriteData, deserCode...)
+					cr.BufSize4WriteConst += size
 				}
 
 			case f.Type.Bitfield != nil:
-				base := toCppTypes(f.Type.Bitfield.Base)
-				cf.Decl = fmt.Sprintf("%s %s;", base, f.Name)
+				base := out.cppType(f.Type.Bitfield.Base)
+				cf.Decl = fmt.Sprintf("%s %s%s;", base, f.Name, cppDefault(f))
+				cf.Params = []string{fmt.Sprintf("%s %s", base, cppArgName(f.Name))}
 				for _, bm := range f.Type.Bitfield.Bits {
+					if bm.Reserved {
+						// reserved members only document the unused bits
+						continue
+					}
 					// Add bit member comments
 					bmComments := flattenComments(bm.Doc)
 					for _, comment := range bmComments {
@@ -246,66 +944,281 @@ func GenerateHppCpp(dev *parser.Device, namespace, hppFileName string) (string,
 					cf.BitMasks = append(cf.BitMasks,
 						fmt.Sprintf("static constexpr %s %s_%s_bm = 0x%X;",
 							base, f.Name, bm.Name, mask))
+
+					bmName := fmt.Sprintf("%s_%s_bm", f.Name, bm.Name)
+					if start == end {
+						cf.Accessors = append(cf.Accessors,
+							fmt.Sprintf("bool get_%s_%s() const { return (this->%s & %s) != 0; }",
+								f.Name, bm.Name, f.Name, bmName),
+							fmt.Sprintf("void set_%s_%s(bool v) { if (v) this->%s |= %s; else this->%s &= static_cast<%s>(~%s); }",
+								f.Name, bm.Name, f.Name, bmName, f.Name, base, bmName))
+					} else if bm.Signed {
+						// the member is moved to the highest bits, so the arithmetic shift extends its sign
+						signed := out.cppType(strings.TrimPrefix(f.Type.Bitfield.Base, "u"))
+						top := wireTypeSize(f.Type.Bitfield.Base)*8 - 1 - end
+						cf.Accessors = append(cf.Accessors,
+							fmt.Sprintf("%s get_%s_%s() const { return static_cast<%s>(static_cast<%s>(this->%s << %d) >> %d); }",
+								signed, f.Name, bm.Name, signed, signed, f.Name, top, top+start),
+							fmt.Sprintf("void set_%s_%s(%s v) { this->%s = static_cast<%s>((this->%s & ~%s) | ((static_cast<%s>(v) << %d) & %s)); }",
+								f.Name, bm.Name, signed, f.Name, base, f.Name, bmName, base, start, bmName))
+					} else {
+						cf.Accessors = append(cf.Accessors,
+							fmt.Sprintf("%s get_%s_%s() const { return static_cast<%s>((this->%s & %s) >> %d); }",
+								base, f.Name, bm.Name, base, f.Name, bmName, start),
+							fmt.Sprintf("void set_%s_%s(%s v) { this->%s = static_cast<%s>((this->%s & ~%s) | ((v << %d) & %s)); }",
+								f.Name, bm.Name, base, f.Name, base, f.Name, bmName, start, bmName))
+					}
 				}
 				serCode := []string{
 					fmt.Sprintf("if (offset + sizeof(this->%s) > size) return -1;", f.Name),
-					fmt.Sprintf("offset += bigendian::encode(buf + offset, this->%s);", f.Name),
+					fmt.Sprintf("offset += %s::encode(buf + offset, this->%s);", ns, f.Name),
 				}
 				deserCode := []string{
 					fmt.Sprintf("if (offset + sizeof(this->%s) > size) return -1;", f.Name),
-					fmt.Sprintf("offset += bigendian::decode(this->%s, buf + offset);", f.Name),
+					fmt.Sprintf("offset += %s::decode(this->%s, buf + offset);", ns, f.Name),
 				}
+				size := wireTypeSize(f.Type.Bitfield.Base)
+				cr.addSizeAssert(reg.Name+"::"+f.Name, size)
 				if cf.IsReadable {
 					cf.SerializeReadData = append(cf.SerializeReadData, serCode...)
 					cf.DeserializeReadData = append(cf.DeserializeReadData, deserCode...)
+					cr.BufSize4ReadConst += size
 				}
 				if cf.IsWritable {
 					cf.SerializeWriteData = append(cf.SerializeWriteData, serCode...)
 					cf.DeserializeWriteData = append(cf.DeserializeWriteData, deserCode...)
+					cr.BufSize4WriteConst += size
+				}
+			case f.Type.Array != nil && f.Type.Array.Type.IsRegisterRef():
+				elem := f.Type.Array.Type.Name
+				var count string // the number of the array elements
+				if f.Type.Array.Size.Constant != nil {
+					count = *f.Type.Array.Size.Constant
+					cf.Decl = fmt.Sprintf("%s %s[%s];", elem, f.Name, count)
+					cf.Params = []string{fmt.Sprintf("const %s (&%s)[%s]", elem, cppArgName(f.Name), count)}
+				} else {
+					field, bm := reg.FindFieldByName(*f.Type.Array.Size.Variable, len(cr.Fields))
+					count = fmt.Sprintf("this->%s", field.Name)
+					if bm != nil {
+						count = fmt.Sprintf("((this->%s&%s_%s_bm)>>%d)", field.Name, field.Name, bm.Name, bm.StartBit())
+					}
+					if out.Vectors {
+						cf.Decl = fmt.Sprintf("std::vector<%s> %s;", elem, f.Name)
+						cf.Params = []string{fmt.Sprintf("const std::vector<%s>& %s", elem, cppArgName(f.Name))}
+						cf.ConsistencyChecks = append(cf.ConsistencyChecks,
+							fmt.Sprintf("if (this->%s.size() != (std::size_t)%s) return -2;", f.Name, count))
+					} else {
+						cf.Decl = fmt.Sprintf("%s* %s;", elem, f.Name)
+						cf.Params = []string{fmt.Sprintf("const %s* %s", elem, cppArgName(f.Name))}
+						cf.ConsistencyChecks = append(cf.ConsistencyChecks,
+							fmt.Sprintf("if (this->%s == nullptr && %s != 0) return -2;", f.Name, count))
+					}
 				}
+				out.RefArrays = true
+				vector := out.Vectors && f.Type.Array.Size.Variable != nil
+				pointer := !out.Vectors && f.Type.Array.Size.Variable != nil
+				regs := "this->" + f.Name
+				if vector {
+					regs += ".data()"
+				}
+
+				// Every element is encoded by its own register methods
+				loop := fmt.Sprintf("for (%ssize_t i = 0; i < (%ssize_t)%s; i++)", out.Std, out.Std, count)
+				for _, dir := range []string{"read", "write"} {
+					if (dir == "read" && !cf.IsReadable) || (dir == "write" && !cf.IsWritable) {
+						continue
+					}
+					serCode := fmt.Sprintf("%s {auto res = this->%s[i].serialize_%s(buf + offset, size - offset); if (res < 0) return res; offset += res;}",
+						loop, f.Name, dir)
+					deserCode := fmt.Sprintf("%s {auto res = this->%s[i].deserialize_%s(buf + offset, size - offset); if (res < 0) return res; offset += res;}",
+						loop, f.Name, dir)
+					bufSizeExpr := fmt.Sprintf("sum_buf_size(%s, %s, &%s::buf_size_%s)", regs, count, elem, dir)
+					if cf.IsReadable && cf.IsWritable {
+						cf.SizeExpr = fmt.Sprintf("sum_buf_size(%s, %s, &%s::size)", regs, count, elem)
+					}
+					if pointer {
+						// the elements are decoded into the buffer the caller provides, e.g. not in decode_register
+						nullCheck := fmt.Sprintf("if (this->%s == nullptr && %s != 0) return -2;", f.Name, count)
+						if dir == "read" {
+							cf.DeserializeReadData = append(cf.DeserializeReadData, nullCheck)
+						} else {
+							cf.DeserializeWriteData = append(cf.DeserializeWriteData, nullCheck)
+						}
+					}
+					if vector {
+						// the elements are default constructed before decoding
+						resize := fmt.Sprintf("this->%s.resize(%s);", f.Name, count)
+						if dir == "read" {
+							cf.DeserializeReadData = append(cf.DeserializeReadData, resize)
+						} else {
+							cf.DeserializeWriteData = append(cf.DeserializeWriteData, resize)
+						}
+					}
+					if dir == "read" {
+						cf.SerializeReadData = append(cf.SerializeReadData, serCode)
+						cf.DeserializeReadData = append(cf.DeserializeReadData, deserCode)
+						cf.BufSize4ReadExpr = bufSizeExpr
+					} else {
+						cf.SerializeWriteData = append(cf.SerializeWriteData, serCode)
+						cf.DeserializeWriteData = append(cf.DeserializeWriteData, deserCode)
+						cf.BufSize4WriteExpr = bufSizeExpr
+					}
+				}
+
 			case f.Type.Array != nil:
-				elem := toCppTypes(f.Type.Array.Type.Name)
+				elem := out.cppType(f.Type.Array.Type.Name)
+				at := f.Type.Array
+				dims := "" // the inner dimensions of the multi-dimensional array
+				for _, d := range at.Dims {
+					dims += "[" + cppIntLiteral(d) + "]"
+				}
+				inner := at.InnerCount()
+				elemBytes := fmt.Sprintf("sizeof(%s)", elem)
+				encVarray, decVarray := "encode_varray", "decode_varray"
+				if is24BitType(at.Type.Name) {
+					// the 24-bit integers take 3 bytes on the wire and 4 bytes in memory
+					elemBytes, encVarray, decVarray = "3", "encode24_varray", "decode24_varray"
+				}
 				if f.Type.Array.Size.Constant != nil {
 					sz := *f.Type.Array.Size.Constant
-					cf.Decl = fmt.Sprintf("%s %s[%s];", elem, f.Name, sz)
+					cf.Decl = fmt.Sprintf("%s %s[%s]%s;", elem, f.Name, sz, dims)
+					cf.Params = []string{fmt.Sprintf("const %s (&%s)[%s]%s", elem, cppArgName(f.Name), sz, dims)}
 					serCode := []string{
 						fmt.Sprintf("if (offset + sizeof(this->%s) > size) return -1;", f.Name),
-						fmt.Sprintf("offset += bigendian::encode(buf + offset, this->%s);", f.Name),
+						fmt.Sprintf("offset += %s::encode(buf + offset, this->%s);", ns, f.Name),
 					}
 					deserCode := []string{
 						fmt.Sprintf("if (offset + sizeof(this->%s) > size) return -1;", f.Name),
-						fmt.Sprintf("offset += bigendian::decode(this->%s, buf + offset);", f.Name),
+						fmt.Sprintf("offset += %s::decode(this->%s, buf + offset);", ns, f.Name),
+					}
+					szInt64, _ := strconv.ParseInt(sz, 0, 64)
+					szInt := int(szInt64)
+					size := szInt * inner * wireTypeSize(f.Type.Array.Type.Name)
+					if at.IsMultiDim() || is24BitType(at.Type.Name) {
+						// the array is sent element by element, the multi-dimensional array elements are contiguous
+						first := fmt.Sprintf("&this->%s%s", f.Name, strings.Repeat("[0]", len(at.Dims)+1))
+						serCode[1] = fmt.Sprintf("offset += %s::%s(buf + offset, %s, %d);", ns, encVarray, first, szInt*inner)
+						deserCode[1] = fmt.Sprintf("offset += %s::%s(%s, buf + offset, %d);", ns, decVarray, first, szInt*inner)
+					}
+					if is24BitType(at.Type.Name) {
+						serCode[0] = fmt.Sprintf("if ((%ssize_t)offset + %d > size) return -1;", out.Std, size)
+						deserCode[0] = serCode[0]
+					} else {
+						cr.addSizeAssert(reg.Name+"::"+f.Name, size)
 					}
 					if cf.IsReadable {
 						cf.SerializeReadData = append(cf.SerializeReadData, serCode...)
 						cf.DeserializeReadData = append(cf.DeserializeReadData, deserCode...)
+						cr.BufSize4ReadConst += size
 					}
 					if cf.IsWritable {
 						cf.SerializeWriteData = append(cf.SerializeWriteData, serCode...)
 						cf.DeserializeWriteData = append(cf.DeserializeWriteData, deserCode...)
+						cr.BufSize4WriteConst += size
 					}
 				} else {
 					cf.Decl = fmt.Sprintf("%s* %s;", elem, f.Name)
+					cf.Params = []string{fmt.Sprintf("const %s* %s", elem, cppArgName(f.Name))}
 					szFieldName := *f.Type.Array.Size.Variable
 					field, bm := reg.FindFieldByName(szFieldName, len(cr.Fields))
-					if bm != nil {
+					elemSize := wireTypeSize(f.Type.Array.Type.Name)
+					if !is24BitType(at.Type.Name) {
+						cr.addSizeAssert(elem, elemSize)
+					}
+					var bufSizeExpr string
+					if at.IsMultiDim() && out.Vectors {
+						return "", "", fmt.Errorf("std::vector array '%s' in register '%s' cannot be multi-dimensional", f.Name, reg.Name)
+					}
+					if at.IsMultiDim() {
+						// the pointer to the rows, they are sent flattened like the one-dimensional array
+						count := fmt.Sprintf("this->%s", field.Name)
+						if bm != nil {
+							count = fmt.Sprintf("((this->%s&%s_%s_bm)>>%d)", field.Name, field.Name, bm.Name, bm.StartBit())
+						}
+						cf.Decl = fmt.Sprintf("%s (*%s)%s;", elem, f.Name, dims)
+						cf.Params = []string{fmt.Sprintf("const %s (*%s)%s", elem, cppArgName(f.Name), dims)}
+						flat := fmt.Sprintf("reinterpret_cast<%s*>(this->%s)", elem, f.Name)
+						serCode := []string{
+							"{",
+							fmt.Sprintf("    %ssize_t elems = (%ssize_t)%s * %d;", out.Std, out.Std, count, inner),
+							fmt.Sprintf("    if (offset + %s*elems > size) return -1;", elemBytes),
+							fmt.Sprintf("    offset += %s::%s(buf + offset, reinterpret_cast<const %s*>(this->%s), elems);", ns, encVarray, elem, f.Name),
+							"}",
+						}
+						nullCheck := fmt.Sprintf("if (this->%s == nullptr && %s != 0) return -2;", f.Name, count)
+						deserCode := []string{
+							"{",
+							fmt.Sprintf("    %ssize_t elems = (%ssize_t)%s * %d;", out.Std, out.Std, count, inner),
+							"    " + nullCheck,
+							fmt.Sprintf("    if (offset + %s*elems > size) return -1;", elemBytes),
+							fmt.Sprintf("    offset += %s::%s(%s, buf + offset, elems);", ns, decVarray, flat),
+							"}",
+						}
+						bufSizeExpr = fmt.Sprintf("%d * (%ssize_t)%s", elemSize*inner, out.Std, count)
+						cf.ConsistencyChecks = append(cf.ConsistencyChecks, nullCheck)
+						if cf.IsReadable {
+							cf.SerializeReadData = append(cf.SerializeReadData, serCode...)
+							cf.DeserializeReadData = append(cf.DeserializeReadData, deserCode...)
+						}
+						if cf.IsWritable {
+							cf.SerializeWriteData = append(cf.SerializeWriteData, serCode...)
+							cf.DeserializeWriteData = append(cf.DeserializeWriteData, deserCode...)
+						}
+					} else if out.Vectors {
+						// the vector is resized to the size field value, so it owns the decoded elements
+						count := fmt.Sprintf("this->%s", field.Name)
+						if bm != nil {
+							count = fmt.Sprintf("((this->%s&%s_%s_bm)>>%d)", field.Name, field.Name, bm.Name, bm.StartBit())
+						}
+						cf.Decl = fmt.Sprintf("std::vector<%s> %s;", elem, f.Name)
+						cf.Params = []string{fmt.Sprintf("const std::vector<%s>& %s", elem, cppArgName(f.Name))}
+						serCode := []string{
+							fmt.Sprintf("if (offset + %s*this->%s.size() > size) return -1;", elemBytes, f.Name),
+							fmt.Sprintf("offset += %s::%s(buf + offset, this->%s.data(), this->%s.size());", ns, encVarray, f.Name, f.Name),
+						}
+						deserCode := []string{
+							"{",
+							fmt.Sprintf("    std::size_t elems = (std::size_t)%s;", count),
+							fmt.Sprintf("    if (offset + %s*elems > size) return -1;", elemBytes),
+							fmt.Sprintf("    this->%s.resize(elems);", f.Name),
+							fmt.Sprintf("    offset += %s::%s(this->%s.data(), buf + offset, elems);", ns, decVarray, f.Name),
+							"}",
+						}
+						bufSizeExpr = fmt.Sprintf("%d * this->%s.size()", elemSize, f.Name)
+						cf.ConsistencyChecks = append(cf.ConsistencyChecks,
+							fmt.Sprintf("if (this->%s.size() != (std::size_t)%s) return -2;", f.Name, count))
+						if cf.IsReadable {
+							cf.SerializeReadData = append(cf.SerializeReadData, serCode...)
+							cf.DeserializeReadData = append(cf.DeserializeReadData, deserCode...)
+						}
+						if cf.IsWritable {
+							cf.SerializeWriteData = append(cf.SerializeWriteData, serCode...)
+							cf.DeserializeWriteData = append(cf.DeserializeWriteData, deserCode...)
+						}
+					} else if bm != nil {
 						// this is the bit mask field
 						serCode := []string{
 							"{",
-							fmt.Sprintf("    %s elems = (this->%s&%s)>>%d;", toCppTypes(field.Type.Bitfield.Base),
+							fmt.Sprintf("    %s elems = (this->%s&%s)>>%d;", out.cppType(field.Type.Bitfield.Base),
 								field.Name, fmt.Sprintf("%s_%s_bm", field.Name, bm.Name), bm.StartBit()),
-							fmt.Sprintf("    if (offset + sizeof(%s)*elems > size) return -1;", elem),
-							fmt.Sprintf("    offset += bigendian::encode_varray(buf + offset, this->%s, elems);", f.Name),
+							fmt.Sprintf("    if (offset + %s*elems > size) return -1;", elemBytes),
+							fmt.Sprintf("    offset += %s::%s(buf + offset, this->%s, elems);", ns, encVarray, f.Name),
 							"}",
 						}
 						deserCode := []string{
 							"{",
-							fmt.Sprintf("    %s elems = (this->%s&%s)>>%d;", toCppTypes(field.Type.Bitfield.Base),
+							fmt.Sprintf("    %s elems = (this->%s&%s)>>%d;", out.cppType(field.Type.Bitfield.Base),
 								field.Name, fmt.Sprintf("%s_%s_bm", field.Name, bm.Name), bm.StartBit()),
-							fmt.Sprintf("    if (offset + sizeof(%s)*elems > size) return -1;", elem),
-							fmt.Sprintf("    offset += bigendian::decode_varray(this->%s, buf + offset, elems);", f.Name),
+							fmt.Sprintf("    if (this->%s == nullptr && elems != 0) return -2;", f.Name),
+							fmt.Sprintf("    if (offset + %s*elems > size) return -1;", elemBytes),
+							fmt.Sprintf("    offset += %s::%s(this->%s, buf + offset, elems);", ns, decVarray, f.Name),
 							"}",
 						}
+						bufSizeExpr = fmt.Sprintf("%d * (%ssize_t)((this->%s&%s_%s_bm)>>%d)",
+							elemSize, out.Std, field.Name, field.Name, bm.Name, bm.StartBit())
+						cf.ConsistencyChecks = append(cf.ConsistencyChecks,
+							fmt.Sprintf("if (this->%s == nullptr && ((this->%s&%s_%s_bm)>>%d) != 0) return -2;",
+								f.Name, field.Name, field.Name, bm.Name, bm.StartBit()))
 						if cf.IsReadable {
 							cf.SerializeReadData = append(cf.SerializeReadData, serCode...)
 							cf.DeserializeReadData = append(cf.DeserializeReadData, deserCode...)
@@ -317,13 +1230,17 @@ func GenerateHppCpp(dev *parser.Device, namespace, hppFileName string) (string,
 					} else {
 						// this is the regular field
 						serCode := []string{
-							fmt.Sprintf("if (offset + sizeof(%s)*this->%s > size) return -1;", elem, field.Name),
-							fmt.Sprintf("offset += bigendian::encode_varray(buf + offset, this->%s, this->%s);", f.Name, field.Name),
+							fmt.Sprintf("if (offset + %s*this->%s > size) return -1;", elemBytes, field.Name),
+							fmt.Sprintf("offset += %s::%s(buf + offset, this->%s, this->%s);", ns, encVarray, f.Name, field.Name),
 						}
+						nullCheck := fmt.Sprintf("if (this->%s == nullptr && this->%s != 0) return -2;", f.Name, field.Name)
 						deserCode := []string{
-							fmt.Sprintf("if (offset + sizeof(%s)*this->%s > size) return -1;", elem, field.Name),
-							fmt.Sprintf("offset += bigendian::decode_varray(this->%s, buf + offset, this->%s);", f.Name, field.Name),
+							nullCheck,
+							fmt.Sprintf("if (offset + %s*this->%s > size) return -1;", elemBytes, field.Name),
+							fmt.Sprintf("offset += %s::%s(this->%s, buf + offset, this->%s);", ns, decVarray, f.Name, field.Name),
 						}
+						bufSizeExpr = fmt.Sprintf("%d * (%ssize_t)this->%s", elemSize, out.Std, field.Name)
+						cf.ConsistencyChecks = append(cf.ConsistencyChecks, nullCheck)
 						if cf.IsReadable {
 							cf.SerializeReadData = append(cf.SerializeReadData, serCode...)
 							cf.DeserializeReadData = append(cf.DeserializeReadData, deserCode...)
@@ -333,41 +1250,198 @@ func GenerateHppCpp(dev *parser.Device, namespace, hppFileName string) (string,
 							cf.DeserializeWriteData = append(cf.DeserializeWriteData, deserCode...)
 						}
 					}
+					if cf.IsReadable {
+						cf.BufSize4ReadExpr = bufSizeExpr
+					}
+					if cf.IsWritable {
+						cf.BufSize4WriteExpr = bufSizeExpr
+					}
+				}
+
+			case f.Type.String != nil:
+				prefix := out.cppType(f.Type.String.PrefixType())
+				maxLen := f.Type.String.MaxLen()
+				// the string is kept in the fixed capacity buffer, its length is in <name>_len
+				cf.Decl = fmt.Sprintf("%s %s_len;\n    char %s[%d];", prefix, f.Name, f.Name, maxLen)
+				cf.Params = []string{fmt.Sprintf("%s %s", prefix, cppArgName(f.Name+"_len")), fmt.Sprintf("const char* %s", cppArgName(f.Name))}
+				serCode := []string{
+					fmt.Sprintf("if (offset + sizeof(this->%s_len) + this->%s_len > size) return -1;", f.Name, f.Name),
+					fmt.Sprintf("offset += %s::encode(buf + offset, this->%s_len);", ns, f.Name),
+					fmt.Sprintf("memcpy(buf + offset, this->%s, this->%s_len); offset += this->%s_len;", f.Name, f.Name, f.Name),
+				}
+				deserCode := []string{
+					fmt.Sprintf("if (offset + sizeof(this->%s_len) > size) return -1;", f.Name),
+					fmt.Sprintf("offset += %s::decode(this->%s_len, buf + offset);", ns, f.Name),
+				}
+				if f.Type.String.MaxLenStr != nil {
+					// without the explicit maximum the length is limited by the prefix type
+					lenCheck := fmt.Sprintf("if (this->%s_len > %d) return -2;", f.Name, maxLen)
+					deserCode = append(deserCode, lenCheck)
+					cf.ConsistencyChecks = append(cf.ConsistencyChecks, lenCheck)
+				}
+				deserCode = append(deserCode,
+					fmt.Sprintf("if ((%ssize_t)offset + this->%s_len > size) return -1;", out.Std, f.Name),
+					fmt.Sprintf("memcpy(this->%s, buf + offset, this->%s_len); offset += this->%s_len;", f.Name, f.Name, f.Name))
+				size := wireTypeSize(f.Type.String.PrefixType())
+				cr.addSizeAssert(reg.Name+"::"+f.Name+"_len", size)
+				if cf.IsReadable {
+					cf.SerializeReadData = append(cf.SerializeReadData, serCode...)
+					cf.DeserializeReadData = append(cf.DeserializeReadData, deserCode...)
+					cr.BufSize4ReadConst += size
+					cf.BufSize4ReadExpr = fmt.Sprintf("(%ssize_t)this->%s_len", out.Std, f.Name)
+				}
+				if cf.IsWritable {
+					cf.SerializeWriteData = append(cf.SerializeWriteData, serCode...)
+					cf.DeserializeWriteData = append(cf.DeserializeWriteData, deserCode...)
+					cr.BufSize4WriteConst += size
+					cf.BufSize4WriteExpr = fmt.Sprintf("(%ssize_t)this->%s_len", out.Std, f.Name)
 				}
 
-			case f.Type.Simple != nil:
-				elem := toCppTypes(f.Type.Simple.Name)
-				cf.Decl = fmt.Sprintf("%s %s;", elem, f.Name)
+			case f.Type.Simple != nil, f.Type.Fixed != nil:
+				elem := out.cppType(scalarTypeName(f))
+				cf.Decl = fmt.Sprintf("%s %s%s;", elem, f.Name, cppDefault(f))
+				cf.Params = []string{fmt.Sprintf("%s %s", elem, cppArgName(f.Name))}
+				if f.Type.Fixed != nil {
+					// the value is rounded half away from zero, so no math library is required
+					scale := fixedScale(f.Type.Fixed)
+					cf.Accessors = append(cf.Accessors,
+						fmt.Sprintf("double get_%s() const { return static_cast<double>(this->%s) / %s; }",
+							f.Name, f.Name, scale),
+						fmt.Sprintf("void set_%s(double v) { this->%s = static_cast<%s>(v * %s + (v < 0 ? -0.5 : 0.5)); }",
+							f.Name, f.Name, elem, scale))
+				}
+				value := "this->" + f.Name
+				if scalarTypeName(f) == "float16" {
+					// the conversions do not depend on the byte order, so the header includes only bigendian.h
+					out.Float16 = true
+					cf.Accessors = append(cf.Accessors,
+						fmt.Sprintf("float get_%s() const { return bigendian::float16_from_bits(this->%s); }", f.Name, f.Name),
+						fmt.Sprintf("void set_%s(float v) { this->%s = bigendian::float16_bits(v); }", f.Name, f.Name))
+					value = fmt.Sprintf("bigendian::float16_from_bits(this->%s)", f.Name)
+				}
 				serCode := []string{
 					fmt.Sprintf("if (offset + sizeof(this->%s) > size) return -1;", f.Name),
-					fmt.Sprintf("offset += bigendian::encode(buf + offset, this->%s);", f.Name),
+					fmt.Sprintf("offset += %s::encode(buf + offset, this->%s);", ns, f.Name),
 				}
 				deserCode := []string{
 					fmt.Sprintf("if (offset + sizeof(this->%s) > size) return -1;", f.Name),
-					fmt.Sprintf("offset += bigendian::decode(this->%s, buf + offset);", f.Name),
+					fmt.Sprintf("offset += %s::decode(this->%s, buf + offset);", ns, f.Name),
+				}
+				if conds := rangeConditions(f, value); len(conds) > 0 {
+					cf.ConsistencyChecks = append(cf.ConsistencyChecks,
+						fmt.Sprintf("if (%s) return -2;", strings.Join(conds, " || ")))
+				}
+				size := wireTypeSize(scalarTypeName(f))
+				if is24BitType(scalarTypeName(f)) {
+					// the 24-bit integers take 3 bytes on the wire and 4 bytes in memory
+					serCode = []string{
+						fmt.Sprintf("if ((%ssize_t)offset + %d > size) return -1;", out.Std, size),
+						fmt.Sprintf("offset += %s::encode24(buf + offset, this->%s);", ns, f.Name),
+					}
+					deserCode = []string{
+						fmt.Sprintf("if ((%ssize_t)offset + %d > size) return -1;", out.Std, size),
+						fmt.Sprintf("offset += %s::decode24(this->%s, buf + offset);", ns, f.Name),
+					}
+				} else {
+					cr.addSizeAssert(reg.Name+"::"+f.Name, size)
 				}
 				if cf.IsReadable {
 					cf.SerializeReadData = append(cf.SerializeReadData, serCode...)
 					cf.DeserializeReadData = append(cf.DeserializeReadData, deserCode...)
+					cr.BufSize4ReadConst += size
 				}
 				if cf.IsWritable {
 					cf.SerializeWriteData = append(cf.SerializeWriteData, serCode...)
 					cf.DeserializeWriteData = append(cf.DeserializeWriteData, deserCode...)
+					cr.BufSize4WriteConst += size
 				}
 			default:
 				cf.Decl = fmt.Sprintf("/* unsupported field %s */", f.Name)
 			}
 
+			// The conditional field is sent only if its condition is set, the absent field keeps its value
+			if f.HasCondition() {
+				cf.Condition = cppConditionExpr(reg, f, len(cr.Fields))
+				cf.BufSize4ReadExpr = sizeSum(cr.BufSize4ReadConst-readConst, cf.BufSize4ReadExpr)
+				cf.BufSize4WriteExpr = sizeSum(cr.BufSize4WriteConst-writeConst, cf.BufSize4WriteExpr)
+				cr.BufSize4ReadConst, cr.BufSize4WriteConst = readConst, writeConst
+				cf.SerializeReadData = cppIf(cf.Condition, cf.SerializeReadData)
+				cf.SerializeWriteData = cppIf(cf.Condition, cf.SerializeWriteData)
+				cf.DeserializeReadData = cppIf(cf.Condition, cf.DeserializeReadData)
+				cf.DeserializeWriteData = cppIf(cf.Condition, cf.DeserializeWriteData)
+				cf.ConsistencyChecks = cppIf(cf.Condition, cf.ConsistencyChecks)
+			}
+
+			// The read-write field is counted once in size(), the gaps before it in both directions
+			switch {
+			case cf.IsReadable && cf.IsWritable:
+				cr.SizeConst += cr.BufSize4ReadConst - readConst + reg.Padding(f, false)
+			case cf.IsReadable:
+				cr.SizeConst += cr.BufSize4ReadConst - readConst
+			default:
+				cr.SizeConst += cr.BufSize4WriteConst - writeConst
+			}
+			if cf.SizeExpr == "" {
+				cf.SizeExpr = cf.BufSize4ReadExpr
+				if !cf.IsReadable {
+					cf.SizeExpr = cf.BufSize4WriteExpr
+				}
+			}
+			if out.Printer {
+				cf.Print = out.cppPrintCode(reg, f, len(cr.Fields), printed == 0)
+				if len(cf.Print) > 0 {
+					printed++
+				}
+			}
+
 			cr.Fields = append(cr.Fields, cf)
 		}
+		// the constants interleaved with the fields keep their places in the struct
+		var ci, fi int
+		for _, item := range reg.Body.Items {
+			if item.Constant != nil {
+				cr.Members = append(cr.Members, CppMember{Constant: &cr.Constants[ci]})
+				ci++
+			} else {
+				cr.Members = append(cr.Members, CppMember{Field: &cr.Fields[fi]})
+				fi++
+			}
+		}
+		if reg.Specifier == "w" {
+			// the write-only command register may be sent from the field values without its instance
+			cr.WriteFrom = true
+			for _, cf := range cr.Fields {
+				cr.WriteFromParams = append(cr.WriteFromParams, cf.Params...)
+				cr.WriteFromChecks = append(cr.WriteFromChecks, cppArgCode(cf.ConsistencyChecks)...)
+				cr.WriteFromData = append(cr.WriteFromData, cppArgCode(cf.SerializeWriteData)...)
+			}
+		}
 		out.Registers = append(out.Registers, cr)
 	}
+	for _, m := range dev.Messages {
+		cm := CppMessage{Name: m.Name, Doc: declComments(m.Doc, m.TrailingComment), HasSize: true}
+		for _, mm := range m.Members {
+			name := strings.TrimLeft(cSnakeCase(mm.Register), "_")
+			if cppMessageMethods[name] || cppKeywords[name] {
+				return "", "", fmt.Errorf("register '%s' of message '%s' is the '%s' member, which is the name of the message method or a C++ keyword",
+					mm.Register, m.Name, name)
+			}
+			cm.Members = append(cm.Members, CppMessageMember{
+				Doc:      flattenComments(mm.Doc),
+				Name:     name,
+				Type:     mm.Register,
+				Trailing: safeString(mm.TrailingComment),
+			})
+			cm.HasSize = cm.HasSize && cppHasSize(dev, dev.FindRegisterByName(mm.Register))
+		}
+		out.Messages = append(out.Messages, cm)
+	}
 
 	var hpp, cpp bytes.Buffer
-	if err := tplHpp.Execute(&hpp, out); err != nil {
+	if err := hppTpl.Execute(&hpp, out); err != nil {
 		return "", "", err
 	}
-	if err := tplCpp.Execute(&cpp, out); err != nil {
+	if err := cppTpl.Execute(&cpp, out); err != nil {
 		return "", "", err
 	}
 	return strings.TrimSpace(hpp.String()) + "\n", strings.TrimSpace(cpp.String()), nil
@@ -377,6 +1451,244 @@ func GenerateHppCpp(dev *parser.Device, namespace, hppFileName string) (string,
 // Helpers
 //
 
+// cppHasSize returns true if the register struct may have the size() method: neither the register
+// nor the read-write registers it refers to have a member named size
+func cppHasSize(dev *parser.Device, reg *parser.Register) bool {
+	for _, c := range reg.Body.Constants() {
+		if c.Name == "size" {
+			return false
+		}
+	}
+	for _, f := range reg.Body.Fields() {
+		var ref string
+		switch {
+		case f.Name == "size" && !f.Reserved:
+			return false
+		case f.Specifier != "":
+			// the one direction register is counted by its buffer size
+		case f.Type.Simple != nil && f.Type.Simple.IsRegisterRef():
+			ref = f.Type.Simple.Name
+		case f.Type.Array != nil && f.Type.Array.Type.IsRegisterRef():
+			ref = f.Type.Array.Type.Name
+		}
+		if nested := dev.FindRegisterByName(ref); nested != nil && !cppHasSize(dev, nested) {
+			return false
+		}
+	}
+	return true
+}
+
+// addSizeAssert adds the static_assert check that the type or the member takes the size bytes,
+// the same type is checked once
+func (r *CppRegister) addSizeAssert(what string, size int) {
+	a := fmt.Sprintf("static_assert(sizeof(%s) == %d, \"%s must take %d byte(s)\");", what, size, what, size)
+	if !slices.Contains(r.SizeAsserts, a) {
+		r.SizeAsserts = append(r.SizeAsserts, a)
+	}
+}
+
+// cppPaddingCode returns the code writing and skipping the zero bytes, e.g. the reserved ones,
+// std is the prefix of size_t
+func cppPaddingCode(std string, size int) (serCode, deserCode []string) {
+	serCode = []string{
+		fmt.Sprintf("if ((%ssize_t)offset + %d > size) return -1;", std, size),
+		fmt.Sprintf("memset(buf + offset, 0, %d); offset += %d;", size, size),
+	}
+	deserCode = []string{
+		fmt.Sprintf("if ((%ssize_t)offset + %d > size) return -1;", std, size),
+		fmt.Sprintf("offset += %d;", size),
+	}
+	return serCode, deserCode
+}
+
+// cppConditionExpr returns the expression which is true if the conditional field is sent, the
+// condition is either the single-bit member or the uint8 field, which is set if it is not zero
+func cppConditionExpr(reg *parser.Register, f *parser.Field, idx int) string {
+	fld, bm := reg.FindFieldByName(*f.Condition, idx)
+	if bm != nil {
+		return fmt.Sprintf("(this->%s & %s_%s_bm) != 0", fld.Name, fld.Name, bm.Name)
+	}
+	return fmt.Sprintf("this->%s != 0", fld.Name)
+}
+
+// cppIf returns the code executed only if the condition is true, there is no code if the code is empty
+func cppIf(cond string, code []string) []string {
+	if len(code) == 0 {
+		return nil
+	}
+	res := []string{fmt.Sprintf("if (%s) {", cond)}
+	for _, line := range code {
+		res = append(res, "    "+line)
+	}
+	return append(res, "}")
+}
+
+// cppPrintCode returns the code printing the field name and value in operator<< of the register r,
+// the reserved, magic and checksum fields have no value, so there is no code for them
+func (d *CppDevice) cppPrintCode(reg *parser.Register, f *parser.Field, idx int, first bool) []string {
+	if f.Reserved || f.IsMagic() || f.Type.CRC != nil {
+		return nil
+	}
+	label := f.Name + ": "
+	if !first {
+		label = ", " + label
+	}
+	code := []string{fmt.Sprintf("os << %q;", label)}
+	t := f.Type
+	switch {
+	case t.Fixed != nil, t.Simple != nil && scalarTypeName(f) == "float16":
+		code = append(code, fmt.Sprintf("print_value(os, r.get_%s());", f.Name))
+
+	case t.Bitfield != nil:
+		code = append(code, fmt.Sprintf("print_value(os, r.%s);", f.Name), `os << " {";`)
+		sep := ""
+		for _, bm := range t.Bitfield.Bits {
+			if bm.Reserved {
+				continue
+			}
+			code = append(code, fmt.Sprintf("os << \"%s%s: \"; print_value(os, r.get_%s_%s());", sep, bm.Name, f.Name, bm.Name))
+			sep = ", "
+		}
+		code = append(code, "os << '}';")
+
+	case t.Array != nil && t.Array.Size.Variable != nil && d.Vectors:
+		code = append(code, fmt.Sprintf("print_array(os, r.%s.data(), r.%s.size());", f.Name, f.Name))
+
+	case t.Array != nil && t.Array.Size.Variable != nil:
+		// the pointer is printed up to the size field value, the bit member one is read by its getter
+		field, bm := reg.FindFieldByName(*t.Array.Size.Variable, idx)
+		count := fmt.Sprintf("r.%s", field.Name)
+		if bm != nil {
+			count = fmt.Sprintf("r.get_%s_%s()", field.Name, bm.Name)
+		}
+		code = append(code, fmt.Sprintf("print_array(os, r.%s, (std::size_t)%s);", f.Name, count))
+
+	case t.String != nil:
+		maxLen := t.String.MaxLen()
+		code = append(code, fmt.Sprintf("os << '\"'; os.write(r.%s, r.%s_len < %d ? r.%s_len : %d); os << '\"';",
+			f.Name, f.Name, maxLen, f.Name, maxLen))
+
+	default:
+		code = append(code, fmt.Sprintf("print_value(os, r.%s);", f.Name))
+	}
+	if f.HasCondition() {
+		// the absent field keeps its value, but it is not a part of the register on the wire
+		fld, bm := reg.FindFieldByName(*f.Condition, idx)
+		cond := fmt.Sprintf("r.%s != 0", fld.Name)
+		if bm != nil {
+			cond = fmt.Sprintf("r.get_%s_%s()", fld.Name, bm.Name)
+		}
+		code = cppIf(cond, code)
+	}
+	return code
+}
+
+// cppNamespaces splits the "::" or "." separated namespace into the nested namespaces names,
+// every name must be a C++ identifier
+func cppNamespaces(namespace string) ([]string, error) {
+	names := strings.Split(strings.ReplaceAll(namespace, "::", "."), ".")
+	for _, name := range names {
+		if !isCppIdentifier(name) {
+			return nil, fmt.Errorf("invalid C++ namespace %q: %q is not an identifier", namespace, name)
+		}
+	}
+	return names, nil
+}
+
+// isCppIdentifier returns true if the name is a valid C++ identifier, which is not a keyword
+func isCppIdentifier(name string) bool {
+	if name == "" || cppKeywords[name] {
+		return false
+	}
+	for i, c := range name {
+		if c != '_' && (c < 'a' || c > 'z') && (c < 'A' || c > 'Z') && (i == 0 || c < '0' || c > '9') {
+			return false
+		}
+	}
+	return true
+}
+
+// cppArgLocals are the names of serialize_write_from parameters and local variables, the field
+// parameters of these names get the underscore suffix
+var cppArgLocals = map[string]bool{
+	"buf": true, "size": true, "offset": true, "res": true, "elems": true, "i": true, "v": true,
+	"crc": true, "magic": true,
+}
+
+// cppArgName returns the serialize_write_from parameter name of the field
+func cppArgName(name string) string {
+	if cppArgLocals[name] {
+		return name + "_"
+	}
+	return name
+}
+
+var cppMemberRe = regexp.MustCompile(`this->([A-Za-z_][A-Za-z0-9_]*)`)
+
+// cppArgCode returns the register method code using the serialize_write_from parameters instead
+// of the register members
+func cppArgCode(code []string) []string {
+	var res []string
+	for _, line := range code {
+		res = append(res, cppMemberRe.ReplaceAllStringFunc(line, func(m string) string {
+			return cppArgName(strings.TrimPrefix(m, "this->"))
+		}))
+	}
+	return res
+}
+
+// cppMessageMethods are the methods of the message struct, its members cannot have their names
+var cppMessageMethods = map[string]bool{
+	"serialize_read": true, "serialize_write": true, "deserialize_read": true, "deserialize_write": true,
+	"buf_size_read": true, "buf_size_write": true, "size": true, "check": true,
+}
+
+// cppKeywords are the C++ keywords which cannot be the namespaces names or the message members
+var cppKeywords = map[string]bool{
+	"alignas": true, "alignof": true, "and": true, "and_eq": true, "asm": true, "auto": true, "bitand": true,
+	"bitor": true, "bool": true, "break": true, "case": true, "catch": true, "char": true, "char16_t": true,
+	"char32_t": true, "class": true, "compl": true, "const": true, "const_cast": true, "constexpr": true,
+	"continue": true, "decltype": true, "default": true, "delete": true, "do": true, "double": true,
+	"dynamic_cast": true, "else": true, "enum": true, "explicit": true, "export": true, "extern": true,
+	"false": true, "float": true, "for": true, "friend": true, "goto": true, "if": true, "inline": true,
+	"int": true, "long": true, "mutable": true, "namespace": true, "new": true, "noexcept": true, "not": true,
+	"not_eq": true, "nullptr": true, "operator": true, "or": true, "or_eq": true, "private": true,
+	"protected": true, "public": true, "register": true, "reinterpret_cast": true, "return": true,
+	"short": true, "signed": true, "sizeof": true, "static": true, "static_assert": true, "static_cast": true,
+	"struct": true, "switch": true, "template": true, "this": true, "thread_local": true, "throw": true,
+	"true": true, "try": true, "typedef": true, "typeid": true, "typename": true, "union": true,
+	"unsigned": true, "using": true, "virtual": true, "void": true, "volatile": true, "wchar_t": true,
+	"while": true, "xor": true, "xor_eq": true,
+}
+
+// cppCodecNamespace returns the namespace of the runtime functions encoding the field
+func cppCodecNamespace(f *parser.Field) string {
+	if f.IsLittleEndian() {
+		return "littleendian"
+	}
+	return "bigendian"
+}
+
+// cppCRCFunc returns the function calculating the checksum and registers its runtime helper
+func (d *CppDevice) cppCRCFunc(ct *parser.CRCType) string {
+	alg := ct.AlgorithmName()
+	if d.CRCs == nil {
+		d.CRCs = make(map[string]bool)
+	}
+	d.CRCs[alg] = true
+	return ct.Kind + "_" + alg
+}
+
+// cppType returns the C++ type of the built-in type, the integer types are taken from
+// the std namespace in the plain C++ mode
+func (d *CppDevice) cppType(typ string) string {
+	t := toCppTypes(typ)
+	if strings.HasSuffix(t, "_t") {
+		return d.Std + t
+	}
+	return t
+}
+
 func toCppTypes(typ string) string {
 	switch typ {
 	case "int8":
@@ -387,14 +1699,17 @@ func toCppTypes(typ string) string {
 		return "int16_t"
 	case "uint16":
 		return "uint16_t"
-	case "int32":
+	case "int24", "int32":
 		return "int32_t"
-	case "uint32":
+	case "uint24", "uint32":
 		return "uint32_t"
 	case "int64":
 		return "int64_t"
 	case "uint64":
 		return "uint64_t"
+	case "float16":
+		// the half-precision number is kept in its IEEE 754 bits, the accessors convert it
+		return "uint16_t"
 	case "float32":
 		return "float"
 	case "float64":
@@ -2,6 +2,8 @@ package generator
 
 import (
 	"fmt"
+	"os"
+	"strings"
 	"testing"
 
 	"github.com/dspasibenko/pargus/pkg/parser"
@@ -211,3 +213,423 @@ func TestGenerateCppWithRegisterRefReadWrite(t *testing.T) {
 	require.Contains(t, hpp, "Config write_config;")
 	require.Contains(t, cpp, "this->write_config.serialize_write")
 }
+
+func TestGenerateCppWithFieldEndianness(t *testing.T) {
+	input := `
+    device test
+
+    register Frame(1) {
+        header uint16;
+        payload uint32 @le;
+        size uint8;
+        data [size]uint16 @le;
+    };`
+
+	device, err := parser.Parse(input)
+	require.NoError(t, err)
+
+	_, cpp, err := GenerateHppCpp(device, "test", "test.h")
+	require.NoError(t, err)
+	require.Contains(t, cpp, `#include "littleendian.h"`)
+	require.Contains(t, cpp, "offset += bigendian::encode(buf + offset, this->header);")
+	require.Contains(t, cpp, "offset += littleendian::encode(buf + offset, this->payload);")
+	require.Contains(t, cpp, "offset += littleendian::decode_varray(this->data, buf + offset, this->size);")
+}
+
+func TestGenerateCppBufSize(t *testing.T) {
+	input := `
+    device test
+
+    register Config(1) {
+        mode uint8;
+        level:r uint16;
+    };
+
+    register Main(2) {
+        id uint32;
+        flags uint8{ready: 0, count: 1-3};
+        config Config;
+        data [4]uint16;
+        size:w uint8;
+        items [size]uint32;
+        bits [flags_count]int16;
+    };`
+
+	device, err := parser.Parse(input)
+	require.NoError(t, err)
+
+	hpp, cpp, err := GenerateHppCpp(device, "test", "test.h")
+	require.NoError(t, err)
+	require.Contains(t, hpp, "size_t buf_size_read() const;")
+	require.Contains(t, hpp, "size_t buf_size_write() const;")
+
+	require.Contains(t, cpp, "size_t Config::buf_size_read() const {\n\tsize_t size = 3;\n\treturn size;\n}")
+	require.Contains(t, cpp, "size_t Config::buf_size_write() const {\n\tsize_t size = 1;\n\treturn size;\n}")
+	// id(4) + flags(1) + data(8) are read, plus size(1) for write
+	require.Contains(t, cpp, "size_t Main::buf_size_read() const {\n\tsize_t size = 13;")
+	require.Contains(t, cpp, "size_t Main::buf_size_write() const {\n\tsize_t size = 14;")
+	// nested register refs delegate to the referenced register
+	require.Contains(t, cpp, "\tsize += this->config.buf_size_read();")
+	require.Contains(t, cpp, "\tsize += this->config.buf_size_write();")
+	require.Contains(t, cpp, "\tsize += 4 * (size_t)this->size;")
+	require.Contains(t, cpp, "\tsize += 2 * (size_t)((this->flags&flags_count_bm)>>1);")
+}
+
+func TestGenerateCppCheck(t *testing.T) {
+	input := `
+    device test
+
+    register Main(1) {
+        flags uint8{ready: 0, count: 1-3};
+        size uint8;
+        items [size]uint32;
+        bits [flags_count]int16;
+    };`
+
+	device, err := parser.Parse(input)
+	require.NoError(t, err)
+
+	hpp, cpp, err := GenerateHppCpp(device, "test", "test.h")
+	require.NoError(t, err)
+	require.Contains(t, hpp, "int check() const;")
+	require.Contains(t, cpp, "int Main::check() const {")
+	require.Contains(t, cpp, "if (this->items == nullptr && this->size != 0) return -2;")
+	// the bit mask sized array uses the same expression as the serializer
+	require.Contains(t, cpp, "uint8_t elems = (this->flags&flags_count_bm)>>1;")
+	require.Contains(t, cpp, "if (this->bits == nullptr && ((this->flags&flags_count_bm)>>1) != 0) return -2;")
+	require.Contains(t, cpp, "int Main::serialize_read(uint8_t* buf, size_t size) const {\n\t{int res = this->check(); if (res < 0) return res;}")
+	require.Contains(t, cpp, "int Main::serialize_write(uint8_t* buf, size_t size) const{\n\t{int res = this->check(); if (res < 0) return res;}")
+}
+
+func TestGenerateCppDecoder(t *testing.T) {
+	input := `
+    device test
+
+    register Control(1) {
+        mode uint8;
+    };
+
+    register Status(2):r {
+        value uint16;
+    };`
+
+	device, err := parser.Parse(input)
+	require.NoError(t, err)
+
+	hpp, _, err := GenerateHppCpp(device, "test", "test.h")
+	require.NoError(t, err)
+	require.NotContains(t, hpp, "decode_register")
+
+	hpp, _, err = GenerateHppCppWithOptions(device, "test", "test.h", CppOptions{Decoder: true})
+	require.NoError(t, err)
+	require.Contains(t, hpp, "int decode_register(uint8_t id, const uint8_t* buf, size_t size, Handler&& handler) {")
+	require.Contains(t, hpp, "\tcase Reg_Control_ID: {\n\t\tControl reg{};\n\t\tint res = reg.deserialize_write(buf, size);")
+	require.Contains(t, hpp, "\tcase Reg_Status_ID: {\n\t\tStatus reg{};\n\t\tint res = reg.deserialize_read(buf, size);")
+	// unknown ids are reported with -3
+	require.Contains(t, hpp, "\tdefault:\n\t\treturn -3;")
+}
+
+func TestGenerateCppEnum(t *testing.T) {
+	input := `
+    device test
+
+    // Operation mode
+    enum Mode uint8 {
+        OFF = 0,
+        ON = 1,
+    };
+
+    register Control(1) {
+        mode Mode;
+    };`
+
+	device, err := parser.Parse(input)
+	require.NoError(t, err)
+
+	hpp, cpp, err := GenerateHppCpp(device, "test", "test.h")
+	require.NoError(t, err)
+	require.Contains(t, hpp, "// Operation mode\nenum class Mode : uint8_t {\n    OFF = 0,\n    ON = 1,\n};")
+	require.Contains(t, hpp, "    Mode mode;")
+	require.Contains(t, cpp, "offset += bigendian::encode(buf + offset, static_cast<uint8_t>(this->mode));")
+	require.Contains(t, cpp, "{uint8_t v; offset += bigendian::decode(v, buf + offset); this->mode = static_cast<Mode>(v);}")
+	require.Contains(t, cpp, "size_t Control::buf_size_read() const {\n\tsize_t size = 1;")
+}
+
+func TestGenerateCppDeviceConstants(t *testing.T) {
+	input := `
+    device test
+
+    // protocol version
+    const Version = uint8(2);
+    const MaxPayload = uint16(0x100);
+
+    register Control(1) {
+        const Limit = uint8(10);
+        mode uint8;
+    };`
+
+	device, err := parser.Parse(input)
+	require.NoError(t, err)
+
+	hpp, _, err := GenerateHppCpp(device, "test", "test.h")
+	require.NoError(t, err)
+	require.Contains(t, hpp, "// protocol version\nstatic constexpr uint8_t Version = 2;\nstatic constexpr uint16_t MaxPayload = 0x100;\n")
+	require.Contains(t, hpp, "struct Control {\n    static constexpr uint8_t Limit = 10;")
+
+	hpp, _, err = GenerateHppCppWithOptions(device, "test", "test.h", CppOptions{Plain: true})
+	require.NoError(t, err)
+	require.Contains(t, hpp, "static constexpr std::uint16_t MaxPayload = 0x100;")
+}
+
+func TestGenerateCppConstantDocs(t *testing.T) {
+	input := `
+    device test
+
+    // protocol version
+    const Version = uint8(2); // major only
+
+    register Control(1) {
+        // the lower limit
+        const Low = uint8(1);
+        level uint8;
+        /* the upper
+           limit */
+        const High = uint8(10); // inclusive
+        const Plain = uint8(5);
+    };`
+
+	device, err := parser.Parse(input)
+	require.NoError(t, err)
+
+	hpp, _, err := GenerateHppCpp(device, "test", "test.h")
+	require.NoError(t, err)
+	require.Contains(t, hpp, "// protocol version\n// major only\nstatic constexpr uint8_t Version = 2;\n")
+	require.Contains(t, hpp, `    // the lower limit
+    static constexpr uint8_t Low = 1;
+    uint8_t level;
+    // the upper
+    // limit
+    // inclusive
+    static constexpr uint8_t High = 10;
+    static constexpr uint8_t Plain = 5;
+`)
+}
+
+func TestGenerateCppInterleavedGolden(t *testing.T) {
+	input, err := os.ReadFile("testdata/interleaved.pa")
+	require.NoError(t, err)
+	device, err := parser.Parse(string(input))
+	require.NoError(t, err)
+
+	// the constants keep their places between the fields with the empty lines separating the groups
+	hpp, _, err := GenerateHppCpp(device, "interleaved", "interleaved.hpp")
+	require.NoError(t, err)
+	golden, err := os.ReadFile("testdata/interleaved.hpp")
+	require.NoError(t, err)
+	require.Equal(t, string(golden), hpp)
+}
+
+func TestGenerateCppBitMemberAccessors(t *testing.T) {
+	input := `
+    device test
+
+    register Control(1) {
+        flags uint8{ready: 0, mode: 4-6};
+    };`
+
+	device, err := parser.Parse(input)
+	require.NoError(t, err)
+
+	hpp, _, err := GenerateHppCpp(device, "test", "test.h")
+	require.NoError(t, err)
+	require.Contains(t, hpp, "bool get_flags_ready() const { return (this->flags & flags_ready_bm) != 0; }")
+	require.Contains(t, hpp, "void set_flags_ready(bool v) { if (v) this->flags |= flags_ready_bm; else this->flags &= static_cast<uint8_t>(~flags_ready_bm); }")
+	require.Contains(t, hpp, "uint8_t get_flags_mode() const { return static_cast<uint8_t>((this->flags & flags_mode_bm) >> 4); }")
+	require.Contains(t, hpp, "void set_flags_mode(uint8_t v) { this->flags = static_cast<uint8_t>((this->flags & ~flags_mode_bm) | ((v << 4) & flags_mode_bm)); }")
+}
+
+func TestGenerateCppString(t *testing.T) {
+	input := `
+    device test
+
+    register Info(1) {
+        name string;
+        version:r string(uint16, 16);
+    };`
+
+	device, err := parser.Parse(input)
+	require.NoError(t, err)
+
+	hpp, cpp, err := GenerateHppCpp(device, "test", "test.h")
+	require.NoError(t, err)
+	require.Contains(t, hpp, "    uint8_t name_len;\n    char name[255];")
+	require.Contains(t, hpp, "    uint16_t version_len;\n    char version[16];")
+	require.Contains(t, cpp, "memcpy(buf + offset, this->name, this->name_len); offset += this->name_len;")
+	require.Contains(t, cpp, "size_t Info::buf_size_read() const {\n\tsize_t size = 3;\n\tsize += (size_t)this->name_len;\n\tsize += (size_t)this->version_len;")
+	require.Contains(t, cpp, "int Info::check() const {\n\tif (this->version_len > 16) return -2;\n\treturn 0;")
+	require.Contains(t, cpp, "if (this->version_len > 16) return -2;\n\tif ((size_t)offset + this->version_len > size) return -1;")
+}
+
+func TestGenerateCppReserved(t *testing.T) {
+	input := `
+    device test
+
+    register Frame(1) {
+        reserved [3]uint8;
+        value uint16;
+        reserved:r uint16;
+    };`
+
+	device, err := parser.Parse(input)
+	require.NoError(t, err)
+
+	hpp, cpp, err := GenerateHppCpp(device, "test", "test.h")
+	require.NoError(t, err)
+	require.Contains(t, hpp, "    // reserved 3 byte(s)\n    uint16_t value;\n    // reserved 2 byte(s)\n")
+	require.Contains(t, cpp, "size_t Frame::buf_size_read() const {\n\tsize_t size = 7;")
+	require.Contains(t, cpp, "size_t Frame::buf_size_write() const {\n\tsize_t size = 5;")
+	require.Contains(t, cpp, "if ((size_t)offset + 3 > size) return -1;\n\tmemset(buf + offset, 0, 3); offset += 3;")
+	require.Contains(t, cpp, "if ((size_t)offset + 2 > size) return -1;\n\toffset += 2;")
+}
+
+func TestGenerateCppPlain(t *testing.T) {
+	input := `
+    device test
+
+    register Control(1) {
+        flags uint8{ready: 0, count: 1-3};
+        items [flags_count]uint16;
+        name string(uint8, 8);
+    };`
+
+	device, err := parser.Parse(input)
+	require.NoError(t, err)
+
+	hpp, cpp, err := GenerateHppCpp(device, "test", "test.h")
+	require.NoError(t, err)
+	require.Contains(t, hpp, "#include <Arduino.h>")
+	require.NotContains(t, hpp+cpp, "std::")
+
+	hpp, cpp, err = GenerateHppCppWithOptions(device, "test", "test.h", CppOptions{Plain: true})
+	require.NoError(t, err)
+	require.NotContains(t, hpp, "Arduino.h")
+	require.Contains(t, hpp, "#pragma once\n\n#include <cstddef>\n#include <cstdint>\n#include <cstring>\n")
+	require.Contains(t, hpp, "static constexpr std::uint8_t Reg_Control_ID = 1;")
+	require.Contains(t, hpp, "static constexpr std::uint8_t flags_count_bm = 0xE;")
+	require.Contains(t, hpp, "std::uint16_t* items;")
+	require.Contains(t, hpp, "int serialize_read(std::uint8_t* buf, std::size_t size) const;")
+	require.Contains(t, cpp, "std::size_t Control::buf_size_read() const {\n\tstd::size_t size = 2;")
+	require.Contains(t, cpp, "\tsize += 2 * (std::size_t)((this->flags&flags_count_bm)>>1);")
+	require.Contains(t, cpp, "\tsize += (std::size_t)this->name_len;")
+	require.Contains(t, cpp, "    std::uint8_t elems = (this->flags&flags_count_bm)>>1;")
+}
+
+func TestGenerateCppReservedBitMember(t *testing.T) {
+	input := `
+    device test
+
+    register Control(1) {
+        flags uint8{ready: 0, reserved: 1-7};
+    };`
+
+	device, err := parser.Parse(input)
+	require.NoError(t, err)
+
+	hpp, _, err := GenerateHppCpp(device, "test", "test.h")
+	require.NoError(t, err)
+	require.Contains(t, hpp, "static constexpr uint8_t flags_ready_bm = 0x1;")
+	require.NotContains(t, hpp, "flags__bm")
+	require.NotContains(t, hpp, "get_flags_()")
+}
+
+func TestGenerateCppSizeAsserts(t *testing.T) {
+	input := `
+    device test
+
+    enum Mode uint8 { OFF = 0, ON = 1 };
+
+    register Control(1) {
+        mode Mode;
+        flags uint16{ready: 0};
+        count uint8;
+        data [count]float64;
+        items [3]int16;
+        words [0x4]uint16;
+        name string(uint8, 8);
+        temp fixed(int16, 4);
+        reserved [2]uint8;
+    };`
+
+	device, err := parser.Parse(input)
+	require.NoError(t, err)
+
+	hpp, _, err := GenerateHppCpp(device, "test", "test.h")
+	require.NoError(t, err)
+	require.Contains(t, hpp, `	int check() const;
+};
+
+// the serializer relies on the members taking their wire size, e.g. double is 4 bytes on AVR
+static_assert(sizeof(Control::mode) == 1, "Control::mode must take 1 byte(s)");
+static_assert(sizeof(Control::flags) == 2, "Control::flags must take 2 byte(s)");
+static_assert(sizeof(Control::count) == 1, "Control::count must take 1 byte(s)");
+static_assert(sizeof(double) == 8, "double must take 8 byte(s)");
+static_assert(sizeof(Control::items) == 6, "Control::items must take 6 byte(s)");
+static_assert(sizeof(Control::words) == 8, "Control::words must take 8 byte(s)");
+static_assert(sizeof(Control::name_len) == 1, "Control::name_len must take 1 byte(s)");
+static_assert(sizeof(Control::temp) == 2, "Control::temp must take 2 byte(s)");
+} // namespace test`)
+}
+
+func TestGenerateCppDeviceEndianness(t *testing.T) {
+	device, err := parser.Parse(`
+    device test @le
+
+    register Control(1) {
+        a uint16;
+        b uint16 @be;
+    };`)
+	require.NoError(t, err)
+
+	_, cpp, err := GenerateHppCpp(device, "test", "test.h")
+	require.NoError(t, err)
+	require.Contains(t, cpp, `#include "littleendian.h"`)
+	require.Contains(t, cpp, "littleendian::encode(buf + offset, this->a)")
+	require.Contains(t, cpp, "bigendian::encode(buf + offset, this->b)")
+}
+
+func TestGenerateCppNestedNamespace(t *testing.T) {
+	device, err := parser.Parse(`
+    device test
+
+    register Control(1) {
+        mode uint8;
+    };`)
+	require.NoError(t, err)
+
+	hpp, cpp, err := GenerateHppCppWithOptions(device, "company::sensors", "test.h", CppOptions{Plain: true})
+	require.NoError(t, err)
+	require.Contains(t, hpp, "namespace company {\nnamespace sensors {\n")
+	require.True(t, strings.HasSuffix(hpp, "} // namespace sensors\n} // namespace company\n"))
+	require.Contains(t, cpp, "namespace company {\nnamespace sensors {\n")
+	require.True(t, strings.HasSuffix(cpp, "} // namespace sensors\n} // namespace company"))
+
+	dotted, _, err := GenerateHppCppWithOptions(device, "company.sensors", "test.h", CppOptions{Plain: true})
+	require.NoError(t, err)
+	require.Equal(t, hpp, dotted)
+
+	runGeneratedCppTest(t, hpp, cpp, `#include "test.h"
+
+int main() {
+	company::sensors::Control c{};
+	c.mode = 7;
+	std::uint8_t buf[1];
+	return c.serialize_write(buf, sizeof(buf)) == 1 && buf[0] == 7 ? 0 : 1;
+}
+`)
+
+	for _, ns := range []string{"", "company::", "::sensors", "company..sensors", "1st", "my-company", "company::class"} {
+		_, _, err := GenerateHppCpp(device, ns, "test.h")
+		require.Error(t, err, ns)
+		require.Contains(t, err.Error(), "invalid C++ namespace")
+	}
+}
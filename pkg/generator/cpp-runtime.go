@@ -0,0 +1,214 @@
+package generator
+
+import (
+	"bytes"
+	"text/template"
+)
+
+//
+// C++ runtime headers template
+//
+
+const cppCodecTemplate = `// This is auto-generated file. DO NOT EDIT. Use pargus compiler to regenerate it.
+
+#pragma once
+
+#include <stddef.h>
+#include <stdint.h>
+#include <string.h>
+
+namespace {{.Namespace}} {
+
+// unsigned integer type of the same size as the encoded value
+template <size_t N> struct uint_of;
+template <> struct uint_of<1> { typedef uint8_t type; };
+template <> struct uint_of<2> { typedef uint16_t type; };
+template <> struct uint_of<4> { typedef uint32_t type; };
+template <> struct uint_of<8> { typedef uint64_t type; };
+
+// Encodes the integer or floating point value into buf in {{.Order}} byte order, returns the number of bytes written
+template <typename T>
+inline size_t encode(uint8_t* buf, T v) {
+	typename uint_of<sizeof(T)>::type u;
+	memcpy(&u, &v, sizeof(T));
+	for (size_t i = 0; i < sizeof(T); i++) {
+		buf[i] = (uint8_t)(u >> (8 * {{if .LittleEndian}}i{{else}}(sizeof(T) - 1 - i){{end}}));
+	}
+	return sizeof(T);
+}
+
+// Decodes the integer or floating point value from buf in {{.Order}} byte order, returns the number of bytes read
+template <typename T>
+inline size_t decode(T& v, const uint8_t* buf) {
+	typename uint_of<sizeof(T)>::type u = 0;
+	for (size_t i = 0; i < sizeof(T); i++) {
+		u = (typename uint_of<sizeof(T)>::type)((u << 8) | buf[{{if .LittleEndian}}sizeof(T) - 1 - i{{else}}i{{end}}]);
+	}
+	memcpy(&v, &u, sizeof(T));
+	return sizeof(T);
+}
+
+// Encodes n elements of the array into buf, returns the number of bytes written
+template <typename T>
+inline size_t encode_varray(uint8_t* buf, const T* arr, size_t n) {
+	size_t offset = 0;
+	for (size_t i = 0; i < n; i++) {
+		offset += encode(buf + offset, arr[i]);
+	}
+	return offset;
+}
+
+// Decodes n elements of the array from buf, returns the number of bytes read
+template <typename T>
+inline size_t decode_varray(T* arr, const uint8_t* buf, size_t n) {
+	size_t offset = 0;
+	for (size_t i = 0; i < n; i++) {
+		offset += decode(arr[i], buf + offset);
+	}
+	return offset;
+}
+
+// Encodes the low 3 bytes of the 24-bit integer into buf in {{.Order}} byte order, returns the number of bytes written
+template <typename T>
+inline size_t encode24(uint8_t* buf, T v) {
+	uint32_t u = (uint32_t)v;
+	for (size_t i = 0; i < 3; i++) {
+		buf[i] = (uint8_t)(u >> (8 * {{if .LittleEndian}}i{{else}}(2 - i){{end}}));
+	}
+	return 3;
+}
+
+// Decodes the 24-bit integer from buf in {{.Order}} byte order, the signed value is sign-extended,
+// returns the number of bytes read
+template <typename T>
+inline size_t decode24(T& v, const uint8_t* buf) {
+	uint32_t u = 0;
+	for (size_t i = 0; i < 3; i++) {
+		u = (u << 8) | buf[{{if .LittleEndian}}2 - i{{else}}i{{end}}];
+	}
+	if (T(-1) < T(0) && (u & 0x800000)) {
+		u |= 0xFF000000;
+	}
+	v = (T)u;
+	return 3;
+}
+
+// Encodes n 24-bit integers of the array into buf, returns the number of bytes written
+template <typename T>
+inline size_t encode24_varray(uint8_t* buf, const T* arr, size_t n) {
+	for (size_t i = 0; i < n; i++) {
+		encode24(buf + 3 * i, arr[i]);
+	}
+	return 3 * n;
+}
+
+// Decodes n 24-bit integers of the array from buf, returns the number of bytes read
+template <typename T>
+inline size_t decode24_varray(T* arr, const uint8_t* buf, size_t n) {
+	for (size_t i = 0; i < n; i++) {
+		decode24(arr[i], buf + 3 * i);
+	}
+	return 3 * n;
+}
+
+// Returns the IEEE 754 half-precision bits of the value rounded to the nearest even, the values
+// too large for the half precision become infinities and the too small ones zeros
+inline uint16_t float16_bits(float v) {
+	uint32_t bits;
+	memcpy(&bits, &v, sizeof(bits));
+	uint16_t sign = (uint16_t)((bits >> 16) & 0x8000);
+	int32_t exp = (int32_t)((bits >> 23) & 0xff) - 127 + 15;
+	uint32_t mant = bits & 0x7fffff;
+	if ((bits & 0x7fffffff) > 0x7f800000) {
+		return sign | 0x7e00;
+	}
+	if (exp >= 0x1f) {
+		return sign | 0x7c00;
+	}
+	uint32_t shift = 13;
+	if (exp <= 0) {
+		if (exp < -10) {
+			return sign;
+		}
+		// the subnormal keeps the implicit leading bit in the mantissa
+		mant |= 0x800000;
+		shift = (uint32_t)(14 - exp);
+		exp = 0;
+	}
+	// the rounding carry may overflow the mantissa to the exponent, which is still correct
+	uint32_t h = ((uint32_t)exp << 10) + (mant >> shift);
+	uint32_t rem = mant & (((uint32_t)1 << shift) - 1);
+	uint32_t half = (uint32_t)1 << (shift - 1);
+	if (rem > half || (rem == half && (h & 1))) {
+		h++;
+	}
+	return (uint16_t)(sign | h);
+}
+
+// Returns the value of the IEEE 754 half-precision bits
+inline float float16_from_bits(uint16_t h) {
+	uint32_t sign = (uint32_t)(h & 0x8000) << 16;
+	uint32_t exp = (h >> 10) & 0x1f;
+	uint32_t mant = h & 0x3ff;
+	uint32_t bits;
+	if (exp == 0) {
+		// the subnormals are mant * 2^-24, they are normal numbers in float
+		float f = (float)mant / 16777216.0f;
+		memcpy(&bits, &f, sizeof(bits));
+		bits |= sign;
+	} else if (exp == 0x1f) {
+		bits = sign | 0x7f800000 | (mant << 13);
+	} else {
+		bits = sign | ((exp + 127 - 15) << 23) | (mant << 13);
+	}
+	float v;
+	memcpy(&v, &bits, sizeof(v));
+	return v;
+}
+
+// Encodes the fixed-size array into buf, returns the number of bytes written
+template <typename T, size_t N>
+inline size_t encode(uint8_t* buf, const T (&arr)[N]) {
+	return encode_varray(buf, arr, N);
+}
+
+// Decodes the fixed-size array from buf, returns the number of bytes read
+template <typename T, size_t N>
+inline size_t decode(T (&arr)[N], const uint8_t* buf) {
+	return decode_varray(arr, buf, N);
+}
+
+} // namespace {{.Namespace}}
+`
+
+var cppCodecTpl = template.Must(template.New("codec").Parse(cppCodecTemplate))
+
+type cppCodec struct {
+	Namespace    string
+	Order        string
+	LittleEndian bool
+}
+
+// GenerateBigEndianHeader generates the bigendian.h runtime header, which provides the
+// bigendian::encode, bigendian::decode, bigendian::encode_varray and bigendian::decode_varray
+// functions the generated C++ code calls, plus their encode24 and decode24 forms for the 24-bit
+// integers and the float16_bits and float16_from_bits conversions of the half-precision numbers.
+// The header is the same for Arduino and plain C++
+func GenerateBigEndianHeader() string {
+	return generateCodecHeader(cppCodec{Namespace: "bigendian", Order: "big-endian"})
+}
+
+// GenerateLittleEndianHeader generates the littleendian.h runtime header, which is included by
+// the generated C++ code if some fields are encoded in little-endian byte order
+func GenerateLittleEndianHeader() string {
+	return generateCodecHeader(cppCodec{Namespace: "littleendian", Order: "little-endian", LittleEndian: true})
+}
+
+func generateCodecHeader(c cppCodec) string {
+	var buf bytes.Buffer
+	if err := cppCodecTpl.Execute(&buf, c); err != nil {
+		// the template is constant, so this is a programming error
+		panic(err)
+	}
+	return buf.String()
+}
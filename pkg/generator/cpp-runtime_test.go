@@ -0,0 +1,1794 @@
+package generator
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+
+	"github.com/dspasibenko/pargus/pkg/parser"
+	"github.com/stretchr/testify/require"
+)
+
+// runGeneratedCppTest puts the generated plain C++ code, the runtime headers and the test program
+// into a temporary directory, then compiles and runs the program there
+func runGeneratedCppTest(t *testing.T, hpp, cpp, mainCode string) {
+	t.Helper()
+	cxx, err := exec.LookPath("g++")
+	if err != nil {
+		t.Skip("g++ is not available")
+	}
+
+	dir := t.TempDir()
+	files := map[string]string{
+		"test.h":         hpp,
+		"test.cpp":       cpp,
+		"main.cpp":       mainCode,
+		"bigendian.h":    GenerateBigEndianHeader(),
+		"littleendian.h": GenerateLittleEndianHeader(),
+	}
+	for name, content := range files {
+		require.NoError(t, os.WriteFile(filepath.Join(dir, name), []byte(content), 0644))
+	}
+
+	cmd := exec.Command(cxx, "-std=c++14", "-Wall", "-Werror=return-type", "-Werror=sign-compare", "-o", "test", "main.cpp", "test.cpp")
+	cmd.Dir = dir
+	out, err := cmd.CombinedOutput()
+	require.NoError(t, err, "generated code compilation failed:\n%s\n%s\n%s", out, hpp, cpp)
+
+	cmd = exec.Command(filepath.Join(dir, "test"))
+	out, err = cmd.CombinedOutput()
+	require.NoError(t, err, "generated code test failed:\n%s", out)
+}
+
+func TestGenerateBigEndianHeader(t *testing.T) {
+	h := GenerateBigEndianHeader()
+	require.Contains(t, h, "namespace bigendian {")
+	require.Contains(t, h, "inline size_t encode(uint8_t* buf, T v) {")
+	require.Contains(t, h, "inline size_t decode(T& v, const uint8_t* buf) {")
+	require.Contains(t, h, "inline size_t encode_varray(uint8_t* buf, const T* arr, size_t n) {")
+	require.Contains(t, h, "inline size_t decode_varray(T* arr, const uint8_t* buf, size_t n) {")
+	require.Contains(t, h, "inline size_t encode(uint8_t* buf, const T (&arr)[N]) {")
+	require.Contains(t, h, "inline size_t decode(T (&arr)[N], const uint8_t* buf) {")
+	require.NotContains(t, h, "Arduino.h")
+
+	require.Contains(t, GenerateLittleEndianHeader(), "namespace littleendian {")
+}
+
+func TestGeneratedCppRoundTrip(t *testing.T) {
+	input := `
+    device test
+
+    register Frame(1) {
+        header uint16;
+        payload uint32 @le;
+        value float32;
+        ratio float64 @le;
+        flags uint8{ready: 0, count: 1-3};
+        fixed [2]int16;
+        items [flags_count]int32 @le;
+    };`
+
+	device, err := parser.Parse(input)
+	require.NoError(t, err)
+
+	hpp, cpp, err := GenerateHppCppWithOptions(device, "test", "test.h", CppOptions{Plain: true})
+	require.NoError(t, err)
+
+	runGeneratedCppTest(t, hpp, cpp, `#include "test.h"
+#include <cstdio>
+#include <cstring>
+
+int main() {
+	std::int32_t items[2] = {-2, 0x01020304};
+	test::Frame src{};
+	src.header = 0x0102;
+	src.payload = 0x03040506;
+	src.value = 1.0f;
+	src.ratio = -1.5;
+	src.set_flags_ready(true);
+	src.set_flags_count(2);
+	src.fixed[0] = -1;
+	src.fixed[1] = 0x0708;
+	src.items = items;
+
+	std::uint8_t buf[64];
+	int n = src.serialize_write(buf, sizeof(buf));
+	if (n != (int)src.buf_size_write() || n != 2 + 4 + 4 + 8 + 1 + 4 + 8) {
+		std::printf("unexpected size %d\n", n);
+		return 1;
+	}
+	const std::uint8_t head[] = {0x01, 0x02, 0x06, 0x05, 0x04, 0x03, 0x3f, 0x80, 0x00, 0x00};
+	if (std::memcmp(buf, head, sizeof(head)) != 0) {
+		std::printf("unexpected wire bytes\n");
+		return 1;
+	}
+	if (buf[23] != 0xfe || buf[24] != 0xff || buf[27] != 0x04) {
+		std::printf("items must be little-endian\n");
+		return 1;
+	}
+
+	std::int32_t decoded[2];
+	test::Frame dst{};
+	dst.items = decoded;
+	if (dst.deserialize_write(buf, n) != n || dst.deserialize_write(buf, n - 1) != -1) {
+		std::printf("deserialization failed\n");
+		return 1;
+	}
+	if (dst.header != src.header || dst.payload != src.payload || dst.value != src.value ||
+		dst.ratio != src.ratio || dst.flags != src.flags || dst.fixed[1] != 0x0708 ||
+		decoded[0] != -2 || decoded[1] != 0x01020304) {
+		std::printf("decoded register mismatch\n");
+		return 1;
+	}
+	return 0;
+}
+`)
+}
+
+func TestGeneratedCppRegisterRefArray(t *testing.T) {
+	input := `
+    device test
+
+    register Channel(1) {
+        id uint8;
+        value:r int16;
+    };
+
+    register Main(2) {
+        channels [2]Channel;
+        flags uint8{n: 0-3};
+        more [flags_n]Channel;
+        count uint8;
+        extra [count]Channel;
+    };`
+
+	device, err := parser.Parse(input)
+	require.NoError(t, err)
+
+	for _, opts := range []CppOptions{{}, {Plain: true}} {
+		hpp, cpp, err := GenerateHppCppWithOptions(device, "test", "test.h", opts)
+		require.NoError(t, err)
+		require.Contains(t, hpp, "Channel channels[2];")
+		require.Contains(t, hpp, "Channel* more;")
+		require.Contains(t, cpp, "if (this->more == nullptr && ((this->flags&flags_n_bm)>>0) != 0) return -2;")
+		if !opts.Plain {
+			continue
+		}
+
+		runGeneratedCppTest(t, hpp, cpp, `#include "test.h"
+#include <cstdio>
+#include <cstring>
+
+int main() {
+	test::Channel more[1];
+	more[0].id = 3;
+	more[0].value = -2;
+	test::Main src{};
+	src.channels[0].id = 1;
+	src.channels[0].value = 0x0102;
+	src.channels[1].id = 2;
+	src.set_flags_n(1);
+	src.more = more;
+
+	std::uint8_t buf[64];
+	int n = src.serialize_read(buf, sizeof(buf));
+	const std::uint8_t expected[] = {1, 0x01, 0x02, 2, 0, 0, 0x01, 3, 0xff, 0xfe, 0};
+	if (n != (int)src.buf_size_read() || n != sizeof(expected) || std::memcmp(buf, expected, n) != 0) {
+		std::printf("unexpected read data, size %d\n", n);
+		return 1;
+	}
+	if (src.serialize_read(buf, n - 1) != -1) {
+		std::printf("the short buffer is not detected\n");
+		return 1;
+	}
+	n = src.serialize_write(buf, sizeof(buf));
+	if (n != (int)src.buf_size_write() || n != 5 || buf[2] != 0x01 || buf[3] != 3) {
+		std::printf("unexpected write data, size %d\n", n);
+		return 1;
+	}
+
+	test::Channel decoded[1];
+	test::Main dst{};
+	dst.more = decoded;
+	if (dst.deserialize_write(buf, n) != n || dst.channels[1].id != 2 || decoded[0].id != 3) {
+		std::printf("deserialization failed\n");
+		return 1;
+	}
+
+	src.count = 1;
+	if (src.serialize_write(buf, sizeof(buf)) != -2 || src.buf_size_write() != 5) {
+		std::printf("the missing array is not detected\n");
+		return 1;
+	}
+	return 0;
+}
+`)
+	}
+}
+
+func TestGeneratedCppFixed(t *testing.T) {
+	input := `
+    device test
+
+    register Sensor(1) {
+        temp fixed(int16, 4) @le;
+        ratio fixed(uint8, 8);
+    };`
+
+	device, err := parser.Parse(input)
+	require.NoError(t, err)
+
+	hpp, cpp, err := GenerateHppCppWithOptions(device, "test", "test.h", CppOptions{Plain: true})
+	require.NoError(t, err)
+	require.Contains(t, hpp, "std::int16_t temp;")
+	require.Contains(t, hpp, "double get_temp() const { return static_cast<double>(this->temp) / 16; }")
+
+	runGeneratedCppTest(t, hpp, cpp, `#include "test.h"
+#include <cstdio>
+#include <cstring>
+
+int main() {
+	test::Sensor src{};
+	src.set_temp(-21.5625);
+	src.set_ratio(0.25);
+	if (src.temp != -345 || src.ratio != 64) {
+		std::printf("unexpected raw values %d %d\n", src.temp, src.ratio);
+		return 1;
+	}
+
+	std::uint8_t buf[8];
+	int n = src.serialize_read(buf, sizeof(buf));
+	const std::uint8_t expected[] = {0xa7, 0xfe, 64};
+	if (n != sizeof(expected) || std::memcmp(buf, expected, n) != 0) {
+		std::printf("unexpected data, size %d\n", n);
+		return 1;
+	}
+
+	test::Sensor dst{};
+	if (dst.deserialize_read(buf, n) != n || dst.get_temp() != -21.5625 || dst.get_ratio() != 0.25) {
+		std::printf("unexpected values %f %f\n", dst.get_temp(), dst.get_ratio());
+		return 1;
+	}
+
+	dst.set_temp(1.03);
+	if (dst.temp != 16) {
+		std::printf("unexpected rounding %d\n", dst.temp);
+		return 1;
+	}
+	return 0;
+}
+`)
+}
+
+func TestGeneratedCppMagic(t *testing.T) {
+	input := `
+    device test
+
+    register Frame(1) {
+        sync = 0xAA55 uint16;
+        value uint8;
+        tag = 0xFFFFFE int24 @le;
+    };`
+
+	device, err := parser.Parse(input)
+	require.NoError(t, err)
+
+	hpp, cpp, err := GenerateHppCppWithOptions(device, "test", "test.h", CppOptions{Plain: true})
+	require.NoError(t, err)
+	require.Contains(t, hpp, "// sync: magic 0xAA55")
+	require.Contains(t, cpp, "offset += bigendian::encode(buf + offset, static_cast<std::uint16_t>(0xAA55));")
+	require.Contains(t, cpp, "static_cast<std::int32_t>(-2)")
+
+	runGeneratedCppTest(t, hpp, cpp, `#include "test.h"
+#include <cstdio>
+#include <cstring>
+
+int main() {
+	test::Frame src{};
+	src.value = 7;
+	std::uint8_t buf[8];
+	int n = src.serialize_write(buf, sizeof(buf));
+	if (n != 6 || std::memcmp(buf, "\xaa\x55\x07\xfe\xff\xff", 6) != 0) {
+		std::printf("unexpected data, size %d\n", n);
+		return 1;
+	}
+	test::Frame dst{};
+	if (dst.deserialize_write(buf, n) != n || dst.value != 7) {
+		std::printf("deserialization failed\n");
+		return 1;
+	}
+	buf[1] = 0x56;
+	if (dst.deserialize_write(buf, n) != -5) {
+		std::printf("the wrong magic is not detected\n");
+		return 1;
+	}
+	buf[1] = 0x55;
+	buf[5] = 0x7f;
+	if (dst.deserialize_write(buf, n) != -5) {
+		std::printf("the wrong 24-bit magic is not detected\n");
+		return 1;
+	}
+	return 0;
+}
+`)
+}
+
+func TestGeneratedCppCRC(t *testing.T) {
+	input := `
+    device test
+
+    register Modbus(1) {
+        data [9]uint8;
+        crc crc16(modbus) @le;
+    };
+
+    register Ccitt(2) {
+        data [9]uint8;
+        crc crc16;
+    };
+
+    register Ieee(3) {
+        data [9]uint8;
+        crc:r crc32;
+    };`
+
+	device, err := parser.Parse(input)
+	require.NoError(t, err)
+
+	hpp, cpp, err := GenerateHppCppWithOptions(device, "test", "test.h", CppOptions{Plain: true})
+	require.NoError(t, err)
+	require.Contains(t, hpp, "// crc: crc16 checksum of the preceding bytes")
+	require.Contains(t, cpp, "offset += littleendian::encode(buf + offset, crc16_modbus(buf, (std::size_t)offset));")
+
+	runGeneratedCppTest(t, hpp, cpp, `#include "test.h"
+#include <cstdio>
+#include <cstring>
+
+template <typename R>
+int check(const std::uint8_t* crc, std::size_t crc_size) {
+	R src{};
+	std::memcpy(src.data, "123456789", 9);
+	std::uint8_t buf[16];
+	int n = src.serialize_read(buf, sizeof(buf));
+	if (n != (int)(9 + crc_size) || std::memcmp(buf + 9, crc, crc_size) != 0) {
+		std::printf("unexpected data, size %d\n", n);
+		return 1;
+	}
+	R dst{};
+	if (dst.deserialize_read(buf, n) != n || std::memcmp(dst.data, src.data, 9) != 0) {
+		std::printf("deserialization failed\n");
+		return 1;
+	}
+	buf[0]++;
+	if (dst.deserialize_read(buf, n) != -4) {
+		std::printf("the corrupted buffer is not detected\n");
+		return 1;
+	}
+	return 0;
+}
+
+int main() {
+	const std::uint8_t modbus[] = {0x37, 0x4b};
+	const std::uint8_t ccitt[] = {0x29, 0xb1};
+	const std::uint8_t ieee[] = {0xcb, 0xf4, 0x39, 0x26};
+	if (check<test::Modbus>(modbus, 2) || check<test::Ccitt>(ccitt, 2) || check<test::Ieee>(ieee, 4)) {
+		return 1;
+	}
+	test::Ieee r{};
+	if (r.buf_size_write() != 9) {
+		std::printf("the checksum is only in the read fields\n");
+		return 1;
+	}
+	return 0;
+}
+`)
+
+	// the Arduino code uses the same checksum helpers
+	_, cpp, err = GenerateHppCpp(device, "test", "test.h")
+	require.NoError(t, err)
+	require.Contains(t, cpp, "static uint32_t crc32_ieee(const uint8_t* data, size_t size) {")
+}
+
+func TestGeneratedCppSignedBitMember(t *testing.T) {
+	input := `
+    device test
+
+    register Control(1) {
+        flags uint8{ready: 0, offset: signed 4-7};
+        wide uint16{value: signed 2-9};
+    };`
+
+	device, err := parser.Parse(input)
+	require.NoError(t, err)
+
+	hpp, cpp, err := GenerateHppCppWithOptions(device, "test", "test.h", CppOptions{Plain: true})
+	require.NoError(t, err)
+	require.Contains(t, hpp, "std::int8_t get_flags_offset() const {")
+
+	runGeneratedCppTest(t, hpp, cpp, `#include "test.h"
+#include <cstdio>
+
+int main() {
+	test::Control r{};
+	r.flags = 0xf1;
+	if (r.get_flags_offset() != -1 || !r.get_flags_ready()) {
+		std::printf("unexpected offset %d\n", r.get_flags_offset());
+		return 1;
+	}
+	r.set_flags_offset(-8);
+	if (r.flags != 0x81 || r.get_flags_offset() != -8) {
+		std::printf("unexpected flags 0x%x\n", r.flags);
+		return 1;
+	}
+	r.set_wide_value(-2);
+	if (r.wide != 0x03f8 || r.get_wide_value() != -2) {
+		std::printf("unexpected wide 0x%x\n", r.wide);
+		return 1;
+	}
+	r.set_wide_value(127);
+	if (r.wide != 0x01fc || r.get_wide_value() != 127) {
+		std::printf("unexpected wide 0x%x\n", r.wide);
+		return 1;
+	}
+	return 0;
+}
+`)
+}
+
+func TestGeneratedCppBitFieldGroups(t *testing.T) {
+	input := `
+    device test
+
+    register Control(1) {
+        control uint16{ctrl: {enable: 0, mode: 1-2}, irq: {mask: 8-11, level: signed 12-15}};
+        data [control_ctrl_mode]uint8;
+    };`
+
+	device, err := parser.Parse(input)
+	require.NoError(t, err)
+
+	hpp, cpp, err := GenerateHppCppWithOptions(device, "test", "test.h", CppOptions{Plain: true})
+	require.NoError(t, err)
+	require.Contains(t, hpp, "static constexpr std::uint16_t control_irq_mask_bm = 0xF00;")
+
+	runGeneratedCppTest(t, hpp, cpp, `#include "test.h"
+#include <cstdio>
+#include <cstring>
+
+int main() {
+	test::Control r{};
+	std::uint8_t data[2] = {7, 8};
+	r.set_control_ctrl_enable(true);
+	r.set_control_irq_mask(0xa);
+	r.set_control_irq_level(-2);
+	r.set_control_ctrl_mode(2);
+	r.data = data;
+
+	// both groups share the single storage word
+	std::uint8_t buf[16];
+	const std::uint8_t want[] = {0xea, 0x05, 0x07, 0x08};
+	int n = r.serialize_write(buf, sizeof(buf));
+	if (n != 4 || std::memcmp(buf, want, sizeof(want)) != 0) {
+		std::printf("unexpected size %d\n", n);
+		return 1;
+	}
+	test::Control d{};
+	std::uint8_t items[2];
+	d.data = items;
+	if (d.deserialize_write(buf, n) != n) {
+		return 1;
+	}
+	if (!d.get_control_ctrl_enable() || d.get_control_ctrl_mode() != 2 || d.get_control_irq_mask() != 0xa ||
+		d.get_control_irq_level() != -2 || items[1] != 8) {
+		std::printf("unexpected control 0x%x\n", d.control);
+		return 1;
+	}
+	return 0;
+}
+`)
+}
+
+func TestGeneratedCppConstantLiterals(t *testing.T) {
+	input := `
+    device test
+
+    const Dec = uint8(10);
+    const Hex = uint8(0xFF);
+    const Bin = uint8(0b1010);
+    enum Level int8 { LOW = -0b11, HIGH = 0B11 };
+
+    register Control(1) {
+        const Mask = uint16(0b1111000011110000);
+        level Level;
+    };`
+
+	device, err := parser.Parse(input)
+	require.NoError(t, err)
+
+	hpp, cpp, err := GenerateHppCppWithOptions(device, "test", "test.h", CppOptions{Plain: true})
+	require.NoError(t, err)
+	require.Contains(t, hpp, "static constexpr std::uint8_t Dec = 10;")
+	require.Contains(t, hpp, "static constexpr std::uint8_t Hex = 0xFF;")
+	require.Contains(t, hpp, "static constexpr std::uint8_t Bin = 0xA;")
+	require.Contains(t, hpp, "static constexpr std::uint16_t Mask = 0xF0F0;")
+	require.NotContains(t, hpp, "0b")
+
+	runGeneratedCppTest(t, hpp, cpp, `#include "test.h"
+
+int main() {
+	if (test::Dec != 10 || test::Hex != 255 || test::Bin != 10 || test::Control::Mask != 0xf0f0) {
+		return 1;
+	}
+	if (static_cast<int>(test::Level::LOW) != -3 || static_cast<int>(test::Level::HIGH) != 3) {
+		return 1;
+	}
+	return 0;
+}
+`)
+}
+
+func TestGeneratedCppVectors(t *testing.T) {
+	input := `
+    device test
+
+    register Channel(1) {
+        id uint8;
+        value int16;
+    };
+
+    register Main(2) {
+        count uint8;
+        values [count]int16;
+        flags uint8{n: 0-3};
+        bytes [flags_n]uint8;
+        size uint8;
+        channels [size]Channel;
+    };`
+
+	device, err := parser.Parse(input)
+	require.NoError(t, err)
+
+	_, _, err = GenerateHppCppWithOptions(device, "test", "test.h", CppOptions{Vectors: true})
+	require.Error(t, err)
+
+	hpp, cpp, err := GenerateHppCppWithOptions(device, "test", "test.h", CppOptions{Plain: true, Vectors: true})
+	require.NoError(t, err)
+	require.Contains(t, hpp, "#include <vector>")
+	require.Contains(t, hpp, "std::vector<std::int16_t> values;")
+	require.Contains(t, hpp, "std::vector<Channel> channels;")
+	require.Contains(t, cpp, "if (this->values.size() != (std::size_t)this->count) return -2;")
+
+	runGeneratedCppTest(t, hpp, cpp, `#include "test.h"
+#include <cstdio>
+
+int main() {
+	const std::uint8_t data[] = {2, 0x01, 0x02, 0xff, 0xfe, 0x03, 7, 8, 9, 1, 5, 0x00, 0x06};
+	test::Main r{};
+	int n = r.deserialize_write(data, sizeof(data));
+	if (n != sizeof(data)) {
+		std::printf("unexpected size %d\n", n);
+		return 1;
+	}
+	if (r.values.size() != 2 || r.values[0] != 0x0102 || r.values[1] != -2) {
+		std::printf("unexpected values of size %d\n", (int)r.values.size());
+		return 1;
+	}
+	if (r.bytes.size() != 3 || r.bytes[0] != 7 || r.bytes[2] != 9) {
+		std::printf("unexpected bytes of size %d\n", (int)r.bytes.size());
+		return 1;
+	}
+	if (r.channels.size() != 1 || r.channels[0].id != 5 || r.channels[0].value != 6) {
+		std::printf("unexpected channels of size %d\n", (int)r.channels.size());
+		return 1;
+	}
+
+	std::uint8_t buf[32];
+	n = r.serialize_write(buf, sizeof(buf));
+	if (n != sizeof(data) || n != (int)r.buf_size_write() || std::memcmp(buf, data, n) != 0) {
+		std::printf("unexpected serialized size %d\n", n);
+		return 1;
+	}
+
+	// the decoding shrinks the vectors
+	const std::uint8_t empty[] = {0, 0, 0};
+	if (r.deserialize_write(empty, sizeof(empty)) != 3 || !r.values.empty() || !r.bytes.empty() || !r.channels.empty()) {
+		std::printf("the vectors are not shrunk\n");
+		return 1;
+	}
+
+	r.values.push_back(1);
+	if (r.serialize_write(buf, sizeof(buf)) != -2) {
+		std::printf("the size mismatch is not detected\n");
+		return 1;
+	}
+	return 0;
+}
+`)
+}
+
+func TestGeneratedCppFieldRange(t *testing.T) {
+	input := `
+    device test
+
+    register Control(1) {
+        duty uint8 [0..100];
+        offset int16 [-50..50];
+        temp float32 [-40..85];
+    };`
+
+	device, err := parser.Parse(input)
+	require.NoError(t, err)
+
+	hpp, cpp, err := GenerateHppCppWithOptions(device, "test", "test.h", CppOptions{Plain: true})
+	require.NoError(t, err)
+	require.Contains(t, cpp, "if (this->duty > 100) return -2;")
+	require.Contains(t, cpp, "if (this->offset < -50 || this->offset > 50) return -2;")
+
+	runGeneratedCppTest(t, hpp, cpp, `#include "test.h"
+#include <cstdio>
+
+int main() {
+	test::Control r{};
+	std::uint8_t buf[16];
+	r.duty = 100; r.offset = -50; r.temp = 85;
+	if (r.check() != 0 || r.serialize_write(buf, sizeof(buf)) != 7) {
+		std::printf("the boundary values are rejected\n");
+		return 1;
+	}
+	r.duty = 101;
+	if (r.check() != -2 || r.serialize_write(buf, sizeof(buf)) != -2) {
+		std::printf("the duty out of range is not detected\n");
+		return 1;
+	}
+	r.duty = 0; r.offset = 51;
+	if (r.check() != -2) {
+		std::printf("the offset out of range is not detected\n");
+		return 1;
+	}
+	r.offset = 0; r.temp = -40.5f;
+	if (r.check() != -2) {
+		std::printf("the temp out of range is not detected\n");
+		return 1;
+	}
+	return 0;
+}
+`)
+}
+
+func TestGeneratedCppBitMemberAccessors(t *testing.T) {
+	input := `
+    device test
+
+    register Control(1) {
+        enable uint32{on: 0, mode: 1-3, level: 8-15, top: 31};
+    };`
+
+	device, err := parser.Parse(input)
+	require.NoError(t, err)
+
+	hpp, cpp, err := GenerateHppCppWithOptions(device, "test", "test.h", CppOptions{Plain: true})
+	require.NoError(t, err)
+
+	runGeneratedCppTest(t, hpp, cpp, `#include "test.h"
+#include <cstdio>
+
+int main() {
+	test::Control r{};
+	r.set_enable_mode(5);
+	r.set_enable_level(0xAB);
+	r.set_enable_top(true);
+	if (r.enable != 0x8000AB0A || r.get_enable_mode() != 5 || r.get_enable_level() != 0xAB || !r.get_enable_top() || r.get_enable_on()) {
+		std::printf("unexpected enable 0x%x\n", (unsigned)r.enable);
+		return 1;
+	}
+	// the value is truncated to the member bits, the other members are kept
+	r.set_enable_mode(0xF);
+	r.set_enable_on(true);
+	r.set_enable_top(false);
+	if (r.enable != 0x0000AB0F || r.get_enable_mode() != 7) {
+		std::printf("unexpected enable 0x%x\n", (unsigned)r.enable);
+		return 1;
+	}
+	return 0;
+}
+`)
+}
+
+func TestGeneratedCppMultiDimArray(t *testing.T) {
+	input := `
+    device test
+
+    register Image(1) {
+        grid [4][2]uint16;
+        count uint8;
+        rows [count][2][3]int8;
+    };`
+
+	device, err := parser.Parse(input)
+	require.NoError(t, err)
+
+	_, _, err = GenerateHppCppWithOptions(device, "test", "test.h", CppOptions{Plain: true, Vectors: true})
+	require.ErrorContains(t, err, "std::vector array 'rows' in register 'Image' cannot be multi-dimensional")
+
+	hpp, cpp, err := GenerateHppCppWithOptions(device, "test", "test.h", CppOptions{Plain: true})
+	require.NoError(t, err)
+	require.Contains(t, hpp, "std::uint16_t grid[4][2];")
+	require.Contains(t, hpp, "std::int8_t (*rows)[2][3];")
+
+	runGeneratedCppTest(t, hpp, cpp, `#include "test.h"
+#include <cstdio>
+#include <cstring>
+
+int main() {
+	std::int8_t rows[2][2][3] = {{{1, 2, 3}, {4, 5, 6}}, {{-1, -2, -3}, {-4, -5, -6}}};
+	test::Image src{};
+	for (int i = 0; i < 4; i++) {
+		src.grid[i][0] = i;
+		src.grid[i][1] = 0x100 + i;
+	}
+	src.count = 2;
+	src.rows = rows;
+	if (src.buf_size_write() != 4*2*2 + 1 + 2*2*3) {
+		std::printf("unexpected buffer size %d\n", (int)src.buf_size_write());
+		return 1;
+	}
+	std::uint8_t buf[29];
+	int n = src.serialize_write(buf, sizeof(buf));
+	if (n != sizeof(buf)) {
+		std::printf("unexpected size %d\n", n);
+		return 1;
+	}
+	// the rows are sent one after another
+	const std::uint8_t grid[] = {0, 0, 1, 0, 0, 1, 1, 1};
+	if (std::memcmp(buf, grid, sizeof(grid)) != 0 || buf[17] != 1 || buf[22] != 6 || buf[23] != 0xFF) {
+		std::printf("unexpected encoding\n");
+		return 1;
+	}
+
+	std::int8_t decoded[2][2][3] = {};
+	test::Image dst{};
+	dst.rows = decoded;
+	if (dst.deserialize_write(buf, sizeof(buf)) != n) {
+		std::printf("deserialize failed\n");
+		return 1;
+	}
+	if (std::memcmp(dst.grid, src.grid, sizeof(src.grid)) != 0 || std::memcmp(decoded, rows, sizeof(rows)) != 0) {
+		std::printf("the decoded register differs\n");
+		return 1;
+	}
+	if (dst.deserialize_write(buf, sizeof(buf) - 1) != -1) {
+		std::printf("the short buffer is not detected\n");
+		return 1;
+	}
+	return 0;
+}
+`)
+}
+
+func TestGeneratedCppMultiDimArrayHexSize(t *testing.T) {
+	input := `
+    device test
+
+    register Image(1) {
+        g [0x2][2]uint16;
+        p [0x2]int24;
+    };`
+
+	device, err := parser.Parse(input)
+	require.NoError(t, err)
+
+	hpp, cpp, err := GenerateHppCppWithOptions(device, "test", "test.h", CppOptions{Plain: true})
+	require.NoError(t, err)
+
+	runGeneratedCppTest(t, hpp, cpp, `#include "test.h"
+#include <cstdio>
+#include <cstring>
+
+int main() {
+	test::Image src{};
+	src.g[0][0] = 1;
+	src.g[1][1] = 0x0203;
+	src.p[1] = -2;
+	std::uint8_t buf[14];
+	int n = src.serialize_write(buf, sizeof(buf));
+	const std::uint8_t expected[] = {0, 1, 0, 0, 0, 0, 2, 3, 0, 0, 0, 0xFF, 0xFF, 0xFE};
+	if (n != sizeof(buf) || std::memcmp(buf, expected, sizeof(expected)) != 0) {
+		std::printf("unexpected encoding %d\n", n);
+		return 1;
+	}
+	test::Image dst{};
+	if (dst.deserialize_write(buf, sizeof(buf)) != n || std::memcmp(dst.g, src.g, sizeof(src.g)) != 0 || dst.p[1] != -2) {
+		std::printf("the decoded register differs\n");
+		return 1;
+	}
+	return 0;
+}
+`)
+}
+
+func TestGeneratedCppInt24(t *testing.T) {
+	input := `
+    device test
+
+    register Audio(1) {
+        sample int24;
+        color uint24 @le;
+        pair [2]int24;
+        count uint24;
+        samples [count]int24 @le;
+    };`
+
+	device, err := parser.Parse(input)
+	require.NoError(t, err)
+
+	hpp, cpp, err := GenerateHppCppWithOptions(device, "test", "test.h", CppOptions{Plain: true})
+	require.NoError(t, err)
+	require.Contains(t, hpp, "std::int32_t sample;")
+	require.Contains(t, hpp, "std::uint32_t color;")
+	require.NotContains(t, hpp, "sizeof(Audio::sample)")
+
+	runGeneratedCppTest(t, hpp, cpp, `#include "test.h"
+#include <cstdio>
+#include <cstring>
+
+int main() {
+	std::int32_t samples[] = {-2, 0x123456};
+	test::Audio src{};
+	src.sample = -8388608;
+	src.color = 0xABCDEF;
+	src.pair[0] = 8388607;
+	src.pair[1] = -1;
+	src.count = 2;
+	src.samples = samples;
+	if (src.buf_size_write() != 3*5 + 3*2) {
+		std::printf("unexpected buffer size %d\n", (int)src.buf_size_write());
+		return 1;
+	}
+	std::uint8_t buf[21];
+	int n = src.serialize_write(buf, sizeof(buf));
+	if (n != sizeof(buf)) {
+		std::printf("unexpected size %d\n", n);
+		return 1;
+	}
+	const std::uint8_t expected[] = {
+		0x80, 0x00, 0x00,
+		0xEF, 0xCD, 0xAB,
+		0x7F, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF,
+		0x00, 0x00, 0x02,
+		0xFE, 0xFF, 0xFF, 0x56, 0x34, 0x12,
+	};
+	if (std::memcmp(buf, expected, sizeof(expected)) != 0) {
+		std::printf("unexpected encoding\n");
+		return 1;
+	}
+
+	std::int32_t decoded[2] = {};
+	test::Audio dst{};
+	dst.samples = decoded;
+	if (dst.deserialize_write(buf, sizeof(buf)) != n) {
+		std::printf("deserialize failed\n");
+		return 1;
+	}
+	if (dst.sample != -8388608 || dst.color != 0xABCDEF || dst.pair[0] != 8388607 || dst.pair[1] != -1 ||
+		dst.count != 2 || decoded[0] != -2 || decoded[1] != 0x123456) {
+		std::printf("the decoded register differs\n");
+		return 1;
+	}
+	if (dst.deserialize_write(buf, sizeof(buf) - 1) != -1) {
+		std::printf("the short buffer is not detected\n");
+		return 1;
+	}
+	return 0;
+}
+`)
+}
+
+func TestGeneratedCppFieldOffset(t *testing.T) {
+	input := `
+    device test
+
+    register Map(1) {
+        id uint8;
+        status:r uint16 @offset 4;
+        mode:w uint8 @offset 8;
+        value uint16 @offset 10 @le;
+        count uint8;
+        data [count]uint8;
+    };`
+
+	device, err := parser.Parse(input)
+	require.NoError(t, err)
+
+	hpp, cpp, err := GenerateHppCppWithOptions(device, "test", "test.h", CppOptions{Plain: true})
+	require.NoError(t, err)
+	require.Contains(t, cpp, "memset(buf + offset, 0, 7); offset += 7;")
+
+	runGeneratedCppTest(t, hpp, cpp, `#include "test.h"
+#include <cstdio>
+#include <cstring>
+
+int main() {
+	std::uint8_t data[] = {9};
+	test::Map src{};
+	src.id = 1;
+	src.status = 0x0203;
+	src.mode = 5;
+	src.value = 0x0607;
+	src.count = 1;
+	src.data = data;
+	if (src.buf_size_read() != 14 || src.buf_size_write() != 14) {
+		std::printf("unexpected buffer sizes %d %d\n", (int)src.buf_size_read(), (int)src.buf_size_write());
+		return 1;
+	}
+
+	std::uint8_t buf[14];
+	std::memset(buf, 0xff, sizeof(buf));
+	const std::uint8_t read[] = {1, 0, 0, 0, 2, 3, 0, 0, 0, 0, 7, 6, 1, 9};
+	if (src.serialize_read(buf, sizeof(buf)) != 14 || std::memcmp(buf, read, sizeof(read)) != 0) {
+		std::printf("unexpected read encoding\n");
+		return 1;
+	}
+	const std::uint8_t write[] = {1, 0, 0, 0, 0, 0, 0, 0, 5, 0, 7, 6, 1, 9};
+	if (src.serialize_write(buf, sizeof(buf)) != 14 || std::memcmp(buf, write, sizeof(write)) != 0) {
+		std::printf("unexpected write encoding\n");
+		return 1;
+	}
+
+	std::uint8_t decoded[1] = {};
+	test::Map dst{};
+	dst.data = decoded;
+	buf[3] = 0xff;
+	if (dst.deserialize_write(buf, sizeof(buf)) != 14 || dst.mode != 5 || dst.value != 0x0607 || decoded[0] != 9) {
+		std::printf("the decoded register differs\n");
+		return 1;
+	}
+	if (dst.deserialize_write(buf, 5) != -1) {
+		std::printf("the short buffer is not detected\n");
+		return 1;
+	}
+	return 0;
+}
+`)
+}
+
+func TestGeneratedCppFloat16(t *testing.T) {
+	input := `
+    device test
+
+    register Half(1) {
+        zero float16;
+        tiny float16;
+        inf float16;
+        value float16 @le;
+        values [2]float16;
+        count uint8;
+        items [count]float16;
+    };`
+
+	device, err := parser.Parse(input)
+	require.NoError(t, err)
+
+	hpp, cpp, err := GenerateHppCppWithOptions(device, "test", "test.h", CppOptions{Plain: true})
+	require.NoError(t, err)
+	require.Contains(t, hpp, "std::uint16_t values[2];")
+	require.Contains(t, hpp, "float get_value() const { return bigendian::float16_from_bits(this->value); }")
+
+	runGeneratedCppTest(t, hpp, cpp, `#include "test.h"
+#include <cmath>
+#include <cstdio>
+#include <cstring>
+
+int main() {
+	// the tie 1+2^-11 rounds to the even 1 and 1e-8 is below the half of the smallest subnormal
+	std::uint16_t items[] = {bigendian::float16_bits(1.00048828125f), bigendian::float16_bits(1e-8f)};
+	test::Half src{};
+	src.set_zero(0.0f);
+	src.set_tiny(std::ldexp(1.0f, -24));
+	src.set_inf(INFINITY);
+	src.set_value(-1.5f);
+	src.values[0] = bigendian::float16_bits(65504.0f);
+	src.values[1] = bigendian::float16_bits(0.5f);
+	src.count = 2;
+	src.items = items;
+
+	std::uint8_t buf[17];
+	const std::uint8_t want[] = {0, 0, 0, 1, 0x7c, 0, 0, 0xbe, 0x7b, 0xff, 0x38, 0, 2, 0x3c, 0, 0, 0};
+	if (src.serialize_write(buf, sizeof(buf)) != 17 || std::memcmp(buf, want, sizeof(want)) != 0) {
+		std::printf("unexpected encoding\n");
+		return 1;
+	}
+
+	std::uint16_t decoded[2] = {};
+	test::Half dst{};
+	dst.items = decoded;
+	if (dst.deserialize_write(buf, sizeof(buf)) != 17 || dst.get_zero() != 0.0f || dst.get_tiny() != std::ldexp(1.0f, -24) ||
+		!std::isinf(dst.get_inf()) || dst.get_value() != -1.5f || bigendian::float16_from_bits(dst.values[0]) != 65504.0f ||
+		bigendian::float16_from_bits(decoded[0]) != 1.0f || bigendian::float16_from_bits(decoded[1]) != 0.0f) {
+		std::printf("unexpected decoding\n");
+		return 1;
+	}
+
+	// the values too large for the half precision are infinities, NaN stays NaN
+	src.set_zero(1e6f);
+	src.set_tiny(NAN);
+	if (src.zero != 0x7c00 || src.tiny != 0x7e00 || !std::isnan(src.get_tiny())) {
+		std::printf("unexpected special values %x %x\n", src.zero, src.tiny);
+		return 1;
+	}
+	return 0;
+}
+`)
+}
+
+func TestGeneratedCppDefaults(t *testing.T) {
+	input := `
+    device test
+
+    enum Mode uint8 { OFF = 0, ON = 1 };
+
+    register Config(1) {
+        level int16 [-100..100] = -5;
+        size uint8;
+    };
+
+    register Control(2) {
+        mode Mode = 1;
+        flags uint8{ready: 0, busy: 1} = 0b10;
+        timeout uint32 = 0x1000;
+        plain uint8;
+        config Config;
+    };`
+
+	device, err := parser.Parse(input)
+	require.NoError(t, err)
+
+	hpp, cpp, err := GenerateHppCppWithOptions(device, "test", "test.h", CppOptions{Plain: true})
+	require.NoError(t, err)
+	require.Contains(t, hpp, "Mode mode = static_cast<Mode>(1);")
+	require.Contains(t, hpp, "std::uint8_t flags = 0x2;")
+	require.Contains(t, hpp, "std::uint32_t timeout = 0x1000;")
+
+	runGeneratedCppTest(t, hpp, cpp, `#include "test.h"
+#include <cstdio>
+
+int main() {
+	test::Control r{};
+	if (r.mode != test::Mode::ON || r.flags != 2 || r.timeout != 0x1000 || r.plain != 0 ||
+		r.config.level != -5 || r.config.size != 0) {
+		std::printf("the defaults are not applied\n");
+		return 1;
+	}
+
+	// the decoding overwrites the defaults
+	const std::uint8_t buf[] = {0, 3, 0, 0, 0, 7, 9, 0, 10, 4};
+	if (r.deserialize_write(buf, sizeof(buf)) != 10 || r.mode != test::Mode::OFF || r.flags != 3 ||
+		r.timeout != 7 || r.plain != 9 || r.config.level != 10 || r.config.size != 4) {
+		std::printf("unexpected decoding\n");
+		return 1;
+	}
+	return 0;
+}
+`)
+}
+
+func TestGeneratedCppSize(t *testing.T) {
+	input := `
+    device test
+
+    register Inner(1) {
+        status:r uint16;
+        mode:w uint8;
+        value uint32;
+    };
+
+    register Control(2) {
+        id uint8;
+        status:r uint32 @offset 4;
+        command:w uint16 @offset 8;
+        count uint8;
+        items [count]uint16;
+        inner Inner;
+        inners [2]Inner;
+        written:w Inner;
+    };
+
+    register Sized(3) {
+        size uint8;
+        data [size]uint8;
+    };
+
+    register Outer(4) {
+        sized Sized;
+    };`
+
+	device, err := parser.Parse(input)
+	require.NoError(t, err)
+
+	hpp, cpp, err := GenerateHppCppWithOptions(device, "test", "test.h", CppOptions{Plain: true})
+	require.NoError(t, err)
+	require.Contains(t, cpp, "\tsize += this->inner.size();\n")
+	require.Contains(t, cpp, "\tsize += sum_buf_size(this->inners, 2, &Inner::size);\n")
+	// the member named size prevents the method in the register and the registers referring to it
+	require.NotContains(t, cpp, "Sized::size()")
+	require.NotContains(t, cpp, "Outer::size()")
+
+	runGeneratedCppTest(t, hpp, cpp, `#include "test.h"
+#include <cstdio>
+
+int main() {
+	// the read fields take 4+2+1 bytes, the write ones 1+4, the read-write ones 4 bytes
+	test::Inner inner{};
+	if (inner.buf_size_read() != 6 || inner.buf_size_write() != 5 || inner.size() != 7) {
+		std::printf("unexpected Inner sizes\n");
+		return 1;
+	}
+
+	std::uint16_t items[] = {1, 2, 3};
+	test::Control r{};
+	r.count = 3;
+	r.items = items;
+	// the id, count, items and the read-write fields of the nested registers are counted once
+	std::size_t both = 1 + 1 + 6 + 4 + 2 * 4;
+	if (r.size() != r.buf_size_read() + r.buf_size_write() - both) {
+		std::printf("unexpected size %d\n", (int)r.size());
+		return 1;
+	}
+	return 0;
+}
+`)
+}
+
+func TestGeneratedCppHexArraySize(t *testing.T) {
+	input := `
+    device test
+
+    register Table(1) {
+        a [0x4]uint16;
+        b [0b11]uint8;
+    };`
+
+	device, err := parser.Parse(input)
+	require.NoError(t, err)
+
+	hpp, cpp, err := GenerateHppCppWithOptions(device, "test", "test.h", CppOptions{Plain: true})
+	require.NoError(t, err)
+
+	runGeneratedCppTest(t, hpp, cpp, `#include "test.h"
+#include <cstdio>
+#include <cstring>
+
+int main() {
+	test::Table src{};
+	for (int i = 0; i < 4; i++) {
+		src.a[i] = 0x100 * i + 1;
+	}
+	src.b[2] = 7;
+	if (src.buf_size_read() != 4*2 + 3 || src.buf_size_write() != 4*2 + 3) {
+		std::printf("unexpected buffer size %d\n", (int)src.buf_size_write());
+		return 1;
+	}
+	std::uint8_t buf[11];
+	int n = src.serialize_write(buf, sizeof(buf));
+	if (n != sizeof(buf) || buf[6] != 3 || buf[7] != 1 || buf[10] != 7) {
+		std::printf("unexpected encoding %d\n", n);
+		return 1;
+	}
+	test::Table dst{};
+	if (dst.deserialize_write(buf, sizeof(buf)) != n || std::memcmp(dst.a, src.a, sizeof(src.a)) != 0 || dst.b[2] != 7) {
+		std::printf("the decoded register differs\n");
+		return 1;
+	}
+	return 0;
+}
+`)
+}
+
+func TestGeneratedCppConditions(t *testing.T) {
+	input := `
+    device test
+
+    register Status(1) {
+        flags uint8{has_extra: 0, has_name: 1};
+        extra uint16 [1..1000] if flags_has_extra;
+        name string if flags_has_name;
+        more:r uint8;
+        count:r uint8 if more;
+        items:r [count]uint16 if more;
+        last uint8 = 7;
+    };`
+
+	device, err := parser.Parse(input)
+	require.NoError(t, err)
+
+	hpp, cpp, err := GenerateHppCppWithOptions(device, "test", "test.h", CppOptions{Plain: true})
+	require.NoError(t, err)
+	require.Contains(t, cpp, "\tif ((this->flags & flags_has_extra_bm) != 0) size += 2;\n")
+	require.Contains(t, cpp, "\tif (this->more != 0) size += 2 * (std::size_t)this->count;\n")
+
+	runGeneratedCppTest(t, hpp, cpp, `#include "test.h"
+#include <cstdio>
+#include <cstring>
+
+int main() {
+	// the absent fields are not sent
+	test::Status src{};
+	src.extra = 5;
+	src.name_len = 2;
+	std::memcpy(src.name, "ab", 2);
+	src.count = 9;
+	if (src.buf_size_read() != 3 || src.buf_size_write() != 2 || src.size() != 3) {
+		std::printf("unexpected sizes %d %d %d\n", (int)src.buf_size_read(), (int)src.buf_size_write(), (int)src.size());
+		return 1;
+	}
+	std::uint8_t buf[16];
+	if (src.serialize_write(buf, sizeof(buf)) != 2 || buf[0] != 0 || buf[1] != 7) {
+		std::printf("unexpected write bytes\n");
+		return 1;
+	}
+
+	// the absent fields keep their values
+	test::Status dst{};
+	dst.extra = 1;
+	if (dst.deserialize_write(buf, 2) != 2 || dst.extra != 1 || dst.name_len != 0 || dst.last != 7) {
+		std::printf("unexpected absent fields\n");
+		return 1;
+	}
+
+	// the present fields are sent after their flags
+	std::uint16_t items[] = {0x0102};
+	src.set_flags_has_extra(true);
+	src.set_flags_has_name(true);
+	src.more = 1;
+	src.count = 1;
+	src.items = items;
+	const std::uint8_t expected[] = {3, 0, 5, 2, 'a', 'b', 1, 1, 1, 2, 7};
+	if (src.buf_size_read() != sizeof(expected) || src.size() != sizeof(expected)) {
+		std::printf("unexpected present sizes %d %d\n", (int)src.buf_size_read(), (int)src.size());
+		return 1;
+	}
+	if (src.serialize_read(buf, sizeof(buf)) != (int)sizeof(expected) || std::memcmp(buf, expected, sizeof(expected)) != 0) {
+		std::printf("unexpected read bytes\n");
+		return 1;
+	}
+	std::uint16_t decoded[1];
+	test::Status back{};
+	back.items = decoded;
+	if (back.deserialize_read(buf, sizeof(expected)) != (int)sizeof(expected) || back.extra != 5 || back.name_len != 2 ||
+		std::memcmp(back.name, "ab", 2) != 0 || back.count != 1 || decoded[0] != 0x0102 || back.last != 7) {
+		std::printf("unexpected present fields\n");
+		return 1;
+	}
+	if (back.deserialize_read(buf, 4) != -1) {
+		std::printf("the truncated present field must be reported\n");
+		return 1;
+	}
+
+	// the absent field is not checked
+	src.extra = 2000;
+	if (src.serialize_write(buf, sizeof(buf)) != -2) {
+		std::printf("the out of range present field must be reported\n");
+		return 1;
+	}
+	src.set_flags_has_extra(false);
+	if (src.serialize_write(buf, sizeof(buf)) < 0) {
+		std::printf("the absent field must not be checked\n");
+		return 1;
+	}
+	return 0;
+}
+`)
+}
+
+func TestGeneratedCppConstBuffer(t *testing.T) {
+	input := `
+    device test
+
+    register Point(1) {
+        x int24;
+        y uint16 @le;
+    };
+
+    register Frame(2) {
+        magic = 0xA5 uint8;
+        name string(uint8, 8);
+        origin Point;
+        grid [2][2]uint8;
+        crc crc16;
+    };`
+
+	device, err := parser.Parse(input)
+	require.NoError(t, err)
+
+	for _, opts := range []CppOptions{{Decoder: true}, {Plain: true, Decoder: true}} {
+		hpp, cpp, err := GenerateHppCppWithOptions(device, "test", "test.h", opts)
+		require.NoError(t, err)
+		std := ""
+		if opts.Plain {
+			std = "std::"
+		}
+		// the deserialization only reads the buffer, so it accepts the const receive buffers
+		require.Contains(t, hpp, "\tint deserialize_read(const "+std+"uint8_t* buf, "+std+"size_t size);\n\tint deserialize_write(const "+std+"uint8_t* buf, "+std+"size_t size);")
+		require.Contains(t, cpp, "int Frame::deserialize_read(const "+std+"uint8_t* buf, "+std+"size_t size) {")
+		require.Contains(t, cpp, "int Frame::deserialize_write(const "+std+"uint8_t* buf, "+std+"size_t size) {")
+		require.Contains(t, hpp, "\tint serialize_read("+std+"uint8_t* buf, "+std+"size_t size) const;")
+		if !opts.Plain {
+			continue
+		}
+
+		runGeneratedCppTest(t, hpp, cpp, `#include "test.h"
+#include <cstdio>
+#include <cstring>
+
+int main() {
+	test::Frame src{};
+	src.name_len = 2;
+	std::memcpy(src.name, "ok", 2);
+	src.origin.x = -5;
+	src.origin.y = 0x0102;
+	src.grid[1][0] = 7;
+
+	std::uint8_t buf[32];
+	int n = src.serialize_write(buf, sizeof(buf));
+	if (n <= 0) {
+		std::printf("serialization failed %d\n", n);
+		return 1;
+	}
+	const std::uint8_t* received = buf;
+	test::Frame dst{};
+	if (dst.deserialize_write(received, n) != n || dst.deserialize_read(received, n) != n) {
+		std::printf("deserialization failed\n");
+		return 1;
+	}
+	if (dst.name_len != 2 || std::memcmp(dst.name, "ok", 2) != 0 || dst.origin.x != -5 ||
+		dst.origin.y != 0x0102 || dst.grid[1][0] != 7) {
+		std::printf("decoded register mismatch\n");
+		return 1;
+	}
+	bool handled = false;
+	if (test::decode_register(2, received, n, [&](const auto&) { handled = true; }) != n || !handled) {
+		std::printf("decoding failed\n");
+		return 1;
+	}
+	return 0;
+}`)
+	}
+}
+
+func TestGeneratedCppDecoderVariableArray(t *testing.T) {
+	input := `
+    device test
+
+    register Point(1) {
+        x int16;
+    };
+
+    register Samples(2) {
+        count uint8;
+        items [count]uint16;
+        flags uint8{n: 0-3};
+        points [flags_n]Point;
+    };`
+
+	device, err := parser.Parse(input)
+	require.NoError(t, err)
+
+	hpp, cpp, err := GenerateHppCppWithOptions(device, "test", "test.h", CppOptions{Plain: true, Decoder: true})
+	require.NoError(t, err)
+
+	runGeneratedCppTest(t, hpp, cpp, `#include "test.h"
+#include <cstdio>
+
+int main() {
+	std::uint16_t items[2] = {0x0102, 0x0304};
+	test::Point points[1]{};
+	points[0].x = -3;
+	test::Samples src{};
+	src.count = 2;
+	src.items = items;
+	src.flags = 1;
+	src.points = points;
+	std::uint8_t buf[16];
+	int n = src.serialize_write(buf, sizeof(buf));
+	if (n != 8) {
+		std::printf("serialization failed %d\n", n);
+		return 1;
+	}
+
+	// the decoded instance has no buffers for the received arrays
+	bool handled = false;
+	if (test::decode_register(test::Reg_Samples_ID, buf, n, [&](const auto&) { handled = true; }) != -2 || handled) {
+		std::printf("the unset array buffer must be reported\n");
+		return 1;
+	}
+	test::Samples dst{};
+	if (dst.deserialize_write(buf, n) != -2) {
+		std::printf("the unset array buffer must be reported\n");
+		return 1;
+	}
+	std::uint16_t gotItems[2]{};
+	test::Point gotPoints[1]{};
+	dst.items = gotItems;
+	dst.points = gotPoints;
+	if (dst.deserialize_write(buf, n) != n || gotItems[1] != 0x0304 || gotPoints[0].x != -3) {
+		std::printf("deserialization failed\n");
+		return 1;
+	}
+
+	// the empty arrays need no buffers
+	src.count = 0;
+	src.flags = 0;
+	n = src.serialize_write(buf, sizeof(buf));
+	if (n != 2 || test::decode_register(test::Reg_Samples_ID, buf, n, [&](const auto&) { handled = true; }) != n || !handled) {
+		std::printf("decoding of the empty arrays failed\n");
+		return 1;
+	}
+	return 0;
+}`)
+}
+
+func TestGeneratedCppMessages(t *testing.T) {
+	input := `
+    device test
+
+    register Control(1) {
+        mode uint8 = 2;
+        count uint8;
+        items [count]uint16;
+    };
+
+    register DataStatus(2): r {
+        value int32;
+    };
+
+    // the telemetry frame
+    message Frame {
+        Control;
+        DataStatus; // the status
+    };`
+
+	device, err := parser.Parse(input)
+	require.NoError(t, err)
+
+	hpp, cpp, err := GenerateHppCppWithOptions(device, "test", "test.h", CppOptions{Plain: true})
+	require.NoError(t, err)
+	require.Contains(t, hpp, "// the telemetry frame\nstruct Frame {\n    Control control;\n    DataStatus data_status; // the status\n")
+	require.Contains(t, cpp, "std::size_t Frame::buf_size_read() const {\n\treturn this->control.buf_size_read() + this->data_status.buf_size_read();\n}")
+	require.Contains(t, cpp, "\t{auto res = this->data_status.deserialize_read(buf + offset, size - offset); if (res < 0) return res; offset += res;}\n")
+	require.NotContains(t, hpp, "Reg_Frame_ID")
+
+	runGeneratedCppTest(t, hpp, cpp, `#include "test.h"
+#include <cstdio>
+#include <cstring>
+
+int main() {
+	std::uint16_t items[2] = {0x0102, 0x0304};
+	test::Frame src{};
+	src.control.mode = 2;
+	src.control.count = 2;
+	src.control.items = items;
+	src.data_status.value = -3;
+
+	std::uint8_t buf[32];
+	int n = src.serialize_read(buf, sizeof(buf));
+	const std::uint8_t want[] = {2, 2, 1, 2, 3, 4, 0xFF, 0xFF, 0xFF, 0xFD};
+	if (n != (int)sizeof(want) || n != (int)src.buf_size_read() || std::memcmp(buf, want, sizeof(want)) != 0) {
+		std::printf("unexpected read bytes %d\n", n);
+		return 1;
+	}
+	if (src.serialize_read(buf, sizeof(want) - 1) != -1) {
+		std::printf("the small buffer must be reported\n");
+		return 1;
+	}
+
+	std::uint16_t decoded[2];
+	test::Frame dst{};
+	dst.control.items = decoded;
+	if (dst.deserialize_read(buf, n) != n || dst.deserialize_read(buf, n - 1) != -1) {
+		std::printf("deserialization failed\n");
+		return 1;
+	}
+	if (dst.control.mode != 2 || dst.control.count != 2 || decoded[1] != 0x0304 || dst.data_status.value != -3) {
+		std::printf("decoded message mismatch\n");
+		return 1;
+	}
+
+	// the write fields of the read-only register are not sent
+	n = src.serialize_write(buf, sizeof(buf));
+	if (n != 6 || n != (int)src.buf_size_write() || src.size() != 10) {
+		std::printf("unexpected write size %d\n", n);
+		return 1;
+	}
+	src.control.items = nullptr;
+	if (src.check() != -2 || src.serialize_write(buf, sizeof(buf)) != -2) {
+		std::printf("the register errors must be reported\n");
+		return 1;
+	}
+	return 0;
+}`)
+
+	// the register cannot be the member having the name of the message method
+	device, err = parser.Parse(`device test
+register Check(1) {
+    a uint8;
+};
+message Frame {
+    Check;
+};`)
+	require.NoError(t, err)
+	_, _, err = GenerateHppCpp(device, "test", "test.h")
+	require.ErrorContains(t, err, "register 'Check' of message 'Frame' is the 'check' member")
+}
+
+func TestGeneratedCppPrinter(t *testing.T) {
+	input := `
+    device test
+
+    enum Mode uint8 {
+        Off = 0,
+        On = 1
+    };
+
+    register Point(1) {
+        x int8;
+        y uint8;
+    };
+
+    register Control(2) {
+        sync = 0xA5 uint8;
+        mode Mode;
+        flags uint8{ready: 0, offset: signed 4-7};
+        reserved uint8;
+        temp fixed(int16, 4);
+        matrix [2][2]uint8;
+        origin Point;
+        count uint8;
+        points [count]Point;
+        n uint8;
+        items [n]uint16 if n;
+        name string(uint8, 8) if flags_ready;
+        crc crc16(modbus);
+    };
+
+    message Frame {
+        Point;
+        Control;
+    };`
+
+	device, err := parser.Parse(input)
+	require.NoError(t, err)
+
+	_, _, err = GenerateHppCppWithOptions(device, "test", "test.h", CppOptions{Printer: true})
+	require.EqualError(t, err, "std::ostream printing requires the plain C++ mode")
+
+	for _, vectors := range []bool{false, true} {
+		hpp, cpp, err := GenerateHppCppWithOptions(device, "test", "test.h", CppOptions{Plain: true, Printer: true, Vectors: vectors})
+		require.NoError(t, err)
+		require.Contains(t, hpp, "#include <ostream>\n")
+		require.Contains(t, hpp, "std::ostream& operator<<(std::ostream& os, const Control& r);\n")
+		require.Contains(t, cpp, "\tcase Mode::On: return os << \"On\";\n")
+
+		points, items := "test::Point points[2] = {{-1, 2}, {3, 4}};\n\tsrc.control.points = points;", "std::uint16_t items[1] = {7};\n\tsrc.control.items = items;"
+		if vectors {
+			points, items = "src.control.points = {{-1, 2}, {3, 4}};", "src.control.items = {7};"
+		}
+		runGeneratedCppTest(t, hpp, cpp, `#include "test.h"
+#include <cstdio>
+#include <cstring>
+#include <sstream>
+
+static int expect(const std::ostringstream& os, const char* want) {
+	if (os.str() != want) {
+		std::printf("unexpected output %s, want %s\n", os.str().c_str(), want);
+		return 1;
+	}
+	return 0;
+}
+
+int main() {
+	test::Frame src{};
+	src.point.x = -5;
+	src.point.y = 65;
+	src.control.mode = test::Mode::On;
+	src.control.set_flags_ready(true);
+	src.control.set_flags_offset(-2);
+	src.control.set_temp(1.5);
+	src.control.matrix[0][1] = 1;
+	src.control.matrix[1][0] = 2;
+	src.control.origin.x = 1;
+	src.control.count = 2;
+	`+points+`
+	src.control.n = 1;
+	`+items+`
+	src.control.name_len = 3;
+	std::memcpy(src.control.name, "abc", 3);
+
+	std::ostringstream os;
+	os << src;
+	if (expect(os, "Frame{point: Point{x: -5, y: 65}, control: Control{mode: On, flags: 225 {ready: true, offset: -2}, "
+		"temp: 1.5, matrix: [[0, 1], [2, 0]], origin: Point{x: 1, y: 0}, count: 2, points: [Point{x: -1, y: 2}, Point{x: 3, y: 4}], "
+		"n: 1, items: [7], name: \"abc\"}}") != 0) {
+		return 1;
+	}
+
+	// the absent conditional fields and the unknown enum values
+	test::Control c{};
+	c.mode = static_cast<test::Mode>(7);
+	std::ostringstream os2;
+	os2 << c;
+	return expect(os2, "Control{mode: Mode(7), flags: 0 {ready: false, offset: 0}, temp: 0, matrix: [[0, 0], [0, 0]], "
+		"origin: Point{x: 0, y: 0}, count: 0, points: [], n: 0}");
+}
+`)
+	}
+}
+
+func TestGeneratedCppRegisterVersion(t *testing.T) {
+	input := `
+    device test
+
+    register Control(1) version 2 {
+        mode uint8;
+        crc crc16(modbus);
+    };
+
+    register Status(2): r version 0x10 {
+        value uint16;
+    };`
+
+	device, err := parser.Parse(input)
+	require.NoError(t, err)
+
+	hpp, cpp, err := GenerateHppCppWithOptions(device, "test", "test.h", CppOptions{Plain: true, Decoder: true})
+	require.NoError(t, err)
+	require.Contains(t, hpp, "static constexpr std::uint8_t Reg_Control_Version = 2;\n")
+	require.Contains(t, hpp, "static constexpr std::uint8_t Reg_Status_Version = 16;\n")
+
+	runGeneratedCppTest(t, hpp, cpp, `#include "test.h"
+#include <cstdio>
+#include <cstring>
+
+int main() {
+	test::Control src{};
+	src.mode = 7;
+	std::uint8_t buf[8];
+	int n = src.serialize_write(buf, sizeof(buf));
+	if (n != 4 || n != (int)src.buf_size_write() || src.size() != 4 || buf[0] != test::Reg_Control_Version || buf[1] != 7) {
+		std::printf("unexpected write bytes %d\n", n);
+		return 1;
+	}
+	if (src.serialize_write(buf, 0) != -1) {
+		std::printf("the empty buffer must be reported\n");
+		return 1;
+	}
+
+	test::Control dst{};
+	if (dst.deserialize_write(buf, n) != n || dst.mode != 7 || dst.deserialize_write(buf, 0) != -1) {
+		std::printf("deserialization failed\n");
+		return 1;
+	}
+	buf[0] = 3;
+	if (dst.deserialize_write(buf, n) != -6 || test::decode_register(test::Reg_Control_ID, buf, n, [](const auto&) {}) != -6) {
+		std::printf("the version mismatch must be reported\n");
+		return 1;
+	}
+
+	// the read-only register sends its version with the read fields only
+	test::Status status{};
+	status.value = 0x0102;
+	const std::uint8_t want[] = {0x10, 1, 2};
+	if (status.buf_size_write() != 0 || status.serialize_read(buf, sizeof(buf)) != 3 || std::memcmp(buf, want, sizeof(want)) != 0) {
+		std::printf("unexpected read bytes\n");
+		return 1;
+	}
+	buf[0] = 0x11;
+	if (status.deserialize_read(buf, 3) != -6) {
+		std::printf("the version mismatch must be reported\n");
+		return 1;
+	}
+	return 0;
+}
+`)
+}
+
+func TestGeneratedCppSerializeWriteFrom(t *testing.T) {
+	input := `
+    device test
+
+    enum Mode uint8 { Off = 0, On = 1 };
+
+    register Point(1) {
+        x int16;
+        y int16;
+    };
+
+    register Command(2): w version 3 {
+        sync = 0xA5 uint8;
+        mode Mode;
+        flags uint8{ready: 0, n: 4-7};
+        level fixed(int16, 4);
+        size uint24 [0..2000000];
+        gain [2]uint16 @le;
+        points [flags_n]Point;
+        rows uint8;
+        grid [rows][2]uint8;
+        name string(uint8, 8) if flags_ready;
+        crc crc16;
+    };
+
+    register Status(3): r {
+        value uint8;
+    };`
+
+	device, err := parser.Parse(input)
+	require.NoError(t, err)
+
+	hpp, cpp, err := GenerateHppCppWithOptions(device, "test", "test.h", CppOptions{Plain: true})
+	require.NoError(t, err)
+	require.Contains(t, hpp, "static int serialize_write_from(std::uint8_t* buf, std::size_t size, Mode mode, std::uint8_t flags, "+
+		"std::int16_t level, std::uint32_t size_, const std::uint16_t (&gain)[2], const Point* points, std::uint8_t rows, "+
+		"const std::uint8_t (*grid)[2], std::uint8_t name_len, const char* name);")
+	require.NotContains(t, cpp, "Status::serialize_write_from", "only the write-only registers have the helper")
+
+	runGeneratedCppTest(t, hpp, cpp, `#include "test.h"
+#include <cstdio>
+#include <cstring>
+
+int main() {
+	test::Point points[2] = {{1, -2}, {3, 4}};
+	std::uint8_t grid[3][2] = {{1, 2}, {3, 4}, {5, 6}};
+	test::Command reg{};
+	reg.mode = test::Mode::On;
+	reg.set_flags_ready(true);
+	reg.set_flags_n(2);
+	reg.set_level(-1.5);
+	reg.size = 0x012345;
+	reg.gain[0] = 0x0102;
+	reg.gain[1] = 0x0304;
+	reg.points = points;
+	reg.rows = 3;
+	reg.grid = grid;
+	reg.name_len = 3;
+	std::memcpy(reg.name, "abc", 3);
+
+	std::uint8_t want[64], got[64];
+	int n = reg.serialize_write(want, sizeof(want));
+	if (n <= 0 || n != (int)reg.buf_size_write()) {
+		std::printf("serialize_write failed %d\n", n);
+		return 1;
+	}
+	int m = test::Command::serialize_write_from(got, sizeof(got), reg.mode, reg.flags, reg.level, reg.size, reg.gain,
+		points, reg.rows, grid, reg.name_len, "abc");
+	if (m != n || std::memcmp(got, want, n) != 0) {
+		std::printf("serialize_write_from bytes differ %d %d\n", m, n);
+		return 1;
+	}
+
+	// the absent conditional field is not sent, the errors are the same as the ones of serialize_write
+	reg.set_flags_ready(false);
+	n = reg.serialize_write(want, sizeof(want));
+	m = test::Command::serialize_write_from(got, sizeof(got), reg.mode, reg.flags, reg.level, reg.size, reg.gain,
+		points, reg.rows, grid, 0, nullptr);
+	if (m != n || std::memcmp(got, want, n) != 0) {
+		std::printf("the bytes without the name differ %d %d\n", m, n);
+		return 1;
+	}
+	if (test::Command::serialize_write_from(got, n - 1, reg.mode, reg.flags, reg.level, reg.size, reg.gain,
+		points, reg.rows, grid, 0, nullptr) != -1) {
+		std::printf("the small buffer must be reported\n");
+		return 1;
+	}
+	if (test::Command::serialize_write_from(got, sizeof(got), reg.mode, reg.flags, reg.level, 2000001, reg.gain,
+		points, reg.rows, grid, 0, nullptr) != -2) {
+		std::printf("the out of range size must be reported\n");
+		return 1;
+	}
+	if (test::Command::serialize_write_from(got, sizeof(got), reg.mode, reg.flags, reg.level, reg.size, reg.gain,
+		nullptr, reg.rows, grid, 0, nullptr) != -2) {
+		std::printf("the missing points must be reported\n");
+		return 1;
+	}
+	return 0;
+}
+`)
+}
@@ -0,0 +1,344 @@
+package generator
+
+import (
+	"bytes"
+	"cmp"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"text/template"
+
+	"github.com/dspasibenko/pargus/pkg/parser"
+)
+
+//
+// FlatBuffers schema template
+//
+
+const fbsTemplate = `
+// This is auto-generated file. DO NOT EDIT. Use pargus compiler to regenerate it.
+{{- range .Doc}}
+//{{if .}} {{.}}{{end}}
+{{- end}}
+
+namespace {{.Namespace}};
+{{- if .Constants}}
+{{range .Constants}}
+{{- range .}}
+//{{if .}} {{.}}{{end}}
+{{- end}}
+{{- end}}
+{{- end}}
+{{- range .Enums}}
+{{fbsDoc 0 .Doc}}
+enum {{.Name}} : {{.Base}} {
+{{- range $i, $m := .Members}}
+{{- if $i}},{{end}}
+{{- fbsDoc 2 $m.Doc}}
+  {{$m.Name}} = {{$m.Value}}
+{{- end}}
+}
+{{- end}}
+{{- range .Tables}}
+{{fbsDoc 0 .Doc}}
+table {{.Name}} {
+{{- range .Fields}}
+{{- fbsDoc 2 .Doc}}
+  {{.Name}}:{{.Type}}{{if .Default}} = {{.Default}}{{end}};
+{{- end}}
+}
+{{- end}}
+`
+
+var fbsTpl = template.Must(template.New("fbs").Funcs(template.FuncMap{"fbsDoc": fbsDoc}).Parse(fbsTemplate))
+
+//
+// Data model
+//
+
+type FBSDevice struct {
+	Namespace string
+	Doc       []string
+	Constants [][]string // the comment lines of the device constants, FlatBuffers has no constants
+	Enums     []FBSEnum
+	Tables    []FBSTable
+}
+
+type FBSEnum struct {
+	Name    string
+	Base    string
+	Doc     []string
+	Members []FBSEnumMember
+}
+
+type FBSEnumMember struct {
+	Name  string
+	Value int64
+	Doc   []string
+}
+
+// FBSTable is the table of the register fields
+type FBSTable struct {
+	Name   string
+	Doc    []string
+	Fields []FBSField
+}
+
+type FBSField struct {
+	Name    string
+	Type    string
+	Default string // the default value of the scalar field, it is empty for the zero one
+	Doc     []string
+}
+
+//
+// Public entry
+//
+
+// GenerateFlatBuffers generates the FlatBuffers schema of the register payloads. Every register
+// is a table of its fields, the bit fields are the scalars of their base type with the member
+// masks in the docs. The constant size arrays are the vectors too, and the multidimensional ones
+// are flattened. The reserved, magic and checksum fields are not the payload, so they are skipped
+func GenerateFlatBuffers(dev *parser.Device) (string, error) {
+	out := FBSDevice{
+		Namespace: strings.NewReplacer("-", "_", ".", "_").Replace(dev.Name),
+		Doc:       plainCommentLines(declComments(dev.Doc, dev.TrailingComment)),
+	}
+	for _, c := range dev.Constants {
+		out.Constants = append(out.Constants, fbsConstantLines(c))
+	}
+	for _, e := range dev.Enums {
+		fe := FBSEnum{
+			Name: e.Name,
+			Base: fbsScalarType(e.Base),
+			Doc:  plainCommentLines(declComments(e.Doc, e.TrailingComment)),
+		}
+		for _, m := range e.Members {
+			fe.Members = append(fe.Members, FBSEnumMember{
+				Name:  m.Name,
+				Value: m.Value(),
+				Doc:   plainCommentLines(declComments(m.Doc, m.TrailingComment)),
+			})
+		}
+		// flatc requires the enum values in the ascending order
+		slices.SortStableFunc(fe.Members, func(a, b FBSEnumMember) int {
+			return cmp.Compare(a.Value, b.Value)
+		})
+		out.Enums = append(out.Enums, fe)
+	}
+
+	for _, reg := range dev.Registers {
+		ft := FBSTable{
+			Name: reg.Name,
+			Doc:  declComments(reg.Doc, reg.TrailingComment),
+		}
+		ft.Doc = append(ft.Doc, fmt.Sprintf("The register number is %d.", reg.Number()))
+		if reg.HasVersion() {
+			ft.Doc = append(ft.Doc, fmt.Sprintf("The register version is %d.", reg.Version()))
+		}
+		for _, c := range reg.Body.Constants() {
+			ft.Doc = append(ft.Doc, fbsConstantLines(c)...)
+		}
+		ft.Doc = plainCommentLines(ft.Doc)
+		for _, f := range reg.Body.Fields() {
+			if f.Reserved || f.IsMagic() || f.Type.CRC != nil {
+				continue
+			}
+			ff, err := fbsField(reg, f)
+			if err != nil {
+				return "", err
+			}
+			ft.Fields = append(ft.Fields, ff)
+		}
+		out.Tables = append(out.Tables, ft)
+	}
+
+	var buf bytes.Buffer
+	if err := fbsTpl.Execute(&buf, out); err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(buf.String()) + "\n", nil
+}
+
+// fbsField returns the table field of the register field
+func fbsField(reg *parser.Register, f *parser.Field) (FBSField, error) {
+	ff := FBSField{Name: f.Name}
+	var notes []string
+	t := f.Type
+	switch {
+	case t.Simple != nil && t.Simple.IsRegisterRef():
+		ff.Type = t.Simple.Name
+
+	case t.Simple != nil && t.Simple.IsEnum():
+		ff.Type = t.Simple.Name
+		ff.Default = fbsEnumDefault(t.Simple.Enum, f)
+
+	case t.Bitfield != nil:
+		ff.Type = fbsScalarType(t.Bitfield.Base)
+		for _, bm := range t.Bitfield.Bits {
+			if bm.Reserved {
+				continue
+			}
+			bits := "bits"
+			if bm.StartBit() == bm.EndBit() {
+				bits = "bit"
+			}
+			note := fmt.Sprintf("%s: %s %s, mask 0x%X", bm.Name, bits, parser.BitRange{Start: bm.StartBit(), End: bm.EndBit()}, bitMask(bm.StartBit(), bm.EndBit()))
+			if bm.Signed {
+				note += ", two's complement"
+			}
+			notes = append(notes, note+".")
+		}
+
+	case t.Array != nil:
+		at := t.Array
+		if at.Type.IsRegisterRef() {
+			ff.Type = "[" + at.Type.Name + "]"
+		} else {
+			ff.Type = "[" + fbsScalarType(at.Type.Name) + "]"
+			notes = append(notes, fbsTypeNotes(at.Type.Name)...)
+		}
+		if at.Size.Constant != nil {
+			n, err := strconv.ParseUint(*at.Size.Constant, 0, 64)
+			if err != nil {
+				return ff, fmt.Errorf("field '%s' in register '%s': invalid array size %s", f.Name, reg.Name, *at.Size.Constant)
+			}
+			notes = append(notes, fmt.Sprintf("The vector has %d items.", n*uint64(at.InnerCount())))
+		} else {
+			notes = append(notes, fmt.Sprintf("The number of the items is the value of %s.", *at.Size.Variable))
+		}
+		if at.IsMultiDim() {
+			size := *cmp.Or(at.Size.Constant, at.Size.Variable)
+			notes = append(notes, fmt.Sprintf("The items of the [%s][%s] array are stored row by row.", size, strings.Join(at.Dims, "][")))
+		}
+
+	case t.String != nil:
+		ff.Type = "string"
+		notes = append(notes, fmt.Sprintf("The string is at most %d bytes long.", t.String.MaxLen()))
+
+	case t.Simple != nil, t.Fixed != nil:
+		typ := scalarTypeName(f)
+		ff.Type = fbsScalarType(typ)
+		if f.HasRange() {
+			notes = append(notes, fmt.Sprintf("The value is in the range [%d..%d].", f.Min(), f.Max()))
+		}
+		if t.Fixed != nil {
+			notes = append(notes, fmt.Sprintf("The fixed-point value in 1/%d units.", uint64(1)<<t.Fixed.Frac()))
+		} else {
+			notes = append(notes, fbsTypeNotes(typ)...)
+		}
+
+	default:
+		return ff, fmt.Errorf("field '%s' in register '%s': unsupported type", f.Name, reg.Name)
+	}
+
+	if f.HasDefault() && ff.Default == "" {
+		ff.Default = defaultLiteral(f)
+	}
+	switch f.Specifier {
+	case "r":
+		notes = append(notes, "The field is read-only.")
+	case "w":
+		notes = append(notes, "The field is write-only.")
+	}
+	if f.HasCondition() {
+		notes = append(notes, fmt.Sprintf("The field is sent only if %s is set.", *f.Condition))
+	}
+	ff.Doc = plainCommentLines(append(declComments(f.Doc, f.TrailingComment), notes...))
+	return ff, nil
+}
+
+//
+// Helpers
+//
+
+// fbsEnumDefault returns the member of the enum field default value. flatc rejects the zero
+// default value which is not a member, so the field without the default value defaults to
+// the first member in this case
+func fbsEnumDefault(e *parser.Enum, f *parser.Field) string {
+	if e == nil || len(e.Members) == 0 {
+		return ""
+	}
+	var value int64
+	if f.HasDefault() {
+		value = f.Default()
+	}
+	for _, m := range e.Members {
+		if m.Value() == value {
+			if value == 0 {
+				return ""
+			}
+			return m.Name
+		}
+	}
+	if f.HasDefault() {
+		return defaultLiteral(f)
+	}
+	return e.Members[0].Name
+}
+
+// fbsConstantLines returns the comment lines of the constant
+func fbsConstantLines(c *parser.Constant) []string {
+	value := strconv.FormatInt(c.Value(), 10)
+	if strings.HasPrefix(c.Type.Name, "u") {
+		value = strconv.FormatUint(uint64(c.Value()), 10)
+	}
+	lines := plainCommentLines(declComments(c.Doc, c.TrailingComment))
+	return append(lines, fmt.Sprintf("The constant %s is %s.", c.Name, value))
+}
+
+// fbsScalarType returns the FlatBuffers scalar type of the built-in type. The 24-bit integers
+// are stored in the 32-bit ones and the half-precision floats as their unsigned bits
+func fbsScalarType(typ string) string {
+	switch typ {
+	case "int8":
+		return "byte"
+	case "uint8":
+		return "ubyte"
+	case "int16":
+		return "short"
+	case "uint16", "float16":
+		return "ushort"
+	case "int24", "int32":
+		return "int"
+	case "uint24", "uint32":
+		return "uint"
+	case "int64":
+		return "long"
+	case "uint64":
+		return "ulong"
+	case "float32":
+		return "float"
+	case "float64":
+		return "double"
+	default:
+		return typ
+	}
+}
+
+// fbsTypeNotes returns the doc lines of the built-in types stored as the other ones
+func fbsTypeNotes(typ string) []string {
+	switch typ {
+	case "int24", "uint24":
+		return []string{"The 24-bit value on the wire."}
+	case "float16":
+		return []string{"The bits of the IEEE 754 half-precision value."}
+	default:
+		return nil
+	}
+}
+
+// fbsDoc returns the doc comment lines indented by the indent spaces, it is empty if there are
+// no lines
+func fbsDoc(indent int, lines []string) string {
+	pad := strings.Repeat(" ", indent)
+	var sb strings.Builder
+	for _, l := range lines {
+		sb.WriteString("\n" + pad + "///")
+		if l != "" {
+			sb.WriteString(" " + l)
+		}
+	}
+	return sb.String()
+}
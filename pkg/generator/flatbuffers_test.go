@@ -0,0 +1,97 @@
+package generator
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+
+	"github.com/dspasibenko/pargus/pkg/parser"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGenerateFlatBuffersGolden(t *testing.T) {
+	input, err := os.ReadFile("testdata/example.pa")
+	require.NoError(t, err)
+	device, err := parser.Parse(string(input))
+	require.NoError(t, err)
+
+	fbs, err := GenerateFlatBuffers(device)
+	require.NoError(t, err)
+	golden, err := os.ReadFile("testdata/example.fbs")
+	require.NoError(t, err)
+	require.Equal(t, string(golden), fbs)
+
+	// the schema is checked by the FlatBuffers compiler if it is installed
+	flatc, err := exec.LookPath("flatc")
+	if err != nil {
+		t.Log("flatc is not found, the schema is not compiled")
+		return
+	}
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "example.fbs"), []byte(fbs), 0644))
+	out, err := exec.Command(flatc, "--cpp", "-o", dir, filepath.Join(dir, "example.fbs")).CombinedOutput()
+	require.NoError(t, err, string(out))
+}
+
+func TestGenerateFlatBuffersFields(t *testing.T) {
+	device, err := parser.Parse(`device my-dev
+
+// the device limit
+const limit = uint16(500);
+
+enum State int8 {
+    // the failure
+    FAIL = -1,
+    RUN = 2,
+    IDLE = 1,
+};
+
+register Nested(1) {
+    a: r uint8;
+    b: w uint16 = 7;
+};
+
+register R(2) {
+    sync = 0xAA55 uint16;
+    n uint8;
+    ctrl uint16{en: 0, m: {lo: 1-2, hi: signed 3-5}, reserved: 8-15};
+    state State;
+    next State = 2;
+    half float16;
+    items [n]Nested;
+    grid [2][3]uint24;
+    reserved uint16;
+    label string(uint8, 10) if ctrl_en;
+    crc crc32;
+};`)
+	require.NoError(t, err)
+
+	fbs, err := GenerateFlatBuffers(device)
+	require.NoError(t, err)
+	require.Contains(t, fbs, "namespace my_dev;\n\n// the device limit\n// The constant limit is 500.\n")
+	// the enum values are ascending
+	require.Contains(t, fbs, "enum State : byte {\n  /// the failure\n  FAIL = -1,\n  IDLE = 1,\n  RUN = 2\n}\n")
+	require.Contains(t, fbs, "table Nested {\n  /// The field is read-only.\n  a:ubyte;\n  /// The field is write-only.\n  b:ushort = 7;\n}\n")
+	require.Contains(t, fbs, `table R {
+  n:ubyte;
+  /// en: bit 0, mask 0x1.
+  /// m_lo: bits 1-2, mask 0x6.
+  /// m_hi: bits 3-5, mask 0x38, two's complement.
+  ctrl:ushort;
+  state:State = FAIL;
+  next:State = RUN;
+  /// The bits of the IEEE 754 half-precision value.
+  half:ushort;
+  /// The number of the items is the value of n.
+  items:[Nested];
+  /// The 24-bit value on the wire.
+  /// The vector has 6 items.
+  /// The items of the [2][3] array are stored row by row.
+  grid:[uint];
+  /// The string is at most 10 bytes long.
+  /// The field is sent only if ctrl_en is set.
+  label:string;
+}
+`)
+}
@@ -0,0 +1,282 @@
+package generator
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/dspasibenko/pargus/pkg/parser"
+)
+
+// jsonSchemaDialect is the JSON Schema version of the generated documents
+const jsonSchemaDialect = "https://json-schema.org/draft/2020-12/schema"
+
+// jsonSchema is the subset of the JSON Schema keywords describing the registers. The fields
+// are declared in the order the keywords are written, so the documents are easy to read
+type jsonSchema struct {
+	Schema               string          `json:"$schema,omitempty"`
+	Ref                  string          `json:"$ref,omitempty"`
+	Title                string          `json:"title,omitempty"`
+	Description          string          `json:"description,omitempty"`
+	Type                 string          `json:"type,omitempty"`
+	Const                *int64          `json:"const,omitempty"`
+	Minimum              json.Number     `json:"minimum,omitempty"`
+	Maximum              json.Number     `json:"maximum,omitempty"`
+	Default              json.Number     `json:"default,omitempty"`
+	MaxLength            *int            `json:"maxLength,omitempty"`
+	MinItems             *uint64         `json:"minItems,omitempty"`
+	MaxItems             *uint64         `json:"maxItems,omitempty"`
+	Items                *jsonSchema     `json:"items,omitempty"`
+	ReadOnly             bool            `json:"readOnly,omitempty"`
+	WriteOnly            bool            `json:"writeOnly,omitempty"`
+	OneOf                []*jsonSchema   `json:"oneOf,omitempty"`
+	AnyOf                []*jsonSchema   `json:"anyOf,omitempty"`
+	Properties           jsonSchemaProps `json:"properties,omitempty"`
+	AdditionalProperties *bool           `json:"additionalProperties,omitempty"`
+	Defs                 jsonSchemaProps `json:"$defs,omitempty"`
+}
+
+// jsonSchemaProp is the named schema of the properties or the definitions
+type jsonSchemaProp struct {
+	Name   string
+	Schema *jsonSchema
+}
+
+// jsonSchemaProps keeps the declaration order of the properties, which is lost in a map
+type jsonSchemaProps []jsonSchemaProp
+
+// MarshalJSON implements json.Marshaler, the properties are written as one object
+func (p jsonSchemaProps) MarshalJSON() ([]byte, error) {
+	var buf bytes.Buffer
+	buf.WriteByte('{')
+	for i, prop := range p {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+		name, err := json.Marshal(prop.Name)
+		if err != nil {
+			return nil, err
+		}
+		schema, err := json.Marshal(prop.Schema)
+		if err != nil {
+			return nil, err
+		}
+		buf.Write(name)
+		buf.WriteByte(':')
+		buf.Write(schema)
+	}
+	buf.WriteByte('}')
+	return buf.Bytes(), nil
+}
+
+//
+// Public entry
+//
+
+// GenerateJSONSchema generates the JSON Schema of the register payloads in the JSON form of the
+// Go registers generated with the JSON option. Every enum and register is a definition, and
+// the document accepts the payload of any register. The reserved, magic and checksum fields
+// are not a part of the JSON form, so they are not described
+func GenerateJSONSchema(dev *parser.Device) (string, error) {
+	doc := &jsonSchema{
+		Schema:      jsonSchemaDialect,
+		Title:       dev.Name,
+		Description: jsonDescription(declComments(dev.Doc, dev.TrailingComment)),
+	}
+	for _, e := range dev.Enums {
+		es := &jsonSchema{
+			Description: jsonDescription(declComments(e.Doc, e.TrailingComment)),
+			Type:        "integer",
+		}
+		for _, m := range e.Members {
+			value := m.Value()
+			es.OneOf = append(es.OneOf, &jsonSchema{
+				Title:       m.Name,
+				Description: jsonDescription(declComments(m.Doc, m.TrailingComment)),
+				Const:       &value,
+			})
+		}
+		doc.Defs = append(doc.Defs, jsonSchemaProp{Name: e.Name, Schema: es})
+	}
+	for _, reg := range dev.Registers {
+		rs := &jsonSchema{
+			Description:          jsonDescription(declComments(reg.Doc, reg.TrailingComment)),
+			Type:                 "object",
+			Properties:           jsonSchemaProps{},
+			AdditionalProperties: new(bool),
+		}
+		for i, f := range reg.Body.Fields() {
+			if f.Reserved || f.IsMagic() || f.Type.CRC != nil {
+				continue
+			}
+			fs, err := jsonFieldSchema(reg, f, i)
+			if err != nil {
+				return "", err
+			}
+			rs.Properties = append(rs.Properties, jsonSchemaProp{Name: f.Name, Schema: fs})
+		}
+		doc.Defs = append(doc.Defs, jsonSchemaProp{Name: reg.Name, Schema: rs})
+		doc.AnyOf = append(doc.AnyOf, &jsonSchema{Ref: jsonSchemaRef(reg.Name)})
+	}
+	data, err := json.MarshalIndent(doc, "", "  ")
+	if err != nil {
+		return "", err
+	}
+	return string(data) + "\n", nil
+}
+
+// jsonFieldSchema returns the schema of the field value, the field with the index idx in the register
+func jsonFieldSchema(reg *parser.Register, f *parser.Field, idx int) (*jsonSchema, error) {
+	var fs *jsonSchema
+	var notes []string
+	t := f.Type
+	switch {
+	case t.Simple != nil:
+		fs = jsonSimpleSchema(t.Simple)
+		if f.HasRange() {
+			fs.Minimum = json.Number(strconv.FormatInt(f.Min(), 10))
+			fs.Maximum = json.Number(strconv.FormatInt(f.Max(), 10))
+		}
+		if f.HasDefault() {
+			fs.Default = json.Number(defaultLiteral(f))
+		}
+	case t.Fixed != nil:
+		fs = jsonIntegerSchema(t.Fixed.Base)
+		notes = append(notes, fmt.Sprintf("The fixed-point value in 1/%d units.", uint64(1)<<t.Fixed.Frac()))
+	case t.String != nil:
+		maxLen := t.String.MaxLen()
+		fs = &jsonSchema{Type: "string", MaxLength: &maxLen}
+	case t.Bitfield != nil:
+		fs = &jsonSchema{Type: "object", Properties: jsonSchemaProps{}, AdditionalProperties: new(bool)}
+		for _, bm := range t.Bitfield.Bits {
+			if bm.Reserved {
+				continue
+			}
+			fs.Properties = append(fs.Properties, jsonSchemaProp{Name: bm.Name, Schema: jsonBitMemberSchema(&bm)})
+		}
+	case t.Array != nil:
+		at := t.Array
+		// the inner dimensions are the nested arrays of the constant size
+		fs = jsonSimpleSchema(&at.Type)
+		for i := len(at.Dims) - 1; i >= 0; i-- {
+			n, err := strconv.ParseUint(at.Dims[i], 0, 64)
+			if err != nil {
+				return nil, fmt.Errorf("field '%s' in register '%s': invalid array dimension %s", f.Name, reg.Name, at.Dims[i])
+			}
+			fs = &jsonSchema{Type: "array", MinItems: &n, MaxItems: &n, Items: fs}
+		}
+		fs = &jsonSchema{Type: "array", Items: fs}
+		if at.Size.Constant != nil {
+			n, err := strconv.ParseUint(*at.Size.Constant, 0, 64)
+			if err != nil {
+				return nil, fmt.Errorf("field '%s' in register '%s': invalid array size %s", f.Name, reg.Name, *at.Size.Constant)
+			}
+			fs.MinItems, fs.MaxItems = &n, &n
+			break
+		}
+		sizeName := *at.Size.Variable
+		sizeField, bm := reg.FindFieldByName(sizeName, idx)
+		if sizeField == nil {
+			return nil, fmt.Errorf("field '%s' in register '%s': size field '%s' is not found", f.Name, reg.Name, sizeName)
+		}
+		maxItems := jsonSizeLimit(sizeField, bm)
+		fs.MaxItems = &maxItems
+		notes = append(notes, fmt.Sprintf("The number of the items is the value of %s.", sizeName))
+	default:
+		return nil, fmt.Errorf("field '%s' in register '%s': unsupported type", f.Name, reg.Name)
+	}
+
+	if f.HasCondition() {
+		notes = append(notes, fmt.Sprintf("The field is sent only if %s is set.", *f.Condition))
+	}
+	fs.Description = jsonDescription(append(declComments(f.Doc, f.TrailingComment), notes...))
+	fs.ReadOnly = f.Specifier == "r"
+	fs.WriteOnly = f.Specifier == "w"
+	return fs, nil
+}
+
+// jsonSimpleSchema returns the schema of the built-in type or the reference to the definition
+// of the enum or the register
+func jsonSimpleSchema(st *parser.SimpleType) *jsonSchema {
+	switch {
+	case st.IsEnum() || st.IsRegisterRef():
+		return &jsonSchema{Ref: jsonSchemaRef(st.Name)}
+	case strings.HasPrefix(st.Name, "float"):
+		return &jsonSchema{Type: "number"}
+	default:
+		return jsonIntegerSchema(st.Name)
+	}
+}
+
+// jsonIntegerSchema returns the schema of the integer type limited by the range of the type
+func jsonIntegerSchema(typ string) *jsonSchema {
+	bits := wireTypeSize(typ) * 8
+	if strings.HasPrefix(typ, "int") {
+		return jsonSignedSchema(bits)
+	}
+	return jsonUnsignedSchema(bits)
+}
+
+// jsonBitMemberSchema returns the schema of the bit field member, the single bits are booleans
+func jsonBitMemberSchema(bm *parser.BitMember) *jsonSchema {
+	var bs *jsonSchema
+	width := bm.EndBit() - bm.StartBit() + 1
+	switch {
+	case width == 1:
+		bs = &jsonSchema{Type: "boolean"}
+	case bm.Signed:
+		bs = jsonSignedSchema(width)
+	default:
+		bs = jsonUnsignedSchema(width)
+	}
+	bs.Description = jsonDescription(flattenComments(bm.Doc))
+	return bs
+}
+
+func jsonSignedSchema(bits int) *jsonSchema {
+	return &jsonSchema{
+		Type:    "integer",
+		Minimum: json.Number(strconv.FormatInt(-1<<(bits-1), 10)),
+		Maximum: json.Number(strconv.FormatInt(1<<(bits-1)-1, 10)),
+	}
+}
+
+func jsonUnsignedSchema(bits int) *jsonSchema {
+	return &jsonSchema{
+		Type:    "integer",
+		Minimum: "0",
+		Maximum: json.Number(strconv.FormatUint(jsonUnsignedMax(bits), 10)),
+	}
+}
+
+// jsonUnsignedMax returns the maximum value of the unsigned integer of the bits width
+func jsonUnsignedMax(bits int) uint64 {
+	if bits >= 64 {
+		return ^uint64(0)
+	}
+	return uint64(1)<<bits - 1
+}
+
+// jsonSizeLimit returns the maximum value of the size field or its bit member, which limits
+// the number of the items of the variable-length array
+func jsonSizeLimit(sizeField *parser.Field, bm *parser.BitMember) uint64 {
+	switch {
+	case bm != nil:
+		return jsonUnsignedMax(bm.EndBit() - bm.StartBit() + 1)
+	case sizeField.HasRange():
+		return uint64(sizeField.Max())
+	default:
+		return jsonUnsignedMax(wireTypeSize(sizeField.Type.Simple.Name) * 8)
+	}
+}
+
+func jsonSchemaRef(name string) string {
+	return "#/$defs/" + name
+}
+
+// jsonDescription joins the comment lines to the description text
+func jsonDescription(comments []string) string {
+	return strings.Join(plainCommentLines(comments), "\n")
+}
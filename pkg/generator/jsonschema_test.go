@@ -0,0 +1,65 @@
+package generator
+
+import (
+	"encoding/json"
+	"os"
+	"testing"
+
+	"github.com/dspasibenko/pargus/pkg/parser"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGenerateJSONSchemaGolden(t *testing.T) {
+	input, err := os.ReadFile("testdata/example.pa")
+	require.NoError(t, err)
+	device, err := parser.Parse(string(input))
+	require.NoError(t, err)
+
+	schema, err := GenerateJSONSchema(device)
+	require.NoError(t, err)
+	golden, err := os.ReadFile("testdata/example.schema.json")
+	require.NoError(t, err)
+	require.Equal(t, string(golden), schema)
+}
+
+func TestGenerateJSONSchemaFields(t *testing.T) {
+	device, err := parser.Parse(`device d
+
+register R(1) {
+    magic = 0xA5 uint8;
+    // the number of the items
+    n: w uint8 [0..10];
+    bits uint8{len: 0-2, delta: signed 3-6, on: 7};
+    reserved uint8;
+    items [n]uint64;
+    words [bits_len]uint16 if bits_on; // the words
+    crc crc16;
+};`)
+	require.NoError(t, err)
+
+	schema, err := GenerateJSONSchema(device)
+	require.NoError(t, err)
+	var doc struct {
+		Defs map[string]struct {
+			Properties map[string]map[string]any `json:"properties"`
+		} `json:"$defs"`
+	}
+	require.NoError(t, json.Unmarshal([]byte(schema), &doc))
+
+	props := doc.Defs["R"].Properties
+	require.Len(t, props, 4)
+	require.NotContains(t, props, "magic")
+	require.NotContains(t, props, "crc")
+	require.Equal(t, "the number of the items", props["n"]["description"])
+	require.Equal(t, true, props["n"]["writeOnly"])
+	require.Equal(t, 10.0, props["n"]["maximum"])
+
+	delta := props["bits"]["properties"].(map[string]any)["delta"].(map[string]any)
+	require.Equal(t, -8.0, delta["minimum"])
+	require.Equal(t, 7.0, delta["maximum"])
+
+	require.Equal(t, 10.0, props["items"]["maxItems"])
+	require.Equal(t, 18446744073709551615.0, props["items"]["items"].(map[string]any)["maximum"])
+	require.Equal(t, 7.0, props["words"]["maxItems"])
+	require.Equal(t, "the words\nThe number of the items is the value of bits_len.\nThe field is sent only if bits_on is set.", props["words"]["description"])
+}
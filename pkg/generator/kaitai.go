@@ -0,0 +1,534 @@
+package generator
+
+import (
+	"bytes"
+	"cmp"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"text/template"
+
+	"github.com/dspasibenko/pargus/pkg/parser"
+)
+
+//
+// Kaitai Struct template
+//
+
+const ksyTemplate = `
+# This is auto-generated file. DO NOT EDIT. Use pargus compiler to regenerate it.
+meta:
+  id: {{.ID}}
+  title: {{.Title}}
+  endian: {{.Endian}}
+  bit-endian: {{.Endian}}
+{{- ksyDoc 0 .Doc}}
+{{- if .Constants}}
+instances:
+{{- range .Constants}}
+  {{.ID}}:
+    value: {{.Value}}
+{{- ksyDoc 4 .Doc}}
+{{- end}}
+{{- end}}
+{{- if .Enums}}
+enums:
+{{- range .Enums}}
+  {{.ID}}:
+{{- range .Members}}
+{{- if .Doc}}
+    {{.Value}}:
+      id: {{.ID}}
+{{- ksyDoc 6 .Doc}}
+{{- else}}
+    {{.Value}}: {{.ID}}
+{{- end}}
+{{- end}}
+{{- end}}
+{{- end}}
+{{- if .Types}}
+types:
+{{- range .Types}}
+  {{.ID}}:
+{{- ksyDoc 4 .Doc}}
+{{- if .Seq}}
+    seq:
+{{- range .Seq}}
+{{- range $i, $p := .Props}}
+      {{if eq $i 0}}- {{else}}  {{end}}{{$p.Key}}: {{$p.Value}}
+{{- end}}
+{{- ksyDoc 8 .Doc}}
+{{- end}}
+{{- end}}
+{{- if .Constants}}
+    instances:
+{{- range .Constants}}
+      {{.ID}}:
+        value: {{.Value}}
+{{- ksyDoc 8 .Doc}}
+{{- end}}
+{{- end}}
+{{- end}}
+{{- end}}
+`
+
+var ksyTpl = template.Must(template.New("ksy").Funcs(template.FuncMap{"ksyDoc": ksyDoc}).Parse(ksyTemplate))
+
+//
+// Data model
+//
+
+type KSYDevice struct {
+	ID        string
+	Title     string
+	Endian    string // be or le, the byte and the bit order of the fields unless their types specify it
+	Doc       []string
+	Constants []KSYInstance
+	Enums     []KSYEnum
+	Types     []KSYType
+}
+
+// KSYInstance is the value instance holding the constant
+type KSYInstance struct {
+	ID    string
+	Value string
+	Doc   []string
+}
+
+type KSYEnum struct {
+	ID      string
+	Members []KSYEnumMember
+}
+
+type KSYEnumMember struct {
+	Value int64
+	ID    string
+	Doc   []string
+}
+
+// KSYType is the register encoding or the bit field of the register
+type KSYType struct {
+	ID        string
+	Doc       []string
+	Seq       []KSYAttr
+	Constants []KSYInstance
+}
+
+// KSYAttr is the seq entry, the properties are written in their order before the doc
+type KSYAttr struct {
+	Props []KSYProp
+	Doc   []string
+}
+
+type KSYProp struct {
+	Key   string
+	Value string
+}
+
+//
+// Public entry
+//
+
+// GenerateKaitai generates the Kaitai Struct definition of the device. Every register is a type
+// decoding its fields, the register which read and write fields differ has the read and the
+// write types. The bit fields are the types of their bits, the enums and the constants of the
+// device and the registers are the enums and the value instances
+func GenerateKaitai(dev *parser.Device) (string, error) {
+	out := KSYDevice{
+		ID:        ksyID(dev.Name),
+		Title:     ksyScalar(dev.Name),
+		Endian:    "be",
+		Doc:       plainCommentLines(declComments(dev.Doc, dev.TrailingComment)),
+		Constants: ksyConstants(dev.Constants),
+	}
+	if dev.Endianness == "le" {
+		out.Endian = "le"
+	}
+	for _, e := range dev.Enums {
+		ke := KSYEnum{ID: ksyID(e.Name)}
+		for _, m := range e.Members {
+			ke.Members = append(ke.Members, KSYEnumMember{
+				Value: m.Value(),
+				ID:    ksyScalar(ksyID(m.Name)),
+				Doc:   plainCommentLines(declComments(m.Doc, m.TrailingComment)),
+			})
+		}
+		out.Enums = append(out.Enums, ke)
+	}
+
+	for _, reg := range dev.Registers {
+		// the bit field types are shared by the read and the write types of the register
+		var bitTypes []KSYType
+		dirs := []string{""}
+		if ksySplit(dev, reg) {
+			dirs = []string{"read", "write"}
+		}
+		for _, dir := range dirs {
+			kt := KSYType{
+				ID:        ksyRegisterType(reg.Name, dir),
+				Doc:       plainCommentLines(declComments(reg.Doc, reg.TrailingComment)),
+				Constants: ksyConstants(reg.Body.Constants()),
+			}
+			if dir == "" {
+				kt.Doc = append(kt.Doc, fmt.Sprintf("The register number is %d.", reg.Number()))
+			} else {
+				kt.Doc = append(kt.Doc, fmt.Sprintf("The %s fields of the register %d.", dir, reg.Number()))
+			}
+			ids := make(map[string]bool)
+			for _, c := range kt.Constants {
+				ids[c.ID] = true
+			}
+			if reg.HasVersion() && (dir == "" || reg.Specifier == "" || reg.Specifier == dir[:1]) {
+				// the version byte precedes the fields in the directions the register is sent in
+				if ids["version"] {
+					return "", fmt.Errorf("register '%s': Kaitai Struct id 'version' is already used", reg.Name)
+				}
+				kt.Seq = append(kt.Seq, KSYAttr{
+					Props: []KSYProp{{"id", "version"}, {"type", "u1"}, {"valid", strconv.Itoa(int(reg.Version()))}},
+					Doc:   []string{"The version of the register encoding."},
+				})
+				ids["version"] = true
+			}
+			for i, f := range reg.Body.Fields() {
+				// the fields of the register with one type are sent in the same directions
+				read := dir == "read" || (dir == "" && f.Specifier != "w")
+				if (dir == "read" && f.Specifier == "w") || (dir == "write" && f.Specifier == "r") {
+					continue
+				}
+				if pad := reg.Padding(f, read); pad > 0 {
+					// the field placed at the offset is preceded by the zero bytes filling the gap
+					kt.Seq = append(kt.Seq, KSYAttr{Props: []KSYProp{{"size", strconv.Itoa(pad)}}})
+				}
+				attrs, bitType, err := ksyFieldAttrs(dev, reg, f, i, dir)
+				if err != nil {
+					return "", err
+				}
+				for _, a := range attrs {
+					if id := a.Props[0]; id.Key == "id" {
+						if ids[id.Value] {
+							return "", fmt.Errorf("field '%s' in register '%s': Kaitai Struct id '%s' is already used", f.Name, reg.Name, id.Value)
+						}
+						ids[id.Value] = true
+					}
+				}
+				kt.Seq = append(kt.Seq, attrs...)
+				if bitType != nil && dir != "write" {
+					bitTypes = append(bitTypes, *bitType)
+				}
+			}
+			out.Types = append(out.Types, kt)
+		}
+		out.Types = append(out.Types, bitTypes...)
+	}
+
+	var buf bytes.Buffer
+	if err := ksyTpl.Execute(&buf, out); err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(buf.String()) + "\n", nil
+}
+
+// ksyFieldAttrs returns the seq entries of the field with the index idx in the register, the
+// bit field returns the type of its bits as well
+func ksyFieldAttrs(dev *parser.Device, reg *parser.Register, f *parser.Field, idx int, dir string) ([]KSYAttr, *KSYType, error) {
+	var notes []string
+	var bitType *KSYType
+	id := ksyID(f.Name)
+	devLE := dev.Endianness == "le"
+	le := f.IsLittleEndian()
+	a := KSYAttr{Props: []KSYProp{{"id", ksyScalar(id)}}}
+	cond := ksyCondition(reg, f, idx)
+	t := f.Type
+	switch {
+	case f.Reserved:
+		a.Props = []KSYProp{{"size", strconv.Itoa(reservedSize(f))}}
+
+	case t.CRC != nil:
+		a.add("type", ksyIntType(t.CRC.BaseType(), le, devLE))
+		notes = append(notes, fmt.Sprintf("The %s(%s) checksum of the preceding register bytes.", t.CRC.Kind, t.CRC.AlgorithmName()))
+
+	case f.IsMagic():
+		a.add("type", ksyIntType(t.Simple.Name, le, devLE))
+		a.add("valid", magicLiteral(f))
+
+	case t.Simple != nil && t.Simple.IsRegisterRef():
+		a.add("type", ksyRegisterType(t.Simple.Name, ksyRefDir(dev, t.Simple.Name, dir)))
+
+	case t.Bitfield != nil:
+		bitType = ksyBitType(reg, f, le != devLE)
+		a.add("type", bitType.ID)
+
+	case t.Array != nil:
+		at := t.Array
+		switch {
+		case at.Type.IsRegisterRef():
+			a.add("type", ksyRegisterType(at.Type.Name, ksyRefDir(dev, at.Type.Name, dir)))
+		default:
+			a.add("type", ksyIntType(at.Type.Name, le, devLE))
+			notes = append(notes, ksyTypeNotes(at.Type.Name)...)
+		}
+		var count string
+		if at.Size.Constant != nil {
+			n, err := strconv.ParseUint(*at.Size.Constant, 0, 64)
+			if err != nil {
+				return nil, nil, fmt.Errorf("field '%s' in register '%s': invalid array size %s", f.Name, reg.Name, *at.Size.Constant)
+			}
+			count = strconv.FormatUint(n*uint64(at.InnerCount()), 10)
+		} else {
+			sizeName := *at.Size.Variable
+			sizeField, bm := reg.FindFieldByName(sizeName, idx)
+			if sizeField == nil {
+				return nil, nil, fmt.Errorf("field '%s' in register '%s': size field '%s' is not found", f.Name, reg.Name, sizeName)
+			}
+			count = ksyID(sizeField.Name)
+			if bm != nil {
+				count += "." + ksyID(bm.Name)
+			}
+			if at.IsMultiDim() {
+				count += " * " + strconv.Itoa(at.InnerCount())
+			}
+			notes = append(notes, fmt.Sprintf("The number of the items is the value of %s.", sizeName))
+		}
+		if at.IsMultiDim() {
+			size := *cmp.Or(at.Size.Constant, at.Size.Variable)
+			notes = append(notes, fmt.Sprintf("The items of the [%s][%s] array are sent row by row.", size, strings.Join(at.Dims, "][")))
+		}
+		a.add("repeat", "expr")
+		a.add("repeat-expr", count)
+
+	case t.String != nil:
+		// the string is the length prefix followed by the bytes
+		st := t.String
+		length := KSYAttr{Props: []KSYProp{{"id", ksyScalar(id + "_len")}, {"type", ksyIntType(st.PrefixType(), le, devLE)}}}
+		if st.MaxLenStr != nil {
+			length.add("valid", fmt.Sprintf("{ max: %d }", st.MaxLen()))
+		}
+		a.add("type", "str")
+		a.add("size", id+"_len")
+		a.add("encoding", "UTF-8")
+		if cond != "" {
+			length.add("if", cond)
+			a.add("if", cond)
+		}
+		a.Doc = plainCommentLines(declComments(f.Doc, f.TrailingComment))
+		return []KSYAttr{length, a}, nil, nil
+
+	case t.Simple != nil && t.Simple.IsEnum():
+		a.add("type", ksyIntType(t.Simple.Enum.Base, le, devLE))
+		a.add("enum", ksyID(t.Simple.Name))
+
+	case t.Simple != nil, t.Fixed != nil:
+		typ := scalarTypeName(f)
+		a.add("type", ksyIntType(typ, le, devLE))
+		if f.HasRange() {
+			a.add("valid", fmt.Sprintf("{ min: %d, max: %d }", f.Min(), f.Max()))
+		}
+		if t.Fixed != nil {
+			notes = append(notes, fmt.Sprintf("The fixed-point value in 1/%d units.", uint64(1)<<t.Fixed.Frac()))
+		} else {
+			notes = append(notes, ksyTypeNotes(typ)...)
+		}
+
+	default:
+		return nil, nil, fmt.Errorf("field '%s' in register '%s': unsupported type", f.Name, reg.Name)
+	}
+
+	if cond != "" {
+		a.add("if", cond)
+	}
+	a.Doc = plainCommentLines(append(declComments(f.Doc, f.TrailingComment), notes...))
+	return []KSYAttr{a}, bitType, nil
+}
+
+// ksyCondition returns the expression of the field condition, it is empty for the unconditional
+// fields. The single-bit member is the boolean b1 attribute, the uint8 field is set if it is not zero
+func ksyCondition(reg *parser.Register, f *parser.Field, idx int) string {
+	if !f.HasCondition() {
+		return ""
+	}
+	fld, bm := reg.FindFieldByName(*f.Condition, idx)
+	if bm != nil {
+		return ksyID(fld.Name) + "." + ksyID(bm.Name)
+	}
+	return ksyID(fld.Name) + " != 0"
+}
+
+// ksyBitType returns the type of the bit field bits. The bits are read from the first byte on
+// the wire, so they are declared from the highest one for the big-endian bit field and from the
+// lowest one for the little-endian one. The bits not covered by the members have no ids
+func ksyBitType(reg *parser.Register, f *parser.Field, swapped bool) *KSYType {
+	le := f.IsLittleEndian()
+	members := make([]*parser.BitMember, 0, len(f.Type.Bitfield.Bits))
+	for i := range f.Type.Bitfield.Bits {
+		members = append(members, &f.Type.Bitfield.Bits[i])
+	}
+	for _, gap := range f.Type.Bitfield.Gaps() {
+		start, end := strconv.Itoa(gap.Start), strconv.Itoa(gap.End)
+		members = append(members, &parser.BitMember{Reserved: true, Start: start, End: &end})
+	}
+	slices.SortFunc(members, func(a, b *parser.BitMember) int {
+		if le {
+			return a.StartBit() - b.StartBit()
+		}
+		return b.StartBit() - a.StartBit()
+	})
+
+	bt := &KSYType{ID: ksyID(reg.Name) + "_" + ksyID(f.Name)}
+	for _, bm := range members {
+		typ := "b" + strconv.Itoa(bm.EndBit()-bm.StartBit()+1)
+		if swapped {
+			typ += ksyEndianSuffix(le)
+		}
+		var a KSYAttr
+		if !bm.Reserved {
+			a.add("id", ksyScalar(ksyID(bm.Name)))
+			a.Doc = flattenComments(bm.Doc)
+			if bm.Signed {
+				a.Doc = append(a.Doc, "The two's complement value of the bits.")
+			}
+			a.Doc = plainCommentLines(a.Doc)
+		}
+		a.add("type", typ)
+		bt.Seq = append(bt.Seq, a)
+	}
+	return bt
+}
+
+//
+// Helpers
+//
+
+// add appends the property to the seq entry
+func (a *KSYAttr) add(key, value string) {
+	a.Props = append(a.Props, KSYProp{Key: key, Value: value})
+}
+
+// ksyConstants returns the value instances of the constants
+func ksyConstants(constants []*parser.Constant) []KSYInstance {
+	var res []KSYInstance
+	for _, c := range constants {
+		value := strconv.FormatInt(c.Value(), 10)
+		if strings.HasPrefix(c.Type.Name, "u") {
+			value = strconv.FormatUint(uint64(c.Value()), 10)
+		}
+		res = append(res, KSYInstance{
+			ID:    ksyScalar(ksyID(c.Name)),
+			Value: value,
+			Doc:   plainCommentLines(declComments(c.Doc, c.TrailingComment)),
+		})
+	}
+	return res
+}
+
+// ksySplit returns true if the read and the write fields of the register differ, so it has the
+// read and the write types. The register referring to such a register has them as well
+func ksySplit(dev *parser.Device, reg *parser.Register) bool {
+	fields := reg.Body.Fields()
+	for _, f := range fields {
+		if f.Specifier != fields[0].Specifier {
+			return true
+		}
+		if ref := dev.FindRegisterByName(f.RefRegisterName()); ref != nil && ksySplit(dev, ref) {
+			return true
+		}
+	}
+	return false
+}
+
+// ksyRefDir returns the direction of the type of the register the field in the dir direction
+// refers to, it is empty if the register has one type
+func ksyRefDir(dev *parser.Device, name, dir string) string {
+	if ref := dev.FindRegisterByName(name); ref != nil && ksySplit(dev, ref) {
+		return dir
+	}
+	return ""
+}
+
+// ksyRegisterType returns the type id of the register fields sent in the dir direction
+func ksyRegisterType(name, dir string) string {
+	if dir == "" {
+		return ksyID(name)
+	}
+	return ksyID(name) + "_" + dir
+}
+
+// ksyIntType returns the Kaitai Struct type of the built-in type. The 24-bit integers are read
+// as the bits and the half-precision floats as their unsigned bits. The byte order suffix is
+// added if the field byte order differs from the device one
+func ksyIntType(typ string, le, devLE bool) string {
+	var res string
+	switch {
+	case is24BitType(typ):
+		res = "b24"
+	case typ == "float16":
+		res = "u2"
+	case strings.HasPrefix(typ, "float"):
+		res = "f" + strconv.Itoa(wireTypeSize(typ))
+	case strings.HasPrefix(typ, "int"):
+		res = "s" + strconv.Itoa(wireTypeSize(typ))
+	default:
+		res = "u" + strconv.Itoa(wireTypeSize(typ))
+	}
+	if wireTypeSize(typ) > 1 && le != devLE {
+		res += ksyEndianSuffix(le)
+	}
+	return res
+}
+
+// ksyTypeNotes returns the doc lines of the built-in types Kaitai Struct reads as the other ones
+func ksyTypeNotes(typ string) []string {
+	switch typ {
+	case "int24":
+		return []string{"The two's complement value of the bits."}
+	case "float16":
+		return []string{"The bits of the IEEE 754 half-precision value."}
+	default:
+		return nil
+	}
+}
+
+func ksyEndianSuffix(le bool) string {
+	if le {
+		return "le"
+	}
+	return "be"
+}
+
+// ksyID converts the name to the Kaitai Struct identifier, e.g. DataFrame is data_frame and
+// argus-p is argus_p
+func ksyID(name string) string {
+	return strings.TrimLeft(strings.ReplaceAll(cSnakeCase(name), "-", "_"), "_")
+}
+
+// ksyScalar quotes the plain YAML scalars which YAML 1.1 reads as booleans or null, e.g. the
+// enum member OFF is 'off'
+func ksyScalar(s string) string {
+	switch strings.ToLower(s) {
+	case "y", "n", "yes", "no", "on", "off", "true", "false", "null", "~":
+		return "'" + s + "'"
+	default:
+		return s
+	}
+}
+
+// ksyDoc returns the doc key of the lines indented by the indent spaces, it is empty if there
+// are no lines. The indentation indicator keeps the leading spaces of the first line
+func ksyDoc(indent int, lines []string) string {
+	if len(lines) == 0 {
+		return ""
+	}
+	pad := strings.Repeat(" ", indent)
+	var sb strings.Builder
+	sb.WriteString("\n" + pad + "doc: |")
+	if strings.HasPrefix(lines[0], " ") {
+		sb.WriteString("2")
+	}
+	for _, l := range lines {
+		sb.WriteString("\n")
+		if l != "" {
+			sb.WriteString(pad + "  " + l)
+		}
+	}
+	return sb.String()
+}
@@ -0,0 +1,157 @@
+package generator
+
+import (
+	"os"
+	"testing"
+
+	"github.com/dspasibenko/pargus/pkg/parser"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/yaml.v3"
+)
+
+func TestGenerateKaitaiGolden(t *testing.T) {
+	input, err := os.ReadFile("testdata/example.pa")
+	require.NoError(t, err)
+	device, err := parser.Parse(string(input))
+	require.NoError(t, err)
+
+	ksy, err := GenerateKaitai(device)
+	require.NoError(t, err)
+	golden, err := os.ReadFile("testdata/example.ksy")
+	require.NoError(t, err)
+	require.Equal(t, string(golden), ksy)
+}
+
+func TestGenerateKaitaiFields(t *testing.T) {
+	device, err := parser.Parse(`device d @le
+
+enum State uint16 {
+    // the idle state
+    IDLE = 0,
+    ON = 1,
+};
+
+register Nested(1) {
+    a: r uint8;
+    b: w uint16;
+};
+
+register R(2) {
+    sync = 0xAA55 uint16;
+    n uint8;
+    ctrl uint16{en: 0, m: {lo: 1-2, hi: signed 3-5}, reserved: 8-9} @be;
+    half float16 @offset 8;
+    state State if n;
+    items [n]Nested;
+    grid [ctrl_m_lo][2]int24 @be;
+    reserved uint16;
+    //   the label
+    label string(uint8, 10) if ctrl_en;
+    crc crc32;
+};`)
+	require.NoError(t, err)
+
+	ksy, err := GenerateKaitai(device)
+	require.NoError(t, err)
+	type attr map[string]any
+	var doc struct {
+		Meta  map[string]string
+		Enums map[string]map[int]any
+		Types map[string]struct {
+			Doc string
+			Seq []attr
+		}
+	}
+	require.NoError(t, yaml.Unmarshal([]byte(ksy), &doc))
+
+	require.Equal(t, "le", doc.Meta["endian"])
+	require.Equal(t, "on", doc.Enums["state"][1])
+	require.Equal(t, map[string]any{"id": "idle", "doc": "the idle state\n"}, doc.Enums["state"][0])
+
+	// the register with the directional fields has the read and the write types
+	require.Equal(t, []attr{{"id": "a", "type": "u1"}}, doc.Types["nested_read"].Seq)
+	require.Equal(t, []attr{{"id": "b", "type": "u2"}}, doc.Types["nested_write"].Seq)
+	require.Equal(t, "The write fields of the register 2.\n", doc.Types["r_write"].Doc)
+	require.Equal(t, []attr{
+		{"id": "sync", "type": "u2", "valid": 0xAA55},
+		{"id": "n", "type": "u1"},
+		{"id": "ctrl", "type": "r_ctrl"},
+		{"size": 3},
+		{"id": "half", "type": "u2", "doc": "The bits of the IEEE 754 half-precision value.\n"},
+		{"id": "state", "type": "u2", "enum": "state", "if": "n != 0"},
+		{"id": "items", "type": "nested_read", "repeat": "expr", "repeat-expr": "n",
+			"doc": "The number of the items is the value of n.\n"},
+		{"id": "grid", "type": "b24be", "repeat": "expr", "repeat-expr": "ctrl.m_lo * 2",
+			"doc": "The two's complement value of the bits.\nThe number of the items is the value of ctrl_m_lo.\n" +
+				"The items of the [ctrl_m_lo][2] array are sent row by row.\n"},
+		{"size": 2},
+		{"id": "label_len", "type": "u1", "valid": attr{"max": 10}, "if": "ctrl.en"},
+		{"id": "label", "type": "str", "size": "label_len", "encoding": "UTF-8", "if": "ctrl.en", "doc": "  the label\n"},
+		{"id": "crc", "type": "u4", "doc": "The crc32(ieee) checksum of the preceding register bytes.\n"},
+	}, doc.Types["r_read"].Seq)
+
+	// the big-endian bits are declared from the highest one, the unused ones have no ids
+	require.Equal(t, []attr{
+		{"type": "b6be"},
+		{"type": "b2be"},
+		{"type": "b2be"},
+		{"id": "m_hi", "type": "b3be", "doc": "The two's complement value of the bits.\n"},
+		{"id": "m_lo", "type": "b2be"},
+		{"id": "en", "type": "b1be"},
+	}, doc.Types["r_ctrl"].Seq)
+}
+
+func TestGenerateKaitaiIDClash(t *testing.T) {
+	device, err := parser.Parse(`device d
+
+register R(1) {
+    name string;
+    name_len uint8;
+};`)
+	require.NoError(t, err)
+
+	_, err = GenerateKaitai(device)
+	require.EqualError(t, err, "field 'name_len' in register 'R': Kaitai Struct id 'name_len' is already used")
+}
+
+func TestGenerateKaitaiRegisterVersion(t *testing.T) {
+	device, err := parser.Parse(`device d
+
+register Control(1) version 2 {
+    mode uint8;
+};
+
+register Status(2) version 7 {
+    a: r uint8;
+    b: w uint16;
+};`)
+	require.NoError(t, err)
+
+	ksy, err := GenerateKaitai(device)
+	require.NoError(t, err)
+	type attr map[string]any
+	var doc struct {
+		Types map[string]struct {
+			Seq []attr
+		}
+	}
+	require.NoError(t, yaml.Unmarshal([]byte(ksy), &doc))
+
+	version := func(v int) attr {
+		return attr{"id": "version", "type": "u1", "valid": v, "doc": "The version of the register encoding.\n"}
+	}
+	require.Equal(t, []attr{version(2), {"id": "mode", "type": "u1"}}, doc.Types["control"].Seq)
+	// both types of the register with the directional fields start with the version byte
+	require.Equal(t, []attr{version(7), {"id": "a", "type": "u1"}}, doc.Types["status_read"].Seq)
+	require.Equal(t, []attr{version(7), {"id": "b", "type": "u2"}}, doc.Types["status_write"].Seq)
+
+	device, err = parser.Parse(`device d
+
+register R(1) version 1 {
+    const version = uint8(3);
+    a uint8;
+};`)
+	require.NoError(t, err)
+	_, err = GenerateKaitai(device)
+	require.EqualError(t, err, "register 'R': Kaitai Struct id 'version' is already used")
+}
@@ -3,6 +3,8 @@ package generator
 import (
 	"bytes"
 	"fmt"
+	"go/format"
+	"slices"
 	"strconv"
 	"strings"
 	"text/template"
@@ -18,190 +20,1257 @@ package {{.Package}}
 
 import (
     "encoding/binary"
+    "errors"
     "fmt"
+    "io"
+    "math"
+{{- range .Imports}}
+    "{{.}}"
+{{- end}}
 )
 
 {{- range .Doc}}
 {{.}}
 {{- end}}
 
-{{- range .Registers}}{{ $regName := .Name }}
+{{- if .Constants}}
+
+const (
+{{- range .Constants}}
+    {{- range .Doc}}
+    {{.}}
+    {{- end}}
+    {{.Name}} {{.Type}} = {{.Value}}
+{{- end}}
+)
+{{- end}}
+
+{{- range .Enums}}{{ $enumName := .Name }}
 {{range .Doc}}{{.}}
 {{end -}}
-type {{.Name}} struct {
+type {{.Name}} {{.Base}}
+
+const (
+{{- range .Members}}
+    {{- range .Doc}}
+    {{.}}
+    {{- end}}
+    {{$enumName}}_{{.Name}} {{$enumName}} = {{.Value}}
+{{- end}}
+)
+
+// String returns the name of the {{.Name}} value
+func (v {{.Name}}) String() string {
+    switch v {
+{{- range .Members}}
+    case {{$enumName}}_{{.Name}}:
+        return "{{.Name}}"
+{{- end}}
+    default:
+        return fmt.Sprintf("{{.Name}}(%d)", v)
+    }
+}
+{{- end}}
+
+// Register IDs
+const (
+{{- range .Registers}}
+    {{ident "Reg" .Name "ID"}} uint8 = {{.ID}}
+{{- end}}
+)
+{{- if .Versions}}
+
+// Register wire versions, the version byte precedes the register fields on the wire
+const (
+{{- range .Registers}}{{- if .Version}}
+    {{.Version}} uint8 = {{.VersionValue}}
+{{- end}}{{- end}}
+)
+{{- end}}
+
+{{- range .Registers}}{{ $regName := .Type }}
+{{range .Doc}}{{.}}
+{{end -}}
+type {{.Type}} struct {
 {{- range .Fields}}
     {{- range .Doc}}
     {{.}}
     {{- end}}
-    {{.Decl}} {{if .Trailing}} {{.Trailing}}{{end}}
+    {{.Decl}}{{if .Tag}} {{.Tag}}{{end}} {{if .Trailing}} {{.Trailing}}{{end}}
+{{- end}}
+}
+
+{{- if .Constants}}
+
+const (
+{{- range .Constants}}
+    {{- range .Doc}}
+    {{.}}
+    {{- end}}
+    {{.Name}} {{.Type}} = {{.Value}}
+{{- end}}
+)
 {{- end}}
+
+{{- range .Fields}}
+{{- range .BitMasks}}
+{{.}}
+{{- end}}
+{{- end}}
+{{- end}}
+
+
+{{- range .Registers}}
+{{ $regName := .Type }}
+// ================= {{.Name}} implementation =================
+{{- if .HasDefaults}}
+// {{ident "New" .Name}} returns a new {{.Name}} register with the default field values
+func {{ident "New" .Name}}() *{{.Type}} {
+	r := &{{.Type}}{}
+	r.Reset()
+	return r
+}
+{{- else}}
+// {{ident "New" .Name}} returns a new zeroed {{.Name}} register
+func {{ident "New" .Name}}() *{{.Type}} {
+	return &{{.Type}}{}
+}
+{{- end}}
+
+// The {{.Name}} register's ID
+func (r *{{.Type}}) ID() uint8 {
+	return {{ident "Reg" .Name "ID"}}
+}
+
+// BufSize4Read returns the buffer size required for read fields serialization
+func (r *{{.Type}}) BufSize4Read() int {
+    size := {{.BufSize4ReadConst}}
+{{- range .Fields}}
+{{- if .IsReadable}}
+{{- if and .BufSize4ReadExpr .Condition}}
+    if {{.Condition}} {
+        size += {{.BufSize4ReadExpr}}
+    }
+{{- else if .BufSize4ReadExpr}}
+    size += {{.BufSize4ReadExpr}}
+{{- end}}
+{{- end}}
+{{- end}}
+    return size
+}
+
+// BufSize4Write returns the buffer size required for write fields serialization
+func (r *{{.Type}}) BufSize4Write() int {
+    size := {{.BufSize4WriteConst}}
+{{- range .Fields}}
+{{- if .IsWritable}}
+{{- if and .BufSize4WriteExpr .Condition}}
+    if {{.Condition}} {
+        size += {{.BufSize4WriteExpr}}
+    }
+{{- else if .BufSize4WriteExpr}}
+    size += {{.BufSize4WriteExpr}}
+{{- end}}
+{{- end}}
+{{- end}}
+    return size
+}
+
+// Size returns the number of bytes of the read and the write fields together, the fields without the
+// r or w specifier are sent in both directions, but they are counted once
+func (r *{{.Type}}) Size() int {
+    size := {{.SizeConst}}
+{{- range .Fields}}
+{{- if and .SizeExpr .Condition}}
+    if {{.Condition}} {
+        size += {{.SizeExpr}}
+    }
+{{- else if .SizeExpr}}
+    size += {{.SizeExpr}}
+{{- end}}
+{{- end}}
+    return size
+}
+
+// Check validates the consistency of variable-length arrays with their size fields, the strings length
+// and the fields ranges
+func (r *{{.Type}}) Check() error {
+{{- range .Fields}}
+{{- range .ConsistencyChecks}}
+    {{.}}
+{{- end}}
+{{- end}}
+    return nil
+}
+
+// SerializeRead serializes read data to the wire buffer
+func (r *{{.Type}}) SerializeRead(buf []byte) (int, error) {
+    if err := r.Check(); err != nil {
+        return 0, err
+    }
+    offset := 0
+{{- if .VersionRead}}
+    if len(buf) < 1 {
+        return offset, errBufferTooSmall(1, len(buf))
+    }
+    buf[0] = {{.Version}}
+    offset++
+{{- end}}
+{{- range .Fields}}{{- if .SerializeReadData}}
+    {{range .SerializeReadData}}{{.}}
+    {{end -}}
+{{- end}}{{- end}}
+    return offset, nil
+}
+
+// SerializeWrite serializes write data to the wire buffer
+func (r *{{.Type}}) SerializeWrite(buf []byte) (int, error) {
+    if err := r.Check(); err != nil {
+        return 0, err
+    }
+    offset := 0
+{{- if .VersionWrite}}
+    if len(buf) < 1 {
+        return offset, errBufferTooSmall(1, len(buf))
+    }
+    buf[0] = {{.Version}}
+    offset++
+{{- end}}
+{{- range .Fields}}{{- if .SerializeWriteData}}
+    {{range .SerializeWriteData}}{{.}}
+    {{end -}}
+{{- end}}{{- end}}
+    return offset, nil
+}
+
+// AppendRead appends the serialized read data to b and returns the extended slice
+func (r *{{.Type}}) AppendRead(b []byte) ([]byte, error) {
+    return appendRegister(b, r.BufSize4Read(), r.SerializeRead)
+}
+
+// AppendWrite appends the serialized write data to b and returns the extended slice
+func (r *{{.Type}}) AppendWrite(b []byte) ([]byte, error) {
+    return appendRegister(b, r.BufSize4Write(), r.SerializeWrite)
+}
+
+// DeserializeRead deserializes read data into the register
+func (r *{{.Type}}) DeserializeRead(buf []byte) (int, error) {
+    offset := 0
+{{- if .VersionRead}}
+    if len(buf) < 1 {
+        return offset, errBufferTooSmall(1, len(buf))
+    }
+    if buf[0] != {{.Version}} {
+        return offset, fmt.Errorf("{{.Name}}: %w: received %d, expected %d", ErrVersionMismatch, buf[0], {{.Version}})
+    }
+    offset++
+{{- end}}
+{{- range .Fields}}{{- if .DeserializeReadData}}
+    {{range .DeserializeReadData}}{{.}}
+    {{end -}}
+{{- end}}{{- end}}
+    return offset, nil
+}
+
+// DeserializeWrite deserializes write data into the register
+func (r *{{.Type}}) DeserializeWrite(buf []byte) (int, error) {
+    offset := 0
+{{- if .VersionWrite}}
+    if len(buf) < 1 {
+        return offset, errBufferTooSmall(1, len(buf))
+    }
+    if buf[0] != {{.Version}} {
+        return offset, fmt.Errorf("{{.Name}}: %w: received %d, expected %d", ErrVersionMismatch, buf[0], {{.Version}})
+    }
+    offset++
+{{- end}}
+{{- range .Fields}}{{- if .DeserializeWriteData}}
+    {{range .DeserializeWriteData}}{{.}}
+    {{end -}}
+{{- end}}{{- end}}
+    return offset, nil
+}
+{{- $dir := "Write"}}{{$fields := "write"}}{{if .ReadOnly}}{{$dir = "Read"}}{{$fields = "read"}}{{end}}
+
+// MarshalBinary implements encoding.BinaryMarshaler, it encodes the {{$fields}} fields
+// the same way Serialize{{$dir}} does{{if .ReadOnly}}, because the register is read-only{{end}}
+func (r *{{.Type}}) MarshalBinary() ([]byte, error) {
+    buf := make([]byte, r.BufSize4{{$dir}}())
+    n, err := r.Serialize{{$dir}}(buf)
+    if err != nil {
+        return nil, err
+    }
+    return buf[:n], nil
+}
+
+// UnmarshalBinary implements encoding.BinaryUnmarshaler, it decodes the {{$fields}} fields
+// the same way Deserialize{{$dir}} does{{if .ReadOnly}}, because the register is read-only{{end}}.
+// The data must contain exactly one encoded register
+func (r *{{.Type}}) UnmarshalBinary(data []byte) error {
+    n, err := r.Deserialize{{$dir}}(data)
+    if err != nil {
+        return err
+    }
+    if n != len(data) {
+        return fmt.Errorf("unexpected %d %w after {{.Name}} register", len(data)-n, ErrTrailingBytes)
+    }
+    return nil
+}
+
+// Clone returns a deep copy of the register, the variable arrays and the nested registers are copied as well
+func (r *{{.Type}}) Clone() *{{.Type}} {
+    c := *r
+{{- range .Fields}}
+{{- range .CloneData}}
+    {{.}}
+{{- end}}
+{{- end}}
+    return &c
+}
+
+// Reset zeroes the register fields, so the register may be reused for the next decoding. The fields with
+// the default values are set to them, the variable arrays are truncated to zero length keeping their
+// capacity, the nested registers are reset as well
+func (r *{{.Type}}) Reset() {
+{{- range .Fields}}
+{{- range .ResetData}}
+    {{.}}
+{{- end}}
+{{- end}}
+}
+
+// Equal returns true if the register fields are equal to the o fields. The variable arrays
+// are compared element-wise, so a nil array is equal to an empty one
+func (r *{{.Type}}) Equal(o *{{.Type}}) bool {
+    if r == nil || o == nil {
+        return r == o
+    }
+{{- range .Fields}}
+{{- if .NotEqualExpr}}
+    if {{.NotEqualExpr}} {
+        return false
+    }
+{{- end}}
+{{- end}}
+    return true
+}
+
+// WriteTo implements io.WriterTo, it writes the data MarshalBinary returns to w
+func (r *{{.Type}}) WriteTo(w io.Writer) (int64, error) {
+    buf, err := r.MarshalBinary()
+    if err != nil {
+        return 0, err
+    }
+    n, err := w.Write(buf)
+    return int64(n), err
+}
+
+// ReadFrom implements io.ReaderFrom, it reads exactly one register in the UnmarshalBinary
+// format from rd. The size fields are decoded first to know how many array bytes to read
+func (r *{{.Type}}) ReadFrom(rd io.Reader) (int64, error) {
+    return readRegister(rd, r.Deserialize{{$dir}})
+}
+{{- if $.Pool}}
+
+// {{.PoolVar}} keeps the released {{.Name}} registers for reuse
+var {{.PoolVar}} = sync.Pool{New: func() interface{} { return {{if .HasDefaults}}{{ident "New" .Name}}(){{else}}&{{.Type}}{}{{end}} }}
+
+// {{ident "Acquire" .Name}} returns a {{if .HasDefaults}}reset{{else}}zeroed{{end}} {{.Name}} register from the pool, it should be returned to the
+// pool by {{ident "Release" .Name}} when it is not needed anymore
+func {{ident "Acquire" .Name}}() *{{.Type}} {
+    return {{.PoolVar}}.Get().(*{{.Type}})
+}
+
+// {{ident "Release" .Name}} resets the register and puts it back to the pool, so the variable arrays capacity is
+// reused by the next {{ident "Acquire" .Name}} caller. The register must not be used after the release
+func {{ident "Release" .Name}}(r *{{.Type}}) {
+    r.Reset()
+    {{.PoolVar}}.Put(r)
+}
+{{- end}}
+
+{{- if $.JSON}}
+
+// json{{.Name}} is the JSON form of the {{.Name}} register
+type json{{.Name}} struct {
+{{- range .Fields}}
+{{- if .JSONType}}
+    {{.CapitalizedName}} {{.JSONType}} ` + "`" + `json:"{{.Name}}"` + "`" + `
+{{- end}}
+{{- end}}
+}
+{{- range .Fields}}
+{{- if .BitMembers}}{{$field := .}}
+
+// json{{$regName}}{{.CapitalizedName}} is the JSON form of the {{.Name}} bit field
+type json{{$regName}}{{.CapitalizedName}} struct {
+{{- range .BitMembers}}
+    {{.CapitalizedName}} {{if .Single}}bool{{else}}{{.Type}}{{end}} ` + "`" + `json:"{{.Name}}"` + "`" + `
+{{- end}}
+}
+{{- end}}
+{{- end}}
+
+func (r *{{.Type}}) toJSON() json{{.Name}} {
+    return json{{.Name}}{
+{{- range .Fields}}
+{{- if .JSONType}}
+        {{.CapitalizedName}}: {{.JSONValue}},
+{{- end}}
+{{- end}}
+    }
+}
+
+// MarshalJSON implements json.Marshaler, the fields are named as in the .pa file and
+// the bit fields are encoded as objects with the members values
+func (r *{{.Type}}) MarshalJSON() ([]byte, error) {
+    return json.Marshal(r.toJSON())
+}
+
+// UnmarshalJSON implements json.Unmarshaler, the fields missing in the data keep their values
+func (r *{{.Type}}) UnmarshalJSON(data []byte) error {
+    j := r.toJSON()
+    if err := json.Unmarshal(data, &j); err != nil {
+        return err
+    }
+{{- range .Fields}}
+{{- range .JSONAssign}}
+    {{.}}
+{{- end}}
+{{- end}}
+    return nil
+}
+{{- end}}
+
+{{- range .Fields}}{{- if not .Reserved}}
+{{- if .FixedScale}}
+// {{ident "Get" .CapitalizedName}} returns the fixed-point value of {{.Name}}
+{{- range .AccessorDoc}}
+{{.}}
+{{- end}}
+func (r *{{$regName}}) {{ident "Get" .CapitalizedName}}() float64 {
+    return float64(r.{{.Name}}) / {{.FixedScale}}
+}
+
+// {{ident "Set" .CapitalizedName}} sets the fixed-point value of {{.Name}}, the value is rounded to the nearest step
+{{- range .AccessorDoc}}
+{{.}}
+{{- end}}
+func (r *{{$regName}}) {{ident "Set" .CapitalizedName}}(v float64) {
+    r.{{.Name}} = {{.Type}}(math.Round(v * {{.FixedScale}}))
+}
+
+// {{ident "Get" .CapitalizedName "Raw"}} returns the integer value of {{.Name}} sent over the wire
+{{- range .AccessorDoc}}
+{{.}}
+{{- end}}
+func (r *{{$regName}}) {{ident "Get" .CapitalizedName "Raw"}}() {{.Type}} {
+    return r.{{.Name}}
+}
+
+// {{ident "Set" .CapitalizedName "Raw"}} sets the integer value of {{.Name}} sent over the wire
+{{- range .AccessorDoc}}
+{{.}}
+{{- end}}
+func (r *{{$regName}}) {{ident "Set" .CapitalizedName "Raw"}}(v {{.Type}}) {
+    r.{{.Name}} = v
+}
+{{- else}}
+// {{ident "Get" .CapitalizedName}} returns value for {{.Name}}
+{{- range .AccessorDoc}}
+{{.}}
+{{- end}}
+func (r *{{$regName}}) {{ident "Get" .CapitalizedName}}() {{.Type}} {
+    return r.{{.Name}}
+}
+
+{{- if .SizeUpdate}}
+// {{ident "Set" .CapitalizedName}} sets value for {{.Name}} and stores its length in {{.SizeField}}.
+// The length must fit into {{.SizeField}}, otherwise Check reports the mismatch
+{{- range .AccessorDoc}}
+{{.}}
+{{- end}}
+func (r *{{$regName}}) {{ident "Set" .CapitalizedName}}(v {{.Type}}) {
+    r.{{.Name}} = v
+    {{.SizeUpdate}}
+}
+{{- else}}
+// {{ident "Set" .CapitalizedName}} sets value for {{.Name}}
+{{- range .AccessorDoc}}
+{{.}}
+{{- end}}
+func (r *{{$regName}}) {{ident "Set" .CapitalizedName}}(v {{.Type}}) {
+    r.{{.Name}} = v
+}
+{{- end}}
+{{- end}}
+{{- $field := .}}
+{{- range .BitMembers}}
+{{- if .Single}}
+
+// {{ident "Get" $field.CapitalizedName .CapitalizedName}} returns true if the {{.Name}} bit of {{$field.Name}} is set
+func (r *{{$regName}}) {{ident "Get" $field.CapitalizedName .CapitalizedName}}() bool {
+    return r.{{$field.Name}}&{{.Mask}} != 0
+}
+
+// {{ident "Set" $field.CapitalizedName .CapitalizedName}} sets or clears the {{.Name}} bit of {{$field.Name}}
+func (r *{{$regName}}) {{ident "Set" $field.CapitalizedName .CapitalizedName}}(v bool) {
+    if v {
+        r.{{$field.Name}} |= {{.Mask}}
+    } else {
+        r.{{$field.Name}} &^= {{.Mask}}
+    }
+}
+{{- else if .Signed}}
+
+// {{ident "Get" $field.CapitalizedName .CapitalizedName}} returns the {{.Name}} bits value of {{$field.Name}} sign-extended to {{.Type}}
+func (r *{{$regName}}) {{ident "Get" $field.CapitalizedName .CapitalizedName}}() {{.Type}} {
+    return {{.Type}}(r.{{$field.Name}}<<{{.TopShift}}) >> {{.SignShift}}
+}
+
+// {{ident "Set" $field.CapitalizedName .CapitalizedName}} sets the {{.Name}} bits value of {{$field.Name}}, the value is truncated to the bits width
+func (r *{{$regName}}) {{ident "Set" $field.CapitalizedName .CapitalizedName}}(v {{.Type}}) {
+    r.{{$field.Name}} = (r.{{$field.Name}} &^ {{.Mask}}) | (({{$field.Type}}(v) << {{.Shift}}) & {{.Mask}})
+}
+{{- else}}
+
+// {{ident "Get" $field.CapitalizedName .CapitalizedName}} returns the {{.Name}} bits value of {{$field.Name}}
+func (r *{{$regName}}) {{ident "Get" $field.CapitalizedName .CapitalizedName}}() {{$field.Type}} {
+    return (r.{{$field.Name}} & {{.Mask}}) >> {{.Shift}}
+}
+
+// {{ident "Set" $field.CapitalizedName .CapitalizedName}} sets the {{.Name}} bits value of {{$field.Name}}, the value is truncated to the bits width
+func (r *{{$regName}}) {{ident "Set" $field.CapitalizedName .CapitalizedName}}(v {{$field.Type}}) {
+    r.{{$field.Name}} = (r.{{$field.Name}} &^ {{.Mask}}) | ((v << {{.Shift}}) & {{.Mask}})
+}
+{{- end}}
+{{- end}}
+{{- if .StringData}}
+
+// {{ident .CapitalizedName "String"}} returns the {{.Name}} bit field members formatted as a string
+func (r *{{$regName}}) {{ident .CapitalizedName "String"}}() string {
+    var parts []string
+    {{range .StringData}}{{.}}
+    {{end -}}
+    return strings.Join(parts, "|")
+}
+{{- end}}
+{{- end}}{{- end}}
+
+{{- end}}
+
+{{- range .Messages}}
+
+// ================= {{.Name}} message =================
+{{range .Doc}}{{.}}
+{{end -}}
+type {{.Type}} struct {
+{{- range .Members}}
+    {{- range .Doc}}
+    {{.}}
+    {{- end}}
+    {{.Name}} {{.Type}}{{if .Trailing}} {{.Trailing}}{{end}}
+{{- end}}
+}
+{{- if .HasDefaults}}
+
+// {{ident "New" .Name}} returns a new {{.Name}} message, its registers have the default field values
+func {{ident "New" .Name}}() *{{.Type}} {
+    m := &{{.Type}}{}
+    m.Reset()
+    return m
+}
+{{- else}}
+
+// {{ident "New" .Name}} returns a new zeroed {{.Name}} message
+func {{ident "New" .Name}}() *{{.Type}} {
+    return &{{.Type}}{}
+}
+{{- end}}
+
+// BufSize4Read returns the buffer size required for the read fields serialization of the registers
+func (m *{{.Type}}) BufSize4Read() int {
+    return {{range $i, $r := .Members}}{{if $i}} + {{end}}m.{{.Name}}.BufSize4Read(){{end}}
+}
+
+// BufSize4Write returns the buffer size required for the write fields serialization of the registers
+func (m *{{.Type}}) BufSize4Write() int {
+    return {{range $i, $r := .Members}}{{if $i}} + {{end}}m.{{.Name}}.BufSize4Write(){{end}}
+}
+
+// Size returns the number of bytes of the read and the write fields of the registers together
+func (m *{{.Type}}) Size() int {
+    return {{range $i, $r := .Members}}{{if $i}} + {{end}}m.{{.Name}}.Size(){{end}}
+}
+
+// Check validates the registers of the message
+func (m *{{.Type}}) Check() error {
+{{- range .Members}}
+    if err := m.{{.Name}}.Check(); err != nil {
+        return err
+    }
+{{- end}}
+    return nil
+}
+
+// SerializeRead serializes the read data of the registers to the wire buffer one after another
+func (m *{{.Type}}) SerializeRead(buf []byte) (int, error) {
+    offset := 0
+{{- range .Members}}
+    if n, err := m.{{.Name}}.SerializeRead(buf[offset:]); err != nil {
+        return offset, err
+    } else {
+        offset += n
+    }
+{{- end}}
+    return offset, nil
+}
+
+// SerializeWrite serializes the write data of the registers to the wire buffer one after another
+func (m *{{.Type}}) SerializeWrite(buf []byte) (int, error) {
+    offset := 0
+{{- range .Members}}
+    if n, err := m.{{.Name}}.SerializeWrite(buf[offset:]); err != nil {
+        return offset, err
+    } else {
+        offset += n
+    }
+{{- end}}
+    return offset, nil
+}
+
+// AppendRead appends the serialized read data of the registers to b and returns the extended slice
+func (m *{{.Type}}) AppendRead(b []byte) ([]byte, error) {
+    return appendRegister(b, m.BufSize4Read(), m.SerializeRead)
+}
+
+// AppendWrite appends the serialized write data of the registers to b and returns the extended slice
+func (m *{{.Type}}) AppendWrite(b []byte) ([]byte, error) {
+    return appendRegister(b, m.BufSize4Write(), m.SerializeWrite)
+}
+
+// DeserializeRead deserializes the read data into the registers one after another
+func (m *{{.Type}}) DeserializeRead(buf []byte) (int, error) {
+    offset := 0
+{{- range .Members}}
+    if n, err := m.{{.Name}}.DeserializeRead(buf[offset:]); err != nil {
+        return offset, err
+    } else {
+        offset += n
+    }
+{{- end}}
+    return offset, nil
+}
+
+// DeserializeWrite deserializes the write data into the registers one after another
+func (m *{{.Type}}) DeserializeWrite(buf []byte) (int, error) {
+    offset := 0
+{{- range .Members}}
+    if n, err := m.{{.Name}}.DeserializeWrite(buf[offset:]); err != nil {
+        return offset, err
+    } else {
+        offset += n
+    }
+{{- end}}
+    return offset, nil
+}
+
+// Clone returns a deep copy of the message
+func (m *{{.Type}}) Clone() *{{.Type}} {
+    return &{{.Type}}{
+{{- range .Members}}
+        {{.Name}}: *m.{{.Name}}.Clone(),
+{{- end}}
+    }
+}
+
+// Reset resets the registers of the message, so it may be reused for the next decoding
+func (m *{{.Type}}) Reset() {
+{{- range .Members}}
+    m.{{.Name}}.Reset()
+{{- end}}
+}
+
+// Equal returns true if the registers of the message are equal to the o ones
+func (m *{{.Type}}) Equal(o *{{.Type}}) bool {
+    if m == nil || o == nil {
+        return m == o
+    }
+    return {{range $i, $r := .Members}}{{if $i}} && {{end}}m.{{.Name}}.Equal(&o.{{.Name}}){{end}}
+}
+{{- end}}
+
+{{- if .Decoder}}
+
+// {{ident "DecodeRegister"}} decodes the write fields of the register with the id from buf.
+// It returns the decoded register and the number of bytes read from buf
+func {{ident "DecodeRegister"}}(id uint8, buf []byte) (interface{}, int, error) {
+    switch id {
+{{- range .Registers}}
+    case {{ident "Reg" .Name "ID"}}:
+        r := &{{.Type}}{}
+        n, err := r.DeserializeWrite(buf)
+        if err != nil {
+            return nil, n, err
+        }
+        return r, n, nil
+{{- end}}
+    default:
+        return nil, 0, fmt.Errorf("%w id %d", ErrUnknownRegister, id)
+    }
+}
+
+// {{ident "DecodeReadRegister"}} decodes the read fields of the register with the id from buf.
+// It returns the decoded register and the number of bytes read from buf
+func {{ident "DecodeReadRegister"}}(id uint8, buf []byte) (interface{}, int, error) {
+    switch id {
+{{- range .Registers}}
+    case {{ident "Reg" .Name "ID"}}:
+        r := &{{.Type}}{}
+        n, err := r.DeserializeRead(buf)
+        if err != nil {
+            return nil, n, err
+        }
+        return r, n, nil
+{{- end}}
+    default:
+        return nil, 0, fmt.Errorf("%w id %d", ErrUnknownRegister, id)
+    }
+}
+
+// {{ident "DecodeAll"}} decodes the registers concatenated in buf, every register is its ID byte
+// followed by the write fields {{ident "DecodeRegister"}} decodes. It returns the decoded registers
+// and the number of bytes remaining in the end of buf undecoded. If the last register is
+// truncated, the error matches ErrBufferTooSmall and the remaining bytes start with its ID,
+// so they may be decoded again when the rest of the register is received
+func {{ident "DecodeAll"}}(buf []byte) ([]interface{}, int, error) {
+    var res []interface{}
+    for len(buf) > 0 {
+        r, n, err := {{ident "DecodeRegister"}}(buf[0], buf[1:])
+        if err != nil {
+            return res, len(buf), err
+        }
+        res = append(res, r)
+        buf = buf[1+n:]
+    }
+    return res, 0, nil
+}
+{{- end}}
+
+{{- if .Framing}}
+
+// frameHeaderSize is the size of the frame register ID and the big-endian payload length
+const frameHeaderSize = 3
+{{- if .FrameCRC}}
+
+// frameCRCSize is the size of the big-endian CRC-16/CCITT of the frame header and payload
+const frameCRCSize = 2
+{{- end}}
+
+// {{ident "FrameWrite"}} writes the register to w as one frame: the register ID, the 2-byte big-endian
+// length of the MarshalBinary payload and the payload{{if .FrameCRC}}, followed by the CRC-16/CCITT of them{{end}}
+func {{ident "FrameWrite"}}(r interface{}, w io.Writer) error {
+    var id uint8
+    var payload []byte
+    var err error
+    switch r := r.(type) {
+{{- range .Registers}}
+    case *{{.Type}}:
+        id = {{ident "Reg" .Name "ID"}}
+        payload, err = r.MarshalBinary()
+{{- end}}
+    default:
+        return fmt.Errorf("cannot frame %T, it is not a register", r)
+    }
+    if err != nil {
+        return err
+    }
+    if len(payload) > math.MaxUint16 {
+        return fmt.Errorf("register id %d payload of %d bytes does not fit into a frame", id, len(payload))
+    }
+    frame := make([]byte, frameHeaderSize, frameHeaderSize+len(payload){{if .FrameCRC}}+frameCRCSize{{end}})
+    frame[0] = id
+    binary.BigEndian.PutUint16(frame[1:], uint16(len(payload)))
+    frame = append(frame, payload...)
+{{- if .FrameCRC}}
+    frame = binary.BigEndian.AppendUint16(frame, crc16Ccitt(frame))
+{{- end}}
+    _, err = w.Write(frame)
+    return err
+}
+
+// {{ident "FrameRead"}} reads one frame {{ident "FrameWrite"}} writes from rd and decodes its payload with
+// UnmarshalBinary of the register the frame ID refers to. The payload must contain
+// exactly one encoded register
+func {{ident "FrameRead"}}(rd io.Reader) (interface{}, error) {
+    var header [frameHeaderSize]byte
+    if _, err := io.ReadFull(rd, header[:]); err != nil {
+        return nil, err
+    }
+    frame := make([]byte, frameHeaderSize+int(binary.BigEndian.Uint16(header[1:])){{if .FrameCRC}}+frameCRCSize{{end}})
+    copy(frame, header[:])
+    if _, err := io.ReadFull(rd, frame[frameHeaderSize:]); err != nil {
+        if err == io.EOF {
+            // the frame is cut after its header
+            err = io.ErrUnexpectedEOF
+        }
+        return nil, err
+    }
+{{- if .FrameCRC}}
+    body := frame[:len(frame)-frameCRCSize]
+    if crc, expected := binary.BigEndian.Uint16(frame[len(body):]), crc16Ccitt(body); crc != expected {
+        return nil, fmt.Errorf("frame %w: received 0x%x, calculated 0x%x", ErrChecksumMismatch, crc, expected)
+    }
+    payload := body[frameHeaderSize:]
+{{- else}}
+    payload := frame[frameHeaderSize:]
+{{- end}}
+    var r interface{ UnmarshalBinary([]byte) error }
+    switch id := header[0]; id {
+{{- range .Registers}}
+    case {{ident "Reg" .Name "ID"}}:
+        r = &{{.Type}}{}
+{{- end}}
+    default:
+        return nil, fmt.Errorf("%w id %d", ErrUnknownRegister, id)
+    }
+    if err := r.UnmarshalBinary(payload); err != nil {
+        return nil, err
+    }
+    return r, nil
+}
+{{- end}}
+
+{{- if .JSON}}
+
+// jsonUint8s is encoded as a JSON array of numbers, not as a base64 string like []byte
+type jsonUint8s []uint8
+
+func (s jsonUint8s) MarshalJSON() ([]byte, error) {
+	if s == nil {
+		return []byte("null"), nil
+	}
+	b := []byte{'['}
+	for i, v := range s {
+		if i > 0 {
+			b = append(b, ',')
+		}
+		b = strconv.AppendUint(b, uint64(v), 10)
+	}
+	return append(b, ']'), nil
+}
+{{- end}}
+
+{{- if .RefArrays}}
+
+// sumBufSize returns the sum of the registers buffer sizes
+func sumBufSize[T any](regs []T, bufSize func(*T) int) int {
+	size := 0
+	for i := range regs {
+		size += bufSize(&regs[i])
+	}
+	return size
+}
+{{- end}}
+
+{{- if .Int24}}
+
+// putNumber24 writes the low 3 bytes of the 24-bit integer
+func putNumber24[T ~int32 | ~uint32](b []byte, v T, order binary.ByteOrder) error {
+	if len(b) < 3 {
+		return errBufferTooSmall(3, len(b))
+	}
+	var tmp [4]byte
+	order.PutUint32(tmp[:], uint32(v))
+	if order == binary.LittleEndian {
+		copy(b, tmp[:3])
+	} else {
+		copy(b, tmp[1:])
+	}
+	return nil
+}
+
+// getNumber24 reads the 24-bit integer, the int24 value is sign-extended
+func getNumber24[T ~int32 | ~uint32](b []byte, res *T, order binary.ByteOrder) error {
+	if len(b) < 3 {
+		return errBufferTooSmall(3, len(b))
+	}
+	var tmp [4]byte
+	if order == binary.LittleEndian {
+		copy(tmp[:3], b)
+	} else {
+		copy(tmp[1:], b)
+	}
+	// the right shift of the signed type copies the sign bit
+	*res = T(order.Uint32(tmp[:])<<8) >> 8
+	return nil
+}
+
+func putSlice24[T ~int32 | ~uint32](b []byte, s []T, order binary.ByteOrder) error {
+	if len(b) < 3*len(s) {
+		return errBufferTooSmall(3*len(s), len(b))
+	}
+	for _, val := range s {
+		if err := putNumber24(b, val, order); err != nil {
+			return err
+		}
+		b = b[3:]
+	}
+	return nil
+}
+
+func getSlice24[T ~int32 | ~uint32](b []byte, s []T, order binary.ByteOrder) error {
+	if len(b) < 3*len(s) {
+		return errBufferTooSmall(3*len(s), len(b))
+	}
+	for i := range s {
+		if err := getNumber24(b, &s[i], order); err != nil {
+			return err
+		}
+		b = b[3:]
+	}
+	return nil
+}
+{{- end}}
+
+{{- if .Float16}}
+
+// float16Bits returns the IEEE 754 half-precision bits of the value rounded to the nearest even,
+// the values too large for the half precision become infinities and the too small ones zeros
+func float16Bits(v float32) uint16 {
+	bits := math.Float32bits(v)
+	sign := uint16(bits>>16) & 0x8000
+	exp := int(bits>>23&0xff) - 127 + 15
+	mant := bits & 0x7fffff
+	switch {
+	case bits&0x7fffffff > 0x7f800000:
+		return sign | 0x7e00
+	case exp >= 0x1f:
+		return sign | 0x7c00
+	case exp <= 0:
+		if exp < -10 {
+			return sign
+		}
+		// the subnormal keeps the implicit leading bit in the mantissa
+		mant |= 0x800000
+		shift := uint(14 - exp)
+		h := mant >> shift
+		rem, half := mant&(1<<shift-1), uint32(1)<<(shift-1)
+		if rem > half || rem == half && h&1 == 1 {
+			h++
+		}
+		return sign | uint16(h)
+	}
+	// the rounding carry may overflow the mantissa to the exponent, which is still correct
+	h := uint32(exp)<<10 | mant>>13
+	if rem := mant & 0x1fff; rem > 0x1000 || rem == 0x1000 && h&1 == 1 {
+		h++
+	}
+	return sign | uint16(h)
+}
+
+// float16FromBits returns the value of the IEEE 754 half-precision bits
+func float16FromBits(h uint16) float32 {
+	sign := uint32(h&0x8000) << 16
+	exp := uint32(h>>10) & 0x1f
+	mant := uint32(h) & 0x3ff
+	switch exp {
+	case 0:
+		// the subnormals are mant * 2^-24, they are normal numbers in float32
+		return math.Float32frombits(sign | math.Float32bits(float32(mant)/(1<<24)))
+	case 0x1f:
+		return math.Float32frombits(sign | 0x7f800000 | mant<<13)
+	default:
+		return math.Float32frombits(sign | (exp+127-15)<<23 | mant<<13)
+	}
+}
+
+// putFloat16 writes the value as 2 bytes of the IEEE 754 half precision
+func putFloat16[T ~float32](b []byte, v T, order binary.ByteOrder) error {
+	return putNumberOrder(b, float16Bits(float32(v)), order)
+}
+
+// getFloat16 reads the IEEE 754 half-precision value
+func getFloat16[T ~float32](b []byte, res *T, order binary.ByteOrder) error {
+	var bits uint16
+	if err := getNumberOrder(b, &bits, order); err != nil {
+		return err
+	}
+	*res = T(float16FromBits(bits))
+	return nil
+}
+
+func putFloat16Slice[T ~float32](b []byte, s []T, order binary.ByteOrder) error {
+	if len(b) < 2*len(s) {
+		return errBufferTooSmall(2*len(s), len(b))
+	}
+	for _, val := range s {
+		if err := putFloat16(b, val, order); err != nil {
+			return err
+		}
+		b = b[2:]
+	}
+	return nil
 }
 
-{{- range .Constants}}
-{{range .Doc}}{{.}}
-{{end -}}
-const {{.Name}} {{.Type}} = {{.Value}}
+func getFloat16Slice[T ~float32](b []byte, s []T, order binary.ByteOrder) error {
+	if len(b) < 2*len(s) {
+		return errBufferTooSmall(2*len(s), len(b))
+	}
+	for i := range s {
+		if err := getFloat16(b, &s[i], order); err != nil {
+			return err
+		}
+		b = b[2:]
+	}
+	return nil
+}
 {{- end}}
 
-{{- range .Fields}}
-{{- range .BitMasks}}
-{{.}}
-{{- end}}
-{{- end}}
+{{- if index .CRCs "ccitt"}}
+
+// crc16Ccitt returns the CRC-16/CCITT-FALSE checksum of data
+func crc16Ccitt(data []byte) uint16 {
+	crc := uint16(0xffff)
+	for _, b := range data {
+		crc ^= uint16(b) << 8
+		for range 8 {
+			if crc&0x8000 != 0 {
+				crc = crc<<1 ^ 0x1021
+			} else {
+				crc <<= 1
+			}
+		}
+	}
+	return crc
+}
 {{- end}}
 
+{{- if index .CRCs "modbus"}}
 
-{{- range .Registers}}
-{{ $regName := .Name }}
-// ================= {{.Name}} implementation =================
-// The {{.Name}} register's ID
-func (r *{{.Name}}) ID() uint8 {
-	return {{.ID}}
+// crc16Modbus returns the CRC-16/MODBUS checksum of data
+func crc16Modbus(data []byte) uint16 {
+	crc := uint16(0xffff)
+	for _, b := range data {
+		crc ^= uint16(b)
+		for range 8 {
+			if crc&1 != 0 {
+				crc = crc>>1 ^ 0xa001
+			} else {
+				crc >>= 1
+			}
+		}
+	}
+	return crc
 }
-
-// BufSize4Read returns the buffer size required for read fields serialization
-func (r *{{.Name}}) BufSize4Read() int {
-    size := {{.BufSize4ReadConst}}
-{{- range .Fields}}
-{{- if .IsReadable}}
-{{- if .BufSize4ReadExpr}}
-    size += {{.BufSize4ReadExpr}}
-{{- end}}
 {{- end}}
-{{- end}}
-    return size
-}
 
-// BufSize4Write returns the buffer size required for write fields serialization
-func (r *{{.Name}}) BufSize4Write() int {
-    size := {{.BufSize4WriteConst}}
-{{- range .Fields}}
-{{- if .IsWritable}}
-{{- if .BufSize4WriteExpr}}
-    size += {{.BufSize4WriteExpr}}
-{{- end}}
-{{- end}}
-{{- end}}
-    return size
+var (
+	// ErrBufferTooSmall is returned when the buffer is shorter than the encoded data
+	ErrBufferTooSmall = errors.New("buffer too small")
+	// ErrArrayLengthMismatch is returned when the variable array length differs from its size field value
+	ErrArrayLengthMismatch = errors.New("array length mismatch")
+	// ErrArrayTooLong is returned when the received variable array size exceeds MaxArrayLength
+	ErrArrayTooLong = errors.New("array too long")
+	// ErrStringTooLong is returned when the string is longer than its maximum length
+	ErrStringTooLong = errors.New("string too long")
+	// ErrOutOfRange is returned when the field value is out of its declared range
+	ErrOutOfRange = errors.New("value out of range")
+	// ErrChecksumMismatch is returned when the received checksum differs from the calculated one
+	ErrChecksumMismatch = errors.New("checksum mismatch")
+	// ErrMagicMismatch is returned when the received magic field differs from its declared value
+	ErrMagicMismatch = errors.New("magic mismatch")
+	// ErrVersionMismatch is returned when the received register version differs from the generated one
+	ErrVersionMismatch = errors.New("version mismatch")
+	// ErrUnknownRegister is returned when the register id does not belong to the device
+	ErrUnknownRegister = errors.New("unknown register")
+	// ErrTrailingBytes is returned when the data has bytes after the encoded register
+	ErrTrailingBytes = errors.New("trailing bytes")
+)
+
+// MaxArrayLength is the maximum number of the variable array elements the deserialization accepts.
+// The array with the larger size field value is rejected before it is allocated, so the corrupted
+// or hostile data cannot exhaust the memory. It may be changed before decoding the longer arrays
+var MaxArrayLength = 65535
+
+// FieldError is the error of the register field, it wraps one of the Err errors above,
+// so errors.Is can be used to find out what is wrong with the field
+type FieldError struct {
+	Register string
+	Field    string
+	Err      error
 }
 
-// Check validates the consistency of variable-length arrays with their size fields
-func (r *{{.Name}}) Check() error {
-{{- range .Fields}}
-{{- range .ConsistencyChecks}}
-    {{.}}
-{{- end}}
-{{- end}}
-    return nil
+func (e *FieldError) Error() string {
+	return fmt.Sprintf("%s.%s: %v", e.Register, e.Field, e.Err)
 }
 
-// SerializeRead serializes read data to the wire buffer
-func (r *{{.Name}}) SerializeRead(buf []byte) (int, error) {
-    if err := r.Check(); err != nil {
-        return 0, err
-    }
-    offset := 0
-{{- range .Fields}}{{- if .SerializeReadData}}
-    {{range .SerializeReadData}}{{.}}
-    {{end -}}
-{{- end}}{{- end}}
-    return offset, nil
+func (e *FieldError) Unwrap() error {
+	return e.Err
 }
 
-// SerializeWrite serializes write data to the wire buffer
-func (r *{{.Name}}) SerializeWrite(buf []byte) (int, error) {
-    if err := r.Check(); err != nil {
-        return 0, err
-    }
-    offset := 0
-{{- range .Fields}}{{- if .SerializeWriteData}}
-    {{range .SerializeWriteData}}{{.}}
-    {{end -}}
-{{- end}}{{- end}}
-    return offset, nil
+// bufferTooSmallError is returned when the buffer is shorter than the encoded data,
+// it matches ErrBufferTooSmall
+type bufferTooSmallError struct {
+	need, have int
 }
 
-// DeserializeRead deserializes read data into the register
-func (r *{{.Name}}) DeserializeRead(buf []byte) (int, error) {
-    offset := 0
-{{- range .Fields}}{{- if .DeserializeReadData}}
-    {{range .DeserializeReadData}}{{.}}
-    {{end -}}
-{{- end}}{{- end}}
-    return offset, nil
+func (e *bufferTooSmallError) Error() string {
+	return fmt.Sprintf("buffer too small: need %d bytes, have %d", e.need, e.have)
 }
 
-// DeserializeWrite deserializes write data into the register
-func (r *{{.Name}}) DeserializeWrite(buf []byte) (int, error) {
-    offset := 0
-{{- range .Fields}}{{- if .DeserializeWriteData}}
-    {{range .DeserializeWriteData}}{{.}}
-    {{end -}}
-{{- end}}{{- end}}
-    return offset, nil
+func (e *bufferTooSmallError) Unwrap() error {
+	return ErrBufferTooSmall
 }
 
-{{- range .Fields}}
-// Get{{.CapitalizedName}} returns value for {{.Name}}
-func (r *{{$regName}}) Get{{.CapitalizedName}}() {{.Type}} {
-    return r.{{.Name}}
+func errBufferTooSmall(need, have int) error {
+	return &bufferTooSmallError{need: need, have: have}
 }
 
-// Set{{.CapitalizedName}} sets value for {{.Name}}
-func (r *{{$regName}}) Set{{.CapitalizedName}}(v {{.Type}}) {
-    r.{{.Name}} = v
+// appendRegister extends b by size bytes, serializes the register into them and returns
+// b with the serialized data, b is returned unchanged if the serialization fails
+func appendRegister(b []byte, size int, serialize func([]byte) (int, error)) ([]byte, error) {
+	start := len(b)
+	b = append(b, make([]byte, size)...)
+	n, err := serialize(b[start:])
+	if err != nil {
+		return b[:start], err
+	}
+	return b[:start+n], nil
 }
-{{- end}}
 
-{{- end}}
+// readRegister reads the register from rd by portions: it decodes the data read so far
+// and, if the data is not complete, reads the number of missing bytes the decoder reported
+func readRegister(rd io.Reader, decode func([]byte) (int, error)) (int64, error) {
+	var buf []byte
+	for {
+		_, err := decode(buf)
+		var tooSmall *bufferTooSmallError
+		if !errors.As(err, &tooSmall) || tooSmall.need <= tooSmall.have {
+			return int64(len(buf)), err
+		}
+		start := len(buf)
+		buf = append(buf, make([]byte, tooSmall.need-tooSmall.have)...)
+		if n, err := io.ReadFull(rd, buf[start:]); err != nil {
+			return int64(start + n), err
+		}
+	}
+}
 
 type Integer interface {
 	~int8 | ~int16 | ~int32 | ~int64 | ~uint8 | ~uint16 | ~uint32 | ~uint64
 }
 
+type Float interface {
+	~float32 | ~float64
+}
+
 func putNumber[T Integer](b []byte, v T) error {
+	return putNumberOrder(b, v, binary.BigEndian)
+}
+
+func getNumber[T Integer](b []byte, res *T) error {
+	return getNumberOrder(b, res, binary.BigEndian)
+}
+
+func putFloat[T Float](b []byte, v T) error {
+	return putFloatOrder(b, v, binary.BigEndian)
+}
+
+func getFloat[T Float](b []byte, res *T) error {
+	return getFloatOrder(b, res, binary.BigEndian)
+}
+
+func putSlice[T Integer](b []byte, s []T) error {
+	return putSliceOrder(b, s, binary.BigEndian)
+}
+
+func getSlice[T Integer](b []byte, s []T) error {
+	return getSliceOrder(b, s, binary.BigEndian)
+}
+
+func putFloatSlice[T Float](b []byte, s []T) error {
+	return putFloatSliceOrder(b, s, binary.BigEndian)
+}
+
+func getFloatSlice[T Float](b []byte, s []T) error {
+	return getFloatSliceOrder(b, s, binary.BigEndian)
+}
+
+func putNumberOrder[T Integer](b []byte, v T, order binary.ByteOrder) error {
 	size := binary.Size(v)
 	if len(b) < size {
-		return fmt.Errorf("buffer too small: need %d bytes, have %d", size, len(b))
+		return errBufferTooSmall(size, len(b))
 	}
 	
 	switch size {
 	case 1:
 		b[0] = byte(v)
 	case 2:
-		binary.BigEndian.PutUint16(b, uint16(v))
+		order.PutUint16(b, uint16(v))
 	case 4:
-		binary.BigEndian.PutUint32(b, uint32(v))
+		order.PutUint32(b, uint32(v))
 	case 8:
-		binary.BigEndian.PutUint64(b, uint64(v))
+		order.PutUint64(b, uint64(v))
 	default:
 		return fmt.Errorf("unsupported type size: %d", size)
 	}
 	return nil
 }
 
-func getNumber[T Integer](b []byte, res *T) error {
+func getNumberOrder[T Integer](b []byte, res *T, order binary.ByteOrder) error {
 	size := binary.Size(*res)
 	if len(b) < size {
-		return fmt.Errorf("buffer too small: need %d bytes, have %d", size, len(b))
+		return errBufferTooSmall(size, len(b))
 	}
 	
 	switch size {
 	case 1:
 		*res = T(b[0])
 	case 2:
-		*res = T(binary.BigEndian.Uint16(b))
+		*res = T(order.Uint16(b))
 	case 4:
-		*res = T(binary.BigEndian.Uint32(b))
+		*res = T(order.Uint32(b))
 	case 8:
-		*res = T(binary.BigEndian.Uint64(b))
+		*res = T(order.Uint64(b))
 	default:
 		return fmt.Errorf("unsupported type size: %d", size)
 	}
 	return nil
 }
 
-func putSlice[T Integer](b []byte, s []T) error {
+func putFloatOrder[T Float](b []byte, v T, order binary.ByteOrder) error {
+	switch size := binary.Size(v); size {
+	case 4:
+		return putNumberOrder(b, math.Float32bits(float32(v)), order)
+	case 8:
+		return putNumberOrder(b, math.Float64bits(float64(v)), order)
+	default:
+		return fmt.Errorf("unsupported type size: %d", size)
+	}
+}
+
+func getFloatOrder[T Float](b []byte, res *T, order binary.ByteOrder) error {
+	switch size := binary.Size(*res); size {
+	case 4:
+		var bits uint32
+		if err := getNumberOrder(b, &bits, order); err != nil {
+			return err
+		}
+		*res = T(math.Float32frombits(bits))
+	case 8:
+		var bits uint64
+		if err := getNumberOrder(b, &bits, order); err != nil {
+			return err
+		}
+		*res = T(math.Float64frombits(bits))
+	default:
+		return fmt.Errorf("unsupported type size: %d", size)
+	}
+	return nil
+}
+
+func putSliceOrder[T Integer](b []byte, s []T, order binary.ByteOrder) error {
 	if len(s) == 0 {
 		return nil
 	}
@@ -209,27 +1278,28 @@ func putSlice[T Integer](b []byte, s []T) error {
 	size := binary.Size(s[0])
 	totalSize := size * len(s)
 	if len(b) < totalSize {
-		return fmt.Errorf("buffer too small: need %d bytes, have %d", totalSize, len(b))
+		return errBufferTooSmall(totalSize, len(b))
 	}
 	
 	switch size {
 	case 1:
-		for i, val := range s {
-			b[i] = byte(val)
+		for _, val := range s {
+			b[0] = byte(val)
+			b = b[1:]
 		}
 	case 2:
 		for _, val := range s {
-			binary.BigEndian.PutUint16(b, uint16(val))
+			order.PutUint16(b, uint16(val))
 			b = b[2:]
 		}
 	case 4:
 		for _, val := range s {
-			binary.BigEndian.PutUint32(b, uint32(val))
+			order.PutUint32(b, uint32(val))
 			b = b[4:]
 		}
 	case 8:
 		for _, val := range s {
-			binary.BigEndian.PutUint64(b, uint64(val))
+			order.PutUint64(b, uint64(val))
 			b = b[8:]
 		}
 	default:
@@ -238,7 +1308,7 @@ func putSlice[T Integer](b []byte, s []T) error {
 	return nil
 }
 
-func getSlice[T Integer](b []byte, s []T) error {
+func getSliceOrder[T Integer](b []byte, s []T, order binary.ByteOrder) error {
 	if len(s) == 0 {
 		return nil
 	}
@@ -246,27 +1316,28 @@ func getSlice[T Integer](b []byte, s []T) error {
 	size := binary.Size(s[0])
 	totalSize := size * len(s)
 	if len(b) < totalSize {
-		return fmt.Errorf("buffer too small: need %d bytes, have %d", totalSize, len(b))
+		return errBufferTooSmall(totalSize, len(b))
 	}
 	
 	switch size {
 	case 1:
 		for i := range s {
-			s[i] = T(b[i])
+			s[i] = T(b[0])
+			b = b[1:]
 		}
 	case 2:
 		for i := range s {
-			s[i] = T(binary.BigEndian.Uint16(b))
+			s[i] = T(order.Uint16(b))
 			b = b[2:]
 		}
 	case 4:
 		for i := range s {
-			s[i] = T(binary.BigEndian.Uint32(b))
+			s[i] = T(order.Uint32(b))
 			b = b[4:]
 		}
 	case 8:
 		for i := range s {
-			s[i] = T(binary.BigEndian.Uint64(b))
+			s[i] = T(order.Uint64(b))
 			b = b[8:]
 		}
 	default:
@@ -274,22 +1345,148 @@ func getSlice[T Integer](b []byte, s []T) error {
 	}
 	return nil
 }
+
+func putFloatSliceOrder[T Float](b []byte, s []T, order binary.ByteOrder) error {
+	if len(s) == 0 {
+		return nil
+	}
+
+	size := binary.Size(s[0])
+	totalSize := size * len(s)
+	if len(b) < totalSize {
+		return errBufferTooSmall(totalSize, len(b))
+	}
+
+	for _, val := range s {
+		if err := putFloatOrder(b, val, order); err != nil {
+			return err
+		}
+		b = b[size:]
+	}
+	return nil
+}
+
+func getFloatSliceOrder[T Float](b []byte, s []T, order binary.ByteOrder) error {
+	if len(s) == 0 {
+		return nil
+	}
+
+	size := binary.Size(s[0])
+	totalSize := size * len(s)
+	if len(b) < totalSize {
+		return errBufferTooSmall(totalSize, len(b))
+	}
+
+	for i := range s {
+		if err := getFloatOrder(b, &s[i], order); err != nil {
+			return err
+		}
+		b = b[size:]
+	}
+	return nil
+}
 `
 
+// goTpl is cloned for every generation to bind the ident function to the options
+var goTpl = template.Must(template.New("go").Funcs(template.FuncMap{"ident": GoOptions{}.goIdent}).Parse(goTemplate))
+
+// GoOptions contains the optional features of the Go generator
+type GoOptions struct {
+	// Decoder enables DecodeRegister and DecodeReadRegister functions, which
+	// decode a register by its ID, and DecodeAll function decoding the concatenated registers
+	Decoder bool
+	// BitfieldStrings enables <Field>String methods formatting the bit field members
+	BitfieldStrings bool
+	// ReuseSlices makes the deserialization reuse the variable arrays capacity instead
+	// of allocating new slices on every call
+	ReuseSlices bool
+	// JSON enables MarshalJSON and UnmarshalJSON methods using the .pa field names
+	JSON bool
+	// Framing enables FrameWrite and FrameRead functions, which write and read the
+	// registers as the length-prefixed frames
+	Framing bool
+	// FrameCRC appends the CRC-16/CCITT checksum to the frames, requires Framing
+	FrameCRC bool
+	// Pool enables Acquire<Register> and Release<Register> functions, which reuse the
+	// registers via sync.Pool
+	Pool bool
+	// Unexported starts the names of the registers, enums, constants, accessors and the functions
+	// creating, decoding and framing the registers with a lowercase letter, so the generated code
+	// is confined to its package. The shared runtime errors stay exported
+	Unexported bool
+}
+
+// goIdent joins the parts into the Go identifier, which is unexported with the Unexported option
+func (o GoOptions) goIdent(parts ...string) string {
+	name := strings.Join(parts, "")
+	if o.Unexported {
+		return strings.ToLower(name[:1]) + name[1:]
+	}
+	return name
+}
+
 type GoDevice struct {
+	GoOptions
 	Doc       []string
 	Package   string
+	Imports   []string        // imports required by the optional features
+	RefArrays bool            // true if any field is an array of registers
+	Int24     bool            // true if any field is a 24-bit integer
+	Float16   bool            // true if any field is a half-precision number
+	CRCs      map[string]bool // checksum algorithms with the runtime helpers the registers use
+	Versions  bool            // true if any register has the wire version
+	Constants []GoConstant
+	Enums     []GoEnum
 	Registers []GoRegister
+	Messages  []GoMessage
+}
+
+type GoEnum struct {
+	Doc     []string
+	Name    string
+	Base    string
+	Members []GoEnumMember
+}
+
+type GoEnumMember struct {
+	Doc   []string
+	Name  string
+	Value string
 }
 
 type GoRegister struct {
 	Name               string
+	Type               string // Go type name of the register
 	ID                 uint8
 	Doc                []string
 	Constants          []GoConstant
 	Fields             []GoField
 	BufSize4ReadConst  int
 	BufSize4WriteConst int
+	SizeConst          int    // constant part of Size(), the read-write fields are counted once
+	ReadOnly           bool   // the register has only read fields
+	PoolVar            string // name of the sync.Pool variable keeping the released registers
+	HasDefaults        bool   // the new register has the default field values, so it is not zeroed
+	Version            string // name of the wire version constant, empty if the register has no version
+	VersionValue       uint8
+	VersionRead        bool // the version byte precedes the read fields
+	VersionWrite       bool // the version byte precedes the write fields
+}
+
+// GoMessage is the struct of the registers sent one after another
+type GoMessage struct {
+	Name        string
+	Type        string // Go type name of the message
+	Doc         []string
+	Members     []GoMessageMember
+	HasDefaults bool // a register of the message has the default field values, so the new message is not zeroed
+}
+
+type GoMessageMember struct {
+	Doc      []string
+	Name     string // the struct member name, it is the register name
+	Type     string // Go type name of the register
+	Trailing string
 }
 
 type GoConstant struct {
@@ -313,51 +1510,306 @@ type GoField struct {
 	DeserializeReadData  []string // Code for DeserializeRead function
 	DeserializeWriteData []string // Code for DeserializeWrite function
 	Trailing             string
+	Tag                  string   // Struct tag with the .pa name, the declaration order and the access
 	BufSize4ReadExpr     string   // Expression for variable size (empty if constant)
 	BufSize4WriteExpr    string   // Expression for variable size (empty if constant)
+	SizeExpr             string   // Expression for variable size in Size() (empty if constant)
 	ConsistencyChecks    []string // Checks for variable-length arrays
+	StringData           []string // Code formatting the bit field members
+	BitMembers           []GoBitMember
+	Reserved             bool     // The field has no struct member and accessors, e.g. reserved bytes or a checksum
+	SizeField            string   // Size field name of the variable array
+	SizeUpdate           string   // Code setting the variable array length to its size field
+	CloneData            []string // Code deep copying the field in Clone
+	ResetData            []string // Code zeroing the field in Reset
+	NotEqualExpr         string   // Condition which is true if the field differs in Equal
+	Condition            string   // Expression which is true if the conditional field is sent, empty for the other fields
+	AccessorDoc          []string // The field comments continuing the doc comments of its accessors
+	JSONType             string   // Type of the field in the JSON form, empty if the field is not encoded
+	JSONValue            string   // Expression converting the field to the JSON form
+	JSONAssign           []string // Code assigning the field from the JSON form
+	FixedScale           string   // 2^frac of the fixed-point field, empty for the other fields
+}
+
+type GoBitMember struct {
+	Name            string
+	CapitalizedName string
+	Type            string // type of the member value, the signed members have the signed type of the field size
+	Mask            string // name of the bit mask constant
+	Shift           int
+	Single          bool // true if the member is a single bit
+	Signed          bool // true if the member value is sign-extended
+	TopShift        int  // left shift moving the member highest bit to the highest bit of the field
+	SignShift       int  // arithmetic right shift sign-extending the member moved by TopShift
 }
 
 func GenerateGo(dev *parser.Device, pkg string) (string, error) {
-	tpl, err := template.New("go").Parse(goTemplate)
+	return GenerateGoWithOptions(dev, pkg, GoOptions{})
+}
+
+// GenerateGoWithOptions generates the Go code for the device with the optional features enabled
+func GenerateGoWithOptions(dev *parser.Device, pkg string, opts GoOptions) (string, error) {
+	if opts.FrameCRC && !opts.Framing {
+		return "", fmt.Errorf("the frame checksum requires the framing")
+	}
+	out, err := newGoDevice(dev, pkg, opts)
 	if err != nil {
 		return "", err
 	}
 
-	out := GoDevice{Package: pkg}
-	out.Doc = flattenComments(dev.Doc)
+	var buf bytes.Buffer
+	tpl := template.Must(goTpl.Clone()).Funcs(template.FuncMap{"ident": opts.goIdent})
+	if err := tpl.Execute(&buf, out); err != nil {
+		return "", err
+	}
+	src, err := format.Source(buf.Bytes())
+	if err != nil {
+		return "", fmt.Errorf("could not format the generated Go code: %w", err)
+	}
+	return string(src), nil
+}
+
+// newGoDevice returns the template data of the device Go code
+func newGoDevice(dev *parser.Device, pkg string, opts GoOptions) (GoDevice, error) {
+	out := GoDevice{GoOptions: opts, Package: pkg}
+	if opts.FrameCRC {
+		out.goCRCFunc(&parser.CRCType{Kind: "crc16", Algorithm: "ccitt"})
+	}
+	out.Doc = declComments(dev.Doc, dev.TrailingComment)
+
+	// The device constants are exported like the register ones, so their names cannot collide
+	// with the types which differ in the case of the first letter only. None of the names may be
+	// a Go keyword or a predeclared identifier, e.g. the unexported register Map is map
+	goNames := make(map[string]bool)
+	for _, r := range dev.Registers {
+		name := opts.goIdent(r.Name)
+		if err := checkGoName("register", r.Name, name); err != nil {
+			return GoDevice{}, err
+		}
+		goNames[name] = true
+	}
+	for _, e := range dev.Enums {
+		name := opts.goIdent(e.Name)
+		if err := checkGoName("enum", e.Name, name); err != nil {
+			return GoDevice{}, err
+		}
+		goNames[name] = true
+	}
+	for _, m := range dev.Messages {
+		name := opts.goIdent(m.Name)
+		if err := checkGoName("message", m.Name, name); err != nil {
+			return GoDevice{}, err
+		}
+		goNames[name] = true
+	}
+	for _, c := range dev.Constants {
+		name := opts.goIdent(strings.ToUpper(c.Name[:1]), c.Name[1:])
+		if err := checkGoName("device constant", c.Name, name); err != nil {
+			return GoDevice{}, err
+		}
+		if goNames[name] {
+			return GoDevice{}, fmt.Errorf("device constant '%s' has the Go name %s of another declaration", c.Name, name)
+		}
+		goNames[name] = true
+		out.Constants = append(out.Constants, GoConstant{
+			Doc:   declComments(c.Doc, c.TrailingComment),
+			Name:  name,
+			Type:  toGoTypes(c.Type.Name),
+			Value: c.ValueStr,
+		})
+	}
+	// The constants are generated in the const block, the empty lines keep apart only the inner constants
+	if len(out.Constants) > 0 {
+		out.Constants[0].Doc = trimLeadingEmptyLines(out.Constants[0].Doc)
+	}
+
+	for _, e := range dev.Enums {
+		ge := GoEnum{
+			Doc:  declComments(e.Doc, e.TrailingComment),
+			Name: opts.goIdent(e.Name),
+			Base: toGoTypes(e.Base),
+		}
+		for _, m := range e.Members {
+			ge.Members = append(ge.Members, GoEnumMember{
+				Doc:   declComments(m.Doc, m.TrailingComment),
+				Name:  m.Name,
+				Value: m.ValueStr,
+			})
+		}
+		out.Enums = append(out.Enums, ge)
+	}
 
 	for _, reg := range dev.Registers {
 		gr := GoRegister{
-			Name: reg.Name,
-			ID:   uint8(reg.Number()),
-			Doc:  flattenComments(reg.Doc),
+			Name:        reg.Name,
+			Type:        opts.goIdent(reg.Name),
+			ID:          uint8(reg.Number()),
+			Doc:         declComments(reg.Doc, reg.TrailingComment),
+			ReadOnly:    reg.Specifier == "r",
+			PoolVar:     strings.ToLower(reg.Name[:1]) + reg.Name[1:] + "Pool",
+			HasDefaults: hasDefaults(dev, reg),
+		}
+		if reg.HasVersion() {
+			// the version is sent in the directions the register is sent in, it is counted once in Size()
+			gr.Version = opts.goIdent("Reg", reg.Name, "Version")
+			gr.VersionValue = reg.Version()
+			gr.VersionRead, gr.VersionWrite = reg.Specifier != "w", reg.Specifier != "r"
+			if gr.VersionRead {
+				gr.BufSize4ReadConst = 1
+			}
+			if gr.VersionWrite {
+				gr.BufSize4WriteConst = 1
+			}
+			gr.SizeConst = 1
+			out.Versions = true
 		}
 
 		// Process constants
 		for _, c := range reg.Body.Constants() {
 			gc := GoConstant{
-				Doc:   flattenComments(c.Doc),
-				Name:  fmt.Sprintf("%s_%s", reg.Name, c.Name),
+				Doc:   declComments(c.Doc, c.TrailingComment),
+				Name:  fmt.Sprintf("%s_%s", gr.Type, c.Name),
 				Type:  toGoTypes(c.Type.Name),
 				Value: c.ValueStr,
 			}
 			gr.Constants = append(gr.Constants, gc)
 		}
+		if len(gr.Constants) > 0 {
+			gr.Constants[0].Doc = trimLeadingEmptyLines(gr.Constants[0].Doc)
+		}
+
+		for i, f := range reg.Body.Fields() {
+			// the fields are selected by their names, so only the keywords cannot be the names
+			if goKeywords[f.Name] {
+				return GoDevice{}, fmt.Errorf("field '%s' in register '%s' is a Go keyword", f.Name, reg.Name)
+			}
+			gf := GoField{
+				Doc:             flattenComments(f.Doc),
+				Name:            f.Name,
+				CapitalizedName: cases.Title(language.English).String(f.Name),
+				Trailing:        safeString(f.TrailingComment),
+				AccessorDoc:     goAccessorDoc(f),
+				IsReadable:      f.Specifier == "r" || f.Specifier == "",
+				IsWritable:      f.Specifier == "w" || f.Specifier == "",
+			}
+
+			readConst, writeConst := gr.BufSize4ReadConst, gr.BufSize4WriteConst
+
+			// The field placed at the offset is preceded by the zero bytes filling the gap
+			if pad := reg.Padding(f, true); pad > 0 {
+				serCode, deserCode := goPaddingCode(pad)
+				gf.SerializeReadData = append(gf.SerializeReadData, serCode...)
+				gf.DeserializeReadData = append(gf.DeserializeReadData, deserCode...)
+				gr.BufSize4ReadConst += pad
+			}
+			if pad := reg.Padding(f, false); pad > 0 {
+				serCode, deserCode := goPaddingCode(pad)
+				gf.SerializeWriteData = append(gf.SerializeWriteData, serCode...)
+				gf.DeserializeWriteData = append(gf.DeserializeWriteData, deserCode...)
+				gr.BufSize4WriteConst += pad
+			}
+
+			switch {
+			case f.Reserved:
+				size := reservedSize(f)
+				gf.Reserved = true
+				gf.Decl = fmt.Sprintf("// reserved %d byte(s)", size)
+				serCode, deserCode := goPaddingCode(size)
+
+				// Reserved bytes are zeros on the wire, they are skipped when deserializing
+				if gf.IsReadable {
+					gf.SerializeReadData = append(gf.SerializeReadData, serCode...)
+					gf.DeserializeReadData = append(gf.DeserializeReadData, deserCode...)
+					gr.BufSize4ReadConst += size
+				}
+				if gf.IsWritable {
+					gf.SerializeWriteData = append(gf.SerializeWriteData, serCode...)
+					gf.DeserializeWriteData = append(gf.DeserializeWriteData, deserCode...)
+					gr.BufSize4WriteConst += size
+				}
+
+			case f.Type.CRC != nil:
+				// the checksum is calculated over the bytes preceding it, so it has no value to keep
+				base := f.Type.CRC.BaseType()
+				crcFn := out.goCRCFunc(f.Type.CRC)
+				size := typeSize(base)
+				gf.Reserved = true
+				gf.Decl = fmt.Sprintf("// %s: %s checksum of the preceding bytes", f.Name, f.Type.CRC.Kind)
+				putFn, getFn, order := goScalarFuncs(base, f.IsLittleEndian())
+				serCode := []string{
+					fmt.Sprintf("if err := %s(buf[offset:], %s(buf[:offset])%s); err != nil {", putFn, crcFn, order),
+					"    return offset, err",
+					"}",
+					fmt.Sprintf("offset += %d", size),
+				}
+				deserCode := []string{
+					"{",
+					fmt.Sprintf("    var crc %s", base),
+					fmt.Sprintf("    if err := %s(buf[offset:], &crc%s); err != nil {", getFn, order),
+					"        return offset, err",
+					"    }",
+					fmt.Sprintf("    if expected := %s(buf[:offset]); crc != expected {", crcFn),
+					fmt.Sprintf("        return offset, &FieldError{Register: %q, Field: %q, Err: fmt.Errorf(\"%%w: received 0x%%x, calculated 0x%%x\", ErrChecksumMismatch, crc, expected)}",
+						reg.Name, f.Name),
+					"    }",
+					"}",
+					fmt.Sprintf("offset += %d", size),
+				}
+				if gf.IsReadable {
+					gf.SerializeReadData = append(gf.SerializeReadData, serCode...)
+					gf.DeserializeReadData = append(gf.DeserializeReadData, deserCode...)
+					gr.BufSize4ReadConst += size
+				}
+				if gf.IsWritable {
+					gf.SerializeWriteData = append(gf.SerializeWriteData, serCode...)
+					gf.DeserializeWriteData = append(gf.DeserializeWriteData, deserCode...)
+					gr.BufSize4WriteConst += size
+				}
 
-		for _, f := range reg.Body.Fields() {
-			gf := GoField{
-				Doc:             flattenComments(f.Doc),
-				Name:            f.Name,
-				CapitalizedName: cases.Title(language.English).String(f.Name),
-				Trailing:        safeString(f.TrailingComment),
-				IsReadable:      f.Specifier == "r" || f.Specifier == "",
-				IsWritable:      f.Specifier == "w" || f.Specifier == "",
-			}
+			case f.IsMagic():
+				// the magic value is always the same, so it has no value to keep
+				typ := f.Type.Simple.Name
+				elem := toGoTypes(typ)
+				size := wireTypeSize(typ)
+				magic := magicLiteral(f)
+				gf.Reserved = true
+				gf.Decl = fmt.Sprintf("// %s: magic %s", f.Name, magic)
+				putFn, getFn, order := goScalarFuncs(elem, f.IsLittleEndian())
+				if is24BitType(typ) {
+					putFn, getFn, order = out.goInt24Funcs(false, f.IsLittleEndian())
+				}
+				serCode := []string{
+					fmt.Sprintf("if err := %s(buf[offset:], %s(%s)%s); err != nil {", putFn, elem, magic, order),
+					"    return offset, err",
+					"}",
+					fmt.Sprintf("offset += %d", size),
+				}
+				deserCode := []string{
+					"{",
+					fmt.Sprintf("    var magic %s", elem),
+					fmt.Sprintf("    if err := %s(buf[offset:], &magic%s); err != nil {", getFn, order),
+					"        return offset, err",
+					"    }",
+					fmt.Sprintf("    if magic != %s {", magic),
+					fmt.Sprintf("        return offset, &FieldError{Register: %q, Field: %q, Err: fmt.Errorf(\"%%w: received 0x%%x, expected 0x%%x\", ErrMagicMismatch, magic, %s(%s))}",
+						reg.Name, f.Name, elem, magic),
+					"    }",
+					"}",
+					fmt.Sprintf("offset += %d", size),
+				}
+				if gf.IsReadable {
+					gf.SerializeReadData = append(gf.SerializeReadData, serCode...)
+					gf.DeserializeReadData = append(gf.DeserializeReadData, deserCode...)
+					gr.BufSize4ReadConst += size
+				}
+				if gf.IsWritable {
+					gf.SerializeWriteData = append(gf.SerializeWriteData, serCode...)
+					gf.DeserializeWriteData = append(gf.DeserializeWriteData, deserCode...)
+					gr.BufSize4WriteConst += size
+				}
 
-			switch {
 			case f.Type.Simple != nil && f.Type.Simple.IsRegisterRef():
-				refRegName := f.Type.Simple.Name
+				refRegName := opts.goIdent(f.Type.Simple.Name)
 				gf.Type = refRegName
 				gf.Decl = fmt.Sprintf("%s %s", f.Name, refRegName)
 
@@ -392,12 +1844,52 @@ func GenerateGo(dev *parser.Device, pkg string) (string, error) {
 						"}")
 					gf.BufSize4WriteExpr = fmt.Sprintf("r.%s.BufSize4Write()", f.Name)
 				}
+				if gf.IsReadable && gf.IsWritable {
+					// the nested register fields may be sent in one direction only
+					gf.SizeExpr = fmt.Sprintf("r.%s.Size()", f.Name)
+				}
+				gf.CloneData = append(gf.CloneData, fmt.Sprintf("c.%s = *r.%s.Clone()", f.Name, f.Name))
+
+			case f.Type.Simple != nil && f.Type.Simple.IsEnum():
+				gf.Type = opts.goIdent(f.Type.Simple.Name)
+				gf.Decl = fmt.Sprintf("%s %s", f.Name, gf.Type)
+				base := toGoTypes(f.Type.Simple.Enum.Base)
+				size := typeSize(base)
+				putFn, getFn, order := goScalarFuncs(base, f.IsLittleEndian())
+				serCode := []string{
+					fmt.Sprintf("if err := %s(buf[offset:], r.%s%s); err != nil {", putFn, f.Name, order),
+					"    return offset, err",
+					"}",
+					fmt.Sprintf("offset += %d", size),
+				}
+				deserCode := []string{
+					fmt.Sprintf("if err := %s(buf[offset:], &r.%s%s); err != nil {", getFn, f.Name, order),
+					"    return offset, err",
+					"}",
+					fmt.Sprintf("offset += %d", size),
+				}
+
+				// Enum is serialized as its base integer type
+				if gf.IsReadable {
+					gf.SerializeReadData = append(gf.SerializeReadData, serCode...)
+					gf.DeserializeReadData = append(gf.DeserializeReadData, deserCode...)
+					gr.BufSize4ReadConst += size
+				}
+				if gf.IsWritable {
+					gf.SerializeWriteData = append(gf.SerializeWriteData, serCode...)
+					gf.DeserializeWriteData = append(gf.DeserializeWriteData, deserCode...)
+					gr.BufSize4WriteConst += size
+				}
 
 			case f.Type.Bitfield != nil:
 				base := toGoTypes(f.Type.Bitfield.Base)
 				gf.Type = base
 				gf.Decl = fmt.Sprintf("%s %s", f.Name, base)
 				for _, bm := range f.Type.Bitfield.Bits {
+					if bm.Reserved {
+						// reserved members only document the unused bits
+						continue
+					}
 					// Add bit member comments
 					bmComments := flattenComments(bm.Doc)
 					for _, comment := range bmComments {
@@ -419,18 +1911,56 @@ func GenerateGo(dev *parser.Device, pkg string) (string, error) {
 					gf.BitMasks = append(gf.BitMasks,
 						fmt.Sprintf("// %s bit field (bits %s)", bm.Name, bitRange))
 					gf.BitMasks = append(gf.BitMasks,
-						fmt.Sprintf("const %s_%s_%s_bm %s = 0x%X", reg.Name,
+						fmt.Sprintf("const %s_%s_%s_bm %s = 0x%X", gr.Type,
 							f.Name, bm.Name, base, mask))
+
+					bmName := fmt.Sprintf("%s_%s_%s_bm", gr.Type, f.Name, bm.Name)
+					gbm := GoBitMember{
+						Name:            bm.Name,
+						CapitalizedName: cases.Title(language.English).String(bm.Name),
+						Type:            base,
+						Mask:            bmName,
+						Shift:           start,
+						Single:          start == end,
+						Signed:          bm.Signed,
+					}
+					if bm.Signed {
+						gbm.Type = strings.TrimPrefix(base, "u")
+						gbm.TopShift = typeSize(base)*8 - 1 - end
+						gbm.SignShift = gbm.TopShift + start
+					}
+					gf.BitMembers = append(gf.BitMembers, gbm)
+
+					if opts.BitfieldStrings {
+						if start == end {
+							gf.StringData = append(gf.StringData,
+								fmt.Sprintf("if r.%s&%s != 0 {", f.Name, bmName),
+								fmt.Sprintf("    parts = append(parts, %q)", bm.Name),
+								"}")
+						} else if bm.Signed {
+							gf.StringData = append(gf.StringData,
+								fmt.Sprintf("parts = append(parts, fmt.Sprintf(\"%s=%%d\", r.%s()))",
+									bm.Name, opts.goIdent("Get", gf.CapitalizedName, gbm.CapitalizedName)))
+						} else {
+							gf.StringData = append(gf.StringData,
+								fmt.Sprintf("parts = append(parts, fmt.Sprintf(\"%s=%%d\", (r.%s&%s)>>%d))",
+									bm.Name, f.Name, bmName, start))
+						}
+					}
+				}
+				if len(gf.StringData) > 0 {
+					out.addImport("strings")
 				}
 				size := typeSize(base)
+				putFn, getFn, order := goScalarFuncs(base, f.IsLittleEndian())
 				serCode := []string{
-					fmt.Sprintf("if err := putNumber(buf[offset:], r.%s); err != nil {", f.Name),
+					fmt.Sprintf("if err := %s(buf[offset:], r.%s%s); err != nil {", putFn, f.Name, order),
 					"    return offset, err",
 					"}",
 					fmt.Sprintf("offset += %d", size),
 				}
 				deserCode := []string{
-					fmt.Sprintf("if err := getNumber(buf[offset:], &r.%s); err != nil {", f.Name),
+					fmt.Sprintf("if err := %s(buf[offset:], &r.%s%s); err != nil {", getFn, f.Name, order),
 					"    return offset, err",
 					"}",
 					fmt.Sprintf("offset += %d", size),
@@ -448,28 +1978,111 @@ func GenerateGo(dev *parser.Device, pkg string) (string, error) {
 					gr.BufSize4WriteConst += size
 				}
 
+			case f.Type.Array != nil && f.Type.Array.Type.IsRegisterRef():
+				elem := opts.goIdent(f.Type.Array.Type.Name)
+				all := fmt.Sprintf("r.%s", f.Name)
+				var fld *parser.Field
+				var bm *parser.BitMember
+				if f.Type.Array.Size.Constant != nil {
+					gf.Type = fmt.Sprintf("[%s]%s", *f.Type.Array.Size.Constant, elem)
+					all += "[:]"
+				} else {
+					gf.Type = "[]" + elem
+					fld, bm = reg.FindFieldByName(*f.Type.Array.Size.Variable, len(gr.Fields))
+					goVarArraySizeField(&gf, &gr, f, fld, bm)
+				}
+				gf.Decl = fmt.Sprintf("%s %s", f.Name, gf.Type)
+				out.RefArrays = true
+
+				// Every element is encoded by its own register methods
+				for _, dir := range []string{"Read", "Write"} {
+					if (dir == "Read" && !gf.IsReadable) || (dir == "Write" && !gf.IsWritable) {
+						continue
+					}
+					serCode := []string{
+						fmt.Sprintf("for i := range r.%s {", f.Name),
+						fmt.Sprintf("    if n, err := r.%s[i].Serialize%s(buf[offset:]); err != nil {", f.Name, dir),
+						"        return offset, err",
+						"    } else {",
+						"        offset += n",
+						"    }",
+						"}",
+					}
+					deserCode := []string{"{"}
+					if fld != nil {
+						deserCode = append(deserCode, fmt.Sprintf("    elems := %s", goSizeFieldExpr(gr.Type, fld, bm)))
+						deserCode = append(deserCode, goSliceAlloc(reg.Name, f.Name, elem, 0, opts.ReuseSlices)...)
+					}
+					deserCode = append(deserCode,
+						fmt.Sprintf("    for i := range r.%s {", f.Name),
+						fmt.Sprintf("        if n, err := r.%s[i].Deserialize%s(buf[offset:]); err != nil {", f.Name, dir),
+						"            return offset, err",
+						"        } else {",
+						"            offset += n",
+						"        }",
+						"    }",
+						"}")
+					bufSizeExpr := fmt.Sprintf("sumBufSize(%s, (*%s).BufSize4%s)", all, elem, dir)
+					if gf.IsReadable && gf.IsWritable {
+						gf.SizeExpr = fmt.Sprintf("sumBufSize(%s, (*%s).Size)", all, elem)
+					}
+					if dir == "Read" {
+						gf.SerializeReadData = append(gf.SerializeReadData, serCode...)
+						gf.DeserializeReadData = append(gf.DeserializeReadData, deserCode...)
+						gf.BufSize4ReadExpr = bufSizeExpr
+					} else {
+						gf.SerializeWriteData = append(gf.SerializeWriteData, serCode...)
+						gf.DeserializeWriteData = append(gf.DeserializeWriteData, deserCode...)
+						gf.BufSize4WriteExpr = bufSizeExpr
+					}
+				}
+
+				if fld != nil {
+					gf.CloneData = append(gf.CloneData,
+						fmt.Sprintf("if r.%s != nil {", f.Name),
+						fmt.Sprintf("    c.%s = make([]%s, len(r.%s))", f.Name, elem, f.Name))
+				} else {
+					gf.CloneData = append(gf.CloneData, "{")
+				}
+				gf.CloneData = append(gf.CloneData,
+					fmt.Sprintf("    for i := range r.%s {", f.Name),
+					fmt.Sprintf("        c.%s[i] = *r.%s[i].Clone()", f.Name, f.Name),
+					"    }",
+					"}")
+
 			case f.Type.Array != nil && f.Type.Array.Size.Constant != nil:
 				elem := toGoTypes(f.Type.Array.Type.Name)
 				sz := *f.Type.Array.Size.Constant
-				gf.Type = fmt.Sprintf("[%s]%s", sz, elem)
+				gf.Type = fmt.Sprintf("[%s]%s", sz, goArrayItem(f.Type.Array))
 				gf.Decl = fmt.Sprintf("%s %s", f.Name, gf.Type)
-				elemSize := typeSize(elem)
+				elemSize := wireTypeSize(f.Type.Array.Type.Name)
+				putFn, getFn, order := goSliceFuncs(elem, f.IsLittleEndian())
+				if is24BitType(f.Type.Array.Type.Name) {
+					putFn, getFn, order = out.goInt24Funcs(true, f.IsLittleEndian())
+				}
+				if f.Type.Array.Type.Name == "float16" {
+					putFn, getFn, order = out.goFloat16Funcs(true, f.IsLittleEndian())
+				}
 				serCode := []string{
-					fmt.Sprintf("if err := putSlice(buf[offset:], r.%s[:]); err != nil {", f.Name),
+					fmt.Sprintf("if err := %s(buf[offset:], r.%s[:]%s); err != nil {", putFn, f.Name, order),
 					"    return offset, err",
 					"}",
 					fmt.Sprintf("offset += %s * %d", sz, elemSize),
 				}
 				deserCode := []string{
-					fmt.Sprintf("if err := getSlice(buf[offset:], r.%s[:]); err != nil {", f.Name),
+					fmt.Sprintf("if err := %s(buf[offset:], r.%s[:]%s); err != nil {", getFn, f.Name, order),
 					"    return offset, err",
 					"}",
 					fmt.Sprintf("offset += %s * %d", sz, elemSize),
 				}
+				if f.Type.Array.IsMultiDim() {
+					serCode = goArrayRows(f, putFn, order, "")
+					deserCode = goArrayRows(f, getFn, order, "")
+				}
 
 				// Constant array buffer size: array size * element size - add directly to register
-				szInt, _ := strconv.Atoi(sz)
-				bufSizeConst := szInt * elemSize
+				szInt, _ := strconv.ParseInt(sz, 0, 64)
+				bufSizeConst := int(szInt) * f.Type.Array.InnerCount() * elemSize
 				if gf.IsReadable {
 					gf.SerializeReadData = append(gf.SerializeReadData, serCode...)
 					gf.DeserializeReadData = append(gf.DeserializeReadData, deserCode...)
@@ -484,9 +2097,18 @@ func GenerateGo(dev *parser.Device, pkg string) (string, error) {
 			case f.Type.Array != nil && f.Type.Array.Size.Variable != nil:
 				elem := toGoTypes(f.Type.Array.Type.Name)
 				refField := *f.Type.Array.Size.Variable
-				gf.Type = "[]" + elem
+				item := goArrayItem(f.Type.Array)
+				gf.Type = "[]" + item
 				gf.Decl = fmt.Sprintf("%s %s", f.Name, gf.Type)
-				elemSize := typeSize(elem)
+				elemSize := wireTypeSize(f.Type.Array.Type.Name)
+				itemSize := elemSize * f.Type.Array.InnerCount()
+				putFn, getFn, order := goSliceFuncs(elem, f.IsLittleEndian())
+				if is24BitType(f.Type.Array.Type.Name) {
+					putFn, getFn, order = out.goInt24Funcs(true, f.IsLittleEndian())
+				}
+				if f.Type.Array.Type.Name == "float16" {
+					putFn, getFn, order = out.goFloat16Funcs(true, f.IsLittleEndian())
+				}
 
 				fld, bm := reg.FindFieldByName(refField, len(gr.Fields))
 
@@ -496,8 +2118,8 @@ func GenerateGo(dev *parser.Device, pkg string) (string, error) {
 				if bm != nil {
 					serCode = []string{
 						"{",
-						fmt.Sprintf("    elems := (r.%s&%s_%s_%s_bm)>>%d", fld.Name, reg.Name, fld.Name, bm.Name, bm.StartBit()),
-						fmt.Sprintf("    if err := putSlice(buf[offset:], r.%s); err != nil {", f.Name),
+						fmt.Sprintf("    elems := (r.%s&%s_%s_%s_bm)>>%d", fld.Name, gr.Type, fld.Name, bm.Name, bm.StartBit()),
+						fmt.Sprintf("    if err := %s(buf[offset:], r.%s%s); err != nil {", putFn, f.Name, order),
 						"        return offset, err",
 						"    }",
 						fmt.Sprintf("    offset += int(elems) * %d", elemSize),
@@ -505,22 +2127,23 @@ func GenerateGo(dev *parser.Device, pkg string) (string, error) {
 					}
 					deserCode = []string{
 						"{",
-						fmt.Sprintf("    elems := (r.%s&%s_%s_%s_bm)>>%d", fld.Name, reg.Name, fld.Name, bm.Name, bm.StartBit()),
-						fmt.Sprintf("    r.%s = make([]%s, int(elems))", f.Name, elem),
-						fmt.Sprintf("    if err := getSlice(buf[offset:], r.%s); err != nil {", f.Name),
+						fmt.Sprintf("    elems := (r.%s&%s_%s_%s_bm)>>%d", fld.Name, gr.Type, fld.Name, bm.Name, bm.StartBit()),
+					}
+					deserCode = append(deserCode, goSliceAlloc(reg.Name, f.Name, item, itemSize, opts.ReuseSlices)...)
+					deserCode = append(deserCode,
+						fmt.Sprintf("    if err := %s(buf[offset:], r.%s%s); err != nil {", getFn, f.Name, order),
 						"        return offset, err",
 						"    }",
 						fmt.Sprintf("    offset += int(elems) * %d", elemSize),
-						"}",
-					}
+						"}")
 					// Variable array buffer size: element size * bitfield value
 					bufSizeExpr = fmt.Sprintf("(int((r.%s&%s_%s_%s_bm)>>%d) * %d)",
-						fld.Name, reg.Name, fld.Name, bm.Name, bm.StartBit(), elemSize)
+						fld.Name, gr.Type, fld.Name, bm.Name, bm.StartBit(), itemSize)
 				} else {
 					serCode = []string{
 						"{",
 						fmt.Sprintf("    elems := r.%s", refField),
-						fmt.Sprintf("    if err := putSlice(buf[offset:], r.%s); err != nil {", f.Name),
+						fmt.Sprintf("    if err := %s(buf[offset:], r.%s%s); err != nil {", putFn, f.Name, order),
 						"        return offset, err",
 						"    }",
 						fmt.Sprintf("    offset += int(elems) * %d", elemSize),
@@ -529,15 +2152,22 @@ func GenerateGo(dev *parser.Device, pkg string) (string, error) {
 					deserCode = []string{
 						"{",
 						fmt.Sprintf("    elems := r.%s", refField),
-						fmt.Sprintf("    r.%s = make([]%s, int(elems))", f.Name, elem),
-						fmt.Sprintf("    if err := getSlice(buf[offset:], r.%s); err != nil {", f.Name),
+					}
+					deserCode = append(deserCode, goSliceAlloc(reg.Name, f.Name, item, itemSize, opts.ReuseSlices)...)
+					deserCode = append(deserCode,
+						fmt.Sprintf("    if err := %s(buf[offset:], r.%s%s); err != nil {", getFn, f.Name, order),
 						"        return offset, err",
 						"    }",
 						fmt.Sprintf("    offset += int(elems) * %d", elemSize),
-						"}",
-					}
+						"}")
 					// Variable array buffer size: element size * reference field
-					bufSizeExpr = fmt.Sprintf("(int(r.%s) * %d)", refField, elemSize)
+					bufSizeExpr = fmt.Sprintf("(int(r.%s) * %d)", refField, itemSize)
+				}
+				if f.Type.Array.IsMultiDim() {
+					// the rows are sent one by one, the slice is allocated as for the one-dimensional array
+					serCode = goArrayRows(f, putFn, order, "")
+					deserCode = append(deserCode[:len(deserCode)-5], goArrayRows(f, getFn, order, "    ")...)
+					deserCode = append(deserCode, "}")
 				}
 
 				if gf.IsReadable {
@@ -549,21 +2179,7 @@ func GenerateGo(dev *parser.Device, pkg string) (string, error) {
 					gf.DeserializeWriteData = append(gf.DeserializeWriteData, deserCode...)
 				}
 
-				// Generate consistency checks for variable-length arrays
-				if bm != nil {
-					gf.ConsistencyChecks = append(gf.ConsistencyChecks,
-						fmt.Sprintf("if len(r.%s) != int((r.%s&%s_%s_%s_bm)>>%d) {",
-							f.Name, fld.Name, reg.Name, fld.Name, bm.Name, bm.StartBit()),
-						fmt.Sprintf("    return fmt.Errorf(\"array %s length (%%d) does not match field %s value (%%d)\", len(r.%s), int((r.%s&%s_%s_%s_bm)>>%d))",
-							f.Name, refField, f.Name, fld.Name, reg.Name, fld.Name, bm.Name, bm.StartBit()),
-						"}")
-				} else {
-					gf.ConsistencyChecks = append(gf.ConsistencyChecks,
-						fmt.Sprintf("if len(r.%s) != int(r.%s) {", f.Name, refField),
-						fmt.Sprintf("    return fmt.Errorf(\"array %s length (%%d) does not match field %s value (%%d)\", len(r.%s), int(r.%s))",
-							f.Name, refField, f.Name, refField),
-						"}")
-				}
+				goVarArraySizeField(&gf, &gr, f, fld, bm)
 
 				if gf.IsReadable {
 					gf.BufSize4ReadExpr = bufSizeExpr
@@ -571,20 +2187,101 @@ func GenerateGo(dev *parser.Device, pkg string) (string, error) {
 				if gf.IsWritable {
 					gf.BufSize4WriteExpr = bufSizeExpr
 				}
+				gf.CloneData = append(gf.CloneData, fmt.Sprintf("c.%s = slices.Clone(r.%s)", f.Name, f.Name))
+				out.addImport("slices")
+
+			case f.Type.String != nil:
+				gf.Type = "string"
+				gf.Decl = fmt.Sprintf("%s string", f.Name)
+				prefix := toGoTypes(f.Type.String.PrefixType())
+				prefixSize := typeSize(prefix)
+				putFn, getFn, order := goScalarFuncs(prefix, f.IsLittleEndian())
+				serCode := []string{
+					"{",
+					fmt.Sprintf("    if err := %s(buf[offset:], %s(len(r.%s))%s); err != nil {", putFn, prefix, f.Name, order),
+					"        return offset, err",
+					"    }",
+					fmt.Sprintf("    offset += %d", prefixSize),
+					fmt.Sprintf("    if len(buf[offset:]) < len(r.%s) {", f.Name),
+					fmt.Sprintf("        return offset, errBufferTooSmall(len(r.%s), len(buf[offset:]))", f.Name),
+					"    }",
+					fmt.Sprintf("    offset += copy(buf[offset:], r.%s)", f.Name),
+					"}",
+				}
+				deserCode := []string{
+					"{",
+					fmt.Sprintf("    var n %s", prefix),
+					fmt.Sprintf("    if err := %s(buf[offset:], &n%s); err != nil {", getFn, order),
+					"        return offset, err",
+					"    }",
+					fmt.Sprintf("    offset += %d", prefixSize),
+				}
+				maxLen := f.Type.String.MaxLen()
+				if f.Type.String.MaxLenStr != nil {
+					// without the explicit maximum the length is limited by the prefix type
+					deserCode = append(deserCode,
+						fmt.Sprintf("    if n > %d {", maxLen),
+						fmt.Sprintf("        return offset, &FieldError{Register: %q, Field: %q, Err: fmt.Errorf(\"%%w: length %%d exceeds the maximum length %d\", ErrStringTooLong, n)}",
+							reg.Name, f.Name, maxLen),
+						"    }")
+				}
+				deserCode = append(deserCode,
+					"    if len(buf[offset:]) < int(n) {",
+					"        return offset, errBufferTooSmall(int(n), len(buf[offset:]))",
+					"    }",
+					fmt.Sprintf("    r.%s = string(buf[offset : offset+int(n)])", f.Name),
+					"    offset += int(n)",
+					"}")
+
+				// The length prefix size is constant, the string bytes are added by the expression
+				if gf.IsReadable {
+					gf.SerializeReadData = append(gf.SerializeReadData, serCode...)
+					gf.DeserializeReadData = append(gf.DeserializeReadData, deserCode...)
+					gr.BufSize4ReadConst += prefixSize
+					gf.BufSize4ReadExpr = fmt.Sprintf("len(r.%s)", f.Name)
+				}
+				if gf.IsWritable {
+					gf.SerializeWriteData = append(gf.SerializeWriteData, serCode...)
+					gf.DeserializeWriteData = append(gf.DeserializeWriteData, deserCode...)
+					gr.BufSize4WriteConst += prefixSize
+					gf.BufSize4WriteExpr = fmt.Sprintf("len(r.%s)", f.Name)
+				}
+				gf.ConsistencyChecks = append(gf.ConsistencyChecks,
+					fmt.Sprintf("if len(r.%s) > %d {", f.Name, maxLen),
+					fmt.Sprintf("    return &FieldError{Register: %q, Field: %q, Err: fmt.Errorf(\"%%w: length %%d exceeds the maximum length %d\", ErrStringTooLong, len(r.%s))}",
+						reg.Name, f.Name, maxLen, f.Name),
+					"}")
 
-			case f.Type.Simple != nil:
-				elem := toGoTypes(f.Type.Simple.Name)
+			case f.Type.Simple != nil, f.Type.Fixed != nil:
+				elem := toGoTypes(scalarTypeName(f))
 				gf.Type = elem
+				if f.Type.Fixed != nil {
+					gf.FixedScale = fixedScale(f.Type.Fixed)
+				}
 				gf.Decl = fmt.Sprintf("%s %s", f.Name, elem)
-				size := typeSize(elem)
+				if conds := rangeConditions(f, "r."+f.Name); len(conds) > 0 {
+					gf.ConsistencyChecks = append(gf.ConsistencyChecks,
+						fmt.Sprintf("if %s {", strings.Join(conds, " || ")),
+						fmt.Sprintf("    return &FieldError{Register: %q, Field: %q, Err: fmt.Errorf(\"%%w: %%v is not in %d..%d\", ErrOutOfRange, r.%s)}",
+							reg.Name, f.Name, f.Min(), f.Max(), f.Name),
+						"}")
+				}
+				size := wireTypeSize(scalarTypeName(f))
+				putFn, getFn, order := goScalarFuncs(elem, f.IsLittleEndian())
+				if is24BitType(scalarTypeName(f)) {
+					putFn, getFn, order = out.goInt24Funcs(false, f.IsLittleEndian())
+				}
+				if scalarTypeName(f) == "float16" {
+					putFn, getFn, order = out.goFloat16Funcs(false, f.IsLittleEndian())
+				}
 				serCode := []string{
-					fmt.Sprintf("if err := putNumber(buf[offset:], r.%s); err != nil {", f.Name),
+					fmt.Sprintf("if err := %s(buf[offset:], r.%s%s); err != nil {", putFn, f.Name, order),
 					"    return offset, err",
 					"}",
 					fmt.Sprintf("offset += %d", size),
 				}
 				deserCode := []string{
-					fmt.Sprintf("if err := getNumber(buf[offset:], &r.%s); err != nil {", f.Name),
+					fmt.Sprintf("if err := %s(buf[offset:], &r.%s%s); err != nil {", getFn, f.Name, order),
 					"    return offset, err",
 					"}",
 					fmt.Sprintf("offset += %d", size),
@@ -607,23 +2304,391 @@ func GenerateGo(dev *parser.Device, pkg string) (string, error) {
 				gf.Decl = fmt.Sprintf("// unsupported field %s", f.Name)
 			}
 
+			// The conditional field size is added only if the field is sent
+			if f.HasCondition() {
+				gf.Condition = goConditionExpr(gr.Type, reg, f, i)
+				gf.BufSize4ReadExpr = sizeSum(gr.BufSize4ReadConst-readConst, gf.BufSize4ReadExpr)
+				gf.BufSize4WriteExpr = sizeSum(gr.BufSize4WriteConst-writeConst, gf.BufSize4WriteExpr)
+				gr.BufSize4ReadConst, gr.BufSize4WriteConst = readConst, writeConst
+			}
+
+			// The read-write field is counted once in Size(), the gaps before it in both directions
+			switch {
+			case gf.IsReadable && gf.IsWritable:
+				gr.SizeConst += gr.BufSize4ReadConst - readConst + reg.Padding(f, false)
+			case gf.IsReadable:
+				gr.SizeConst += gr.BufSize4ReadConst - readConst
+			default:
+				gr.SizeConst += gr.BufSize4WriteConst - writeConst
+			}
+			if gf.SizeExpr == "" {
+				gf.SizeExpr = gf.BufSize4ReadExpr
+				if !gf.IsReadable {
+					gf.SizeExpr = gf.BufSize4WriteExpr
+				}
+			}
+
+			switch {
+			case gf.Reserved:
+				// reserved fields have no value to compare
+			case f.Type.Simple != nil && f.Type.Simple.IsRegisterRef():
+				gf.NotEqualExpr = fmt.Sprintf("!r.%s.Equal(&o.%s)", f.Name, f.Name)
+			case f.Type.Array != nil && f.Type.Array.Type.IsRegisterRef():
+				suffix := ""
+				if f.Type.Array.Size.Constant != nil {
+					suffix = "[:]"
+				}
+				gf.NotEqualExpr = fmt.Sprintf("!slices.EqualFunc(r.%s%s, o.%s%s, func(a, b %s) bool { return a.Equal(&b) })",
+					f.Name, suffix, f.Name, suffix, opts.goIdent(f.Type.Array.Type.Name))
+				out.addImport("slices")
+			case f.Type.Array != nil && f.Type.Array.Size.Variable != nil:
+				gf.NotEqualExpr = fmt.Sprintf("!slices.Equal(r.%s, o.%s)", f.Name, f.Name)
+			default:
+				gf.NotEqualExpr = fmt.Sprintf("r.%s != o.%s", f.Name, f.Name)
+			}
+
+			switch {
+			case gf.Reserved:
+				// reserved fields have no value to reset
+			case f.Type.Simple != nil && f.Type.Simple.IsRegisterRef():
+				gf.ResetData = []string{fmt.Sprintf("r.%s.Reset()", f.Name)}
+			case f.Type.Array != nil && f.Type.Array.Size.Variable != nil:
+				gf.ResetData = []string{fmt.Sprintf("r.%s = r.%s[:0]", f.Name, f.Name)}
+			case f.Type.Array != nil && f.Type.Array.Type.IsRegisterRef():
+				gf.ResetData = []string{
+					fmt.Sprintf("for i := range r.%s {", f.Name),
+					fmt.Sprintf("    r.%s[i].Reset()", f.Name),
+					"}",
+				}
+			case f.Type.Array != nil:
+				gf.ResetData = []string{fmt.Sprintf("clear(r.%s[:])", f.Name)}
+			case f.Type.String != nil:
+				gf.ResetData = []string{fmt.Sprintf("r.%s = \"\"", f.Name)}
+			case f.HasDefault():
+				gf.ResetData = []string{fmt.Sprintf("r.%s = %s", f.Name, *f.DefaultStr)}
+			default:
+				gf.ResetData = []string{fmt.Sprintf("r.%s = 0", f.Name)}
+			}
+
+			// The absent conditional field is decoded as the reset one
+			if gf.Condition != "" {
+				gf.SerializeReadData = goIf(gf.Condition, gf.SerializeReadData, nil)
+				gf.SerializeWriteData = goIf(gf.Condition, gf.SerializeWriteData, nil)
+				gf.DeserializeReadData = goIf(gf.Condition, gf.DeserializeReadData, gf.ResetData)
+				gf.DeserializeWriteData = goIf(gf.Condition, gf.DeserializeWriteData, gf.ResetData)
+				gf.ConsistencyChecks = goIf(gf.Condition, gf.ConsistencyChecks, nil)
+			}
+
+			// The tags describe the field in the .pa file for the reflection based tools
+			if !gf.Reserved {
+				access := f.Specifier
+				if access == "" {
+					access = "rw"
+				}
+				gf.Tag = fmt.Sprintf("`pa:%q order:\"%d\" access:%q`", f.Name, i, access)
+			}
+
+			if opts.JSON {
+				goJSONField(&gf, f, gr.Type, opts)
+			}
+
 			gr.Fields = append(gr.Fields, gf)
 		}
+		if opts.JSON {
+			out.addImport("encoding/json")
+			out.addImport("strconv")
+		}
+		if opts.Pool {
+			out.addImport("sync")
+		}
 
 		out.Registers = append(out.Registers, gr)
 	}
 
-	var buf bytes.Buffer
-	if err := tpl.Execute(&buf, out); err != nil {
-		return "", err
+	for _, m := range dev.Messages {
+		gm := GoMessage{
+			Name: m.Name,
+			Type: opts.goIdent(m.Name),
+			Doc:  declComments(m.Doc, m.TrailingComment),
+		}
+		for _, mm := range m.Members {
+			if goMessageMethods[mm.Register] {
+				return GoDevice{}, fmt.Errorf("register '%s' of message '%s' has the name of the message method", mm.Register, m.Name)
+			}
+			gm.Members = append(gm.Members, GoMessageMember{
+				Doc:      flattenComments(mm.Doc),
+				Name:     mm.Register,
+				Type:     opts.goIdent(mm.Register),
+				Trailing: safeString(mm.TrailingComment),
+			})
+			gm.HasDefaults = gm.HasDefaults || hasDefaults(dev, dev.FindRegisterByName(mm.Register))
+		}
+		out.Messages = append(out.Messages, gm)
 	}
-	return strings.TrimSpace(buf.String()) + "\n", nil
+	return out, nil
 }
 
 //
 // Helpers
 //
 
+// goKeywords are the Go keywords, which cannot be the names of the types, constants and fields
+var goKeywords = map[string]bool{
+	"break": true, "case": true, "chan": true, "const": true, "continue": true, "default": true,
+	"defer": true, "else": true, "fallthrough": true, "for": true, "func": true, "go": true, "goto": true,
+	"if": true, "import": true, "interface": true, "map": true, "package": true, "range": true,
+	"return": true, "select": true, "struct": true, "switch": true, "type": true, "var": true,
+}
+
+// goPredeclared are the predeclared Go identifiers, the generated code refers to them, so the
+// types and constants cannot hide them
+var goPredeclared = map[string]bool{
+	"any": true, "append": true, "bool": true, "byte": true, "cap": true, "clear": true, "close": true,
+	"comparable": true, "complex": true, "complex64": true, "complex128": true, "copy": true,
+	"delete": true, "error": true, "false": true, "float32": true, "float64": true, "imag": true,
+	"int": true, "int8": true, "int16": true, "int32": true, "int64": true, "iota": true, "len": true,
+	"make": true, "max": true, "min": true, "new": true, "nil": true, "panic": true, "print": true,
+	"println": true, "real": true, "recover": true, "rune": true, "string": true, "true": true,
+	"uint": true, "uint8": true, "uint16": true, "uint32": true, "uint64": true, "uintptr": true,
+}
+
+// checkGoName returns the error if the Go name of the declaration is a Go keyword or a
+// predeclared identifier
+func checkGoName(kind, name, goName string) error {
+	switch {
+	case goKeywords[goName]:
+		return fmt.Errorf("%s '%s' has the Go name %s, which is a Go keyword", kind, name, goName)
+	case goPredeclared[goName]:
+		return fmt.Errorf("%s '%s' has the Go name %s, which is a predeclared Go identifier", kind, name, goName)
+	}
+	return nil
+}
+
+// goMessageMethods are the methods of the message struct, its registers cannot have their names
+var goMessageMethods = map[string]bool{
+	"BufSize4Read": true, "BufSize4Write": true, "Size": true, "Check": true, "SerializeRead": true,
+	"SerializeWrite": true, "AppendRead": true, "AppendWrite": true, "DeserializeRead": true,
+	"DeserializeWrite": true, "Clone": true, "Reset": true, "Equal": true,
+}
+
+// goSizeFieldExpr returns the expression with the value of the variable array size field,
+// which is either the regular field or the bit field member
+func goSizeFieldExpr(regType string, fld *parser.Field, bm *parser.BitMember) string {
+	if bm != nil {
+		return fmt.Sprintf("(r.%s&%s_%s_%s_bm)>>%d", fld.Name, regType, fld.Name, bm.Name, bm.StartBit())
+	}
+	return fmt.Sprintf("r.%s", fld.Name)
+}
+
+// goConditionExpr returns the expression which is true if the conditional field is sent, the
+// condition is either the single-bit member or the uint8 field, which is set if it is not zero
+func goConditionExpr(regType string, reg *parser.Register, f *parser.Field, idx int) string {
+	fld, bm := reg.FindFieldByName(*f.Condition, idx)
+	if bm != nil {
+		return fmt.Sprintf("r.%s&%s_%s_%s_bm != 0", fld.Name, regType, fld.Name, bm.Name)
+	}
+	return fmt.Sprintf("r.%s != 0", fld.Name)
+}
+
+// goIf returns the code executed only if the condition is true, the elseCode is executed otherwise.
+// There is no code if the code is empty
+func goIf(cond string, code, elseCode []string) []string {
+	if len(code) == 0 {
+		return nil
+	}
+	res := []string{fmt.Sprintf("if %s {", cond)}
+	for _, line := range code {
+		res = append(res, "    "+line)
+	}
+	if len(elseCode) > 0 {
+		res = append(res, "} else {")
+		for _, line := range elseCode {
+			res = append(res, "    "+line)
+		}
+	}
+	return append(res, "}")
+}
+
+// goVarArraySizeField fills the variable array consistency check and the setter code
+// keeping the size field consistent with the array length
+func goVarArraySizeField(gf *GoField, gr *GoRegister, f *parser.Field, fld *parser.Field, bm *parser.BitMember) {
+	refField := *f.Type.Array.Size.Variable
+	value := goSizeFieldExpr(gr.Type, fld, bm)
+	gf.ConsistencyChecks = append(gf.ConsistencyChecks,
+		fmt.Sprintf("if len(r.%s) != int(%s) {", f.Name, value),
+		fmt.Sprintf("    return &FieldError{Register: %q, Field: %q, Err: fmt.Errorf(\"%%w: length %%d, field %s value %%d\", ErrArrayLengthMismatch, len(r.%s), int(%s))}",
+			gr.Name, f.Name, refField, f.Name, value),
+		"}")
+
+	gf.SizeField = refField
+	if bm != nil {
+		base := toGoTypes(fld.Type.Bitfield.Base)
+		mask := fmt.Sprintf("%s_%s_%s_bm", gr.Type, fld.Name, bm.Name)
+		gf.SizeUpdate = fmt.Sprintf("r.%s = (r.%s &^ %s) | ((%s(len(v)) << %d) & %s)",
+			fld.Name, fld.Name, mask, base, bm.StartBit(), mask)
+	} else {
+		gf.SizeUpdate = fmt.Sprintf("r.%s = %s(len(v))", refField, toGoTypes(fld.Type.Simple.Name))
+	}
+}
+
+// goArrayItem returns the Go type of the array item, which is the row of the inner
+// dimensions for the multi-dimensional arrays, e.g. [8]uint8 for [count][8]uint8
+func goArrayItem(at *parser.ArrayType) string {
+	item := toGoTypes(at.Type.Name)
+	for i := len(at.Dims) - 1; i >= 0; i-- {
+		item = fmt.Sprintf("[%s]%s", at.Dims[i], item)
+	}
+	return item
+}
+
+// goArrayRows returns the code encoding or decoding the multi-dimensional array by fn: the
+// loops go over all the dimensions except the last one, which rows are passed as slices
+func goArrayRows(f *parser.Field, fn, order, indent string) []string {
+	at := f.Type.Array
+	lastDim, _ := strconv.ParseInt(at.Dims[len(at.Dims)-1], 0, 64)
+	rowSize := int(lastDim) * wireTypeSize(at.Type.Name)
+	var res []string
+	row := "r." + f.Name
+	for i := range at.Dims {
+		idx := fmt.Sprintf("i%d", i)
+		res = append(res, fmt.Sprintf("%sfor %s := range %s {", indent, idx, row))
+		row += "[" + idx + "]"
+		indent += "    "
+	}
+	res = append(res,
+		fmt.Sprintf("%sif err := %s(buf[offset:], %s[:]%s); err != nil {", indent, fn, row, order),
+		indent+"    return offset, err",
+		indent+"}",
+		fmt.Sprintf("%soffset += %d", indent, rowSize))
+	for range at.Dims {
+		indent = indent[4:]
+		res = append(res, indent+"}")
+	}
+	return res
+}
+
+// goSliceAlloc returns the code preparing the variable array for elems elements, if reuse is set
+// the existing slice is resliced instead of allocating when it has enough capacity. The number
+// of the elements is checked before the allocation: it cannot exceed MaxArrayLength, and the
+// elements of the itemSize wire bytes must fit into the buffer, itemSize is 0 if it is not constant
+func goSliceAlloc(regName, name, elem string, itemSize int, reuse bool) []string {
+	res := []string{
+		"    if uint64(elems) > uint64(MaxArrayLength) {",
+		fmt.Sprintf("        return offset, &FieldError{Register: %q, Field: %q, Err: fmt.Errorf(\"%%w: %%d elements, the maximum is %%d\", ErrArrayTooLong, uint64(elems), MaxArrayLength)}",
+			regName, name),
+		"    }",
+	}
+	if itemSize > 0 {
+		res = append(res,
+			fmt.Sprintf("    if need := int(elems) * %d; len(buf[offset:]) < need {", itemSize),
+			"        return offset, errBufferTooSmall(need, len(buf[offset:]))",
+			"    }")
+	}
+	if !reuse {
+		return append(res, fmt.Sprintf("    r.%s = make([]%s, int(elems))", name, elem))
+	}
+	return append(res,
+		fmt.Sprintf("    if cap(r.%s) >= int(elems) {", name),
+		fmt.Sprintf("        r.%s = r.%s[:int(elems)]", name, name),
+		"    } else {",
+		fmt.Sprintf("        r.%s = make([]%s, int(elems))", name, elem),
+		"    }")
+}
+
+// goPaddingCode returns the code writing and skipping the zero bytes, e.g. the reserved ones
+func goPaddingCode(size int) (serCode, deserCode []string) {
+	serCode = []string{
+		fmt.Sprintf("if len(buf[offset:]) < %d {", size),
+		fmt.Sprintf("    return offset, errBufferTooSmall(%d, len(buf[offset:]))", size),
+		"}",
+		fmt.Sprintf("clear(buf[offset : offset+%d])", size),
+		fmt.Sprintf("offset += %d", size),
+	}
+	deserCode = []string{
+		fmt.Sprintf("if len(buf[offset:]) < %d {", size),
+		fmt.Sprintf("    return offset, errBufferTooSmall(%d, len(buf[offset:]))", size),
+		"}",
+		fmt.Sprintf("offset += %d", size),
+	}
+	return serCode, deserCode
+}
+
+// goAccessorDoc returns the field comments as a separate paragraph of the accessors doc comments,
+// so go doc shows them for the accessors. The empty lines between the comments separate paragraphs
+func goAccessorDoc(f *parser.Field) []string {
+	lines := trimLeadingEmptyLines(declComments(f.Doc, f.TrailingComment))
+	for len(lines) > 0 && lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+	if len(lines) == 0 {
+		return nil
+	}
+	res := []string{"//"}
+	for _, line := range lines {
+		if line == "" {
+			line = "//"
+		}
+		res = append(res, line)
+	}
+	return res
+}
+
+// goJSONField fills the JSON form of the field: the nested registers are referenced to
+// be encoded by their own methods and the bit fields are converted by the accessors
+func goJSONField(gf *GoField, f *parser.Field, regType string, opts GoOptions) {
+	switch {
+	case gf.Reserved:
+		// reserved fields have no value to encode
+	case f.Type.Simple != nil && f.Type.Simple.IsRegisterRef():
+		gf.JSONType = "*" + gf.Type
+		gf.JSONValue = fmt.Sprintf("&r.%s", f.Name)
+	case f.Type.Array != nil && f.Type.Array.Size.Constant != nil && f.Type.Array.Type.IsRegisterRef():
+		// the array elements must be addressable to be encoded by the pointer receiver methods
+		gf.JSONType = "*" + gf.Type
+		gf.JSONValue = fmt.Sprintf("&r.%s", f.Name)
+	case f.Type.Bitfield != nil:
+		gf.JSONType = fmt.Sprintf("json%s%s", regType, gf.CapitalizedName)
+		var values []string
+		for _, bm := range gf.BitMembers {
+			values = append(values, fmt.Sprintf("%s: r.%s()", bm.CapitalizedName, opts.goIdent("Get", gf.CapitalizedName, bm.CapitalizedName)))
+			gf.JSONAssign = append(gf.JSONAssign, fmt.Sprintf("r.%s(j.%s.%s)",
+				opts.goIdent("Set", gf.CapitalizedName, bm.CapitalizedName), gf.CapitalizedName, bm.CapitalizedName))
+		}
+		gf.JSONValue = fmt.Sprintf("%s{%s}", gf.JSONType, strings.Join(values, ", "))
+	case gf.Type == "[]uint8":
+		gf.JSONType = "jsonUint8s"
+		gf.JSONValue = fmt.Sprintf("jsonUint8s(r.%s)", f.Name)
+		gf.JSONAssign = []string{fmt.Sprintf("r.%s = []uint8(j.%s)", f.Name, gf.CapitalizedName)}
+	default:
+		gf.JSONType = gf.Type
+		gf.JSONValue = fmt.Sprintf("r.%s", f.Name)
+		gf.JSONAssign = []string{fmt.Sprintf("r.%s = j.%s", f.Name, gf.CapitalizedName)}
+	}
+}
+
+// goCRCFunc returns the function calculating the checksum and registers its runtime helper
+func (d *GoDevice) goCRCFunc(ct *parser.CRCType) string {
+	switch alg := ct.AlgorithmName(); alg {
+	case "ieee":
+		d.addImport("hash/crc32")
+		return "crc32.ChecksumIEEE"
+	default:
+		if d.CRCs == nil {
+			d.CRCs = make(map[string]bool)
+		}
+		d.CRCs[alg] = true
+		return "crc16" + cases.Title(language.English).String(alg)
+	}
+}
+
+// addImport adds the package to the generated code imports, if it is not there yet
+func (d *GoDevice) addImport(pkg string) {
+	if !slices.Contains(d.Imports, pkg) {
+		d.Imports = append(d.Imports, pkg)
+		slices.Sort(d.Imports)
+	}
+}
+
 func toGoTypes(typ string) string {
 	switch typ {
 	case "int8":
@@ -634,15 +2699,15 @@ func toGoTypes(typ string) string {
 		return "int16"
 	case "uint16":
 		return "uint16"
-	case "int32":
+	case "int24", "int32":
 		return "int32"
-	case "uint32":
+	case "uint24", "uint32":
 		return "uint32"
 	case "int64":
 		return "int64"
 	case "uint64":
 		return "uint64"
-	case "float32":
+	case "float16", "float32":
 		return "float32"
 	case "float64":
 		return "float64"
@@ -651,6 +2716,64 @@ func toGoTypes(typ string) string {
 	}
 }
 
+// goScalarFuncs returns the names of the runtime helpers encoding and decoding
+// a single value of the Go type, plus the byte order argument the helpers require
+func goScalarFuncs(goType string, littleEndian bool) (string, string, string) {
+	put, get := "putNumber", "getNumber"
+	if isFloatType(goType) {
+		put, get = "putFloat", "getFloat"
+	}
+	if littleEndian {
+		return put + "Order", get + "Order", ", binary.LittleEndian"
+	}
+	return put, get, ""
+}
+
+// goSliceFuncs returns the names of the runtime helpers encoding and decoding
+// a slice of values of the Go type, plus the byte order argument the helpers require
+func goSliceFuncs(goType string, littleEndian bool) (string, string, string) {
+	put, get := "putSlice", "getSlice"
+	if isFloatType(goType) {
+		put, get = "putFloatSlice", "getFloatSlice"
+	}
+	if littleEndian {
+		return put + "Order", get + "Order", ", binary.LittleEndian"
+	}
+	return put, get, ""
+}
+
+// goInt24Funcs returns the names of the runtime helpers encoding and decoding the 24-bit
+// integers, which have no Go type of their size, plus the byte order argument
+func (d *GoDevice) goInt24Funcs(slice, littleEndian bool) (string, string, string) {
+	d.Int24 = true
+	order := ", binary.BigEndian"
+	if littleEndian {
+		order = ", binary.LittleEndian"
+	}
+	if slice {
+		return "putSlice24", "getSlice24", order
+	}
+	return "putNumber24", "getNumber24", order
+}
+
+// goFloat16Funcs returns the names of the runtime helpers encoding and decoding the half-precision
+// numbers, which are kept in float32, plus the byte order argument
+func (d *GoDevice) goFloat16Funcs(slice, littleEndian bool) (string, string, string) {
+	d.Float16 = true
+	order := ", binary.BigEndian"
+	if littleEndian {
+		order = ", binary.LittleEndian"
+	}
+	if slice {
+		return "putFloat16Slice", "getFloat16Slice", order
+	}
+	return "putFloat16", "getFloat16", order
+}
+
+func isFloatType(goType string) bool {
+	return goType == "float32" || goType == "float64"
+}
+
 func typeSize(goType string) int {
 	switch goType {
 	case "int8", "uint8":
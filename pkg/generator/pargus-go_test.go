@@ -0,0 +1,3388 @@
+package generator
+
+import (
+	"go/ast"
+	"go/format"
+	goparser "go/parser"
+	"go/token"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/dspasibenko/pargus/pkg/parser"
+	"github.com/stretchr/testify/require"
+)
+
+// runGeneratedGoTest puts the generated code and the test code into a temporary
+// module and runs go test there with the args, so the generated code is really compiled and executed
+func runGeneratedGoTest(t *testing.T, code, testCode string, args ...string) {
+	t.Helper()
+	goBin, err := exec.LookPath("go")
+	if err != nil {
+		t.Skip("go toolchain is not available")
+	}
+
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "go.mod"), []byte("module gentest\n\ngo 1.24\n"), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "registers.go"), []byte(code), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "registers_test.go"), []byte(testCode), 0644))
+
+	cmd := exec.Command(goBin, append([]string{"test", "./..."}, args...)...)
+	cmd.Dir = dir
+	cmd.Env = append(os.Environ(), "GOFLAGS=-mod=mod", "GOWORK=off")
+	out, err := cmd.CombinedOutput()
+	require.NoError(t, err, "generated code test failed:\n%s\n%s", out, code)
+}
+
+func TestGenerateGoFloatRoundTrip(t *testing.T) {
+	input := `
+    device test
+
+    register Control(1) {
+        flow float32;
+        count uint8;
+        samples [count]float64;
+        fixed [2]float32;
+    };`
+
+	device, err := parser.Parse(input)
+	require.NoError(t, err)
+
+	code, err := GenerateGo(device, "gentest")
+	require.NoError(t, err)
+	require.Contains(t, code, "if err := putFloat(buf[offset:], r.flow); err != nil {")
+	require.Contains(t, code, "if err := getFloatSlice(buf[offset:], r.samples); err != nil {")
+
+	runGeneratedGoTest(t, code, `package gentest
+
+import "testing"
+
+func TestRoundTrip(t *testing.T) {
+	src := Control{flow: 3.25, count: 2, samples: []float64{-1.5, 1e100}, fixed: [2]float32{0.5, -2}}
+	buf := make([]byte, src.BufSize4Write())
+	n, err := src.SerializeWrite(buf)
+	if err != nil || n != len(buf) {
+		t.Fatalf("serialize: n=%d err=%v", n, err)
+	}
+	if buf[0] != 0x40 || buf[1] != 0x50 {
+		t.Fatalf("float32 must be big-endian encoded, got % x", buf[:4])
+	}
+
+	var dst Control
+	if _, err := dst.DeserializeWrite(buf); err != nil {
+		t.Fatal(err)
+	}
+	if dst.flow != src.flow || dst.count != src.count || dst.fixed != src.fixed {
+		t.Fatalf("mismatch: %+v != %+v", dst, src)
+	}
+	if len(dst.samples) != 2 || dst.samples[0] != -1.5 || dst.samples[1] != 1e100 {
+		t.Fatalf("samples mismatch: %v", dst.samples)
+	}
+}
+`)
+}
+
+func TestGenerateGoFieldEndianness(t *testing.T) {
+	input := `
+    device test
+
+    register Frame(1) {
+        header uint16;
+        payload uint32 @le;
+        flags uint16{ready: 0} @le;
+        value float32 @le;
+        count uint8;
+        data [count]int16 @le;
+    };`
+
+	device, err := parser.Parse(input)
+	require.NoError(t, err)
+
+	code, err := GenerateGo(device, "gentest")
+	require.NoError(t, err)
+	require.Contains(t, code, "if err := putNumber(buf[offset:], r.header); err != nil {")
+	require.Contains(t, code, "if err := putNumberOrder(buf[offset:], r.payload, binary.LittleEndian); err != nil {")
+
+	runGeneratedGoTest(t, code, `package gentest
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestRoundTrip(t *testing.T) {
+	src := Frame{header: 0x0102, payload: 0x03040506, flags: 0x0001, value: 1, count: 2, data: []int16{0x0708, -2}}
+	buf := make([]byte, src.BufSize4Write())
+	if _, err := src.SerializeWrite(buf); err != nil {
+		t.Fatal(err)
+	}
+	expected := []byte{
+		0x01, 0x02, // header big-endian
+		0x06, 0x05, 0x04, 0x03, // payload little-endian
+		0x01, 0x00, // flags little-endian
+		0x00, 0x00, 0x80, 0x3f, // value little-endian
+		0x02,
+		0x08, 0x07, 0xfe, 0xff, // data little-endian
+	}
+	if !bytes.Equal(buf, expected) {
+		t.Fatalf("unexpected wire bytes % x", buf)
+	}
+
+	var dst Frame
+	if _, err := dst.DeserializeWrite(buf); err != nil {
+		t.Fatal(err)
+	}
+	if dst.header != src.header || dst.payload != src.payload || dst.flags != src.flags || dst.value != src.value {
+		t.Fatalf("mismatch: %+v != %+v", dst, src)
+	}
+	if len(dst.data) != 2 || dst.data[0] != 0x0708 || dst.data[1] != -2 {
+		t.Fatalf("data mismatch: %v", dst.data)
+	}
+}
+`)
+}
+
+func TestGenerateGoRegisterIDs(t *testing.T) {
+	input := `
+    device test
+
+    register Control(1) {
+        mode uint8;
+    };
+
+    register Status(0x10):r {
+        value uint16;
+    };`
+
+	device, err := parser.Parse(input)
+	require.NoError(t, err)
+
+	code, err := GenerateGo(device, "gentest")
+	require.NoError(t, err)
+	require.Contains(t, code, "RegControlID uint8 = 1")
+	require.Contains(t, code, "RegStatusID  uint8 = 16")
+
+	runGeneratedGoTest(t, code, `package gentest
+
+import "testing"
+
+func TestIDs(t *testing.T) {
+	var regs []interface{ ID() uint8 }
+	for _, id := range []uint8{16, 1} {
+		switch id {
+		case RegControlID:
+			regs = append(regs, &Control{})
+		case RegStatusID:
+			regs = append(regs, &Status{})
+		}
+	}
+	if len(regs) != 2 || regs[0].ID() != RegStatusID || regs[1].ID() != RegControlID {
+		t.Fatalf("unexpected registers %v", regs)
+	}
+}
+`)
+}
+
+func TestGenerateGoDecoder(t *testing.T) {
+	input := `
+    device test
+
+    register Control(1) {
+        mode uint8;
+        count uint8;
+        data [count]uint16;
+    };
+
+    register Status(2):r {
+        value uint16;
+    };`
+
+	device, err := parser.Parse(input)
+	require.NoError(t, err)
+
+	code, err := GenerateGo(device, "gentest")
+	require.NoError(t, err)
+	require.NotContains(t, code, "func DecodeRegister(")
+
+	code, err = GenerateGoWithOptions(device, "gentest", GoOptions{Decoder: true})
+	require.NoError(t, err)
+	require.Contains(t, code, "func DecodeRegister(id uint8, buf []byte) (interface{}, int, error) {")
+	require.Contains(t, code, "func DecodeReadRegister(id uint8, buf []byte) (interface{}, int, error) {")
+
+	runGeneratedGoTest(t, code, `package gentest
+
+import "testing"
+
+func TestDecodeRegister(t *testing.T) {
+	reg, n, err := DecodeRegister(RegControlID, []byte{7, 2, 0, 1, 0, 2, 0xff})
+	if err != nil || n != 6 {
+		t.Fatalf("n=%d err=%v", n, err)
+	}
+	c, ok := reg.(*Control)
+	if !ok || c.mode != 7 || len(c.data) != 2 || c.data[1] != 2 {
+		t.Fatalf("unexpected register %#v", reg)
+	}
+
+	reg, n, err = DecodeReadRegister(RegStatusID, []byte{1, 2})
+	if err != nil || n != 2 || reg.(*Status).value != 0x0102 {
+		t.Fatalf("reg=%#v n=%d err=%v", reg, n, err)
+	}
+
+	if _, _, err := DecodeRegister(RegControlID, []byte{7, 2, 0}); err == nil {
+		t.Fatal("truncated buffer must be reported")
+	}
+	if reg, _, err := DecodeRegister(42, []byte{1}); err == nil || reg != nil {
+		t.Fatalf("unknown id must be reported, got reg=%v err=%v", reg, err)
+	}
+}
+`)
+}
+
+func TestGenerateGoDecodeAll(t *testing.T) {
+	input := `
+    device test
+
+    register Control(1) {
+        mode uint8;
+        count uint8;
+        data [count]uint16;
+    };
+
+    register Config(2) {
+        value uint16;
+    };`
+
+	device, err := parser.Parse(input)
+	require.NoError(t, err)
+
+	code, err := GenerateGoWithOptions(device, "gentest", GoOptions{Decoder: true})
+	require.NoError(t, err)
+	require.Contains(t, code, "func DecodeAll(buf []byte) ([]interface{}, int, error) {")
+
+	runGeneratedGoTest(t, code, `package gentest
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestDecodeAll(t *testing.T) {
+	buf := []byte{RegControlID, 7, 1, 0, 5, RegConfigID, 1, 2, RegControlID, 3, 0}
+	regs, remaining, err := DecodeAll(buf)
+	if err != nil || remaining != 0 || len(regs) != 3 {
+		t.Fatalf("regs=%v remaining=%d err=%v", regs, remaining, err)
+	}
+	if c := regs[0].(*Control); c.mode != 7 || len(c.data) != 1 || c.data[0] != 5 {
+		t.Fatalf("unexpected first register %#v", c)
+	}
+	if c := regs[1].(*Config); c.value != 0x0102 {
+		t.Fatalf("unexpected second register %#v", c)
+	}
+	if c := regs[2].(*Control); c.mode != 3 || len(c.data) != 0 {
+		t.Fatalf("unexpected third register %#v", c)
+	}
+
+	// the last register is cut, the decoded prefix and its size are returned
+	regs, remaining, err = DecodeAll(buf[:7])
+	if !errors.Is(err, ErrBufferTooSmall) || remaining != 2 || len(regs) != 1 {
+		t.Fatalf("regs=%v remaining=%d err=%v", regs, remaining, err)
+	}
+	regs, remaining, err = DecodeAll(append(buf[7-remaining:7:7], buf[7:]...))
+	if err != nil || remaining != 0 || len(regs) != 2 || regs[0].(*Config).value != 0x0102 {
+		t.Fatalf("regs=%v remaining=%d err=%v", regs, remaining, err)
+	}
+
+	regs, remaining, err = DecodeAll([]byte{RegConfigID, 1, 2, 42, 0})
+	if !errors.Is(err, ErrUnknownRegister) || remaining != 2 || len(regs) != 1 {
+		t.Fatalf("regs=%v remaining=%d err=%v", regs, remaining, err)
+	}
+
+	if regs, remaining, err := DecodeAll(nil); err != nil || remaining != 0 || len(regs) != 0 {
+		t.Fatalf("regs=%v remaining=%d err=%v", regs, remaining, err)
+	}
+}
+`)
+}
+
+func TestGenerateGoEnum(t *testing.T) {
+	input := `
+    device test
+
+    // Operation mode
+    enum Mode uint8 {
+        OFF = 0,
+        ON = 1,
+        STANDBY = 2,
+    };
+
+    enum Offset int16 { NEG = -5, POS = 5 };
+
+    register Control(1) {
+        mode Mode;
+        offset Offset @le;
+    };`
+
+	device, err := parser.Parse(input)
+	require.NoError(t, err)
+
+	code, err := GenerateGo(device, "gentest")
+	require.NoError(t, err)
+	require.Contains(t, code, "type Mode uint8")
+	require.Contains(t, code, "Mode_STANDBY Mode = 2")
+
+	runGeneratedGoTest(t, code, `package gentest
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestEnum(t *testing.T) {
+	src := Control{mode: Mode_STANDBY, offset: Offset_NEG}
+	buf := make([]byte, src.BufSize4Write())
+	if _, err := src.SerializeWrite(buf); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(buf, []byte{2, 0xfb, 0xff}) {
+		t.Fatalf("unexpected wire bytes % x", buf)
+	}
+	var dst Control
+	if _, err := dst.DeserializeWrite(buf); err != nil {
+		t.Fatal(err)
+	}
+	if dst.GetMode() != Mode_STANDBY || dst.GetOffset() != Offset_NEG {
+		t.Fatalf("mismatch %+v", dst)
+	}
+	if Mode_ON.String() != "ON" || Mode(7).String() != "Mode(7)" || Offset_NEG.String() != "NEG" {
+		t.Fatal("unexpected String() result")
+	}
+}
+`)
+}
+
+func TestGenerateGoBitfieldStrings(t *testing.T) {
+	input := `
+    device test
+
+    register Control(1) {
+        flags uint32{error: 0, ready: 1, mode: 4-6};
+        value uint8;
+    };`
+
+	device, err := parser.Parse(input)
+	require.NoError(t, err)
+
+	code, err := GenerateGo(device, "gentest")
+	require.NoError(t, err)
+	require.NotContains(t, code, "FlagsString")
+	require.NotContains(t, code, `"strings"`)
+
+	code, err = GenerateGoWithOptions(device, "gentest", GoOptions{BitfieldStrings: true})
+	require.NoError(t, err)
+	require.Contains(t, code, "func (r *Control) FlagsString() string {")
+	require.NotContains(t, code, "ValueString")
+
+	runGeneratedGoTest(t, code, `package gentest
+
+import "testing"
+
+func TestFlagsString(t *testing.T) {
+	r := Control{flags: 0x52}
+	if s := r.FlagsString(); s != "ready|mode=5" {
+		t.Fatalf("unexpected string %q", s)
+	}
+	r.flags = 0x01
+	if s := r.FlagsString(); s != "error|mode=0" {
+		t.Fatalf("unexpected string %q", s)
+	}
+}
+`)
+}
+
+func TestGenerateGoBitMemberAccessors(t *testing.T) {
+	input := `
+    device test
+
+    register Control(1) {
+        flags uint16{ready: 0, mode: 4-6, high: 12-15};
+    };`
+
+	device, err := parser.Parse(input)
+	require.NoError(t, err)
+
+	code, err := GenerateGo(device, "gentest")
+	require.NoError(t, err)
+	require.Contains(t, code, "func (r *Control) GetFlagsReady() bool {")
+	require.Contains(t, code, "func (r *Control) SetFlagsMode(v uint16) {")
+
+	runGeneratedGoTest(t, code, `package gentest
+
+import "testing"
+
+func TestBitMembers(t *testing.T) {
+	var r Control
+	r.SetFlagsMode(5)
+	r.SetFlagsReady(true)
+	r.SetFlagsHigh(0xf)
+	if r.GetFlagsMode() != 5 || !r.GetFlagsReady() || r.GetFlagsHigh() != 0xf || r.flags != 0xf051 {
+		t.Fatalf("unexpected flags %#x", r.flags)
+	}
+	// the value is truncated to the member width and the other members are kept
+	r.SetFlagsMode(0xff)
+	if r.GetFlagsMode() != 7 || r.flags != 0xf071 {
+		t.Fatalf("unexpected flags %#x", r.flags)
+	}
+	r.SetFlagsReady(false)
+	r.SetFlagsMode(0)
+	if r.GetFlagsReady() || r.flags != 0xf000 {
+		t.Fatalf("unexpected flags %#x", r.flags)
+	}
+}
+`)
+}
+
+func TestGenerateGoIsFormatted(t *testing.T) {
+	input := `
+    device test
+
+    enum Mode uint8 { OFF = 0, ON = 1 };
+
+    register Config(1) {
+        mode Mode;
+    };
+
+    register Control(2) {
+        flags uint16{ready: 0, mode: 4-6} @le;
+        count uint8;
+        data [count]float32;
+        fixed [2]int16;
+        config Config;
+    };`
+
+	device, err := parser.Parse(input)
+	require.NoError(t, err)
+
+	code, err := GenerateGoWithOptions(device, "gentest", GoOptions{Decoder: true, BitfieldStrings: true})
+	require.NoError(t, err)
+	formatted, err := format.Source([]byte(code))
+	require.NoError(t, err)
+	require.Equal(t, string(formatted), code)
+}
+
+func TestGenerateGoBlockComments(t *testing.T) {
+	input := `
+    device test
+
+    /* The register
+     * takes two lines
+     */
+    register Config(1) {
+        /* the mode */
+        mode uint8; /* trailing */
+    };`
+
+	device, err := parser.Parse(input)
+	require.NoError(t, err)
+
+	code, err := GenerateGo(device, "gentest")
+	require.NoError(t, err)
+	require.Contains(t, code, "// The register\n// takes two lines\ntype Config struct")
+	require.Contains(t, code, "\t// the mode\n\tmode uint8 `pa:\"mode\" order:\"0\" access:\"rw\"` /* trailing */\n")
+}
+
+func TestGenerateGoAccessorDocs(t *testing.T) {
+	input := `
+    device test
+
+    register Config(1) {
+        // the operation mode
+
+        // 0 is off
+        mode uint8;
+        count uint8;
+        data [count]uint8; // the payload
+        temp fixed(int16, 4);
+    };`
+
+	device, err := parser.Parse(input)
+	require.NoError(t, err)
+
+	code, err := GenerateGo(device, "gentest")
+	require.NoError(t, err)
+	require.Contains(t, code, "// GetMode returns value for mode\n//\n// the operation mode\n//\n// 0 is off\nfunc (r *Config) GetMode() uint8 {")
+	require.Contains(t, code, "// SetMode sets value for mode\n//\n// the operation mode\n//\n// 0 is off\nfunc (r *Config) SetMode(v uint8) {")
+	require.Contains(t, code, "otherwise Check reports the mismatch\n//\n// the payload\nfunc (r *Config) SetData(v []uint8) {")
+	require.Contains(t, code, "// GetCount returns value for count\nfunc (r *Config) GetCount() uint8 {")
+	require.Contains(t, code, "// GetTemp returns the fixed-point value of temp\nfunc")
+}
+
+func TestGenerateDeclarationTrailingComments(t *testing.T) {
+	input := `
+    // The device
+    device test // the test device
+
+    // The register
+    register Config(1) {
+        mode uint8;
+    }; /* main config */`
+
+	device, err := parser.Parse(input)
+	require.NoError(t, err)
+
+	code, err := GenerateGo(device, "gentest")
+	require.NoError(t, err)
+	require.Contains(t, code, "// The device\n// the test device\n")
+	require.Contains(t, code, "// The register\n// main config\ntype Config struct")
+
+	hpp, _, err := GenerateHppCpp(device, "test", "test_h")
+	require.NoError(t, err)
+	require.Contains(t, hpp, "// The register\n// main config\n")
+}
+
+func TestGenerateGoDeviceConstants(t *testing.T) {
+	input := `
+    device test
+
+    // protocol version
+    const Version = uint8(2);
+    const MaxPayload = uint16(0x100);
+
+    register Control(1) {
+        const Limit = uint8(10);
+        mode uint8;
+    };`
+
+	device, err := parser.Parse(input)
+	require.NoError(t, err)
+
+	code, err := GenerateGo(device, "gentest")
+	require.NoError(t, err)
+	require.Contains(t, code, "const (\n\t// protocol version\n\tVersion    uint8  = 2\n\tMaxPayload uint16 = 0x100\n)\n")
+	require.Contains(t, code, "\tControl_Limit uint8 = 10\n")
+
+	runGeneratedGoTest(t, code, `package gentest
+
+import "testing"
+
+func TestDeviceConstants(t *testing.T) {
+	if Version != 2 || MaxPayload != 256 || Control_Limit != 10 {
+		t.Fatalf("unexpected constants %d %d %d", Version, MaxPayload, Control_Limit)
+	}
+}
+`)
+}
+
+func TestGenerateGoDeviceConstantsExported(t *testing.T) {
+	device, err := parser.Parse(`device test
+const protocolVersion = uint8(2);
+register Control(1) {
+    mode uint8;
+};`)
+	require.NoError(t, err)
+
+	code, err := GenerateGo(device, "gentest")
+	require.NoError(t, err)
+	require.Contains(t, code, "\tProtocolVersion uint8 = 2\n")
+	runGeneratedGoTest(t, code, `package gentest
+
+import "testing"
+
+func TestExportedConstant(t *testing.T) {
+	if ProtocolVersion != 2 {
+		t.Fatalf("unexpected constant %d", ProtocolVersion)
+	}
+}
+`)
+
+	code, err = GenerateGoWithOptions(device, "gentest", GoOptions{Unexported: true})
+	require.NoError(t, err)
+	require.Contains(t, code, "\tprotocolVersion uint8 = 2\n")
+
+	// the exported name of the constant is the name of the register
+	device, err = parser.Parse(`device test
+const control = uint8(2);
+register Control(1) {
+    mode uint8;
+};`)
+	require.NoError(t, err)
+	_, err = GenerateGo(device, "gentest")
+	require.ErrorContains(t, err, "device constant 'control' has the Go name Control of another declaration")
+}
+
+func TestGenerateGoReservedNames(t *testing.T) {
+	tests := []struct {
+		input      string
+		unexported bool
+		err        string
+	}{
+		{"register Map(1) { a uint8; };", true, "register 'Map' has the Go name map, which is a Go keyword"},
+		{"register map(1) { a uint8; };", false, "register 'map' has the Go name map, which is a Go keyword"},
+		{"register Error(1) { a uint8; };", true, "register 'Error' has the Go name error, which is a predeclared Go identifier"},
+		{"enum Len uint8 { A = 1 };", true, "enum 'Len' has the Go name len, which is a predeclared Go identifier"},
+		{"register R(1) { a uint8; };\nmessage Select { R; };", true, "message 'Select' has the Go name select, which is a Go keyword"},
+		{"const string = uint8(1);", true, "device constant 'string' has the Go name string, which is a predeclared Go identifier"},
+		{"register R(1) { type uint8; };", false, "field 'type' in register 'R' is a Go keyword"},
+	}
+	for _, tt := range tests {
+		device, err := parser.Parse("device test\n" + tt.input)
+		require.NoError(t, err, tt.input)
+		_, err = GenerateGoWithOptions(device, "gentest", GoOptions{Unexported: tt.unexported})
+		require.EqualError(t, err, tt.err)
+	}
+
+	// the exported names and the fields named as the predeclared identifiers are valid
+	device, err := parser.Parse(`device test
+const string = uint8(1);
+register Error(1) {
+    len uint8;
+    error uint8;
+};`)
+	require.NoError(t, err)
+	code, err := GenerateGo(device, "gentest")
+	require.NoError(t, err)
+	require.Contains(t, code, "type Error struct {")
+}
+
+func TestGenerateGoConstantDocs(t *testing.T) {
+	input := `
+    // the device
+    device test
+
+    const Version = uint8(2);
+
+    // Control register
+    register Control(1) {
+        // the lower limit
+        const Low = uint8(1);
+        level uint8;
+        /* the upper
+           limit */
+        const High = uint8(10);
+
+        // unrelated comment
+
+        // the default
+        const Default = uint8(5);
+        flags uint8{a: 0, b: 1};
+    };`
+
+	device, err := parser.Parse(input)
+	require.NoError(t, err)
+
+	code, err := GenerateGo(device, "gentest")
+	require.NoError(t, err)
+	require.Contains(t, code, "\n// the device\n\nconst (\n\tVersion uint8 = 2\n)\n",
+		"the device comment is not attached to the constant")
+	require.Contains(t, code, `// Control register
+type Control struct {
+	level uint8 `+"`"+`pa:"level" order:"0" access:"rw"`+"`"+`
+	flags uint8 `+"`"+`pa:"flags" order:"1" access:"rw"`+"`"+`
+}
+
+const (
+	// the lower limit
+	Control_Low uint8 = 1
+	// the upper
+	// limit
+	Control_High uint8 = 10
+
+	// unrelated comment
+
+	// the default
+	Control_Default uint8 = 5
+)
+
+// a bit field (bits 0)
+const Control_flags_a_bm uint8 = 0x1
+`)
+}
+
+func TestGenerateGoConstantTrailingComments(t *testing.T) {
+	input := `
+    device test
+
+    const Version = uint8(2); // major only
+
+    register Control(1) {
+        // the upper limit
+        const High = uint8(10); /* inclusive */
+        level uint8;
+    };`
+
+	device, err := parser.Parse(input)
+	require.NoError(t, err)
+
+	code, err := GenerateGo(device, "gentest")
+	require.NoError(t, err)
+	require.Contains(t, code, "const (\n\t// major only\n\tVersion uint8 = 2\n)\n")
+	require.Contains(t, code, "const (\n\t// the upper limit\n\t// inclusive\n\tControl_High uint8 = 10\n)\n")
+}
+
+func TestGenerateGoString(t *testing.T) {
+	input := `
+    device test
+
+    register Info(1) {
+        name string;
+        version string(uint16, 4) @le;
+        id uint8;
+    };`
+
+	device, err := parser.Parse(input)
+	require.NoError(t, err)
+
+	code, err := GenerateGo(device, "gentest")
+	require.NoError(t, err)
+	require.Contains(t, code, "\tname    string `pa:\"name\" order:\"0\" access:\"rw\"`\n")
+	require.Contains(t, code, "return &FieldError{Register: \"Info\", Field: \"version\", Err: fmt.Errorf(\"%w: length %d exceeds the maximum length 4\", ErrStringTooLong, len(r.version))}")
+	// the length of the string without the explicit maximum is limited by its prefix type
+	require.Contains(t, code, "\t\tif n > 4 {\n")
+	require.NotContains(t, code, "if n > 255 {")
+
+	runGeneratedGoTest(t, code, `package gentest
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestString(t *testing.T) {
+	// empty strings
+	src := Info{id: 7}
+	buf := make([]byte, src.BufSize4Write())
+	if n, err := src.SerializeWrite(buf); err != nil || n != 4 {
+		t.Fatalf("n=%d err=%v", n, err)
+	}
+	if !bytes.Equal(buf, []byte{0, 0, 0, 7}) {
+		t.Fatalf("unexpected wire bytes % x", buf)
+	}
+	dst := Info{name: "old"}
+	if _, err := dst.DeserializeWrite(buf); err != nil || dst != src {
+		t.Fatalf("dst=%+v err=%v", dst, err)
+	}
+
+	// strings of the maximum length
+	src = Info{name: strings.Repeat("n", 255), version: "v1.2", id: 1}
+	buf = make([]byte, src.BufSize4Write())
+	if n, err := src.SerializeWrite(buf); err != nil || n != 1+255+2+4+1 {
+		t.Fatalf("n=%d err=%v", n, err)
+	}
+	if buf[0] != 255 || !bytes.Equal(buf[256:262], []byte{4, 0, 'v', '1', '.', '2'}) {
+		t.Fatalf("unexpected wire bytes % x", buf[250:])
+	}
+	if _, err := dst.DeserializeWrite(buf); err != nil || dst != src {
+		t.Fatalf("dst=%+v err=%v", dst, err)
+	}
+	if _, err := dst.DeserializeWrite(buf[:100]); err == nil {
+		t.Fatal("truncated buffer must be reported")
+	}
+
+	// strings exceeding the maximum length
+	src.version = "v1.23"
+	if _, err := src.SerializeWrite(make([]byte, 300)); err == nil {
+		t.Fatal("too long version must be reported")
+	}
+	src.version, src.name = "", strings.Repeat("n", 256)
+	if _, err := src.SerializeWrite(make([]byte, 300)); err == nil {
+		t.Fatal("too long name must be reported")
+	}
+
+	// the received length exceeding the maximum length
+	var fe *FieldError
+	_, err := dst.DeserializeWrite([]byte{0, 5, 0, 'v', '1', '.', '2', '3', 1})
+	if !errors.Is(err, ErrStringTooLong) || !errors.As(err, &fe) || fe.Field != "version" {
+		t.Fatalf("too long received version must be reported, got %v", err)
+	}
+}
+`)
+}
+
+func TestGenerateGoFraming(t *testing.T) {
+	input := `
+    device test
+
+    register Control(1) {
+        mode uint8;
+        count uint8;
+        data [count]uint16;
+    };
+
+    register Status(2):r {
+        value uint16;
+    };`
+
+	device, err := parser.Parse(input)
+	require.NoError(t, err)
+
+	code, err := GenerateGo(device, "gentest")
+	require.NoError(t, err)
+	require.NotContains(t, code, "FrameWrite")
+
+	_, err = GenerateGoWithOptions(device, "gentest", GoOptions{FrameCRC: true})
+	require.Error(t, err)
+
+	code, err = GenerateGoWithOptions(device, "gentest", GoOptions{Framing: true})
+	require.NoError(t, err)
+	require.Contains(t, code, "func FrameWrite(r interface{}, w io.Writer) error {")
+	require.Contains(t, code, "func FrameRead(rd io.Reader) (interface{}, error) {")
+	require.NotContains(t, code, "crc16Ccitt")
+
+	runGeneratedGoTest(t, code, `package gentest
+
+import (
+	"bytes"
+	"io"
+	"testing"
+)
+
+func TestFraming(t *testing.T) {
+	var buf bytes.Buffer
+	if err := FrameWrite(&Control{mode: 7, count: 2, data: []uint16{0x0102, 0x0304}}, &buf); err != nil {
+		t.Fatal(err)
+	}
+	if err := FrameWrite(&Status{value: 0x0506}, &buf); err != nil {
+		t.Fatal(err)
+	}
+	if err := FrameWrite(Status{}, &buf); err == nil {
+		t.Fatal("not a register pointer must be reported")
+	}
+	expected := []byte{1, 0, 6, 7, 2, 1, 2, 3, 4, 2, 0, 2, 5, 6}
+	if !bytes.Equal(buf.Bytes(), expected) {
+		t.Fatalf("unexpected frames % x", buf.Bytes())
+	}
+
+	r, err := FrameRead(&buf)
+	if c, ok := r.(*Control); err != nil || !ok || c.mode != 7 || len(c.data) != 2 || c.data[1] != 0x0304 {
+		t.Fatalf("r=%+v err=%v", r, err)
+	}
+	r, err = FrameRead(&buf)
+	if s, ok := r.(*Status); err != nil || !ok || s.value != 0x0506 {
+		t.Fatalf("r=%+v err=%v", r, err)
+	}
+	if _, err := FrameRead(&buf); err != io.EOF {
+		t.Fatalf("expected io.EOF, got %v", err)
+	}
+
+	if _, err := FrameRead(bytes.NewReader([]byte{9, 0, 0})); err == nil {
+		t.Fatal("unknown register id must be reported")
+	}
+	if _, err := FrameRead(bytes.NewReader([]byte{2, 0, 3})); err != io.ErrUnexpectedEOF {
+		t.Fatalf("expected io.ErrUnexpectedEOF, got %v", err)
+	}
+	if _, err := FrameRead(bytes.NewReader([]byte{2, 0, 3, 5, 6, 7})); err == nil {
+		t.Fatal("the payload trailing bytes must be reported")
+	}
+}
+`)
+
+	code, err = GenerateGoWithOptions(device, "gentest", GoOptions{Framing: true, FrameCRC: true})
+	require.NoError(t, err)
+	require.Contains(t, code, "func crc16Ccitt(data []byte) uint16 {")
+
+	runGeneratedGoTest(t, code, `package gentest
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestFramingCRC(t *testing.T) {
+	var buf bytes.Buffer
+	if err := FrameWrite(&Status{value: 0x0506}, &buf); err != nil {
+		t.Fatal(err)
+	}
+	if err := FrameWrite(&Control{mode: 1}, &buf); err != nil {
+		t.Fatal(err)
+	}
+	frames := buf.Bytes()
+	if len(frames) != 7+7 || !bytes.Equal(frames[:5], []byte{2, 0, 2, 5, 6}) {
+		t.Fatalf("unexpected frames % x", frames)
+	}
+	if crc := crc16Ccitt(frames[:5]); frames[5] != byte(crc>>8) || frames[6] != byte(crc) {
+		t.Fatalf("unexpected crc % x", frames[5:7])
+	}
+
+	r, err := FrameRead(bytes.NewReader(frames))
+	if s, ok := r.(*Status); err != nil || !ok || s.value != 0x0506 {
+		t.Fatalf("r=%+v err=%v", r, err)
+	}
+	rd := bytes.NewReader(frames[7:])
+	if r, err := FrameRead(rd); err != nil || r.(*Control).mode != 1 {
+		t.Fatalf("r=%+v err=%v", r, err)
+	}
+
+	frames[4] ^= 0xff
+	if _, err := FrameRead(bytes.NewReader(frames)); err == nil {
+		t.Fatal("corrupted frame must be reported")
+	}
+}
+`)
+}
+
+func TestGenerateGoReserved(t *testing.T) {
+	input := `
+    device test
+
+    register Frame(1) {
+        reserved [3]uint8;
+        value uint16;
+        reserved:r uint16;
+    };
+
+    register Pad(2) {
+        reserved [0x2]uint16;
+    };`
+
+	device, err := parser.Parse(input)
+	require.NoError(t, err)
+
+	code, err := GenerateGo(device, "gentest")
+	require.NoError(t, err)
+	require.Contains(t, code, "// reserved 3 byte(s)")
+	require.Contains(t, code, "// reserved 4 byte(s)")
+	require.NotContains(t, code, "GetReserved")
+
+	runGeneratedGoTest(t, code, `package gentest
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestReserved(t *testing.T) {
+	src := Frame{value: 0x0102}
+	if src.BufSize4Read() != 7 || src.BufSize4Write() != 5 {
+		t.Fatalf("unexpected sizes %d, %d", src.BufSize4Read(), src.BufSize4Write())
+	}
+	buf := bytes.Repeat([]byte{0xff}, 7)
+	if n, err := src.SerializeRead(buf); err != nil || n != 7 {
+		t.Fatalf("n=%d err=%v", n, err)
+	}
+	if !bytes.Equal(buf, []byte{0, 0, 0, 1, 2, 0, 0}) {
+		t.Fatalf("unexpected wire bytes % x", buf)
+	}
+
+	// the reserved bytes are skipped whatever they contain
+	var dst Frame
+	if n, err := dst.DeserializeWrite([]byte{9, 9, 9, 1, 2}); err != nil || n != 5 || dst.value != 0x0102 {
+		t.Fatalf("n=%d err=%v dst=%+v", n, err, dst)
+	}
+	if _, err := dst.DeserializeRead([]byte{9, 9, 9, 1, 2, 0}); err == nil {
+		t.Fatal("truncated buffer must be reported")
+	}
+}
+`)
+}
+
+func TestGenerateGoFieldOffset(t *testing.T) {
+	input := `
+    device test
+
+    register Map(1) {
+        id uint8;
+        status:r uint16 @offset 4;
+        mode:w uint8 @offset 8;
+        value uint16 @offset 10 @le;
+        count uint8;
+        data [count]uint8;
+    };`
+
+	device, err := parser.Parse(input)
+	require.NoError(t, err)
+
+	code, err := GenerateGo(device, "gentest")
+	require.NoError(t, err)
+	require.Contains(t, code, "func (r *Map) BufSize4Read() int {\n\tsize := 13\n")
+	require.Contains(t, code, "func (r *Map) BufSize4Write() int {\n\tsize := 13\n")
+	require.Contains(t, code, "\tclear(buf[offset : offset+7])\n\toffset += 7\n")
+
+	runGeneratedGoTest(t, code, `package gentest
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+)
+
+func TestFieldOffset(t *testing.T) {
+	src := Map{id: 1, status: 0x0203, mode: 5, value: 0x0607}
+	src.SetData([]uint8{9})
+	if src.BufSize4Read() != 14 || src.BufSize4Write() != 14 {
+		t.Fatalf("unexpected sizes %d, %d", src.BufSize4Read(), src.BufSize4Write())
+	}
+
+	// the fields are at their offsets in both directions, the gaps are zeros
+	buf := bytes.Repeat([]byte{0xff}, 14)
+	if n, err := src.SerializeRead(buf); err != nil || n != 14 {
+		t.Fatalf("n=%d err=%v", n, err)
+	}
+	if !bytes.Equal(buf, []byte{1, 0, 0, 0, 2, 3, 0, 0, 0, 0, 7, 6, 1, 9}) {
+		t.Fatalf("unexpected read bytes % x", buf)
+	}
+	if n, err := src.SerializeWrite(buf); err != nil || n != 14 {
+		t.Fatalf("n=%d err=%v", n, err)
+	}
+	if !bytes.Equal(buf, []byte{1, 0, 0, 0, 0, 0, 0, 0, 5, 0, 7, 6, 1, 9}) {
+		t.Fatalf("unexpected write bytes % x", buf)
+	}
+
+	// the gaps are skipped whatever they contain
+	var dst Map
+	if n, err := dst.DeserializeRead([]byte{1, 9, 9, 9, 2, 3, 9, 9, 9, 9, 7, 6, 1, 9}); err != nil || n != 14 {
+		t.Fatalf("n=%d err=%v", n, err)
+	}
+	if dst.id != 1 || dst.status != 0x0203 || dst.value != 0x0607 || !bytes.Equal(dst.data, []uint8{9}) {
+		t.Fatalf("unexpected register %+v", dst)
+	}
+	if _, err := dst.DeserializeWrite(buf[:5]); !errors.Is(err, ErrBufferTooSmall) {
+		t.Fatalf("truncated gap must be reported, got %v", err)
+	}
+}
+`)
+}
+
+func TestGenerateGoFloat16(t *testing.T) {
+	input := `
+    device test
+
+    register Half(1) {
+        zero float16;
+        tiny float16;
+        inf float16;
+        value float16 @le;
+        values [2]float16;
+        count uint8;
+        items [count]float16;
+    };`
+
+	device, err := parser.Parse(input)
+	require.NoError(t, err)
+
+	code, err := GenerateGo(device, "gentest")
+	require.NoError(t, err)
+	require.Contains(t, code, "\tvalues [2]float32 `pa:")
+	require.Contains(t, code, "func (r *Half) BufSize4Read() int {\n\tsize := 13\n")
+
+	runGeneratedGoTest(t, code, `package gentest
+
+import (
+	"bytes"
+	"math"
+	"testing"
+)
+
+func TestFloat16(t *testing.T) {
+	// the tie 1+2^-11 rounds to the even 1 and 1e-8 is below the half of the smallest subnormal
+	src := Half{tiny: float32(math.Ldexp(1, -24)), inf: float32(math.Inf(1)), value: -1.5, values: [2]float32{65504, 0.5}}
+	src.SetItems([]float32{1.00048828125, 1e-8})
+	buf := make([]byte, src.BufSize4Write())
+	if n, err := src.SerializeWrite(buf); err != nil || n != 17 {
+		t.Fatalf("n=%d err=%v", n, err)
+	}
+	want := []byte{0, 0, 0, 1, 0x7c, 0, 0, 0xbe, 0x7b, 0xff, 0x38, 0, 2, 0x3c, 0, 0, 0}
+	if !bytes.Equal(buf, want) {
+		t.Fatalf("unexpected bytes % x", buf)
+	}
+
+	var dst Half
+	if n, err := dst.DeserializeWrite(buf); err != nil || n != 17 {
+		t.Fatalf("n=%d err=%v", n, err)
+	}
+	if dst.zero != 0 || dst.tiny != float32(math.Ldexp(1, -24)) || !math.IsInf(float64(dst.inf), 1) || dst.value != -1.5 ||
+		dst.values != [2]float32{65504, 0.5} || len(dst.items) != 2 || dst.items[0] != 1 || dst.items[1] != 0 {
+		t.Fatalf("unexpected register %+v", dst)
+	}
+
+	// the values too large for the half precision are infinities, NaN stays NaN
+	src = Half{zero: 1e6, tiny: float32(math.NaN())}
+	src.SetItems(nil)
+	if _, err := src.SerializeWrite(buf); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(buf[:4], []byte{0x7c, 0, 0x7e, 0}) {
+		t.Fatalf("unexpected bytes % x", buf[:4])
+	}
+	if _, err := dst.DeserializeWrite(buf); err != nil || !math.IsInf(float64(dst.zero), 1) || !math.IsNaN(float64(dst.tiny)) {
+		t.Fatalf("unexpected register %+v, err=%v", dst, err)
+	}
+}
+`)
+}
+
+func TestGenerateGoMarshalBinary(t *testing.T) {
+	input := `
+    device test
+
+    register Control(1) {
+        mode uint8;
+        level:r uint16;
+        count uint8;
+        data [count]uint16;
+    };
+
+    register Status(2):r {
+        value uint16;
+    };`
+
+	device, err := parser.Parse(input)
+	require.NoError(t, err)
+
+	code, err := GenerateGo(device, "gentest")
+	require.NoError(t, err)
+	require.Contains(t, code, "func (r *Control) MarshalBinary() ([]byte, error) {\n\tbuf := make([]byte, r.BufSize4Write())")
+	require.Contains(t, code, "func (r *Status) MarshalBinary() ([]byte, error) {\n\tbuf := make([]byte, r.BufSize4Read())")
+
+	runGeneratedGoTest(t, code, `package gentest
+
+import (
+	"bytes"
+	"encoding"
+	"encoding/gob"
+	"testing"
+)
+
+var (
+	_ encoding.BinaryMarshaler   = (*Control)(nil)
+	_ encoding.BinaryUnmarshaler = (*Control)(nil)
+)
+
+func TestMarshalBinary(t *testing.T) {
+	src := &Control{mode: 3, level: 7, count: 2, data: []uint16{0x0102, 5}}
+	data, err := src.MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+	// the level is read only, so it is not encoded
+	if !bytes.Equal(data, []byte{3, 2, 1, 2, 0, 5}) {
+		t.Fatalf("unexpected data % x", data)
+	}
+
+	var dst Control
+	if err := dst.UnmarshalBinary(data); err != nil {
+		t.Fatal(err)
+	}
+	if dst.mode != 3 || dst.count != 2 || len(dst.data) != 2 || dst.data[0] != 0x0102 || dst.level != 0 {
+		t.Fatalf("unexpected register %+v", dst)
+	}
+	if err := dst.UnmarshalBinary(append(data, 0)); err == nil {
+		t.Fatal("trailing bytes must be reported")
+	}
+	if err := dst.UnmarshalBinary(data[:3]); err == nil {
+		t.Fatal("truncated data must be reported")
+	}
+
+	// the read-only register encodes its read fields
+	status := &Status{value: 0x0a0b}
+	if data, err := status.MarshalBinary(); err != nil || !bytes.Equal(data, []byte{0x0a, 0x0b}) {
+		t.Fatalf("data=% x err=%v", data, err)
+	}
+
+	// gob uses the encoding interfaces for the types without exported fields
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(src); err != nil {
+		t.Fatal(err)
+	}
+	var decoded Control
+	if err := gob.NewDecoder(&buf).Decode(&decoded); err != nil {
+		t.Fatal(err)
+	}
+	if decoded.mode != 3 || len(decoded.data) != 2 || decoded.data[1] != 5 {
+		t.Fatalf("unexpected register %+v", decoded)
+	}
+}
+`)
+}
+
+func TestGenerateGoAppend(t *testing.T) {
+	input := `
+    device test
+
+    register Control(1) {
+        mode uint8;
+        level:r uint16;
+        count uint8;
+        data [count]uint16;
+        crc crc16(ccitt);
+    };
+
+    register Status(2):r {
+        value uint16;
+    };`
+
+	device, err := parser.Parse(input)
+	require.NoError(t, err)
+
+	code, err := GenerateGo(device, "gentest")
+	require.NoError(t, err)
+	require.Contains(t, code, "func (r *Control) AppendWrite(b []byte) ([]byte, error) {\n\treturn appendRegister(b, r.BufSize4Write(), r.SerializeWrite)")
+
+	runGeneratedGoTest(t, code, `package gentest
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+)
+
+func TestAppend(t *testing.T) {
+	c := Control{mode: 7, level: 9, count: 2, data: []uint16{0x0102, 0x0304}}
+	buf := make([]byte, c.BufSize4Write())
+	if _, err := c.SerializeWrite(buf); err != nil {
+		t.Fatal(err)
+	}
+	b, err := c.AppendWrite([]byte{0xee})
+	if err != nil || !bytes.Equal(b, append([]byte{0xee}, buf...)) {
+		t.Fatalf("b=% x err=%v", b, err)
+	}
+
+	// the checksum covers the register bytes only, not the preceding ones
+	var c2 Control
+	if n, err := c2.DeserializeWrite(b[1:]); err != nil || n != len(buf) || !c2.Equal(&Control{mode: 7, count: 2, data: c.data}) {
+		t.Fatalf("c2=%+v n=%d err=%v", c2, n, err)
+	}
+
+	buf = make([]byte, c.BufSize4Read())
+	if _, err := c.SerializeRead(buf); err != nil {
+		t.Fatal(err)
+	}
+	if b, err := c.AppendRead(nil); err != nil || !bytes.Equal(b, buf) {
+		t.Fatalf("b=% x err=%v", b, err)
+	}
+
+	// a multi-register message is built without sizing the buffer up front
+	s := Status{value: 0x0506}
+	b, err = s.AppendRead(b[:0])
+	if err == nil {
+		b, err = c.AppendWrite(b)
+	}
+	if err != nil || !bytes.Equal(b[:2], []byte{5, 6}) || len(b) != 2+c.BufSize4Write() {
+		t.Fatalf("b=% x err=%v", b, err)
+	}
+
+	c.count = 3
+	if b2, err := c.AppendWrite(b); !errors.Is(err, ErrArrayLengthMismatch) || !bytes.Equal(b2, b) {
+		t.Fatalf("b2=% x err=%v", b2, err)
+	}
+}
+`)
+}
+
+func TestGenerateGoWriteToReadFrom(t *testing.T) {
+	input := `
+    device test
+
+    register Config(1) {
+        size uint8;
+        values [size]uint16;
+    };
+
+    register Control(2) {
+        flags uint8{ready: 0, count: 1-3};
+        items [flags_count]int32;
+        config Config;
+        name string;
+        tail uint16;
+    };`
+
+	device, err := parser.Parse(input)
+	require.NoError(t, err)
+
+	code, err := GenerateGo(device, "gentest")
+	require.NoError(t, err)
+	require.Contains(t, code, "func (r *Control) WriteTo(w io.Writer) (int64, error) {")
+	require.Contains(t, code, "func (r *Control) ReadFrom(rd io.Reader) (int64, error) {")
+
+	runGeneratedGoTest(t, code, `package gentest
+
+import (
+	"errors"
+	"io"
+	"testing"
+)
+
+var (
+	_ io.WriterTo   = (*Control)(nil)
+	_ io.ReaderFrom = (*Control)(nil)
+)
+
+func TestStreaming(t *testing.T) {
+	src := &Control{
+		flags:  0x05,
+		items:  []int32{-1, 7},
+		config: Config{size: 3, values: []uint16{1, 2, 3}},
+		name:   "abc",
+		tail:   0x0102,
+	}
+	size := src.BufSize4Write()
+
+	pr, pw := io.Pipe()
+	go func() {
+		// two registers in a row, the reader must not consume the second one
+		for i := 0; i < 2; i++ {
+			if n, err := src.WriteTo(pw); err != nil || n != int64(size) {
+				pw.CloseWithError(errors.New("write failed"))
+				return
+			}
+		}
+		pw.Close()
+	}()
+
+	for i := 0; i < 2; i++ {
+		var dst Control
+		n, err := dst.ReadFrom(pr)
+		if err != nil || n != int64(size) {
+			t.Fatalf("n=%d err=%v", n, err)
+		}
+		if dst.flags != src.flags || len(dst.items) != 2 || dst.items[0] != -1 || len(dst.config.values) != 3 ||
+			dst.config.values[2] != 3 || dst.name != "abc" || dst.tail != 0x0102 {
+			t.Fatalf("unexpected register %+v", dst)
+		}
+	}
+
+	var dst Control
+	if _, err := dst.ReadFrom(pr); err != io.EOF {
+		t.Fatalf("expected EOF, got %v", err)
+	}
+}
+
+func TestReadFromTruncated(t *testing.T) {
+	src := &Control{config: Config{size: 1, values: []uint16{1}}, name: "abc"}
+	buf, err := src.MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+	pr, pw := io.Pipe()
+	go func() {
+		pw.Write(buf[:len(buf)-3])
+		pw.Close()
+	}()
+	var dst Control
+	if _, err := dst.ReadFrom(pr); err != io.ErrUnexpectedEOF {
+		t.Fatalf("expected unexpected EOF, got %v", err)
+	}
+}
+`)
+}
+
+func TestGenerateGoClone(t *testing.T) {
+	input := `
+    device test
+
+    register Config(1) {
+        size uint8;
+        values [size]uint16;
+    };
+
+    register Control(2) {
+        mode uint8;
+        fixed [2]uint8;
+        count uint8;
+        items [count]int32;
+        config Config;
+    };`
+
+	device, err := parser.Parse(input)
+	require.NoError(t, err)
+
+	code, err := GenerateGo(device, "gentest")
+	require.NoError(t, err)
+	require.Contains(t, code, "\tc.items = slices.Clone(r.items)\n")
+	require.Contains(t, code, "\tc.config = *r.config.Clone()\n")
+
+	runGeneratedGoTest(t, code, `package gentest
+
+import "testing"
+
+func TestClone(t *testing.T) {
+	orig := &Control{
+		mode:   1,
+		fixed:  [2]uint8{1, 2},
+		count:  2,
+		items:  []int32{1, 2},
+		config: Config{size: 1, values: []uint16{5}},
+	}
+	c := orig.Clone()
+	if c == orig || c.mode != 1 || c.fixed != orig.fixed || len(c.items) != 2 || c.config.values[0] != 5 {
+		t.Fatalf("unexpected clone %+v", c)
+	}
+
+	c.mode = 2
+	c.fixed[0] = 9
+	c.items[0] = 9
+	c.config.values[0] = 9
+	if orig.mode != 1 || orig.fixed[0] != 1 || orig.items[0] != 1 || orig.config.values[0] != 5 {
+		t.Fatalf("the original is changed %+v", orig)
+	}
+
+	var empty Control
+	if c := empty.Clone(); c.items != nil || c.config.values != nil {
+		t.Fatalf("nil slices must stay nil %+v", c)
+	}
+}
+`)
+}
+
+func TestGenerateGoReset(t *testing.T) {
+	input := `
+    device test
+
+    register Config(1) {
+        size uint8;
+        values [size]uint16;
+    };
+
+    register Control(2) {
+        mode uint8;
+        flags uint8{ready: 0};
+        name string;
+        fixed [2]uint8;
+        count uint8;
+        items [count]int32;
+        config Config;
+        configs [2]Config;
+        reserved uint8;
+    };`
+
+	device, err := parser.Parse(input)
+	require.NoError(t, err)
+
+	code, err := GenerateGo(device, "gentest")
+	require.NoError(t, err)
+	require.Contains(t, code, "\tr.items = r.items[:0]\n")
+	require.Contains(t, code, "\tr.config.Reset()\n")
+
+	runGeneratedGoTest(t, code, `package gentest
+
+import "testing"
+
+func TestReset(t *testing.T) {
+	r := &Control{
+		mode:    1,
+		flags:   1,
+		name:    "name",
+		fixed:   [2]uint8{1, 2},
+		count:   2,
+		items:   []int32{1, 2},
+		config:  Config{size: 1, values: []uint16{5}},
+		configs: [2]Config{{size: 1, values: []uint16{6}}},
+	}
+	r.Reset()
+	if !r.Equal(&Control{}) {
+		t.Fatalf("the register is not zeroed %+v", r)
+	}
+	if cap(r.items) != 2 || cap(r.config.values) != 1 || cap(r.configs[0].values) != 1 {
+		t.Fatalf("the slices capacity is not retained %+v", r)
+	}
+}
+`)
+}
+
+func TestGenerateGoEqual(t *testing.T) {
+	input := `
+    device test
+
+    register Config(1) {
+        size uint8;
+        values [size]uint16;
+    };
+
+    register Control(2) {
+        mode uint8;
+        reserved [2]uint8;
+        flags uint8{ready: 0};
+        fixed [2]float32;
+        count uint8;
+        items [count]int32;
+        name string;
+        config Config;
+    };`
+
+	device, err := parser.Parse(input)
+	require.NoError(t, err)
+
+	code, err := GenerateGo(device, "gentest")
+	require.NoError(t, err)
+	require.Contains(t, code, "\tif !slices.Equal(r.items, o.items) {\n")
+	require.Contains(t, code, "\tif !r.config.Equal(&o.config) {\n")
+
+	runGeneratedGoTest(t, code, `package gentest
+
+import "testing"
+
+func TestEqual(t *testing.T) {
+	newControl := func() *Control {
+		return &Control{
+			mode:   1,
+			flags:  1,
+			fixed:  [2]float32{1.5, 2},
+			count:  2,
+			items:  []int32{1, 2},
+			name:   "abc",
+			config: Config{size: 1, values: []uint16{5}},
+		}
+	}
+	a, b := newControl(), newControl()
+	if !a.Equal(b) || !b.Equal(a) || !a.Equal(a.Clone()) {
+		t.Fatal("equal registers are reported different")
+	}
+
+	b.mode = 2
+	if a.Equal(b) {
+		t.Fatal("different scalars are not detected")
+	}
+	b = newControl()
+	b.fixed[1] = 3
+	if a.Equal(b) {
+		t.Fatal("different fixed arrays are not detected")
+	}
+	b = newControl()
+	b.items = append(b.items, 3)
+	if a.Equal(b) {
+		t.Fatal("different slice lengths are not detected")
+	}
+	b = newControl()
+	b.config.values[0] = 6
+	if a.Equal(b) {
+		t.Fatal("different nested registers are not detected")
+	}
+	b = newControl()
+	b.name = "abd"
+	if a.Equal(b) {
+		t.Fatal("different strings are not detected")
+	}
+
+	// nil and empty arrays are equal
+	if !(&Control{items: []int32{}}).Equal(&Control{}) {
+		t.Fatal("nil and empty arrays must be equal")
+	}
+	var nilControl *Control
+	if !nilControl.Equal(nil) || nilControl.Equal(a) || a.Equal(nil) {
+		t.Fatal("unexpected nil comparison result")
+	}
+}
+`)
+}
+
+func TestGenerateGoReuseSlices(t *testing.T) {
+	input := `
+    device test
+
+    register Control(1) {
+        flags uint8{count: 0-3};
+        bits [flags_count]uint8;
+        size uint8;
+        items [size]int32;
+    };`
+
+	device, err := parser.Parse(input)
+	require.NoError(t, err)
+
+	code, err := GenerateGo(device, "gentest")
+	require.NoError(t, err)
+	require.NotContains(t, code, "cap(r.items)")
+
+	code, err = GenerateGoWithOptions(device, "gentest", GoOptions{ReuseSlices: true})
+	require.NoError(t, err)
+	require.Contains(t, code, "\t\tif cap(r.items) >= int(elems) {\n\t\t\tr.items = r.items[:int(elems)]\n\t\t} else {")
+
+	runGeneratedGoTest(t, code, `package gentest
+
+import "testing"
+
+var data = []byte{0x03, 1, 2, 3, 2, 0, 0, 0, 1, 0, 0, 0, 2}
+
+func TestReuseSlices(t *testing.T) {
+	var r Control
+	if _, err := r.DeserializeWrite(data); err != nil {
+		t.Fatal(err)
+	}
+	items := r.items
+	allocs := testing.AllocsPerRun(100, func() {
+		if _, err := r.DeserializeWrite(data); err != nil {
+			t.Fatal(err)
+		}
+	})
+	if allocs != 0 {
+		t.Fatalf("expected no allocations, got %f", allocs)
+	}
+	if &r.items[0] != &items[0] || len(r.bits) != 3 || r.items[1] != 2 {
+		t.Fatalf("unexpected register %+v", r)
+	}
+
+	// the slice grows if its capacity is not enough
+	r.items = r.items[:0:1]
+	if _, err := r.DeserializeWrite(data); err != nil || len(r.items) != 2 || r.items[1] != 2 {
+		t.Fatalf("unexpected register %+v, err=%v", r, err)
+	}
+}
+
+func BenchmarkDeserializeWrite(b *testing.B) {
+	var r Control
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		if _, err := r.DeserializeWrite(data); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+`)
+}
+
+func TestGenerateGoPool(t *testing.T) {
+	input := `
+    device test
+
+    register Control(1) {
+        mode uint8;
+        count uint8;
+        data [count]uint16;
+    };`
+
+	device, err := parser.Parse(input)
+	require.NoError(t, err)
+
+	code, err := GenerateGo(device, "gentest")
+	require.NoError(t, err)
+	require.NotContains(t, code, "sync.Pool")
+
+	code, err = GenerateGoWithOptions(device, "gentest", GoOptions{Pool: true, ReuseSlices: true})
+	require.NoError(t, err)
+	require.Contains(t, code, "\t\"sync\"\n")
+	require.Contains(t, code, "var controlPool = sync.Pool{New: func() interface{} { return &Control{} }}")
+
+	runGeneratedGoTest(t, code, `package gentest
+
+import "testing"
+
+func TestPool(t *testing.T) {
+	r := AcquireControl()
+	if !r.Equal(&Control{}) {
+		t.Fatalf("the acquired register is not zeroed %+v", r)
+	}
+	r.mode = 7
+	r.SetData([]uint16{1, 2, 3})
+	ReleaseControl(r)
+	if r.mode != 0 || len(r.data) != 0 || cap(r.data) != 3 {
+		t.Fatalf("the released register is not reset %+v", r)
+	}
+
+	for i := 0; i < 10; i++ {
+		r := AcquireControl()
+		if !r.Equal(&Control{}) || len(r.data) != 0 {
+			t.Fatalf("the acquired register is not zeroed %+v", r)
+		}
+		r.SetData([]uint16{uint16(i)})
+		ReleaseControl(r)
+	}
+}
+
+func BenchmarkPool(b *testing.B) {
+	src := Control{count: 3, data: []uint16{1, 2, 3}}
+	buf := make([]byte, src.BufSize4Write())
+	if _, err := src.SerializeWrite(buf); err != nil {
+		b.Fatal(err)
+	}
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		r := AcquireControl()
+		if _, err := r.DeserializeWrite(buf); err != nil {
+			b.Fatal(err)
+		}
+		ReleaseControl(r)
+	}
+}
+`, "-bench=.", "-benchtime=100x")
+}
+
+func TestGenerateGoJSON(t *testing.T) {
+	input := `
+    device test
+
+    enum Mode uint8 { OFF = 0, ON = 1 };
+
+    register Config(1) {
+        mode Mode;
+        name string;
+    };
+
+    register Control(2) {
+        enable uint16{ready: 0, level: 4-6};
+        count uint8;
+        data [count]uint8;
+        samples [enable_level]int16;
+        fixed [2]uint8;
+        reserved uint8;
+        config Config;
+    };`
+
+	device, err := parser.Parse(input)
+	require.NoError(t, err)
+
+	code, err := GenerateGo(device, "gentest")
+	require.NoError(t, err)
+	require.NotContains(t, code, "MarshalJSON")
+
+	code, err = GenerateGoWithOptions(device, "gentest", GoOptions{JSON: true})
+	require.NoError(t, err)
+
+	runGeneratedGoTest(t, code, `package gentest
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestJSON(t *testing.T) {
+	var c Control
+	c.SetEnableReady(true)
+	c.SetEnableLevel(2)
+	c.count = 3
+	c.data = []uint8{1, 2, 3}
+	c.samples = []int16{-1, 1}
+	c.fixed = [2]uint8{4, 5}
+	c.config = Config{mode: Mode_ON, name: "cfg"}
+
+	data, err := json.Marshal(&c)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := `+"`"+`{"enable":{"ready":true,"level":2},"count":3,"data":[1,2,3],"samples":[-1,1],"fixed":[4,5],"config":{"mode":1,"name":"cfg"}}`+"`"+`
+	if string(data) != expected {
+		t.Fatalf("unexpected JSON %s", data)
+	}
+
+	var c2 Control
+	if err := json.Unmarshal(data, &c2); err != nil {
+		t.Fatal(err)
+	}
+	if !c.Equal(&c2) || c2.Check() != nil {
+		t.Fatalf("unexpected register %+v", c2)
+	}
+
+	// the missing fields keep their values
+	if err := json.Unmarshal([]byte(`+"`"+`{"enable":{"level":0},"samples":null,"config":{"name":"new"}}`+"`"+`), &c2); err != nil {
+		t.Fatal(err)
+	}
+	if !c2.GetEnableReady() || c2.GetEnableLevel() != 0 || c2.count != 3 || c2.samples != nil || c2.config.mode != Mode_ON || c2.config.name != "new" {
+		t.Fatalf("unexpected register %+v", c2)
+	}
+}
+`)
+}
+
+func TestGenerateGoStructTags(t *testing.T) {
+	input := `
+    device test
+
+    register Status(1): r {
+        value uint16;
+    };
+
+    register Control(2) {
+        enable:w uint16{ready: 0};
+        reserved uint8;
+        count:r uint8;
+        data [count]uint8;
+        status Status;
+    };`
+
+	device, err := parser.Parse(input)
+	require.NoError(t, err)
+
+	code, err := GenerateGo(device, "gentest")
+	require.NoError(t, err)
+
+	runGeneratedGoTest(t, code, `package gentest
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestStructTags(t *testing.T) {
+	expected := []struct{ name, pa, order, access string }{
+		{"enable", "enable", "0", "w"},
+		{"count", "count", "2", "r"},
+		{"data", "data", "3", "rw"},
+		{"status", "status", "4", "rw"},
+	}
+	typ := reflect.TypeOf(Control{})
+	if typ.NumField() != len(expected) {
+		t.Fatalf("unexpected number of fields %d", typ.NumField())
+	}
+	for i, e := range expected {
+		f := typ.Field(i)
+		if f.Name != e.name || f.Tag.Get("pa") != e.pa || f.Tag.Get("order") != e.order || f.Tag.Get("access") != e.access {
+			t.Fatalf("unexpected field %s with tag %s", f.Name, f.Tag)
+		}
+	}
+
+	f := reflect.TypeOf(Status{}).Field(0)
+	if f.Tag.Get("pa") != "value" || f.Tag.Get("access") != "r" {
+		t.Fatalf("the register specifier is not inherited, tag %s", f.Tag)
+	}
+}
+`)
+}
+
+func TestGenerateGoArraySetters(t *testing.T) {
+	input := `
+    device test
+
+    register Control(1) {
+        flags uint8{one: 0, count: 4-6};
+        bits [flags_count]uint8;
+        single [flags_one]uint16;
+        size uint16;
+        items [size]int32;
+    };`
+
+	device, err := parser.Parse(input)
+	require.NoError(t, err)
+
+	code, err := GenerateGo(device, "gentest")
+	require.NoError(t, err)
+
+	runGeneratedGoTest(t, code, `package gentest
+
+import "testing"
+
+func TestArraySetters(t *testing.T) {
+	r := NewControl()
+	if r.Check() != nil || r.size != 0 || r.flags != 0 {
+		t.Fatalf("the new register must be zeroed %+v", r)
+	}
+
+	r.SetItems([]int32{1, 2, 3})
+	r.SetBits([]uint8{1, 2})
+	r.SetSingle([]uint16{7})
+	if err := r.Check(); err != nil {
+		t.Fatal(err)
+	}
+	if r.size != 3 || r.GetFlagsCount() != 2 || !r.GetFlagsOne() {
+		t.Fatalf("unexpected size fields %+v", r)
+	}
+
+	r.SetBits(nil)
+	r.SetSingle([]uint16{})
+	if r.Check() != nil || r.flags != 0 {
+		t.Fatalf("unexpected size fields %+v", r)
+	}
+
+	// the length which does not fit into the size field is reported by Check
+	r.SetBits(make([]uint8, 8))
+	if r.Check() == nil {
+		t.Fatal("the length overflow is not detected")
+	}
+}
+`)
+}
+
+func TestGenerateGoRegisterRefArray(t *testing.T) {
+	input := `
+    device test
+
+    register Channel(1) {
+        id uint8;
+        count:r uint8;
+        samples:r [count]int16;
+    };
+
+    register Main(2) {
+        channels [2]Channel;
+        flags uint8{n: 0-3};
+        more [flags_n]Channel;
+    };`
+
+	device, err := parser.Parse(input)
+	require.NoError(t, err)
+
+	code, err := GenerateGoWithOptions(device, "gentest", GoOptions{JSON: true})
+	require.NoError(t, err)
+
+	runGeneratedGoTest(t, code, `package gentest
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+)
+
+func newMain() *Main {
+	m := NewMain()
+	m.channels[0] = Channel{id: 1, count: 1, samples: []int16{-2}}
+	m.channels[1] = Channel{id: 2}
+	m.SetMore([]Channel{{id: 3, count: 2, samples: []int16{1, 2}}})
+	return m
+}
+
+func TestRegisterRefArray(t *testing.T) {
+	m := newMain()
+	if m.BufSize4Read() != 1+1+2+1+1+1+1+1+2+2 || m.BufSize4Write() != 4 {
+		t.Fatalf("unexpected buffer sizes %d, %d", m.BufSize4Read(), m.BufSize4Write())
+	}
+
+	buf := make([]byte, m.BufSize4Read())
+	n, err := m.SerializeRead(buf)
+	if err != nil || n != len(buf) {
+		t.Fatalf("n=%d, err=%v", n, err)
+	}
+	expected := []byte{1, 1, 0xFF, 0xFE, 2, 0, 0x01, 3, 2, 0, 1, 0, 2}
+	if !bytes.Equal(buf, expected) {
+		t.Fatalf("unexpected data %v", buf)
+	}
+	var m2 Main
+	if n, err := m2.DeserializeRead(buf); err != nil || n != len(buf) || !m.Equal(&m2) {
+		t.Fatalf("n=%d, err=%v, register %+v", n, err, m2)
+	}
+
+	// the write fields of the nested registers
+	buf = make([]byte, m.BufSize4Write())
+	if n, err := m.SerializeWrite(buf); err != nil || !bytes.Equal(buf[:n], []byte{1, 2, 0x01, 3}) {
+		t.Fatalf("unexpected data %v, err=%v", buf[:n], err)
+	}
+	if _, err := m.SerializeRead(make([]byte, 5)); err == nil {
+		t.Fatal("the short buffer is not detected")
+	}
+
+	// the array length must match its size field
+	m2.flags = 2
+	if _, err := m2.SerializeRead(make([]byte, 100)); err == nil {
+		t.Fatal("the inconsistent array is not detected")
+	}
+}
+
+func TestRegisterRefArrayCloneEqual(t *testing.T) {
+	m := newMain()
+	c := m.Clone()
+	if !m.Equal(c) {
+		t.Fatal("the clone must be equal")
+	}
+	c.channels[0].samples[0] = 5
+	c.more[0].samples[1] = 5
+	if m.channels[0].samples[0] != -2 || m.more[0].samples[1] != 2 || m.Equal(c) {
+		t.Fatal("the clone shares the nested arrays")
+	}
+}
+
+func TestRegisterRefArrayJSON(t *testing.T) {
+	m := newMain()
+	data, err := json.Marshal(m)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := `+"`"+`{"channels":[{"id":1,"count":1,"samples":[-2]},{"id":2,"count":0,"samples":null}],"flags":{"n":1},"more":[{"id":3,"count":2,"samples":[1,2]}]}`+"`"+`
+	if string(data) != expected {
+		t.Fatalf("unexpected JSON %s", data)
+	}
+	var m2 Main
+	if err := json.Unmarshal(data, &m2); err != nil || !m.Equal(&m2) {
+		t.Fatalf("unexpected register %+v, err=%v", m2, err)
+	}
+}
+`)
+}
+
+func TestGenerateGoFixed(t *testing.T) {
+	input := `
+    device test
+
+    register Sensor(1) {
+        temp fixed(int16, 4) @le;
+        ratio fixed(uint8, 8);
+    };`
+
+	device, err := parser.Parse(input)
+	require.NoError(t, err)
+
+	code, err := GenerateGo(device, "gentest")
+	require.NoError(t, err)
+	require.Contains(t, code, "temp  int16")
+	require.Contains(t, code, "func (r *Sensor) GetTemp() float64 {\n\treturn float64(r.temp) / 16\n}")
+	require.Contains(t, code, "func (r *Sensor) SetTempRaw(v int16) {")
+
+	runGeneratedGoTest(t, code, `package gentest
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestFixed(t *testing.T) {
+	var s Sensor
+	s.SetTemp(-21.5625)
+	s.SetRatio(0.25)
+	if s.GetTempRaw() != -345 || s.GetRatioRaw() != 64 {
+		t.Fatalf("unexpected raw values %d %d", s.GetTempRaw(), s.GetRatioRaw())
+	}
+
+	// the raw integers are sent over the wire
+	buf := make([]byte, s.BufSize4Read())
+	n, err := s.SerializeRead(buf)
+	if err != nil || n != 3 || !bytes.Equal(buf, []byte{0xa7, 0xfe, 64}) {
+		t.Fatalf("unexpected data %v %d %v", buf, n, err)
+	}
+
+	var d Sensor
+	if _, err := d.DeserializeRead(buf); err != nil {
+		t.Fatal(err)
+	}
+	if d.GetTemp() != -21.5625 || d.GetRatio() != 0.25 {
+		t.Fatalf("unexpected values %v %v", d.GetTemp(), d.GetRatio())
+	}
+
+	// the value is rounded to the nearest step
+	d.SetTemp(1.03)
+	if d.GetTempRaw() != 16 {
+		t.Fatalf("unexpected rounding %d", d.GetTempRaw())
+	}
+}
+`)
+}
+
+func TestGenerateGoCRC(t *testing.T) {
+	input := `
+    device test
+
+    register Modbus(1) {
+        data [9]uint8;
+        crc crc16(modbus) @le;
+    };
+
+    register Ccitt(2) {
+        data [9]uint8;
+        crc crc16;
+    };
+
+    register Ieee(3) {
+        data [9]uint8;
+        crc:r crc32;
+    };`
+
+	device, err := parser.Parse(input)
+	require.NoError(t, err)
+
+	code, err := GenerateGo(device, "gentest")
+	require.NoError(t, err)
+	require.Contains(t, code, "if err := putNumberOrder(buf[offset:], crc16Modbus(buf[:offset]), binary.LittleEndian); err != nil {")
+	require.Contains(t, code, "\"hash/crc32\"")
+
+	runGeneratedGoTest(t, code, `package gentest
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+)
+
+var check = [9]uint8{'1', '2', '3', '4', '5', '6', '7', '8', '9'}
+
+func TestCRC(t *testing.T) {
+	for _, tt := range []struct {
+		reg interface {
+			BufSize4Read() int
+			SerializeRead([]byte) (int, error)
+			DeserializeRead([]byte) (int, error)
+		}
+		crc []byte
+	}{
+		{&Modbus{data: check}, []byte{0x37, 0x4b}},
+		{&Ccitt{data: check}, []byte{0x29, 0xb1}},
+		{&Ieee{data: check}, []byte{0xcb, 0xf4, 0x39, 0x26}},
+	} {
+		buf := make([]byte, tt.reg.BufSize4Read())
+		n, err := tt.reg.SerializeRead(buf)
+		if err != nil || n != len(buf) || !bytes.HasSuffix(buf, tt.crc) {
+			t.Fatalf("unexpected data % x %d %v", buf, n, err)
+		}
+		if n, err := tt.reg.DeserializeRead(buf); err != nil || n != len(buf) {
+			t.Fatalf("deserialization failed %d %v", n, err)
+		}
+
+		buf[len(buf)-len(tt.crc)-1]++
+		if _, err := tt.reg.DeserializeRead(buf); err == nil || !errors.Is(err, ErrChecksumMismatch) {
+			t.Fatalf("the corrupted buffer is not detected: %v", err)
+		}
+	}
+
+	// the checksum is only in the read fields of Ieee
+	r := Ieee{data: check}
+	if r.BufSize4Write() != 9 {
+		t.Fatalf("unexpected write size %d", r.BufSize4Write())
+	}
+}
+`)
+}
+
+func TestGenerateGoSignedBitMember(t *testing.T) {
+	input := `
+    device test
+
+    register Control(1) {
+        flags uint8{ready: 0, offset: signed 4-7};
+        wide uint32{value: signed 8-19};
+    };`
+
+	device, err := parser.Parse(input)
+	require.NoError(t, err)
+
+	code, err := GenerateGoWithOptions(device, "gentest", GoOptions{BitfieldStrings: true})
+	require.NoError(t, err)
+	require.Contains(t, code, "func (r *Control) GetFlagsOffset() int8 {\n\treturn int8(r.flags<<0) >> 4\n}")
+
+	runGeneratedGoTest(t, code, `package gentest
+
+import "testing"
+
+func TestSignedBitMember(t *testing.T) {
+	r := Control{flags: 0xf1}
+	if r.GetFlagsOffset() != -1 || !r.GetFlagsReady() {
+		t.Fatalf("unexpected offset %d", r.GetFlagsOffset())
+	}
+	r.SetFlagsOffset(-8)
+	if r.flags != 0x81 || r.GetFlagsOffset() != -8 {
+		t.Fatalf("unexpected flags 0x%x", r.flags)
+	}
+	r.SetFlagsOffset(7)
+	if r.flags != 0x71 || r.GetFlagsOffset() != 7 {
+		t.Fatalf("unexpected flags 0x%x", r.flags)
+	}
+	if s := r.FlagsString(); s != "ready|offset=7" {
+		t.Fatalf("unexpected string %q", s)
+	}
+
+	r.SetWideValue(-2)
+	if r.wide != 0x000ffe00 || r.GetWideValue() != -2 {
+		t.Fatalf("unexpected wide 0x%x", r.wide)
+	}
+}
+`)
+}
+
+func TestGenerateGoBitFieldGroups(t *testing.T) {
+	input := `
+    device test
+
+    register Control(1) {
+        control uint16{ctrl: {enable: 0, mode: 1-2}, irq: {mask: 8-11, level: signed 12-15}};
+        data [control_ctrl_mode]uint8;
+    };`
+
+	device, err := parser.Parse(input)
+	require.NoError(t, err)
+
+	code, err := GenerateGo(device, "gentest")
+	require.NoError(t, err)
+	require.Contains(t, code, "const Control_control_ctrl_mode_bm uint16 = 0x6\n")
+	require.Contains(t, code, "const Control_control_irq_mask_bm uint16 = 0xF00\n")
+
+	runGeneratedGoTest(t, code, `package gentest
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestBitFieldGroups(t *testing.T) {
+	r := NewControl()
+	r.SetControlCtrl_enable(true)
+	r.SetControlIrq_mask(0xa)
+	r.SetControlIrq_level(-2)
+	r.data = []uint8{7, 8}
+	r.SetControlCtrl_mode(2)
+
+	// both groups share the single storage word
+	buf := make([]byte, r.BufSize4Write())
+	n, err := r.SerializeWrite(buf)
+	if err != nil || !bytes.Equal(buf[:n], []byte{0xea, 0x05, 0x07, 0x08}) {
+		t.Fatalf("unexpected data %x %v", buf[:n], err)
+	}
+	dst := NewControl()
+	if _, err := dst.DeserializeWrite(buf[:n]); err != nil || !dst.Equal(r) {
+		t.Fatalf("unexpected register %+v %v", dst, err)
+	}
+	if !dst.GetControlCtrl_enable() || dst.GetControlCtrl_mode() != 2 || dst.GetControlIrq_mask() != 0xa || dst.GetControlIrq_level() != -2 {
+		t.Fatalf("unexpected members 0x%x", dst.control)
+	}
+}
+`)
+}
+
+func TestGenerateGoMagic(t *testing.T) {
+	input := `
+    device test
+
+    register Frame(1) {
+        sync = 0xAA55 uint16;
+        value uint8;
+        tag = 0xFFFFFE int24 @le;
+        crc crc16;
+    };`
+
+	device, err := parser.Parse(input)
+	require.NoError(t, err)
+
+	code, err := GenerateGo(device, "gentest")
+	require.NoError(t, err)
+	require.Contains(t, code, "// sync: magic 0xAA55")
+	require.Contains(t, code, "if err := putNumber(buf[offset:], uint16(0xAA55)); err != nil {")
+	require.NotContains(t, code, "sync uint16")
+
+	runGeneratedGoTest(t, code, `package gentest
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+)
+
+func TestMagic(t *testing.T) {
+	r := Frame{value: 7}
+	buf := make([]byte, r.BufSize4Write())
+	n, err := r.SerializeWrite(buf)
+	if err != nil || n != 8 || !bytes.Equal(buf[:6], []byte{0xaa, 0x55, 7, 0xfe, 0xff, 0xff}) {
+		t.Fatalf("unexpected data % x %d %v", buf, n, err)
+	}
+	var r2 Frame
+	if n, err := r2.DeserializeWrite(buf); err != nil || n != 8 || r2.value != 7 {
+		t.Fatalf("deserialization failed %d %v", n, err)
+	}
+
+	buf[1] = 0x56
+	if _, err := r2.DeserializeWrite(buf); err == nil || err.Error() != "Frame.sync: magic mismatch: received 0xaa56, expected 0xaa55" {
+		t.Fatalf("the wrong magic is not detected: %v", err)
+	}
+	buf[1] = 0x55
+	buf[5] = 0x7f
+	if _, err := r2.DeserializeWrite(buf); err == nil || !errors.Is(err, ErrMagicMismatch) {
+		t.Fatalf("the wrong magic is not detected: %v", err)
+	}
+}
+`)
+}
+
+func TestGenerateGoReservedBitMember(t *testing.T) {
+	input := `
+    device test
+
+    register Control(1) {
+        flags uint8{ready: 0, reserved: 1-7};
+    };`
+
+	device, err := parser.Parse(input)
+	require.NoError(t, err)
+
+	code, err := GenerateGoWithOptions(device, "gentest", GoOptions{BitfieldStrings: true, JSON: true})
+	require.NoError(t, err)
+	require.Contains(t, code, "const Control_flags_ready_bm uint8 = 0x1")
+	require.NotContains(t, code, "Control_flags__bm")
+	require.NotContains(t, code, "GetFlags_()")
+	_, err = format.Source([]byte(code))
+	require.NoError(t, err)
+}
+
+func TestGenerateGoConstantLiterals(t *testing.T) {
+	input := `
+    device test
+
+    const Dec = uint8(10);
+    const Hex = uint8(0xFF);
+    const Bin = uint8(0b1010);
+    enum Level int8 { LOW = -0b11, HIGH = 0B11 };
+
+    register Control(1) {
+        const Mask = uint16(0b1111000011110000);
+        level Level;
+    };`
+
+	device, err := parser.Parse(input)
+	require.NoError(t, err)
+
+	code, err := GenerateGoWithOptions(device, "gentest", GoOptions{})
+	require.NoError(t, err)
+
+	runGeneratedGoTest(t, code, `package gentest
+
+import "testing"
+
+func TestConstantLiterals(t *testing.T) {
+	if Dec != 10 || Hex != 255 || Bin != 10 || Control_Mask != 0xf0f0 {
+		t.Fatalf("unexpected constants %d %d %d 0x%x", Dec, Hex, Bin, Control_Mask)
+	}
+	if Level_LOW != -3 || Level_HIGH != 3 {
+		t.Fatalf("unexpected enum values %d %d", Level_LOW, Level_HIGH)
+	}
+}
+`)
+}
+
+func TestGenerateGoTest(t *testing.T) {
+	input := `
+    device test
+
+    enum Mode uint8 { OFF = 0, ON = 1 };
+
+    register Channel(1) {
+        id uint8;
+        value:r int16;
+        gain:w fixed(int16, 4);
+    };
+
+    register Status(2): r {
+        flags uint8{ready: 0, n: 1-3, reserved: 4-7};
+        items [flags_n]uint16 @le;
+        name string(uint8, 2);
+        extra uint16 if flags_ready;
+        crc crc16;
+    };
+
+    register Main(3) {
+        mode Mode;
+        flow float32;
+        bits uint16{a: 0, one: 1, b: signed 4-7};
+        single [bits_one]uint8;
+        fixed [3]int8;
+        reserved [2]uint8;
+        count:w uint8;
+        samples:w [count]float64;
+        channels [2]Channel;
+        size uint8;
+        more [size]Channel;
+        label string;
+        status Status;
+        duty int8 [-20..-10];
+        grid [2][3]uint16;
+        rows:w [count][2]int8;
+        tail:w int8 if count;
+    };`
+
+	device, err := parser.Parse(input)
+	require.NoError(t, err)
+
+	code, err := GenerateGo(device, "gentest")
+	require.NoError(t, err)
+	testCode, err := GenerateGoTest(device, "gentest")
+	require.NoError(t, err)
+	require.Contains(t, testCode, "func TestMainRoundTrip(t *testing.T) {")
+	require.Contains(t, testCode, "\tr.SetFlagsN(2)\n")
+	require.Contains(t, testCode, "\tr.SetBitsOne(true)\n")
+	require.Contains(t, testCode, "\tr.extra = ")
+	require.NotContains(t, testCode, "\tr.tail = ")
+
+	runGeneratedGoTest(t, code, testCode)
+}
+
+func TestGenerateGoBench(t *testing.T) {
+	input := `
+    device test
+
+    register Channel(1) {
+        id uint8;
+        value:r int16;
+    };
+
+    register Main(2) {
+        flags uint8{ready: 0, n: 1-3};
+        items [flags_n]uint16 @le;
+        count:w uint8;
+        samples:w [count]float64;
+        channels [2]Channel;
+        label string;
+        crc crc16;
+    };`
+
+	device, err := parser.Parse(input)
+	require.NoError(t, err)
+
+	opts := GoOptions{ReuseSlices: true}
+	code, err := GenerateGoWithOptions(device, "gentest", opts)
+	require.NoError(t, err)
+	benchCode, err := GenerateGoBench(device, "gentest", opts)
+	require.NoError(t, err)
+	require.Contains(t, benchCode, "func BenchmarkMainSerializeWrite(b *testing.B) {")
+	require.Contains(t, benchCode, "func BenchmarkMainDeserializeRead(b *testing.B) {")
+	require.Contains(t, benchCode, "\tr.channels[i] = *benchSampleChannel(read)\n")
+	require.NotContains(t, benchCode, "func sample")
+
+	runGeneratedGoTest(t, code, benchCode, "-run", "^$", "-bench", ".", "-benchtime", "1x")
+}
+
+func TestGenerateGoFieldRange(t *testing.T) {
+	input := `
+    device test
+
+    register Control(1) {
+        duty uint8 [0..100];
+        offset int16 [-50..50];
+        temp float32 [-40..85];
+        full int8 [-128..127];
+    };`
+
+	device, err := parser.Parse(input)
+	require.NoError(t, err)
+
+	code, err := GenerateGo(device, "gentest")
+	require.NoError(t, err)
+	require.Contains(t, code, "if r.duty > 100 {\n\t\treturn &FieldError{Register: \"Control\", Field: \"duty\", Err: fmt.Errorf(\"%w: %v is not in 0..100\", ErrOutOfRange, r.duty)}\n\t}")
+	require.Contains(t, code, "if r.offset < -50 || r.offset > 50 {")
+	require.NotContains(t, code, "r.full <")
+
+	runGeneratedGoTest(t, code, `package gentest
+
+import "testing"
+
+func TestFieldRange(t *testing.T) {
+	buf := make([]byte, 16)
+	for _, r := range []Control{
+		{duty: 50},
+		{duty: 0, offset: -50, temp: -40},
+		{duty: 100, offset: 50, temp: 85},
+	} {
+		if err := r.Check(); err != nil {
+			t.Fatalf("unexpected error for %+v: %v", r, err)
+		}
+	}
+	for _, r := range []Control{
+		{duty: 101},
+		{offset: -51},
+		{offset: 51},
+		{temp: -40.5},
+		{temp: 85.5},
+	} {
+		if err := r.Check(); err == nil {
+			t.Fatalf("the out of range value is not detected for %+v", r)
+		}
+		if _, err := r.SerializeWrite(buf); err == nil {
+			t.Fatalf("the out of range value is serialized for %+v", r)
+		}
+	}
+	r := Control{duty: 101}
+	if err := r.Check(); err.Error() != "Control.duty: value out of range: 101 is not in 0..100" {
+		t.Fatalf("unexpected error %v", err)
+	}
+}
+`)
+}
+
+func TestGenerateGoErrors(t *testing.T) {
+	input := `
+    device test
+
+    register Status(1) {
+        count uint8;
+        samples [count]uint16;
+        crc crc16(modbus);
+    };`
+
+	device, err := parser.Parse(input)
+	require.NoError(t, err)
+
+	code, err := GenerateGoWithOptions(device, "gentest", GoOptions{Decoder: true, Framing: true})
+	require.NoError(t, err)
+	require.Contains(t, code, "ErrArrayLengthMismatch = errors.New(\"array length mismatch\")")
+
+	runGeneratedGoTest(t, code, `package gentest
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+)
+
+func TestErrors(t *testing.T) {
+	r := Status{count: 2, samples: []uint16{1}}
+	err := r.Check()
+	var fieldErr *FieldError
+	if !errors.Is(err, ErrArrayLengthMismatch) || !errors.As(err, &fieldErr) {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if fieldErr.Register != "Status" || fieldErr.Field != "samples" {
+		t.Fatalf("unexpected field error %+v", fieldErr)
+	}
+	if err.Error() != "Status.samples: array length mismatch: length 1, field count value 2" {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if _, err := r.SerializeWrite(make([]byte, 16)); !errors.Is(err, ErrArrayLengthMismatch) {
+		t.Fatalf("unexpected error %v", err)
+	}
+
+	r.samples = append(r.samples, 2)
+	if _, err := r.SerializeWrite(make([]byte, 4)); !errors.Is(err, ErrBufferTooSmall) || errors.Is(err, ErrArrayLengthMismatch) {
+		t.Fatalf("unexpected error %v", err)
+	}
+	buf := make([]byte, r.BufSize4Write())
+	if _, err := r.SerializeWrite(buf); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := r.DeserializeWrite(buf[:3]); !errors.Is(err, ErrBufferTooSmall) {
+		t.Fatalf("unexpected error %v", err)
+	}
+	buf[1]++
+	if _, err := r.DeserializeWrite(buf); !errors.Is(err, ErrChecksumMismatch) || !errors.As(err, &fieldErr) || fieldErr.Field != "crc" {
+		t.Fatalf("unexpected error %v", err)
+	}
+	buf[1]--
+	if err := r.UnmarshalBinary(append(buf, 0)); !errors.Is(err, ErrTrailingBytes) {
+		t.Fatalf("unexpected error %v", err)
+	}
+
+	if _, _, err := DecodeRegister(9, buf); !errors.Is(err, ErrUnknownRegister) {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if _, err := FrameRead(bytes.NewReader([]byte{9, 0, 0})); !errors.Is(err, ErrUnknownRegister) {
+		t.Fatalf("unexpected error %v", err)
+	}
+}
+`)
+}
+
+func TestGenerateGoArrayTooLong(t *testing.T) {
+	input := `
+    device test
+
+    register Item(2) {
+        v uint8;
+    };
+
+    register Samples(1) {
+        count uint32;
+        samples [count]uint16;
+        n uint16;
+        items [n]Item;
+    };`
+
+	device, err := parser.Parse(input)
+	require.NoError(t, err)
+
+	for _, reuse := range []bool{false, true} {
+		code, err := GenerateGoWithOptions(device, "gentest", GoOptions{ReuseSlices: reuse})
+		require.NoError(t, err)
+		require.Contains(t, code, "ErrArrayTooLong = errors.New(\"array too long\")")
+		require.Contains(t, code, "var MaxArrayLength = 65535")
+
+		runGeneratedGoTest(t, code, `package gentest
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestArrayTooLong(t *testing.T) {
+	var r Samples
+	// the size field is far beyond the limit, it is rejected before the allocation
+	_, err := r.DeserializeWrite([]byte{0xFF, 0xFF, 0xFF, 0xFF, 0, 0})
+	var fieldErr *FieldError
+	if !errors.Is(err, ErrArrayTooLong) || !errors.As(err, &fieldErr) || fieldErr.Field != "samples" {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if r.samples != nil {
+		t.Fatalf("unexpected samples %v", r.samples)
+	}
+
+	// the size field is within the limit, but the buffer cannot contain so many elements
+	if _, err := r.DeserializeWrite([]byte{0, 0, 0xFF, 0xFF, 0, 0}); !errors.Is(err, ErrBufferTooSmall) {
+		t.Fatalf("unexpected error %v", err)
+	}
+
+	src := Samples{count: 3, samples: []uint16{1, 2, 3}, n: 3, items: []Item{{v: 1}, {v: 2}, {v: 3}}}
+	buf := make([]byte, src.BufSize4Write())
+	if _, err := src.SerializeWrite(buf); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := r.DeserializeWrite(buf); err != nil || !r.Equal(&src) {
+		t.Fatalf("unexpected result %v %+v", err, r)
+	}
+
+	defer func(max int) { MaxArrayLength = max }(MaxArrayLength)
+	MaxArrayLength = 2
+	if _, err = r.DeserializeWrite(buf); !errors.Is(err, ErrArrayTooLong) {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if err.Error() != "Samples.samples: array too long: 3 elements, the maximum is 2" {
+		t.Fatalf("unexpected error %v", err)
+	}
+	src.count, src.samples = 2, src.samples[:2]
+	buf = make([]byte, src.BufSize4Write())
+	if _, err := src.SerializeWrite(buf); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := r.DeserializeWrite(buf); !errors.As(err, &fieldErr) || fieldErr.Field != "items" {
+		t.Fatalf("unexpected error %v", err)
+	}
+}
+`)
+	}
+}
+
+func TestGenerateGoRegisterVersion(t *testing.T) {
+	input := `
+    device test
+
+    register Control(1) version 2 {
+        mode uint8;
+        crc crc16(modbus);
+    };
+
+    register Status(2): r version 0x10 {
+        value uint16;
+    };
+
+    register Plain(3) {
+        value uint8;
+    };`
+
+	device, err := parser.Parse(input)
+	require.NoError(t, err)
+
+	code, err := GenerateGoWithOptions(device, "gentest", GoOptions{Decoder: true})
+	require.NoError(t, err)
+	require.Contains(t, code, "RegControlVersion uint8 = 2\n")
+	require.Contains(t, code, "RegStatusVersion  uint8 = 16\n")
+	require.NotContains(t, code, "RegPlainVersion")
+
+	runGeneratedGoTest(t, code, `package gentest
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+)
+
+func TestRegisterVersion(t *testing.T) {
+	c := Control{mode: 7}
+	buf, err := c.MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(buf) != 4 || buf[0] != RegControlVersion || buf[1] != 7 || c.BufSize4Read() != 4 || c.Size() != 4 {
+		t.Fatalf("unexpected bytes %v", buf)
+	}
+	var dst Control
+	if err := dst.UnmarshalBinary(buf); err != nil || dst != c {
+		t.Fatalf("unexpected result %v %+v", err, dst)
+	}
+	if _, err := dst.DeserializeWrite(nil); !errors.Is(err, ErrBufferTooSmall) {
+		t.Fatalf("unexpected error %v", err)
+	}
+
+	// the checksum covers the version, so the mismatch is reported before the checksum one
+	buf[0] = 3
+	_, err = dst.DeserializeWrite(buf)
+	if !errors.Is(err, ErrVersionMismatch) || err.Error() != "Control: version mismatch: received 3, expected 2" {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if _, _, err := DecodeRegister(RegControlID, buf); !errors.Is(err, ErrVersionMismatch) {
+		t.Fatalf("unexpected error %v", err)
+	}
+
+	// the read-only register sends its version with the read fields only
+	s := Status{value: 0x0102}
+	if s.BufSize4Write() != 0 || s.BufSize4Read() != 3 {
+		t.Fatalf("unexpected sizes %d %d", s.BufSize4Read(), s.BufSize4Write())
+	}
+	b, err := s.AppendRead(nil)
+	if err != nil || !bytes.Equal(b, []byte{0x10, 1, 2}) {
+		t.Fatalf("unexpected bytes %v %v", b, err)
+	}
+	if _, err := s.DeserializeRead([]byte{0x11, 1, 2}); !errors.Is(err, ErrVersionMismatch) {
+		t.Fatalf("unexpected error %v", err)
+	}
+
+	p := Plain{value: 1}
+	if b, _ := p.AppendWrite(nil); !bytes.Equal(b, []byte{1}) {
+		t.Fatalf("unexpected bytes %v", b)
+	}
+}
+`)
+}
+
+func TestGenerateGoMultiDimArray(t *testing.T) {
+	input := `
+    device test
+
+    register Image(1) {
+        grid [4][2]uint16;
+        count uint8;
+        rows [count][2][3]int8 @le;
+    };`
+
+	device, err := parser.Parse(input)
+	require.NoError(t, err)
+
+	code, err := GenerateGo(device, "gentest")
+	require.NoError(t, err)
+	require.Contains(t, code, "grid  [4][2]uint16")
+	require.Contains(t, code, "rows  [][2][3]int8")
+
+	runGeneratedGoTest(t, code, `package gentest
+
+import "testing"
+
+func TestMultiDimArray(t *testing.T) {
+	src := NewImage()
+	for i := range src.grid {
+		src.grid[i] = [2]uint16{uint16(i), uint16(0x100 + i)}
+	}
+	src.SetRows([][2][3]int8{{{1, 2, 3}, {4, 5, 6}}, {{-1, -2, -3}, {-4, -5, -6}}})
+	if n := src.BufSize4Write(); n != 4*2*2+1+2*2*3 {
+		t.Fatalf("unexpected buffer size %d", n)
+	}
+	buf := make([]byte, src.BufSize4Write())
+	n, err := src.SerializeWrite(buf)
+	if err != nil || n != len(buf) {
+		t.Fatalf("serialize: %d, %v", n, err)
+	}
+	// the rows are sent one after another
+	if buf[0] != 0 || buf[1] != 0 || buf[2] != 1 || buf[3] != 0 || buf[6] != 1 || buf[7] != 1 {
+		t.Fatalf("unexpected encoding % x", buf[:8])
+	}
+	if buf[16] != 2 || buf[17] != 1 || buf[22] != 6 || buf[23] != 0xFF {
+		t.Fatalf("unexpected encoding % x", buf[16:])
+	}
+	dst := NewImage()
+	if m, err := dst.DeserializeWrite(buf); err != nil || m != n {
+		t.Fatalf("deserialize: %d, %v", m, err)
+	}
+	if !src.Equal(dst) {
+		t.Fatalf("the decoded register %+v differs from %+v", dst, src)
+	}
+	if _, err := dst.DeserializeWrite(buf[:len(buf)-1]); err == nil {
+		t.Fatalf("the short buffer is not detected")
+	}
+}
+`)
+}
+
+func TestGenerateGoHexArraySize(t *testing.T) {
+	input := `
+    device test
+
+    register Table(1) {
+        a [0x4]uint16;
+        g [0x2][2]uint8;
+    };`
+
+	device, err := parser.Parse(input)
+	require.NoError(t, err)
+
+	code, err := GenerateGo(device, "gentest")
+	require.NoError(t, err)
+
+	runGeneratedGoTest(t, code, `package gentest
+
+import "testing"
+
+func TestHexArraySize(t *testing.T) {
+	src := NewTable()
+	src.a = [0x4]uint16{1, 2, 3, 0x0405}
+	src.g = [0x2][2]uint8{{6, 7}, {8, 9}}
+	if n := src.BufSize4Write(); n != 4*2+2*2 {
+		t.Fatalf("unexpected buffer size %d", n)
+	}
+	buf := make([]byte, src.BufSize4Write())
+	n, err := src.SerializeWrite(buf)
+	if err != nil || n != len(buf) || buf[6] != 4 || buf[11] != 9 {
+		t.Fatalf("serialize: %d, %v, % x", n, err, buf)
+	}
+	dst := NewTable()
+	if m, err := dst.DeserializeWrite(buf); err != nil || m != n || !src.Equal(dst) {
+		t.Fatalf("deserialize: %d, %v, %+v", m, err, dst)
+	}
+}
+`)
+}
+
+func TestGenerateGoSliceHelpers(t *testing.T) {
+	device, err := parser.Parse(`
+    device test
+
+    register Control(1) {
+        values [3]uint8;
+    };`)
+	require.NoError(t, err)
+
+	code, err := GenerateGo(device, "gentest")
+	require.NoError(t, err)
+
+	// every element size advances the buffer the same way, so the values are at the same
+	// positions of their elements
+	runGeneratedGoTest(t, code, `package gentest
+
+import (
+	"encoding/binary"
+	"slices"
+	"testing"
+)
+
+func TestSliceHelpers(t *testing.T) {
+	checkSlice(t, []uint8{1, 2, 3}, []byte{0xAA, 1, 2, 3, 0xAA})
+	checkSlice(t, []uint16{1, 2, 3}, []byte{0xAA, 0, 1, 0, 2, 0, 3, 0xAA})
+	checkSlice(t, []uint32{1, 2, 3}, []byte{0xAA, 0, 0, 0, 1, 0, 0, 0, 2, 0, 0, 0, 3, 0xAA})
+
+	buf := make([]byte, 5)
+	if err := putSliceOrder(buf[1:], []int8{-1, 2}, binary.LittleEndian); err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Equal(buf, []byte{0, 0xFF, 2, 0, 0}) {
+		t.Fatalf("unexpected encoding % x", buf)
+	}
+	res := make([]int8, 2)
+	if err := getSliceOrder(buf[1:], res, binary.LittleEndian); err != nil || !slices.Equal(res, []int8{-1, 2}) {
+		t.Fatalf("unexpected decoding %v: %v", res, err)
+	}
+}
+
+// checkSlice encodes the values into the middle of the buffer filled with 0xAA and decodes them back
+func checkSlice[T Integer](t *testing.T, values []T, expected []byte) {
+	t.Helper()
+	buf := slices.Repeat([]byte{0xAA}, len(expected))
+	if err := putSlice(buf[1:], values); err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Equal(buf, expected) {
+		t.Fatalf("%T is encoded as % x, expected % x", values, buf, expected)
+	}
+	res := make([]T, len(values))
+	if err := getSlice(buf[1:], res); err != nil || !slices.Equal(res, values) {
+		t.Fatalf("%T is decoded as %v: %v", values, res, err)
+	}
+	if err := putSlice(buf[1:len(buf)-2], values); err == nil {
+		t.Fatalf("%T: the short buffer is not detected", values)
+	}
+	if err := getSlice(buf[1:len(buf)-2], res); err == nil {
+		t.Fatalf("%T: the short buffer is not detected", values)
+	}
+}
+`)
+}
+
+func TestGenerateGoInt24(t *testing.T) {
+	input := `
+    device test
+
+    register Audio(1) {
+        sample int24;
+        color uint24 @le;
+        pair [2]int24;
+        count uint24;
+        samples [count]int24 @le;
+    };`
+
+	device, err := parser.Parse(input)
+	require.NoError(t, err)
+
+	code, err := GenerateGo(device, "gentest")
+	require.NoError(t, err)
+	require.Contains(t, code, "sample  int32")
+	require.Contains(t, code, "color   uint32")
+	require.Contains(t, code, "func getNumber24[")
+
+	runGeneratedGoTest(t, code, `package gentest
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestInt24(t *testing.T) {
+	src := &Audio{sample: -8388608, color: 0xABCDEF, pair: [2]int32{8388607, -1}}
+	src.SetSamples([]int32{-2, 0x123456})
+	if n := src.BufSize4Write(); n != 3*5+3*2 {
+		t.Fatalf("unexpected buffer size %d", n)
+	}
+	buf := make([]byte, src.BufSize4Write())
+	n, err := src.SerializeWrite(buf)
+	if err != nil || n != len(buf) {
+		t.Fatalf("serialize: %d, %v", n, err)
+	}
+	expected := []byte{
+		0x80, 0x00, 0x00, // sample
+		0xEF, 0xCD, 0xAB, // color
+		0x7F, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, // pair
+		0x00, 0x00, 0x02, // count
+		0xFE, 0xFF, 0xFF, 0x56, 0x34, 0x12, // samples
+	}
+	if !bytes.Equal(buf, expected) {
+		t.Fatalf("unexpected encoding % x", buf)
+	}
+	dst := &Audio{}
+	if m, err := dst.DeserializeWrite(buf); err != nil || m != n {
+		t.Fatalf("deserialize: %d, %v", m, err)
+	}
+	if !src.Equal(dst) {
+		t.Fatalf("the decoded register %+v differs from %+v", dst, src)
+	}
+	if dst.sample != -8388608 || dst.pair[0] != 8388607 || dst.pair[1] != -1 || dst.color != 0xABCDEF {
+		t.Fatalf("unexpected sign extension %+v", dst)
+	}
+	if _, err := dst.DeserializeWrite(buf[:len(buf)-1]); err == nil {
+		t.Fatalf("the short buffer is not detected")
+	}
+}
+`)
+}
+
+func TestGenerateGoDeviceEndianness(t *testing.T) {
+	device, err := parser.Parse(`
+    device test @le
+
+    register Control(1) {
+        a uint16;
+        b uint16 @be;
+    };`)
+	require.NoError(t, err)
+
+	code, err := GenerateGo(device, "gentest")
+	require.NoError(t, err)
+	require.Contains(t, code, "putNumberOrder(buf[offset:], r.a, binary.LittleEndian)")
+	require.Contains(t, code, "putNumber(buf[offset:], r.b)")
+}
+
+func TestGenerateGoUnexported(t *testing.T) {
+	input := `
+    device test
+
+    const Version = uint8(2);
+
+    enum Mode uint8 {
+        OFF = 0,
+        ON = 1,
+    };
+
+    register Config(1) {
+        const Limit = uint8(10);
+        mode Mode;
+        name string(uint8, 8);
+    };
+
+    register Control(2) {
+        flags uint8{ready: 0, offset: signed 1-3, count: 4-6};
+        items [flags_count]int16;
+        temp fixed(int16, 4);
+        config Config;
+        n uint8;
+        configs [n]Config;
+    };`
+
+	device, err := parser.Parse(input)
+	require.NoError(t, err)
+
+	opts := GoOptions{Unexported: true, Decoder: true, BitfieldStrings: true, JSON: true, Framing: true, Pool: true}
+	code, err := GenerateGoWithOptions(device, "gentest", opts)
+	require.NoError(t, err)
+
+	// only the runtime shared by all the devices is exported
+	runtime := []string{"Integer", "Float", "FieldError", "ErrBufferTooSmall", "ErrArrayLengthMismatch", "ErrArrayTooLong", "ErrStringTooLong",
+		"ErrOutOfRange", "ErrChecksumMismatch", "ErrMagicMismatch", "ErrVersionMismatch", "ErrUnknownRegister", "ErrTrailingBytes", "MaxArrayLength"}
+	file, err := goparser.ParseFile(token.NewFileSet(), "registers.go", code, 0)
+	require.NoError(t, err)
+	var exported []string
+	for _, decl := range file.Decls {
+		switch d := decl.(type) {
+		case *ast.FuncDecl:
+			// the methods are only reachable through the unexported types, but the accessors are lowercased as well
+			if d.Name.IsExported() && (d.Recv == nil || strings.HasPrefix(d.Name.Name, "Get") ||
+				strings.HasPrefix(d.Name.Name, "Set") || strings.HasSuffix(d.Name.Name, "String")) && d.Name.Name != "String" {
+				exported = append(exported, d.Name.Name)
+			}
+		case *ast.GenDecl:
+			for _, spec := range d.Specs {
+				switch sp := spec.(type) {
+				case *ast.TypeSpec:
+					if sp.Name.IsExported() && !slices.Contains(runtime, sp.Name.Name) {
+						exported = append(exported, sp.Name.Name)
+					}
+				case *ast.ValueSpec:
+					for _, name := range sp.Names {
+						if name.IsExported() && !slices.Contains(runtime, name.Name) {
+							exported = append(exported, name.Name)
+						}
+					}
+				}
+			}
+		}
+	}
+	require.Empty(t, exported)
+	require.Contains(t, code, "func (r *control) setFlagsOffset(v int8) {")
+	require.Contains(t, code, "\tconfig_Limit uint8 = 10\n")
+	require.Contains(t, code, "const control_flags_ready_bm uint8 = 0x1")
+	require.Contains(t, code, `Err: fmt.Errorf("%w: length %d, field flags_count value %d", ErrArrayLengthMismatch`)
+	require.Contains(t, code, `&FieldError{Register: "Control", Field: "items"`)
+
+	runGeneratedGoTest(t, code, `package gentest
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestUnexported(t *testing.T) {
+	c := acquireControl()
+	c.setFlagsReady(true)
+	c.setFlagsOffset(-2)
+	c.setItems([]int16{1, -1})
+	c.setTemp(1.5)
+	c.config = config{mode: mode_ON, name: "abc"}
+	c.setConfigs([]config{{name: "x"}})
+	if c.flagsString() != "ready|offset=-2|count=2" || c.getTempRaw() != 24 || version != 2 || config_Limit != 10 {
+		t.Fatalf("unexpected register %+v", c)
+	}
+
+	var buf bytes.Buffer
+	if err := frameWrite(c, &buf); err != nil {
+		t.Fatal(err)
+	}
+	r, err := frameRead(&buf)
+	if err != nil || !r.(*control).Equal(c) {
+		t.Fatalf("unexpected frame %+v %v", r, err)
+	}
+	data, err := c.MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+	r, _, err = decodeRegister(regControlID, data)
+	if err != nil || !r.(*control).Equal(c) {
+		t.Fatalf("unexpected register %+v %v", r, err)
+	}
+	js, err := c.MarshalJSON()
+	if err != nil {
+		t.Fatal(err)
+	}
+	dst := newControl()
+	if err := dst.UnmarshalJSON(js); err != nil || !dst.Equal(c) {
+		t.Fatalf("unexpected JSON %s %v", js, err)
+	}
+	releaseControl(c)
+}
+`)
+
+	testCode, err := GenerateGoTestWithOptions(device, "gentest", opts)
+	require.NoError(t, err)
+	require.Contains(t, testCode, "func sampleControl(read bool) *control {")
+	runGeneratedGoTest(t, code, testCode)
+}
+
+func TestGenerateGoDefaults(t *testing.T) {
+	input := `
+    device test
+
+    enum Mode uint8 { OFF = 0, ON = 1 };
+
+    register Config(1) {
+        level int16 [-100..100] = -5;
+        size uint8;
+    };
+
+    register Control(2) {
+        mode Mode = 1;
+        flags uint8{ready: 0, busy: 1} = 0b10;
+        timeout uint32 = 0x1000;
+        plain uint8;
+        config Config;
+        configs [2]Config;
+    };
+
+    register Plain(3) {
+        value uint8;
+    };`
+
+	device, err := parser.Parse(input)
+	require.NoError(t, err)
+
+	code, err := GenerateGoWithOptions(device, "gentest", GoOptions{Pool: true})
+	require.NoError(t, err)
+	require.Contains(t, code, "// NewControl returns a new Control register with the default field values\n")
+	require.Contains(t, code, "// NewPlain returns a new zeroed Plain register\n")
+	require.Contains(t, code, "\tr.timeout = 0x1000\n")
+	require.Contains(t, code, "var controlPool = sync.Pool{New: func() interface{} { return NewControl() }}")
+
+	runGeneratedGoTest(t, code, `package gentest
+
+import "testing"
+
+func TestDefaults(t *testing.T) {
+	expected := &Control{
+		mode:    Mode_ON,
+		flags:   2,
+		timeout: 0x1000,
+		config:  Config{level: -5},
+		configs: [2]Config{{level: -5}, {level: -5}},
+	}
+	r := NewControl()
+	if !r.Equal(expected) {
+		t.Fatalf("the defaults are not applied %+v", r)
+	}
+
+	r.mode = Mode_OFF
+	r.timeout = 1
+	r.configs[1].level = 7
+	r.Reset()
+	if !r.Equal(expected) {
+		t.Fatalf("the reset register has no defaults %+v", r)
+	}
+
+	r.plain = 3
+	ReleaseControl(r)
+	if r = AcquireControl(); !r.Equal(expected) {
+		t.Fatalf("the acquired register has no defaults %+v", r)
+	}
+	if !NewPlain().Equal(&Plain{}) {
+		t.Fatal("the register without defaults is not zeroed")
+	}
+}
+`)
+}
+
+func TestGenerateGoSize(t *testing.T) {
+	input := `
+    device test
+
+    register Inner(1) {
+        status:r uint16;
+        mode:w uint8;
+        value uint32;
+    };
+
+    register Control(2) {
+        id uint8;
+        status:r uint32 @offset 4;
+        command:w uint16 @offset 8;
+        count uint8;
+        items [count]uint16;
+        name:r string;
+        inner Inner;
+        inners [2]Inner;
+        written:w Inner;
+    };`
+
+	device, err := parser.Parse(input)
+	require.NoError(t, err)
+
+	code, err := GenerateGo(device, "gentest")
+	require.NoError(t, err)
+	require.Contains(t, code, "\tsize += r.inner.Size()\n")
+	require.Contains(t, code, "\tsize += sumBufSize(r.inners[:], (*Inner).Size)\n")
+	require.Contains(t, code, "\tsize += r.written.BufSize4Write()\n")
+
+	runGeneratedGoTest(t, code, `package gentest
+
+import "testing"
+
+func TestSize(t *testing.T) {
+	// the read fields take 4+2+1 bytes, the write ones 1+4, the read-write ones 4 bytes
+	inner := &Inner{}
+	if inner.BufSize4Read() != 6 || inner.BufSize4Write() != 5 || inner.Size() != 7 {
+		t.Fatalf("unexpected Inner sizes %d %d %d", inner.BufSize4Read(), inner.BufSize4Write(), inner.Size())
+	}
+
+	r := &Control{count: 3, items: []uint16{1, 2, 3}, name: "abc"}
+	// the id, count, items and the nested registers are sent in both directions, the gap before status
+	// is read only, the gap before command covers the status, so it is written only
+	read, write := r.BufSize4Read(), r.BufSize4Write()
+	if read != 1+3+4+1+6+4+6+2*6 || write != 1+7+2+1+6+5+2*5+5 {
+		t.Fatalf("unexpected buffer sizes %d %d", read, write)
+	}
+	both := 1 + 1 + 6 + 4 + 2*4
+	if r.Size() != read+write-both {
+		t.Fatalf("unexpected size %d, %d is expected", r.Size(), read+write-both)
+	}
+}
+`)
+}
+
+func TestGenerateGoConditions(t *testing.T) {
+	input := `
+    device test
+
+    register Status(1) {
+        flags uint8{has_extra: 0, has_name: 1};
+        extra uint16 [1..1000] if flags_has_extra;
+        name string if flags_has_name;
+        more:r uint8;
+        count:r uint8 if more;
+        items:r [count]uint16 if more;
+        last uint8 = 7;
+        tail uint8 = 3 if flags_has_extra;
+    };`
+
+	device, err := parser.Parse(input)
+	require.NoError(t, err)
+
+	code, err := GenerateGo(device, "gentest")
+	require.NoError(t, err)
+	require.Contains(t, code, "func (r *Status) BufSize4Write() int {\n\tsize := 2\n")
+	require.Contains(t, code, "\tif r.flags&Status_flags_has_extra_bm != 0 {\n\t\tsize += 2\n\t}\n")
+	require.Contains(t, code, "\tif r.flags&Status_flags_has_name_bm != 0 {\n\t\tsize += 1 + len(r.name)\n\t}\n")
+	require.Contains(t, code, "\t} else {\n\t\tr.extra = 0\n\t}\n")
+
+	runGeneratedGoTest(t, code, `package gentest
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+)
+
+func TestConditions(t *testing.T) {
+	// the absent fields are not sent
+	src := NewStatus()
+	src.SetExtra(5)
+	src.SetName("ab")
+	src.SetCount(9)
+	if src.BufSize4Read() != 3 || src.BufSize4Write() != 2 || src.Size() != 3 {
+		t.Fatalf("unexpected sizes %d %d %d", src.BufSize4Read(), src.BufSize4Write(), src.Size())
+	}
+	buf, err := src.MarshalBinary()
+	if err != nil || !bytes.Equal(buf, []byte{0, 7}) {
+		t.Fatalf("unexpected bytes % x, err=%v", buf, err)
+	}
+
+	// the absent fields are decoded as the reset ones
+	dst := &Status{extra: 1, name: "x", tail: 1}
+	if err := dst.UnmarshalBinary(buf); err != nil {
+		t.Fatal(err)
+	}
+	if dst.extra != 0 || dst.name != "" || dst.last != 7 || dst.tail != 3 {
+		t.Fatalf("unexpected register %+v", dst)
+	}
+
+	// the present fields are sent after their flags
+	src.SetFlagsHas_extra(true)
+	src.SetFlagsHas_name(true)
+	src.SetMore(1)
+	src.SetItems([]uint16{0x0102})
+	buf = make([]byte, src.BufSize4Read())
+	if n, err := src.SerializeRead(buf); err != nil || n != 12 {
+		t.Fatalf("n=%d err=%v", n, err)
+	}
+	if !bytes.Equal(buf, []byte{3, 0, 5, 2, 'a', 'b', 1, 1, 1, 2, 7, 3}) {
+		t.Fatalf("unexpected read bytes % x", buf)
+	}
+	dst = &Status{}
+	if n, err := dst.DeserializeRead(buf); err != nil || n != 12 || !dst.Equal(src) {
+		t.Fatalf("n=%d err=%v, unexpected register %+v", n, err, dst)
+	}
+	if src.Size() != 12 {
+		t.Fatalf("unexpected size %d", src.Size())
+	}
+
+	// the absent field is not checked
+	src.SetExtra(2000)
+	if _, err := src.MarshalBinary(); !errors.Is(err, ErrOutOfRange) {
+		t.Fatalf("the out of range present field must be reported, got %v", err)
+	}
+	src.SetFlagsHas_extra(false)
+	if _, err := src.MarshalBinary(); err != nil {
+		t.Fatal(err)
+	}
+}
+`)
+}
+
+func TestGenerateGoMessages(t *testing.T) {
+	input := `
+    device test
+
+    register Control(1) {
+        mode uint8 = 2;
+        count uint8;
+        items [count]uint16;
+    };
+
+    register Status(2): r {
+        value int32;
+    };
+
+    // the telemetry frame
+    message Frame {
+        Control;
+        Status; // the status
+    };`
+
+	device, err := parser.Parse(input)
+	require.NoError(t, err)
+
+	code, err := GenerateGo(device, "gentest")
+	require.NoError(t, err)
+	require.Contains(t, code, "// the telemetry frame\ntype Frame struct {\n\tControl Control\n\tStatus  Status // the status\n}\n")
+	require.Contains(t, code, "func (m *Frame) BufSize4Read() int {\n\treturn m.Control.BufSize4Read() + m.Status.BufSize4Read()\n}")
+	require.Contains(t, code, "\tif n, err := m.Status.DeserializeRead(buf[offset:]); err != nil {\n\t\treturn offset, err\n\t} else {\n\t\toffset += n\n\t}\n")
+	require.NotContains(t, code, "Reg_Frame_ID")
+
+	runGeneratedGoTest(t, code, `package gentest
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestMessage(t *testing.T) {
+	src := NewFrame()
+	if src.Control.mode != 2 {
+		t.Fatalf("the registers must have the default values, got %+v", src.Control)
+	}
+	src.Control.SetItems([]uint16{0x0102, 0x0304})
+	src.Status.SetValue(-3)
+
+	// the read fields of the registers follow each other without the IDs
+	buf, err := src.AppendRead([]byte{0xFF})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []byte{0xFF, 2, 2, 1, 2, 3, 4, 0xFF, 0xFF, 0xFF, 0xFD}
+	if string(buf) != string(want) || src.BufSize4Read() != len(want)-1 {
+		t.Fatalf("unexpected bytes % x", buf)
+	}
+	dst := &Frame{}
+	n, err := dst.DeserializeRead(buf[1:])
+	if err != nil || n != len(want)-1 {
+		t.Fatalf("n=%d, err=%v", n, err)
+	}
+	if !dst.Equal(src) {
+		t.Fatalf("decoded message mismatch %+v", dst)
+	}
+
+	// the write fields of the read-only register are not sent
+	wbuf := make([]byte, src.BufSize4Write())
+	if n, err := src.SerializeWrite(wbuf); err != nil || n != 6 {
+		t.Fatalf("n=%d, err=%v", n, err)
+	}
+	dst = NewFrame()
+	if _, err := dst.DeserializeWrite(wbuf); err != nil || !dst.Control.Equal(&src.Control) || dst.Status.value != 0 {
+		t.Fatalf("unexpected message %+v, err=%v", dst, err)
+	}
+
+	// the truncated message reports the missing bytes of the register it stops at
+	if _, err := dst.DeserializeRead(buf[1:9]); !errors.Is(err, ErrBufferTooSmall) {
+		t.Fatalf("unexpected error %v", err)
+	}
+
+	c := src.Clone()
+	c.Control.items[0] = 7
+	if src.Control.items[0] != 0x0102 || c.Equal(src) {
+		t.Fatal("the clone must be deep")
+	}
+	c.Reset()
+	if c.Control.mode != 2 || len(c.Control.items) != 0 || c.Status.value != 0 {
+		t.Fatalf("unexpected reset message %+v", c)
+	}
+}
+`)
+
+	// the register cannot have the name of the message method
+	device, err = parser.Parse(`device test
+register Check(1) {
+    a uint8;
+};
+message Frame {
+    Check;
+};`)
+	require.NoError(t, err)
+	_, err = GenerateGo(device, "gentest")
+	require.ErrorContains(t, err, "register 'Check' of message 'Frame' has the name of the message method")
+}
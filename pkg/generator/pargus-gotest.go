@@ -0,0 +1,344 @@
+package generator
+
+import (
+	"bytes"
+	"fmt"
+	"go/format"
+	"slices"
+	"strconv"
+	"strings"
+	"text/template"
+
+	"github.com/dspasibenko/pargus/pkg/parser"
+	"golang.org/x/text/cases"
+	"golang.org/x/text/language"
+)
+
+const goTestTemplate = `
+// This is auto-generated file. DO NOT EDIT. Use pargus compiler to regenerate it.
+package {{.Package}}
+
+import "testing"
+{{- range .Registers}}
+
+{{- template "sample" .}}
+
+func Test{{.Name}}RoundTrip(t *testing.T) {
+	tests := []struct {
+		name        string
+		read        bool
+		bufSize     func(*{{.Type}}) int
+		serialize   func(*{{.Type}}, []byte) (int, error)
+		deserialize func(*{{.Type}}, []byte) (int, error)
+	}{
+		{"read", true, (*{{.Type}}).BufSize4Read, (*{{.Type}}).SerializeRead, (*{{.Type}}).DeserializeRead},
+		{"write", false, (*{{.Type}}).BufSize4Write, (*{{.Type}}).SerializeWrite, (*{{.Type}}).DeserializeWrite},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			src := sample{{.Name}}(tt.read)
+			buf := make([]byte, tt.bufSize(src))
+			n, err := tt.serialize(src, buf)
+			if err != nil {
+				t.Fatalf("serialize: %v", err)
+			}
+			if n != len(buf) {
+				t.Fatalf("serialized %d bytes, the buffer size is %d", n, len(buf))
+			}
+			dst := {{.New}}()
+			m, err := tt.deserialize(dst, buf)
+			if err != nil {
+				t.Fatalf("deserialize: %v", err)
+			}
+			if m != n {
+				t.Fatalf("deserialized %d bytes of %d", m, n)
+			}
+			if !src.Equal(dst) {
+				t.Fatalf("the decoded register %+v differs from %+v", dst, src)
+			}
+		})
+	}
+}
+{{- end}}
+`
+
+// goSampleTemplate is the function populating the register, which is shared by the tests and
+// the benchmarks
+const goSampleTemplate = `
+{{- define "sample"}}
+
+// {{.Sample}} returns the {{.Name}} register with the read or the write fields populated,
+// the variable arrays lengths are consistent with their size fields
+func {{.Sample}}(read bool) *{{.Type}} {
+	r := {{.New}}()
+{{- if .SameFill}}
+	{{- range .ReadFill}}
+	{{.}}
+	{{- end}}
+{{- else}}
+	if read {
+	{{- range .ReadFill}}
+		{{.}}
+	{{- end}}
+	} else {
+	{{- range .WriteFill}}
+		{{.}}
+	{{- end}}
+	}
+{{- end}}
+	return r
+}
+{{- end}}`
+
+const goBenchTemplate = `
+// This is auto-generated file. DO NOT EDIT. Use pargus compiler to regenerate it.
+package {{.Package}}
+
+import "testing"
+{{- range .Registers}}
+{{- template "sample" .}}
+{{- $reg := .}}
+{{- range $dir := list "Read" "Write"}}
+
+func Benchmark{{$reg.Name}}Serialize{{$dir}}(b *testing.B) {
+	r := {{$reg.Sample}}({{eq $dir "Read"}})
+	buf := make([]byte, r.BufSize4{{$dir}}())
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := r.Serialize{{$dir}}(buf); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func Benchmark{{$reg.Name}}Deserialize{{$dir}}(b *testing.B) {
+	src := {{$reg.Sample}}({{eq $dir "Read"}})
+	buf := make([]byte, src.BufSize4{{$dir}}())
+	if _, err := src.Serialize{{$dir}}(buf); err != nil {
+		b.Fatal(err)
+	}
+	r := {{$reg.New}}()
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := r.Deserialize{{$dir}}(buf); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+{{- end}}
+{{- end}}
+`
+
+var (
+	goTestTpl  = template.Must(template.Must(template.New("gotest").Parse(goTestTemplate)).Parse(goSampleTemplate))
+	goBenchTpl = template.Must(template.Must(template.New("gobench").Funcs(template.FuncMap{
+		"list": func(items ...string) []string { return items },
+	}).Parse(goBenchTemplate)).Parse(goSampleTemplate))
+)
+
+type GoTestDevice struct {
+	Package   string
+	Registers []GoTestRegister
+}
+
+type GoTestRegister struct {
+	Name      string
+	Type      string   // Go type name of the register
+	Sample    string   // Name of the function populating the register
+	New       string   // Name of the function creating the register
+	ReadFill  []string // Code populating the read fields
+	WriteFill []string // Code populating the write fields
+	SameFill  bool     // The read and the write fields are populated the same way
+}
+
+// GenerateGoTest generates the Go test file checking that every register of the device
+// code GenerateGo produces for the same package survives the serialization round trip
+func GenerateGoTest(dev *parser.Device, pkg string) (string, error) {
+	return GenerateGoTestWithOptions(dev, pkg, GoOptions{})
+}
+
+// GenerateGoTestWithOptions generates the Go test file for the code GenerateGoWithOptions
+// produces with the same options, only the Unexported option changes the tests
+func GenerateGoTestWithOptions(dev *parser.Device, pkg string, opts GoOptions) (string, error) {
+	return executeGoTestTemplate(goTestTpl, newGoTestDevice(dev, pkg, "sample", opts))
+}
+
+// GenerateGoBench generates the Go benchmarks of the serialization and the deserialization
+// of every register of the device code GenerateGoWithOptions produces with the same options.
+// Every benchmark reuses one register and one buffer and reports the allocations, so the
+// file is a baseline of the codec performance. The file does not depend on the tests
+// GenerateGoTestWithOptions produces and can be put into the same package.
+func GenerateGoBench(dev *parser.Device, pkg string, opts GoOptions) (string, error) {
+	return executeGoTestTemplate(goBenchTpl, newGoTestDevice(dev, pkg, "benchSample", opts))
+}
+
+// newGoTestDevice returns the template data of the device registers, the functions populating
+// the registers are named by the sample prefix and the register name
+func newGoTestDevice(dev *parser.Device, pkg, sample string, opts GoOptions) GoTestDevice {
+	out := GoTestDevice{Package: pkg}
+	for _, reg := range dev.Registers {
+		gr := GoTestRegister{
+			Name:      reg.Name,
+			Type:      opts.goIdent(reg.Name),
+			Sample:    sample + reg.Name,
+			New:       opts.goIdent("New", reg.Name),
+			ReadFill:  goSampleFill(reg, true, sample, opts),
+			WriteFill: goSampleFill(reg, false, sample, opts),
+		}
+		gr.SameFill = slices.Equal(gr.ReadFill, gr.WriteFill)
+		out.Registers = append(out.Registers, gr)
+	}
+	return out
+}
+
+func executeGoTestTemplate(tpl *template.Template, dev GoTestDevice) (string, error) {
+	var buf bytes.Buffer
+	if err := tpl.Execute(&buf, dev); err != nil {
+		return "", err
+	}
+	src, err := format.Source(buf.Bytes())
+	if err != nil {
+		return "", fmt.Errorf("could not format the generated Go test code: %w", err)
+	}
+	return string(src), nil
+}
+
+// goSampleFill returns the code assigning non-zero values to the register fields sent in the
+// direction. The size fields get the length of their arrays, so they are not assigned
+// unless the array is sent in the same direction. The conditions are set, so the conditional
+// fields are sent, unless they are the size fields. The nested registers are populated by
+// the functions with the sample prefix.
+func goSampleFill(reg *parser.Register, read bool, sample string, opts GoOptions) []string {
+	inDir := func(f *parser.Field) bool {
+		if read {
+			return f.Specifier == "r" || f.Specifier == ""
+		}
+		return f.Specifier == "w" || f.Specifier == ""
+	}
+
+	fields := reg.Body.Fields()
+	sizeFields := make(map[string]bool)
+	sizeMembers := make(map[string]bool) // <field>_<member> names of the size bit members
+	for i, f := range fields {
+		if f.Type.Array == nil || f.Type.Array.Size.Variable == nil {
+			continue
+		}
+		if field, bm := reg.FindFieldByName(*f.Type.Array.Size.Variable, i); bm != nil {
+			sizeMembers[field.Name+"_"+bm.Name] = true
+		} else {
+			sizeFields[field.Name] = true
+		}
+	}
+
+	var res []string
+	for i, f := range fields {
+		if f.Reserved || f.IsMagic() || f.Type.CRC != nil || sizeFields[f.Name] || !inDir(f) {
+			continue
+		}
+		if f.HasCondition() && (sizeFields[*f.Condition] || sizeMembers[*f.Condition]) {
+			// the size may leave the condition clear, the zero field is decoded the same either way
+			continue
+		}
+		value := strconv.Itoa(i%100 + 1)
+		t := f.Type
+		switch {
+		case t.Bitfield != nil:
+			var mask uint64
+			for _, bm := range t.Bitfield.Bits {
+				if !bm.Reserved && !sizeMembers[f.Name+"_"+bm.Name] {
+					mask |= bitMask(bm.StartBit(), bm.EndBit())
+				}
+			}
+			if mask != 0 {
+				res = append(res, fmt.Sprintf("r.%s = 0x%X", f.Name, mask))
+			}
+		case t.Fixed != nil:
+			res = append(res, fmt.Sprintf("r.%s = %s", f.Name, value))
+		case t.String != nil:
+			s := "abc"[:min(3, t.String.MaxLen())]
+			res = append(res, fmt.Sprintf("r.%s = %q", f.Name, s))
+		case t.Simple != nil && t.Simple.IsRegisterRef():
+			res = append(res, fmt.Sprintf("r.%s = *%s%s(read)", f.Name, sample, t.Simple.Name))
+		case t.Simple != nil && t.Simple.IsEnum():
+			members := t.Simple.Enum.Members
+			res = append(res, fmt.Sprintf("r.%s = %s_%s", f.Name, opts.goIdent(t.Simple.Name), members[len(members)-1].Name))
+		case t.Simple != nil && f.HasRange():
+			// the value must be in the range, so Check succeeds
+			value = strconv.FormatInt(max(min(int64(i%100+1), f.Max()), f.Min()), 10)
+			res = append(res, fmt.Sprintf("r.%s = %s", f.Name, value))
+		case t.Simple != nil:
+			if isFloatType(toGoTypes(t.Simple.Name)) {
+				value += ".5"
+			}
+			res = append(res, fmt.Sprintf("r.%s = %s", f.Name, value))
+		case t.Array != nil && t.Array.Size.Constant != nil && t.Array.IsMultiDim():
+			res = append(res, goSampleRows(f)...)
+		case t.Array != nil && t.Array.Size.Constant != nil:
+			elem := fmt.Sprintf("%s(i + 1)", toGoTypes(t.Array.Type.Name))
+			if t.Array.Type.IsRegisterRef() {
+				elem = fmt.Sprintf("*%s%s(read)", sample, t.Array.Type.Name)
+			}
+			res = append(res, fmt.Sprintf("for i := range r.%s {", f.Name),
+				fmt.Sprintf("\tr.%s[i] = %s", f.Name, elem),
+				"}")
+		case t.Array != nil:
+			field, bm := reg.FindFieldByName(*t.Array.Size.Variable, i)
+			if !inDir(field) {
+				// the size is not sent in this direction, so the array is empty on the wire
+				continue
+			}
+			count := 2
+			if bm != nil && bm.StartBit() == bm.EndBit() {
+				count = 1
+			}
+			elems := make([]string, count)
+			for j := range elems {
+				if t.Array.Type.IsRegisterRef() {
+					elems[j] = fmt.Sprintf("*%s%s(read)", sample, t.Array.Type.Name)
+				} else {
+					elems[j] = strconv.Itoa(j + 1)
+				}
+			}
+			elemType := opts.goIdent(t.Array.Type.Name)
+			if !t.Array.Type.IsRegisterRef() {
+				elemType = goArrayItem(t.Array)
+			}
+			if t.Array.IsMultiDim() {
+				res = append(res, fmt.Sprintf("r.%s = make([]%s, %d)", f.Name, elemType, count))
+				res = append(res, goSampleRows(f)...)
+			} else {
+				res = append(res, fmt.Sprintf("r.%s = []%s{%s}", f.Name, elemType, strings.Join(elems, ", ")))
+			}
+			switch {
+			case bm == nil:
+				res = append(res, fmt.Sprintf("r.%s = %d", field.Name, count))
+			case count == 1:
+				res = append(res, fmt.Sprintf("r.%s(true)", opts.goIdent("Set", cases.Title(language.English).String(field.Name),
+					cases.Title(language.English).String(bm.Name))))
+			default:
+				res = append(res, fmt.Sprintf("r.%s(%d)", opts.goIdent("Set", cases.Title(language.English).String(field.Name),
+					cases.Title(language.English).String(bm.Name)), count))
+			}
+		}
+	}
+	return res
+}
+
+// goSampleRows returns the loops assigning the elements of the multi-dimensional array,
+// every row holds 1, 2, 3...
+func goSampleRows(f *parser.Field) []string {
+	at := f.Type.Array
+	var loops, ends []string
+	elem := "r." + f.Name
+	idx := ""
+	for i := 0; i <= len(at.Dims); i++ {
+		idx = fmt.Sprintf("i%d", i)
+		loops = append(loops, fmt.Sprintf("for %s := range %s {", idx, elem))
+		ends = append(ends, "}")
+		elem += "[" + idx + "]"
+	}
+	loops = append(loops, fmt.Sprintf("\t%s = %s(%s + 1)", elem, toGoTypes(at.Type.Name), idx))
+	return append(loops, ends...)
+}
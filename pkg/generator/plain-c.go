@@ -0,0 +1,1029 @@
+package generator
+
+import (
+	"bytes"
+	"fmt"
+	"strconv"
+	"strings"
+	"text/template"
+	"unicode"
+
+	"github.com/dspasibenko/pargus/pkg/parser"
+)
+
+//
+// C template
+//
+
+const hTemplate = `
+// This is auto-generated file. DO NOT EDIT. Use pargus compiler to regenerate it.
+
+#ifndef {{.Guard}}
+#define {{.Guard}}
+
+#include <stdbool.h>
+#include <stddef.h>
+#include <stdint.h>
+
+#ifdef __cplusplus
+extern "C" {
+#endif
+
+{{- range .Doc}}
+{{.}}
+{{- end}}
+
+// Register IDs
+{{- range .Registers}}
+#define Reg_{{.Name}}_ID {{.Number}}
+{{- end}}
+
+#define Max_Reg_ID {{.MaxRegisterId}}
+{{- if .Versions}}
+
+// Register wire versions, the version byte precedes the register fields on the wire
+{{- range .Registers}}{{- if .HasVersion}}
+#define Reg_{{.Name}}_Version {{.Version}}
+{{- end}}{{- end}}
+{{- end}}
+
+{{- range .Constants}}
+{{range .Doc}}{{.}}
+{{end -}}
+#define {{.Name}} (({{.Type}}){{.Value}})
+{{- end}}
+
+{{- range .Enums}}{{$enum := .Name}}
+{{range .Doc}}{{.}}
+{{end -}}
+typedef {{.Base}} {{.Name}};
+{{- range .Members}}
+{{- range .Doc}}
+{{.}}
+{{- end}}
+#define {{.Name}} (({{$enum}}){{.Value}})
+{{- end}}
+{{- end}}
+
+{{- range .Registers}}
+{{range .Doc}}{{.}}
+{{end -}}
+typedef struct {{.Name}} {
+{{- range .Fields}}
+    {{- range .Doc}}
+    {{.}}
+    {{- end}}
+    {{.Decl}}{{if .Trailing}} {{.Trailing}}{{end}}
+{{- end}}
+{{- if not .HasMembers}}
+    char unused; // C does not allow the empty structs
+{{- end}}
+} {{.Name}};
+{{- range .Constants}}
+{{range .Doc}}{{.}}
+{{end -}}
+#define {{.Name}} (({{.Type}}){{.Value}})
+{{- end}}
+{{- range .Fields}}
+{{- range .BitMasks}}
+{{.}}
+{{- end}}
+{{- end}}
+
+{{- if .HasDefaults}}
+
+// Zeroes the register fields and sets the fields with the default values to them
+void {{.Prefix}}_init({{.Name}}* r);
+{{- end}}
+
+int {{.Prefix}}_serialize_read(const {{.Name}}* r, uint8_t* buf, size_t size);
+int {{.Prefix}}_serialize_write(const {{.Name}}* r, uint8_t* buf, size_t size);
+int {{.Prefix}}_deserialize_read({{.Name}}* r, const uint8_t* buf, size_t size);
+int {{.Prefix}}_deserialize_write({{.Name}}* r, const uint8_t* buf, size_t size);
+size_t {{.Prefix}}_buf_size_read(const {{.Name}}* r);
+size_t {{.Prefix}}_buf_size_write(const {{.Name}}* r);
+int {{.Prefix}}_check(const {{.Name}}* r);
+{{- range .Fields}}{{- if .Accessors}}
+{{range .Accessors}}
+{{.}}
+{{- end}}
+{{- end}}{{- end}}
+{{- end}}
+
+#ifdef __cplusplus
+}
+#endif
+
+#endif // {{.Guard}}
+`
+
+const cTemplate = `
+// This is auto-generated file. DO NOT EDIT. Use pargus compiler to regenerate it.
+
+#include "{{.HFileName}}"
+
+#include <string.h>
+{{- if .BigEndian}}
+
+// Writes the n low bytes of v into buf in big-endian byte order
+static void put_be(uint8_t* buf, uint64_t v, size_t n) {
+	for (size_t i = 0; i < n; i++) {
+		buf[i] = (uint8_t)(v >> (8 * (n - 1 - i)));
+	}
+}
+
+// Reads the n bytes integer from buf in big-endian byte order
+static uint64_t get_be(const uint8_t* buf, size_t n) {
+	uint64_t v = 0;
+	for (size_t i = 0; i < n; i++) {
+		v = (v << 8) | buf[i];
+	}
+	return v;
+}
+{{- end}}
+{{- if .LittleEndian}}
+
+// Writes the n low bytes of v into buf in little-endian byte order
+static void put_le(uint8_t* buf, uint64_t v, size_t n) {
+	for (size_t i = 0; i < n; i++) {
+		buf[i] = (uint8_t)(v >> (8 * i));
+	}
+}
+
+// Reads the n bytes integer from buf in little-endian byte order
+static uint64_t get_le(const uint8_t* buf, size_t n) {
+	uint64_t v = 0;
+	for (size_t i = 0; i < n; i++) {
+		v = (v << 8) | buf[n - 1 - i];
+	}
+	return v;
+}
+{{- end}}
+{{- if .Float16}}
+
+// Returns the IEEE 754 half-precision bits of the float value rounded to the nearest even, the
+// values too large for the half precision become infinities and the too small ones zeros
+static uint64_t float16_bits(float v) {
+	uint32_t bits;
+	memcpy(&bits, &v, sizeof(bits));
+	uint32_t sign = (bits >> 16) & 0x8000;
+	int32_t exp = (int32_t)((bits >> 23) & 0xff) - 127 + 15;
+	uint32_t mant = bits & 0x7fffff;
+	if ((bits & 0x7fffffff) > 0x7f800000) {
+		return sign | 0x7e00;
+	}
+	if (exp >= 0x1f) {
+		return sign | 0x7c00;
+	}
+	uint32_t shift = 13;
+	if (exp <= 0) {
+		if (exp < -10) {
+			return sign;
+		}
+		// the subnormal keeps the implicit leading bit in the mantissa
+		mant |= 0x800000;
+		shift = (uint32_t)(14 - exp);
+		exp = 0;
+	}
+	// the rounding carry may overflow the mantissa to the exponent, which is still correct
+	uint32_t h = ((uint32_t)exp << 10) + (mant >> shift);
+	uint32_t rem = mant & (((uint32_t)1 << shift) - 1);
+	uint32_t half = (uint32_t)1 << (shift - 1);
+	if (rem > half || (rem == half && (h & 1))) {
+		h++;
+	}
+	return sign | h;
+}
+
+// Returns the float value of the IEEE 754 half-precision bits
+static float float16_from_bits(uint64_t v) {
+	uint32_t sign = (uint32_t)(v & 0x8000) << 16;
+	uint32_t exp = (uint32_t)(v >> 10) & 0x1f;
+	uint32_t mant = (uint32_t)v & 0x3ff;
+	uint32_t bits;
+	if (exp == 0) {
+		// the subnormals are mant * 2^-24, they are normal numbers in float
+		float f = (float)mant / 16777216.0f;
+		memcpy(&bits, &f, sizeof(bits));
+		bits |= sign;
+	} else if (exp == 0x1f) {
+		bits = sign | 0x7f800000 | (mant << 13);
+	} else {
+		bits = sign | ((exp + 127 - 15) << 23) | (mant << 13);
+	}
+	float f;
+	memcpy(&f, &bits, sizeof(f));
+	return f;
+}
+{{- end}}
+{{- if .Float32}}
+
+// Returns the bits of the float value
+static uint64_t float32_bits(float v) {
+	uint32_t u;
+	memcpy(&u, &v, sizeof(u));
+	return u;
+}
+
+// Returns the float value of the bits
+static float float32_from_bits(uint64_t v) {
+	uint32_t u = (uint32_t)v;
+	float f;
+	memcpy(&f, &u, sizeof(f));
+	return f;
+}
+{{- end}}
+{{- if .Float64}}
+
+// Returns the bits of the double value
+static uint64_t float64_bits(double v) {
+	uint64_t u;
+	memcpy(&u, &v, sizeof(u));
+	return u;
+}
+
+// Returns the double value of the bits
+static double float64_from_bits(uint64_t v) {
+	double f;
+	memcpy(&f, &v, sizeof(f));
+	return f;
+}
+{{- end}}
+{{- if index .CRCs "ccitt"}}
+
+// Returns the CRC-16/CCITT-FALSE checksum of the data
+static uint16_t crc16_ccitt(const uint8_t* data, size_t size) {
+	uint16_t crc = 0xFFFF;
+	for (size_t i = 0; i < size; i++) {
+		crc ^= (uint16_t)(data[i] << 8);
+		for (int b = 0; b < 8; b++) {
+			crc = (crc & 0x8000) ? (uint16_t)((crc << 1) ^ 0x1021) : (uint16_t)(crc << 1);
+		}
+	}
+	return crc;
+}
+{{- end}}
+{{- if index .CRCs "modbus"}}
+
+// Returns the CRC-16/MODBUS checksum of the data
+static uint16_t crc16_modbus(const uint8_t* data, size_t size) {
+	uint16_t crc = 0xFFFF;
+	for (size_t i = 0; i < size; i++) {
+		crc ^= data[i];
+		for (int b = 0; b < 8; b++) {
+			crc = (crc & 1) ? (uint16_t)((crc >> 1) ^ 0xA001) : (uint16_t)(crc >> 1);
+		}
+	}
+	return crc;
+}
+{{- end}}
+{{- if index .CRCs "ieee"}}
+
+// Returns the CRC-32/IEEE checksum of the data
+static uint32_t crc32_ieee(const uint8_t* data, size_t size) {
+	uint32_t crc = 0xFFFFFFFF;
+	for (size_t i = 0; i < size; i++) {
+		crc ^= data[i];
+		for (int b = 0; b < 8; b++) {
+			crc = (crc & 1) ? (crc >> 1) ^ 0xEDB88320 : crc >> 1;
+		}
+	}
+	return ~crc;
+}
+{{- end}}
+{{- range .Registers}}
+
+// ================= {{.Name}} implementation =================
+{{if .HasDefaults -}}
+// Zeroes the register fields and sets the fields with the default values to them
+void {{.Prefix}}_init({{.Name}}* r) {
+	memset(r, 0, sizeof(*r));
+{{- range .Fields}}{{- range .InitData}}
+	{{.}}
+{{- end}}{{- end}}
+}
+
+{{end -}}
+// Returns the buffer size required for read fields serialization
+size_t {{.Prefix}}_buf_size_read(const {{.Name}}* r) {
+	size_t size = {{.BufSize4ReadConst}};
+{{- range .Fields}}{{- range .BufSize4ReadCode}}
+	{{.}}
+{{- end}}{{- end}}
+	return size;
+}
+
+// Returns the buffer size required for write fields serialization
+size_t {{.Prefix}}_buf_size_write(const {{.Name}}* r) {
+	size_t size = {{.BufSize4WriteConst}};
+{{- range .Fields}}{{- range .BufSize4WriteCode}}
+	{{.}}
+{{- end}}{{- end}}
+	return size;
+}
+
+// Validates the consistency of variable-length arrays with their size fields and the strings length,
+// returns -2 if an array is not set, but its size field is not zero, a string is too long or
+// a field is out of its range
+int {{.Prefix}}_check(const {{.Name}}* r) {
+{{- range .Fields}}
+{{- range .ConsistencyChecks}}
+	{{.}}
+{{- end}}
+{{- end}}
+	return 0;
+}
+
+// Send read-only fields to wire (register read fields -> wire)
+int {{.Prefix}}_serialize_read(const {{.Name}}* r, uint8_t* buf, size_t size) {
+	{int res = {{.Prefix}}_check(r); if (res < 0) return res;}
+	size_t offset = 0;
+{{- if .VersionRead}}
+	if (size < 1) return -1;
+	buf[0] = Reg_{{.Name}}_Version; offset = 1;
+{{- end}}
+{{- range .Fields}}{{- range .SerializeReadData}}
+	{{.}}
+{{- end}}{{- end}}
+	return (int)offset;
+}
+
+// Send write-only fields to wire (register write fields -> wire)
+int {{.Prefix}}_serialize_write(const {{.Name}}* r, uint8_t* buf, size_t size) {
+	{int res = {{.Prefix}}_check(r); if (res < 0) return res;}
+	size_t offset = 0;
+{{- if .VersionWrite}}
+	if (size < 1) return -1;
+	buf[0] = Reg_{{.Name}}_Version; offset = 1;
+{{- end}}
+{{- range .Fields}}{{- range .SerializeWriteData}}
+	{{.}}
+{{- end}}{{- end}}
+	return (int)offset;
+}
+
+// Get read-only fields from wire (wire -> the register read fields)
+int {{.Prefix}}_deserialize_read({{.Name}}* r, const uint8_t* buf, size_t size) {
+	size_t offset = 0;
+{{- if .VersionRead}}
+	if (size < 1) return -1;
+	if (buf[0] != Reg_{{.Name}}_Version) return -6;
+	offset = 1;
+{{- end}}
+{{- range .Fields}}{{- range .DeserializeReadData}}
+	{{.}}
+{{- end}}{{- end}}
+	return (int)offset;
+}
+
+// Get write-only fields from wire (wire -> the register writable fields)
+int {{.Prefix}}_deserialize_write({{.Name}}* r, const uint8_t* buf, size_t size) {
+	size_t offset = 0;
+{{- if .VersionWrite}}
+	if (size < 1) return -1;
+	if (buf[0] != Reg_{{.Name}}_Version) return -6;
+	offset = 1;
+{{- end}}
+{{- range .Fields}}{{- range .DeserializeWriteData}}
+	{{.}}
+{{- end}}{{- end}}
+	return (int)offset;
+}
+{{- end}}
+`
+
+var (
+	hTpl = template.Must(template.New("h").Parse(hTemplate))
+	cTpl = template.Must(template.New("c").Parse(cTemplate))
+)
+
+//
+// Intermediate representation for template
+//
+
+type CDevice struct {
+	Doc           []string
+	HFileName     string
+	Guard         string // the include guard macro of the header
+	Constants     []CppConstant
+	Enums         []CEnum
+	Registers     []CRegister
+	MaxRegisterId int
+	BigEndian     bool            // true if any field is encoded in big-endian byte order
+	LittleEndian  bool            // true if any field is encoded in little-endian byte order
+	Float16       bool            // true if any field is a float16
+	Float32       bool            // true if any field is a float32
+	Float64       bool            // true if any field is a float64
+	CRCs          map[string]bool // checksum algorithms the registers use
+	Versions      bool            // true if any register has the wire version
+}
+
+type CEnum struct {
+	Doc     []string
+	Name    string
+	Base    string
+	Members []CppEnumMember
+}
+
+type CRegister struct {
+	Name               string
+	Prefix             string // the snake case prefix of the register functions
+	Number             int
+	Doc                []string
+	Constants          []CppConstant
+	Fields             []CField
+	HasMembers         bool // false if no field takes a struct member
+	HasDefaults        bool // the register has the <prefix>_init function setting the default values
+	BufSize4ReadConst  int
+	BufSize4WriteConst int
+	HasVersion         bool // the version byte precedes the fields in the directions the register is sent in
+	Version            int
+	VersionRead        bool
+	VersionWrite       bool
+}
+
+type CField struct {
+	Doc                  []string
+	Name                 string
+	BitMasks             []string // the bit members masks and their comments
+	Decl                 string
+	IsReadable           bool
+	IsWritable           bool
+	SerializeReadData    []string // Code for <prefix>_serialize_read function
+	SerializeWriteData   []string // Code for <prefix>_serialize_write function
+	DeserializeReadData  []string // Code for <prefix>_deserialize_read function
+	DeserializeWriteData []string // Code for <prefix>_deserialize_write function
+	InitData             []string // Code for <prefix>_init function setting the default values
+	Trailing             string
+	BufSize4ReadCode     []string // Statements adding the variable size (empty if constant)
+	BufSize4WriteCode    []string // Statements adding the variable size (empty if constant)
+	ConsistencyChecks    []string // Checks for variable-length arrays, strings and ranges
+	Accessors            []string // Inline getters and setters of the bit field members and fixed-point numbers
+}
+
+//
+// Public entry
+//
+
+// GenerateHC generates the C99 header and source for the device. The registers are plain
+// structs encoded by the <register>_serialize_read and the other functions declared in
+// the header, the variable-length arrays are pointers sized by their size fields
+func GenerateHC(dev *parser.Device, hFileName string) (string, string, error) {
+	out := CDevice{HFileName: hFileName, Guard: cIncludeGuard(hFileName)}
+	out.Doc = declComments(dev.Doc, dev.TrailingComment)
+	// the device constants are the macros, so they are prefixed by the device name like the
+	// register constants by the register one, e.g. the constant size would replace the parameters
+	prefix := strings.ReplaceAll(dev.Name, "-", "_") + "_"
+	for _, c := range dev.Constants {
+		out.Constants = append(out.Constants, CppConstant{
+			Doc:   declComments(c.Doc, c.TrailingComment),
+			Name:  prefix + c.Name,
+			Type:  toCppTypes(c.Type.Name),
+			Value: cppIntLiteral(c.ValueStr),
+		})
+	}
+	for _, e := range dev.Enums {
+		ce := CEnum{
+			Doc:  declComments(e.Doc, e.TrailingComment),
+			Name: e.Name,
+			Base: toCppTypes(e.Base),
+		}
+		// the C enumerators share one scope, so they are prefixed by the enum name
+		for _, m := range e.Members {
+			ce.Members = append(ce.Members, CppEnumMember{
+				Doc:   declComments(m.Doc, m.TrailingComment),
+				Name:  e.Name + "_" + m.Name,
+				Value: cppIntLiteral(m.ValueStr),
+			})
+		}
+		out.Enums = append(out.Enums, ce)
+	}
+	for _, reg := range dev.Registers {
+		num := int(reg.Number())
+		out.MaxRegisterId = max(out.MaxRegisterId, num)
+		cr := CRegister{
+			Name:        reg.Name,
+			Prefix:      cSnakeCase(reg.Name),
+			Number:      num,
+			Doc:         declComments(reg.Doc, reg.TrailingComment),
+			HasDefaults: hasDefaults(dev, reg),
+		}
+		if reg.HasVersion() {
+			cr.HasVersion, cr.Version = true, int(reg.Version())
+			cr.VersionRead, cr.VersionWrite = reg.Specifier != "w", reg.Specifier != "r"
+			if cr.VersionRead {
+				cr.BufSize4ReadConst = 1
+			}
+			if cr.VersionWrite {
+				cr.BufSize4WriteConst = 1
+			}
+			out.Versions = true
+		}
+		for _, c := range reg.Body.Constants() {
+			cr.Constants = append(cr.Constants, CppConstant{
+				Doc:   declComments(c.Doc, c.TrailingComment),
+				Name:  reg.Name + "_" + c.Name,
+				Type:  toCppTypes(c.Type.Name),
+				Value: cppIntLiteral(c.ValueStr),
+			})
+		}
+
+		for _, f := range reg.Body.Fields() {
+			cf := CField{
+				Doc:        flattenComments(f.Doc),
+				Name:       f.Name,
+				Trailing:   safeString(f.TrailingComment),
+				IsReadable: f.Specifier == "r" || f.Specifier == "",
+				IsWritable: f.Specifier == "w" || f.Specifier == "",
+			}
+			field := "r->" + f.Name
+			readConst, writeConst := cr.BufSize4ReadConst, cr.BufSize4WriteConst
+
+			// The field placed at the offset is preceded by the zero bytes filling the gap
+			if pad := reg.Padding(f, true); pad > 0 {
+				serCode, deserCode := cppPaddingCode("", pad)
+				cf.SerializeReadData = append(cf.SerializeReadData, serCode...)
+				cf.DeserializeReadData = append(cf.DeserializeReadData, deserCode...)
+				cr.BufSize4ReadConst += pad
+			}
+			if pad := reg.Padding(f, false); pad > 0 {
+				serCode, deserCode := cppPaddingCode("", pad)
+				cf.SerializeWriteData = append(cf.SerializeWriteData, serCode...)
+				cf.DeserializeWriteData = append(cf.DeserializeWriteData, deserCode...)
+				cr.BufSize4WriteConst += pad
+			}
+
+			switch {
+			case f.Reserved:
+				size := reservedSize(f)
+				cf.Decl = fmt.Sprintf("// reserved %d byte(s)", size)
+				serCode, deserCode := cppPaddingCode("", size)
+				cf.add(&cr, size, nil, serCode, deserCode)
+
+			case f.Type.CRC != nil:
+				order := out.byteOrder(f)
+				// the checksum is calculated over the bytes preceding it, so it has no value to keep
+				base := f.Type.CRC.BaseType()
+				size := wireTypeSize(base)
+				crcFn := out.cCRCFunc(f.Type.CRC)
+				cf.Decl = fmt.Sprintf("// %s: %s checksum of the preceding bytes", f.Name, f.Type.CRC.Kind)
+				cf.add(&cr, size, nil, []string{
+					fmt.Sprintf("if (offset + %d > size) return -1;", size),
+					fmt.Sprintf("put_%s(buf + offset, %s(buf, offset), %d); offset += %d;", order, crcFn, size, size),
+				}, []string{
+					fmt.Sprintf("if (offset + %d > size) return -1;", size),
+					fmt.Sprintf("if ((%s)get_%s(buf + offset, %d) != %s(buf, offset)) return -4;", toCppTypes(base), order, size, crcFn),
+					fmt.Sprintf("offset += %d;", size),
+				})
+
+			case f.IsMagic():
+				order := out.byteOrder(f)
+				// the magic value is always the same, so it has no value to keep
+				typ := f.Type.Simple.Name
+				size := wireTypeSize(typ)
+				magic := fmt.Sprintf("((%s)%s)", toCppTypes(typ), magicLiteral(f))
+				cf.Decl = fmt.Sprintf("// %s: magic %s", f.Name, magicLiteral(f))
+				cf.add(&cr, size, nil, []string{
+					fmt.Sprintf("if (offset + %d > size) return -1;", size),
+					fmt.Sprintf("%s offset += %d;", cPut(typ, order, "buf + offset", magic), size),
+				}, []string{
+					fmt.Sprintf("if (offset + %d > size) return -1;", size),
+					fmt.Sprintf("if (%s != %s) return -5;", cGet(typ, order, "buf + offset"), magic),
+					fmt.Sprintf("offset += %d;", size),
+				})
+
+			case f.Type.Simple != nil && f.Type.Simple.IsRegisterRef():
+				ref := f.Type.Simple.Name
+				refPrefix := cSnakeCase(ref)
+				cf.Decl = fmt.Sprintf("%s %s;", ref, f.Name)
+				for _, dir := range cf.dirs() {
+					cf.addDir(dir, fmt.Sprintf("size += %s_buf_size_%s(&%s);", refPrefix, dir, field),
+						[]string{cNestedCall(refPrefix, "serialize_"+dir, "&"+field)},
+						[]string{cNestedCall(refPrefix, "deserialize_"+dir, "&"+field)})
+				}
+
+			case f.Type.Bitfield != nil:
+				order := out.byteOrder(f)
+				bf := f.Type.Bitfield
+				base := toCppTypes(bf.Base)
+				size := wireTypeSize(bf.Base)
+				cf.Decl = fmt.Sprintf("%s %s;", base, f.Name)
+				for _, bm := range bf.Bits {
+					if bm.Reserved {
+						// reserved members only document the unused bits
+						continue
+					}
+					cf.BitMasks = append(cf.BitMasks, flattenComments(bm.Doc)...)
+					start, end := bm.StartBit(), bm.EndBit()
+					bitRange := bm.Start
+					if start != end {
+						bitRange = fmt.Sprintf("%d-%d", start, end)
+					}
+					bmName := fmt.Sprintf("%s_%s_%s_bm", reg.Name, f.Name, bm.Name)
+					cf.BitMasks = append(cf.BitMasks,
+						fmt.Sprintf("// %s bit field (bits %s)", bm.Name, bitRange),
+						fmt.Sprintf("#define %s ((%s)0x%X)", bmName, base, bitMask(start, end)))
+
+					get := fmt.Sprintf("%s_get_%s_%s", cr.Prefix, f.Name, bm.Name)
+					set := fmt.Sprintf("%s_set_%s_%s", cr.Prefix, f.Name, bm.Name)
+					if start == end {
+						cf.Accessors = append(cf.Accessors,
+							fmt.Sprintf("static inline bool %s(const %s* r) { return (%s & %s) != 0; }", get, reg.Name, field, bmName),
+							fmt.Sprintf("static inline void %s(%s* r, bool v) { if (v) %s |= %s; else %s &= (%s)~%s; }",
+								set, reg.Name, field, bmName, field, base, bmName))
+					} else if bm.Signed {
+						// the member is moved to the highest bits, so the arithmetic shift extends its sign
+						signed := toCppTypes(strings.TrimPrefix(bf.Base, "u"))
+						top := size*8 - 1 - end
+						cf.Accessors = append(cf.Accessors,
+							fmt.Sprintf("static inline %s %s(const %s* r) { return (%s)((%s)(%s << %d) >> %d); }",
+								signed, get, reg.Name, signed, signed, field, top, top+start),
+							fmt.Sprintf("static inline void %s(%s* r, %s v) { %s = (%s)((%s & ~%s) | (((%s)v << %d) & %s)); }",
+								set, reg.Name, signed, field, base, field, bmName, base, start, bmName))
+					} else {
+						cf.Accessors = append(cf.Accessors,
+							fmt.Sprintf("static inline %s %s(const %s* r) { return (%s)((%s & %s) >> %d); }",
+								base, get, reg.Name, base, field, bmName, start),
+							fmt.Sprintf("static inline void %s(%s* r, %s v) { %s = (%s)((%s & ~%s) | (((%s)v << %d) & %s)); }",
+								set, reg.Name, base, field, base, field, bmName, base, start, bmName))
+					}
+				}
+				cf.addScalar(&cr, bf.Base, field, order)
+
+			case f.Type.Array != nil && f.Type.Array.Type.IsRegisterRef():
+				elem := f.Type.Array.Type.Name
+				elemPrefix := cSnakeCase(elem)
+				var count, bufSizeCond string
+				if f.Type.Array.Size.Constant != nil {
+					count = *f.Type.Array.Size.Constant
+					cf.Decl = fmt.Sprintf("%s %s[%s];", elem, f.Name, count)
+				} else {
+					count = cSizeFieldValue(reg, f, len(cr.Fields))
+					cf.Decl = fmt.Sprintf("%s* %s;", elem, f.Name)
+					cf.ConsistencyChecks = append(cf.ConsistencyChecks,
+						fmt.Sprintf("if (%s == NULL && %s != 0) return -2;", field, count))
+					bufSizeCond = field + " != NULL && "
+				}
+
+				// Every element is encoded by its own register functions
+				loop := fmt.Sprintf("for (size_t i = 0; i < (size_t)%s; i++)", count)
+				for _, dir := range cf.dirs() {
+					cf.addDir(dir,
+						fmt.Sprintf("for (size_t i = 0; %si < (size_t)%s; i++) size += %s_buf_size_%s(&%s[i]);",
+							bufSizeCond, count, elemPrefix, dir, field),
+						[]string{loop + " " + cNestedCall(elemPrefix, "serialize_"+dir, "&"+field+"[i]")},
+						[]string{loop + " " + cNestedCall(elemPrefix, "deserialize_"+dir, "&"+field+"[i]")})
+				}
+
+			case f.Type.Array != nil:
+				order := out.byteOrder(f)
+				at := f.Type.Array
+				elem := cType(at.Type.Name)
+				elemSize := wireTypeSize(at.Type.Name)
+				out.useType(at.Type.Name)
+				dims := "" // the inner dimensions of the multi-dimensional array
+				for _, d := range at.Dims {
+					dims += "[" + cppIntLiteral(d) + "]"
+				}
+				inner := at.InnerCount()
+
+				// the multi-dimensional array elements are contiguous, they are sent flattened
+				arr, constArr := field, field
+				if at.IsMultiDim() {
+					arr = fmt.Sprintf("((%s*)%s)", elem, field)
+					constArr = fmt.Sprintf("((const %s*)%s)", elem, field)
+				}
+				put := cPut(at.Type.Name, order, "buf + offset", constArr+"[i]")
+				get := fmt.Sprintf("%s[i] = %s;", arr, cGet(at.Type.Name, order, "buf + offset"))
+				if at.Size.Constant != nil {
+					n64, _ := strconv.ParseInt(*at.Size.Constant, 0, 64)
+					n := int(n64)
+					cf.Decl = fmt.Sprintf("%s %s[%d]%s;", elem, f.Name, n, dims)
+					size := n * inner * elemSize
+					loop := fmt.Sprintf("for (size_t i = 0; i < %d; i++)", n*inner)
+					cf.add(&cr, size, nil, []string{
+						fmt.Sprintf("if (offset + %d > size) return -1;", size),
+						fmt.Sprintf("%s {%s offset += %d;}", loop, put, elemSize),
+					}, []string{
+						fmt.Sprintf("if (offset + %d > size) return -1;", size),
+						fmt.Sprintf("%s {%s offset += %d;}", loop, get, elemSize),
+					})
+					break
+				}
+
+				count := cSizeFieldValue(reg, f, len(cr.Fields))
+				if at.IsMultiDim() {
+					// the pointer to the rows
+					cf.Decl = fmt.Sprintf("%s (*%s)%s;", elem, f.Name, dims)
+				} else {
+					cf.Decl = fmt.Sprintf("%s* %s;", elem, f.Name)
+				}
+				elems := fmt.Sprintf("(size_t)%s", count)
+				if inner > 1 {
+					elems += fmt.Sprintf(" * %d", inner)
+				}
+				cf.ConsistencyChecks = append(cf.ConsistencyChecks,
+					fmt.Sprintf("if (%s == NULL && %s != 0) return -2;", field, count))
+				for _, dir := range cf.dirs() {
+					cf.addDir(dir, fmt.Sprintf("size += %d * %s;", elemSize, elems),
+						[]string{
+							"{",
+							fmt.Sprintf("    size_t elems = %s;", elems),
+							fmt.Sprintf("    if (offset + %d*elems > size) return -1;", elemSize),
+							fmt.Sprintf("    for (size_t i = 0; i < elems; i++) {%s offset += %d;}", put, elemSize),
+							"}",
+						}, []string{
+							"{",
+							fmt.Sprintf("    size_t elems = %s;", elems),
+							fmt.Sprintf("    if (offset + %d*elems > size) return -1;", elemSize),
+							fmt.Sprintf("    for (size_t i = 0; i < elems; i++) {%s offset += %d;}", get, elemSize),
+							"}",
+						})
+				}
+
+			case f.Type.String != nil:
+				order := out.byteOrder(f)
+				prefixType := f.Type.String.PrefixType()
+				prefix := toCppTypes(prefixType)
+				prefixSize := wireTypeSize(prefixType)
+				maxLen := f.Type.String.MaxLen()
+				// the string is kept in the fixed capacity buffer, its length is in <name>_len
+				cf.Decl = fmt.Sprintf("%s %s_len;\n    char %s[%d];", prefix, f.Name, f.Name, maxLen)
+				serCode := []string{
+					fmt.Sprintf("if (offset + %d + %s_len > size) return -1;", prefixSize, field),
+					fmt.Sprintf("%s offset += %d;", cPut(prefixType, order, "buf + offset", field+"_len"), prefixSize),
+					fmt.Sprintf("memcpy(buf + offset, %s, %s_len); offset += %s_len;", field, field, field),
+				}
+				deserCode := []string{
+					fmt.Sprintf("if (offset + %d > size) return -1;", prefixSize),
+					fmt.Sprintf("%s_len = %s; offset += %d;", field, cGet(prefixType, order, "buf + offset"), prefixSize),
+				}
+				if f.Type.String.MaxLenStr != nil {
+					// without the explicit maximum the length is limited by the prefix type
+					lenCheck := fmt.Sprintf("if (%s_len > %d) return -2;", field, maxLen)
+					deserCode = append(deserCode, lenCheck)
+					cf.ConsistencyChecks = append(cf.ConsistencyChecks, lenCheck)
+				}
+				deserCode = append(deserCode,
+					fmt.Sprintf("if (offset + %s_len > size) return -1;", field),
+					fmt.Sprintf("memcpy(%s, buf + offset, %s_len); offset += %s_len;", field, field, field))
+				cf.add(&cr, prefixSize, []string{fmt.Sprintf("size += %s_len;", field)}, serCode, deserCode)
+
+			case f.Type.Simple != nil && f.Type.Simple.IsEnum():
+				order := out.byteOrder(f)
+				// the enum is sent over the wire as its base integer type
+				cf.Decl = fmt.Sprintf("%s %s;", f.Type.Simple.Name, f.Name)
+				cf.addScalar(&cr, f.Type.Simple.Enum.Base, field, order)
+
+			case f.Type.Simple != nil, f.Type.Fixed != nil:
+				order := out.byteOrder(f)
+				typ := scalarTypeName(f)
+				elem := cType(typ)
+				out.useType(typ)
+				cf.Decl = fmt.Sprintf("%s %s;", elem, f.Name)
+				if f.Type.Fixed != nil {
+					// the value is rounded half away from zero, so no math library is required
+					scale := fixedScale(f.Type.Fixed)
+					cf.Accessors = append(cf.Accessors,
+						fmt.Sprintf("static inline double %s_get_%s(const %s* r) { return (double)%s / %s; }",
+							cr.Prefix, f.Name, reg.Name, field, scale),
+						fmt.Sprintf("static inline void %s_set_%s(%s* r, double v) { %s = (%s)(v * %s + (v < 0 ? -0.5 : 0.5)); }",
+							cr.Prefix, f.Name, reg.Name, field, elem, scale))
+				}
+				if conds := rangeConditions(f, field); len(conds) > 0 {
+					cf.ConsistencyChecks = append(cf.ConsistencyChecks,
+						fmt.Sprintf("if (%s) return -2;", strings.Join(conds, " || ")))
+				}
+				cf.addScalar(&cr, typ, field, order)
+
+			default:
+				cf.Decl = fmt.Sprintf("/* unsupported field %s */", f.Name)
+			}
+
+			// The conditional field is sent only if its condition is set, the absent field keeps its value
+			if f.HasCondition() {
+				cond := cConditionExpr(reg, f, len(cr.Fields))
+				if size := cr.BufSize4ReadConst - readConst; size > 0 {
+					cf.BufSize4ReadCode = append([]string{fmt.Sprintf("size += %d;", size)}, cf.BufSize4ReadCode...)
+				}
+				if size := cr.BufSize4WriteConst - writeConst; size > 0 {
+					cf.BufSize4WriteCode = append([]string{fmt.Sprintf("size += %d;", size)}, cf.BufSize4WriteCode...)
+				}
+				cr.BufSize4ReadConst, cr.BufSize4WriteConst = readConst, writeConst
+				cf.BufSize4ReadCode = cppIf(cond, cf.BufSize4ReadCode)
+				cf.BufSize4WriteCode = cppIf(cond, cf.BufSize4WriteCode)
+				cf.SerializeReadData = cppIf(cond, cf.SerializeReadData)
+				cf.SerializeWriteData = cppIf(cond, cf.SerializeWriteData)
+				cf.DeserializeReadData = cppIf(cond, cf.DeserializeReadData)
+				cf.DeserializeWriteData = cppIf(cond, cf.DeserializeWriteData)
+				cf.ConsistencyChecks = cppIf(cond, cf.ConsistencyChecks)
+			}
+
+			// The init function sets the default values and initializes the nested registers with them
+			switch {
+			case f.HasDefault():
+				cf.InitData = []string{fmt.Sprintf("%s = %s;", field, cppIntLiteral(*f.DefaultStr))}
+			case f.Type.Simple != nil && f.Type.Simple.IsRegisterRef() && hasDefaults(dev, dev.FindRegisterByName(f.Type.Simple.Name)):
+				cf.InitData = []string{fmt.Sprintf("%s_init(&%s);", cSnakeCase(f.Type.Simple.Name), field)}
+			case f.Type.Array != nil && f.Type.Array.Size.Constant != nil && f.Type.Array.Type.IsRegisterRef() &&
+				hasDefaults(dev, dev.FindRegisterByName(f.Type.Array.Type.Name)):
+				cf.InitData = []string{fmt.Sprintf("for (size_t i = 0; i < %s; i++) %s_init(&%s[i]);",
+					*f.Type.Array.Size.Constant, cSnakeCase(f.Type.Array.Type.Name), field)}
+			}
+
+			if !strings.HasPrefix(cf.Decl, "//") && !strings.HasPrefix(cf.Decl, "/*") {
+				cr.HasMembers = true
+			}
+			cr.Fields = append(cr.Fields, cf)
+		}
+		out.Registers = append(out.Registers, cr)
+	}
+
+	var h, c bytes.Buffer
+	if err := hTpl.Execute(&h, out); err != nil {
+		return "", "", err
+	}
+	if err := cTpl.Execute(&c, out); err != nil {
+		return "", "", err
+	}
+	return strings.TrimSpace(h.String()) + "\n", strings.TrimSpace(c.String()) + "\n", nil
+}
+
+//
+// Helpers
+//
+
+// dirs returns the directions, read and write, the field is sent in
+func (f *CField) dirs() []string {
+	var res []string
+	if f.IsReadable {
+		res = append(res, "read")
+	}
+	if f.IsWritable {
+		res = append(res, "write")
+	}
+	return res
+}
+
+// add adds the code of the field taking the size bytes plus the variable size the bufSize
+// statements count to the directions the field is sent in
+func (f *CField) add(r *CRegister, size int, bufSize, serCode, deserCode []string) {
+	if f.IsReadable {
+		f.SerializeReadData = append(f.SerializeReadData, serCode...)
+		f.DeserializeReadData = append(f.DeserializeReadData, deserCode...)
+		f.BufSize4ReadCode = append(f.BufSize4ReadCode, bufSize...)
+		r.BufSize4ReadConst += size
+	}
+	if f.IsWritable {
+		f.SerializeWriteData = append(f.SerializeWriteData, serCode...)
+		f.DeserializeWriteData = append(f.DeserializeWriteData, deserCode...)
+		f.BufSize4WriteCode = append(f.BufSize4WriteCode, bufSize...)
+		r.BufSize4WriteConst += size
+	}
+}
+
+// addDir adds the code of the variable size field to the dir direction
+func (f *CField) addDir(dir, bufSize string, serCode, deserCode []string) {
+	if dir == "read" {
+		f.SerializeReadData = append(f.SerializeReadData, serCode...)
+		f.DeserializeReadData = append(f.DeserializeReadData, deserCode...)
+		f.BufSize4ReadCode = append(f.BufSize4ReadCode, bufSize)
+	} else {
+		f.SerializeWriteData = append(f.SerializeWriteData, serCode...)
+		f.DeserializeWriteData = append(f.DeserializeWriteData, deserCode...)
+		f.BufSize4WriteCode = append(f.BufSize4WriteCode, bufSize)
+	}
+}
+
+// addScalar adds the code of the field holding a value of the built-in typ
+func (f *CField) addScalar(r *CRegister, typ, field, order string) {
+	size := wireTypeSize(typ)
+	f.add(r, size, nil, []string{
+		fmt.Sprintf("if (offset + %d > size) return -1;", size),
+		fmt.Sprintf("%s offset += %d;", cPut(typ, order, "buf + offset", field), size),
+	}, []string{
+		fmt.Sprintf("if (offset + %d > size) return -1;", size),
+		fmt.Sprintf("%s = %s; offset += %d;", field, cGet(typ, order, "buf + offset"), size),
+	})
+}
+
+// cConditionExpr returns the expression which is true if the conditional field is sent, the
+// condition is either the single-bit member or the uint8 field, which is set if it is not zero
+func cConditionExpr(reg *parser.Register, f *parser.Field, idx int) string {
+	fld, bm := reg.FindFieldByName(*f.Condition, idx)
+	if bm != nil {
+		return fmt.Sprintf("(r->%s & %s_%s_%s_bm) != 0", fld.Name, reg.Name, fld.Name, bm.Name)
+	}
+	return fmt.Sprintf("r->%s != 0", fld.Name)
+}
+
+// byteOrder returns the suffix of the runtime helpers encoding the field, be or le, and
+// registers the helpers
+func (d *CDevice) byteOrder(f *parser.Field) string {
+	if f.IsLittleEndian() {
+		d.LittleEndian = true
+		return "le"
+	}
+	d.BigEndian = true
+	return "be"
+}
+
+// useType registers the runtime helpers the built-in type requires
+func (d *CDevice) useType(typ string) {
+	switch typ {
+	case "float16":
+		d.Float16 = true
+	case "float32":
+		d.Float32 = true
+	case "float64":
+		d.Float64 = true
+	}
+}
+
+// cCRCFunc returns the function calculating the checksum and registers its runtime helper
+func (d *CDevice) cCRCFunc(ct *parser.CRCType) string {
+	alg := ct.AlgorithmName()
+	if d.CRCs == nil {
+		d.CRCs = make(map[string]bool)
+	}
+	d.CRCs[alg] = true
+	return ct.Kind + "_" + alg
+}
+
+// cPut returns the statement writing the value of the built-in typ to dst in the order byte order
+func cPut(typ, order, dst, value string) string {
+	switch typ {
+	case "float16", "float32", "float64":
+		value = fmt.Sprintf("%s_bits(%s)", typ, value)
+	default:
+		value = "(uint64_t)" + value
+	}
+	return fmt.Sprintf("put_%s(%s, %s, %d);", order, dst, value, wireTypeSize(typ))
+}
+
+// cGet returns the expression reading the value of the built-in typ from src in the order byte order
+func cGet(typ, order, src string) string {
+	get := fmt.Sprintf("get_%s(%s, %d)", order, src, wireTypeSize(typ))
+	switch typ {
+	case "float16", "float32", "float64":
+		return fmt.Sprintf("%s_from_bits(%s)", typ, get)
+	case "int24":
+		// the sign bit of the 3 bytes is extended to the 32-bit integer
+		return fmt.Sprintf("(int32_t)((%s ^ 0x800000) - 0x800000)", get)
+	default:
+		return fmt.Sprintf("(%s)%s", toCppTypes(typ), get)
+	}
+}
+
+// cType returns the C type of the built-in type, the half-precision numbers are kept in float
+// and converted when they are encoded
+func cType(typ string) string {
+	if typ == "float16" {
+		return "float"
+	}
+	return toCppTypes(typ)
+}
+
+// cNestedCall returns the statement calling the register function fn over the rest of the buffer
+func cNestedCall(prefix, fn, reg string) string {
+	return fmt.Sprintf("{int res = %s_%s(%s, buf + offset, size - offset); if (res < 0) return res; offset += (size_t)res;}",
+		prefix, fn, reg)
+}
+
+// cSizeFieldValue returns the expression of the variable-length array f size field value
+func cSizeFieldValue(reg *parser.Register, f *parser.Field, idx int) string {
+	field, bm := reg.FindFieldByName(*f.Type.Array.Size.Variable, idx)
+	if bm != nil {
+		return fmt.Sprintf("((r->%s & %s_%s_%s_bm) >> %d)", field.Name, reg.Name, field.Name, bm.Name, bm.StartBit())
+	}
+	return "r->" + field.Name
+}
+
+// cSnakeCase converts the register name to the snake case prefix of its functions,
+// e.g. SensorData is sensor_data
+func cSnakeCase(name string) string {
+	runes := []rune(name)
+	var sb strings.Builder
+	for i, r := range runes {
+		if unicode.IsUpper(r) && i > 0 && runes[i-1] != '_' &&
+			(!unicode.IsUpper(runes[i-1]) || (i+1 < len(runes) && unicode.IsLower(runes[i+1]))) {
+			sb.WriteRune('_')
+		}
+		sb.WriteRune(unicode.ToLower(r))
+	}
+	return sb.String()
+}
+
+// cIncludeGuard returns the include guard macro of the header file, e.g. DEVICE_H for device.h
+func cIncludeGuard(fileName string) string {
+	guard := strings.Map(func(r rune) rune {
+		if r < unicode.MaxASCII && (unicode.IsLetter(r) || unicode.IsDigit(r)) {
+			return unicode.ToUpper(r)
+		}
+		return '_'
+	}, fileName)
+	if guard == "" || unicode.IsDigit(rune(guard[0])) {
+		guard = "_" + guard
+	}
+	return guard
+}
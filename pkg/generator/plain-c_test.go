@@ -0,0 +1,526 @@
+package generator
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+
+	"github.com/dspasibenko/pargus/pkg/parser"
+	"github.com/stretchr/testify/require"
+)
+
+// runGeneratedCTest puts the generated C code and the test program into a temporary
+// directory, then compiles them as C99 and runs the program there
+func runGeneratedCTest(t *testing.T, h, c, mainCode string) {
+	t.Helper()
+	cc, err := exec.LookPath("gcc")
+	if err != nil {
+		t.Skip("gcc is not available")
+	}
+
+	dir := t.TempDir()
+	files := map[string]string{
+		"test.h": h,
+		"test.c": c,
+		"main.c": mainCode,
+	}
+	for name, content := range files {
+		require.NoError(t, os.WriteFile(filepath.Join(dir, name), []byte(content), 0644))
+	}
+
+	cmd := exec.Command(cc, "-std=c99", "-pedantic-errors", "-Wall", "-Werror", "-o", "test", "main.c", "test.c")
+	cmd.Dir = dir
+	out, err := cmd.CombinedOutput()
+	require.NoError(t, err, "generated code compilation failed:\n%s\n%s\n%s", out, h, c)
+
+	cmd = exec.Command(filepath.Join(dir, "test"))
+	out, err = cmd.CombinedOutput()
+	require.NoError(t, err, "generated code test failed:\n%s", out)
+}
+
+func TestGenerateCGolden(t *testing.T) {
+	input, err := os.ReadFile("testdata/example.pa")
+	require.NoError(t, err)
+	device, err := parser.Parse(string(input))
+	require.NoError(t, err)
+
+	h, c, err := GenerateHC(device, "example.h")
+	require.NoError(t, err)
+	goldenH, err := os.ReadFile("testdata/example.h")
+	require.NoError(t, err)
+	goldenC, err := os.ReadFile("testdata/example.c")
+	require.NoError(t, err)
+	require.Equal(t, string(goldenH), h)
+	require.Equal(t, string(goldenC), c)
+}
+
+func TestGeneratedCRoundTrip(t *testing.T) {
+	input, err := os.ReadFile("testdata/example.pa")
+	require.NoError(t, err)
+	device, err := parser.Parse(string(input))
+	require.NoError(t, err)
+	h, c, err := GenerateHC(device, "test.h")
+	require.NoError(t, err)
+
+	runGeneratedCTest(t, h, c, `
+#include <stdio.h>
+#include <string.h>
+#include "test.h"
+
+#define CHECK(cond) do { if (!(cond)) { printf("line %d: %s\n", __LINE__, #cond); return 1; } } while (0)
+
+int main(void) {
+	uint8_t buf[64];
+
+	Config cfg = {0};
+	cfg.mode = Mode_STANDBY;
+	cfg.level = 42;
+	cfg.name_len = 3;
+	memcpy(cfg.name, "abc", 3);
+	CHECK(config_buf_size_write(&cfg) == 6);
+	CHECK(config_serialize_write(&cfg, buf, sizeof(buf)) == 6);
+	CHECK(memcmp(buf, "\x02\x2a\x03" "abc", 6) == 0);
+	CHECK(config_serialize_write(&cfg, buf, 5) == -1);
+	Config cfg2 = {0};
+	CHECK(config_deserialize_write(&cfg2, buf, 6) == 6);
+	CHECK(cfg2.mode == Mode_STANDBY && cfg2.level == 42 && cfg2.name_len == 3 && memcmp(cfg2.name, "abc", 3) == 0);
+	cfg.level = Config_maxLevel + 1;
+	CHECK(config_serialize_write(&cfg, buf, sizeof(buf)) == -2);
+
+	int16_t samples[2] = {0x0102, -2};
+	Status st = {0};
+	st.counter = -5;
+	status_set_flags_ready(&st, true);
+	status_set_flags_error(&st, 5);
+	status_set_flags_count(&st, 2);
+	status_set_temp(&st, 1.5);
+	CHECK(st.temp == 24);
+	CHECK(status_check(&st) == -2);
+	st.samples = samples;
+	CHECK(status_buf_size_read(&st) == 11);
+	CHECK(status_serialize_read(&st, buf, sizeof(buf)) == 11);
+	CHECK(memcmp(buf, "\xff\xff\xff\xfb\x2b\x00\x18\x02\x01\xfe\xff", 11) == 0);
+	int16_t samples2[2];
+	Status st2 = {0};
+	st2.samples = samples2;
+	CHECK(status_deserialize_read(&st2, buf, 11) == 11);
+	CHECK(st2.counter == -5 && status_get_flags_ready(&st2) && status_get_flags_error(&st2) == 5);
+	CHECK(status_get_temp(&st2) == 1.5 && samples2[0] == 0x0102 && samples2[1] == -2);
+
+	DataFrame df = {0};
+	df.points[0].x = -2;
+	df.points[0].y = 0.5f;
+	df.points[1].x = 0x123456;
+	df.matrix[1][2] = 7;
+	CHECK(data_frame_buf_size_write(&df) == 22);
+	CHECK(data_frame_serialize_write(&df, buf, sizeof(buf)) == 22);
+	CHECK(memcmp(buf, "\xff\xff\xfe\x3f\x00\x00\x00\x12\x34\x56", 10) == 0);
+	CHECK(buf[19] == 7);
+	DataFrame df2 = {0};
+	CHECK(data_frame_deserialize_write(&df2, buf, 22) == 22);
+	CHECK(df2.points[0].x == -2 && df2.points[0].y == 0.5f && df2.points[1].x == 0x123456 && df2.matrix[1][2] == 7);
+	buf[0] ^= 1;
+	CHECK(data_frame_deserialize_write(&df2, buf, 22) == -4);
+	return 0;
+}
+`)
+}
+
+func TestGeneratedCArrays(t *testing.T) {
+	device, err := parser.Parse(`
+    device test @le
+
+    register Empty(1) {
+        reserved [2]uint8;
+    };
+
+    register Rows(2) {
+        count uint8;
+        rows [count][2]uint16;
+        values [2]float64 @be;
+        crc crc32;
+    };
+
+    register Sync(3) {
+        sync = 0xAA55 int16 @be;
+    };
+
+    register Hex(4) {
+        a [0x4]uint8;
+    };`)
+	require.NoError(t, err)
+	h, c, err := GenerateHC(device, "test.h")
+	require.NoError(t, err)
+	require.Contains(t, h, "    uint16_t (*rows)[2];")
+	require.Contains(t, h, "    char unused; // C does not allow the empty structs")
+	require.NotContains(t, c, "float32_bits")
+
+	runGeneratedCTest(t, h, c, `
+#include <stdio.h>
+#include <string.h>
+#include "test.h"
+
+#define CHECK(cond) do { if (!(cond)) { printf("line %d: %s\n", __LINE__, #cond); return 1; } } while (0)
+
+int main(void) {
+	uint8_t buf[64];
+
+	Empty e = {0};
+	CHECK(empty_serialize_read(&e, buf, sizeof(buf)) == 2);
+	CHECK(empty_deserialize_read(&e, buf, 1) == -1);
+
+	uint16_t rows[2][2] = {{1, 2}, {3, 0x0405}};
+	Rows r = {0};
+	r.count = 2;
+	r.values[1] = -1.25;
+	CHECK(rows_check(&r) == -2);
+	r.rows = rows;
+	CHECK(rows_buf_size_write(&r) == 29);
+	CHECK(rows_serialize_write(&r, buf, sizeof(buf)) == 29);
+	CHECK(memcmp(buf, "\x02\x01\x00\x02\x00\x03\x00\x05\x04", 9) == 0);
+	CHECK(buf[17] == 0xbf && buf[18] == 0xf4);
+
+	uint16_t rows2[2][2];
+	Rows r2 = {0};
+	r2.rows = rows2;
+	CHECK(rows_deserialize_write(&r2, buf, 29) == 29);
+	CHECK(r2.count == 2 && rows2[1][1] == 0x0405 && r2.values[1] == -1.25);
+	buf[3] ^= 1;
+	CHECK(rows_deserialize_write(&r2, buf, 29) == -4);
+
+	Sync s = {0};
+	CHECK(sync_serialize_write(&s, buf, sizeof(buf)) == 2);
+	CHECK(buf[0] == 0xaa && buf[1] == 0x55);
+	CHECK(sync_deserialize_write(&s, buf, 2) == 2);
+	buf[0] = 0;
+	CHECK(sync_deserialize_write(&s, buf, 2) == -5);
+
+	Hex x = {{1, 2, 3, 4}};
+	CHECK(hex_buf_size_write(&x) == 4);
+	CHECK(hex_serialize_write(&x, buf, sizeof(buf)) == 4);
+	CHECK(memcmp(buf, "\x01\x02\x03\x04", 4) == 0);
+	Hex x2 = {0};
+	CHECK(hex_deserialize_write(&x2, buf, 4) == 4);
+	CHECK(memcmp(x2.a, x.a, sizeof(x.a)) == 0);
+	return 0;
+}
+`)
+}
+
+func TestCSnakeCase(t *testing.T) {
+	for name, expected := range map[string]string{
+		"Control":    "control",
+		"DataFrame":  "data_frame",
+		"ADCReading": "adc_reading",
+		"Reg2Value":  "reg2_value",
+		"my_reg":     "my_reg",
+	} {
+		require.Equal(t, expected, cSnakeCase(name))
+	}
+	require.Equal(t, "DEVICE_H", cIncludeGuard("device.h"))
+	require.Equal(t, "_2ND_DEVICE_H", cIncludeGuard("2nd-device.h"))
+}
+
+func TestGeneratedCFieldOffset(t *testing.T) {
+	device, err := parser.Parse(`
+    device test
+
+    register Map(1) {
+        id uint8;
+        status:r uint16 @offset 4;
+        mode:w uint8 @offset 8;
+        value uint16 @offset 10 @le;
+        count uint8;
+        data [count]uint8;
+    };`)
+	require.NoError(t, err)
+	h, c, err := GenerateHC(device, "test.h")
+	require.NoError(t, err)
+
+	runGeneratedCTest(t, h, c, `
+#include <stdio.h>
+#include <string.h>
+#include "test.h"
+
+#define CHECK(cond) do { if (!(cond)) { printf("line %d: %s\n", __LINE__, #cond); return 1; } } while (0)
+
+int main(void) {
+	uint8_t buf[14];
+	uint8_t data[1] = {9};
+	Map m = {0};
+	m.id = 1;
+	m.status = 0x0203;
+	m.mode = 5;
+	m.value = 0x0607;
+	m.count = 1;
+	m.data = data;
+	CHECK(map_buf_size_read(&m) == 14 && map_buf_size_write(&m) == 14);
+
+	memset(buf, 0xff, sizeof(buf));
+	CHECK(map_serialize_read(&m, buf, sizeof(buf)) == 14);
+	CHECK(memcmp(buf, "\x01\x00\x00\x00\x02\x03\x00\x00\x00\x00\x07\x06\x01\x09", 14) == 0);
+	CHECK(map_serialize_write(&m, buf, sizeof(buf)) == 14);
+	CHECK(memcmp(buf, "\x01\x00\x00\x00\x00\x00\x00\x00\x05\x00\x07\x06\x01\x09", 14) == 0);
+
+	uint8_t decoded[1] = {0};
+	Map d = {0};
+	d.data = decoded;
+	buf[3] = 0xff;
+	CHECK(map_deserialize_write(&d, buf, sizeof(buf)) == 14);
+	CHECK(d.mode == 5 && d.value == 0x0607 && decoded[0] == 9);
+	CHECK(map_deserialize_write(&d, buf, 5) == -1);
+	return 0;
+}
+`)
+}
+
+func TestGeneratedCFloat16(t *testing.T) {
+	device, err := parser.Parse(`
+    device test
+
+    register Half(1) {
+        zero float16;
+        tiny float16;
+        inf float16;
+        value float16 @le;
+        values [2]float16;
+        count uint8;
+        items [count]float16;
+    };`)
+	require.NoError(t, err)
+	h, c, err := GenerateHC(device, "test.h")
+	require.NoError(t, err)
+	require.Contains(t, h, "float values[2];")
+
+	runGeneratedCTest(t, h, c, `
+#include <math.h>
+#include <stdio.h>
+#include <string.h>
+#include "test.h"
+
+#define CHECK(cond) do { if (!(cond)) { printf("line %d: %s\n", __LINE__, #cond); return 1; } } while (0)
+
+int main(void) {
+	/* the tie 1+2^-11 rounds to the even 1 and 1e-8 is below the half of the smallest subnormal */
+	float items[2] = {1.00048828125f, 1e-8f};
+	uint8_t buf[17];
+	Half src = {0};
+	src.tiny = ldexpf(1.0f, -24);
+	src.inf = INFINITY;
+	src.value = -1.5f;
+	src.values[0] = 65504.0f;
+	src.values[1] = 0.5f;
+	src.count = 2;
+	src.items = items;
+	CHECK(half_serialize_write(&src, buf, sizeof(buf)) == 17);
+	CHECK(memcmp(buf, "\x00\x00\x00\x01\x7c\x00\x00\xbe\x7b\xff\x38\x00\x02\x3c\x00\x00\x00", 17) == 0);
+
+	float decoded[2] = {0};
+	Half dst = {0};
+	dst.items = decoded;
+	CHECK(half_deserialize_write(&dst, buf, sizeof(buf)) == 17);
+	CHECK(dst.zero == 0.0f && dst.tiny == ldexpf(1.0f, -24) && isinf(dst.inf) && dst.value == -1.5f);
+	CHECK(dst.values[0] == 65504.0f && dst.values[1] == 0.5f && decoded[0] == 1.0f && decoded[1] == 0.0f);
+
+	/* the values too large for the half precision are infinities, NaN stays NaN */
+	src.zero = 1e6f;
+	src.tiny = NAN;
+	CHECK(half_serialize_write(&src, buf, sizeof(buf)) == 17);
+	CHECK(memcmp(buf, "\x7c\x00\x7e\x00", 4) == 0);
+	CHECK(half_deserialize_write(&dst, buf, sizeof(buf)) == 17);
+	CHECK(isinf(dst.zero) && isnan(dst.tiny));
+	return 0;
+}
+`)
+}
+
+func TestGeneratedCDefaults(t *testing.T) {
+	device, err := parser.Parse(`
+    device test
+
+    enum Mode uint8 { OFF = 0, ON = 1 };
+
+    register Config(1) {
+        level int16 [-100..100] = -5;
+        size uint8;
+    };
+
+    register Control(2) {
+        mode Mode = 1;
+        flags uint8{ready: 0, busy: 1} = 0b10;
+        timeout uint32 = 0x1000;
+        plain uint8;
+        config Config;
+        configs [2]Config;
+    };
+
+    register Plain(3) {
+        value uint8;
+    };`)
+	require.NoError(t, err)
+	h, c, err := GenerateHC(device, "test.h")
+	require.NoError(t, err)
+	require.Contains(t, h, "void control_init(Control* r);")
+	require.NotContains(t, h, "plain_init")
+	require.Contains(t, c, "\tr->flags = 0x2;\n")
+	require.Contains(t, c, "\tconfig_init(&r->config);\n")
+	require.Contains(t, c, "\tfor (size_t i = 0; i < 2; i++) config_init(&r->configs[i]);\n")
+
+	runGeneratedCTest(t, h, c, `
+#include <stdio.h>
+#include "test.h"
+
+#define CHECK(cond) do { if (!(cond)) { printf("line %d: %s\n", __LINE__, #cond); return 1; } } while (0)
+
+int main(void) {
+	Control r;
+	r.plain = 7;
+	control_init(&r);
+	CHECK(r.mode == 1 && r.flags == 2 && r.timeout == 0x1000 && r.plain == 0);
+	CHECK(r.config.level == -5 && r.config.size == 0);
+	CHECK(r.configs[0].level == -5 && r.configs[1].level == -5);
+	return 0;
+}
+`)
+}
+
+func TestGeneratedCConditions(t *testing.T) {
+	device, err := parser.Parse(`
+    device test
+
+    register Status(1) {
+        flags uint8{has_extra: 0};
+        extra uint16 if flags_has_extra;
+        more uint8;
+        count uint8 if more;
+        items [count]uint8 if more;
+    };`)
+	require.NoError(t, err)
+	h, c, err := GenerateHC(device, "test.h")
+	require.NoError(t, err)
+	require.Contains(t, c, "\tif ((r->flags & Status_flags_has_extra_bm) != 0) {\n")
+
+	runGeneratedCTest(t, h, c, `
+#include <stdio.h>
+#include <string.h>
+#include "test.h"
+
+#define CHECK(cond) do { if (!(cond)) { printf("line %d: %s\n", __LINE__, #cond); return 1; } } while (0)
+
+int main(void) {
+	uint8_t buf[8];
+	uint8_t items[2] = {8, 9};
+	Status s = {0};
+	s.extra = 0x0102;
+	s.count = 2;
+	s.items = items;
+	CHECK(status_buf_size_read(&s) == 2);
+	CHECK(status_serialize_read(&s, buf, sizeof(buf)) == 2);
+	CHECK(memcmp(buf, "\x00\x00", 2) == 0);
+
+	s.flags = Status_flags_has_extra_bm;
+	s.more = 1;
+	CHECK(status_buf_size_read(&s) == 7);
+	CHECK(status_serialize_read(&s, buf, sizeof(buf)) == 7);
+	CHECK(memcmp(buf, "\x01\x01\x02\x01\x02\x08\x09", 7) == 0);
+
+	uint8_t decoded[2] = {0};
+	Status d = {0};
+	d.items = decoded;
+	CHECK(status_deserialize_read(&d, buf, 7) == 7);
+	CHECK(d.extra == 0x0102 && d.count == 2 && decoded[1] == 9);
+	memset(buf, 0, 2);
+	CHECK(status_deserialize_read(&d, buf, 2) == 2);
+	return 0;
+}
+`)
+}
+
+func TestGeneratedCRegisterVersion(t *testing.T) {
+	device, err := parser.Parse(`
+    device test
+
+    register Control(1) version 2 {
+        mode uint8;
+        crc crc16(modbus);
+    };
+
+    register Status(2): r version 0x10 {
+        value uint16;
+    };`)
+	require.NoError(t, err)
+	h, c, err := GenerateHC(device, "test.h")
+	require.NoError(t, err)
+	require.Contains(t, h, "#define Reg_Control_Version 2\n#define Reg_Status_Version 16\n")
+
+	runGeneratedCTest(t, h, c, `
+#include <stdio.h>
+#include <string.h>
+#include "test.h"
+
+#define CHECK(cond) do { if (!(cond)) { printf("line %d: %s\n", __LINE__, #cond); return 1; } } while (0)
+
+int main(void) {
+	uint8_t buf[8];
+	Control ctl = {0};
+	ctl.mode = 7;
+	CHECK(control_buf_size_write(&ctl) == 4);
+	CHECK(control_serialize_write(&ctl, buf, sizeof(buf)) == 4);
+	CHECK(buf[0] == Reg_Control_Version && buf[1] == 7);
+	CHECK(control_serialize_write(&ctl, buf, 0) == -1);
+
+	Control d = {0};
+	CHECK(control_deserialize_write(&d, buf, 4) == 4 && d.mode == 7);
+	CHECK(control_deserialize_write(&d, buf, 0) == -1);
+	buf[0] = 3;
+	CHECK(control_deserialize_write(&d, buf, 4) == -6);
+
+	/* the read-only register sends its version with the read fields only */
+	Status s = {0};
+	s.value = 0x0102;
+	CHECK(status_buf_size_write(&s) == 0);
+	CHECK(status_serialize_read(&s, buf, sizeof(buf)) == 3);
+	CHECK(memcmp(buf, "\x10\x01\x02", 3) == 0);
+	buf[0] = 0x11;
+	CHECK(status_deserialize_read(&s, buf, 3) == -6);
+	return 0;
+}
+`)
+}
+
+func TestGeneratedCDeviceConstants(t *testing.T) {
+	// the constants are named as the parameters and the locals of the generated functions
+	device, err := parser.Parse(`
+    device my-dev
+
+    const size = uint8(4);
+    const buf = uint16(0x100);
+    const r = uint8(1);
+    const offset = uint8(2);
+
+    register Control(1) {
+        mode uint8;
+    };`)
+	require.NoError(t, err)
+	h, c, err := GenerateHC(device, "test.h")
+	require.NoError(t, err)
+	require.Contains(t, h, "#define my_dev_size ((uint8_t)4)\n")
+	require.NotContains(t, h, "#define size ")
+
+	runGeneratedCTest(t, h, c, `
+#include <stdio.h>
+#include "test.h"
+
+#define CHECK(cond) do { if (!(cond)) { printf("line %d: %s\n", __LINE__, #cond); return 1; } } while (0)
+
+int main(void) {
+	uint8_t size[4];
+	Control ctl = {0};
+	ctl.mode = 7;
+	CHECK(my_dev_size == 4 && my_dev_buf == 256 && my_dev_r == 1 && my_dev_offset == 2);
+	CHECK(control_serialize_write(&ctl, size, sizeof(size)) == 1 && size[0] == 7);
+	return 0;
+}
+`)
+}
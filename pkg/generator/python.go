@@ -0,0 +1,870 @@
+package generator
+
+import (
+	"bytes"
+	"fmt"
+	"strconv"
+	"strings"
+	"text/template"
+
+	"github.com/dspasibenko/pargus/pkg/parser"
+)
+
+//
+// Python template
+//
+
+const pyTemplate = `
+# This is auto-generated file. DO NOT EDIT. Use pargus compiler to regenerate it.
+{{- range .Doc}}
+{{.}}
+{{- end}}
+
+from __future__ import annotations
+
+import dataclasses
+import struct
+from enum import IntEnum
+from typing import ClassVar, List, Tuple
+{{- if index .CRCs "ieee"}}
+import zlib
+{{- end}}
+{{- range .Constants}}
+{{range .Doc}}
+{{.}}
+{{- end}}
+{{.Name}} = {{.Value}}
+{{- end}}
+
+
+def _take(data: bytes, offset: int, n: int) -> bytes:
+    """Returns n bytes of data at offset, raises struct.error if data is too short"""
+    if len(data) - offset < n:
+        raise struct.error(f"unpack requires a buffer of {n} bytes")
+    return data[offset:offset + n]
+{{- if .Fixed}}
+
+
+def _round(v: float) -> int:
+    """Rounds v half away from zero"""
+    return int(v + 0.5) if v >= 0 else -int(-v + 0.5)
+{{- end}}
+{{- if .MultiDim}}
+
+
+def _has_shape(rows: list, dims: Tuple[int, ...]) -> bool:
+    """Returns true if every nested list of rows has the dims sizes"""
+    return all(isinstance(row, list) and len(row) == dims[0] and (len(dims) == 1 or _has_shape(row, dims[1:]))
+               for row in rows)
+
+
+def _flatten(rows: list, depth: int) -> list:
+    """Returns the elements of the lists nested depth levels deep"""
+    for _ in range(depth):
+        rows = [v for row in rows for v in row]
+    return rows
+
+
+def _reshape(items: list, dims: Tuple[int, ...]) -> list:
+    """Splits the items into the nested lists of the dims sizes"""
+    for d in reversed(dims):
+        items = [items[i:i + d] for i in range(0, len(items), d)]
+    return items
+{{- end}}
+{{- if index .CRCs "ccitt"}}
+
+
+def _crc16_ccitt(data: bytes) -> int:
+    """Returns the CRC-16/CCITT-FALSE checksum of data"""
+    crc = 0xFFFF
+    for b in data:
+        crc ^= b << 8
+        for _ in range(8):
+            crc = ((crc << 1) ^ 0x1021) & 0xFFFF if crc & 0x8000 else (crc << 1) & 0xFFFF
+    return crc
+{{- end}}
+{{- if index .CRCs "modbus"}}
+
+
+def _crc16_modbus(data: bytes) -> int:
+    """Returns the CRC-16/MODBUS checksum of data"""
+    crc = 0xFFFF
+    for b in data:
+        crc ^= b
+        for _ in range(8):
+            crc = (crc >> 1) ^ 0xA001 if crc & 1 else crc >> 1
+    return crc
+{{- end}}
+{{- range .Enums}}
+
+{{range .Doc}}
+{{.}}
+{{- end}}
+class {{.Name}}(IntEnum):
+{{- range .Members}}
+{{- range .Doc}}
+{{if .}}    {{.}}{{end}}
+{{- end}}
+    {{.Name}} = {{.Value}}
+{{- end}}
+{{- end}}
+{{- range .Registers}}
+
+{{range .Doc}}
+{{.}}
+{{- end}}
+@dataclasses.dataclass
+class {{.Name}}:
+    ID: ClassVar[int] = {{.Number}}
+{{- if .HasVersion}}
+    VERSION: ClassVar[int] = {{.Version}}
+{{- end}}
+{{- range .Constants}}
+{{- range .Doc}}
+{{if .}}    {{.}}{{end}}
+{{- end}}
+    {{.Name}}: ClassVar[int] = {{.Value}}
+{{- end}}
+{{- range .Fields}}{{if .Decl}}
+{{- range .Doc}}
+{{if .}}    {{.}}{{end}}
+{{- end}}
+    {{.Decl}}{{if .Trailing}}  {{.Trailing}}{{end}}
+{{- end}}{{end}}
+{{- range .Fields}}{{range .Properties}}
+
+{{.}}
+{{- end}}{{end}}
+
+    def check(self) -> None:
+        """Raises ValueError if an array length differs from its size, a string is too long or a field is out of its range"""
+{{- range .Fields}}{{range .Checks}}
+        {{.}}
+{{- end}}{{end}}
+
+    def pack(self) -> bytes:
+        """Encodes the {{.Dir}} fields"""
+        return self.pack_{{.Dir}}()
+
+    @classmethod
+    def unpack(cls, data: bytes) -> {{.Name}}:
+        """Decodes the {{.Dir}} fields, data must hold exactly one register"""
+        return cls.unpack_{{.Dir}}(data)
+{{- $reg := .}}
+{{- range .Dirs}}
+
+    def pack_{{.Name}}(self) -> bytes:
+        """Encodes the {{.Name}} fields"""
+        return bytes(self._pack_{{.Name}}())
+
+    @classmethod
+    def unpack_{{.Name}}(cls, data: bytes) -> {{$reg.Name}}:
+        """Decodes the {{.Name}} fields, data must hold exactly one register"""
+        r, offset = cls._unpack_{{.Name}}(data, 0)
+        if offset != len(data):
+            raise ValueError(f"{len(data) - offset} trailing bytes after the {{$reg.Name}} register")
+        return r
+
+    def _pack_{{.Name}}(self) -> bytearray:
+        self.check()
+        buf = bytearray()
+{{- range .Pack}}
+        {{.}}
+{{- end}}
+        return buf
+
+    @classmethod
+    def _unpack_{{.Name}}(cls, data: bytes, offset: int) -> Tuple[{{$reg.Name}}, int]:
+        r = cls()
+{{- if $reg.HasCRC}}
+        start = offset
+{{- end}}
+{{- range .Unpack}}
+        {{.}}
+{{- end}}
+        return r, offset
+{{- end}}
+{{- end}}
+`
+
+var pyTpl = template.Must(template.New("py").Parse(pyTemplate))
+
+//
+// Intermediate representation for template
+//
+
+type PyDevice struct {
+	Doc       []string
+	Constants []PyConstant
+	Enums     []PyEnum
+	Registers []PyRegister
+	Fixed     bool            // true if any field is a fixed-point number
+	MultiDim  bool            // true if any field is a multi-dimensional array
+	CRCs      map[string]bool // checksum algorithms the registers use
+}
+
+type PyConstant struct {
+	Doc   []string
+	Name  string
+	Value string
+}
+
+type PyEnum struct {
+	Doc     []string
+	Name    string
+	Members []PyConstant
+}
+
+type PyRegister struct {
+	Name       string
+	Number     int
+	Doc        []string
+	Dir        string // the direction pack and unpack encode, write unless the register is read-only
+	HasCRC     bool   // true if a field is a checksum of the preceding bytes
+	HasVersion bool   // true if the version byte precedes the fields
+	Version    uint8
+	Constants  []PyConstant
+	Fields     []PyField
+	Dirs       []PyDir
+}
+
+// PyDir is the code encoding and decoding the fields sent in one direction
+type PyDir struct {
+	Name   string // read or write
+	Pack   []string
+	Unpack []string
+}
+
+type PyField struct {
+	Doc         []string
+	Decl        string // the dataclass field, empty for the reserved and checksum fields
+	Trailing    string
+	IsReadable  bool
+	IsWritable  bool
+	PackRead    []string // Code for _pack_read method
+	PackWrite   []string // Code for _pack_write method
+	UnpackRead  []string // Code for _unpack_read method
+	UnpackWrite []string // Code for _unpack_write method
+	Checks      []string // Checks for variable-length arrays, strings and ranges
+	Properties  []string // Properties of the bit field members and fixed-point numbers
+}
+
+//
+// Public entry
+//
+
+// GeneratePython generates the Python module for the device. Every register is a dataclass
+// encoded by its pack and unpack methods with the struct module
+func GeneratePython(dev *parser.Device) (string, error) {
+	out := PyDevice{Doc: pyDoc(declComments(dev.Doc, dev.TrailingComment))}
+	for _, c := range dev.Constants {
+		out.Constants = append(out.Constants, PyConstant{
+			Doc:   pyDoc(declComments(c.Doc, c.TrailingComment)),
+			Name:  c.Name,
+			Value: c.ValueStr,
+		})
+	}
+	for _, e := range dev.Enums {
+		pe := PyEnum{Doc: pyDoc(declComments(e.Doc, e.TrailingComment)), Name: e.Name}
+		for _, m := range e.Members {
+			pe.Members = append(pe.Members, PyConstant{
+				Doc:   pyComments(declComments(m.Doc, m.TrailingComment)),
+				Name:  m.Name,
+				Value: m.ValueStr,
+			})
+		}
+		out.Enums = append(out.Enums, pe)
+	}
+
+	for _, reg := range dev.Registers {
+		pr := PyRegister{
+			Name:   reg.Name,
+			Number: int(reg.Number()),
+			Doc:    pyDoc(declComments(reg.Doc, reg.TrailingComment)),
+			Dir:    "write",
+		}
+		if reg.Specifier == "r" {
+			pr.Dir = "read"
+		}
+		if reg.HasVersion() {
+			pr.HasVersion, pr.Version = true, reg.Version()
+		}
+		for _, c := range reg.Body.Constants() {
+			pr.Constants = append(pr.Constants, PyConstant{
+				Doc:   pyComments(declComments(c.Doc, c.TrailingComment)),
+				Name:  c.Name,
+				Value: c.ValueStr,
+			})
+		}
+
+		for i, f := range reg.Body.Fields() {
+			pf := PyField{
+				Doc:        pyComments(flattenComments(f.Doc)),
+				Trailing:   pyComment(safeString(f.TrailingComment)),
+				IsReadable: f.Specifier == "r" || f.Specifier == "",
+				IsWritable: f.Specifier == "w" || f.Specifier == "",
+			}
+			name := pyName(f.Name)
+			field := "self." + name
+			order := ">"
+			if f.IsLittleEndian() {
+				order = "<"
+			}
+
+			// The field placed at the offset is preceded by the zero bytes filling the gap
+			for _, dir := range pf.dirs() {
+				if pad := reg.Padding(f, dir == "read"); pad > 0 {
+					pf.addDir(dir, []string{fmt.Sprintf("buf += bytes(%d)", pad)}, []string{
+						fmt.Sprintf("_take(data, offset, %d)", pad),
+						fmt.Sprintf("offset += %d", pad),
+					})
+				}
+			}
+
+			switch {
+			case f.Reserved:
+				size := reservedSize(f)
+				pf.add([]string{fmt.Sprintf("buf += bytes(%d)", size)}, []string{
+					fmt.Sprintf("_take(data, offset, %d)", size),
+					fmt.Sprintf("offset += %d", size),
+				})
+
+			case f.Type.CRC != nil:
+				// the checksum is calculated over the register bytes preceding it
+				base := f.Type.CRC.BaseType()
+				format := strconv.Quote(order + pyFormat(base))
+				crcFn := out.pyCRCFunc(f.Type.CRC)
+				pr.HasCRC = true
+				pf.add([]string{fmt.Sprintf("buf += struct.pack(%s, %s(buf))", format, crcFn)}, []string{
+					fmt.Sprintf("crc = struct.unpack_from(%s, data, offset)[0]", format),
+					fmt.Sprintf("if crc != %s(data[start:offset]):", crcFn),
+					fmt.Sprintf("    raise ValueError(f\"%s checksum 0x{crc:X} mismatch\")", f.Name),
+					fmt.Sprintf("offset += %d", wireTypeSize(base)),
+				})
+
+			case f.IsMagic():
+				// the magic value is always the same, so it has no value to keep
+				typ := f.Type.Simple.Name
+				magic := magicLiteral(f)
+				var pack, get string
+				if is24BitType(typ) {
+					byteOrder := pyByteOrder(order)
+					pack = fmt.Sprintf("buf += (%s & 0xFFFFFF).to_bytes(3, %s)", magic, byteOrder)
+					get = fmt.Sprintf("int.from_bytes(_take(data, offset, 3), %s, signed=%s)", byteOrder, pyBool(typ == "int24"))
+				} else {
+					format := strconv.Quote(order + pyFormat(typ))
+					pack = fmt.Sprintf("buf += struct.pack(%s, %s)", format, magic)
+					get = fmt.Sprintf("struct.unpack_from(%s, data, offset)[0]", format)
+				}
+				pf.add([]string{pack}, []string{
+					"magic = " + get,
+					fmt.Sprintf("if magic != %s:", magic),
+					fmt.Sprintf("    raise ValueError(f\"%s mismatch: received 0x{magic:X}, expected %s\")", f.Name, magic),
+					fmt.Sprintf("offset += %d", wireTypeSize(typ)),
+				})
+
+			case f.Type.Simple != nil && f.Type.Simple.IsRegisterRef():
+				ref := f.Type.Simple.Name
+				pf.Decl = fmt.Sprintf("%s: %s = dataclasses.field(default_factory=%s)", name, ref, ref)
+				for _, dir := range pf.dirs() {
+					pf.addDir(dir, []string{fmt.Sprintf("buf += %s._pack_%s()", field, dir)},
+						[]string{fmt.Sprintf("r.%s, offset = %s._unpack_%s(data, offset)", name, ref, dir)})
+				}
+
+			case f.Type.Bitfield != nil:
+				bf := f.Type.Bitfield
+				pf.Decl = fmt.Sprintf("%s: int = %s", name, pyDefault(f, "0"))
+				for _, bm := range bf.Bits {
+					if bm.Reserved {
+						// reserved members only document the unused bits
+						continue
+					}
+					pf.Properties = append(pf.Properties, pyBitProperty(name, &bm))
+				}
+				pf.addScalar(bf.Base, field, "r."+name, order)
+
+			case f.Type.Array != nil && f.Type.Array.Type.IsRegisterRef():
+				elem := f.Type.Array.Type.Name
+				var count string
+				if f.Type.Array.Size.Constant != nil {
+					count = *f.Type.Array.Size.Constant
+					pf.Decl = fmt.Sprintf("%s: List[%s] = dataclasses.field(default_factory=lambda: [%s() for _ in range(%s)])",
+						name, elem, elem, count)
+				} else {
+					count = pySizeFieldValue(reg, f, i, "self")
+					pf.Decl = fmt.Sprintf("%s: List[%s] = dataclasses.field(default_factory=list)", name, elem)
+				}
+				pf.Checks = append(pf.Checks, pyLenCheck(f.Name, field, count)...)
+
+				// Every element is encoded by its own register methods
+				for _, dir := range pf.dirs() {
+					pf.addDir(dir, []string{
+						fmt.Sprintf("for v in %s:", field),
+						fmt.Sprintf("    buf += v._pack_%s()", dir),
+					}, []string{
+						fmt.Sprintf("r.%s = []", name),
+						fmt.Sprintf("for _ in range(%s):", pyUnpackCount(count)),
+						fmt.Sprintf("    v, offset = %s._unpack_%s(data, offset)", elem, dir),
+						fmt.Sprintf("    r.%s.append(v)", name),
+					})
+				}
+
+			case f.Type.Array != nil:
+				at := f.Type.Array
+				typ := at.Type.Name
+				inner := at.InnerCount()
+				listType := "List[" + pyType(typ) + "]"
+				for range at.Dims {
+					listType = "List[" + listType + "]"
+				}
+				var count string
+				if at.Size.Constant != nil {
+					count = *at.Size.Constant
+					pf.Decl = fmt.Sprintf("%s: %s = dataclasses.field(default_factory=lambda: %s)",
+						name, listType, pyNestedList(pyZero(typ), append([]string{count}, at.Dims...)))
+				} else {
+					count = pySizeFieldValue(reg, f, i, "self")
+					pf.Decl = fmt.Sprintf("%s: %s = dataclasses.field(default_factory=list)", name, listType)
+				}
+				pf.Checks = append(pf.Checks, pyLenCheck(f.Name, field, count)...)
+
+				// the multi-dimensional arrays are sent flattened
+				values := field
+				dims := "(" + strings.Join(at.Dims, ", ") + ",)"
+				if at.IsMultiDim() {
+					out.MultiDim = true
+					pf.Checks = append(pf.Checks,
+						fmt.Sprintf("if not _has_shape(%s, %s):", field, dims),
+						fmt.Sprintf("    raise ValueError(\"%s rows must have the [%s] shape\")", f.Name, strings.Join(at.Dims, "][")))
+					values = fmt.Sprintf("_flatten(%s, %d)", field, len(at.Dims))
+				}
+				elems := pyUnpackCount(count)
+				if inner > 1 {
+					elems = fmt.Sprintf("%s * %d", elems, inner)
+				}
+
+				var pack, unpack []string
+				if is24BitType(typ) {
+					byteOrder := pyByteOrder(order)
+					pack = []string{
+						fmt.Sprintf("for v in %s:", values),
+						fmt.Sprintf("    buf += (v & 0xFFFFFF).to_bytes(3, %s)", byteOrder),
+					}
+					unpack = []string{
+						"items = []",
+						fmt.Sprintf("for _ in range(%s):", elems),
+						fmt.Sprintf("    items.append(int.from_bytes(_take(data, offset, 3), %s, signed=%s))",
+							byteOrder, pyBool(typ == "int24")),
+						"    offset += 3",
+					}
+				} else {
+					format := fmt.Sprintf("f\"%s{len(items)}%s\"", order, pyFormat(typ))
+					unpackFormat := fmt.Sprintf("f\"%s{%s}%s\"", order, elems, pyFormat(typ))
+					if n, ok := pyConstCount(count); ok {
+						format = strconv.Quote(fmt.Sprintf("%s%d%s", order, n*inner, pyFormat(typ)))
+						unpackFormat = format
+					}
+					pack = []string{
+						fmt.Sprintf("items = %s", values),
+						fmt.Sprintf("buf += struct.pack(%s, *items)", format),
+					}
+					size := "len(items)"
+					if n, ok := pyConstCount(count); ok {
+						size = strconv.Itoa(n * inner * wireTypeSize(typ))
+					} else if wireTypeSize(typ) > 1 {
+						size = fmt.Sprintf("%d * len(items)", wireTypeSize(typ))
+					}
+					unpack = []string{
+						fmt.Sprintf("items = list(struct.unpack_from(%s, data, offset))", unpackFormat),
+						"offset += " + size,
+					}
+				}
+				if at.IsMultiDim() {
+					unpack = append(unpack, fmt.Sprintf("r.%s = _reshape(items, %s)", name, dims))
+				} else {
+					unpack = append(unpack, fmt.Sprintf("r.%s = items", name))
+				}
+				pf.add(pack, unpack)
+
+			case f.Type.String != nil:
+				prefix := f.Type.String.PrefixType()
+				format := strconv.Quote(order + pyFormat(prefix))
+				maxLen := f.Type.String.MaxLen()
+				pf.Decl = fmt.Sprintf("%s: str = \"\"", name)
+				pf.Checks = append(pf.Checks,
+					fmt.Sprintf("if len(%s.encode()) > %d:", field, maxLen),
+					fmt.Sprintf("    raise ValueError(f\"%s is {len(%s.encode())} bytes long, but the maximum is %d\")",
+						f.Name, field, maxLen))
+				unpack := []string{
+					fmt.Sprintf("n = struct.unpack_from(%s, data, offset)[0]", format),
+					fmt.Sprintf("offset += %d", wireTypeSize(prefix)),
+				}
+				if f.Type.String.MaxLenStr != nil {
+					// without the explicit maximum the length is limited by the prefix type
+					unpack = append(unpack,
+						fmt.Sprintf("if n > %d:", maxLen),
+						fmt.Sprintf("    raise ValueError(f\"%s is {n} bytes long, but the maximum is %d\")", f.Name, maxLen))
+				}
+				unpack = append(unpack,
+					fmt.Sprintf("r.%s = _take(data, offset, n).decode()", name),
+					"offset += n")
+				pf.add([]string{
+					fmt.Sprintf("encoded = %s.encode()", field),
+					fmt.Sprintf("buf += struct.pack(%s, len(encoded)) + encoded", format),
+				}, unpack)
+
+			case f.Type.Simple != nil && f.Type.Simple.IsEnum():
+				// the enum is sent over the wire as its base integer type, so unknown values are kept
+				pf.Decl = fmt.Sprintf("%s: int = %s", name, pyDefault(f, "0"))
+				pf.addScalar(f.Type.Simple.Enum.Base, field, "r."+name, order)
+
+			case f.Type.Simple != nil, f.Type.Fixed != nil:
+				typ := scalarTypeName(f)
+				pf.Decl = fmt.Sprintf("%s: %s = %s", name, pyType(typ), pyDefault(f, pyZero(typ)))
+				if f.Type.Fixed != nil {
+					// the field keeps the raw integer, the property converts it
+					out.Fixed = true
+					scale := fixedScale(f.Type.Fixed)
+					pf.Properties = append(pf.Properties, strings.Join([]string{
+						"    @property",
+						fmt.Sprintf("    def %s_value(self) -> float:", f.Name),
+						fmt.Sprintf("        return %s / %s", field, scale),
+						"",
+						fmt.Sprintf("    @%s_value.setter", f.Name),
+						fmt.Sprintf("    def %s_value(self, v: float) -> None:", f.Name),
+						fmt.Sprintf("        %s = _round(v * %s)", field, scale),
+					}, "\n"))
+				}
+				if conds := rangeConditions(f, field); len(conds) > 0 {
+					pf.Checks = append(pf.Checks,
+						fmt.Sprintf("if %s:", strings.Join(conds, " or ")),
+						fmt.Sprintf("    raise ValueError(f\"%s {%s} is out of range [%d..%d]\")", f.Name, field, f.Min(), f.Max()))
+				}
+				pf.addScalar(typ, field, "r."+name, order)
+			}
+
+			// The conditional field is sent only if its condition is set, the absent field keeps its default
+			if f.HasCondition() {
+				pack, unpack := pyConditionExpr(reg, f, i, "self"), pyConditionExpr(reg, f, i, "r")
+				pf.PackRead, pf.PackWrite = pyIf(pack, pf.PackRead), pyIf(pack, pf.PackWrite)
+				pf.UnpackRead, pf.UnpackWrite = pyIf(unpack, pf.UnpackRead), pyIf(unpack, pf.UnpackWrite)
+				pf.Checks = pyIf(pack, pf.Checks)
+			}
+
+			pr.Fields = append(pr.Fields, pf)
+		}
+
+		for _, dir := range []string{"read", "write"} {
+			pd := PyDir{Name: dir}
+			if reg.HasVersion() && (reg.Specifier == "" || reg.Specifier == dir[:1]) {
+				// the version byte precedes the fields in the directions the register is sent in
+				pd.Pack = append(pd.Pack, "buf.append(self.VERSION)")
+				pd.Unpack = append(pd.Unpack,
+					"version = _take(data, offset, 1)[0]",
+					"if version != cls.VERSION:",
+					fmt.Sprintf("    raise ValueError(f\"%s version mismatch: received {version}, expected {cls.VERSION}\")", reg.Name),
+					"offset += 1")
+			}
+			for _, pf := range pr.Fields {
+				if dir == "read" {
+					pd.Pack = append(pd.Pack, pf.PackRead...)
+					pd.Unpack = append(pd.Unpack, pf.UnpackRead...)
+				} else {
+					pd.Pack = append(pd.Pack, pf.PackWrite...)
+					pd.Unpack = append(pd.Unpack, pf.UnpackWrite...)
+				}
+			}
+			pr.Dirs = append(pr.Dirs, pd)
+		}
+		out.Registers = append(out.Registers, pr)
+	}
+
+	var buf bytes.Buffer
+	if err := pyTpl.Execute(&buf, out); err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(buf.String()) + "\n", nil
+}
+
+//
+// Helpers
+//
+
+// dirs returns the directions, read and write, the field is sent in
+func (f *PyField) dirs() []string {
+	var res []string
+	if f.IsReadable {
+		res = append(res, "read")
+	}
+	if f.IsWritable {
+		res = append(res, "write")
+	}
+	return res
+}
+
+// add adds the code of the field to the directions the field is sent in
+func (f *PyField) add(pack, unpack []string) {
+	for _, dir := range f.dirs() {
+		f.addDir(dir, pack, unpack)
+	}
+}
+
+// addDir adds the code of the field to the dir direction
+func (f *PyField) addDir(dir string, pack, unpack []string) {
+	if dir == "read" {
+		f.PackRead = append(f.PackRead, pack...)
+		f.UnpackRead = append(f.UnpackRead, unpack...)
+	} else {
+		f.PackWrite = append(f.PackWrite, pack...)
+		f.UnpackWrite = append(f.UnpackWrite, unpack...)
+	}
+}
+
+// addScalar adds the code of the field holding a value of the built-in typ, value is
+// the packed expression and target is the unpacked one
+func (f *PyField) addScalar(typ, value, target, order string) {
+	if is24BitType(typ) {
+		byteOrder := pyByteOrder(order)
+		f.add([]string{fmt.Sprintf("buf += (%s & 0xFFFFFF).to_bytes(3, %s)", value, byteOrder)}, []string{
+			fmt.Sprintf("%s = int.from_bytes(_take(data, offset, 3), %s, signed=%s)", target, byteOrder, pyBool(typ == "int24")),
+			"offset += 3",
+		})
+		return
+	}
+	format := strconv.Quote(order + pyFormat(typ))
+	f.add([]string{fmt.Sprintf("buf += struct.pack(%s, %s)", format, value)}, []string{
+		fmt.Sprintf("%s = struct.unpack_from(%s, data, offset)[0]", target, format),
+		fmt.Sprintf("offset += %d", wireTypeSize(typ)),
+	})
+}
+
+// pyCRCFunc returns the function calculating the checksum and registers its runtime helper
+func (d *PyDevice) pyCRCFunc(ct *parser.CRCType) string {
+	alg := ct.AlgorithmName()
+	if d.CRCs == nil {
+		d.CRCs = make(map[string]bool)
+	}
+	d.CRCs[alg] = true
+	if alg == "ieee" {
+		return "zlib.crc32"
+	}
+	return "_" + ct.Kind + "_" + alg
+}
+
+// pyConditionExpr returns the expression which is true if the conditional field of the obj is sent,
+// the condition is either the single-bit member or the uint8 field, which is set if it is not zero
+func pyConditionExpr(reg *parser.Register, f *parser.Field, idx int, obj string) string {
+	fld, bm := reg.FindFieldByName(*f.Condition, idx)
+	if bm != nil {
+		return fmt.Sprintf("%s.%s & 0x%X", obj, pyName(fld.Name), bitMask(bm.StartBit(), bm.EndBit()))
+	}
+	return fmt.Sprintf("%s.%s", obj, pyName(fld.Name))
+}
+
+// pyIf returns the code executed only if the condition is true, there is no code if the code is empty
+func pyIf(cond string, code []string) []string {
+	if len(code) == 0 {
+		return nil
+	}
+	res := []string{fmt.Sprintf("if %s:", cond)}
+	for _, line := range code {
+		res = append(res, "    "+line)
+	}
+	return res
+}
+
+// pyBitProperty returns the property reading and writing the bit field member bm of the field
+func pyBitProperty(field string, bm *parser.BitMember) string {
+	start, end := bm.StartBit(), bm.EndBit()
+	mask := bitMask(start, end)
+	prop := field + "_" + bm.Name
+	self := "self." + field
+	if start == end {
+		return strings.Join([]string{
+			"    @property",
+			fmt.Sprintf("    def %s(self) -> bool:", prop),
+			fmt.Sprintf("        return %s & 0x%X != 0", self, mask),
+			"",
+			fmt.Sprintf("    @%s.setter", prop),
+			fmt.Sprintf("    def %s(self, v: bool) -> None:", prop),
+			fmt.Sprintf("        %s = %s | 0x%X if v else %s & ~0x%X", self, self, mask, self, mask),
+		}, "\n")
+	}
+	width := bitMask(0, end-start)
+	lines := []string{
+		"    @property",
+		fmt.Sprintf("    def %s(self) -> int:", prop),
+	}
+	if bm.Signed {
+		// the member holds a two's complement value
+		lines = append(lines,
+			fmt.Sprintf("        v = (%s >> %d) & 0x%X", self, start, width),
+			fmt.Sprintf("        return v - 0x%X if v & 0x%X else v", width+1, (width+1)>>1))
+	} else {
+		lines = append(lines, fmt.Sprintf("        return (%s >> %d) & 0x%X", self, start, width))
+	}
+	lines = append(lines,
+		"",
+		fmt.Sprintf("    @%s.setter", prop),
+		fmt.Sprintf("    def %s(self, v: int) -> None:", prop),
+		fmt.Sprintf("        %s = (%s & ~0x%X) | ((v << %d) & 0x%X)", self, self, mask, start, mask))
+	return strings.Join(lines, "\n")
+}
+
+// pySizeFieldValue returns the expression of the variable-length array f size field value,
+// the bit field members are read by their properties
+func pySizeFieldValue(reg *parser.Register, f *parser.Field, idx int, obj string) string {
+	field, bm := reg.FindFieldByName(*f.Type.Array.Size.Variable, idx)
+	if bm != nil {
+		return fmt.Sprintf("%s.%s_%s", obj, pyName(field.Name), bm.Name)
+	}
+	return obj + "." + pyName(field.Name)
+}
+
+// pyLenCheck returns the statements raising ValueError if the length of the array differs from count
+func pyLenCheck(name, field, count string) []string {
+	expected := "{" + count + "}"
+	if n, ok := pyConstCount(count); ok {
+		expected = strconv.Itoa(n)
+	}
+	return []string{
+		fmt.Sprintf("if len(%s) != %s:", field, count),
+		fmt.Sprintf("    raise ValueError(f\"%s has {len(%s)} elements, but %s are expected\")", name, field, expected),
+	}
+}
+
+// pyConstCount returns the value of the constant array size written as any integer literal,
+// ok is false for the size field references
+func pyConstCount(count string) (int, bool) {
+	n, err := strconv.ParseInt(count, 0, 64)
+	return int(n), err == nil
+}
+
+// pyUnpackCount returns the array size expression of the register being unpacked
+func pyUnpackCount(count string) string {
+	return strings.Replace(count, "self.", "r.", 1)
+}
+
+// pyNestedList returns the expression of the nested lists of zero values with the dims sizes
+func pyNestedList(zero string, dims []string) string {
+	res := fmt.Sprintf("[%s] * %s", zero, dims[len(dims)-1])
+	for i := len(dims) - 2; i >= 0; i-- {
+		res = fmt.Sprintf("[%s for _ in range(%s)]", res, dims[i])
+	}
+	return res
+}
+
+// pyFormat returns the struct module format character of the built-in type
+func pyFormat(typ string) string {
+	switch typ {
+	case "int8":
+		return "b"
+	case "uint8":
+		return "B"
+	case "int16":
+		return "h"
+	case "uint16":
+		return "H"
+	case "int32":
+		return "i"
+	case "uint32":
+		return "I"
+	case "int64":
+		return "q"
+	case "uint64":
+		return "Q"
+	case "float16":
+		return "e"
+	case "float32":
+		return "f"
+	case "float64":
+		return "d"
+	default:
+		return ""
+	}
+}
+
+// pyType returns the Python type of the built-in type
+func pyType(typ string) string {
+	if strings.HasPrefix(typ, "float") {
+		return "float"
+	}
+	return "int"
+}
+
+// pyDefault returns the default value literal of the field or zero if the field has no default value
+func pyDefault(f *parser.Field, zero string) string {
+	if f.HasDefault() {
+		return defaultLiteral(f)
+	}
+	return zero
+}
+
+// pyZero returns the zero value literal of the built-in type
+func pyZero(typ string) string {
+	if pyType(typ) == "float" {
+		return "0.0"
+	}
+	return "0"
+}
+
+// pyByteOrder returns the int.to_bytes byte order of the struct module order character
+func pyByteOrder(order string) string {
+	if order == "<" {
+		return `"little"`
+	}
+	return `"big"`
+}
+
+func pyBool(v bool) string {
+	if v {
+		return "True"
+	}
+	return "False"
+}
+
+// pyKeywords are the Python keywords which cannot be the attribute names
+var pyKeywords = map[string]bool{
+	"False": true, "None": true, "True": true, "and": true, "as": true, "assert": true, "async": true,
+	"await": true, "break": true, "class": true, "continue": true, "def": true, "del": true, "elif": true,
+	"else": true, "except": true, "finally": true, "for": true, "from": true, "global": true, "if": true,
+	"import": true, "in": true, "is": true, "lambda": true, "nonlocal": true, "not": true, "or": true,
+	"pass": true, "raise": true, "return": true, "try": true, "while": true, "with": true, "yield": true,
+}
+
+// pyName returns the attribute name of the field, the Python keywords get the underscore suffix
+func pyName(name string) string {
+	if pyKeywords[name] {
+		return name + "_"
+	}
+	return name
+}
+
+// pyComment converts the // comment to the # one
+func pyComment(comment string) string {
+	if comment == "" {
+		return ""
+	}
+	if text, ok := strings.CutPrefix(comment, "//"); ok {
+		return "#" + text
+	}
+	return "# " + comment
+}
+
+// pyComments converts the // comment lines to the # ones
+func pyComments(comments []string) []string {
+	res := make([]string, 0, len(comments))
+	for _, c := range comments {
+		res = append(res, pyComment(c))
+	}
+	return res
+}
+
+// pyDoc converts the comment lines preceding the top level definition, the leading empty lines
+// are dropped since the definitions are separated by two empty lines anyway
+func pyDoc(comments []string) []string {
+	for len(comments) > 0 && comments[0] == "" {
+		comments = comments[1:]
+	}
+	return pyComments(comments)
+}
@@ -0,0 +1,369 @@
+package generator
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+
+	"github.com/dspasibenko/pargus/pkg/parser"
+	"github.com/stretchr/testify/require"
+)
+
+// runGeneratedPythonTest puts the generated module and the test script into a temporary
+// directory and runs the script there by python3
+func runGeneratedPythonTest(t *testing.T, code, script string) {
+	t.Helper()
+	python, err := exec.LookPath("python3")
+	if err != nil {
+		t.Skip("python3 is not available")
+	}
+
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "registers.py"), []byte(code), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "main.py"), []byte(script), 0644))
+
+	cmd := exec.Command(python, "-B", "main.py")
+	cmd.Dir = dir
+	out, err := cmd.CombinedOutput()
+	require.NoError(t, err, "generated code test failed:\n%s\n%s", out, code)
+}
+
+func TestGeneratePythonGolden(t *testing.T) {
+	input, err := os.ReadFile("testdata/example.pa")
+	require.NoError(t, err)
+	device, err := parser.Parse(string(input))
+	require.NoError(t, err)
+
+	code, err := GeneratePython(device)
+	require.NoError(t, err)
+	golden, err := os.ReadFile("testdata/example.py")
+	require.NoError(t, err)
+	require.Equal(t, string(golden), code)
+}
+
+func TestGeneratedPythonRoundTrip(t *testing.T) {
+	input, err := os.ReadFile("testdata/example.pa")
+	require.NoError(t, err)
+	device, err := parser.Parse(string(input))
+	require.NoError(t, err)
+	code, err := GeneratePython(device)
+	require.NoError(t, err)
+
+	runGeneratedPythonTest(t, code, `
+import struct
+
+from registers import *
+
+cfg = Config(mode=Mode.STANDBY, level=42, name="abc")
+assert cfg.pack() == b"\x02\x2a\x03abc"
+assert Config.unpack(cfg.pack()) == cfg
+cfg.level = Config.maxLevel + 1
+try:
+    cfg.pack()
+    raise AssertionError("level out of range is packed")
+except ValueError:
+    pass
+try:
+    Config.unpack(b"\x02\x2a\x03ab")
+    raise AssertionError("short data is unpacked")
+except struct.error:
+    pass
+try:
+    Config.unpack(b"\x02\x2a\x00\x00")
+    raise AssertionError("trailing bytes are unpacked")
+except ValueError:
+    pass
+
+st = Status(counter=-5)
+st.flags_ready = True
+st.flags_error = 5
+st.flags_count = 2
+st.temp_value = 1.5
+assert st.temp == 24
+try:
+    st.pack()
+    raise AssertionError("samples of the wrong length are packed")
+except ValueError:
+    pass
+st.samples = [0x0102, -2]
+data = st.pack()
+assert data == b"\xff\xff\xff\xfb\x2b\x00\x18\x02\x01\xfe\xff", data
+st2 = Status.unpack(data)
+assert st2 == st
+assert st2.flags_ready and st2.flags_error == 5 and st2.temp_value == 1.5
+assert Status().pack_write() == b""
+
+df = DataFrame()
+df.points[0] = Point(x=-2, y=0.5)
+df.points[1].x = 0x123456
+df.matrix[1][2] = 7
+data = df.pack()
+assert len(data) == 22 and data[:10] == b"\xff\xff\xfe\x3f\x00\x00\x00\x12\x34\x56" and data[19] == 7
+assert DataFrame.unpack(data) == df
+try:
+    DataFrame.unpack(b"\x00" + data[1:])
+    raise AssertionError("corrupted data is unpacked")
+except ValueError:
+    pass
+df.matrix[0] = [1, 2]
+try:
+    df.pack()
+    raise AssertionError("ragged matrix is packed")
+except ValueError:
+    pass
+`)
+}
+
+func TestGeneratedPythonArrays(t *testing.T) {
+	device, err := parser.Parse(`
+    device test @le
+
+    register Empty(1) {
+        reserved [2]uint8;
+    };
+
+    register Rows(2) {
+        count uint8;
+        rows [count][2]uint16;
+        values [2]float64 @be;
+        ids [count]int24;
+        flags uint16{lo: signed 0-3, hi: 4-7};
+        crc crc32;
+    };
+
+    register Keys(3): w {
+        sync = 0xAA55 int16 @be;
+        class uint8;
+        crc crc16(modbus);
+    };
+
+    register Hex(4) {
+        a [0x4]uint8;
+        b [0x2][2]uint16;
+    };`)
+	require.NoError(t, err)
+	code, err := GeneratePython(device)
+	require.NoError(t, err)
+	require.Contains(t, code, "    class_: int = 0")
+	require.Contains(t, code, "import zlib")
+	require.NotContains(t, code, "def _round")
+
+	runGeneratedPythonTest(t, code, `
+import zlib
+
+from registers import *
+
+assert Empty().pack() == b"\x00\x00"
+assert Empty.unpack(b"\x01\x02") == Empty()
+
+r = Rows(count=2, rows=[[1, 2], [3, 0x0405]], values=[0.0, -1.25], ids=[-1, 0x10203])
+r.flags_lo = -3
+r.flags_hi = 9
+assert r.flags_lo == -3 and r.flags_hi == 9 and r.flags == 0x9D
+data = r.pack()
+assert len(data) == 37, len(data)
+assert data[:9] == b"\x02\x01\x00\x02\x00\x03\x00\x05\x04"
+assert data[17] == 0xbf and data[18] == 0xf4
+assert data[25:31] == b"\xff\xff\xff\x03\x02\x01"
+assert int.from_bytes(data[33:], "little") == zlib.crc32(data[:33])
+assert Rows.unpack(data) == r
+
+k = Keys(class_=7)
+assert k.pack()[:3] == b"\xaa\x55\x07"
+assert Keys.unpack(k.pack()) == k
+try:
+    Keys.unpack(b"\x00" + k.pack()[1:])
+    raise AssertionError("the wrong magic is unpacked")
+except ValueError as e:
+    assert "sync mismatch" in str(e)
+assert Keys.unpack_read(b"") == Keys()
+
+h = Hex(a=[1, 2, 3, 4], b=[[5, 6], [7, 8]])
+assert h.pack() == b"\x01\x02\x03\x04\x05\x00\x06\x00\x07\x00\x08\x00"
+assert Hex.unpack(h.pack()) == h
+try:
+    Hex(a=[1]).pack()
+    raise AssertionError("the short array is packed")
+except ValueError as e:
+    assert "a has 1 elements, but 4 are expected" in str(e), e
+`)
+}
+
+func TestGeneratedPythonFieldOffset(t *testing.T) {
+	device, err := parser.Parse(`
+    device test
+
+    register Map(1) {
+        id uint8;
+        status:r uint16 @offset 4;
+        mode:w uint8 @offset 8;
+        value uint16 @offset 10 @le;
+        count uint8;
+        data [count]uint8;
+    };`)
+	require.NoError(t, err)
+	code, err := GeneratePython(device)
+	require.NoError(t, err)
+
+	runGeneratedPythonTest(t, code, `
+import struct
+
+from registers import *
+
+m = Map(id=1, status=0x0203, mode=5, value=0x0607, count=1, data=[9])
+assert m.pack_read() == b"\x01\x00\x00\x00\x02\x03\x00\x00\x00\x00\x07\x06\x01\x09"
+assert m.pack() == b"\x01\x00\x00\x00\x00\x00\x00\x00\x05\x00\x07\x06\x01\x09"
+d = Map.unpack(b"\x01\xff\xff\xff\xff\xff\xff\xff\x05\xff\x07\x06\x01\x09")
+assert d == Map(id=1, mode=5, value=0x0607, count=1, data=[9]), d
+try:
+    Map.unpack(m.pack()[:5])
+    raise AssertionError("the truncated gap is unpacked")
+except struct.error:
+    pass
+`)
+}
+
+func TestGeneratedPythonFloat16(t *testing.T) {
+	device, err := parser.Parse(`
+    device test
+
+    register Half(1) {
+        zero float16;
+        tiny float16;
+        inf float16;
+        value float16 @le;
+        values [2]float16;
+        count uint8;
+        items [count]float16;
+    };`)
+	require.NoError(t, err)
+	code, err := GeneratePython(device)
+	require.NoError(t, err)
+
+	runGeneratedPythonTest(t, code, `
+import math
+
+from registers import *
+
+# the tie 1+2^-11 rounds to the even 1 and 1e-8 is below the half of the smallest subnormal
+h = Half(tiny=2**-24, inf=math.inf, value=-1.5, values=[65504.0, 0.5], count=2, items=[1.00048828125, 1e-8])
+data = h.pack()
+assert data == b"\x00\x00\x00\x01\x7c\x00\x00\xbe\x7b\xff\x38\x00\x02\x3c\x00\x00\x00", data
+d = Half.unpack(data)
+assert d == Half(tiny=2**-24, inf=math.inf, value=-1.5, values=[65504.0, 0.5], count=2, items=[1.0, 0.0]), d
+assert math.isnan(Half.unpack(b"\x7e\x00" + data[2:]).zero)
+`)
+}
+
+func TestGeneratedPythonDefaults(t *testing.T) {
+	device, err := parser.Parse(`
+    device test
+
+    enum Mode uint8 { OFF = 0, ON = 1 };
+
+    register Config(1) {
+        level int16 [-100..100] = -5;
+        size uint8;
+    };
+
+    register Control(2) {
+        mode Mode = 1;
+        flags uint8{ready: 0, busy: 1} = 0b10;
+        big uint64 = 0xFFFFFFFFFFFFFFFF;
+        plain uint8;
+        config Config;
+        configs [2]Config;
+    };`)
+	require.NoError(t, err)
+	code, err := GeneratePython(device)
+	require.NoError(t, err)
+	require.Contains(t, code, "    big: int = 18446744073709551615\n")
+
+	runGeneratedPythonTest(t, code, `
+from registers import *
+
+c = Control()
+assert c.mode == 1 and c.flags == 2 and c.big == 2**64 - 1 and c.plain == 0, c
+assert c.config.level == -5 and [x.level for x in c.configs] == [-5, -5], c
+assert Control.unpack(bytes(20)) == Control(mode=0, flags=0, big=0, config=Config(level=0), configs=[Config(level=0), Config(level=0)])
+`)
+}
+
+func TestGeneratedPythonConditions(t *testing.T) {
+	device, err := parser.Parse(`
+    device test
+
+    register Status(1) {
+        flags uint8{has_extra: 0};
+        extra uint16 [1..1000] if flags_has_extra;
+        more uint8;
+        count uint8 if more;
+        items [count]uint8 if more;
+    };`)
+	require.NoError(t, err)
+	code, err := GeneratePython(device)
+	require.NoError(t, err)
+
+	runGeneratedPythonTest(t, code, `
+from registers import *
+
+s = Status(extra=5000, more=0, count=2, items=[8])
+assert s.pack() == b"\x00\x00"
+assert Status.unpack(b"\x00\x00") == Status()
+
+s = Status(flags=1, extra=0x0102, more=1, count=2, items=[8, 9])
+assert s.pack() == b"\x01\x01\x02\x01\x02\x08\x09"
+assert Status.unpack(s.pack()) == s
+s.extra = 5000
+try:
+    s.pack()
+    raise AssertionError("the out of range present field is packed")
+except ValueError:
+    pass
+`)
+}
+
+func TestGeneratedPythonRegisterVersion(t *testing.T) {
+	device, err := parser.Parse(`
+    device test
+
+    register Control(1) version 2 {
+        mode uint8;
+        crc crc16;
+    };
+    register Status(2):r version 7 {
+        value uint16;
+    };`)
+	require.NoError(t, err)
+	code, err := GeneratePython(device)
+	require.NoError(t, err)
+
+	runGeneratedPythonTest(t, code, `
+from registers import *
+from registers import _crc16_ccitt
+
+assert Control.VERSION == 2 and Status.VERSION == 7
+c = Control(mode=5)
+data = c.pack()
+assert data[:2] == b"\x02\x05" and len(data) == 4
+assert int.from_bytes(data[2:], "big") == _crc16_ccitt(data[:2])
+assert Control.unpack(data) == c
+assert Control.unpack_read(c.pack_read()) == c
+try:
+    Control.unpack(b"\x03\x05" + _crc16_ccitt(b"\x03\x05").to_bytes(2, "big"))
+    raise AssertionError("the other version is unpacked")
+except ValueError as e:
+    assert "version mismatch" in str(e)
+
+s = Status(value=0x0102)
+assert s.pack() == b"\x07\x01\x02"
+assert Status.unpack(b"\x07\x01\x02") == s
+assert s.pack_write() == b""
+try:
+    Status.unpack(b"\x06\x01\x02")
+    raise AssertionError("the other version is unpacked")
+except ValueError:
+    pass
+`)
+}
@@ -0,0 +1,1083 @@
+package generator
+
+import (
+	"bytes"
+	"fmt"
+	"slices"
+	"strings"
+	"text/template"
+
+	"github.com/dspasibenko/pargus/pkg/parser"
+)
+
+//
+// Rust template
+//
+
+const rustTemplate = `
+// This is auto-generated file. DO NOT EDIT. Use pargus compiler to regenerate it.
+{{- range .Doc}}
+{{.}}
+{{- end}}
+
+#![allow(dead_code, non_camel_case_types, non_snake_case, non_upper_case_globals)]
+
+use std::fmt;
+{{- range .Constants}}
+{{range .Doc}}
+{{.}}
+{{- end}}
+pub const {{.Name}}: {{.Type}} = {{.Value}};
+{{- end}}
+
+/// Error is returned when a register cannot be encoded or decoded
+#[derive(Debug, Clone, PartialEq)]
+pub enum Error {
+    /// The data is shorter than the encoded register
+    BufferTooSmall { need: usize, have: usize },
+    /// The variable array length differs from its size field value
+    ArrayLengthMismatch { field: &'static str, len: usize, expected: usize },
+    /// The string is longer than its maximum length
+    StringTooLong { field: &'static str, len: usize, max: usize },
+    /// The string is not valid UTF-8
+    InvalidString { field: &'static str },
+    /// The field value is out of its declared range
+    OutOfRange { field: &'static str },
+    /// The received checksum differs from the calculated one
+    ChecksumMismatch { field: &'static str, received: u64, calculated: u64 },
+    /// The received magic field differs from its declared value
+    MagicMismatch { field: &'static str, received: u64 },
+    /// The received register version differs from its declared one
+    VersionMismatch { register: &'static str, received: u8, expected: u8 },
+    /// The data has bytes after the encoded register
+    TrailingBytes { count: usize },
+}
+
+impl fmt::Display for Error {
+    fn fmt(&self, f: &mut fmt::Formatter<'_>) -> fmt::Result {
+        match self {
+            Error::BufferTooSmall { need, have } => write!(f, "buffer too small: need {} bytes, have {}", need, have),
+            Error::ArrayLengthMismatch { field, len, expected } => {
+                write!(f, "{} has {} elements, but {} are expected", field, len, expected)
+            }
+            Error::StringTooLong { field, len, max } => write!(f, "{} is {} bytes long, but the maximum is {}", field, len, max),
+            Error::InvalidString { field } => write!(f, "{} is not a valid UTF-8 string", field),
+            Error::OutOfRange { field } => write!(f, "{} is out of range", field),
+            Error::ChecksumMismatch { field, received, calculated } => {
+                write!(f, "{} mismatch: received 0x{:X}, calculated 0x{:X}", field, received, calculated)
+            }
+            Error::MagicMismatch { field, received } => write!(f, "{} mismatch: received 0x{:X}", field, received),
+            Error::VersionMismatch { register, received, expected } => {
+                write!(f, "{} version mismatch: received {}, expected {}", register, received, expected)
+            }
+            Error::TrailingBytes { count } => write!(f, "{} trailing bytes after the register", count),
+        }
+    }
+}
+
+impl std::error::Error for Error {}
+
+/// Wire is implemented by the numbers sent over the wire as their own bytes
+trait Wire: Sized {
+    const SIZE: usize;
+    fn put_be(self, buf: &mut Vec<u8>);
+    fn put_le(self, buf: &mut Vec<u8>);
+    fn from_be(b: &[u8]) -> Self;
+    fn from_le(b: &[u8]) -> Self;
+}
+
+macro_rules! impl_wire {
+    ($($t:ty),*) => {$(
+        impl Wire for $t {
+            const SIZE: usize = std::mem::size_of::<$t>();
+            fn put_be(self, buf: &mut Vec<u8>) {
+                buf.extend_from_slice(&self.to_be_bytes());
+            }
+            fn put_le(self, buf: &mut Vec<u8>) {
+                buf.extend_from_slice(&self.to_le_bytes());
+            }
+            fn from_be(b: &[u8]) -> Self {
+                <$t>::from_be_bytes(b.try_into().unwrap())
+            }
+            fn from_le(b: &[u8]) -> Self {
+                <$t>::from_le_bytes(b.try_into().unwrap())
+            }
+        }
+    )*};
+}
+
+impl_wire!(u8, i8, u16, i16, u32, i32, u64, i64, f32, f64);
+
+/// Reader decodes the values from data one after another
+struct Reader<'a> {
+    data: &'a [u8],
+    offset: usize,
+}
+
+impl<'a> Reader<'a> {
+    /// Returns the next n bytes of data
+    fn take(&mut self, n: usize) -> Result<&'a [u8], Error> {
+        let have = self.data.len() - self.offset;
+        if have < n {
+            return Err(Error::BufferTooSmall { need: n, have });
+        }
+        let b = &self.data[self.offset..self.offset + n];
+        self.offset += n;
+        Ok(b)
+    }
+
+    fn get_be<T: Wire>(&mut self) -> Result<T, Error> {
+        Ok(T::from_be(self.take(T::SIZE)?))
+    }
+
+    fn get_le<T: Wire>(&mut self) -> Result<T, Error> {
+        Ok(T::from_le(self.take(T::SIZE)?))
+    }
+{{- if .Int24}}
+
+    fn get_u24_be(&mut self) -> Result<u32, Error> {
+        let b = self.take(3)?;
+        Ok(u32::from_be_bytes([0, b[0], b[1], b[2]]))
+    }
+
+    fn get_u24_le(&mut self) -> Result<u32, Error> {
+        let b = self.take(3)?;
+        Ok(u32::from_le_bytes([b[0], b[1], b[2], 0]))
+    }
+{{- end}}
+
+    /// Returns an error if data has bytes after the decoded register
+    fn finish(&self) -> Result<(), Error> {
+        if self.offset != self.data.len() {
+            return Err(Error::TrailingBytes { count: self.data.len() - self.offset });
+        }
+        Ok(())
+    }
+}
+{{- if .Int24}}
+
+fn put_u24_be(v: u32, buf: &mut Vec<u8>) {
+    buf.extend_from_slice(&v.to_be_bytes()[1..]);
+}
+
+fn put_u24_le(v: u32, buf: &mut Vec<u8>) {
+    buf.extend_from_slice(&v.to_le_bytes()[..3]);
+}
+{{- end}}
+{{- if .Float16}}
+
+/// Returns the IEEE 754 half-precision bits of v rounded to the nearest even, the values too
+/// large for the half precision become infinities and the too small ones zeros
+fn f16_bits(v: f32) -> u16 {
+    let bits = v.to_bits();
+    let sign = ((bits >> 16) & 0x8000) as u16;
+    let mut exp = ((bits >> 23) & 0xff) as i32 - 127 + 15;
+    let mut mant = bits & 0x7f_ffff;
+    if bits & 0x7fff_ffff > 0x7f80_0000 {
+        return sign | 0x7e00;
+    }
+    if exp >= 0x1f {
+        return sign | 0x7c00;
+    }
+    let mut shift = 13;
+    if exp <= 0 {
+        if exp < -10 {
+            return sign;
+        }
+        // the subnormal keeps the implicit leading bit in the mantissa
+        mant |= 0x80_0000;
+        shift = (14 - exp) as u32;
+        exp = 0;
+    }
+    // the rounding carry may overflow the mantissa to the exponent, which is still correct
+    let mut h = ((exp as u32) << 10) + (mant >> shift);
+    let rem = mant & ((1 << shift) - 1);
+    let half = 1 << (shift - 1);
+    if rem > half || (rem == half && h & 1 == 1) {
+        h += 1;
+    }
+    sign | h as u16
+}
+
+/// Returns the value of the IEEE 754 half-precision bits
+fn f16_from_bits(h: u16) -> f32 {
+    let sign = ((h & 0x8000) as u32) << 16;
+    let exp = ((h >> 10) & 0x1f) as u32;
+    let mant = (h & 0x3ff) as u32;
+    let bits = match exp {
+        // the subnormals are mant * 2^-24, they are normal numbers in f32
+        0 => (mant as f32 / 16777216.0).to_bits() | sign,
+        0x1f => sign | 0x7f80_0000 | (mant << 13),
+        _ => sign | ((exp + 127 - 15) << 23) | (mant << 13),
+    };
+    f32::from_bits(bits)
+}
+{{- end}}
+{{- if index .CRCs "ccitt"}}
+
+/// Returns the CRC-16/CCITT-FALSE checksum of data
+fn crc16_ccitt(data: &[u8]) -> u16 {
+    let mut crc: u16 = 0xFFFF;
+    for &b in data {
+        crc ^= (b as u16) << 8;
+        for _ in 0..8 {
+            crc = if crc & 0x8000 != 0 { (crc << 1) ^ 0x1021 } else { crc << 1 };
+        }
+    }
+    crc
+}
+{{- end}}
+{{- if index .CRCs "modbus"}}
+
+/// Returns the CRC-16/MODBUS checksum of data
+fn crc16_modbus(data: &[u8]) -> u16 {
+    let mut crc: u16 = 0xFFFF;
+    for &b in data {
+        crc ^= b as u16;
+        for _ in 0..8 {
+            crc = if crc & 1 != 0 { (crc >> 1) ^ 0xA001 } else { crc >> 1 };
+        }
+    }
+    crc
+}
+{{- end}}
+{{- if index .CRCs "ieee"}}
+
+/// Returns the CRC-32/IEEE checksum of data
+fn crc32_ieee(data: &[u8]) -> u32 {
+    let mut crc: u32 = 0xFFFFFFFF;
+    for &b in data {
+        crc ^= b as u32;
+        for _ in 0..8 {
+            crc = if crc & 1 != 0 { (crc >> 1) ^ 0xEDB88320 } else { crc >> 1 };
+        }
+    }
+    !crc
+}
+{{- end}}
+{{- range .Enums}}
+{{- $enum := .}}
+{{range .Doc}}
+{{.}}
+{{- end}}
+#[derive(Debug, Clone, Copy, PartialEq, Eq)]
+#[repr({{.Base}})]
+pub enum {{.Name}} {
+{{- range .Members}}
+{{- range .Doc}}
+    {{.}}
+{{- end}}
+    {{.Name}} = {{.Value}},
+{{- end}}
+}
+
+impl From<{{.Name}}> for {{.Base}} {
+    fn from(v: {{.Name}}) -> Self {
+        v as {{.Base}}
+    }
+}
+
+impl TryFrom<{{.Base}}> for {{.Name}} {
+    type Error = {{.Base}};
+
+    /// Returns the {{.Name}} member of the value or the value itself if there is no such member
+    fn try_from(v: {{.Base}}) -> Result<Self, {{.Base}}> {
+        match v {
+{{- range .Members}}
+            {{.Value}} => Ok({{$enum.Name}}::{{.Name}}),
+{{- end}}
+            _ => Err(v),
+        }
+    }
+}
+{{- end}}
+{{- range .Registers}}
+{{range .Doc}}
+{{.}}
+{{- end}}
+#[derive(Debug, Clone, PartialEq)]
+pub struct {{.Name}} {
+{{- range .Fields}}{{if .Decl}}
+{{- range .Doc}}
+    {{.}}
+{{- end}}
+    {{.Decl}},{{if .Trailing}} {{.Trailing}}{{end}}
+{{- end}}{{end}}
+}
+
+impl Default for {{.Name}} {
+    fn default() -> Self {
+        Self {
+{{- range .Fields}}{{if .Decl}}
+            {{.Name}}: {{.Zero}},
+{{- end}}{{end}}
+        }
+    }
+}
+
+impl {{.Name}} {
+    pub const ID: u8 = {{.Number}};
+{{- if .HasVersion}}
+    pub const VERSION: u8 = {{.Version}};
+{{- end}}
+{{- range .Constants}}
+{{- range .Doc}}
+    {{.}}
+{{- end}}
+    pub const {{.Name}}: {{.Type}} = {{.Value}};
+{{- end}}
+{{- range .Fields}}{{range .Accessors}}
+
+{{.}}
+{{- end}}{{end}}
+
+    /// Returns an error if an array length differs from its size, a string is too long or a field is out of its range
+    pub fn check(&self) -> Result<(), Error> {
+{{- range .Fields}}{{range .Checks}}
+        {{.}}
+{{- end}}{{end}}
+        Ok(())
+    }
+
+    /// Encodes the {{.Dir}} fields
+    pub fn to_bytes(&self) -> Result<Vec<u8>, Error> {
+        self.to_bytes_{{.Dir}}()
+    }
+
+    /// Decodes the {{.Dir}} fields, data must hold exactly one register
+    pub fn from_bytes(data: &[u8]) -> Result<Self, Error> {
+        Self::from_bytes_{{.Dir}}(data)
+    }
+{{- range .Dirs}}
+
+    /// Encodes the {{.Name}} fields
+    pub fn to_bytes_{{.Name}}(&self) -> Result<Vec<u8>, Error> {
+        let mut buf = Vec::new();
+        self.encode_{{.Name}}(&mut buf)?;
+        Ok(buf)
+    }
+
+    /// Decodes the {{.Name}} fields, data must hold exactly one register
+    pub fn from_bytes_{{.Name}}(data: &[u8]) -> Result<Self, Error> {
+        let mut rd = Reader { data, offset: 0 };
+        let r = Self::decode_{{.Name}}(&mut rd)?;
+        rd.finish()?;
+        Ok(r)
+    }
+
+    fn encode_{{.Name}}(&self, {{if not .Encode}}_{{end}}buf: &mut Vec<u8>) -> Result<(), Error> {
+        self.check()?;
+{{- if .HasCRC}}
+        let start = buf.len();
+{{- end}}
+{{- range .Encode}}
+        {{.}}
+{{- end}}
+        Ok(())
+    }
+
+    fn decode_{{.Name}}({{if not .Decode}}_{{end}}rd: &mut Reader) -> Result<Self, Error> {
+{{- if .Decode}}
+        let {{if .HasValues}}mut {{end}}r = Self::default();
+{{- if .HasCRC}}
+        let start = rd.offset;
+{{- end}}
+{{- range .Decode}}
+        {{.}}
+{{- end}}
+        Ok(r)
+{{- else}}
+        Ok(Self::default())
+{{- end}}
+    }
+{{- end}}
+}
+{{- end}}
+`
+
+var rustTpl = template.Must(template.New("rust").Parse(rustTemplate))
+
+//
+// Intermediate representation for template
+//
+
+type RustDevice struct {
+	Doc       []string
+	Constants []RustConstant
+	Enums     []RustEnum
+	Registers []RustRegister
+	Int24     bool            // true if any field is a 24-bit integer
+	Float16   bool            // true if any field is a half-precision number
+	CRCs      map[string]bool // checksum algorithms the registers use
+}
+
+type RustConstant struct {
+	Doc   []string
+	Name  string
+	Type  string
+	Value string
+}
+
+type RustEnum struct {
+	Doc     []string
+	Name    string
+	Base    string
+	Members []RustConstant
+}
+
+type RustRegister struct {
+	Name       string
+	Number     int
+	Doc        []string
+	Dir        string // the direction to_bytes and from_bytes encode, write unless the register is read-only
+	HasVersion bool   // true if the version byte precedes the fields
+	Version    uint8
+	Constants  []RustConstant
+	Fields     []RustField
+	Dirs       []RustDir
+}
+
+// RustDir is the code encoding and decoding the fields sent in one direction
+type RustDir struct {
+	Name      string // read or write
+	HasCRC    bool   // true if a field is a checksum of the preceding bytes
+	HasValues bool   // true if a field has the value kept in the struct
+	Encode    []string
+	Decode    []string
+}
+
+type RustField struct {
+	Doc         []string
+	Name        string // the struct field name, the Rust keywords are raw identifiers
+	Decl        string // the struct field, empty for the reserved, checksum and magic fields
+	Zero        string // the default value of the field
+	Trailing    string
+	IsCRC       bool
+	IsReadable  bool
+	IsWritable  bool
+	EncodeRead  []string // Code for encode_read method
+	EncodeWrite []string // Code for encode_write method
+	DecodeRead  []string // Code for decode_read method
+	DecodeWrite []string // Code for decode_write method
+	Checks      []string // Checks for variable-length arrays, strings and ranges
+	Accessors   []string // Methods of the bit field members and fixed-point numbers
+}
+
+//
+// Public entry
+//
+
+// GenerateRust generates the Rust module for the device. Every register is a struct encoded
+// by its to_bytes and decoded by its from_bytes methods
+func GenerateRust(dev *parser.Device) (string, error) {
+	out := RustDevice{Doc: rustDocs(declComments(dev.Doc, dev.TrailingComment), "//!")}
+	for _, c := range dev.Constants {
+		out.Constants = append(out.Constants, RustConstant{
+			Doc:   rustDocs(declComments(c.Doc, c.TrailingComment), "///"),
+			Name:  c.Name,
+			Type:  rustType(c.Type.Name),
+			Value: rustIntLiteral(c.ValueStr),
+		})
+	}
+	for _, e := range dev.Enums {
+		re := RustEnum{Doc: rustDocs(declComments(e.Doc, e.TrailingComment), "///"), Name: e.Name, Base: rustType(e.Base)}
+		for _, m := range e.Members {
+			re.Members = append(re.Members, RustConstant{
+				Doc:   rustDocs(declComments(m.Doc, m.TrailingComment), "///"),
+				Name:  m.Name,
+				Value: rustIntLiteral(m.ValueStr),
+			})
+		}
+		out.Enums = append(out.Enums, re)
+	}
+
+	for _, reg := range dev.Registers {
+		rr := RustRegister{
+			Name:   reg.Name,
+			Number: int(reg.Number()),
+			Doc:    rustDocs(declComments(reg.Doc, reg.TrailingComment), "///"),
+			Dir:    "write",
+		}
+		if reg.Specifier == "r" {
+			rr.Dir = "read"
+		}
+		if reg.HasVersion() {
+			rr.HasVersion, rr.Version = true, reg.Version()
+		}
+		for _, c := range reg.Body.Constants() {
+			rr.Constants = append(rr.Constants, RustConstant{
+				Doc:   rustDocs(declComments(c.Doc, c.TrailingComment), "///"),
+				Name:  c.Name,
+				Type:  rustType(c.Type.Name),
+				Value: rustIntLiteral(c.ValueStr),
+			})
+		}
+
+		for i, f := range reg.Body.Fields() {
+			rf := RustField{
+				Doc:        rustDocs(flattenComments(f.Doc), "///"),
+				Name:       rustName(f.Name),
+				Trailing:   safeString(f.TrailingComment),
+				IsReadable: f.Specifier == "r" || f.Specifier == "",
+				IsWritable: f.Specifier == "w" || f.Specifier == "",
+			}
+			field := "self." + rf.Name
+			target := "r." + rf.Name
+			le := f.IsLittleEndian()
+
+			// The field placed at the offset is preceded by the zero bytes filling the gap
+			for _, dir := range rf.dirs() {
+				if pad := reg.Padding(f, dir == "read"); pad > 0 {
+					rf.addDir(dir, []string{fmt.Sprintf("buf.extend_from_slice(&[0; %d]);", pad)},
+						[]string{fmt.Sprintf("rd.take(%d)?;", pad)})
+				}
+			}
+
+			switch {
+			case f.Reserved:
+				size := reservedSize(f)
+				rf.add([]string{fmt.Sprintf("buf.extend_from_slice(&[0; %d]);", size)},
+					[]string{fmt.Sprintf("rd.take(%d)?;", size)})
+
+			case f.Type.CRC != nil:
+				// the checksum is calculated over the register bytes preceding it
+				base := f.Type.CRC.BaseType()
+				crcFn := out.rustCRCFunc(f.Type.CRC)
+				rf.IsCRC = true
+				rf.add([]string{rustPut(base, crcFn+"(&buf[start..])", le)}, []string{
+					fmt.Sprintf("let calculated = %s(&rd.data[start..rd.offset]);", crcFn),
+					fmt.Sprintf("let crc: %s = %s;", rustType(base), out.rustGet(base, le)),
+					"if crc != calculated {",
+					fmt.Sprintf("    return Err(Error::ChecksumMismatch { field: %q, received: crc as u64, calculated: calculated as u64 });", f.Name),
+					"}",
+				})
+
+			case f.IsMagic():
+				// the magic value is always the same, so it has no value to keep
+				typ := f.Type.Simple.Name
+				magic := magicLiteral(f)
+				rf.add([]string{rustPut(typ, fmt.Sprintf("(%s as %s)", magic, rustType(typ)), le)}, []string{
+					fmt.Sprintf("let magic: %s = %s;", rustType(typ), out.rustGet(typ, le)),
+					fmt.Sprintf("if magic != %s {", magic),
+					fmt.Sprintf("    return Err(Error::MagicMismatch { field: %q, received: magic as u64 });", f.Name),
+					"}",
+				})
+
+			case f.Type.Simple != nil && f.Type.Simple.IsRegisterRef():
+				ref := f.Type.Simple.Name
+				rf.Decl = fmt.Sprintf("pub %s: %s", rf.Name, ref)
+				rf.Zero = ref + "::default()"
+				for _, dir := range rf.dirs() {
+					rf.addDir(dir, []string{fmt.Sprintf("%s.encode_%s(buf)?;", field, dir)},
+						[]string{fmt.Sprintf("%s = %s::decode_%s(rd)?;", target, ref, dir)})
+				}
+
+			case f.Type.Bitfield != nil:
+				bf := f.Type.Bitfield
+				rf.Decl = fmt.Sprintf("pub %s: %s", rf.Name, rustType(bf.Base))
+				rf.Zero = rustDefault(f, "0")
+				for _, bm := range bf.Bits {
+					if bm.Reserved {
+						// reserved members only document the unused bits
+						continue
+					}
+					rf.Accessors = append(rf.Accessors, rustBitAccessors(f.Name, rf.Name, bf.Base, &bm))
+				}
+				rf.add([]string{rustPut(bf.Base, field, le)}, []string{fmt.Sprintf("%s = %s;", target, out.rustGet(bf.Base, le))})
+
+			case f.Type.Array != nil && f.Type.Array.Type.IsRegisterRef():
+				elem := f.Type.Array.Type.Name
+				if n := f.Type.Array.Size.Constant; n != nil {
+					rf.Decl = fmt.Sprintf("pub %s: [%s; %s]", rf.Name, elem, rustIntLiteral(*n))
+					rf.Zero = fmt.Sprintf("std::array::from_fn(|_| %s::default())", elem)
+				} else {
+					rf.Decl = fmt.Sprintf("pub %s: Vec<%s>", rf.Name, elem)
+					rf.Zero = "Vec::new()"
+					rf.Checks = append(rf.Checks, rustLenCheck(f.Name, field, rustSizeFieldValue(reg, f, i, "self"))...)
+				}
+
+				// Every element is encoded by its own register methods
+				for _, dir := range rf.dirs() {
+					decode := []string{
+						fmt.Sprintf("for v in %s.iter_mut() {", target),
+						fmt.Sprintf("    *v = %s::decode_%s(rd)?;", elem, dir),
+						"}",
+					}
+					if f.Type.Array.Size.Variable != nil {
+						decode = []string{
+							fmt.Sprintf("for _ in 0..%s {", rustSizeFieldValue(reg, f, i, "r")),
+							fmt.Sprintf("    %s.push(%s::decode_%s(rd)?);", target, elem, dir),
+							"}",
+						}
+					}
+					rf.addDir(dir, []string{
+						fmt.Sprintf("for v in %s.iter() {", field),
+						fmt.Sprintf("    v.encode_%s(buf)?;", dir),
+						"}",
+					}, decode)
+				}
+
+			case f.Type.Array != nil:
+				at := f.Type.Array
+				typ := at.Type.Name
+				if is24BitType(typ) {
+					out.Int24 = true
+				}
+				if typ == "float16" {
+					out.Float16 = true
+				}
+				// the items of the multi-dimensional arrays are the arrays of the inner dimensions
+				item, zero := rustType(typ), rustZero(typ)
+				for j := len(at.Dims) - 1; j >= 0; j-- {
+					item = fmt.Sprintf("[%s; %s]", item, rustIntLiteral(at.Dims[j]))
+					zero = fmt.Sprintf("[%s; %s]", zero, rustIntLiteral(at.Dims[j]))
+				}
+				depth := len(at.Dims) + 1
+
+				encode := rustLoops(field, depth, false, rustPut(typ, "(*v)", le))
+				var decode []string
+				if n := at.Size.Constant; n != nil {
+					rf.Decl = fmt.Sprintf("pub %s: [%s; %s]", rf.Name, item, rustIntLiteral(*n))
+					rf.Zero = fmt.Sprintf("[%s; %s]", zero, rustIntLiteral(*n))
+					decode = rustLoops(target, depth, true, fmt.Sprintf("*v = %s;", out.rustGet(typ, le)))
+				} else {
+					rf.Decl = fmt.Sprintf("pub %s: Vec<%s>", rf.Name, item)
+					rf.Zero = "Vec::new()"
+					rf.Checks = append(rf.Checks, rustLenCheck(f.Name, field, rustSizeFieldValue(reg, f, i, "self"))...)
+					decode = []string{fmt.Sprintf("for _ in 0..%s {", rustSizeFieldValue(reg, f, i, "r"))}
+					if at.IsMultiDim() {
+						decode = append(decode, fmt.Sprintf("    let mut row = %s;", zero))
+						for _, line := range rustLoops("row", depth-1, true, fmt.Sprintf("*v = %s;", out.rustGet(typ, le))) {
+							decode = append(decode, "    "+line)
+						}
+						decode = append(decode, fmt.Sprintf("    %s.push(row);", target))
+					} else {
+						decode = append(decode, fmt.Sprintf("    %s.push(%s);", target, out.rustGet(typ, le)))
+					}
+					decode = append(decode, "}")
+				}
+				rf.add(encode, decode)
+
+			case f.Type.String != nil:
+				prefix := f.Type.String.PrefixType()
+				maxLen := f.Type.String.MaxLen()
+				rf.Decl = fmt.Sprintf("pub %s: String", rf.Name)
+				rf.Zero = "String::new()"
+				rf.Checks = append(rf.Checks,
+					fmt.Sprintf("if %s.len() > %d {", field, maxLen),
+					fmt.Sprintf("    return Err(Error::StringTooLong { field: %q, len: %s.len(), max: %d });", f.Name, field, maxLen),
+					"}")
+				decode := []string{
+					"{",
+					fmt.Sprintf("    let n = rd.get_%s::<%s>()? as usize;", rustOrder(le), rustType(prefix)),
+				}
+				if f.Type.String.MaxLenStr != nil {
+					// without the explicit maximum the length is limited by the prefix type
+					decode = append(decode,
+						fmt.Sprintf("    if n > %d {", maxLen),
+						fmt.Sprintf("        return Err(Error::StringTooLong { field: %q, len: n, max: %d });", f.Name, maxLen),
+						"    }")
+				}
+				decode = append(decode,
+					fmt.Sprintf("    %s = String::from_utf8(rd.take(n)?.to_vec()).map_err(|_| Error::InvalidString { field: %q })?;", target, f.Name),
+					"}")
+				rf.add([]string{
+					rustPut(prefix, fmt.Sprintf("(%s.len() as %s)", field, rustType(prefix)), le),
+					fmt.Sprintf("buf.extend_from_slice(%s.as_bytes());", field),
+				}, decode)
+
+			case f.Type.Simple != nil && f.Type.Simple.IsEnum():
+				// the enum is sent over the wire as its base integer type, so unknown values are kept
+				base := f.Type.Simple.Enum.Base
+				rf.Decl = fmt.Sprintf("pub %s: %s", rf.Name, rustType(base))
+				rf.Zero = rustDefault(f, "0")
+				if rf.Trailing == "" {
+					rf.Trailing = "// " + f.Type.Simple.Name
+				}
+				rf.add([]string{rustPut(base, field, le)}, []string{fmt.Sprintf("%s = %s;", target, out.rustGet(base, le))})
+
+			case f.Type.Simple != nil, f.Type.Fixed != nil:
+				typ := scalarTypeName(f)
+				rf.Decl = fmt.Sprintf("pub %s: %s", rf.Name, rustType(typ))
+				rf.Zero = rustDefault(f, rustZero(typ))
+				if f.Type.Fixed != nil {
+					// the field keeps the raw integer, the accessors convert it
+					scale := fixedScale(f.Type.Fixed)
+					if !strings.Contains(scale, ".") {
+						scale += ".0"
+					}
+					rf.Accessors = append(rf.Accessors, strings.Join([]string{
+						fmt.Sprintf("    /// Returns the %s value", f.Name),
+						fmt.Sprintf("    pub fn %s_value(&self) -> f64 {", f.Name),
+						fmt.Sprintf("        %s as f64 / %s", field, scale),
+						"    }",
+						"",
+						fmt.Sprintf("    /// Sets the %s value rounded to the nearest raw one", f.Name),
+						fmt.Sprintf("    pub fn set_%s_value(&mut self, v: f64) {", f.Name),
+						fmt.Sprintf("        %s = (v * %s).round() as %s;", field, scale, rustType(typ)),
+						"    }",
+					}, "\n"))
+				}
+				if conds := rangeConditions(f, field); len(conds) > 0 {
+					if strings.HasPrefix(typ, "float") {
+						// the bounds are integers, so they are compared as the floating-point literals
+						for j := range conds {
+							conds[j] += ".0"
+						}
+					}
+					rf.Checks = append(rf.Checks,
+						fmt.Sprintf("if %s {", strings.Join(conds, " || ")),
+						fmt.Sprintf("    return Err(Error::OutOfRange { field: %q });", f.Name),
+						"}")
+				}
+				if is24BitType(typ) {
+					out.Int24 = true
+				}
+				if typ == "float16" {
+					out.Float16 = true
+				}
+				rf.add([]string{rustPut(typ, field, le)}, []string{fmt.Sprintf("%s = %s;", target, out.rustGet(typ, le))})
+			}
+
+			// The conditional field is sent only if its condition is set, the absent field keeps its default
+			if f.HasCondition() {
+				encode, decode := rustConditionExpr(reg, f, i, "self"), rustConditionExpr(reg, f, i, "r")
+				rf.EncodeRead, rf.EncodeWrite = rustIf(encode, rf.EncodeRead), rustIf(encode, rf.EncodeWrite)
+				rf.DecodeRead, rf.DecodeWrite = rustIf(decode, rf.DecodeRead), rustIf(decode, rf.DecodeWrite)
+				rf.Checks = rustIf(encode, rf.Checks)
+			}
+
+			rr.Fields = append(rr.Fields, rf)
+		}
+
+		for _, dir := range []string{"read", "write"} {
+			rd := RustDir{Name: dir}
+			if reg.HasVersion() && (reg.Specifier == "" || reg.Specifier == dir[:1]) {
+				// the version byte precedes the fields in the directions the register is sent in
+				rd.Encode = append(rd.Encode, "buf.push(Self::VERSION);")
+				rd.Decode = append(rd.Decode,
+					"let version: u8 = rd.get_be()?;",
+					"if version != Self::VERSION {",
+					fmt.Sprintf("    return Err(Error::VersionMismatch { register: %q, received: version, expected: Self::VERSION });", reg.Name),
+					"}")
+			}
+			for _, rf := range rr.Fields {
+				if slices.Contains(rf.dirs(), dir) {
+					rd.HasCRC = rd.HasCRC || rf.IsCRC
+					rd.HasValues = rd.HasValues || rf.Decl != ""
+				}
+				if dir == "read" {
+					rd.Encode = append(rd.Encode, rf.EncodeRead...)
+					rd.Decode = append(rd.Decode, rf.DecodeRead...)
+				} else {
+					rd.Encode = append(rd.Encode, rf.EncodeWrite...)
+					rd.Decode = append(rd.Decode, rf.DecodeWrite...)
+				}
+			}
+			rr.Dirs = append(rr.Dirs, rd)
+		}
+		out.Registers = append(out.Registers, rr)
+	}
+
+	var buf bytes.Buffer
+	if err := rustTpl.Execute(&buf, out); err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(buf.String()) + "\n", nil
+}
+
+//
+// Helpers
+//
+
+// dirs returns the directions, read and write, the field is sent in
+func (f *RustField) dirs() []string {
+	var res []string
+	if f.IsReadable {
+		res = append(res, "read")
+	}
+	if f.IsWritable {
+		res = append(res, "write")
+	}
+	return res
+}
+
+// add adds the code of the field to the directions the field is sent in
+func (f *RustField) add(encode, decode []string) {
+	for _, dir := range f.dirs() {
+		f.addDir(dir, encode, decode)
+	}
+}
+
+// addDir adds the code of the field to the dir direction
+func (f *RustField) addDir(dir string, encode, decode []string) {
+	if dir == "read" {
+		f.EncodeRead = append(f.EncodeRead, encode...)
+		f.DecodeRead = append(f.DecodeRead, decode...)
+	} else {
+		f.EncodeWrite = append(f.EncodeWrite, encode...)
+		f.DecodeWrite = append(f.DecodeWrite, decode...)
+	}
+}
+
+// rustCRCFunc returns the function calculating the checksum and registers its runtime helper
+func (d *RustDevice) rustCRCFunc(ct *parser.CRCType) string {
+	alg := ct.AlgorithmName()
+	if d.CRCs == nil {
+		d.CRCs = make(map[string]bool)
+	}
+	d.CRCs[alg] = true
+	return ct.Kind + "_" + alg
+}
+
+// rustOrder returns the suffix of the functions encoding and decoding the values in the byte order
+func rustOrder(le bool) string {
+	if le {
+		return "le"
+	}
+	return "be"
+}
+
+// rustPut returns the statement appending the value of the built-in typ to buf
+func rustPut(typ, value string, le bool) string {
+	order := rustOrder(le)
+	switch typ {
+	case "int24":
+		return fmt.Sprintf("put_u24_%s(%s as u32, buf);", order, value)
+	case "uint24":
+		return fmt.Sprintf("put_u24_%s(%s, buf);", order, value)
+	case "float16":
+		// the dereferenced array element needs no parentheses as the function argument
+		value = strings.TrimSuffix(strings.TrimPrefix(value, "("), ")")
+		return fmt.Sprintf("f16_bits(%s).put_%s(buf);", value, order)
+	default:
+		return fmt.Sprintf("%s.put_%s(buf);", value, order)
+	}
+}
+
+// rustGet returns the expression reading the value of the built-in typ from rd, the type
+// of the other integers is inferred from the variable the value is assigned to
+func (d *RustDevice) rustGet(typ string, le bool) string {
+	order := rustOrder(le)
+	switch typ {
+	case "int24":
+		// the sign bit of the 24-bit value is extended by the arithmetic shift
+		d.Int24 = true
+		return fmt.Sprintf("((rd.get_u24_%s()? << 8) as i32) >> 8", order)
+	case "uint24":
+		d.Int24 = true
+		return fmt.Sprintf("rd.get_u24_%s()?", order)
+	case "float16":
+		d.Float16 = true
+		return fmt.Sprintf("f16_from_bits(rd.get_%s::<u16>()?)", order)
+	default:
+		return fmt.Sprintf("rd.get_%s()?", order)
+	}
+}
+
+// rustLoops returns the nested loops over the elements of the array with the depth dimensions,
+// the body handles one element v
+func rustLoops(array string, depth int, mutable bool, body string) []string {
+	iter := "iter()"
+	if mutable {
+		iter = "iter_mut()"
+	}
+	var res []string
+	for j := 0; j < depth; j++ {
+		src := "v"
+		if j == 0 {
+			src = array
+		}
+		res = append(res, fmt.Sprintf("%sfor v in %s.%s {", strings.Repeat("    ", j), src, iter))
+	}
+	res = append(res, strings.Repeat("    ", depth)+body)
+	for j := depth - 1; j >= 0; j-- {
+		res = append(res, strings.Repeat("    ", j)+"}")
+	}
+	return res
+}
+
+// rustConditionExpr returns the expression which is true if the conditional field of the obj is sent,
+// the condition is either the single-bit member or the uint8 field, which is set if it is not zero
+func rustConditionExpr(reg *parser.Register, f *parser.Field, idx int, obj string) string {
+	fld, bm := reg.FindFieldByName(*f.Condition, idx)
+	if bm != nil {
+		return fmt.Sprintf("%s.%s & 0x%X != 0", obj, rustName(fld.Name), bitMask(bm.StartBit(), bm.EndBit()))
+	}
+	return fmt.Sprintf("%s.%s != 0", obj, rustName(fld.Name))
+}
+
+// rustIf returns the code executed only if the condition is true, there is no code if the code is empty
+func rustIf(cond string, code []string) []string {
+	if len(code) == 0 {
+		return nil
+	}
+	res := []string{fmt.Sprintf("if %s {", cond)}
+	for _, line := range code {
+		res = append(res, "    "+line)
+	}
+	return append(res, "}")
+}
+
+// rustBitAccessors returns the methods reading and writing the bit field member bm of the field
+func rustBitAccessors(field, name, base string, bm *parser.BitMember) string {
+	start, end := bm.StartBit(), bm.EndBit()
+	mask := bitMask(start, end)
+	typ := rustType(base)
+	self := "self." + name
+	method := field + "_" + bm.Name
+	if start == end {
+		return strings.Join([]string{
+			fmt.Sprintf("    /// Returns the %s bit of %s", bm.Name, field),
+			fmt.Sprintf("    pub fn %s(&self) -> bool {", method),
+			fmt.Sprintf("        %s & 0x%X != 0", self, mask),
+			"    }",
+			"",
+			fmt.Sprintf("    /// Sets the %s bit of %s", bm.Name, field),
+			fmt.Sprintf("    pub fn set_%s(&mut self, v: bool) {", method),
+			fmt.Sprintf("        if v { %s |= 0x%X } else { %s &= !0x%X }", self, mask, self, mask),
+			"    }",
+		}, "\n")
+	}
+
+	bits := wireTypeSize(base) * 8
+	if is24BitType(base) {
+		// the 24-bit bit field is kept in u32
+		bits = 32
+	}
+	valueType, getter, value := typ, fmt.Sprintf("(%s >> %d) & 0x%X", self, start, bitMask(0, end-start)), "v"
+	if bm.Signed {
+		// the member is shifted to the top bits, so the arithmetic shift extends its sign
+		valueType = "i" + strings.TrimPrefix(typ, "u")
+		getter = fmt.Sprintf("((%s << %d) as %s) >> %d", self, bits-1-end, valueType, bits-1-end+start)
+		value = fmt.Sprintf("(v as %s)", typ)
+	}
+	return strings.Join([]string{
+		fmt.Sprintf("    /// Returns the %s bits of %s", bm.Name, field),
+		fmt.Sprintf("    pub fn %s(&self) -> %s {", method, valueType),
+		"        " + getter,
+		"    }",
+		"",
+		fmt.Sprintf("    /// Sets the %s bits of %s, the value bits not fitting the member are dropped", bm.Name, field),
+		fmt.Sprintf("    pub fn set_%s(&mut self, v: %s) {", method, valueType),
+		fmt.Sprintf("        %s = (%s & !0x%X) | ((%s << %d) & 0x%X);", self, self, mask, value, start, mask),
+		"    }",
+	}, "\n")
+}
+
+// rustSizeFieldValue returns the usize expression of the variable-length array f size field value,
+// the bit field members are read by their accessors
+func rustSizeFieldValue(reg *parser.Register, f *parser.Field, idx int, obj string) string {
+	field, bm := reg.FindFieldByName(*f.Type.Array.Size.Variable, idx)
+	if bm != nil {
+		return fmt.Sprintf("%s.%s_%s() as usize", obj, field.Name, bm.Name)
+	}
+	return fmt.Sprintf("%s.%s as usize", obj, rustName(field.Name))
+}
+
+// rustLenCheck returns the statements returning an error if the length of the array differs from count
+func rustLenCheck(name, field, count string) []string {
+	return []string{
+		fmt.Sprintf("if %s.len() != %s {", field, count),
+		fmt.Sprintf("    return Err(Error::ArrayLengthMismatch { field: %q, len: %s.len(), expected: %s });", name, field, count),
+		"}",
+	}
+}
+
+// rustType returns the Rust type of the built-in type, the 24-bit integers are kept in the 32-bit ones
+func rustType(typ string) string {
+	switch typ {
+	case "int8":
+		return "i8"
+	case "uint8":
+		return "u8"
+	case "int16":
+		return "i16"
+	case "uint16":
+		return "u16"
+	case "int24", "int32":
+		return "i32"
+	case "uint24", "uint32":
+		return "u32"
+	case "int64":
+		return "i64"
+	case "uint64":
+		return "u64"
+	case "float16", "float32":
+		return "f32"
+	case "float64":
+		return "f64"
+	default:
+		return typ
+	}
+}
+
+// rustDefault returns the default value literal of the field or zero if the field has no default value
+func rustDefault(f *parser.Field, zero string) string {
+	if f.HasDefault() {
+		return defaultLiteral(f)
+	}
+	return zero
+}
+
+// rustIntLiteral returns the integer literal valid in Rust, which accepts only the lowercase
+// 0x and 0b prefixes
+func rustIntLiteral(s string) string {
+	digits, sign := strings.CutPrefix(s, "-")
+	if len(digits) < 2 || digits[0] != '0' || (digits[1] != 'X' && digits[1] != 'B') {
+		return s
+	}
+	res := "0" + strings.ToLower(digits[1:2]) + digits[2:]
+	if sign {
+		res = "-" + res
+	}
+	return res
+}
+
+// rustZero returns the zero value literal of the built-in type
+func rustZero(typ string) string {
+	if strings.HasPrefix(typ, "float") {
+		return "0.0"
+	}
+	return "0"
+}
+
+// rustKeywords are the Rust keywords which are the raw identifiers as the field names
+var rustKeywords = map[string]bool{
+	"abstract": true, "as": true, "async": true, "await": true, "become": true, "box": true, "break": true,
+	"const": true, "continue": true, "do": true, "dyn": true, "else": true, "enum": true, "extern": true,
+	"false": true, "final": true, "fn": true, "for": true, "gen": true, "if": true, "impl": true, "in": true,
+	"let": true, "loop": true, "macro": true, "match": true, "mod": true, "move": true, "mut": true,
+	"override": true, "priv": true, "pub": true, "ref": true, "return": true, "static": true, "struct": true,
+	"trait": true, "true": true, "try": true, "type": true, "typeof": true, "unsafe": true, "unsized": true,
+	"use": true, "virtual": true, "where": true, "while": true, "yield": true,
+}
+
+// rustName returns the struct field name, the Rust keywords become the raw identifiers except for
+// the ones which cannot be raw, they get the underscore suffix
+func rustName(name string) string {
+	switch {
+	case name == "self" || name == "Self" || name == "super" || name == "crate":
+		return name + "_"
+	case rustKeywords[name]:
+		return "r#" + name
+	default:
+		return name
+	}
+}
+
+// rustDocs converts the // comment lines to the doc comments with the prefix, /// or //!
+func rustDocs(comments []string, prefix string) []string {
+	for len(comments) > 0 && comments[0] == "" {
+		comments = comments[1:]
+	}
+	res := make([]string, 0, len(comments))
+	for _, c := range comments {
+		if text, ok := strings.CutPrefix(c, "//"); ok {
+			res = append(res, prefix+text)
+		} else {
+			res = append(res, strings.TrimSpace(prefix+" "+c))
+		}
+	}
+	return res
+}
@@ -0,0 +1,413 @@
+package generator
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+
+	"github.com/dspasibenko/pargus/pkg/parser"
+	"github.com/stretchr/testify/require"
+)
+
+// runGeneratedRustTest puts the generated module and the test program into a temporary
+// directory, compiles them by rustc denying the warnings and runs the program
+func runGeneratedRustTest(t *testing.T, code, mainCode string) {
+	t.Helper()
+	rustc, err := exec.LookPath("rustc")
+	if err != nil {
+		t.Skip("rustc is not available")
+	}
+
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "registers.rs"), []byte(code), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "main.rs"), []byte(mainCode), 0644))
+
+	cmd := exec.Command(rustc, "--edition", "2021", "-D", "warnings", "-o", "test", "main.rs")
+	cmd.Dir = dir
+	out, err := cmd.CombinedOutput()
+	require.NoError(t, err, "generated code compilation failed:\n%s\n%s", out, code)
+
+	cmd = exec.Command(filepath.Join(dir, "test"))
+	out, err = cmd.CombinedOutput()
+	require.NoError(t, err, "generated code test failed:\n%s", out)
+}
+
+func TestGenerateRustGolden(t *testing.T) {
+	input, err := os.ReadFile("testdata/example.pa")
+	require.NoError(t, err)
+	device, err := parser.Parse(string(input))
+	require.NoError(t, err)
+
+	code, err := GenerateRust(device)
+	require.NoError(t, err)
+	golden, err := os.ReadFile("testdata/example.rs")
+	require.NoError(t, err)
+	require.Equal(t, string(golden), code)
+}
+
+func TestGeneratedRustRoundTrip(t *testing.T) {
+	input, err := os.ReadFile("testdata/example.pa")
+	require.NoError(t, err)
+	device, err := parser.Parse(string(input))
+	require.NoError(t, err)
+	code, err := GenerateRust(device)
+	require.NoError(t, err)
+
+	runGeneratedRustTest(t, code, `mod registers;
+
+use registers::*;
+
+fn main() {
+    let mut cfg = Config { mode: Mode::STANDBY.into(), level: 42, name: "abc".to_string() };
+    assert_eq!(cfg.to_bytes().unwrap(), b"\x02\x2a\x03abc");
+    assert_eq!(Config::from_bytes(&cfg.to_bytes().unwrap()).unwrap(), cfg);
+    assert_eq!(Mode::try_from(cfg.mode), Ok(Mode::STANDBY));
+    assert_eq!(Mode::try_from(7), Err(7));
+    cfg.level = Config::maxLevel + 1;
+    assert_eq!(cfg.to_bytes(), Err(Error::OutOfRange { field: "level" }));
+    assert_eq!(Config::from_bytes(b"\x02\x2a\x03ab"), Err(Error::BufferTooSmall { need: 3, have: 2 }));
+    assert_eq!(Config::from_bytes(b"\x02\x2a\x00\x00"), Err(Error::TrailingBytes { count: 1 }));
+    assert_eq!(
+        Config::from_bytes(b"\x02\x2a\x01\xff").unwrap_err().to_string(),
+        "name is not a valid UTF-8 string"
+    );
+
+    let mut st = Status { counter: -5, ..Default::default() };
+    st.set_flags_ready(true);
+    st.set_flags_error(5);
+    st.set_flags_count(2);
+    st.set_temp_value(1.5);
+    assert_eq!(st.temp, 24);
+    assert_eq!(
+        st.to_bytes(),
+        Err(Error::ArrayLengthMismatch { field: "samples", len: 0, expected: 2 })
+    );
+    st.samples = vec![0x0102, -2];
+    let data = st.to_bytes().unwrap();
+    assert_eq!(data, b"\xff\xff\xff\xfb\x2b\x00\x18\x02\x01\xfe\xff");
+    let st2 = Status::from_bytes(&data).unwrap();
+    assert_eq!(st2, st);
+    assert!(st2.flags_ready() && st2.flags_error() == 5 && st2.temp_value() == 1.5);
+    assert!(st.to_bytes_write().unwrap().is_empty());
+
+    let mut df = DataFrame::default();
+    df.points[0] = Point { x: -2, y: 0.5 };
+    df.points[1].x = 0x123456;
+    df.matrix[1][2] = 7;
+    let mut data = df.to_bytes().unwrap();
+    assert_eq!(data.len(), 22);
+    assert_eq!(&data[..10], b"\xff\xff\xfe\x3f\x00\x00\x00\x12\x34\x56");
+    assert_eq!(data[19], 7);
+    assert_eq!(DataFrame::from_bytes(&data).unwrap(), df);
+    data[0] = 0;
+    assert!(matches!(DataFrame::from_bytes(&data), Err(Error::ChecksumMismatch { field: "crc", .. })));
+}
+`)
+}
+
+func TestGeneratedRustArrays(t *testing.T) {
+	device, err := parser.Parse(`
+    // the test device
+    device test @le
+
+    register Empty(1) {
+        reserved [2]uint8;
+    };
+
+    register Rows(2) {
+        count uint8;
+        rows [count][2]uint16;
+        values [2]float64 @be;
+        ids [count]int24;
+        flags uint16{lo: signed 0-3, hi: 4-7};
+        crc crc32;
+    };
+
+    register Keys(3): w {
+        sync = 0xAA55 int16 @be;
+        type uint8;
+        temp float32 [-40..85];
+        crc crc16(modbus);
+    };
+
+    register Wrapper(4) {
+        n uint8;
+        keys [n]Keys;
+        rows:r Rows;
+    };`)
+	require.NoError(t, err)
+	code, err := GenerateRust(device)
+	require.NoError(t, err)
+	require.Contains(t, code, "    pub r#type: u8,\n")
+	require.Contains(t, code, "//! the test device\n")
+	require.Contains(t, code, "fn crc32_ieee(data: &[u8]) -> u32 {")
+	require.NotContains(t, code, "fn crc16_ccitt")
+
+	runGeneratedRustTest(t, code, `mod registers;
+
+use registers::*;
+
+fn main() {
+    assert_eq!(Empty::default().to_bytes().unwrap(), b"\x00\x00");
+    assert_eq!(Empty::from_bytes(b"\x01\x02").unwrap(), Empty::default());
+
+    let mut r = Rows { count: 2, rows: vec![[1, 2], [3, 0x0405]], values: [0.0, -1.25], ids: vec![-1, 0x10203], ..Default::default() };
+    r.set_flags_lo(-3);
+    r.set_flags_hi(9);
+    assert!(r.flags_lo() == -3 && r.flags_hi() == 9 && r.flags == 0x9D);
+    let data = r.to_bytes().unwrap();
+    assert_eq!(data.len(), 37);
+    assert_eq!(&data[..9], b"\x02\x01\x00\x02\x00\x03\x00\x05\x04");
+    assert!(data[17] == 0xbf && data[18] == 0xf4);
+    assert_eq!(&data[25..31], b"\xff\xff\xff\x03\x02\x01");
+    assert_eq!(Rows::from_bytes(&data).unwrap(), r);
+
+    let k = Keys { r#type: 7, temp: 85.0 };
+    let data = k.to_bytes().unwrap();
+    assert_eq!(&data[..3], b"\xaa\x55\x07");
+    assert_eq!(Keys::from_bytes(&data).unwrap(), k);
+    let mut wrong = data.clone();
+    wrong[0] = 0;
+    assert_eq!(Keys::from_bytes(&wrong), Err(Error::MagicMismatch { field: "sync", received: 0x55 }));
+    assert_eq!(Keys::from_bytes_read(b"").unwrap(), Keys::default());
+    assert_eq!(Keys { temp: 85.5, ..Default::default() }.to_bytes(), Err(Error::OutOfRange { field: "temp" }));
+
+    let w = Wrapper { n: 2, keys: vec![k.clone(), Keys::default()], rows: r.clone() };
+    let data = w.to_bytes().unwrap();
+    assert_eq!(data.len(), 1 + 2 * 9);
+    assert_eq!(Wrapper::from_bytes(&data).unwrap(), Wrapper { rows: Rows::default(), ..w.clone() });
+    let data = w.to_bytes_read().unwrap();
+    assert_eq!(data.len(), 1 + 37);
+    let w2 = Wrapper::from_bytes_read(&data).unwrap();
+    assert!(w2.keys.iter().all(|k| *k == Keys::default()) && w2.rows == r);
+}
+`)
+}
+
+func TestGeneratedRustFieldOffset(t *testing.T) {
+	device, err := parser.Parse(`
+    device test
+
+    register Map(1) {
+        id uint8;
+        status:r uint16 @offset 4;
+        mode:w uint8 @offset 8;
+        value uint16 @offset 10 @le;
+        count uint8;
+        data [count]uint8;
+    };`)
+	require.NoError(t, err)
+	code, err := GenerateRust(device)
+	require.NoError(t, err)
+
+	runGeneratedRustTest(t, code, `mod registers;
+
+use registers::*;
+
+fn main() {
+    let m = Map { id: 1, status: 0x0203, mode: 5, value: 0x0607, count: 1, data: vec![9] };
+    assert_eq!(m.to_bytes_read().unwrap(), b"\x01\x00\x00\x00\x02\x03\x00\x00\x00\x00\x07\x06\x01\x09");
+    let data = m.to_bytes().unwrap();
+    assert_eq!(data, b"\x01\x00\x00\x00\x00\x00\x00\x00\x05\x00\x07\x06\x01\x09");
+    let d = Map::from_bytes(b"\x01\xff\xff\xff\xff\xff\xff\xff\x05\xff\x07\x06\x01\x09").unwrap();
+    assert_eq!(d, Map { status: 0, ..m.clone() });
+    assert!(matches!(Map::from_bytes(&data[..5]), Err(Error::BufferTooSmall { .. })));
+}
+`)
+}
+
+func TestGeneratedRustFloat16(t *testing.T) {
+	device, err := parser.Parse(`
+    device test
+
+    register Half(1) {
+        zero float16;
+        tiny float16;
+        inf float16;
+        value float16 @le;
+        values [2]float16;
+        count uint8;
+        items [count]float16;
+    };`)
+	require.NoError(t, err)
+	code, err := GenerateRust(device)
+	require.NoError(t, err)
+	require.Contains(t, code, "pub values: [f32; 2],")
+
+	runGeneratedRustTest(t, code, `mod registers;
+
+use registers::*;
+
+fn main() {
+    // the tie 1+2^-11 rounds to the even 1 and 1e-8 is below the half of the smallest subnormal
+    let tiny = f32::from_bits(0x3380_0000);
+    let mut h = Half {
+        zero: 0.0,
+        tiny,
+        inf: f32::INFINITY,
+        value: -1.5,
+        values: [65504.0, 0.5],
+        count: 2,
+        items: vec![1.00048828125, 1e-8],
+    };
+    let data = h.to_bytes().unwrap();
+    assert_eq!(data, b"\x00\x00\x00\x01\x7c\x00\x00\xbe\x7b\xff\x38\x00\x02\x3c\x00\x00\x00");
+    let d = Half::from_bytes(&data).unwrap();
+    assert_eq!(d, Half { items: vec![1.0, 0.0], ..h.clone() });
+
+    // the values too large for the half precision are infinities, NaN stays NaN
+    h.zero = 1e6;
+    h.tiny = f32::NAN;
+    let data = h.to_bytes().unwrap();
+    assert_eq!(&data[..4], b"\x7c\x00\x7e\x00");
+    let d = Half::from_bytes(&data).unwrap();
+    assert!(d.zero.is_infinite() && d.tiny.is_nan());
+}
+`)
+}
+
+func TestGeneratedRustDefaults(t *testing.T) {
+	device, err := parser.Parse(`
+    device test
+
+    enum Mode uint8 { OFF = 0, ON = 1 };
+
+    register Config(1) {
+        level int16 [-100..100] = -5;
+        size uint8;
+    };
+
+    register Control(2) {
+        mode Mode = 1;
+        flags uint8{ready: 0, busy: 1} = 0b10;
+        big uint64 = 0xFFFFFFFFFFFFFFFF;
+        plain uint8;
+        config Config;
+        configs [2]Config;
+    };`)
+	require.NoError(t, err)
+	code, err := GenerateRust(device)
+	require.NoError(t, err)
+	require.Contains(t, code, "            big: 18446744073709551615,\n")
+
+	runGeneratedRustTest(t, code, `mod registers;
+
+use registers::*;
+
+fn main() {
+    let c = Control::default();
+    assert_eq!((c.mode, c.flags, c.big, c.plain), (1, 2, u64::MAX, 0));
+    assert_eq!((c.config.level, c.configs[0].level, c.configs[1].level), (-5, -5, -5));
+}
+`)
+}
+
+func TestGeneratedRustConstantLiterals(t *testing.T) {
+	device, err := parser.Parse(`
+    device test
+
+    const Hex = uint8(0XFF);
+    const Bin = uint8(0B1010);
+    enum Level int8 { LOW = -0B11, HIGH = 0X3 };
+
+    register Control(1) {
+        const Mask = uint16(0XF0F0);
+        level Level;
+        items [0X2][0B10]uint8;
+    };`)
+	require.NoError(t, err)
+	code, err := GenerateRust(device)
+	require.NoError(t, err)
+	require.NotContains(t, code, "0X")
+	require.NotContains(t, code, "0B")
+
+	runGeneratedRustTest(t, code, `mod registers;
+
+use registers::*;
+
+fn main() {
+    assert_eq!((Hex, Bin, Control::Mask), (255, 10, 0xf0f0));
+    assert_eq!((Level::LOW as i8, Level::HIGH as i8), (-3, 3));
+    assert_eq!(Level::try_from(-3i8), Ok(Level::LOW));
+    let mut c = Control::default();
+    c.items = [[1, 2], [3, 4]];
+    assert_eq!(Control::from_bytes(&c.to_bytes().unwrap()).unwrap(), c);
+}
+`)
+}
+
+func TestGeneratedRustConditions(t *testing.T) {
+	device, err := parser.Parse(`
+    device test
+
+    register Status(1) {
+        flags uint8{has_extra: 0};
+        extra uint16 [1..1000] if flags_has_extra;
+        more uint8;
+        count uint8 if more;
+        items [count]uint8 if more;
+    };`)
+	require.NoError(t, err)
+	code, err := GenerateRust(device)
+	require.NoError(t, err)
+
+	runGeneratedRustTest(t, code, `mod registers;
+
+use registers::*;
+
+fn main() {
+    let s = Status { extra: 5000, count: 2, items: vec![8], ..Default::default() };
+    assert_eq!(s.to_bytes().unwrap(), b"\x00\x00");
+    assert_eq!(Status::from_bytes(b"\x00\x00").unwrap(), Status::default());
+
+    let mut s = Status { flags: 1, extra: 0x0102, more: 1, count: 2, items: vec![8, 9] };
+    let data = s.to_bytes().unwrap();
+    assert_eq!(data, b"\x01\x01\x02\x01\x02\x08\x09");
+    assert_eq!(Status::from_bytes(&data).unwrap(), s);
+    s.extra = 5000;
+    assert!(matches!(s.to_bytes(), Err(Error::OutOfRange { .. })));
+}
+`)
+}
+
+func TestGeneratedRustRegisterVersion(t *testing.T) {
+	device, err := parser.Parse(`
+    device test
+
+    register Control(1) version 2 {
+        mode uint8;
+        crc crc16;
+    };
+    register Status(2):r version 7 {
+        value uint16;
+    };`)
+	require.NoError(t, err)
+	code, err := GenerateRust(device)
+	require.NoError(t, err)
+
+	runGeneratedRustTest(t, code, `mod registers;
+
+use registers::*;
+
+fn main() {
+    assert_eq!(Control::VERSION, 2);
+    assert_eq!(Status::VERSION, 7);
+    let c = Control { mode: 5 };
+    let data = c.to_bytes().unwrap();
+    assert_eq!(data.len(), 4);
+    assert_eq!(&data[..2], b"\x02\x05");
+    assert_eq!(Control::from_bytes(&data).unwrap(), c);
+    assert_eq!(Control::from_bytes_read(&c.to_bytes_read().unwrap()).unwrap(), c);
+    let mut other = data.clone();
+    other[0] = 3;
+    assert!(matches!(Control::from_bytes(&other), Err(Error::VersionMismatch { received: 3, expected: 2, .. })));
+
+    let s = Status { value: 0x0102 };
+    assert_eq!(s.to_bytes().unwrap(), b"\x07\x01\x02");
+    assert_eq!(Status::from_bytes(b"\x07\x01\x02").unwrap(), s);
+    assert!(s.to_bytes_write().unwrap().is_empty());
+    assert!(matches!(Status::from_bytes(b"\x06\x01\x02"), Err(Error::VersionMismatch { received: 6, .. })));
+}
+`)
+}
@@ -0,0 +1,190 @@
+package generator
+
+import (
+	"fmt"
+	"math"
+	"math/bits"
+	"strconv"
+	"strings"
+
+	"github.com/dspasibenko/pargus/pkg/parser"
+)
+
+// RegisterStats is the number of bytes the register takes on the wire in the read and the
+// write directions
+type RegisterStats struct {
+	Name  string
+	ID    uint8
+	Read  WireSize
+	Write WireSize
+}
+
+// WireSize is the number of bytes the register takes on the wire in one direction
+type WireSize struct {
+	Const    int      // bytes sent regardless of the field values, the constant part of BufSize4Read or BufSize4Write
+	Variable []string // terms of the size depending on the field values, e.g. count*2 or len(name)
+	Max      uint64   // worst case size: the size fields have their maximum values, the strings the maximum length and the conditional fields are sent
+}
+
+// Formula returns the variable size terms joined by +, it is empty if the size is constant
+func (ws WireSize) Formula() string {
+	return strings.Join(ws.Variable, " + ")
+}
+
+// Stats returns the wire sizes of the device registers in the declaration order. The constant
+// and the variable parts are the ones the generated Go code sums in BufSize4Read and
+// BufSize4Write: the strings, the variable-length arrays, the register fields and the
+// conditional fields are variable, the rest of the fields are constant
+func Stats(dev *parser.Device) ([]RegisterStats, error) {
+	gd, err := newGoDevice(dev, "stats", GoOptions{})
+	if err != nil {
+		return nil, err
+	}
+	// the Go registers are in the declaration order
+	sc := statsCalc{dev: dev, goRegs: map[*parser.Register]*GoRegister{}, regs: map[*parser.Register]*RegisterStats{}}
+	for i, reg := range dev.Registers {
+		sc.goRegs[reg] = &gd.Registers[i]
+	}
+	res := make([]RegisterStats, 0, len(dev.Registers))
+	for _, reg := range dev.Registers {
+		res = append(res, *sc.register(reg))
+	}
+	return res, nil
+}
+
+// statsCalc calculates the register wire sizes, the sizes of the referenced registers are
+// calculated once
+type statsCalc struct {
+	dev    *parser.Device
+	goRegs map[*parser.Register]*GoRegister
+	regs   map[*parser.Register]*RegisterStats
+}
+
+func (sc *statsCalc) register(reg *parser.Register) *RegisterStats {
+	if rs, ok := sc.regs[reg]; ok {
+		return rs
+	}
+	gr := sc.goRegs[reg]
+	rs := &RegisterStats{
+		Name:  reg.Name,
+		ID:    gr.ID,
+		Read:  WireSize{Const: gr.BufSize4ReadConst, Max: uint64(gr.BufSize4ReadConst)},
+		Write: WireSize{Const: gr.BufSize4WriteConst, Max: uint64(gr.BufSize4WriteConst)},
+	}
+	for i, f := range reg.Body.Fields() {
+		if gr.Fields[i].BufSize4ReadExpr != "" {
+			sc.addField(&rs.Read, reg, f, i, true)
+		}
+		if gr.Fields[i].BufSize4WriteExpr != "" {
+			sc.addField(&rs.Write, reg, f, i, false)
+		}
+	}
+	sc.regs[reg] = rs
+	return rs
+}
+
+// addField adds the size of the variable field to ws, the conditional field is counted
+// with its constant part, which is sent only if the condition is set
+func (sc *statsCalc) addField(ws *WireSize, reg *parser.Register, f *parser.Field, idx int, read bool) {
+	term, size := sc.variableSize(reg, f, idx, read)
+	if f.HasCondition() {
+		fixed := statsFixedSize(f)
+		term = fmt.Sprintf("(%s ? %s : 0)", *f.Condition, sizeSum(fixed, term))
+		size = satAdd(size, uint64(fixed))
+	}
+	ws.Variable = append(ws.Variable, term)
+	ws.Max = satAdd(ws.Max, size)
+}
+
+// variableSize returns the term and the worst case of the field size depending on its value,
+// the term is empty if the field size is constant
+func (sc *statsCalc) variableSize(reg *parser.Register, f *parser.Field, idx int, read bool) (string, uint64) {
+	t := f.Type
+	switch {
+	case t.Simple != nil && t.Simple.IsRegisterRef():
+		return fmt.Sprintf("size(%s)", f.Name), sc.refSize(t.Simple.Name, read)
+
+	case t.Array != nil && t.Array.Type.IsRegisterRef():
+		count := uint64(0)
+		if t.Array.Size.Constant != nil {
+			count, _ = strconv.ParseUint(*t.Array.Size.Constant, 0, 64)
+		} else {
+			count = sizeFieldMax(reg, *t.Array.Size.Variable, idx)
+		}
+		return fmt.Sprintf("size(%s)", f.Name), satMul(count, sc.refSize(t.Array.Type.Name, read))
+
+	case t.Array != nil && t.Array.Size.Variable != nil:
+		itemSize := wireTypeSize(t.Array.Type.Name) * t.Array.InnerCount()
+		count := sizeFieldMax(reg, *t.Array.Size.Variable, idx)
+		return fmt.Sprintf("%s*%d", *t.Array.Size.Variable, itemSize), satMul(count, uint64(itemSize))
+
+	case t.String != nil:
+		return fmt.Sprintf("len(%s)", f.Name), uint64(t.String.MaxLen())
+
+	default:
+		return "", 0
+	}
+}
+
+// refSize returns the worst case size of the referenced register in the direction
+func (sc *statsCalc) refSize(name string, read bool) uint64 {
+	rs := sc.register(sc.dev.FindRegisterByName(name))
+	if read {
+		return rs.Read.Max
+	}
+	return rs.Write.Max
+}
+
+// statsFixedSize returns the number of bytes the conditional field sends regardless of its
+// value, e.g. the length prefix of the string
+func statsFixedSize(f *parser.Field) int {
+	t := f.Type
+	switch {
+	case t.Simple != nil && t.Simple.IsRegisterRef(), t.Array != nil && t.Array.Type.IsRegisterRef():
+		return 0
+	case t.Simple != nil && t.Simple.IsEnum():
+		return wireTypeSize(t.Simple.Enum.Base)
+	case t.Bitfield != nil:
+		return wireTypeSize(t.Bitfield.Base)
+	case t.Array != nil && t.Array.Size.Constant != nil:
+		return reservedSize(f)
+	case t.Array != nil:
+		return 0
+	case t.String != nil:
+		return wireTypeSize(t.String.PrefixType())
+	default:
+		return wireTypeSize(scalarTypeName(f))
+	}
+}
+
+// sizeFieldMax returns the maximum value of the variable array size field: the upper bound of
+// its range or the maximum value of its type
+func sizeFieldMax(reg *parser.Register, name string, idx int) uint64 {
+	fld, bm := reg.FindFieldByName(name, idx)
+	switch {
+	case bm != nil:
+		return bitMask(bm.StartBit(), bm.EndBit()) >> bm.StartBit()
+	case fld.HasRange():
+		return uint64(fld.Max())
+	default:
+		return math.MaxUint64 >> (64 - 8*wireTypeSize(fld.Type.Simple.Name))
+	}
+}
+
+// satAdd returns a+b, it is the maximum uint64 value if the sum overflows
+func satAdd(a, b uint64) uint64 {
+	sum, carry := bits.Add64(a, b, 0)
+	if carry != 0 {
+		return math.MaxUint64
+	}
+	return sum
+}
+
+// satMul returns a*b, it is the maximum uint64 value if the product overflows
+func satMul(a, b uint64) uint64 {
+	hi, lo := bits.Mul64(a, b)
+	if hi != 0 {
+		return math.MaxUint64
+	}
+	return lo
+}
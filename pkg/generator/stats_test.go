@@ -0,0 +1,73 @@
+package generator
+
+import (
+	"math"
+	"os"
+	"testing"
+
+	"github.com/dspasibenko/pargus/pkg/parser"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStatsExample(t *testing.T) {
+	input, err := os.ReadFile("testdata/example.pa")
+	require.NoError(t, err)
+	device, err := parser.Parse(string(input))
+	require.NoError(t, err)
+
+	stats, err := Stats(device)
+	require.NoError(t, err)
+	require.Equal(t, []RegisterStats{
+		{Name: "Config", ID: 0,
+			Read:  WireSize{Const: 3, Variable: []string{"len(name)"}, Max: 19},
+			Write: WireSize{Const: 3, Variable: []string{"len(name)"}, Max: 19}},
+		{Name: "Status", ID: 1,
+			Read:  WireSize{Const: 7, Variable: []string{"flags_count*2"}, Max: 37},
+			Write: WireSize{}},
+		{Name: "Point", ID: 2,
+			Read:  WireSize{Const: 7, Max: 7},
+			Write: WireSize{Const: 7, Max: 7}},
+		{Name: "DataFrame", ID: 3,
+			Read:  WireSize{Const: 8, Variable: []string{"size(points)"}, Max: 22},
+			Write: WireSize{Const: 8, Variable: []string{"size(points)"}, Max: 22}},
+	}, stats)
+}
+
+func TestStatsVariableFields(t *testing.T) {
+	device, err := parser.Parse(`device my-dev
+
+register Item(5) {
+    n uint8 [0..4];
+    v [n][2]uint16;
+};
+
+register Frame(6) {
+    flags uint8{has_name: 0, count: 1-3};
+    name string(uint8, 10) if flags_has_name;
+    extra uint32 if flags_has_name;
+    items [flags_count]Item;
+    one:r Item;
+    len uint16;
+    data [len]uint8;
+    total uint64;
+    all:w [total]uint32;
+};
+`)
+	require.NoError(t, err)
+
+	stats, err := Stats(device)
+	require.NoError(t, err)
+	require.Len(t, stats, 2)
+	require.Equal(t, WireSize{Const: 1, Variable: []string{"n*4"}, Max: 17}, stats[0].Read)
+
+	// the conditional fields are sent in the worst case, the arrays have the maximum size field values
+	read := stats[1].Read
+	require.Equal(t, 11, read.Const)
+	require.Equal(t, "(flags_has_name ? 1 + len(name) : 0) + (flags_has_name ? 4 : 0) + size(items) + size(one) + len*1", read.Formula())
+	require.Equal(t, uint64(11+11+4+7*17+17+65535), read.Max)
+
+	// the array sized by the uint64 field saturates the worst case
+	write := stats[1].Write
+	require.Equal(t, "(flags_has_name ? 1 + len(name) : 0) + (flags_has_name ? 4 : 0) + size(items) + len*1 + total*4", write.Formula())
+	require.Equal(t, uint64(math.MaxUint64), write.Max)
+}
@@ -0,0 +1,1147 @@
+package generator
+
+import (
+	"bytes"
+	"fmt"
+	"strconv"
+	"strings"
+	"text/template"
+
+	"github.com/dspasibenko/pargus/pkg/parser"
+)
+
+//
+// TypeScript template
+//
+
+const tsTemplate = `
+// This is auto-generated file. DO NOT EDIT. Use pargus compiler to regenerate it.
+{{- range .Doc}}
+{{.}}
+{{- end}}
+{{- range .Constants}}
+{{range .Doc}}
+{{.}}
+{{- end}}
+export const {{.Name}} = {{.Value}};
+{{- end}}
+
+/** Writer appends the encoded values to the buffer growing as needed */
+class _Writer {
+    private buf = new Uint8Array(64);
+    private view = new DataView(this.buf.buffer);
+    length = 0;
+
+    /** Returns the copy of the bytes written after the start offset */
+    bytes(start = 0): Uint8Array {
+        return this.buf.slice(start, this.length);
+    }
+
+    /** Returns the offset of the n zero bytes appended to the buffer */
+    private alloc(n: number): number {
+        const offset = this.length;
+        if (offset + n > this.buf.length) {
+            const buf = new Uint8Array(Math.max(2 * this.buf.length, offset + n));
+            buf.set(this.buf);
+            this.buf = buf;
+            this.view = new DataView(buf.buffer);
+        }
+        this.length += n;
+        return offset;
+    }
+
+    zeros(n: number): void {
+        this.alloc(n);
+    }
+
+    raw(b: Uint8Array): void {
+        const offset = this.alloc(b.length);
+        this.buf.set(b, offset);
+    }
+
+    int8(v: number): void {
+        const offset = this.alloc(1);
+        this.view.setInt8(offset, v);
+    }
+
+    uint8(v: number): void {
+        const offset = this.alloc(1);
+        this.view.setUint8(offset, v);
+    }
+
+    int16(v: number, le = false): void {
+        const offset = this.alloc(2);
+        this.view.setInt16(offset, v, le);
+    }
+
+    uint16(v: number, le = false): void {
+        const offset = this.alloc(2);
+        this.view.setUint16(offset, v, le);
+    }
+
+    int24(v: number, le = false): void {
+        this.uint24(v & 0xffffff, le);
+    }
+
+    uint24(v: number, le = false): void {
+        const offset = this.alloc(3);
+        if (le) {
+            this.view.setUint16(offset, v & 0xffff, true);
+            this.view.setUint8(offset + 2, v >>> 16);
+        } else {
+            this.view.setUint8(offset, v >>> 16);
+            this.view.setUint16(offset + 1, v & 0xffff);
+        }
+    }
+
+    int32(v: number, le = false): void {
+        const offset = this.alloc(4);
+        this.view.setInt32(offset, v, le);
+    }
+
+    uint32(v: number, le = false): void {
+        const offset = this.alloc(4);
+        this.view.setUint32(offset, v, le);
+    }
+
+    int64(v: bigint, le = false): void {
+        const offset = this.alloc(8);
+        this.view.setBigInt64(offset, v, le);
+    }
+
+    uint64(v: bigint, le = false): void {
+        const offset = this.alloc(8);
+        this.view.setBigUint64(offset, v, le);
+    }
+{{- if .Float16}}
+
+    float16(v: number, le = false): void {
+        this.uint16(_float16Bits(v), le);
+    }
+{{- end}}
+
+    float32(v: number, le = false): void {
+        const offset = this.alloc(4);
+        this.view.setFloat32(offset, v, le);
+    }
+
+    float64(v: number, le = false): void {
+        const offset = this.alloc(8);
+        this.view.setFloat64(offset, v, le);
+    }
+}
+
+/** Reader decodes the values of the data one by one */
+class _Reader {
+    readonly buf: Uint8Array;
+    private view: DataView;
+    offset = 0;
+
+    constructor(buf: Uint8Array) {
+        this.buf = buf;
+        this.view = new DataView(buf.buffer, buf.byteOffset, buf.byteLength);
+    }
+
+    /** Returns the offset of the n next bytes, throws RangeError if the data is too short */
+    take(n: number): number {
+        if (this.buf.length - this.offset < n) {
+            throw new RangeError(` + "`" + `the data is too short, ${n} bytes are expected at offset ${this.offset}` + "`" + `);
+        }
+        const offset = this.offset;
+        this.offset += n;
+        return offset;
+    }
+
+    raw(n: number): Uint8Array {
+        const offset = this.take(n);
+        return this.buf.subarray(offset, offset + n);
+    }
+
+    int8(): number {
+        return this.view.getInt8(this.take(1));
+    }
+
+    uint8(): number {
+        return this.view.getUint8(this.take(1));
+    }
+
+    int16(le = false): number {
+        return this.view.getInt16(this.take(2), le);
+    }
+
+    uint16(le = false): number {
+        return this.view.getUint16(this.take(2), le);
+    }
+
+    int24(le = false): number {
+        return (this.uint24(le) << 8) >> 8;
+    }
+
+    uint24(le = false): number {
+        const offset = this.take(3);
+        if (le) {
+            return this.view.getUint16(offset, true) | (this.view.getUint8(offset + 2) << 16);
+        }
+        return (this.view.getUint8(offset) << 16) | this.view.getUint16(offset + 1);
+    }
+
+    int32(le = false): number {
+        return this.view.getInt32(this.take(4), le);
+    }
+
+    uint32(le = false): number {
+        return this.view.getUint32(this.take(4), le);
+    }
+
+    int64(le = false): bigint {
+        return this.view.getBigInt64(this.take(8), le);
+    }
+
+    uint64(le = false): bigint {
+        return this.view.getBigUint64(this.take(8), le);
+    }
+{{- if .Float16}}
+
+    float16(le = false): number {
+        return _float16FromBits(this.uint16(le));
+    }
+{{- end}}
+
+    float32(le = false): number {
+        return this.view.getFloat32(this.take(4), le);
+    }
+
+    float64(le = false): number {
+        return this.view.getFloat64(this.take(8), le);
+    }
+}
+{{- if .Strings}}
+
+const _encoder = new TextEncoder();
+const _decoder = new TextDecoder("utf-8", { fatal: true });
+{{- end}}
+{{- if .Fixed}}
+
+/** Rounds v half away from zero */
+function _round(v: number): number {
+    return v >= 0 ? Math.floor(v + 0.5) : -Math.floor(-v + 0.5);
+}
+{{- end}}
+{{- if .MultiDim}}
+
+/** Returns true if every nested array of rows has the dims sizes */
+function _hasShape(rows: unknown[], dims: number[]): boolean {
+    return rows.every((row) => Array.isArray(row) && row.length === dims[0] && (dims.length === 1 || _hasShape(row, dims.slice(1))));
+}
+
+/** Splits the items into the nested arrays of the dims sizes */
+function _reshape(items: unknown[], dims: number[]): any {
+    for (const d of dims.slice().reverse()) {
+        const rows: unknown[] = [];
+        for (let i = 0; i < items.length; i += d) {
+            rows.push(items.slice(i, i + d));
+        }
+        items = rows;
+    }
+    return items;
+}
+{{- end}}
+{{- if .Float16}}
+
+const _float32 = new DataView(new ArrayBuffer(4));
+
+/**
+ * Returns the IEEE 754 half-precision bits of v rounded to the nearest even, the values too
+ * large for the half precision become infinities and the too small ones zeros
+ */
+function _float16Bits(v: number): number {
+    _float32.setFloat32(0, v);
+    const bits = _float32.getUint32(0);
+    const sign = (bits >>> 16) & 0x8000;
+    let exp = ((bits >>> 23) & 0xff) - 127 + 15;
+    let mant = bits & 0x7fffff;
+    if ((bits & 0x7fffffff) > 0x7f800000) {
+        return sign | 0x7e00;
+    }
+    if (exp >= 0x1f) {
+        return sign | 0x7c00;
+    }
+    let shift = 13;
+    if (exp <= 0) {
+        if (exp < -10) {
+            return sign;
+        }
+        // the subnormal keeps the implicit leading bit in the mantissa
+        mant |= 0x800000;
+        shift = 14 - exp;
+        exp = 0;
+    }
+    // the rounding carry may overflow the mantissa to the exponent, which is still correct
+    let h = (exp << 10) + (mant >>> shift);
+    const rem = mant & ((1 << shift) - 1);
+    const half = 1 << (shift - 1);
+    if (rem > half || (rem === half && (h & 1) === 1)) {
+        h++;
+    }
+    return sign | h;
+}
+
+/** Returns the value of the IEEE 754 half-precision bits */
+function _float16FromBits(h: number): number {
+    const sign = h & 0x8000 ? -1 : 1;
+    const exp = (h >>> 10) & 0x1f;
+    const mant = h & 0x3ff;
+    if (exp === 0) {
+        return sign * mant * 2 ** -24;
+    }
+    if (exp === 0x1f) {
+        return mant !== 0 ? NaN : sign * Infinity;
+    }
+    return sign * (1 + mant / 1024) * 2 ** (exp - 15);
+}
+{{- end}}
+{{- if index .CRCs "ccitt"}}
+
+/** Returns the CRC-16/CCITT-FALSE checksum of data */
+function _crc16Ccitt(data: Uint8Array): number {
+    let crc = 0xffff;
+    for (const b of data) {
+        crc ^= b << 8;
+        for (let i = 0; i < 8; i++) {
+            crc = crc & 0x8000 ? ((crc << 1) ^ 0x1021) & 0xffff : (crc << 1) & 0xffff;
+        }
+    }
+    return crc;
+}
+{{- end}}
+{{- if index .CRCs "modbus"}}
+
+/** Returns the CRC-16/MODBUS checksum of data */
+function _crc16Modbus(data: Uint8Array): number {
+    let crc = 0xffff;
+    for (const b of data) {
+        crc ^= b;
+        for (let i = 0; i < 8; i++) {
+            crc = crc & 1 ? (crc >>> 1) ^ 0xa001 : crc >>> 1;
+        }
+    }
+    return crc;
+}
+{{- end}}
+{{- if index .CRCs "ieee"}}
+
+/** Returns the CRC-32/IEEE checksum of data */
+function _crc32Ieee(data: Uint8Array): number {
+    let crc = 0xffffffff;
+    for (const b of data) {
+        crc ^= b;
+        for (let i = 0; i < 8; i++) {
+            crc = crc & 1 ? (crc >>> 1) ^ 0xedb88320 : crc >>> 1;
+        }
+    }
+    return (crc ^ 0xffffffff) >>> 0;
+}
+{{- end}}
+{{- range .Enums}}
+{{range .Doc}}
+{{.}}
+{{- end}}
+export const {{.Name}} = {
+{{- range .Members}}
+{{- range .Doc}}
+{{if .}}    {{.}}{{end}}
+{{- end}}
+    {{.Name}}: {{.Value}},
+{{- end}}
+} as const;
+export type {{.Name}} = (typeof {{.Name}})[keyof typeof {{.Name}}];
+{{- end}}
+{{- range .Registers}}
+{{- $reg := .}}
+{{range .Doc}}
+{{.}}
+{{- end}}
+export interface {{.Name}} {
+{{- range .Fields}}{{if .Decl}}
+{{- range .Doc}}
+{{if .}}    {{.}}{{end}}
+{{- end}}
+    {{.Decl}}{{if .Trailing}} {{.Trailing}}{{end}}
+{{- end}}{{end}}
+}
+
+export const {{.Name}}_ID = {{.Number}};
+{{- if .HasVersion}}
+export const {{.Name}}_VERSION = {{.Version}};
+{{- end}}
+{{- range .Constants}}
+{{- range .Doc}}
+{{.}}
+{{- end}}
+export const {{$reg.Name}}_{{.Name}} = {{.Value}};
+{{- end}}
+
+/** Returns the {{.Name}} register with the zero fields */
+export function new{{.Name}}(): {{.Name}} {
+    return {
+{{- range .Fields}}{{if .Init}}
+        {{.Init}},
+{{- end}}{{end}}
+    };
+}
+{{- range .Fields}}{{range .Accessors}}
+
+{{.}}
+{{- end}}{{end}}
+
+/** Throws RangeError if an array length differs from its size, a string is too long or a field is out of its range */
+export function check{{.Name}}(c: {{.Name}}): void {
+{{- range .Fields}}{{range .Checks}}
+    {{.}}
+{{- end}}{{end}}
+}
+
+/** Encodes the {{.Dir}} fields */
+export function encode{{.Name}}(c: {{.Name}}): Uint8Array {
+    return encode{{.Name}}{{.DirTitle}}(c);
+}
+
+/** Decodes the {{.Dir}} fields, the data must hold exactly one register */
+export function decode{{.Name}}(data: Uint8Array): {{.Name}} {
+    return decode{{.Name}}{{.DirTitle}}(data);
+}
+{{- range .Dirs}}
+
+/** Encodes the {{.Name}} fields */
+export function encode{{$reg.Name}}{{.Title}}(c: {{$reg.Name}}): Uint8Array {
+    const w = new _Writer();
+    write{{$reg.Name}}{{.Title}}(w, c);
+    return w.bytes();
+}
+
+/** Decodes the {{.Name}} fields, the data must hold exactly one register */
+export function decode{{$reg.Name}}{{.Title}}(data: Uint8Array): {{$reg.Name}} {
+    const r = new _Reader(data);
+    const c = read{{$reg.Name}}{{.Title}}(r);
+    if (r.offset !== data.length) {
+        throw new RangeError(` + "`" + `${data.length - r.offset} trailing bytes after the {{$reg.Name}} register` + "`" + `);
+    }
+    return c;
+}
+
+function write{{$reg.Name}}{{.Title}}(w: _Writer, c: {{$reg.Name}}): void {
+    check{{$reg.Name}}(c);
+{{- if $reg.HasCRC}}
+    const start = w.length;
+{{- end}}
+{{- range .Pack}}
+    {{.}}
+{{- end}}
+}
+
+function read{{$reg.Name}}{{.Title}}(r: _Reader): {{$reg.Name}} {
+    const c = new{{$reg.Name}}();
+{{- if $reg.HasCRC}}
+    const start = r.offset;
+{{- end}}
+{{- range .Unpack}}
+    {{.}}
+{{- end}}
+    return c;
+}
+{{- end}}
+{{- end}}
+`
+
+var tsTpl = template.Must(template.New("ts").Parse(tsTemplate))
+
+//
+// Intermediate representation for template
+//
+
+type TSDevice struct {
+	Doc       []string
+	Constants []TSConstant
+	Enums     []TSEnum
+	Registers []TSRegister
+	Fixed     bool            // true if any field is a fixed-point number
+	MultiDim  bool            // true if any field is a multi-dimensional array
+	Strings   bool            // true if any field is a string
+	Float16   bool            // true if any field holds the half-precision numbers
+	CRCs      map[string]bool // checksum algorithms the registers use
+}
+
+type TSConstant struct {
+	Doc   []string
+	Name  string
+	Value string
+}
+
+type TSEnum struct {
+	Doc     []string
+	Name    string
+	Members []TSConstant
+}
+
+type TSRegister struct {
+	Name       string
+	Number     int
+	Doc        []string
+	Dir        string // the direction encode and decode use, write unless the register is read-only
+	DirTitle   string // the capitalized Dir
+	HasCRC     bool   // true if a field is a checksum of the preceding bytes
+	HasVersion bool   // true if the version byte precedes the fields
+	Version    uint8
+	Constants  []TSConstant
+	Fields     []TSField
+	Dirs       []TSDir
+}
+
+// TSDir is the code encoding and decoding the fields sent in one direction
+type TSDir struct {
+	Name   string // read or write
+	Title  string // Read or Write
+	Pack   []string
+	Unpack []string
+}
+
+type TSField struct {
+	Doc         []string
+	Decl        string // the interface property, empty for the reserved, magic and checksum fields
+	Init        string // the property of the zero register
+	Trailing    string
+	IsReadable  bool
+	IsWritable  bool
+	PackRead    []string // Code for the read direction writer
+	PackWrite   []string // Code for the write direction writer
+	UnpackRead  []string // Code for the read direction reader
+	UnpackWrite []string // Code for the write direction reader
+	Checks      []string // Checks for variable-length arrays, strings and ranges
+	Accessors   []string // Functions of the bit field members and fixed-point numbers
+}
+
+//
+// Public entry
+//
+
+// GenerateTypeScript generates the TypeScript module for the device. Every register is an
+// interface encoded by its encode and decode functions with DataView, the 64-bit integers
+// are bigint and the other numbers are number
+func GenerateTypeScript(dev *parser.Device) (string, error) {
+	out := TSDevice{Doc: tsDoc(declComments(dev.Doc, dev.TrailingComment))}
+	for _, c := range dev.Constants {
+		out.Constants = append(out.Constants, TSConstant{
+			Doc:   tsDoc(declComments(c.Doc, c.TrailingComment)),
+			Name:  c.Name,
+			Value: tsLiteral(c.ValueStr, c.Type.Name),
+		})
+	}
+	for _, e := range dev.Enums {
+		te := TSEnum{Doc: tsDoc(declComments(e.Doc, e.TrailingComment)), Name: e.Name}
+		for _, m := range e.Members {
+			te.Members = append(te.Members, TSConstant{
+				Doc:   declComments(m.Doc, m.TrailingComment),
+				Name:  m.Name,
+				Value: tsLiteral(m.ValueStr, e.Base),
+			})
+		}
+		out.Enums = append(out.Enums, te)
+	}
+
+	for _, reg := range dev.Registers {
+		tr := TSRegister{
+			Name:     reg.Name,
+			Number:   int(reg.Number()),
+			Doc:      tsDoc(declComments(reg.Doc, reg.TrailingComment)),
+			Dir:      "write",
+			DirTitle: "Write",
+		}
+		if reg.Specifier == "r" {
+			tr.Dir, tr.DirTitle = "read", "Read"
+		}
+		if reg.HasVersion() {
+			tr.HasVersion, tr.Version = true, reg.Version()
+		}
+		for _, c := range reg.Body.Constants() {
+			tr.Constants = append(tr.Constants, TSConstant{
+				Doc:   declComments(c.Doc, c.TrailingComment),
+				Name:  c.Name,
+				Value: tsLiteral(c.ValueStr, c.Type.Name),
+			})
+		}
+
+		for i, f := range reg.Body.Fields() {
+			tf := TSField{
+				Doc:        trimLeadingEmptyLines(flattenComments(f.Doc)),
+				Trailing:   safeString(f.TrailingComment),
+				IsReadable: f.Specifier == "r" || f.Specifier == "",
+				IsWritable: f.Specifier == "w" || f.Specifier == "",
+			}
+			field := "c." + f.Name
+			le := f.IsLittleEndian()
+
+			// The field placed at the offset is preceded by the zero bytes filling the gap
+			for _, dir := range tf.dirs() {
+				if pad := reg.Padding(f, dir == "read"); pad > 0 {
+					tf.addDir(dir, []string{fmt.Sprintf("w.zeros(%d);", pad)}, []string{fmt.Sprintf("r.take(%d);", pad)})
+				}
+			}
+
+			switch {
+			case f.Reserved:
+				size := reservedSize(f)
+				tf.add([]string{fmt.Sprintf("w.zeros(%d);", size)}, []string{fmt.Sprintf("r.take(%d);", size)})
+
+			case f.Type.CRC != nil:
+				// the checksum is calculated over the register bytes preceding it
+				base := f.Type.CRC.BaseType()
+				crcFn := out.tsCRCFunc(f.Type.CRC)
+				tr.HasCRC = true
+				tf.add([]string{tsWrite(base, crcFn+"(w.bytes(start))", le)}, []string{
+					"{",
+					"    const end = r.offset;",
+					"    const crc = " + tsRead(base, le) + ";",
+					fmt.Sprintf("    if (crc !== %s(r.buf.subarray(start, end))) {", crcFn),
+					fmt.Sprintf("        throw new Error(`%s checksum 0x${crc.toString(16).toUpperCase()} mismatch`);", f.Name),
+					"    }",
+					"}",
+				})
+
+			case f.IsMagic():
+				// the magic value is always the same, so it has no value to keep
+				typ := f.Type.Simple.Name
+				magic := magicLiteral(f)
+				if tsType(typ) == "bigint" {
+					magic += "n"
+				}
+				tf.add([]string{tsWrite(typ, magic, le)}, []string{
+					"{",
+					"    const magic = " + tsRead(typ, le) + ";",
+					fmt.Sprintf("    if (magic !== %s) {", magic),
+					fmt.Sprintf("        throw new Error(`%s mismatch: received 0x${magic.toString(16).toUpperCase()}, expected %s`);",
+						f.Name, strings.TrimSuffix(magic, "n")),
+					"    }",
+					"}",
+				})
+
+			case f.Type.Simple != nil && f.Type.Simple.IsRegisterRef():
+				ref := f.Type.Simple.Name
+				tf.Decl = fmt.Sprintf("%s: %s;", f.Name, ref)
+				tf.Init = fmt.Sprintf("%s: new%s()", f.Name, ref)
+				for _, dir := range tf.dirs() {
+					title := tsTitle(dir)
+					tf.addDir(dir, []string{fmt.Sprintf("write%s%s(w, %s);", ref, title, field)},
+						[]string{fmt.Sprintf("%s = read%s%s(r);", field, ref, title)})
+				}
+
+			case f.Type.Bitfield != nil:
+				bf := f.Type.Bitfield
+				typ := tsType(bf.Base)
+				tf.Decl = fmt.Sprintf("%s: %s;", f.Name, typ)
+				tf.Init = fmt.Sprintf("%s: %s", f.Name, tsDefault(f, bf.Base))
+				for _, bm := range bf.Bits {
+					if bm.Reserved {
+						// reserved members only document the unused bits
+						continue
+					}
+					tf.Accessors = append(tf.Accessors, tsBitAccessors(reg.Name, f.Name, bf.Base, &bm))
+				}
+				tf.add([]string{tsWrite(bf.Base, field, le)}, []string{fmt.Sprintf("%s = %s;", field, tsRead(bf.Base, le))})
+
+			case f.Type.Array != nil && f.Type.Array.Type.IsRegisterRef():
+				elem := f.Type.Array.Type.Name
+				tf.Decl = fmt.Sprintf("%s: %s[];", f.Name, elem)
+				var count string
+				if f.Type.Array.Size.Constant != nil {
+					count = *f.Type.Array.Size.Constant
+					tf.Init = fmt.Sprintf("%s: Array.from({ length: %s }, () => new%s())", f.Name, count, elem)
+				} else {
+					count = tsSizeFieldValue(reg, f, i)
+					tf.Init = f.Name + ": []"
+				}
+				tf.Checks = append(tf.Checks, tsLenCheck(f.Name, field, count)...)
+
+				// Every element is encoded by its own register functions
+				for _, dir := range tf.dirs() {
+					title := tsTitle(dir)
+					tf.addDir(dir, []string{
+						fmt.Sprintf("for (const v of %s) {", field),
+						fmt.Sprintf("    write%s%s(w, v);", elem, title),
+						"}",
+					}, []string{
+						field + " = [];",
+						fmt.Sprintf("for (let i = 0; i < %s; i++) {", count),
+						fmt.Sprintf("    %s.push(read%s%s(r));", field, elem, title),
+						"}",
+					})
+				}
+
+			case f.Type.Array != nil:
+				at := f.Type.Array
+				typ := at.Type.Name
+				out.useType(typ)
+				elemType := tsType(typ)
+				tf.Decl = fmt.Sprintf("%s: %s%s;", f.Name, elemType, strings.Repeat("[]", len(at.Dims)+1))
+				var count string
+				if at.Size.Constant != nil {
+					count = *at.Size.Constant
+					tf.Init = fmt.Sprintf("%s: %s", f.Name, tsNestedArray(elemType, tsZero(typ), append([]string{count}, at.Dims...)))
+				} else {
+					count = tsSizeFieldValue(reg, f, i)
+					tf.Init = f.Name + ": []"
+				}
+				tf.Checks = append(tf.Checks, tsLenCheck(f.Name, field, count)...)
+
+				if !at.IsMultiDim() {
+					tf.add([]string{
+						fmt.Sprintf("for (const v of %s) {", field),
+						"    " + tsWrite(typ, "v", le),
+						"}",
+					}, []string{
+						field + " = [];",
+						fmt.Sprintf("for (let i = 0; i < %s; i++) {", count),
+						fmt.Sprintf("    %s.push(%s);", field, tsRead(typ, le)),
+						"}",
+					})
+					break
+				}
+
+				// the multi-dimensional arrays are sent flattened
+				out.MultiDim = true
+				dims := "[" + strings.Join(at.Dims, ", ") + "]"
+				tf.Checks = append(tf.Checks,
+					fmt.Sprintf("if (!_hasShape(%s, %s)) {", field, dims),
+					fmt.Sprintf("    throw new RangeError(\"%s rows must have the [%s] shape\");", f.Name, strings.Join(at.Dims, "][")),
+					"}")
+				elems := fmt.Sprintf("%s * %d", count, at.InnerCount())
+				if n, ok := tsConstCount(count); ok {
+					elems = strconv.Itoa(n * at.InnerCount())
+				}
+				tf.add([]string{
+					fmt.Sprintf("for (const v of %s.flat(%d)) {", field, len(at.Dims)),
+					"    " + tsWrite(typ, "v", le),
+					"}",
+				}, []string{
+					"{",
+					fmt.Sprintf("    const items: %s[] = [];", elemType),
+					fmt.Sprintf("    for (let i = 0; i < %s; i++) {", elems),
+					fmt.Sprintf("        items.push(%s);", tsRead(typ, le)),
+					"    }",
+					fmt.Sprintf("    %s = _reshape(items, %s);", field, dims),
+					"}",
+				})
+
+			case f.Type.String != nil:
+				out.Strings = true
+				prefix := f.Type.String.PrefixType()
+				maxLen := f.Type.String.MaxLen()
+				tf.Decl = f.Name + ": string;"
+				tf.Init = f.Name + `: ""`
+				tf.Checks = append(tf.Checks,
+					fmt.Sprintf("if (_encoder.encode(%s).length > %d) {", field, maxLen),
+					fmt.Sprintf("    throw new RangeError(`%s is ${_encoder.encode(%s).length} bytes long, but the maximum is %d`);",
+						f.Name, field, maxLen),
+					"}")
+				unpack := []string{
+					"{",
+					"    const n = " + tsRead(prefix, le) + ";",
+				}
+				if f.Type.String.MaxLenStr != nil {
+					// without the explicit maximum the length is limited by the prefix type
+					unpack = append(unpack,
+						fmt.Sprintf("    if (n > %d) {", maxLen),
+						fmt.Sprintf("        throw new RangeError(`%s is ${n} bytes long, but the maximum is %d`);", f.Name, maxLen),
+						"    }")
+				}
+				unpack = append(unpack,
+					fmt.Sprintf("    %s = _decoder.decode(r.raw(n));", field),
+					"}")
+				tf.add([]string{
+					"{",
+					fmt.Sprintf("    const encoded = _encoder.encode(%s);", field),
+					"    " + tsWrite(prefix, "encoded.length", le),
+					"    w.raw(encoded);",
+					"}",
+				}, unpack)
+
+			case f.Type.Simple != nil && f.Type.Simple.IsEnum():
+				// the enum is sent over the wire as its base integer type, so unknown values are kept
+				base := f.Type.Simple.Enum.Base
+				tf.Decl = fmt.Sprintf("%s: %s; // %s", f.Name, tsType(base), f.Type.Simple.Name)
+				tf.Init = fmt.Sprintf("%s: %s", f.Name, tsDefault(f, base))
+				tf.add([]string{tsWrite(base, field, le)}, []string{fmt.Sprintf("%s = %s;", field, tsRead(base, le))})
+
+			case f.Type.Simple != nil, f.Type.Fixed != nil:
+				typ := scalarTypeName(f)
+				out.useType(typ)
+				tf.Decl = fmt.Sprintf("%s: %s;", f.Name, tsType(typ))
+				tf.Init = fmt.Sprintf("%s: %s", f.Name, tsDefault(f, typ))
+				if f.Type.Fixed != nil {
+					// the field keeps the raw integer, the accessors convert it
+					out.Fixed = true
+					tf.Accessors = append(tf.Accessors, tsFixedAccessors(reg.Name, f.Name, typ, fixedScale(f.Type.Fixed)))
+				}
+				if conds := rangeConditions(f, field); len(conds) > 0 {
+					tf.Checks = append(tf.Checks,
+						fmt.Sprintf("if (%s) {", strings.Join(conds, " || ")),
+						fmt.Sprintf("    throw new RangeError(`%s ${%s} is out of range [%d..%d]`);", f.Name, field, f.Min(), f.Max()),
+						"}")
+				}
+				tf.add([]string{tsWrite(typ, field, le)}, []string{fmt.Sprintf("%s = %s;", field, tsRead(typ, le))})
+			}
+
+			// The conditional field is sent only if its condition is set, the absent field keeps its default
+			if f.HasCondition() {
+				cond := tsConditionExpr(reg, f, i)
+				tf.PackRead, tf.PackWrite = tsIf(cond, tf.PackRead), tsIf(cond, tf.PackWrite)
+				tf.UnpackRead, tf.UnpackWrite = tsIf(cond, tf.UnpackRead), tsIf(cond, tf.UnpackWrite)
+				tf.Checks = tsIf(cond, tf.Checks)
+			}
+
+			if tf.Decl != "" && tf.Trailing != "" && strings.Contains(tf.Decl, "//") {
+				// the enum type comment is already there
+				tf.Trailing = strings.TrimPrefix(strings.TrimPrefix(tf.Trailing, "//"), " ")
+				tf.Trailing = "- " + tf.Trailing
+			}
+			tr.Fields = append(tr.Fields, tf)
+		}
+
+		for _, dir := range []string{"read", "write"} {
+			td := TSDir{Name: dir, Title: tsTitle(dir)}
+			if reg.HasVersion() && (reg.Specifier == "" || reg.Specifier == dir[:1]) {
+				// the version byte precedes the fields in the directions the register is sent in
+				version := reg.Name + "_VERSION"
+				td.Pack = append(td.Pack, tsWrite("uint8", version, false))
+				td.Unpack = append(td.Unpack,
+					"{",
+					"    const version = "+tsRead("uint8", false)+";",
+					fmt.Sprintf("    if (version !== %s) {", version),
+					fmt.Sprintf("        throw new Error(`%s version mismatch: received ${version}, expected ${%s}`);", reg.Name, version),
+					"    }",
+					"}")
+			}
+			for _, tf := range tr.Fields {
+				if dir == "read" {
+					td.Pack = append(td.Pack, tf.PackRead...)
+					td.Unpack = append(td.Unpack, tf.UnpackRead...)
+				} else {
+					td.Pack = append(td.Pack, tf.PackWrite...)
+					td.Unpack = append(td.Unpack, tf.UnpackWrite...)
+				}
+			}
+			tr.Dirs = append(tr.Dirs, td)
+		}
+		out.Registers = append(out.Registers, tr)
+	}
+
+	var buf bytes.Buffer
+	if err := tsTpl.Execute(&buf, out); err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(buf.String()) + "\n", nil
+}
+
+//
+// Helpers
+//
+
+// dirs returns the directions, read and write, the field is sent in
+func (f *TSField) dirs() []string {
+	var res []string
+	if f.IsReadable {
+		res = append(res, "read")
+	}
+	if f.IsWritable {
+		res = append(res, "write")
+	}
+	return res
+}
+
+// add adds the code of the field to the directions the field is sent in
+func (f *TSField) add(pack, unpack []string) {
+	for _, dir := range f.dirs() {
+		f.addDir(dir, pack, unpack)
+	}
+}
+
+// addDir adds the code of the field to the dir direction
+func (f *TSField) addDir(dir string, pack, unpack []string) {
+	if dir == "read" {
+		f.PackRead = append(f.PackRead, pack...)
+		f.UnpackRead = append(f.UnpackRead, unpack...)
+	} else {
+		f.PackWrite = append(f.PackWrite, pack...)
+		f.UnpackWrite = append(f.UnpackWrite, unpack...)
+	}
+}
+
+// useType registers the runtime helpers of the built-in type
+func (d *TSDevice) useType(typ string) {
+	if typ == "float16" {
+		d.Float16 = true
+	}
+}
+
+// tsCRCFunc returns the function calculating the checksum and registers its runtime helper
+func (d *TSDevice) tsCRCFunc(ct *parser.CRCType) string {
+	alg := ct.AlgorithmName()
+	if d.CRCs == nil {
+		d.CRCs = make(map[string]bool)
+	}
+	d.CRCs[alg] = true
+	return "_" + ct.Kind + tsTitle(alg)
+}
+
+// tsWrite returns the statement writing the value of the built-in type
+func tsWrite(typ, value string, le bool) string {
+	if wireTypeSize(typ) > 1 && le {
+		return fmt.Sprintf("w.%s(%s, true);", typ, value)
+	}
+	return fmt.Sprintf("w.%s(%s);", typ, value)
+}
+
+// tsRead returns the expression reading the value of the built-in type
+func tsRead(typ string, le bool) string {
+	if wireTypeSize(typ) > 1 && le {
+		return fmt.Sprintf("r.%s(true)", typ)
+	}
+	return fmt.Sprintf("r.%s()", typ)
+}
+
+// tsConditionExpr returns the expression which is true if the conditional field is sent, the
+// condition is either the single-bit member or the uint8 field, which is set if it is not zero
+func tsConditionExpr(reg *parser.Register, f *parser.Field, idx int) string {
+	fld, bm := reg.FindFieldByName(*f.Condition, idx)
+	switch {
+	case bm == nil:
+		return fmt.Sprintf("c.%s !== 0", fld.Name)
+	case tsType(fld.Type.Bitfield.Base) == "bigint":
+		return fmt.Sprintf("(c.%s & 0x%Xn) !== 0n", fld.Name, bitMask(bm.StartBit(), bm.EndBit()))
+	default:
+		return fmt.Sprintf("(c.%s & 0x%X) !== 0", fld.Name, bitMask(bm.StartBit(), bm.EndBit()))
+	}
+}
+
+// tsIf returns the code executed only if the condition is true, there is no code if the code is empty
+func tsIf(cond string, code []string) []string {
+	if len(code) == 0 {
+		return nil
+	}
+	res := []string{fmt.Sprintf("if (%s) {", cond)}
+	for _, line := range code {
+		res = append(res, "    "+line)
+	}
+	return append(res, "}")
+}
+
+// tsBitAccessors returns the functions reading and writing the bit field member bm of the field.
+// The members of the bit fields up to 32 bits are numbers, the 64-bit ones are bigints
+func tsBitAccessors(reg, field, base string, bm *parser.BitMember) string {
+	start, end := bm.StartBit(), bm.EndBit()
+	mask := bitMask(start, end)
+	name := tsTitle(reg) + tsTitle(field) + tsTitle(bm.Name)
+	value := "c." + field
+	lines := tsComments(flattenComments(bm.Doc))
+	if tsType(base) == "bigint" {
+		if start == end {
+			return strings.Join(append(lines,
+				fmt.Sprintf("export function get%s(c: %s): boolean {", name, reg),
+				fmt.Sprintf("    return (%s & 0x%Xn) !== 0n;", value, mask),
+				"}",
+				"",
+				fmt.Sprintf("export function set%s(c: %s, v: boolean): void {", name, reg),
+				fmt.Sprintf("    %s = v ? %s | 0x%Xn : %s & ~0x%Xn;", value, value, mask, value, mask),
+				"}"), "\n")
+		}
+		shifted, v := value, "v"
+		if start > 0 {
+			shifted, v = fmt.Sprintf("%s >> %dn", value, start), fmt.Sprintf("(v << %dn)", start)
+		}
+		get := fmt.Sprintf("BigInt.asUintN(%d, %s)", end-start+1, shifted)
+		if bm.Signed {
+			get = fmt.Sprintf("BigInt.asIntN(%d, %s)", end-start+1, shifted)
+		}
+		return strings.Join(append(lines,
+			fmt.Sprintf("export function get%s(c: %s): bigint {", name, reg),
+			fmt.Sprintf("    return %s;", get),
+			"}",
+			"",
+			fmt.Sprintf("export function set%s(c: %s, v: bigint): void {", name, reg),
+			fmt.Sprintf("    %s = (%s & ~0x%Xn) | (%s & 0x%Xn);", value, value, mask, v, mask),
+			"}"), "\n")
+	}
+
+	// the bitwise operators work with the signed 32-bit integers, so the 32-bit results are
+	// converted back to the unsigned ones
+	unsigned := ""
+	if wireTypeSize(base) == 4 {
+		unsigned = " >>> 0"
+	}
+	if start == end {
+		return strings.Join(append(lines,
+			fmt.Sprintf("export function get%s(c: %s): boolean {", name, reg),
+			fmt.Sprintf("    return (%s & 0x%X) !== 0;", value, mask),
+			"}",
+			"",
+			fmt.Sprintf("export function set%s(c: %s, v: boolean): void {", name, reg),
+			fmt.Sprintf("    %s = v ? %s : %s;", value,
+				tsUnsigned(fmt.Sprintf("%s | 0x%X", value, mask), unsigned), tsUnsigned(fmt.Sprintf("%s & ~0x%X", value, mask), unsigned)),
+			"}"), "\n")
+	}
+	var get string
+	v := "v"
+	if start > 0 {
+		v = fmt.Sprintf("(v << %d)", start)
+	}
+	switch {
+	case bm.Signed:
+		// the member is moved to the highest bits, so the arithmetic shift extends its sign
+		top := 31 - end
+		get = fmt.Sprintf("(%s << %d) >> %d", value, top, top+start)
+	case end-start == 31:
+		get = value
+	case start == 0:
+		get = fmt.Sprintf("%s & 0x%X", value, mask)
+	default:
+		get = fmt.Sprintf("(%s >>> %d) & 0x%X", value, start, bitMask(0, end-start))
+	}
+	return strings.Join(append(lines,
+		fmt.Sprintf("export function get%s(c: %s): number {", name, reg),
+		fmt.Sprintf("    return %s;", get),
+		"}",
+		"",
+		fmt.Sprintf("export function set%s(c: %s, v: number): void {", name, reg),
+		fmt.Sprintf("    %s = %s;", value, tsUnsigned(fmt.Sprintf("(%s & ~0x%X) | (%s & 0x%X)", value, mask, v, mask), unsigned)),
+		"}"), "\n")
+}
+
+// tsUnsigned returns the expression converted by the unsigned suffix, if any
+func tsUnsigned(expr, unsigned string) string {
+	if unsigned == "" {
+		return expr
+	}
+	return "(" + expr + ")" + unsigned
+}
+
+// tsFixedAccessors returns the functions reading and writing the value of the fixed-point field
+func tsFixedAccessors(reg, field, typ, scale string) string {
+	name := tsTitle(reg) + tsTitle(field) + "Value"
+	get, set := fmt.Sprintf("c.%s / %s", field, scale), fmt.Sprintf("_round(v * %s)", scale)
+	if tsType(typ) == "bigint" {
+		get, set = fmt.Sprintf("Number(c.%s) / %s", field, scale), fmt.Sprintf("BigInt(%s)", set)
+	}
+	return strings.Join([]string{
+		fmt.Sprintf("export function get%s(c: %s): number {", name, reg),
+		fmt.Sprintf("    return %s;", get),
+		"}",
+		"",
+		fmt.Sprintf("export function set%s(c: %s, v: number): void {", name, reg),
+		fmt.Sprintf("    c.%s = %s;", field, set),
+		"}",
+	}, "\n")
+}
+
+// tsSizeFieldValue returns the expression of the variable-length array f size field value,
+// the bit field members are read by their accessors and the bigints are converted to numbers
+func tsSizeFieldValue(reg *parser.Register, f *parser.Field, idx int) string {
+	field, bm := reg.FindFieldByName(*f.Type.Array.Size.Variable, idx)
+	var res, typ string
+	if bm != nil {
+		res = fmt.Sprintf("get%s%s%s(c)", tsTitle(reg.Name), tsTitle(field.Name), tsTitle(bm.Name))
+		typ = field.Type.Bitfield.Base
+	} else {
+		res = "c." + field.Name
+		typ = field.Type.Simple.Name
+	}
+	if tsType(typ) == "bigint" {
+		return "Number(" + res + ")"
+	}
+	return res
+}
+
+// tsConstCount returns the value of the constant array size written as any integer literal,
+// ok is false for the size field references
+func tsConstCount(count string) (int, bool) {
+	n, err := strconv.ParseInt(count, 0, 64)
+	return int(n), err == nil
+}
+
+// tsLenCheck returns the statements throwing RangeError if the length of the array differs from count
+func tsLenCheck(name, field, count string) []string {
+	expected := "${" + count + "}"
+	if n, ok := tsConstCount(count); ok {
+		expected = strconv.Itoa(n)
+	}
+	return []string{
+		fmt.Sprintf("if (%s.length !== %s) {", field, count),
+		fmt.Sprintf("    throw new RangeError(`%s has ${%s.length} elements, but %s are expected`);", name, field, expected),
+		"}",
+	}
+}
+
+// tsNestedArray returns the expression of the nested arrays of zero values with the dims sizes
+func tsNestedArray(elemType, zero string, dims []string) string {
+	res := fmt.Sprintf("new Array<%s>(%s).fill(%s)", elemType, dims[len(dims)-1], zero)
+	for i := len(dims) - 2; i >= 0; i-- {
+		res = fmt.Sprintf("Array.from({ length: %s }, () => %s)", dims[i], res)
+	}
+	return res
+}
+
+// tsType returns the TypeScript type of the built-in type
+func tsType(typ string) string {
+	if typ == "int64" || typ == "uint64" {
+		return "bigint"
+	}
+	return "number"
+}
+
+// tsDefault returns the default value literal of the field of the type or zero if the field has
+// no default value
+func tsDefault(f *parser.Field, typ string) string {
+	if f.HasDefault() {
+		return tsLiteral(defaultLiteral(f), typ)
+	}
+	return tsZero(typ)
+}
+
+// tsZero returns the zero value literal of the built-in type
+func tsZero(typ string) string {
+	if tsType(typ) == "bigint" {
+		return "0n"
+	}
+	return "0"
+}
+
+// tsLiteral returns the literal of the integer value of the type, the 64-bit ones are bigints
+func tsLiteral(value, typ string) string {
+	if tsType(typ) == "bigint" {
+		return value + "n"
+	}
+	return value
+}
+
+// tsTitle returns the name with the first letter capitalized
+func tsTitle(name string) string {
+	if name == "" {
+		return name
+	}
+	return strings.ToUpper(name[:1]) + name[1:]
+}
+
+// tsComments returns the comment lines, the empty ones are dropped
+func tsComments(comments []string) []string {
+	var res []string
+	for _, c := range comments {
+		if c != "" {
+			res = append(res, c)
+		}
+	}
+	return res
+}
+
+// tsDoc returns the comment lines preceding the top level definition, the leading empty lines
+// are dropped since the definitions are separated by an empty line anyway
+func tsDoc(comments []string) []string {
+	return trimLeadingEmptyLines(comments)
+}
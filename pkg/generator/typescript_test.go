@@ -0,0 +1,412 @@
+package generator
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/dspasibenko/pargus/pkg/parser"
+	"github.com/stretchr/testify/require"
+)
+
+// minTypeScriptNode is the first node version running the TypeScript files by stripping the types
+// with --experimental-strip-types, the runtime tests need it
+const minTypeScriptNode = "22.6"
+
+// typeScriptNode finds the node binary stripping the TypeScript types once for all the tests.
+// PARGUS_NODE selects the binary, otherwise the node on the PATH and then the ones installed by
+// nvm are tried. The node is empty if none of them strips the types, the tried ones are reported
+var typeScriptNode = sync.OnceValues(func() (node string, tried []string) {
+	var candidates []string
+	if env := os.Getenv("PARGUS_NODE"); env != "" {
+		candidates = append(candidates, env)
+	} else {
+		if path, err := exec.LookPath("node"); err == nil {
+			candidates = append(candidates, path)
+		}
+		nvmDir := os.Getenv("NVM_DIR")
+		if home, err := os.UserHomeDir(); err == nil && nvmDir == "" {
+			nvmDir = filepath.Join(home, ".nvm")
+		}
+		installed, _ := filepath.Glob(filepath.Join(nvmDir, "versions", "node", "*", "bin", "node"))
+		candidates = append(candidates, installed...)
+	}
+	for _, c := range candidates {
+		if exec.Command(c, "--experimental-strip-types", "-e", "").Run() == nil {
+			return c, nil
+		}
+	}
+	return "", candidates
+})
+
+// runGeneratedTypeScriptTest puts the generated module and the test script into a temporary
+// directory and runs the script there by node 22.6 or newer, which strips the TypeScript types.
+// The test is skipped like the other runtime tests if there is no such node, PARGUS_NODE points
+// to a newer node than the one on the PATH
+func runGeneratedTypeScriptTest(t *testing.T, code, script string) {
+	t.Helper()
+	node, tried := typeScriptNode()
+	switch {
+	case node != "":
+	case len(tried) == 0:
+		t.Skip("node is not available")
+	default:
+		t.Skipf("none of %s supports the type stripping, node %s or newer is required to run the generated TypeScript, "+
+			"set PARGUS_NODE to its binary", strings.Join(tried, ", "), minTypeScriptNode)
+	}
+
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "package.json"), []byte(`{"type": "module"}`), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "registers.ts"), []byte(code), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "main.ts"), []byte(script), 0644))
+
+	cmd := exec.Command(node, "--experimental-strip-types", "--no-warnings", "main.ts")
+	cmd.Dir = dir
+	out, err := cmd.CombinedOutput()
+	require.NoError(t, err, "generated code test failed:\n%s\n%s", out, code)
+}
+
+func TestGenerateTypeScriptGolden(t *testing.T) {
+	input, err := os.ReadFile("testdata/example.pa")
+	require.NoError(t, err)
+	device, err := parser.Parse(string(input))
+	require.NoError(t, err)
+
+	code, err := GenerateTypeScript(device)
+	require.NoError(t, err)
+	golden, err := os.ReadFile("testdata/example.ts")
+	require.NoError(t, err)
+	require.Equal(t, string(golden), code)
+}
+
+func TestGeneratedTypeScriptRoundTrip(t *testing.T) {
+	input, err := os.ReadFile("testdata/example.pa")
+	require.NoError(t, err)
+	device, err := parser.Parse(string(input))
+	require.NoError(t, err)
+	code, err := GenerateTypeScript(device)
+	require.NoError(t, err)
+
+	runGeneratedTypeScriptTest(t, code, `
+import assert from "node:assert/strict";
+import * as r from "./registers.ts";
+
+const hex = (b: Uint8Array): string => Buffer.from(b).toString("hex");
+
+const cfg = r.newConfig();
+cfg.mode = r.Mode.STANDBY;
+cfg.level = 42;
+cfg.name = "abc";
+assert.equal(hex(r.encodeConfig(cfg)), "022a03616263");
+assert.deepEqual(r.decodeConfig(r.encodeConfig(cfg)), cfg);
+cfg.level = r.Config_maxLevel + 1;
+assert.throws(() => r.encodeConfig(cfg), RangeError);
+assert.throws(() => r.decodeConfig(Uint8Array.of(2, 0x2a, 3, 0x61, 0x62)), /too short/);
+assert.throws(() => r.decodeConfig(Uint8Array.of(2, 0x2a, 0, 0)), /trailing bytes/);
+
+const st = r.newStatus();
+st.counter = -5;
+r.setStatusFlagsReady(st, true);
+r.setStatusFlagsError(st, 5);
+r.setStatusFlagsCount(st, 2);
+r.setStatusTempValue(st, 1.5);
+assert.equal(st.temp, 24);
+assert.throws(() => r.encodeStatus(st), RangeError);
+st.samples = [0x0102, -2];
+const data = r.encodeStatus(st);
+assert.equal(hex(data), "fffffffb2b00180201feff");
+const st2 = r.decodeStatus(data);
+assert.deepEqual(st2, st);
+assert.ok(r.getStatusFlagsReady(st2));
+assert.equal(r.getStatusFlagsError(st2), 5);
+assert.equal(r.getStatusTempValue(st2), 1.5);
+assert.equal(r.encodeStatusWrite(st).length, 0);
+
+const df = r.newDataFrame();
+df.points[0] = { x: -2, y: 0.5 };
+df.points[1].x = 0x123456;
+df.matrix[1][2] = 7;
+const frame = r.encodeDataFrame(df);
+assert.equal(frame.length, 22);
+assert.equal(hex(frame.subarray(0, 10)), "fffffe3f000000123456");
+assert.equal(frame[19], 7);
+assert.deepEqual(r.decodeDataFrame(frame), df);
+assert.throws(() => r.decodeDataFrame(Uint8Array.of(0, ...frame.subarray(1))), /checksum/);
+df.matrix[0] = [1, 2];
+assert.throws(() => r.encodeDataFrame(df), RangeError);
+`)
+}
+
+func TestGeneratedTypeScriptArrays(t *testing.T) {
+	device, err := parser.Parse(`
+    device test @le
+
+    const big = uint64(0xFFFFFFFFFFFFFFFF);
+
+    register Empty(1) {
+        reserved [2]uint8;
+    };
+
+    register Rows(2) {
+        count uint8;
+        rows [count][2]uint16;
+        values [2]float64 @be;
+        ids [count]int24;
+        flags uint16{lo: signed 0-3, hi: 4-7};
+        crc crc32;
+    };
+
+    register Keys(3): w {
+        sync = 0xAA55 int16 @be;
+        class uint8;
+        crc crc16(modbus);
+    };
+
+    register Wide(4) {
+        mask uint32{top: 31, low: 0-30};
+        word uint64{sign: signed 60-63, all: 0-58, bit: 59} @be;
+        total uint64;
+        half float16 @offset 24;
+        n uint8;
+        items [n]int64;
+        fx fixed(int64, 8);
+    };
+
+    register Hex(5) {
+        a [0x4]uint8;
+        b [0x2][2]uint16;
+    };`)
+	require.NoError(t, err)
+	code, err := GenerateTypeScript(device)
+	require.NoError(t, err)
+	require.Contains(t, code, "function _crc32Ieee")
+	require.NotContains(t, code, "function _crc16Ccitt")
+
+	runGeneratedTypeScriptTest(t, code, `
+import assert from "node:assert/strict";
+import { crc32 } from "node:zlib";
+import * as r from "./registers.ts";
+
+const hex = (b: Uint8Array): string => Buffer.from(b).toString("hex");
+
+assert.equal(hex(r.encodeEmpty(r.newEmpty())), "0000");
+assert.deepEqual(r.decodeEmpty(Uint8Array.of(1, 2)), r.newEmpty());
+
+const rows = r.newRows();
+rows.count = 2;
+rows.rows = [[1, 2], [3, 0x0405]];
+rows.values = [0, -1.25];
+rows.ids = [-1, 0x10203];
+r.setRowsFlagsLo(rows, -3);
+r.setRowsFlagsHi(rows, 9);
+assert.equal(r.getRowsFlagsLo(rows), -3);
+assert.equal(r.getRowsFlagsHi(rows), 9);
+assert.equal(rows.flags, 0x9d);
+const data = r.encodeRows(rows);
+assert.equal(data.length, 37);
+assert.equal(hex(data.subarray(0, 9)), "020100020003000504");
+assert.equal(hex(data.subarray(17, 19)), "bff4");
+assert.equal(hex(data.subarray(25, 31)), "ffffff030201");
+assert.equal(Buffer.from(data).readUInt32LE(33), crc32(data.subarray(0, 33)));
+assert.deepEqual(r.decodeRows(data), rows);
+assert.throws(() => r.decodeRows(Uint8Array.of(...data.subarray(0, 36), data[36] ^ 1)), /checksum/);
+
+const keys = r.newKeys();
+keys.class = 7;
+const k = r.encodeKeys(keys);
+assert.equal(hex(k.subarray(0, 3)), "aa5507");
+assert.deepEqual(r.decodeKeys(k), keys);
+assert.throws(() => r.decodeKeys(Uint8Array.of(0, ...k.subarray(1))), /sync mismatch/);
+assert.deepEqual(r.decodeKeysRead(new Uint8Array(0)), r.newKeys());
+assert.equal(r.big, 0xffffffffffffffffn);
+
+const wide = r.newWide();
+r.setWideMaskTop(wide, true);
+r.setWideMaskLow(wide, 5);
+assert.equal(wide.mask, 0x80000005);
+r.setWideMaskTop(wide, false);
+assert.equal(wide.mask, 5);
+r.setWideMaskTop(wide, true);
+r.setWideWordSign(wide, -2n);
+r.setWideWordAll(wide, 0x123n);
+r.setWideWordBit(wide, true);
+assert.equal(r.getWideWordSign(wide), -2n);
+assert.equal(r.getWideWordAll(wide), 0x123n);
+assert.ok(r.getWideWordBit(wide));
+wide.total = 0xfedcba9876543210n;
+wide.half = -1.5;
+wide.n = 1;
+wide.items = [-3n];
+r.setWideFxValue(wide, -2.5);
+assert.equal(wide.fx, -640n);
+const w = r.encodeWide(wide);
+assert.equal(hex(w), "05000080" + "e800000000000123" + "1032547698badcfe" + "00000000" + "00be" + "01" + "fdffffffffffffff" + "80fdffffffffffff");
+assert.deepEqual(r.decodeWide(w), wide);
+assert.equal(r.getWideFxValue(r.decodeWide(w)), -2.5);
+
+const hx = r.newHex();
+hx.a = [1, 2, 3, 4];
+hx.b = [[5, 6], [7, 8]];
+assert.equal(hex(r.encodeHex(hx)), "01020304" + "0500060007000800");
+assert.deepEqual(r.decodeHex(r.encodeHex(hx)), hx);
+hx.a = [1];
+assert.throws(() => r.encodeHex(hx), /a has 1 elements, but 4 are expected/);
+`)
+}
+
+func TestGeneratedTypeScriptFloat16(t *testing.T) {
+	device, err := parser.Parse(`
+    device test
+
+    register Half(1) {
+        zero float16;
+        tiny float16;
+        inf float16;
+        value float16 @le;
+        values [2]float16;
+        count uint8;
+        items [count]float16;
+    };`)
+	require.NoError(t, err)
+	code, err := GenerateTypeScript(device)
+	require.NoError(t, err)
+
+	runGeneratedTypeScriptTest(t, code, `
+import assert from "node:assert/strict";
+import * as r from "./registers.ts";
+
+// the tie 1+2^-11 rounds to the even 1 and 1e-8 is below the half of the smallest subnormal
+const h = r.newHalf();
+h.tiny = 2 ** -24;
+h.inf = Infinity;
+h.value = -1.5;
+h.values = [65504, 0.5];
+h.count = 2;
+h.items = [1.00048828125, 1e-8];
+const data = r.encodeHalf(h);
+assert.equal(Buffer.from(data).toString("hex"), "000000017c0000be7bff3800023c000000");
+assert.deepEqual(r.decodeHalf(data), { ...h, items: [1, 0] });
+assert.ok(Number.isNaN(r.decodeHalf(Uint8Array.of(0x7e, 0, ...data.subarray(2))).zero));
+`)
+}
+
+func TestGeneratedTypeScriptDefaults(t *testing.T) {
+	device, err := parser.Parse(`
+    device test
+
+    enum Mode uint8 { OFF = 0, ON = 1 };
+
+    register Config(1) {
+        level int16 [-100..100] = -5;
+        size uint8;
+    };
+
+    register Control(2) {
+        mode Mode = 1;
+        flags uint8{ready: 0, busy: 1} = 0b10;
+        big uint64 = 0xFFFFFFFFFFFFFFFF;
+        plain uint8;
+        config Config;
+        configs [2]Config;
+    };`)
+	require.NoError(t, err)
+	code, err := GenerateTypeScript(device)
+	require.NoError(t, err)
+	require.Contains(t, code, "        big: 18446744073709551615n,\n")
+
+	runGeneratedTypeScriptTest(t, code, `
+import assert from "node:assert/strict";
+import * as r from "./registers.ts";
+
+const c = r.newControl();
+assert.deepEqual([c.mode, c.flags, c.big, c.plain], [1, 2, 2n ** 64n - 1n, 0]);
+assert.deepEqual([c.config.level, c.configs[0].level, c.configs[1].level], [-5, -5, -5]);
+`)
+}
+
+func TestGeneratedTypeScriptConditions(t *testing.T) {
+	device, err := parser.Parse(`
+    device test
+
+    register Status(1) {
+        flags uint8{has_extra: 0};
+        extra uint16 [1..1000] if flags_has_extra;
+        big uint64{on: 63};
+        more uint8;
+        count uint8 if more;
+        items [count]uint8 if more;
+        last uint8 if big_on;
+    };`)
+	require.NoError(t, err)
+	code, err := GenerateTypeScript(device)
+	require.NoError(t, err)
+
+	runGeneratedTypeScriptTest(t, code, `
+import assert from "node:assert/strict";
+import * as r from "./registers.ts";
+
+const s = r.newStatus();
+s.extra = 5000;
+s.count = 2;
+s.items = [8];
+s.last = 3;
+const absent = r.encodeStatus(s);
+assert.equal(Buffer.from(absent).toString("hex"), "00000000000000000000");
+assert.deepEqual(r.decodeStatus(absent), r.newStatus());
+
+s.flags = 1;
+s.extra = 0x0102;
+s.big = 1n << 63n;
+s.more = 1;
+s.items = [8, 9];
+const present = r.encodeStatus(s);
+assert.equal(Buffer.from(present).toString("hex"), "01010280000000000000000102080903");
+assert.deepEqual(r.decodeStatus(present), s);
+s.extra = 5000;
+assert.throws(() => r.encodeStatus(s), RangeError);
+`)
+}
+
+func TestGeneratedTypeScriptRegisterVersion(t *testing.T) {
+	device, err := parser.Parse(`
+    device test
+
+    register Control(1) version 2 {
+        mode uint8;
+        crc crc16;
+    };
+    register Status(2):r version 7 {
+        value uint16;
+    };`)
+	require.NoError(t, err)
+	code, err := GenerateTypeScript(device)
+	require.NoError(t, err)
+
+	runGeneratedTypeScriptTest(t, code, `
+import assert from "node:assert/strict";
+import * as r from "./registers.ts";
+
+assert.equal(r.Control_VERSION, 2);
+assert.equal(r.Status_VERSION, 7);
+const c = r.newControl();
+c.mode = 5;
+const data = r.encodeControl(c);
+assert.equal(data.length, 4);
+assert.equal(Buffer.from(data.subarray(0, 2)).toString("hex"), "0205");
+assert.deepEqual(r.decodeControl(data), c);
+assert.deepEqual(r.decodeControlRead(r.encodeControlRead(c)), c);
+const other = data.slice();
+other[0] = 3;
+assert.throws(() => r.decodeControl(other), /version mismatch: received 3, expected 2/);
+
+const s = r.newStatus();
+s.value = 0x0102;
+assert.equal(Buffer.from(r.encodeStatus(s)).toString("hex"), "070102");
+assert.deepEqual(r.decodeStatus(Uint8Array.of(7, 1, 2)), s);
+assert.equal(r.encodeStatusWrite(s).length, 0);
+assert.throws(() => r.decodeStatus(Uint8Array.of(6, 1, 2)), /version mismatch/);
+`)
+}
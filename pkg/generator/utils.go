@@ -1,6 +1,13 @@
 package generator
 
-import "github.com/dspasibenko/pargus/pkg/parser"
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/dspasibenko/pargus/pkg/parser"
+)
 
 func bitMask(start, end int) uint64 {
 	width := end - start + 1
@@ -13,7 +20,9 @@ func flattenComments(cg *parser.CommentGroup) []string {
 	}
 	var out []string
 	for _, e := range cg.Elements {
-		if e.Comment != nil {
+		if e.Comment != nil && strings.HasPrefix(*e.Comment, "/*") {
+			out = append(out, blockCommentLines(*e.Comment)...)
+		} else if e.Comment != nil {
 			out = append(out, *e.Comment)
 		}
 		if e.EmptyLine != nil {
@@ -23,9 +32,233 @@ func flattenComments(cg *parser.CommentGroup) []string {
 	return out
 }
 
+// declComments returns the comment lines of the declaration, e.g. the register or the device,
+// the trailing comment of the declaration is the last line of them
+func declComments(doc *parser.CommentGroup, trailing *string) []string {
+	out := flattenComments(doc)
+	switch {
+	case trailing == nil || *trailing == "":
+	case strings.HasPrefix(*trailing, "/*"):
+		out = append(out, blockCommentLines(*trailing)...)
+	default:
+		out = append(out, strings.TrimRight(*trailing, " \t"))
+	}
+	return out
+}
+
+// blockCommentLines converts the /* */ block comment to the // line comments, the leading
+// asterisks of the comment lines and the empty first and last lines are removed
+func blockCommentLines(comment string) []string {
+	lines := strings.Split(strings.TrimSuffix(strings.TrimPrefix(comment, "/*"), "*/"), "\n")
+	var out []string
+	for i, line := range lines {
+		line = strings.TrimSpace(line)
+		if i > 0 {
+			line = strings.TrimSpace(strings.TrimPrefix(line, "*"))
+		}
+		if line == "" && (i == 0 || i == len(lines)-1) {
+			continue
+		}
+		if line == "" {
+			out = append(out, "//")
+			continue
+		}
+		out = append(out, "// "+line)
+	}
+	return out
+}
+
+// trimLeadingEmptyLines removes the empty lines preceding the comment, they would open
+// a block of declarations by an empty line
+func trimLeadingEmptyLines(lines []string) []string {
+	for len(lines) > 0 && lines[0] == "" {
+		lines = lines[1:]
+	}
+	return lines
+}
+
 func safeString(s *string) string {
 	if s == nil {
 		return ""
 	}
 	return *s
 }
+
+// wireTypeSize returns the number of bytes the built-in type occupies on the wire
+func wireTypeSize(typ string) int {
+	switch typ {
+	case "int8", "uint8":
+		return 1
+	case "int16", "uint16", "float16":
+		return 2
+	case "int24", "uint24":
+		return 3
+	case "int32", "uint32", "float32":
+		return 4
+	case "int64", "uint64", "float64":
+		return 8
+	default:
+		return 0
+	}
+}
+
+// is24BitType returns true for the 24-bit integers, which are kept in the 32-bit types
+// and sent over the wire as 3 bytes
+func is24BitType(typ string) bool {
+	return typ == "int24" || typ == "uint24"
+}
+
+// reservedSize returns the number of bytes the reserved field occupies on the wire
+func reservedSize(f *parser.Field) int {
+	if f.Type.Array != nil {
+		n, _ := strconv.ParseInt(*f.Type.Array.Size.Constant, 0, 64)
+		return int(n) * f.Type.Array.InnerCount() * wireTypeSize(f.Type.Array.Type.Name)
+	}
+	return wireTypeSize(f.Type.Simple.Name)
+}
+
+// scalarTypeName returns the built-in type of the simple or the fixed-point field
+func scalarTypeName(f *parser.Field) string {
+	if f.Type.Fixed != nil {
+		return f.Type.Fixed.Base
+	}
+	return f.Type.Simple.Name
+}
+
+// defaultLiteral returns the decimal default value of the field for the languages which do not
+// read the leading zero as the octal number like the pargus does
+func defaultLiteral(f *parser.Field) string {
+	if strings.HasPrefix(*f.DefaultStr, "-") {
+		return strconv.FormatInt(f.Default(), 10)
+	}
+	return strconv.FormatUint(uint64(f.Default()), 10)
+}
+
+// cppDefault returns the C++ member initializer of the field default value, it is empty if the
+// field has no default value
+func cppDefault(f *parser.Field) string {
+	if !f.HasDefault() {
+		return ""
+	}
+	if f.Type.Simple != nil && f.Type.Simple.IsEnum() {
+		return fmt.Sprintf(" = static_cast<%s>(%s)", f.Type.Simple.Name, cppIntLiteral(*f.DefaultStr))
+	}
+	return " = " + cppIntLiteral(*f.DefaultStr)
+}
+
+// hasDefaults returns true if the new register has a non-zero field: a field with the default
+// value or a nested register with them
+func hasDefaults(dev *parser.Device, reg *parser.Register) bool {
+	for _, f := range reg.Body.Fields() {
+		var ref string
+		switch {
+		case f.HasDefault():
+			return true
+		case f.Type.Simple != nil && f.Type.Simple.IsRegisterRef():
+			ref = f.Type.Simple.Name
+		case f.Type.Array != nil && f.Type.Array.Size.Constant != nil && f.Type.Array.Type.IsRegisterRef():
+			// the elements of the variable-length arrays are created by the decoding
+			ref = f.Type.Array.Type.Name
+		}
+		if nested := dev.FindRegisterByName(ref); nested != nil && hasDefaults(dev, nested) {
+			return true
+		}
+	}
+	return false
+}
+
+// magicLiteral returns the integer literal of the magic field value. The value of a signed
+// type is the two's complement of its bits, so it is negative if the sign bit is set
+func magicLiteral(f *parser.Field) string {
+	val := f.MagicValue()
+	typ := f.Type.Simple.Name
+	bits := wireTypeSize(typ) * 8
+	if strings.HasPrefix(typ, "int") && val>>(bits-1)&1 == 1 {
+		return strconv.FormatInt(int64(val<<(64-bits))>>(64-bits), 10)
+	}
+	return "0x" + strings.ToUpper(strconv.FormatUint(val, 16))
+}
+
+// fixedScale returns the 2^frac divisor of the fixed-point number as a floating-point literal
+func fixedScale(ft *parser.FixedType) string {
+	return strconv.FormatFloat(math.Ldexp(1, ft.Frac()), 'g', -1, 64)
+}
+
+// cppIntLiteral returns the integer literal valid in C++11: the binary literals appeared only
+// in C++14, so they are written in the hexadecimal form
+func cppIntLiteral(s string) string {
+	digits, sign := strings.CutPrefix(s, "-")
+	if !strings.HasPrefix(digits, "0b") && !strings.HasPrefix(digits, "0B") {
+		return s
+	}
+	val, err := strconv.ParseUint(digits[2:], 2, 64)
+	if err != nil {
+		return s
+	}
+	res := "0x" + strings.ToUpper(strconv.FormatUint(val, 16))
+	if sign {
+		res = "-" + res
+	}
+	return res
+}
+
+// rangeConditions returns the conditions which are true if the value is out of the field range,
+// the bounds equal to the integer type limits are not checked
+func rangeConditions(f *parser.Field, value string) []string {
+	if !f.HasRange() {
+		return nil
+	}
+	typ := f.Type.Simple.Name
+	lo, hi := f.Min(), f.Max()
+	bits := wireTypeSize(typ) * 8
+	checkLo, checkHi := true, true
+	switch {
+	case strings.HasPrefix(typ, "uint"):
+		checkLo = lo > 0
+		checkHi = bits == 64 || uint64(hi) < 1<<bits-1
+	case strings.HasPrefix(typ, "int"):
+		checkLo = lo > -1<<(bits-1)
+		checkHi = hi < 1<<(bits-1)-1
+	}
+	var res []string
+	if checkLo {
+		res = append(res, fmt.Sprintf("%s < %d", value, lo))
+	}
+	if checkHi {
+		res = append(res, fmt.Sprintf("%s > %d", value, hi))
+	}
+	return res
+}
+
+// plainCommentLines returns the text of the comment lines for the formats without comments, e.g.
+// the descriptions of JSON Schema. The comment markers, the trailing spaces and the leading and
+// trailing empty lines are removed
+func plainCommentLines(comments []string) []string {
+	lines := make([]string, 0, len(comments))
+	for _, c := range comments {
+		if text, ok := strings.CutPrefix(c, "//"); ok {
+			c = strings.TrimPrefix(text, " ")
+		}
+		lines = append(lines, strings.TrimRight(c, " \t"))
+	}
+	for len(lines) > 0 && lines[0] == "" {
+		lines = lines[1:]
+	}
+	for len(lines) > 0 && lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+	return lines
+}
+
+// sizeSum returns the expression adding the constant size to the variable size expression,
+// it is empty if both are zero
+func sizeSum(size int, expr string) string {
+	switch {
+	case size == 0:
+		return expr
+	case expr == "":
+		return strconv.Itoa(size)
+	default:
+		return fmt.Sprintf("%d + %s", size, expr)
+	}
+}
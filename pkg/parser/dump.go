@@ -0,0 +1,327 @@
+package parser
+
+import (
+	"encoding/json"
+	"strconv"
+
+	"github.com/alecthomas/participle/v2/lexer"
+)
+
+// The dump types are the stable JSON form of the validated device. The grammar structures are
+// not encoded directly, so the grammar may change without breaking the external tools.
+
+type dumpDevice struct {
+	Name            string         `json:"name"`
+	Pos             dumpPos        `json:"pos"`
+	Comments        []string       `json:"comments,omitempty"`
+	TrailingComment string         `json:"trailing_comment,omitempty"`
+	Endianness      string         `json:"endianness"` // the byte order of the fields without the annotation
+	Constants       []dumpConstant `json:"constants,omitempty"`
+	Enums           []dumpEnum     `json:"enums,omitempty"`
+	Registers       []dumpRegister `json:"registers"`
+	Messages        []dumpMessage  `json:"messages,omitempty"`
+}
+
+type dumpPos struct {
+	Offset int `json:"offset"`
+	Line   int `json:"line"`
+	Column int `json:"column"`
+}
+
+type dumpConstant struct {
+	Name            string   `json:"name"`
+	Pos             dumpPos  `json:"pos"`
+	Comments        []string `json:"comments,omitempty"`
+	TrailingComment string   `json:"trailing_comment,omitempty"`
+	Type            string   `json:"type"`
+	Value           int64    `json:"value"`
+}
+
+type dumpEnum struct {
+	Name            string           `json:"name"`
+	Pos             dumpPos          `json:"pos"`
+	Comments        []string         `json:"comments,omitempty"`
+	TrailingComment string           `json:"trailing_comment,omitempty"`
+	Base            string           `json:"base"`
+	Members         []dumpEnumMember `json:"members"`
+}
+
+type dumpEnumMember struct {
+	Name            string   `json:"name"`
+	Comments        []string `json:"comments,omitempty"`
+	TrailingComment string   `json:"trailing_comment,omitempty"`
+	Value           int64    `json:"value"`
+}
+
+type dumpRegister struct {
+	Name            string         `json:"name"`
+	Pos             dumpPos        `json:"pos"`
+	Comments        []string       `json:"comments,omitempty"`
+	TrailingComment string         `json:"trailing_comment,omitempty"`
+	Number          int64          `json:"number"`
+	Access          string         `json:"access"`            // r, w or rw
+	Version         *uint8         `json:"version,omitempty"` // the wire version sent before the fields
+	Constants       []dumpConstant `json:"constants,omitempty"`
+	Fields          []dumpField    `json:"fields"`
+}
+
+type dumpMessage struct {
+	Name            string              `json:"name"`
+	Pos             dumpPos             `json:"pos"`
+	Comments        []string            `json:"comments,omitempty"`
+	TrailingComment string              `json:"trailing_comment,omitempty"`
+	Members         []dumpMessageMember `json:"members"` // the registers in the order they are sent
+}
+
+type dumpMessageMember struct {
+	Register        string   `json:"register"`
+	Pos             dumpPos  `json:"pos"`
+	Comments        []string `json:"comments,omitempty"`
+	TrailingComment string   `json:"trailing_comment,omitempty"`
+}
+
+type dumpField struct {
+	Name            string          `json:"name,omitempty"` // empty for the reserved fields
+	Pos             dumpPos         `json:"pos"`
+	Comments        []string        `json:"comments,omitempty"`
+	TrailingComment string          `json:"trailing_comment,omitempty"`
+	Reserved        bool            `json:"reserved,omitempty"`
+	Access          string          `json:"access"` // r, w or rw, the register access is inherited
+	Kind            string          `json:"kind"`   // builtin, enum, register, array, bitfield, string, fixed or crc
+	Type            string          `json:"type"`   // the built-in, enum or register name, the base type for the other kinds
+	Endianness      string          `json:"endianness"`
+	Array           *dumpArray      `json:"array,omitempty"`
+	Bits            []dumpBitMember `json:"bits,omitempty"`
+	MaxLength       int             `json:"max_length,omitempty"` // the string maximum length
+	Frac            int             `json:"frac,omitempty"`       // the fixed-point fractional bits
+	Algorithm       string          `json:"algorithm,omitempty"`  // the checksum algorithm
+	Magic           *uint64         `json:"magic,omitempty"`      // the magic field value
+	Range           *dumpRange      `json:"range,omitempty"`
+	Default         *int64          `json:"default,omitempty"`   // the value of the new register
+	Offset          *int            `json:"offset,omitempty"`    // the byte offset of the field in the register
+	Condition       string          `json:"condition,omitempty"` // the flag the field is sent only if it is set
+}
+
+type dumpRange struct {
+	Min int64 `json:"min"`
+	Max int64 `json:"max"`
+}
+
+type dumpArray struct {
+	Size      int    `json:"size,omitempty"`       // the constant size
+	SizeField string `json:"size_field,omitempty"` // the size field or the bit member reference
+	Dims      []int  `json:"dims,omitempty"`       // the inner dimensions of the multi-dimensional array
+	Element   string `json:"element"`
+	Kind      string `json:"kind"` // builtin or register
+}
+
+type dumpBitMember struct {
+	Name     string   `json:"name,omitempty"` // empty for the reserved members
+	Comments []string `json:"comments,omitempty"`
+	Reserved bool     `json:"reserved,omitempty"`
+	Start    int      `json:"start"`
+	End      int      `json:"end"`
+	Signed   bool     `json:"signed,omitempty"`
+	Group    string   `json:"group,omitempty"` // the group of the member, the name is prefixed by it
+}
+
+// DumpJSON returns the JSON form of the parsed device with the positions, the comments and
+// the resolved fields metadata, e.g. the inherited access and the bit ranges. The device must
+// be returned by Parse, so its references are resolved.
+func DumpJSON(d *Device) ([]byte, error) {
+	dd := dumpDevice{
+		Name:       d.Name,
+		Pos:        toDumpPos(declarationPos(d.Pos, d.Tokens)),
+		Comments:   dumpComments(d.Doc),
+		Endianness: "be",
+		Constants:  dumpConstants(d.Constants),
+		Registers:  []dumpRegister{},
+	}
+	if d.TrailingComment != nil {
+		dd.TrailingComment = *d.TrailingComment
+	}
+	if d.Endianness == "le" {
+		dd.Endianness = "le"
+	}
+	for _, e := range d.Enums {
+		de := dumpEnum{Name: e.Name, Pos: toDumpPos(e.DeclPos()), Comments: dumpComments(e.Doc), Base: e.Base}
+		if e.TrailingComment != nil {
+			de.TrailingComment = *e.TrailingComment
+		}
+		for _, m := range e.Members {
+			dm := dumpEnumMember{Name: m.Name, Comments: dumpComments(m.Doc), Value: m.Value()}
+			if m.TrailingComment != nil {
+				dm.TrailingComment = *m.TrailingComment
+			}
+			de.Members = append(de.Members, dm)
+		}
+		dd.Enums = append(dd.Enums, de)
+	}
+	for _, r := range d.Registers {
+		dr := dumpRegister{
+			Name:      r.Name,
+			Pos:       toDumpPos(r.DeclPos()),
+			Comments:  dumpComments(r.Doc),
+			Number:    r.Number(),
+			Access:    dumpAccess(r.Specifier),
+			Constants: dumpConstants(r.Body.Constants()),
+			Fields:    []dumpField{},
+		}
+		if r.TrailingComment != nil {
+			dr.TrailingComment = *r.TrailingComment
+		}
+		if r.HasVersion() {
+			v := r.Version()
+			dr.Version = &v
+		}
+		for _, f := range r.Body.Fields() {
+			dr.Fields = append(dr.Fields, dumpFieldOf(f))
+		}
+		dd.Registers = append(dd.Registers, dr)
+	}
+	for _, m := range d.Messages {
+		dm := dumpMessage{Name: m.Name, Pos: toDumpPos(m.DeclPos()), Comments: dumpComments(m.Doc)}
+		if m.TrailingComment != nil {
+			dm.TrailingComment = *m.TrailingComment
+		}
+		for _, mm := range m.Members {
+			dmm := dumpMessageMember{Register: mm.Register, Pos: toDumpPos(mm.DeclPos()), Comments: dumpComments(mm.Doc)}
+			if mm.TrailingComment != nil {
+				dmm.TrailingComment = *mm.TrailingComment
+			}
+			dm.Members = append(dm.Members, dmm)
+		}
+		dd.Messages = append(dd.Messages, dm)
+	}
+	return json.MarshalIndent(dd, "", "  ")
+}
+
+func dumpFieldOf(f *Field) dumpField {
+	df := dumpField{
+		Pos:        toDumpPos(f.DeclPos()),
+		Comments:   dumpComments(f.Doc),
+		Reserved:   f.Reserved,
+		Access:     dumpAccess(f.Specifier),
+		Endianness: "be",
+	}
+	if !f.Reserved {
+		df.Name = f.Name
+	}
+	if f.TrailingComment != nil {
+		df.TrailingComment = *f.TrailingComment
+	}
+	if f.IsLittleEndian() {
+		df.Endianness = "le"
+	}
+	if f.IsMagic() {
+		magic := f.MagicValue()
+		df.Magic = &magic
+	}
+	if f.HasRange() {
+		df.Range = &dumpRange{Min: f.Min(), Max: f.Max()}
+	}
+	if f.HasDefault() {
+		def := f.Default()
+		df.Default = &def
+	}
+	if f.HasOffset() {
+		offset := f.Offset()
+		df.Offset = &offset
+	}
+	if f.HasCondition() {
+		df.Condition = *f.Condition
+	}
+
+	t := f.Type
+	switch {
+	case t.Bitfield != nil:
+		df.Kind, df.Type = "bitfield", t.Bitfield.Base
+		for _, bm := range t.Bitfield.Bits {
+			df.Bits = append(df.Bits, dumpBitMember{
+				Name:     bm.Name,
+				Comments: dumpComments(bm.Doc),
+				Start:    bm.StartBit(),
+				End:      bm.EndBit(),
+				Signed:   bm.Signed,
+				Group:    bm.Group,
+			})
+		}
+	case t.Array != nil:
+		df.Kind, df.Type = "array", t.Array.Type.Name
+		da := &dumpArray{Element: t.Array.Type.Name, Kind: "builtin"}
+		if t.Array.Type.IsRegisterRef() {
+			da.Kind = "register"
+		}
+		if t.Array.Size.Constant != nil {
+			n, _ := strconv.ParseInt(*t.Array.Size.Constant, 0, 64)
+			da.Size = int(n)
+		} else {
+			da.SizeField = *t.Array.Size.Variable
+		}
+		for _, d := range t.Array.Dims {
+			n, _ := strconv.ParseInt(d, 0, 64)
+			da.Dims = append(da.Dims, int(n))
+		}
+		df.Array = da
+	case t.String != nil:
+		df.Kind, df.Type, df.MaxLength = "string", t.String.PrefixType(), t.String.MaxLen()
+	case t.Fixed != nil:
+		df.Kind, df.Type, df.Frac = "fixed", t.Fixed.Base, t.Fixed.Frac()
+	case t.CRC != nil:
+		df.Kind, df.Type, df.Algorithm = "crc", t.CRC.BaseType(), t.CRC.AlgorithmName()
+	case t.Simple != nil:
+		df.Type = t.Simple.Name
+		switch {
+		case t.Simple.IsEnum():
+			df.Kind = "enum"
+		case t.Simple.IsRegisterRef():
+			df.Kind = "register"
+		default:
+			df.Kind = "builtin"
+		}
+	}
+	return df
+}
+
+func dumpConstants(constants []*Constant) []dumpConstant {
+	var res []dumpConstant
+	for _, c := range constants {
+		dc := dumpConstant{
+			Name:     c.Name,
+			Pos:      toDumpPos(c.DeclPos()),
+			Comments: dumpComments(c.Doc),
+			Type:     c.Type.Name,
+			Value:    c.Value(),
+		}
+		if c.TrailingComment != nil {
+			dc.TrailingComment = *c.TrailingComment
+		}
+		res = append(res, dc)
+	}
+	return res
+}
+
+// dumpComments returns the comment lines of the group, the empty lines are skipped
+func dumpComments(doc *CommentGroup) []string {
+	if doc == nil {
+		return nil
+	}
+	var res []string
+	for _, e := range doc.Elements {
+		if e.Comment != nil {
+			res = append(res, *e.Comment)
+		}
+	}
+	return res
+}
+
+func dumpAccess(specifier string) string {
+	if specifier == "" {
+		return "rw"
+	}
+	return specifier
+}
+
+func toDumpPos(pos lexer.Position) dumpPos {
+	return dumpPos{Offset: pos.Offset, Line: pos.Line, Column: pos.Column}
+}
@@ -0,0 +1,116 @@
+package parser
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDumpJSON(t *testing.T) {
+	device, err := Parse(`// the device
+device test
+const version = uint8(2);
+
+enum Mode uint8 { OFF = 0, ON = 1 };
+
+// the status
+register Status(0x10): r {
+    mode Mode;
+    flags uint8{ready: 0, offset: signed 4-7};
+    count uint8;
+    items [count]int16 @le if flags_ready; // the items
+    name string(uint8, 8);
+    reserved [2]uint8;
+    crc crc16(modbus);
+};
+
+register Control(2) version 1 {
+    const limit = uint8(10);
+    status:r Status;
+    temp fixed(int16, 4);
+    sync = 0xAA55 uint16;
+};
+
+message Frame {
+    Control; // the control
+    Status;
+};`)
+	require.NoError(t, err)
+
+	data, err := DumpJSON(device)
+	require.NoError(t, err)
+
+	var dd dumpDevice
+	require.NoError(t, json.Unmarshal(data, &dd))
+	again, err := json.MarshalIndent(dd, "", "  ")
+	require.NoError(t, err)
+	assert.Equal(t, string(data), string(again), "the JSON must round-trip")
+
+	assert.Equal(t, "test", dd.Name)
+	assert.Equal(t, dumpPos{Offset: 14, Line: 2, Column: 1}, dd.Pos)
+	assert.Equal(t, []string{"// the device"}, dd.Comments)
+	assert.Equal(t, "be", dd.Endianness)
+	assert.Equal(t, []dumpConstant{{Name: "version", Pos: dumpPos{Offset: 26, Line: 3, Column: 1}, Type: "uint8", Value: 2}}, dd.Constants)
+	require.Len(t, dd.Enums, 1)
+	assert.Equal(t, []dumpEnumMember{{Name: "OFF", Value: 0}, {Name: "ON", Value: 1}}, dd.Enums[0].Members)
+
+	require.Len(t, dd.Registers, 2)
+	status := dd.Registers[0]
+	assert.Equal(t, int64(16), status.Number)
+	assert.Equal(t, "r", status.Access)
+	assert.Nil(t, status.Version)
+	assert.Equal(t, []string{"// the status"}, status.Comments)
+	assert.Equal(t, 8, status.Pos.Line)
+	require.Len(t, status.Fields, 7)
+	assert.Equal(t, dumpField{Name: "mode", Pos: dumpPos{Offset: 136, Line: 9, Column: 5}, Access: "r", Kind: "enum",
+		Type: "Mode", Endianness: "be"}, status.Fields[0])
+	assert.Equal(t, []dumpBitMember{{Name: "ready", Start: 0, End: 0}, {Name: "offset", Start: 4, End: 7, Signed: true}},
+		status.Fields[1].Bits)
+	assert.Equal(t, &dumpArray{SizeField: "count", Element: "int16", Kind: "builtin"}, status.Fields[3].Array)
+	assert.Equal(t, "le", status.Fields[3].Endianness)
+	assert.Equal(t, "// the items", status.Fields[3].TrailingComment)
+	assert.Equal(t, "flags_ready", status.Fields[3].Condition)
+	assert.Equal(t, 8, status.Fields[4].MaxLength)
+	assert.True(t, status.Fields[5].Reserved)
+	assert.Empty(t, status.Fields[5].Name)
+	assert.Equal(t, 2, status.Fields[5].Array.Size)
+	assert.Equal(t, "crc", status.Fields[6].Kind)
+	assert.Equal(t, "modbus", status.Fields[6].Algorithm)
+
+	control := dd.Registers[1]
+	assert.Equal(t, "rw", control.Access)
+	require.NotNil(t, control.Version)
+	assert.Equal(t, uint8(1), *control.Version)
+	assert.Equal(t, "limit", control.Constants[0].Name)
+	assert.Equal(t, "register", control.Fields[0].Kind)
+	assert.Equal(t, "r", control.Fields[0].Access)
+	assert.Equal(t, "rw", control.Fields[1].Access)
+	assert.Equal(t, 4, control.Fields[1].Frac)
+	require.NotNil(t, control.Fields[2].Magic)
+	assert.Equal(t, uint64(0xAA55), *control.Fields[2].Magic)
+	assert.Nil(t, control.Fields[1].Magic)
+
+	require.Len(t, dd.Messages, 1)
+	assert.Equal(t, "Frame", dd.Messages[0].Name)
+	assert.Equal(t, []dumpMessageMember{
+		{Register: "Control", Pos: dumpPos{Offset: 502, Line: 26, Column: 5}, TrailingComment: "// the control"},
+		{Register: "Status", Pos: dumpPos{Offset: 530, Line: 27, Column: 5}},
+	}, dd.Messages[0].Members)
+}
+
+func TestDumpJSONHexArraySize(t *testing.T) {
+	device, err := Parse(`device test
+register Table(1) {
+    a [0x10][0b10]uint8;
+};`)
+	require.NoError(t, err)
+
+	data, err := DumpJSON(device)
+	require.NoError(t, err)
+
+	var dd dumpDevice
+	require.NoError(t, json.Unmarshal(data, &dd))
+	assert.Equal(t, &dumpArray{Size: 16, Dims: []int{2}, Element: "uint8", Kind: "builtin"}, dd.Registers[0].Fields[0].Array)
+}
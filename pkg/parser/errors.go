@@ -0,0 +1,82 @@
+package parser
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/alecthomas/participle/v2"
+	"github.com/alecthomas/participle/v2/lexer"
+)
+
+// Error is a syntax or a validation error at the position in the input
+type Error struct {
+	Pos  lexer.Position
+	Msg  string
+	Line string // the source line of the position, empty if unknown
+}
+
+// Error returns the message prefixed by the position. If the source line is known, it follows
+// the message with a caret under the position column.
+func (e *Error) Error() string {
+	msg := fmt.Sprintf("%s: %s", e.Pos, e.Msg)
+	if e.Line == "" {
+		return msg
+	}
+	// keep the tabs of the line, so the caret is aligned the same way
+	var caret strings.Builder
+	for i, r := range []rune(e.Line) {
+		if i >= e.Pos.Column-1 {
+			break
+		}
+		if r == '\t' {
+			caret.WriteRune('\t')
+		} else {
+			caret.WriteRune(' ')
+		}
+	}
+	return fmt.Sprintf("%s\n%s\n%s^", msg, e.Line, caret.String())
+}
+
+func errorAt(pos lexer.Position, format string, args ...any) error {
+	return &Error{Pos: pos, Msg: fmt.Sprintf(format, args...)}
+}
+
+// joinErrors returns the only error as is and errors.Join of several errors
+func joinErrors(errs []error) error {
+	if len(errs) == 1 {
+		return errs[0]
+	}
+	return errors.Join(errs...)
+}
+
+// withSource converts the positioned err to *Error with the offending line of the input,
+// the other errors are returned as is. Each of the joined errors is converted separately
+func withSource(err error, input string) error {
+	if joined, ok := err.(interface{ Unwrap() []error }); ok {
+		var errs []error
+		for _, e := range joined.Unwrap() {
+			errs = append(errs, withSource(e, input))
+		}
+		return errors.Join(errs...)
+	}
+	var e *Error
+	if !errors.As(err, &e) {
+		var pe participle.Error
+		if !errors.As(err, &pe) {
+			return err
+		}
+		e = &Error{Pos: pe.Position(), Msg: pe.Message()}
+	}
+	lines := strings.Split(normalizeLineEndings(input), "\n")
+	if e.Pos.Line >= 1 && e.Pos.Line <= len(lines) {
+		e.Line = strings.TrimRight(lines[e.Pos.Line-1], " \t")
+	}
+	return e
+}
+
+// normalizeLineEndings replaces the CRLF and CR line endings by LF
+func normalizeLineEndings(input string) string {
+	input = strings.ReplaceAll(input, "\r\n", "\n")
+	return strings.ReplaceAll(input, "\r", "\n")
+}
@@ -0,0 +1,254 @@
+package parser
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// indentStep is the indentation of the nested declarations in the canonical form
+const indentStep = "    "
+
+// Format parses the input and returns it in the canonical form: the nested declarations are
+// indented by 4 spaces, one declaration per line with the normalized spacing. The comments and
+// the empty lines between the declarations are preserved, consecutive empty lines are collapsed
+// into one. The numbers are written as they are, with their digit separators. Only the syntax
+// is checked, the input may be semantically invalid.
+func Format(input string) (string, error) {
+	device, err := parseAST(literalParser, input)
+	if err != nil {
+		return "", withSource(err, input)
+	}
+
+	var sb strings.Builder
+	writeDoc(&sb, device.Doc, "", true)
+	sb.WriteString("device " + device.Name)
+	if device.Endianness != "" {
+		sb.WriteString(" @" + device.Endianness)
+	}
+	sb.WriteString(formatTrailingComment(device.TrailingComment) + "\n")
+	for _, c := range device.Constants {
+		writeDoc(&sb, c.Doc, "", false)
+		sb.WriteString(formatConstant(c) + "\n")
+	}
+
+	// enums, registers and messages may be interleaved, keep their order in the source
+	type decl struct {
+		offset int
+		write  func()
+	}
+	var decls []decl
+	for _, e := range device.Enums {
+		decls = append(decls, decl{e.Pos.Offset, func() { writeEnum(&sb, e) }})
+	}
+	for _, r := range device.Registers {
+		decls = append(decls, decl{r.Pos.Offset, func() { writeRegister(&sb, r) }})
+	}
+	for _, m := range device.Messages {
+		decls = append(decls, decl{m.Pos.Offset, func() { writeMessage(&sb, m) }})
+	}
+	slices.SortFunc(decls, func(a, b decl) int { return a.offset - b.offset })
+	for _, d := range decls {
+		d.write()
+	}
+	return sb.String(), nil
+}
+
+// writeDoc writes the comment lines and the empty lines of the comment group. The empty
+// lines preceding the first comment are skipped if the declaration is the first in its block.
+func writeDoc(sb *strings.Builder, doc *CommentGroup, indent string, first bool) {
+	if doc == nil {
+		return
+	}
+	for _, e := range doc.Elements {
+		if e.Comment != nil {
+			sb.WriteString(indent + strings.TrimRight(*e.Comment, " \t") + "\n")
+			first = false
+		} else if !first {
+			sb.WriteString("\n")
+		}
+	}
+}
+
+func writeEnum(sb *strings.Builder, e *Enum) {
+	writeDoc(sb, e.Doc, "", false)
+	fmt.Fprintf(sb, "enum %s %s {\n", e.Name, e.Base)
+	for i, m := range e.Members {
+		writeDoc(sb, m.Doc, indentStep, i == 0)
+		fmt.Fprintf(sb, "%s%s = %s,%s\n", indentStep, m.Name, m.ValueStr, formatTrailingComment(m.TrailingComment))
+	}
+	writeDoc(sb, e.Tail, indentStep, true)
+	sb.WriteString("};" + formatTrailingComment(e.TrailingComment) + "\n")
+}
+
+func writeRegister(sb *strings.Builder, r *Register) {
+	writeDoc(sb, r.Doc, "", false)
+	fmt.Fprintf(sb, "register %s(%s)", r.Name, r.NumberStr)
+	if r.Specifier != "" {
+		fmt.Fprintf(sb, ": %s", r.Specifier)
+	}
+	if r.HasVersion() {
+		fmt.Fprintf(sb, " version %s", *r.VersionStr)
+	}
+	sb.WriteString(" {\n")
+	for i, item := range r.Body.Items {
+		if item.Constant != nil {
+			c := item.Constant
+			writeDoc(sb, c.Doc, indentStep, i == 0)
+			sb.WriteString(indentStep + formatConstant(c) + "\n")
+			continue
+		}
+		f := item.Field
+		writeDoc(sb, f.Doc, indentStep, i == 0)
+		sb.WriteString(indentStep + formatField(f, indentStep) + "\n")
+	}
+	sb.WriteString("};" + formatTrailingComment(r.TrailingComment) + "\n")
+}
+
+func writeMessage(sb *strings.Builder, m *Message) {
+	writeDoc(sb, m.Doc, "", false)
+	fmt.Fprintf(sb, "message %s {\n", m.Name)
+	for i, mm := range m.Members {
+		writeDoc(sb, mm.Doc, indentStep, i == 0)
+		sb.WriteString(indentStep + mm.Register + ";" + formatTrailingComment(mm.TrailingComment) + "\n")
+	}
+	sb.WriteString("};" + formatTrailingComment(m.TrailingComment) + "\n")
+}
+
+// formatTrailingComment returns the trailing comment preceded by a space, or an empty string
+// if there is no comment
+func formatTrailingComment(comment *string) string {
+	if comment == nil || *comment == "" {
+		return ""
+	}
+	return " " + strings.TrimRight(*comment, " \t")
+}
+
+func formatConstant(c *Constant) string {
+	return fmt.Sprintf("const %s = %s(%s);", c.Name, c.Type.Name, c.ValueStr) + formatTrailingComment(c.TrailingComment)
+}
+
+// formatField returns the field declaration, the lines following the first one are indented
+func formatField(f *Field, indent string) string {
+	decl := f.Name
+	if f.Reserved {
+		decl = "reserved"
+	}
+	if f.Specifier != "" {
+		decl += ": " + f.Specifier
+	}
+	if f.IsMagic() {
+		decl += " = " + *f.MagicStr
+	}
+	decl += " " + formatType(f.Type, indent)
+	if f.HasRange() {
+		decl += fmt.Sprintf(" [%s..%s]", *f.MinStr, *f.MaxStr)
+	}
+	if f.HasDefault() {
+		decl += " = " + *f.DefaultStr
+	}
+	if f.HasOffset() {
+		decl += " @offset " + *f.OffsetStr
+	}
+	if f.Endianness != "" {
+		decl += " @" + f.Endianness
+	}
+	if f.HasCondition() {
+		decl += " if " + *f.Condition
+	}
+	return decl + ";" + formatTrailingComment(f.TrailingComment)
+}
+
+// formatType returns the type declaration. A bit field with commented members takes
+// one line per member, otherwise the type is written in a single line.
+func formatType(t *TypeUnion, indent string) string {
+	switch {
+	case t.Bitfield != nil:
+		return t.Bitfield.Base + formatBitMembers(t.Bitfield.Items, indent)
+	case t.Array != nil:
+		size := ""
+		if t.Array.Size.Constant != nil {
+			size = *t.Array.Size.Constant
+		} else if t.Array.Size.Variable != nil {
+			size = *t.Array.Size.Variable
+		}
+		dims := ""
+		for _, d := range t.Array.Dims {
+			dims += "[" + d + "]"
+		}
+		return "[" + size + "]" + dims + t.Array.Type.Name
+	case t.String != nil:
+		if t.String.Prefix == "" {
+			return "string"
+		}
+		if t.String.MaxLenStr == nil {
+			return "string(" + t.String.Prefix + ")"
+		}
+		return "string(" + t.String.Prefix + ", " + *t.String.MaxLenStr + ")"
+	case t.Fixed != nil:
+		return "fixed(" + t.Fixed.Base + ", " + t.Fixed.FracStr + ")"
+	case t.CRC != nil:
+		if t.CRC.Algorithm == "" {
+			return t.CRC.Kind
+		}
+		return t.CRC.Kind + "(" + t.CRC.Algorithm + ")"
+	case t.Simple != nil:
+		return t.Simple.Name
+	}
+	return ""
+}
+
+// formatBitMembers returns the braced members of the bit field or the group. The members are
+// written one per line if any of them or the members of its group is commented.
+func formatBitMembers(items []*BitMember, indent string) string {
+	multiline := false
+	for _, bm := range items {
+		multiline = multiline || hasBitMemberDoc(bm)
+	}
+	var sb strings.Builder
+	sb.WriteString("{")
+	for i, bm := range items {
+		member := bm.Name + ": "
+		if bm.Reserved {
+			member = "reserved: "
+		}
+		if bm.Signed {
+			member += "signed "
+		}
+		switch {
+		case bm.IsGroup():
+			member += formatBitMembers(bm.Members, indent+indentStep)
+		default:
+			member += bm.Start
+			if bm.End != nil {
+				member += "-" + *bm.End
+			}
+		}
+		if !multiline {
+			if i > 0 {
+				sb.WriteString(", ")
+			}
+			sb.WriteString(member)
+			continue
+		}
+		if i > 0 {
+			sb.WriteString(",")
+		}
+		sb.WriteString("\n")
+		writeDoc(&sb, bm.Doc, indent+indentStep, i == 0)
+		sb.WriteString(indent + indentStep + member)
+	}
+	if multiline {
+		sb.WriteString("\n" + indent)
+	}
+	sb.WriteString("}")
+	return sb.String()
+}
+
+// hasBitMemberDoc returns true if the member or a member of its group is commented
+func hasBitMemberDoc(bm *BitMember) bool {
+	if bm.Doc != nil && len(bm.Doc.Elements) > 0 {
+		return true
+	}
+	return slices.ContainsFunc(bm.Members, hasBitMemberDoc)
+}
@@ -0,0 +1,83 @@
+package parser
+
+import (
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFormat(t *testing.T) {
+	input, err := os.ReadFile("testdata/format.pa")
+	require.NoError(t, err)
+	golden, err := os.ReadFile("testdata/format.golden.pa")
+	require.NoError(t, err)
+
+	formatted, err := Format(string(input))
+	require.NoError(t, err)
+	assert.Equal(t, string(golden), formatted)
+
+	// the canonical form is stable and describes the same device
+	again, err := Format(formatted)
+	require.NoError(t, err)
+	assert.Equal(t, formatted, again)
+
+	_, err = Parse(formatted)
+	require.NoError(t, err)
+}
+
+func TestFormatEnumCommentsAndSeparators(t *testing.T) {
+	input := `device sensor
+const limit = uint32(1_000_000);
+enum Mode uint16 {
+  OFF = 0, // turned off
+  // turned on
+  ON = 0x1_00,  /* on */
+  STANDBY = 2 // waiting
+  // no more modes
+};   // the operation mode
+register Config(0x0_1) {
+  mode Mode;
+  level uint16 [0..1_000] = 5_00;
+  data [1_0]uint8;
+};
+`
+	formatted, err := Format(input)
+	require.NoError(t, err)
+	assert.Equal(t, `device sensor
+const limit = uint32(1_000_000);
+enum Mode uint16 {
+    OFF = 0, // turned off
+    // turned on
+    ON = 0x1_00, /* on */
+    STANDBY = 2, // waiting
+    // no more modes
+}; // the operation mode
+register Config(0x0_1) {
+    mode Mode;
+    level uint16 [0..1_000] = 5_00;
+    data [1_0]uint8;
+};
+`, formatted)
+
+	again, err := Format(formatted)
+	require.NoError(t, err)
+	assert.Equal(t, formatted, again)
+
+	// the formatted device is the same one
+	orig, err := Parse(input)
+	require.NoError(t, err)
+	device, err := Parse(formatted)
+	require.NoError(t, err)
+	assert.Equal(t, orig.Constants[0].Value(), device.Constants[0].Value())
+	assert.Equal(t, int64(0x100), device.Enums[0].Members[1].Value())
+	assert.Equal(t, "// waiting", *device.Enums[0].Members[2].TrailingComment)
+	assert.Equal(t, int64(1), device.Registers[0].Number())
+	assert.Equal(t, int64(500), device.Registers[0].Body.Fields()[1].Default())
+}
+
+func TestFormatSyntaxError(t *testing.T) {
+	_, err := Format("device sensor\nregister R(1) {\n    a uint8\n};")
+	require.Error(t, err)
+}
@@ -1,7 +1,11 @@
 package parser
 
 import (
+	"errors"
 	"fmt"
+	"math"
+	"regexp"
+	"slices"
 	"strconv"
 	"strings"
 
@@ -19,29 +23,85 @@ type CommentGroup struct {
 	Elements []*CommentElement `(@@)*`
 }
 
+// CommentElement is a line comment, a /* */ block comment or an empty line
 type CommentElement struct {
+	Pos       lexer.Position
 	Comment   *string `@Comment`
 	EmptyLine *string `| @EmptyLine`
 }
 
 type Device struct {
-	Pos       lexer.Position
-	Doc       *CommentGroup `@@?`
-	Name      string        `"device" @Ident`
-	Registers []*Register   `@@*`
+	Pos        lexer.Position
+	Tokens     []lexer.Token
+	Doc        *CommentGroup `@@?`
+	Name       string        `"device" @Ident`
+	Endianness string        `( "@" @("le"|"be") )?` // the fields byte order unless annotated, big-endian by default
+	Constants  []*Constant   `@@*`                   // device-level constants, declared before the enums and registers
+	Enums      []*Enum       `( @@`
+	Registers  []*Register   `| @@`
+	Messages   []*Message    `| @@ )*`
+	// TrailingComment is the comment following the device declaration in the same line, it is
+	// parsed as the comment of the next declaration and moved here after parsing
+	TrailingComment *string
+}
+
+type Enum struct {
+	Pos             lexer.Position
+	Tokens          []lexer.Token
+	Doc             *CommentGroup `@@?`
+	Name            string        `"enum" @Ident`
+	Base            string        `@("int8"|"uint8"|"int16"|"uint16"|"int32"|"uint32"|"int64"|"uint64")`
+	Members         []*EnumMember `"{" @@ ("," @@)* ","?`
+	Tail            *CommentGroup `@@? "}"` // the comments after the last member
+	TrailingComment *string       `@End`
+}
+
+type EnumMember struct {
+	Pos      lexer.Position
+	Tokens   []lexer.Token
+	Doc      *CommentGroup `@@?`
+	Name     string        `@Ident "="`
+	ValueStr string        `@("-"? Int)`
+	// TrailingComment is the comment following the member in the same line, it is parsed as the
+	// comment preceding the next member or the closing brace, so it is moved after parsing
+	TrailingComment *string
 }
 
 type Register struct {
-	Pos       lexer.Position
-	Doc       *CommentGroup `@@?`
-	Name      string        `"register" @Ident`
-	NumberStr string        `"(" @Int ")"`
-	Specifier string        `( ":" @("r"|"w") )?`
-	Body      *RegisterBody `@@`
+	Pos             lexer.Position
+	Tokens          []lexer.Token
+	Doc             *CommentGroup `@@?`
+	Name            string        `"register" @Ident`
+	NumberStr       string        `"(" @Int ")"`
+	Specifier       string        `( ":" @("r"|"w") )?`
+	VersionStr      *string       `( "version" @Int )?` // the wire version sent before the fields, e.g. version 2
+	Body            *RegisterBody `@@`
+	TrailingComment *string       `@End`
+}
+
+// Message is the composite of the registers sent one after another in the declaration order,
+// e.g. message Frame { Control; Status; };. The message has no ID and its registers are sent
+// without their IDs
+type Message struct {
+	Pos             lexer.Position
+	Tokens          []lexer.Token
+	Doc             *CommentGroup    `@@?`
+	Name            string           `"message" @Ident`
+	Members         []*MessageMember `"{" @@* "}"`
+	TrailingComment *string          `@End`
+}
+
+// MessageMember is the register of the message
+type MessageMember struct {
+	Pos             lexer.Position
+	Tokens          []lexer.Token
+	Doc             *CommentGroup `@@?`
+	Register        string        `@Ident`
+	TrailingComment *string       `@End`
 }
 
 type RegisterBody struct {
-	Items []*BodyItem `"{" ( @@ )* "}" ";"`
+	Items []*BodyItem `"{" ( @@ )* "}"`
 }
 
 type BodyItem struct {
@@ -50,19 +110,30 @@ type BodyItem struct {
 }
 
 type Constant struct {
-	Pos      lexer.Position
-	Doc      *CommentGroup `@@?`
-	Name     string        `"const" @Ident "="`
-	Type     SimpleType    `@@`
-	ValueStr string        `"(" @Int ")" ";"`
+	Pos             lexer.Position
+	Tokens          []lexer.Token
+	Doc             *CommentGroup `@@?`
+	Name            string        `"const" @Ident "="`
+	Type            SimpleType    `@@`
+	ValueStr        string        `"(" @Int ")"`
+	TrailingComment *string       `@End`
 }
 
 type Field struct {
 	Pos             lexer.Position
-	Doc             *CommentGroup `@@?` // leading comments
-	Name            string        `@Ident`
+	Tokens          []lexer.Token
+	Doc             *CommentGroup `@@?`           // leading comments
+	Reserved        bool          `( @"reserved"` // reserved fields have no name, they are zeros on the wire
+	Name            string        `| @Ident )`
 	Specifier       string        `( ":" @("r"|"w") )?`
+	MagicStr        *string       `( "=" @Int )?` // the magic value always sent and verified when received
 	Type            *TypeUnion    `@@`
+	MinStr          *string       `( "[" @("-"? Int) ".."` // the optional range of the valid values
+	MaxStr          *string       `  @("-"? Int) "]" )?`
+	DefaultStr      *string       `( "=" @("-"? Int) )?`   // the value the new register has instead of zero
+	OffsetStr       *string       `( "@" "offset" @Int )?` // the byte offset of the field in the register
+	Endianness      string        `( "@" @("le"|"be") )?`
+	Condition       *string       `( "if" @Ident )?` // the flag the field is sent only if it is set, e.g. if flags_has_extra
 	TrailingComment *string       `@End`
 }
 
@@ -76,10 +147,15 @@ type Type interface {
 
 type SimpleType struct {
 	Name string `@Ident`
+	// Enum is the enum the type refers to, it is resolved after parsing
+	Enum *Enum
 }
 
+// ArrayType is an array of the elements of the type. The multi-dimensional arrays have the
+// constant inner dimensions after the first one, e.g. [count][8]uint8, and are sent row by row
 type ArrayType struct {
 	Size ArraySize  `"[" @@ "]"`
+	Dims []string   `( "[" @Int "]" )*`
 	Type SimpleType `@@`
 }
 
@@ -88,16 +164,86 @@ type ArraySize struct {
 	Variable *string `| @Ident`
 }
 
+// StringType is a length prefixed byte sequence. The length prefix is uint8 unless specified,
+// the maximum length is limited by the prefix type unless specified, e.g. string(uint16, 100)
+type StringType struct {
+	Prefix    string  `"string" ( "(" @("uint8"|"uint16")`
+	MaxLenStr *string `( "," @Int )? ")" )?`
+}
+
+// FixedType is a fixed-point number sent over the wire as its base integer type. The number
+// is the integer divided by 2^frac, e.g. fixed(int16, 4) holds the value in 1/16 units
+type FixedType struct {
+	Base    string `"fixed" "(" @("int8"|"uint8"|"int16"|"uint16"|"int32"|"uint32"|"int64"|"uint64")`
+	FracStr string `"," @Int ")"`
+}
+
+// CRCType is the checksum of the register bytes preceding the field, e.g. crc16(modbus). The
+// crc16 checksum is CRC-16/CCITT-FALSE unless specified, crc32 is always the IEEE one
+type CRCType struct {
+	Kind      string `@("crc16"|"crc32")`
+	Algorithm string `( "(" @("ccitt"|"modbus"|"ieee") ")" )?`
+}
+
 type BitField struct {
-	Base string      `@("uint8"|"uint16"|"uint32"|"uint64")`
-	Bits []BitMember `"{" @@ ("," @@)* "}"`
+	Base  string       `@("uint8"|"uint16"|"uint24"|"uint32"|"uint64")`
+	Items []*BitMember `"{" @@ ("," @@)* "}"` // the declared members and groups
+	// Bits are the members of the bit field with the members of the groups, which names are
+	// prefixed by the group name, e.g. ctrl_mode. They are collected after parsing
+	Bits []BitMember
 }
 
+// BitMember is a member of the bit field holding the bits or a named group of the members
+// sharing the storage of the bit field, e.g. ctrl: {enable: 0, mode: 1-2}
 type BitMember struct {
-	Doc   *CommentGroup `@@?`
-	Name  string        `@Ident ":"`
-	Start string        `@Int`
-	End   *string       `( "-" @Int )?`
+	Pos      lexer.Position
+	Tokens   []lexer.Token
+	Doc      *CommentGroup `@@?`
+	Reserved bool          `( @"reserved"` // reserved members have no name and accessors, they document unused bits
+	Name     string        `| @Ident ) ":"`
+	Members  []*BitMember  `( "{" @@ ("," @@)* "}"` // the members of the group
+	Signed   bool          `| @"signed"?`           // the member bits hold a two's complement value
+	Start    string        `  @Int`
+	End      *string       `  ( "-" @Int )? )`
+	// Group is the name of the group the member is collected from, it is empty for the
+	// members declared in the bit field itself
+	Group string
+}
+
+// BitRange is an inclusive range of bits
+type BitRange struct {
+	Start int
+	End   int
+}
+
+func (br BitRange) String() string {
+	if br.Start == br.End {
+		return strconv.Itoa(br.Start)
+	}
+	return fmt.Sprintf("%d-%d", br.Start, br.End)
+}
+
+// BitfieldGap describes the bits of a bit field which are not covered by its members
+type BitfieldGap struct {
+	Register string
+	Field    string
+	Pos      lexer.Position
+	Bits     []BitRange
+}
+
+// BitfieldOversize describes the bit field which base type is more than one size class larger
+// than its members need, e.g. uint64 for the members in the bits 0-3 wastes 7 bytes on the wire
+type BitfieldOversize struct {
+	Register string
+	Field    string
+	Pos      lexer.Position
+	Base     string // the declared base type
+	Fit      string // the smallest base type holding the members
+}
+
+// ParseOptions controls the optional validations of Parse
+type ParseOptions struct {
+	Strict bool // the bit field bits not covered by members and the oversized bit fields are errors
 }
 
 //
@@ -107,38 +253,105 @@ type BitMember struct {
 type TypeUnion struct {
 	Bitfield *BitField   `  @@`
 	Array    *ArrayType  `| @@`
+	String   *StringType `| @@`
+	Fixed    *FixedType  `| @@`
+	CRC      *CRCType    `| @@`
 	Simple   *SimpleType `| @@`
 }
 
 func (*SimpleType) isType() {}
 func (*ArrayType) isType()  {}
 func (*BitField) isType()   {}
+func (*StringType) isType() {}
+func (*FixedType) isType()  {}
+func (*CRCType) isType()    {}
 func (*TypeUnion) isType()  {} // for compatibility
 
 //
 // Parser
 //
 
-var parser = participle.MustBuild[Device](
-	participle.Lexer(lexer.MustSimple([]lexer.SimpleRule{
-		{"End", `;([ \t]+//[^\r\n]*)?`},
-		{"Comment", `//[^\r\n]*`},
-		{"EmptyLine", `\n\s*\n`},
-		{"Keyword", `\b(const|device|register)\b`},
-		{"Ident", `[a-zA-Z_][a-zA-Z0-9_-]*`},
-		{"Int", `0[xX][0-9a-fA-F]+|0[bB][01]+|\d+`},
-		{"Punct", `[{}();:,\[\]=\-]`},
-		{"Whitespace", `\s+`},
-	})),
-	participle.Elide("Whitespace"),
-	participle.Union[Type](&SimpleType{}, &ArrayType{}, &BitField{}),
-	participle.UseLookahead(4),
-)
+var pargusLexer = lexer.MustSimple([]lexer.SimpleRule{
+	// the comment in the line of the ';' is the trailing comment, whatever spaces precede it
+	{"End", `;([ \t]*(//[^\r\n]*|/\*[^\r\n]*?\*/))?`},
+	{"Comment", `//[^\r\n]*|/\*(?s:.*?)\*/`},
+	{"EmptyLine", `\n\s*\n`},
+	{"Keyword", `\b(const|device|enum|register)\b`},
+	{"Ident", `[a-zA-Z_][a-zA-Z0-9_-]*`},
+	// the letters after the decimal digits are lexed with them, so the names starting with a
+	// digit are reported as such instead of a number followed by a name
+	{"Int", `0[xX][0-9a-fA-F_]+|0[bB][01_]+|\d[\d_]*([a-zA-Z_][a-zA-Z0-9_]*)?`},
+	{"Punct", `\.\.|[{}();:,\[\]=\-@]`},
+	{"Whitespace", `\s+`},
+})
+
+var parser = newParser(removeDigitSeparators)
+
+// literalParser keeps the integer literals as they are written, so the formatter keeps their
+// digit separators
+var literalParser = newParser(checkDigitSeparators)
+
+// newParser returns the parser of the devices mapping the integer literals by intMapper
+func newParser(intMapper participle.Mapper) *participle.Parser[Device] {
+	return participle.MustBuild[Device](
+		participle.Lexer(pargusLexer),
+		participle.Elide("Whitespace"),
+		participle.Union[Type](&SimpleType{}, &ArrayType{}, &BitField{}, &StringType{}, &FixedType{}, &CRCType{}),
+		participle.UseLookahead(4),
+		participle.Map(rejectDigitFirstNames, "Int"),
+		participle.Map(intMapper, "Int"),
+	)
+}
+
+// numberToken matches the Int tokens which are the numbers
+var numberToken = regexp.MustCompile(`^(0[xX][0-9a-fA-F_]+|0[bB][01_]+|\d[\d_]*)$`)
+
+// rejectDigitFirstNames reports the Int token which is not a number, it is the name starting
+// with a digit like 2nd
+func rejectDigitFirstNames(token lexer.Token) (lexer.Token, error) {
+	if !numberToken.MatchString(token.Value) {
+		return token, participle.Errorf(token.Pos, "invalid name %s, the names must start with a letter or an underscore", token.Value)
+	}
+	return token, nil
+}
+
+// removeDigitSeparators removes the underscores separating the digits of the integer literal like
+// 0xAA_55 or 1_000, so the AST and the generated code have the plain literals. The separators
+// follow the Go rules, an underscore must separate the digits or the base prefix and a digit
+func removeDigitSeparators(token lexer.Token) (lexer.Token, error) {
+	token, err := checkDigitSeparators(token)
+	token.Value = strings.ReplaceAll(token.Value, "_", "")
+	return token, err
+}
+
+// checkDigitSeparators reports the integer literal which underscores do not separate the digits
+func checkDigitSeparators(token lexer.Token) (lexer.Token, error) {
+	if !strings.Contains(token.Value, "_") {
+		return token, nil
+	}
+	if _, err := strconv.ParseUint(token.Value, 0, 64); errors.Is(err, strconv.ErrSyntax) {
+		return token, participle.Errorf(token.Pos, "invalid number %s, the underscores must separate the digits", token.Value)
+	}
+	return token, nil
+}
+
+// MaxRegisterNumber is the maximum register number, the register ID is sent over the wire as a single byte
+const MaxRegisterNumber = 255
+
+// MaxRegisterVersion is the maximum register wire version, the version is sent over the wire as a single byte
+const MaxRegisterVersion = 255
+
+// MaxArrayLength is the maximum number of the constant-length array elements, including the elements
+// of all the dimensions, so the array buffer size cannot overflow int
+const MaxArrayLength = 65535
+
+// MaxFieldOffset is the maximum byte offset of the field placed by the offset annotation
+const MaxFieldOffset = 65535
 
 // IsBuiltinType returns true if the type name is a built-in simple type
 func IsBuiltinType(typeName string) bool {
 	switch typeName {
-	case "int8", "uint8", "int16", "uint16", "int32", "uint32", "int64", "uint64", "float32", "float64":
+	case "int8", "uint8", "int16", "uint16", "int24", "uint24", "int32", "uint32", "int64", "uint64", "float16", "float32", "float64":
 		return true
 	default:
 		return false
@@ -147,67 +360,304 @@ func IsBuiltinType(typeName string) bool {
 
 // IsRegisterRef returns true if this SimpleType is actually a reference to a register
 func (st *SimpleType) IsRegisterRef() bool {
-	return !IsBuiltinType(st.Name)
+	return !IsBuiltinType(st.Name) && st.Enum == nil
+}
+
+// IsEnum returns true if this SimpleType refers to an enum
+func (st *SimpleType) IsEnum() bool {
+	return st.Enum != nil
 }
 
+// Parse parses and validates the device description. The errors with a position in the
+// input are returned as *Error with the offending source line.
 func Parse(input string) (*Device, error) {
-	// trim the input
-	input = trimString(input)
+	return ParseWithOptions(input, ParseOptions{})
+}
+
+// ParseWithOptions parses and validates the input like Parse does, the options enable the
+// optional validations
+func ParseWithOptions(input string, opts ParseOptions) (*Device, error) {
+	device, err := parse(input, opts)
+	if err != nil {
+		return nil, withSource(err, input)
+	}
+	return device, nil
+}
 
-	device, err := parser.ParseString("", input)
+func parse(input string, opts ParseOptions) (*Device, error) {
+	device, err := parseAST(parser, input)
 	if err != nil {
 		return nil, err
 	}
 
-	// Process trailing comments - extract comment part from TrailingComment tokens
-	for _, register := range device.Registers {
-		for _, field := range register.Body.Fields() {
-			if field.TrailingComment == nil {
-				continue
-			}
-			commentStart := strings.Index(*field.TrailingComment, "//")
-			if commentStart == -1 {
-				field.TrailingComment = cast.StringPtr("")
-				continue
-			}
-			extractedComment := (*field.TrailingComment)[commentStart:]
-			field.TrailingComment = cast.StringPtr(extractedComment)
-		}
+	// The semantic errors are collected, so all of them are reported at once
+	var errs []error
+
+	// Validate the names are valid identifiers of the generated code
+	if err := device.validateNames(); err != nil {
+		errs = append(errs, err)
+	}
+
+	// Validate enums and resolve the fields types referring to them
+	if err := device.validateAndResolveEnums(); err != nil {
+		errs = append(errs, err)
+	}
+
+	// Validate the constants names and values
+	if err := device.validateConstants(); err != nil {
+		errs = append(errs, err)
 	}
 
-	// Validate register numbers are unique
+	// Validate register names and numbers are unique
+	registerNames := make(map[string]bool)
 	registerNumbers := make(map[int64]bool)
 	for _, r := range device.Registers {
-		val := r.Number()
-		if registerNumbers[val] {
-			return nil, fmt.Errorf("duplicate register number %d", val)
+		if registerNames[r.Name] {
+			errs = append(errs, errorAt(r.DeclPos(), "duplicate register '%s'", r.Name))
+		}
+		registerNames[r.Name] = true
+
+		val, err := strconv.ParseInt(r.NumberStr, 0, 64)
+		if err != nil || val < 0 || val > MaxRegisterNumber {
+			errs = append(errs, errorAt(r.DeclPos(), "register '%s' number %s is out of range, it must be between 0 and %d",
+				r.Name, r.NumberStr, MaxRegisterNumber))
+		} else if registerNumbers[val] {
+			errs = append(errs, errorAt(r.DeclPos(), "duplicate register number %d", val))
 		}
 		registerNumbers[val] = true
 
-		// Validate field specifiers compatibility with register specifier
-		if err := r.validateAndUpdateFieldSpecifiers(); err != nil {
-			return nil, err
+		if err := r.validate(device.Endianness, opts); err != nil {
+			errs = append(errs, err)
 		}
+	}
+
+	// Validate the messages refer to the registers
+	if err := device.validateMessages(); err != nil {
+		errs = append(errs, err)
+	}
+
+	if len(errs) > 0 {
+		return nil, joinErrors(errs)
+	}
+
+	// Validate register references and check for circular dependencies, it relies on
+	// the resolved fields, so it runs only for an otherwise valid device
+	if err := device.validateRegisterReferences(); err != nil {
+		return nil, err
+	}
+
+	return device, nil
+}
 
-		// Validate bit fields
-		if err := r.validateBitFields(); err != nil {
-			return nil, err
+// validateNames reports the names with hyphens, which are not valid identifiers in the generated
+// code. Only the device name may have them, e.g. device argus-p, the generators use it as is or
+// replace the hyphens
+func (d *Device) validateNames() error {
+	symbols := pargusLexer.Symbols()
+	var errs []error
+	var prev string
+	for _, t := range d.Tokens {
+		switch t.Type {
+		case symbols["Comment"], symbols["EmptyLine"], symbols["Whitespace"]:
+			continue
+		case symbols["Ident"]:
+			if strings.Contains(t.Value, "-") && prev != "device" {
+				errs = append(errs, errorAt(t.Pos, "invalid name '%s', the names may contain only letters, digits and underscores", t.Value))
+			}
 		}
+		prev = t.Value
+	}
+	if len(errs) > 0 {
+		return joinErrors(errs)
+	}
+	return nil
+}
 
-		// Validate arrays
-		if err := r.validateArrays(); err != nil {
-			return nil, err
+// validate runs the semantic checks of the register fields. The specifiers, bit fields and
+// arrays checks are independent, so all their errors are returned. The other checks rely on
+// them and run only if they pass, up to the first failure
+func (r *Register) validate(endianness string, opts ParseOptions) error {
+	var errs []error
+	if err := r.validateAndUpdateFieldSpecifiers(); err != nil {
+		errs = append(errs, err)
+	}
+	if err := r.validateBitFields(); err != nil {
+		errs = append(errs, err)
+	} else if opts.Strict {
+		if err := r.validateBitFieldGaps(); err != nil {
+			errs = append(errs, err)
+		}
+		if err := r.validateBitFieldSizes(); err != nil {
+			errs = append(errs, err)
 		}
 	}
+	if err := r.validateArrays(); err != nil {
+		errs = append(errs, err)
+	}
+	if err := r.validateVersion(); err != nil {
+		errs = append(errs, err)
+	}
+	if len(errs) > 0 {
+		return joinErrors(errs)
+	}
 
-	// Validate register references and check for circular dependencies
-	if err := device.validateRegisterReferences(); err != nil {
+	// Validate endianness annotations
+	if err := r.validateEndianness(); err != nil {
+		return err
+	}
+	r.updateFieldsEndianness(endianness)
+
+	// Validate strings
+	if err := r.validateStrings(); err != nil {
+		return err
+	}
+
+	// Validate reserved fields
+	if err := r.validateReserved(); err != nil {
+		return err
+	}
+
+	// Validate magic fields
+	if err := r.validateMagic(); err != nil {
+		return err
+	}
+
+	// Validate fixed-point fields
+	if err := r.validateFixed(); err != nil {
+		return err
+	}
+
+	// Validate checksum fields
+	if err := r.validateCRC(); err != nil {
+		return err
+	}
+
+	// Validate the conditional fields
+	if err := r.validateConditions(); err != nil {
+		return err
+	}
+
+	// Validate the fields offsets
+	if err := r.validateOffsets(); err != nil {
+		return err
+	}
+
+	// Validate the fields ranges
+	if err := r.validateRanges(); err != nil {
+		return err
+	}
+
+	// Validate the default values, they must fit the ranges
+	return r.validateDefaults()
+}
+
+// parseAST parses the input into the AST by p without any validation
+func parseAST(p *participle.Parser[Device], input string) (*Device, error) {
+	// normalize the CRLF and CR line endings, so the comments never contain '\r'
+	input = normalizeLineEndings(input)
+
+	// trim the input
+	input = trimString(input)
+
+	device, err := p.ParseString("", input)
+	if err != nil {
 		return nil, err
 	}
 
+	// Process trailing comments - extract comment part from TrailingComment tokens
+	for _, c := range device.Constants {
+		c.TrailingComment = endComment(c.TrailingComment)
+	}
+	for _, e := range device.Enums {
+		e.TrailingComment = endComment(e.TrailingComment)
+		e.moveMemberComments()
+	}
+	for _, register := range device.Registers {
+		register.TrailingComment = endComment(register.TrailingComment)
+		for _, c := range register.Body.Constants() {
+			c.TrailingComment = endComment(c.TrailingComment)
+		}
+		for _, field := range register.Body.Fields() {
+			field.TrailingComment = endComment(field.TrailingComment)
+			if field.Type.Bitfield != nil {
+				field.Type.Bitfield.collectBits()
+			}
+		}
+	}
+	for _, m := range device.Messages {
+		m.TrailingComment = endComment(m.TrailingComment)
+		for _, mm := range m.Members {
+			mm.TrailingComment = endComment(mm.TrailingComment)
+		}
+	}
+	device.moveTrailingComment()
 	return device, nil
 }
 
+// endComment returns the comment of the End token without the trailing spaces, which is empty
+// if the token is only ';'
+func endComment(end *string) *string {
+	if end == nil {
+		return nil
+	}
+	commentStart := strings.Index(*end, "/")
+	if commentStart == -1 {
+		return cast.StringPtr("")
+	}
+	return cast.StringPtr(strings.TrimRight((*end)[commentStart:], " \t"))
+}
+
+// moveMemberComments moves the comment in the line of the enum member from the comments of the
+// next member or the closing brace to the member trailing comment
+func (e *Enum) moveMemberComments() {
+	for i, m := range e.Members {
+		next := e.Tail
+		if i+1 < len(e.Members) {
+			next = e.Members[i+1].Doc
+		}
+		if next == nil || len(next.Elements) == 0 || next.Elements[0].Comment == nil {
+			continue
+		}
+		if next.Elements[0].Pos.Line != m.Tokens[len(m.Tokens)-1].Pos.Line {
+			continue
+		}
+		m.TrailingComment = next.Elements[0].Comment
+		next.Elements = next.Elements[1:]
+	}
+}
+
+// moveTrailingComment moves the comment in the line of the device declaration from the comments
+// of the next declaration to the device trailing comment
+func (d *Device) moveTrailingComment() {
+	line := declarationPos(d.Pos, d.Tokens).Line
+	var doc *CommentGroup
+	if len(d.Constants) > 0 {
+		doc = d.Constants[0].Doc
+	} else {
+		// the enums, registers and messages may be interleaved, the first of them is the next one
+		offset := -1
+		next := func(pos lexer.Position, cg *CommentGroup) {
+			if offset == -1 || pos.Offset < offset {
+				offset, doc = pos.Offset, cg
+			}
+		}
+		if len(d.Enums) > 0 {
+			next(d.Enums[0].Pos, d.Enums[0].Doc)
+		}
+		if len(d.Registers) > 0 {
+			next(d.Registers[0].Pos, d.Registers[0].Doc)
+		}
+		if len(d.Messages) > 0 {
+			next(d.Messages[0].Pos, d.Messages[0].Doc)
+		}
+	}
+	if doc == nil || len(doc.Elements) == 0 || doc.Elements[0].Comment == nil || doc.Elements[0].Pos.Line != line {
+		return
+	}
+	d.TrailingComment = doc.Elements[0].Comment
+	doc.Elements = doc.Elements[1:]
+}
+
 func trimString(input string) string {
 	// Split into lines
 	lines := strings.Split(input, "\n")
@@ -253,14 +703,56 @@ func (rb *RegisterBody) Fields() []*Field {
 	return fields
 }
 
-func (r *Register) Number() int64 {
-	val, err := strconv.ParseInt(r.NumberStr, 0, 64)
-	if err != nil {
-		panic(fmt.Sprintf("invalid register number %s", r.NumberStr))
+// DeclPos returns the position of the register declaration, skipping its leading comments
+func (r *Register) DeclPos() lexer.Position {
+	return declarationPos(r.Pos, r.Tokens)
+}
+
+// declarationPos returns the position of the first token which is not a comment,
+// an empty line or a whitespace, or pos if there is no such token
+func declarationPos(pos lexer.Position, tokens []lexer.Token) lexer.Position {
+	symbols := pargusLexer.Symbols()
+	for _, t := range tokens {
+		switch t.Type {
+		case symbols["Comment"], symbols["EmptyLine"], symbols["Whitespace"]:
+			continue
+		}
+		return t.Pos
 	}
+	return pos
+}
+
+// Number returns the register number. Parse reports the numbers out of the register numbers
+// range, the number of an unchecked register beyond int64 is clamped to math.MaxInt64
+func (r *Register) Number() int64 {
+	val, _ := strconv.ParseInt(r.NumberStr, 0, 64)
 	return val
 }
 
+// HasVersion returns true if the register is sent with its wire version, e.g.
+// register Control(1) version 2 { ... }
+func (r *Register) HasVersion() bool {
+	return r.VersionStr != nil
+}
+
+// Version returns the wire version of the register, the register must have the version
+func (r *Register) Version() uint8 {
+	val, _ := strconv.ParseUint(*r.VersionStr, 0, 8)
+	return uint8(val)
+}
+
+// validateVersion checks that the register version fits the single byte it is sent in
+func (r *Register) validateVersion() error {
+	if !r.HasVersion() {
+		return nil
+	}
+	if val, err := strconv.ParseUint(*r.VersionStr, 0, 64); err != nil || val > MaxRegisterVersion {
+		return errorAt(r.DeclPos(), "register '%s' version %s is out of range, it must be between 0 and %d",
+			r.Name, *r.VersionStr, MaxRegisterVersion)
+	}
+	return nil
+}
+
 // validateAndUpdateFieldSpecifiers checks if field specifiers are compatible with register specifier
 func (r *Register) validateAndUpdateFieldSpecifiers() error {
 	registerSpec := r.Specifier
@@ -280,11 +772,11 @@ func (r *Register) validateAndUpdateFieldSpecifiers() error {
 
 		// Check compatibility
 		if registerSpec == "r" && fieldSpec == "w" {
-			return fmt.Errorf("field '%s' in register '%s' cannot be write-only because register is read-only", field.Name, r.Name)
+			return errorAt(field.DeclPos(), "field '%s' in register '%s' cannot be write-only because register is read-only", field.Name, r.Name)
 		}
 
 		if registerSpec == "w" && fieldSpec == "r" {
-			return fmt.Errorf("field '%s' in register '%s' cannot be read-only because register is write-only", field.Name, r.Name)
+			return errorAt(field.DeclPos(), "field '%s' in register '%s' cannot be read-only because register is write-only", field.Name, r.Name)
 		}
 	}
 
@@ -300,34 +792,107 @@ func (r *Register) validateBitFields() error {
 
 			// Check that base type is unsigned
 			if !isUnsignedType(bitField.Base) {
-				return fmt.Errorf("bit field '%s' in register '%s' must use unsigned integer type, got '%s'",
+				return errorAt(field.DeclPos(), "bit field '%s' in register '%s' must use unsigned integer type, got '%s'",
 					field.Name, r.Name, bitField.Base)
 			}
 
 			// Get the size of the base type in bits
 			baseTypeBits := getTypeSizeInBits(bitField.Base)
 
+			// The 24-bit integers are decoded into the 32-bit types, they cannot hold the bit members
+			if baseTypeBits == 24 {
+				return errorAt(field.DeclPos(), "bit field '%s' in register '%s' cannot use the 24-bit type '%s'",
+					field.Name, r.Name, bitField.Base)
+			}
+
+			// Check the groups, they hold only the members
+			for _, item := range bitField.Items {
+				if !item.IsGroup() {
+					continue
+				}
+				if item.Reserved {
+					return errorAt(item.DeclPos(), "bit field '%s' in register '%s': reserved member cannot be a group",
+						field.Name, r.Name)
+				}
+				for _, m := range item.Members {
+					if m.IsGroup() {
+						return errorAt(m.DeclPos(), "bit field '%s' in register '%s': group '%s' cannot contain group '%s'",
+							field.Name, r.Name, item.Name, m.label())
+					}
+				}
+			}
+
+			// Check that the names of the members and the groups are unique, the names of the
+			// group members are prefixed by the group name, so they may collide with the other members
+			names := make(map[string]bool)
+			for _, item := range bitField.Items {
+				if item.IsGroup() {
+					if names[item.Name] {
+						return errorAt(item.DeclPos(), "bit field '%s' in register '%s': duplicate member '%s'",
+							field.Name, r.Name, item.Name)
+					}
+					names[item.Name] = true
+				}
+			}
+			for _, bitMember := range bitField.Bits {
+				if bitMember.Reserved {
+					continue
+				}
+				if names[bitMember.Name] {
+					return errorAt(bitMember.DeclPos(), "bit field '%s' in register '%s': duplicate member '%s'",
+						field.Name, r.Name, bitMember.Name)
+				}
+				names[bitMember.Name] = true
+			}
+
 			// Validate each bit member
 			for _, bitMember := range bitField.Bits {
+				// The bit numbers beyond int64 are clamped, so they are reported by the literals
+				if !bitMember.validBitNumbers() {
+					return errorAt(bitMember.DeclPos(), "bit field '%s' in register '%s': bit range %s exceeds size of base type '%s' (%d bits)",
+						field.Name, r.Name, bitMember.bitRange(), bitField.Base, baseTypeBits)
+				}
 				endBit := bitMember.EndBit()
 
 				// Check that bit range doesn't exceed base type size
 				if endBit >= baseTypeBits {
-					return fmt.Errorf("bit field '%s' in register '%s': bit range %s-%d exceeds size of base type '%s' (%d bits)",
-						field.Name, r.Name, bitMember.Start, &endBit, bitField.Base, baseTypeBits)
-				}
-
-				// Check that start bit is not negative
-				if bitMember.StartBit() < 0 {
-					return fmt.Errorf("bit field '%s' in register '%s': bit position cannot be negative, got %s",
-						field.Name, r.Name, bitMember.Start)
+					return errorAt(bitMember.DeclPos(), "bit field '%s' in register '%s': bit range %s-%d exceeds size of base type '%s' (%d bits)",
+						field.Name, r.Name, bitMember.Start, endBit, bitField.Base, baseTypeBits)
 				}
 
 				// Check that start <= end
 				if bitMember.StartBit() > endBit {
-					return fmt.Errorf("bit field '%s' in register '%s': start bit %s cannot be greater than end bit %d",
+					return errorAt(bitMember.DeclPos(), "bit field '%s' in register '%s': start bit %s cannot be greater than end bit %d",
 						field.Name, r.Name, bitMember.Start, endBit)
 				}
+
+				if bitMember.Reserved && bitMember.Signed {
+					return errorAt(bitMember.DeclPos(), "bit field '%s' in register '%s': reserved member cannot be signed",
+						field.Name, r.Name)
+				}
+
+				// A single signed bit could hold only 0 and -1
+				if bitMember.Signed && bitMember.StartBit() == endBit {
+					return errorAt(bitMember.DeclPos(), "bit field '%s' in register '%s': signed member '%s' must have at least 2 bits",
+						field.Name, r.Name, bitMember.Name)
+				}
+			}
+
+			// Check that bit members don't overlap
+			for i := range bitField.Bits {
+				for j := i + 1; j < len(bitField.Bits); j++ {
+					a, b := &bitField.Bits[i], &bitField.Bits[j]
+					from, to := max(a.StartBit(), b.StartBit()), min(a.EndBit(), b.EndBit())
+					if from > to {
+						continue
+					}
+					overlap := strconv.Itoa(from)
+					if to > from {
+						overlap = fmt.Sprintf("%d-%d", from, to)
+					}
+					return errorAt(b.DeclPos(), "bit field '%s' in register '%s': members '%s' and '%s' overlap in bits %s",
+						field.Name, r.Name, a.label(), b.label(), overlap)
+				}
 			}
 		}
 	}
@@ -335,51 +900,252 @@ func (r *Register) validateBitFields() error {
 	return nil
 }
 
-func (bm *BitMember) EndBit() int {
-	if bm.End != nil {
-		val, err := strconv.ParseInt(*bm.End, 0, 64)
-		if err != nil {
-			panic(fmt.Sprintf("invalid bit member end %s", *bm.End))
+// collectBits fills the Bits of the bit field with its members and the members of its groups
+func (bf *BitField) collectBits() {
+	bf.Bits = nil
+	for _, item := range bf.Items {
+		if !item.IsGroup() {
+			bf.Bits = append(bf.Bits, *item)
+			continue
+		}
+		for _, m := range item.Members {
+			if m.IsGroup() {
+				// the nested groups are reported by the validation
+				continue
+			}
+			bm := *m
+			if !bm.Reserved {
+				bm.Name = item.Name + "_" + bm.Name
+			}
+			bm.Group = item.Name
+			bf.Bits = append(bf.Bits, bm)
 		}
-		return int(val)
 	}
-	return bm.StartBit()
 }
 
-func (bm *BitMember) StartBit() int {
-	val, err := strconv.ParseInt(bm.Start, 0, 64)
-	if err != nil {
-		panic(fmt.Sprintf("invalid bit member start %s", bm.Start))
+// IsGroup returns true if the member is a group of the members
+func (bm *BitMember) IsGroup() bool {
+	return bm.Members != nil
+}
+
+// validateBitFieldGaps checks that every bit of the bit fields is covered by a member, the
+// unused bits must be covered by reserved members explicitly
+func (r *Register) validateBitFieldGaps() error {
+	for _, field := range r.Body.Fields() {
+		if field.Type.Bitfield == nil {
+			continue
+		}
+		if gaps := field.Type.Bitfield.Gaps(); len(gaps) > 0 {
+			return errorAt(field.DeclPos(), "bit field '%s' in register '%s': bits %s are not used, cover them with a reserved member",
+				field.Name, r.Name, FormatBitRanges(gaps))
+		}
 	}
-	return int(val)
+	return nil
 }
 
-func (c *Constant) Value() int64 {
-	val, err := strconv.ParseInt(c.ValueStr, 0, 64)
-	if err != nil {
-		panic(fmt.Sprintf("invalid constant value %s", c.ValueStr))
+// validateBitFieldSizes checks that the base types of the bit fields are at most one size class
+// larger than their members need
+func (r *Register) validateBitFieldSizes() error {
+	for _, field := range r.Body.Fields() {
+		if field.Type.Bitfield == nil || !field.Type.Bitfield.IsOversized() {
+			continue
+		}
+		return errorAt(field.DeclPos(), "bit field '%s' in register '%s': base type '%s' is oversized, the members fit '%s'",
+			field.Name, r.Name, field.Type.Bitfield.Base, field.Type.Bitfield.FitType())
 	}
-	return val
+	return nil
 }
 
-// isUnsignedType checks if a type is an unsigned integer type
-func isUnsignedType(typeName string) bool {
-	switch typeName {
-	case "uint8", "uint16", "uint32", "uint64":
-		return true
-	default:
-		return false
+// bitfieldBaseTypes are the size classes of the bit field base types in the ascending order
+var bitfieldBaseTypes = []string{"uint8", "uint16", "uint32", "uint64"}
+
+// FitType returns the smallest base type holding the named members of the bit field. The reserved
+// members only document the unused bits, so they do not need to fit. The bit field must be validated.
+func (bf *BitField) FitType() string {
+	highest := 0
+	for _, bm := range bf.Bits {
+		if !bm.Reserved {
+			highest = max(highest, bm.EndBit())
+		}
+	}
+	for _, typ := range bitfieldBaseTypes {
+		if highest < getTypeSizeInBits(typ) {
+			return typ
+		}
 	}
+	return bf.Base
 }
 
-// getTypeSizeInBits returns the size of a type in bits
-func getTypeSizeInBits(typeName string) int {
-	switch typeName {
-	case "uint8":
-		return 8
-	case "uint16":
-		return 16
-	case "uint32":
+// IsOversized returns true if the base type is more than one size class larger than the members
+// need, e.g. uint32 or uint64 for the members fitting uint8. The bit field must be validated.
+func (bf *BitField) IsOversized() bool {
+	return slices.Index(bitfieldBaseTypes, bf.Base)-slices.Index(bitfieldBaseTypes, bf.FitType()) > 1
+}
+
+// Gaps returns the bit ranges of the base type which are not covered by the members, including
+// the reserved ones. The bit field must be validated.
+func (bf *BitField) Gaps() []BitRange {
+	used := make([]bool, getTypeSizeInBits(bf.Base))
+	for _, bm := range bf.Bits {
+		for i := bm.StartBit(); i <= bm.EndBit(); i++ {
+			used[i] = true
+		}
+	}
+	var res []BitRange
+	for i := 0; i < len(used); i++ {
+		if used[i] {
+			continue
+		}
+		start := i
+		for i+1 < len(used) && !used[i+1] {
+			i++
+		}
+		res = append(res, BitRange{Start: start, End: i})
+	}
+	return res
+}
+
+// AnalyzeBitfields returns the unused bits of all the bit fields of the parsed device
+func AnalyzeBitfields(d *Device) []BitfieldGap {
+	var res []BitfieldGap
+	for _, r := range d.Registers {
+		for _, field := range r.Body.Fields() {
+			if field.Type.Bitfield == nil {
+				continue
+			}
+			if gaps := field.Type.Bitfield.Gaps(); len(gaps) > 0 {
+				res = append(res, BitfieldGap{Register: r.Name, Field: field.Name, Pos: field.DeclPos(), Bits: gaps})
+			}
+		}
+	}
+	return res
+}
+
+// AnalyzeBitfieldSizes returns the bit fields of the parsed device which base types are more than
+// one size class larger than their members need
+func AnalyzeBitfieldSizes(d *Device) []BitfieldOversize {
+	var res []BitfieldOversize
+	for _, r := range d.Registers {
+		for _, field := range r.Body.Fields() {
+			if field.Type.Bitfield == nil || !field.Type.Bitfield.IsOversized() {
+				continue
+			}
+			bf := field.Type.Bitfield
+			res = append(res, BitfieldOversize{Register: r.Name, Field: field.Name, Pos: field.DeclPos(), Base: bf.Base, Fit: bf.FitType()})
+		}
+	}
+	return res
+}
+
+// FormatBitRanges returns the bit ranges separated by commas, e.g. 2-3, 5
+func FormatBitRanges(ranges []BitRange) string {
+	parts := make([]string, len(ranges))
+	for i, br := range ranges {
+		parts[i] = br.String()
+	}
+	return strings.Join(parts, ", ")
+}
+
+// DeclPos returns the position of the bit member declaration, skipping its leading comments
+func (bm *BitMember) DeclPos() lexer.Position {
+	return declarationPos(bm.Pos, bm.Tokens)
+}
+
+// label returns the member name used in the error messages
+func (bm *BitMember) label() string {
+	if bm.Reserved {
+		return "reserved"
+	}
+	return bm.Name
+}
+
+// EndBit returns the last bit of the member. Parse reports the bits beyond the base type, the
+// bit of an unchecked member beyond int is clamped to math.MaxInt
+func (bm *BitMember) EndBit() int {
+	if bm.End != nil {
+		return bitNumber(*bm.End)
+	}
+	return bm.StartBit()
+}
+
+// StartBit returns the first bit of the member, it is clamped like EndBit
+func (bm *BitMember) StartBit() int {
+	return bitNumber(bm.Start)
+}
+
+// validBitNumbers returns true if the start and end bits of the member fit int
+func (bm *BitMember) validBitNumbers() bool {
+	if _, err := strconv.ParseInt(bm.Start, 0, strconv.IntSize); err != nil {
+		return false
+	}
+	if bm.End != nil {
+		if _, err := strconv.ParseInt(*bm.End, 0, strconv.IntSize); err != nil {
+			return false
+		}
+	}
+	return true
+}
+
+// bitRange returns the bits of the member as they are declared
+func (bm *BitMember) bitRange() string {
+	if bm.End != nil {
+		return bm.Start + "-" + *bm.End
+	}
+	return bm.Start
+}
+
+// bitNumber returns the value of the bit number literal, the values beyond int are clamped
+func bitNumber(s string) int {
+	val, _ := strconv.ParseInt(s, 0, strconv.IntSize)
+	return int(val)
+}
+
+// DeclPos returns the position of the constant declaration, skipping its leading comments
+func (c *Constant) DeclPos() lexer.Position {
+	return declarationPos(c.Pos, c.Tokens)
+}
+
+// Value returns the constant value, the uint64 values above math.MaxInt64 wrap around to the
+// negative ones. Parse reports the values out of the range of the constant type
+func (c *Constant) Value() int64 {
+	val, _ := strconv.ParseUint(c.ValueStr, 0, 64)
+	return int64(val)
+}
+
+// validateValue checks that the constant value fits its type, the values of the non-integer
+// types must fit uint64
+func (c *Constant) validateValue() error {
+	val, err := strconv.ParseUint(c.ValueStr, 0, 64)
+	fits := err == nil
+	if fits && c.Type.Name != "uint64" && getTypeSizeInBits("u"+strings.TrimPrefix(c.Type.Name, "u")) > 0 {
+		fits = val <= math.MaxInt64 && fitsType(int64(val), c.Type.Name)
+	}
+	if !fits {
+		return errorAt(c.DeclPos(), "constant '%s' value %s is out of range of type '%s'", c.Name, c.ValueStr, c.Type.Name)
+	}
+	return nil
+}
+
+// isUnsignedType checks if a type is an unsigned integer type
+func isUnsignedType(typeName string) bool {
+	switch typeName {
+	case "uint8", "uint16", "uint24", "uint32", "uint64":
+		return true
+	default:
+		return false
+	}
+}
+
+// getTypeSizeInBits returns the size of a type in bits
+func getTypeSizeInBits(typeName string) int {
+	switch typeName {
+	case "uint8":
+		return 8
+	case "uint16":
+		return 16
+	case "uint24":
+		return 24
+	case "uint32":
 		return 32
 	case "uint64":
 		return 64
@@ -403,6 +1169,9 @@ func (r *Register) FindFieldByName(fieldName string, currentFieldIndex int) (*Fi
 		// Check if it's a bitfield with matching member names
 		if field.Type.Bitfield != nil {
 			for _, bitMember := range field.Type.Bitfield.Bits {
+				if bitMember.Reserved {
+					continue
+				}
 				// Check if it's a bitfield reference (fieldName_bitMemberName)
 				bitFieldRefName := field.Name + "_" + bitMember.Name
 				if fieldName == bitFieldRefName {
@@ -415,8 +1184,31 @@ func (r *Register) FindFieldByName(fieldName string, currentFieldIndex int) (*Fi
 	return nil, nil
 }
 
+// undefinedSizeFieldError returns the error of the variable-length array referring to the size
+// field it cannot use. The size must be known before the array is decoded, so the size field declared
+// after the array is reported with the positions of both declarations
+func (r *Register) undefinedSizeFieldError(array *Field, fieldName string) error {
+	if later, bitMember := r.FindFieldByName(fieldName, len(r.Body.Fields())); later != nil {
+		pos := later.DeclPos()
+		if bitMember != nil {
+			pos = bitMember.DeclPos()
+		}
+		return errorAt(array.DeclPos(), "variable-length array '%s' in register '%s' references size field '%s' declared after it at %s, the size field must be declared before the array",
+			array.Name, r.Name, fieldName, pos)
+	}
+	for _, f := range r.Body.Fields() {
+		if f.Name == fieldName && f.Type.Bitfield != nil {
+			return errorAt(array.DeclPos(), "variable-length array '%s' in register '%s' references bit field '%s', a bit field member is referenced as '%s_<member>'",
+				array.Name, r.Name, fieldName, fieldName)
+		}
+	}
+	return errorAt(array.DeclPos(), "variable-length array '%s' in register '%s' references undefined field '%s'",
+		array.Name, r.Name, fieldName)
+}
+
 // validateArrays validates that variable-length arrays use unsigned integer types for size
-// and that referenced fields are declared before the array
+// and that referenced fields are declared before the array. The multi-dimensional arrays
+// must have positive inner dimensions and built-in elements
 func (r *Register) validateArrays() error {
 	fields := r.Body.Fields()
 	for i, field := range fields {
@@ -425,6 +1217,36 @@ func (r *Register) validateArrays() error {
 		}
 		arrayType := field.Type.Array
 
+		label := fmt.Sprintf("array '%s'", field.Name)
+		if field.Reserved {
+			label = "reserved array"
+		}
+		length := int64(1)
+		if size := arrayType.Size.Constant; size != nil {
+			val, err := strconv.ParseInt(*size, 0, 64)
+			if err != nil || val <= 0 {
+				return errorAt(field.DeclPos(), "%s in register '%s': size %s must be a positive number",
+					label, r.Name, *size)
+			}
+			length = min(val, MaxArrayLength+1)
+		}
+		for _, d := range arrayType.Dims {
+			val, err := strconv.ParseInt(d, 0, 64)
+			if err != nil || val <= 0 {
+				return errorAt(field.DeclPos(), "%s in register '%s': dimension %s must be a positive number",
+					label, r.Name, d)
+			}
+			length = min(length*min(val, MaxArrayLength+1), MaxArrayLength+1)
+		}
+		if length > MaxArrayLength {
+			return errorAt(field.DeclPos(), "%s in register '%s' is too large, it cannot have more than %d elements",
+				label, r.Name, MaxArrayLength)
+		}
+		if arrayType.IsMultiDim() && !IsBuiltinType(arrayType.Type.Name) {
+			return errorAt(field.DeclPos(), "multi-dimensional array '%s' in register '%s' must have a built-in element type, got '%s'",
+				field.Name, r.Name, arrayType.Type.Name)
+		}
+
 		if arrayType.Size.Variable == nil {
 			// this is a constant-length array
 			continue
@@ -434,15 +1256,603 @@ func (r *Register) validateArrays() error {
 		fieldName := cast.String(arrayType.Size.Variable, "")
 
 		// This is a field reference - check if the referenced field exists and is declared before this array
-		exists, _ := r.FindFieldByName(fieldName, i)
+		exists, bitMember := r.FindFieldByName(fieldName, i)
 		if exists == nil {
-			return fmt.Errorf("variable-length array '%s' in register '%s' references undefined field '%s'",
+			return r.undefinedSizeFieldError(field, fieldName)
+		}
+
+		// Bit members are unsigned, a regular size field must have an unsigned type as well
+		if bitMember == nil && !isUnsignedType(exists.Type.Simple.Name) {
+			return errorAt(field.DeclPos(), "variable-length array '%s' in register '%s' size field '%s' must be an unsigned integer, got '%s'",
+				field.Name, r.Name, fieldName, exists.Type.Simple.Name)
+		}
+		if bitMember != nil && bitMember.Signed {
+			return errorAt(field.DeclPos(), "variable-length array '%s' in register '%s' size field '%s' must be an unsigned bit member",
 				field.Name, r.Name, fieldName)
 		}
 	}
 	return nil
 }
 
+// validateEndianness checks that the endianness annotation is applied to the fields
+// which are encoded by the register itself, a referenced register defines its own encoding
+func (r *Register) validateEndianness() error {
+	for _, field := range r.Body.Fields() {
+		if field.Endianness == "" {
+			continue
+		}
+		if refName := field.RefRegisterName(); refName != "" {
+			return errorAt(field.DeclPos(), "field '%s' in register '%s' references register '%s' and cannot have endianness annotation '@%s'",
+				field.Name, r.Name, refName, field.Endianness)
+		}
+	}
+	return nil
+}
+
+// updateFieldsEndianness sets the device byte order to the fields without the endianness
+// annotation, the fields referencing registers are encoded by the registers themselves
+func (r *Register) updateFieldsEndianness(endianness string) {
+	for _, field := range r.Body.Fields() {
+		if field.Endianness == "" && field.RefRegisterName() == "" {
+			field.Endianness = endianness
+		}
+	}
+}
+
+// validateStrings checks that the strings maximum length fits into their length prefix type
+func (r *Register) validateStrings() error {
+	for _, field := range r.Body.Fields() {
+		st := field.Type.String
+		if st == nil || st.MaxLenStr == nil {
+			continue
+		}
+		maxLen, err := strconv.ParseInt(*st.MaxLenStr, 0, 64)
+		if err != nil || maxLen <= 0 || maxLen > maxUnsignedValue(st.PrefixType()) {
+			return errorAt(field.DeclPos(), "string '%s' in register '%s': maximum length %s must be between 1 and %d for the '%s' length prefix",
+				field.Name, r.Name, *st.MaxLenStr, maxUnsignedValue(st.PrefixType()), st.PrefixType())
+		}
+	}
+	return nil
+}
+
+// validateReserved checks that the reserved fields have a constant size, so they are
+// built-in types or fixed-size arrays of built-in types
+func (r *Register) validateReserved() error {
+	for _, field := range r.Body.Fields() {
+		if !field.Reserved {
+			continue
+		}
+		switch {
+		case field.Type.Simple != nil && IsBuiltinType(field.Type.Simple.Name):
+		case field.Type.Array != nil && field.Type.Array.Size.Constant != nil && IsBuiltinType(field.Type.Array.Type.Name):
+		default:
+			return errorAt(field.DeclPos(), "reserved field in register '%s' must be a built-in type or a fixed-size array of a built-in type",
+				r.Name)
+		}
+	}
+	return nil
+}
+
+// validateMagic checks that the magic fields are built-in integers holding a value which fits
+// their type. The magic fields have no value in the generated code, so they have no range and
+// cannot be the size fields of the arrays
+func (r *Register) validateMagic() error {
+	fields := r.Body.Fields()
+	for _, field := range fields {
+		if !field.IsMagic() {
+			continue
+		}
+		if field.Reserved {
+			return errorAt(field.DeclPos(), "reserved field in register '%s' cannot have a magic value", r.Name)
+		}
+		st := field.Type.Simple
+		if st == nil || !IsBuiltinType(st.Name) || strings.HasPrefix(st.Name, "float") {
+			return errorAt(field.DeclPos(), "magic field '%s' in register '%s' must be a built-in integer type", field.Name, r.Name)
+		}
+		bits := getTypeSizeInBits("u" + strings.TrimPrefix(st.Name, "u"))
+		val, err := strconv.ParseUint(*field.MagicStr, 0, 64)
+		if err != nil || (bits < 64 && val >= 1<<bits) {
+			return errorAt(field.DeclPos(), "magic field '%s' in register '%s': value %s does not fit the '%s' type",
+				field.Name, r.Name, *field.MagicStr, st.Name)
+		}
+		if field.HasRange() {
+			return errorAt(field.DeclPos(), "magic field '%s' in register '%s' cannot have a range", field.Name, r.Name)
+		}
+		for _, other := range fields {
+			if other.Type.Array != nil && other.Type.Array.Size.Variable != nil && *other.Type.Array.Size.Variable == field.Name {
+				return errorAt(other.DeclPos(), "variable-length array '%s' in register '%s' cannot be sized by magic field '%s'",
+					other.Name, r.Name, field.Name)
+			}
+		}
+	}
+	return nil
+}
+
+// validateFixed checks that the fractional bits of the fixed-point fields fit their base type
+func (r *Register) validateFixed() error {
+	for _, field := range r.Body.Fields() {
+		ft := field.Type.Fixed
+		if ft == nil {
+			continue
+		}
+		bits := getTypeSizeInBits("u" + strings.TrimPrefix(ft.Base, "u"))
+		frac, err := strconv.ParseInt(ft.FracStr, 0, 64)
+		if err != nil || frac <= 0 || frac > int64(bits) {
+			return errorAt(field.DeclPos(), "fixed-point field '%s' in register '%s': fractional bits %s must be between 1 and %d for the '%s' base type",
+				field.Name, r.Name, ft.FracStr, bits, ft.Base)
+		}
+	}
+	return nil
+}
+
+// validateRanges checks that the ranges are set for the built-in number fields only and the
+// range bounds fit the field type
+func (r *Register) validateRanges() error {
+	for _, field := range r.Body.Fields() {
+		if !field.HasRange() {
+			continue
+		}
+		st := field.Type.Simple
+		if st == nil || st.IsEnum() || st.IsRegisterRef() {
+			return errorAt(field.DeclPos(), "field '%s' in register '%s': the range requires a built-in number type",
+				field.Name, r.Name)
+		}
+		lo, err1 := strconv.ParseInt(*field.MinStr, 0, 64)
+		hi, err2 := strconv.ParseInt(*field.MaxStr, 0, 64)
+		if err1 != nil || err2 != nil || lo > hi {
+			return errorAt(field.DeclPos(), "field '%s' in register '%s': invalid range %s..%s",
+				field.Name, r.Name, *field.MinStr, *field.MaxStr)
+		}
+		if typeMin, typeMax, ok := integerLimits(st.Name); ok && (lo < typeMin || (hi > 0 && uint64(hi) > typeMax)) {
+			return errorAt(field.DeclPos(), "field '%s' in register '%s': range %s..%s does not fit the '%s' type",
+				field.Name, r.Name, *field.MinStr, *field.MaxStr, st.Name)
+		}
+	}
+	return nil
+}
+
+// validateDefaults checks that only the integer, enum and bit fields have the default values,
+// which fit the field type and range
+func (r *Register) validateDefaults() error {
+	for _, field := range r.Body.Fields() {
+		if !field.HasDefault() {
+			continue
+		}
+		typ := field.valueType()
+		typeMin, typeMax, ok := integerLimits(typ)
+		if !ok || field.Reserved || field.IsMagic() {
+			return errorAt(field.DeclPos(), "field '%s' in register '%s' cannot have a default value, only the integer, enum and bit fields can",
+				field.label(), r.Name)
+		}
+		fits := false
+		if val, err := strconv.ParseInt(*field.DefaultStr, 0, 64); err == nil {
+			fits = val >= typeMin && (val < 0 || uint64(val) <= typeMax)
+		} else if val, err := strconv.ParseUint(*field.DefaultStr, 0, 64); err == nil {
+			fits = val <= typeMax
+		}
+		if !fits {
+			return errorAt(field.DeclPos(), "field '%s' in register '%s': default value %s does not fit the '%s' type",
+				field.Name, r.Name, *field.DefaultStr, typ)
+		}
+		if field.HasRange() {
+			val, err := strconv.ParseInt(*field.DefaultStr, 0, 64)
+			if err != nil || val < field.Min() || val > field.Max() {
+				return errorAt(field.DeclPos(), "field '%s' in register '%s': default value %s is out of range [%s..%s]",
+					field.Name, r.Name, *field.DefaultStr, *field.MinStr, *field.MaxStr)
+			}
+		}
+	}
+	return nil
+}
+
+// valueType returns the integer type the field value is sent as: the built-in type, the enum
+// or the bit field base type. It is empty for the other fields
+func (f *Field) valueType() string {
+	switch {
+	case f.Type.Bitfield != nil:
+		return f.Type.Bitfield.Base
+	case f.Type.Simple != nil && f.Type.Simple.IsEnum():
+		return f.Type.Simple.Enum.Base
+	case f.Type.Simple != nil:
+		return f.Type.Simple.Name
+	default:
+		return ""
+	}
+}
+
+// validateOffsets checks that the offsets of the fields increase and the fields do not overlap
+// the preceding ones. The offset is known only if the preceding fields have a constant size
+func (r *Register) validateOffsets() error {
+	end := 0
+	var variable *Field
+	for _, field := range r.Body.Fields() {
+		if field.HasOffset() {
+			offset, err := strconv.ParseInt(*field.OffsetStr, 0, 64)
+			if err != nil || offset < 0 || offset > MaxFieldOffset {
+				return errorAt(field.DeclPos(), "field '%s' in register '%s': offset %s must be between 0 and %d",
+					field.label(), r.Name, *field.OffsetStr, MaxFieldOffset)
+			}
+			if variable != nil {
+				return errorAt(field.DeclPos(), "field '%s' in register '%s' cannot have an offset, the preceding field '%s' has no constant size",
+					field.label(), r.Name, variable.label())
+			}
+			if int(offset) < end {
+				return errorAt(field.DeclPos(), "field '%s' in register '%s': offset %d overlaps the preceding fields, which end at offset %d",
+					field.label(), r.Name, offset, end)
+			}
+			end = int(offset)
+		}
+		size, ok := field.WireSize()
+		if !ok && variable == nil {
+			variable = field
+		}
+		end += size
+	}
+	return nil
+}
+
+// validateConditions checks that the conditions of the fields are the single-bit members or the
+// uint8 fields declared before them, the uint8 condition is set if it is not zero. The condition is
+// decoded before the field, so it must be sent in every direction the field is sent in
+func (r *Register) validateConditions() error {
+	fields := r.Body.Fields()
+	for i, field := range fields {
+		if !field.HasCondition() {
+			continue
+		}
+		name := *field.Condition
+		switch {
+		case field.Reserved:
+			return errorAt(field.DeclPos(), "reserved field in register '%s' cannot be conditional", r.Name)
+		case field.IsMagic():
+			return errorAt(field.DeclPos(), "magic field '%s' in register '%s' cannot be conditional", field.Name, r.Name)
+		case field.Type.CRC != nil:
+			return errorAt(field.DeclPos(), "checksum field '%s' in register '%s' cannot be conditional", field.Name, r.Name)
+		case field.HasOffset():
+			return errorAt(field.DeclPos(), "field '%s' in register '%s' cannot have both an offset and a condition", field.Name, r.Name)
+		}
+		cond, bitMember := r.FindFieldByName(name, i)
+		if cond == nil {
+			if later, _ := r.FindFieldByName(name, len(fields)); later != nil {
+				return errorAt(field.DeclPos(), "field '%s' in register '%s': condition '%s' must be declared before the field",
+					field.Name, r.Name, name)
+			}
+			return errorAt(field.DeclPos(), "field '%s' in register '%s' references undefined condition '%s'",
+				field.Name, r.Name, name)
+		}
+		if bitMember != nil && bitMember.StartBit() != bitMember.EndBit() {
+			return errorAt(field.DeclPos(), "field '%s' in register '%s': condition '%s' must be a single-bit member, it has bits %s",
+				field.Name, r.Name, name, bitMember.bitRange())
+		}
+		if bitMember == nil && (cond.Type.Simple.Name != "uint8" || cond.IsMagic()) {
+			return errorAt(field.DeclPos(), "field '%s' in register '%s': condition '%s' must be a single-bit member or a uint8 field",
+				field.Name, r.Name, name)
+		}
+		if cond.HasCondition() {
+			return errorAt(field.DeclPos(), "field '%s' in register '%s': condition '%s' cannot be conditional itself",
+				field.Name, r.Name, name)
+		}
+		if cond.Specifier != "" && cond.Specifier != field.Specifier {
+			return errorAt(field.DeclPos(), "field '%s' in register '%s': condition '%s' must be sent in every direction the field is sent in",
+				field.Name, r.Name, name)
+		}
+	}
+	// the array decoded without its conditional size field would have an undefined length
+	for i, field := range fields {
+		if field.Type.Array == nil || field.Type.Array.Size.Variable == nil {
+			continue
+		}
+		size, _ := r.FindFieldByName(*field.Type.Array.Size.Variable, i)
+		if size.HasCondition() && (!field.HasCondition() || *field.Condition != *size.Condition) {
+			return errorAt(field.DeclPos(), "variable-length array '%s' in register '%s' must have the condition '%s' of its size field '%s'",
+				field.Name, r.Name, *size.Condition, *field.Type.Array.Size.Variable)
+		}
+	}
+	return nil
+}
+
+// integerLimits returns the minimum and the maximum values of the integer type, ok is false
+// for the other types
+func integerLimits(typeName string) (minVal int64, maxVal uint64, ok bool) {
+	if bits := getTypeSizeInBits(typeName); bits > 0 {
+		return 0, math.MaxUint64 >> (64 - bits), true
+	}
+	if bits := getTypeSizeInBits("u" + typeName); bits > 0 && strings.HasPrefix(typeName, "int") {
+		return -1 << (bits - 1), 1<<(bits-1) - 1, true
+	}
+	return 0, 0, false
+}
+
+// IsMagic returns true if the field holds the magic value, e.g. sync = 0xAA55 uint16
+func (f *Field) IsMagic() bool {
+	return f.MagicStr != nil
+}
+
+// HasDefault returns true if the new register has the default value of the field instead of
+// zero, e.g. mode uint8 = 1
+func (f *Field) HasDefault() bool {
+	return f.DefaultStr != nil
+}
+
+// Default returns the default value of the field, the uint64 values above math.MaxInt64 wrap
+// around to the negative ones. The field must have the default value
+func (f *Field) Default() int64 {
+	if val, err := strconv.ParseInt(*f.DefaultStr, 0, 64); err == nil {
+		return val
+	}
+	val, _ := strconv.ParseUint(*f.DefaultStr, 0, 64)
+	return int64(val)
+}
+
+// HasCondition returns true if the field is sent only if its condition is set, e.g.
+// extra uint16 if flags_has_extra
+func (f *Field) HasCondition() bool {
+	return f.Condition != nil
+}
+
+// HasOffset returns true if the field is placed at the byte offset in the register
+func (f *Field) HasOffset() bool {
+	return f.OffsetStr != nil
+}
+
+// Offset returns the byte offset of the field in the register, the field must have the offset
+func (f *Field) Offset() int {
+	val, err := strconv.ParseInt(*f.OffsetStr, 0, 64)
+	if err != nil {
+		panic(fmt.Sprintf("invalid field offset %s", *f.OffsetStr))
+	}
+	return int(val)
+}
+
+// Padding returns the number of the zero bytes preceding the field in the read or the write
+// encoding of the register, they fill the gap up to the field offset. The encoding of a direction
+// has the fields of that direction only, so the gap covers the fields of the other one as well
+func (r *Register) Padding(field *Field, read bool) int {
+	if !field.HasOffset() || !field.inDirection(read) {
+		return 0
+	}
+	pos := 0
+	for _, f := range r.Body.Fields() {
+		if f == field {
+			return field.Offset() - pos
+		}
+		if !f.inDirection(read) {
+			continue
+		}
+		if f.HasOffset() {
+			pos = f.Offset()
+		}
+		size, _ := f.WireSize()
+		pos += size
+	}
+	return 0
+}
+
+// inDirection returns true if the field is sent in the read or the write direction
+func (f *Field) inDirection(read bool) bool {
+	if read {
+		return f.Specifier != "w"
+	}
+	return f.Specifier != "r"
+}
+
+// WireSize returns the number of bytes the field occupies on the wire, ok is false if the size
+// depends on the field value, e.g. for the variable-length arrays, the strings, the nested registers
+// and the conditional fields
+func (f *Field) WireSize() (size int, ok bool) {
+	t := f.Type
+	switch {
+	case f.HasCondition():
+		return 0, false
+	case t.Bitfield != nil:
+		return typeWireSize(t.Bitfield.Base), true
+	case t.Fixed != nil:
+		return typeWireSize(t.Fixed.Base), true
+	case t.CRC != nil:
+		return typeWireSize(t.CRC.BaseType()), true
+	case t.Array != nil && t.Array.Size.Constant != nil:
+		n, err := strconv.ParseInt(*t.Array.Size.Constant, 0, 64)
+		elem, ok := simpleTypeWireSize(&t.Array.Type)
+		if err != nil || !ok {
+			return 0, false
+		}
+		return int(n) * t.Array.InnerCount() * elem, true
+	case t.Simple != nil:
+		return simpleTypeWireSize(t.Simple)
+	}
+	return 0, false
+}
+
+// simpleTypeWireSize returns the wire size of the built-in type or the enum, ok is false for
+// the nested registers
+func simpleTypeWireSize(st *SimpleType) (int, bool) {
+	if st.Enum != nil {
+		return typeWireSize(st.Enum.Base), true
+	}
+	size := typeWireSize(st.Name)
+	return size, size > 0
+}
+
+// typeWireSize returns the number of bytes the built-in type occupies on the wire, it is 0
+// for the other types
+func typeWireSize(typeName string) int {
+	switch typeName {
+	case "float16":
+		return 2
+	case "float32":
+		return 4
+	case "float64":
+		return 8
+	default:
+		return getTypeSizeInBits("u"+strings.TrimPrefix(typeName, "u")) / 8
+	}
+}
+
+// MagicValue returns the value of the magic field, the field must be a magic one
+func (f *Field) MagicValue() uint64 {
+	val, err := strconv.ParseUint(*f.MagicStr, 0, 64)
+	if err != nil {
+		panic(fmt.Sprintf("invalid magic value %s", *f.MagicStr))
+	}
+	return val
+}
+
+// HasRange returns true if the field has the range of the valid values
+func (f *Field) HasRange() bool {
+	return f.MinStr != nil
+}
+
+// Min returns the lower bound of the field range, the field must have the range
+func (f *Field) Min() int64 {
+	val, err := strconv.ParseInt(*f.MinStr, 0, 64)
+	if err != nil {
+		panic(fmt.Sprintf("invalid range minimum %s", *f.MinStr))
+	}
+	return val
+}
+
+// Max returns the upper bound of the field range, the field must have the range
+func (f *Field) Max() int64 {
+	val, err := strconv.ParseInt(*f.MaxStr, 0, 64)
+	if err != nil {
+		panic(fmt.Sprintf("invalid range maximum %s", *f.MaxStr))
+	}
+	return val
+}
+
+// validateCRC checks that the checksum field is the last field of the register, so it covers
+// all the register bytes, and the checksum algorithm is defined for the checksum size
+func (r *Register) validateCRC() error {
+	fields := r.Body.Fields()
+	for i, field := range fields {
+		ct := field.Type.CRC
+		if ct == nil {
+			continue
+		}
+		if i != len(fields)-1 {
+			return errorAt(field.DeclPos(), "checksum field '%s' in register '%s' must be the last field", field.Name, r.Name)
+		}
+		if (ct.Kind == "crc16") == (ct.AlgorithmName() == "ieee") {
+			return errorAt(field.DeclPos(), "checksum field '%s' in register '%s': algorithm '%s' is not supported by '%s'",
+				field.Name, r.Name, ct.Algorithm, ct.Kind)
+		}
+	}
+	return nil
+}
+
+// AlgorithmName returns the checksum algorithm, the default one if it is not specified
+func (ct *CRCType) AlgorithmName() string {
+	switch {
+	case ct.Algorithm != "":
+		return ct.Algorithm
+	case ct.Kind == "crc32":
+		return "ieee"
+	default:
+		return "ccitt"
+	}
+}
+
+// BaseType returns the unsigned integer type the checksum is sent over the wire as
+func (ct *CRCType) BaseType() string {
+	return "uint" + strings.TrimPrefix(ct.Kind, "crc")
+}
+
+// Frac returns the number of the fractional bits of the fixed-point number
+func (ft *FixedType) Frac() int {
+	val, err := strconv.ParseInt(ft.FracStr, 0, 64)
+	if err != nil {
+		panic(fmt.Sprintf("invalid fixed-point fractional bits %s", ft.FracStr))
+	}
+	return int(val)
+}
+
+// PrefixType returns the type of the string length prefix
+func (st *StringType) PrefixType() string {
+	if st.Prefix == "" {
+		return "uint8"
+	}
+	return st.Prefix
+}
+
+// MaxLen returns the maximum length of the string in bytes
+func (st *StringType) MaxLen() int {
+	if st.MaxLenStr == nil {
+		return int(maxUnsignedValue(st.PrefixType()))
+	}
+	val, err := strconv.ParseInt(*st.MaxLenStr, 0, 64)
+	if err != nil {
+		panic(fmt.Sprintf("invalid string maximum length %s", *st.MaxLenStr))
+	}
+	return int(val)
+}
+
+// IsMultiDim returns true if the array has the inner dimensions
+func (at *ArrayType) IsMultiDim() bool {
+	return len(at.Dims) > 0
+}
+
+// InnerCount returns the number of the elements in one item of the first dimension,
+// e.g. 8 for [4][2][4]uint8 and 1 for the one-dimensional arrays
+func (at *ArrayType) InnerCount() int {
+	count := 1
+	for _, d := range at.Dims {
+		val, err := strconv.ParseInt(d, 0, 64)
+		if err != nil {
+			panic(fmt.Sprintf("invalid array dimension %s", d))
+		}
+		count *= int(val)
+	}
+	return count
+}
+
+// maxUnsignedValue returns the maximum value of the unsigned integer type
+func maxUnsignedValue(typeName string) int64 {
+	return int64(1)<<getTypeSizeInBits(typeName) - 1
+}
+
+// DeclPos returns the position of the field declaration, skipping its leading comments
+func (f *Field) DeclPos() lexer.Position {
+	return declarationPos(f.Pos, f.Tokens)
+}
+
+// label returns the field name used in the error messages
+func (f *Field) label() string {
+	if f.Reserved {
+		return "reserved"
+	}
+	return f.Name
+}
+
+// IsLittleEndian returns true if the field must be encoded in little-endian byte order
+func (f *Field) IsLittleEndian() bool {
+	return f.Endianness == "le"
+}
+
+// RefRegisterName returns the name of the register the field or the field array elements
+// refer to, or an empty string if the field does not refer to a register
+func (f *Field) RefRegisterName() string {
+	switch {
+	case f.Type.Simple != nil && f.Type.Simple.IsRegisterRef():
+		return f.Type.Simple.Name
+	case f.Type.Array != nil && f.Type.Array.Type.IsRegisterRef():
+		return f.Type.Array.Type.Name
+	default:
+		return ""
+	}
+}
+
+// HasLittleEndianFields returns true if any field of the device is encoded in little-endian byte order
+func (d *Device) HasLittleEndianFields() bool {
+	for _, reg := range d.Registers {
+		for _, field := range reg.Body.Fields() {
+			if field.IsLittleEndian() {
+				return true
+			}
+		}
+	}
+	return false
+}
+
 // validateRegisterReferences validates that all register references exist and there are no circular dependencies
 func (d *Device) validateRegisterReferences() error {
 	// Build a map of all registers
@@ -454,10 +1864,20 @@ func (d *Device) validateRegisterReferences() error {
 	// Validate that all referenced registers exist
 	for _, reg := range d.Registers {
 		for _, field := range reg.Body.Fields() {
-			if field.Type.Simple != nil && field.Type.Simple.IsRegisterRef() {
-				refName := field.Type.Simple.Name
-				if _, exists := registerMap[refName]; !exists {
-					return fmt.Errorf("field '%s' in register '%s' references undefined register '%s'",
+			if refName := field.RefRegisterName(); refName != "" {
+				ref, exists := registerMap[refName]
+				if !exists {
+					return errorAt(field.DeclPos(), "field '%s' in register '%s' references undefined register '%s'",
+						field.Name, reg.Name, refName)
+				}
+				// The read-only field is never written, so it cannot refer to the register having only
+				// the write fields, and vice versa
+				if field.Specifier == "r" && ref.Specifier == "w" {
+					return errorAt(field.DeclPos(), "field '%s' in register '%s' cannot be read-only because referenced register '%s' is write-only",
+						field.Name, reg.Name, refName)
+				}
+				if field.Specifier == "w" && ref.Specifier == "r" {
+					return errorAt(field.DeclPos(), "field '%s' in register '%s' cannot be write-only because referenced register '%s' is read-only",
 						field.Name, reg.Name, refName)
 				}
 			}
@@ -469,7 +1889,7 @@ func (d *Device) validateRegisterReferences() error {
 		visited := make(map[string]bool)
 		recursionStack := make(map[string]bool)
 		if hasCycle(reg.Name, registerMap, visited, recursionStack) {
-			return fmt.Errorf("circular dependency detected involving register '%s'", reg.Name)
+			return errorAt(reg.DeclPos(), "circular dependency detected involving register '%s'", reg.Name)
 		}
 	}
 
@@ -488,9 +1908,7 @@ func hasCycle(regName string, registerMap map[string]*Register, visited, recursi
 
 	// Check all fields for register references
 	for _, field := range reg.Body.Fields() {
-		if field.Type.Simple != nil && field.Type.Simple.IsRegisterRef() {
-			refName := field.Type.Simple.Name
-
+		if refName := field.RefRegisterName(); refName != "" {
 			// If we find a node in recursion stack, we have a cycle
 			if recursionStack[refName] {
 				return true
@@ -510,6 +1928,167 @@ func hasCycle(regName string, registerMap map[string]*Register, visited, recursi
 	return false
 }
 
+// validateAndResolveEnums validates the enum declarations and binds the field types to the enums they refer to
+func (d *Device) validateAndResolveEnums() error {
+	enums := make(map[string]*Enum)
+	for _, e := range d.Enums {
+		if IsBuiltinType(e.Name) {
+			return errorAt(e.DeclPos(), "enum '%s' cannot have the name of a built-in type", e.Name)
+		}
+		if _, ok := enums[e.Name]; ok {
+			return errorAt(e.DeclPos(), "duplicate enum '%s'", e.Name)
+		}
+		if d.FindRegisterByName(e.Name) != nil {
+			return errorAt(e.DeclPos(), "enum '%s' has the same name as a register", e.Name)
+		}
+		enums[e.Name] = e
+		if err := e.validate(); err != nil {
+			return err
+		}
+	}
+
+	for _, reg := range d.Registers {
+		for _, field := range reg.Body.Fields() {
+			switch {
+			case field.Type.Simple != nil:
+				field.Type.Simple.Enum = enums[field.Type.Simple.Name]
+			case field.Type.Array != nil:
+				if _, ok := enums[field.Type.Array.Type.Name]; ok {
+					return errorAt(field.DeclPos(), "array '%s' in register '%s' cannot have enum '%s' elements",
+						field.Name, reg.Name, field.Type.Array.Type.Name)
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// validate checks that the enum members have unique names and values, and the values fit the base type
+func (e *Enum) validate() error {
+	names := make(map[string]bool)
+	values := make(map[int64]string)
+	for _, m := range e.Members {
+		if names[m.Name] {
+			return errorAt(e.DeclPos(), "enum '%s' has duplicate member '%s'", e.Name, m.Name)
+		}
+		names[m.Name] = true
+
+		val, err := strconv.ParseInt(m.ValueStr, 0, 64)
+		if err != nil || !fitsType(val, e.Base) {
+			return errorAt(e.DeclPos(), "enum '%s' member '%s' value %s is out of range of type '%s'",
+				e.Name, m.Name, m.ValueStr, e.Base)
+		}
+		if other, ok := values[val]; ok {
+			return errorAt(e.DeclPos(), "enum '%s' members '%s' and '%s' have the same value %d",
+				e.Name, other, m.Name, val)
+		}
+		values[val] = m.Name
+	}
+	return nil
+}
+
+// DeclPos returns the position of the enum declaration, skipping its leading comments
+func (e *Enum) DeclPos() lexer.Position {
+	return declarationPos(e.Pos, e.Tokens)
+}
+
+// Value returns the enum member value. Parse reports the values out of the range of the enum
+// base type, the value of an unchecked member beyond int64 is clamped to the int64 range
+func (m *EnumMember) Value() int64 {
+	val, _ := strconv.ParseInt(m.ValueStr, 0, 64)
+	return val
+}
+
+// fitsType returns true if the value can be represented by the integer type
+func fitsType(val int64, typeName string) bool {
+	bits := getTypeSizeInBits("u" + strings.TrimPrefix(typeName, "u"))
+	if isUnsignedType(typeName) {
+		return val >= 0 && (bits == 64 || val < int64(1)<<bits)
+	}
+	return bits == 64 || (val >= -(int64(1)<<(bits-1)) && val < int64(1)<<(bits-1))
+}
+
+// validateConstants checks that the device-level constants have unique names, which are not
+// used by the register constants, the registers, the enums and the messages, and that the
+// values of all the constants fit their types
+func (d *Device) validateConstants() error {
+	names := make(map[string]bool)
+	for _, c := range d.Constants {
+		if names[c.Name] {
+			return errorAt(c.DeclPos(), "duplicate device constant '%s'", c.Name)
+		}
+		names[c.Name] = true
+		if err := c.validateValue(); err != nil {
+			return err
+		}
+		// the constants are declared in the same scope as the types of the generated code
+		switch {
+		case d.FindRegisterByName(c.Name) != nil:
+			return errorAt(c.DeclPos(), "device constant '%s' has the same name as a register", c.Name)
+		case slices.ContainsFunc(d.Enums, func(e *Enum) bool { return e.Name == c.Name }):
+			return errorAt(c.DeclPos(), "device constant '%s' has the same name as an enum", c.Name)
+		case slices.ContainsFunc(d.Messages, func(m *Message) bool { return m.Name == c.Name }):
+			return errorAt(c.DeclPos(), "device constant '%s' has the same name as a message", c.Name)
+		}
+	}
+	for _, reg := range d.Registers {
+		for _, c := range reg.Body.Constants() {
+			if err := c.validateValue(); err != nil {
+				return err
+			}
+			if names[c.Name] {
+				return errorAt(c.DeclPos(), "constant '%s' in register '%s' has the same name as a device constant",
+					c.Name, reg.Name)
+			}
+		}
+	}
+	return nil
+}
+
+// validateMessages checks that the message names are unique and differ from the registers
+// and the enums ones, and every message has the registers declared in the device, each of
+// them once
+func (d *Device) validateMessages() error {
+	names := make(map[string]bool)
+	for _, m := range d.Messages {
+		switch {
+		case IsBuiltinType(m.Name):
+			return errorAt(m.DeclPos(), "message '%s' cannot have the name of a built-in type", m.Name)
+		case names[m.Name]:
+			return errorAt(m.DeclPos(), "duplicate message '%s'", m.Name)
+		case d.FindRegisterByName(m.Name) != nil:
+			return errorAt(m.DeclPos(), "message '%s' has the same name as a register", m.Name)
+		case slices.ContainsFunc(d.Enums, func(e *Enum) bool { return e.Name == m.Name }):
+			return errorAt(m.DeclPos(), "message '%s' has the same name as an enum", m.Name)
+		case len(m.Members) == 0:
+			return errorAt(m.DeclPos(), "message '%s' has no registers", m.Name)
+		}
+		names[m.Name] = true
+
+		registers := make(map[string]bool)
+		for _, mm := range m.Members {
+			if d.FindRegisterByName(mm.Register) == nil {
+				return errorAt(mm.DeclPos(), "message '%s' references undefined register '%s'", m.Name, mm.Register)
+			}
+			if registers[mm.Register] {
+				return errorAt(mm.DeclPos(), "message '%s' has duplicate register '%s'", m.Name, mm.Register)
+			}
+			registers[mm.Register] = true
+		}
+	}
+	return nil
+}
+
+// DeclPos returns the position of the message declaration, skipping its leading comments
+func (m *Message) DeclPos() lexer.Position {
+	return declarationPos(m.Pos, m.Tokens)
+}
+
+// DeclPos returns the position of the message member, skipping its leading comments
+func (mm *MessageMember) DeclPos() lexer.Position {
+	return declarationPos(mm.Pos, mm.Tokens)
+}
+
 // FindRegisterByName finds a register by name in the device
 func (d *Device) FindRegisterByName(name string) *Register {
 	for _, reg := range d.Registers {
@@ -1,6 +1,7 @@
 package parser
 
 import (
+	"strings"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -361,3 +362,1539 @@ register Main(2) {
 	require.NotNil(t, rwConfigField.Type.Simple)
 	assert.True(t, rwConfigField.Type.Simple.IsRegisterRef())
 }
+
+func TestRegisterRefSpecifierMismatch(t *testing.T) {
+	tests := []struct {
+		name   string
+		input  string
+		errMsg string
+	}{
+		{
+			name: "read-only field refers to write-only register",
+			input: `device test
+register Config(1): w { mode uint8; };
+register Main(2) { cfg: r Config; };`,
+			errMsg: "field 'cfg' in register 'Main' cannot be read-only because referenced register 'Config' is write-only",
+		},
+		{
+			name: "write-only field refers to read-only register",
+			input: `device test
+register Config(1): r { mode uint8; };
+register Main(2) { cfg: w Config; };`,
+			errMsg: "field 'cfg' in register 'Main' cannot be write-only because referenced register 'Config' is read-only",
+		},
+		{
+			name: "field of read-only register refers to write-only register",
+			input: `device test
+register Config(1): w { mode uint8; };
+register Main(2): r { cfgs [2]Config; };`,
+			errMsg: "field 'cfgs' in register 'Main' cannot be read-only because referenced register 'Config' is write-only",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Parse(tt.input)
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), tt.errMsg)
+		})
+	}
+
+	// the compatible specifiers and the read-write fields referring to any register
+	_, err := Parse(`device test
+register RO(1): r { mode uint8; };
+register WO(2): w { mode uint8; };
+register RW(3) { mode uint8; };
+register Main(4) {
+    ro: r RO;
+    wo: w WO;
+    rw_r: r RW;
+    rw_w: w RW;
+    any_ro RO;
+    any_wo WO;
+};`)
+	require.NoError(t, err)
+}
+
+func TestFieldEndianness(t *testing.T) {
+	input := `
+device test
+
+register Frame(1) {
+    header uint16;
+    payload uint32 @le;
+    flags uint16{ready: 0} @le;
+    samples [2]int16 @be;
+};
+`
+	device, err := Parse(input)
+	require.NoError(t, err)
+
+	fields := device.Registers[0].Body.Fields()
+	require.Len(t, fields, 4)
+	assert.Equal(t, "", fields[0].Endianness)
+	assert.False(t, fields[0].IsLittleEndian())
+	assert.Equal(t, "le", fields[1].Endianness)
+	assert.True(t, fields[1].IsLittleEndian())
+	assert.Equal(t, "le", fields[2].Endianness)
+	assert.Equal(t, "be", fields[3].Endianness)
+	assert.False(t, fields[3].IsLittleEndian())
+}
+
+func TestFieldEndiannessOnRegisterRef(t *testing.T) {
+	input := `
+device test
+
+register Config(1) {
+    mode uint8;
+};
+
+register Main(2) {
+    config Config @le;
+};
+`
+	_, err := Parse(input)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "cannot have endianness annotation")
+}
+
+func TestRegisterNumberRange(t *testing.T) {
+	input := `
+device test
+
+register Low(0) {
+    a uint8;
+};
+
+register High(0xff) {
+    a uint8;
+};
+`
+	device, err := Parse(input)
+	require.NoError(t, err)
+	assert.Equal(t, int64(255), device.Registers[1].Number())
+
+	input = `
+device test
+
+register Foo(300) {
+    a uint8;
+};
+`
+	_, err = Parse(input)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "register 'Foo' number 300 is out of range")
+	assert.Contains(t, err.Error(), "4:1:")
+}
+
+func TestDuplicateRegisterName(t *testing.T) {
+	_, err := Parse(`device test
+register Ctl(1) {
+    a uint8;
+};
+register Ctl(2) {
+    a uint8;
+    b uint16;
+};`)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "5:1: duplicate register 'Ctl'")
+}
+
+func TestLargeNumbers(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		err   string
+	}{
+		{"register number", "register R(99999999999999999999) {\n    a uint8;\n};",
+			"2:1: register 'R' number 99999999999999999999 is out of range, it must be between 0 and 255"},
+		{"start bit", "register R(1) {\n    a uint8{b: 99999999999999999999};\n};",
+			"3:13: bit field 'a' in register 'R': bit range 99999999999999999999 exceeds size of base type 'uint8' (8 bits)"},
+		{"end bit", "register R(1) {\n    a uint8{b: 0-0x1_0000_0000_0000_0000};\n};",
+			"3:13: bit field 'a' in register 'R': bit range 0-0x10000000000000000 exceeds size of base type 'uint8' (8 bits)"},
+		{"device constant", "const c = uint64(18446744073709551616);",
+			"2:1: constant 'c' value 18446744073709551616 is out of range of type 'uint64'"},
+		{"register constant", "register R(1) {\n    const c = int8(128);\n};",
+			"3:5: constant 'c' value 128 is out of range of type 'int8'"},
+		{"enum value", "enum E uint8 { A = 99999999999999999999 };",
+			"2:1: enum 'E' member 'A' value 99999999999999999999 is out of range of type 'uint8'"},
+		{"separators", "register R(1__0) {\n    a uint8;\n};",
+			"2:12: invalid number 1__0, the underscores must separate the digits"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Parse("device test\n" + tt.input)
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), tt.err)
+		})
+	}
+}
+
+func TestDigitSeparators(t *testing.T) {
+	device, err := Parse(`device test
+const mask = uint64(0xFFFF_FFFF_FFFF_FFFF);
+register R(0b1_0) {
+    sync = 0xAA_55 uint16;
+    flags uint16{a: 1_0-1_1};
+    data [1_000]uint8;
+};`)
+	require.NoError(t, err)
+	assert.Equal(t, "0xFFFFFFFFFFFFFFFF", device.Constants[0].ValueStr)
+	assert.Equal(t, int64(2), device.Registers[0].Number())
+	fields := device.Registers[0].Body.Fields()
+	assert.Equal(t, uint64(0xAA55), fields[0].MagicValue())
+	assert.Equal(t, 10, fields[1].Type.Bitfield.Bits[0].StartBit())
+	assert.Equal(t, 11, fields[1].Type.Bitfield.Bits[0].EndBit())
+	assert.Equal(t, "1000", *fields[2].Type.Array.Size.Constant)
+
+	// the uint64 constant beyond int64 used to crash the dump
+	_, err = DumpJSON(device)
+	require.NoError(t, err)
+}
+
+func TestInvalidNames(t *testing.T) {
+	// the device name may have hyphens, the generators replace them
+	device, err := Parse("device my-dev\nregister R(1) {\n    data_size uint8;\n};")
+	require.NoError(t, err)
+	assert.Equal(t, "my-dev", device.Name)
+
+	// the hyphenated names and references are reported, each at its position
+	_, err = Parse(`device test
+enum my-mode uint8 { off-state = 0 };
+register R(1) {
+    data-size uint8;
+    flags uint8{is-on: 0};
+    data [data-size]uint8;
+};`)
+	require.Error(t, err)
+	for _, msg := range []string{
+		"2:6: invalid name 'my-mode', the names may contain only letters, digits and underscores",
+		"2:22: invalid name 'off-state'",
+		"4:5: invalid name 'data-size'",
+		"5:17: invalid name 'is-on'",
+		"6:11: invalid name 'data-size'",
+	} {
+		assert.Contains(t, err.Error(), msg)
+	}
+
+	_, err = Parse("device test\nregister R(1) {\n    2nd uint8;\n};")
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "3:5: invalid name 2nd, the names must start with a letter or an underscore")
+	_, err = Parse("device test\nregister R(1) {\n    value 1_x;\n};")
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "invalid name 1_x")
+}
+
+func TestBitFieldOverlap(t *testing.T) {
+	input := `
+device test
+
+register R(1) {
+    flags uint8{a: 0-3, b: 2-5};
+};
+`
+	_, err := Parse(input)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "members 'a' and 'b' overlap in bits 2-3")
+
+	input = `
+device test
+
+register R(1) {
+    flags uint8{a: 0-3, b: 5, c: 4, d: 5-7};
+};
+`
+	_, err = Parse(input)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "members 'b' and 'd' overlap in bits 5")
+
+	input = `
+device test
+
+register R(1) {
+    flags uint8{a: 0-3, b: 4-5, c: 6, d: 7};
+};
+`
+	_, err = Parse(input)
+	require.NoError(t, err)
+}
+
+func TestEnum(t *testing.T) {
+	input := `
+device test
+
+// Operation mode
+enum Mode uint8 {
+    // turned off
+    OFF = 0,
+    ON = 1,
+    STANDBY = 0x2,
+};
+
+enum Offset int16 { NEG = -5, POS = 5 };
+
+register Control(1) {
+    mode Mode;
+    offset Offset;
+    config Config;
+};
+
+register Config(2) {
+    a uint8;
+};
+`
+	device, err := Parse(input)
+	require.NoError(t, err)
+	require.Len(t, device.Enums, 2)
+	require.Len(t, device.Registers, 2)
+
+	mode := device.Enums[0]
+	assert.Equal(t, "Mode", mode.Name)
+	assert.Equal(t, "uint8", mode.Base)
+	require.NotNil(t, mode.Doc)
+	assert.Equal(t, "// Operation mode", *mode.Doc.Elements[len(mode.Doc.Elements)-1].Comment)
+	require.Len(t, mode.Members, 3)
+	assert.Equal(t, "OFF", mode.Members[0].Name)
+	require.NotNil(t, mode.Members[0].Doc)
+	assert.Equal(t, int64(2), mode.Members[2].Value())
+	assert.Equal(t, int64(-5), device.Enums[1].Members[0].Value())
+
+	fields := device.Registers[0].Body.Fields()
+	assert.True(t, fields[0].Type.Simple.IsEnum())
+	assert.False(t, fields[0].Type.Simple.IsRegisterRef())
+	assert.Same(t, mode, fields[0].Type.Simple.Enum)
+	assert.True(t, fields[1].Type.Simple.IsEnum())
+	assert.False(t, fields[2].Type.Simple.IsEnum())
+	assert.True(t, fields[2].Type.Simple.IsRegisterRef())
+}
+
+func TestEnumTrailingComments(t *testing.T) {
+	device, err := Parse(`device test
+enum Mode uint8 {
+    OFF = 0, // turned off
+    // turned on
+    ON = 1,  /* on */
+    STANDBY = 2 // waiting
+    // no more modes
+}; // the operation mode
+enum Level uint8 { LOW = 0, HIGH = 1 };
+`)
+	require.NoError(t, err)
+	mode := device.Enums[0]
+	assert.Equal(t, "// the operation mode", *mode.TrailingComment)
+	require.Len(t, mode.Members, 3)
+	assert.Equal(t, "// turned off", *mode.Members[0].TrailingComment)
+	// the trailing comment of the member is not the doc of the next one
+	require.Len(t, mode.Members[1].Doc.Elements, 1)
+	assert.Equal(t, "// turned on", *mode.Members[1].Doc.Elements[0].Comment)
+	assert.Equal(t, "/* on */", *mode.Members[1].TrailingComment)
+	assert.Equal(t, "// waiting", *mode.Members[2].TrailingComment)
+	require.Len(t, mode.Tail.Elements, 1)
+	assert.Equal(t, "// no more modes", *mode.Tail.Elements[0].Comment)
+
+	level := device.Enums[1]
+	assert.Equal(t, "", *level.TrailingComment)
+	assert.Nil(t, level.Members[0].TrailingComment)
+	assert.Nil(t, level.Members[1].TrailingComment)
+}
+
+func TestEnumValidation(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		err   string
+	}{
+		{"duplicate value", `device test
+enum Mode uint8 { A = 1, B = 1 };`, "members 'A' and 'B' have the same value 1"},
+		{"duplicate member", `device test
+enum Mode uint8 { A = 1, A = 2 };`, "enum 'Mode' has duplicate member 'A'"},
+		{"unsigned overflow", `device test
+enum Mode uint8 { A = 256 };`, "member 'A' value 256 is out of range of type 'uint8'"},
+		{"negative unsigned", `device test
+enum Mode uint16 { A = -1 };`, "member 'A' value -1 is out of range of type 'uint16'"},
+		{"signed overflow", `device test
+enum Mode int8 { A = -129 };`, "member 'A' value -129 is out of range of type 'int8'"},
+		{"duplicate enum", `device test
+enum Mode uint8 { A = 1 };
+enum Mode uint8 { B = 1 };`, "duplicate enum 'Mode'"},
+		{"array of enums", `device test
+enum Mode uint8 { A = 1 };
+register R(1) {
+    modes [2]Mode;
+};`, "array 'modes' in register 'R' cannot have enum 'Mode' elements"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Parse(tt.input)
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), tt.err)
+		})
+	}
+
+	_, err := Parse(`device test
+enum Mode int8 { A = -128, B = 127 };`)
+	require.NoError(t, err)
+}
+
+func TestStringType(t *testing.T) {
+	input := `
+device test
+
+register Info(1) {
+    name string;
+    version string(uint16);
+    label string(uint8, 16) @le;
+};`
+
+	device, err := Parse(input)
+	require.NoError(t, err)
+
+	fields := device.Registers[0].Body.Fields()
+	require.Len(t, fields, 3)
+	require.NotNil(t, fields[0].Type.String)
+	assert.Nil(t, fields[0].Type.Simple)
+	assert.Equal(t, "uint8", fields[0].Type.String.PrefixType())
+	assert.Equal(t, 255, fields[0].Type.String.MaxLen())
+	assert.Equal(t, "uint16", fields[1].Type.String.PrefixType())
+	assert.Equal(t, 65535, fields[1].Type.String.MaxLen())
+	assert.Equal(t, "uint8", fields[2].Type.String.PrefixType())
+	assert.Equal(t, 16, fields[2].Type.String.MaxLen())
+	assert.True(t, fields[2].IsLittleEndian())
+
+	_, err = Parse(`device test
+register Info(1) {
+    name string(uint8, 256);
+};`)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "string 'name' in register 'Info': maximum length 256 must be between 1 and 255 for the 'uint8' length prefix")
+
+	_, err = Parse(`device test
+register Info(1) {
+    name string(uint16, 0);
+};`)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "maximum length 0 must be between 1 and 65535")
+}
+
+func TestReservedField(t *testing.T) {
+	device, err := Parse(`device test
+register R(1) {
+    reserved [2]uint8;
+    value uint8;
+    reserved:r uint16;
+};`)
+	require.NoError(t, err)
+
+	fields := device.Registers[0].Body.Fields()
+	require.Len(t, fields, 3)
+	assert.True(t, fields[0].Reserved)
+	assert.Equal(t, "", fields[0].Name)
+	assert.False(t, fields[1].Reserved)
+	assert.Equal(t, "value", fields[1].Name)
+	assert.True(t, fields[2].Reserved)
+	assert.Equal(t, "r", fields[2].Specifier)
+
+	for _, typ := range []string{"[size]uint8", "R", "string", "uint8{a: 0}"} {
+		_, err = Parse(`device test
+register R(1) {
+    size uint8;
+    reserved ` + typ + `;
+};`)
+		require.Error(t, err, typ)
+		assert.Contains(t, err.Error(), "reserved field in register 'R' must be a built-in type or a fixed-size array of a built-in type")
+	}
+}
+
+func TestVariableArraySizeFieldUnsigned(t *testing.T) {
+	for _, typ := range []string{"int8", "int32", "float32", "Mode", "Other"} {
+		_, err := Parse(`device test
+enum Mode uint8 {
+    A = 1,
+};
+register Other(2) {
+    v uint8;
+};
+register R(1) {
+    size ` + typ + `;
+    data [size]uint8;
+};`)
+		require.Error(t, err, typ)
+		assert.Contains(t, err.Error(), "variable-length array 'data' in register 'R' size field 'size' must be an unsigned integer, got '"+typ+"'")
+	}
+
+	for _, typ := range []string{"uint8", "uint16", "uint32", "uint64"} {
+		_, err := Parse(`device test
+register R(1) {
+    size ` + typ + `;
+    data [size]uint8;
+};`)
+		require.NoError(t, err, typ)
+	}
+
+	_, err := Parse(`device test
+register R(1) {
+    flags uint8{ready: 0, count: 4-7};
+    data [flags_count]uint8;
+};`)
+	require.NoError(t, err)
+}
+
+func TestBlockComments(t *testing.T) {
+	device, err := Parse(`device test
+/* The register
+ * takes two lines */
+register R(1) {
+    /* single line */
+    a uint8; /* trailing */
+    b uint8;   // line comment
+    /*
+       field c
+    */
+    c uint8;
+};
+/* the second register */
+register S(2) {
+    v uint8; /* not swallowing */ 
+};`)
+	require.NoError(t, err)
+	require.Len(t, device.Registers, 2)
+
+	r := device.Registers[0]
+	require.NotNil(t, r.Doc)
+	require.Len(t, r.Doc.Elements, 1)
+	assert.Equal(t, "/* The register\n * takes two lines */", *r.Doc.Elements[0].Comment)
+
+	fields := r.Body.Fields()
+	require.Len(t, fields, 3)
+	assert.Equal(t, "/* single line */", *fields[0].Doc.Elements[0].Comment)
+	assert.Equal(t, "/* trailing */", *fields[0].TrailingComment)
+	assert.Equal(t, "// line comment", *fields[1].TrailingComment)
+	assert.Equal(t, "/*\n       field c\n    */", *fields[2].Doc.Elements[0].Comment)
+
+	s := device.Registers[1]
+	assert.Equal(t, "/* the second register */", *s.Doc.Elements[0].Comment)
+	require.Len(t, s.Body.Fields(), 1)
+	assert.Equal(t, "/* not swallowing */", *s.Body.Fields()[0].TrailingComment)
+}
+
+func TestDeclarationTrailingComments(t *testing.T) {
+	device, err := Parse(`// the device
+device test // the test device
+// the control
+register Control(1) {
+    mode uint8;
+}; // main control
+register Status(2): r {
+    v uint8;
+};   /* the status */
+register Data(3) {
+    v uint8;
+};
+// not trailing
+register Last(4) {
+    v uint8;
+};`)
+	require.NoError(t, err)
+	require.NotNil(t, device.TrailingComment)
+	assert.Equal(t, "// the test device", *device.TrailingComment)
+	require.Len(t, device.Doc.Elements, 1)
+	assert.Equal(t, "// the device", *device.Doc.Elements[0].Comment)
+
+	require.Len(t, device.Registers, 4)
+	control := device.Registers[0]
+	require.Len(t, control.Doc.Elements, 1)
+	assert.Equal(t, "// the control", *control.Doc.Elements[0].Comment)
+	assert.Equal(t, "// main control", *control.TrailingComment)
+	assert.Equal(t, "/* the status */", *device.Registers[1].TrailingComment)
+	assert.Equal(t, "", *device.Registers[2].TrailingComment)
+	assert.Equal(t, "// not trailing", *device.Registers[3].Doc.Elements[0].Comment)
+
+	// the comment in the next line is the doc of the first declaration
+	device, err = Parse("device test\n// the version\nconst version = uint8(1);\nregister R(1) {\n    v uint8;\n};")
+	require.NoError(t, err)
+	assert.Nil(t, device.TrailingComment)
+	assert.Equal(t, "// the version", *device.Constants[0].Doc.Elements[0].Comment)
+}
+
+func TestTrailingCommentSeparators(t *testing.T) {
+	for _, tc := range []struct {
+		line, comment string
+	}{
+		{"v uint8; // a; b", "// a; b"},
+		{"v uint8; // a // b", "// a // b"},
+		{"v uint8;\t// tab", "// tab"},
+		{"v uint8; \t  // mixed", "// mixed"},
+		{"v uint8;// no space", "// no space"},
+		{"v uint8;/* block; */", "/* block; */"},
+		{"v uint8; // trailing spaces \t", "// trailing spaces"},
+		{"v uint8;", ""},
+	} {
+		device, err := Parse("device test\nregister R(1) {\n    " + tc.line + "\n    w uint8;\n};")
+		require.NoError(t, err, tc.line)
+		fields := device.Registers[0].Body.Fields()
+		require.NotNil(t, fields[0].TrailingComment, tc.line)
+		assert.Equal(t, tc.comment, *fields[0].TrailingComment, tc.line)
+		assert.Empty(t, fields[1].Doc.Elements, "the comment is attached to the next field: %s", tc.line)
+	}
+
+	device, err := Parse("device test\nconst c = uint8(1);// the constant\nregister R(1) {\n    v uint8;\n};\t// the register")
+	require.NoError(t, err)
+	assert.Equal(t, "// the constant", *device.Constants[0].TrailingComment)
+	assert.Equal(t, "// the register", *device.Registers[0].TrailingComment)
+}
+
+func TestCRLFLineEndings(t *testing.T) {
+	input := `// the device
+device test
+
+// the register
+register R(1) {
+    // the value
+    value uint8; // trailing
+
+    /* the flags */
+    flags uint8{a: 0}; /* trailing */
+};
+`
+	lf, err := Parse(input)
+	require.NoError(t, err)
+	crlf, err := Parse(strings.ReplaceAll(input, "\n", "\r\n"))
+	require.NoError(t, err)
+	assert.Equal(t, lf, crlf)
+	cr, err := Parse(strings.ReplaceAll(input, "\n", "\r"))
+	require.NoError(t, err)
+	assert.Equal(t, lf, cr)
+
+	fields := crlf.Registers[0].Body.Fields()
+	assert.Equal(t, "// trailing", *fields[0].TrailingComment)
+	assert.Equal(t, "/* trailing */", *fields[1].TrailingComment)
+	require.Len(t, fields[1].Doc.Elements, 2)
+	assert.NotNil(t, fields[1].Doc.Elements[0].EmptyLine)
+	assert.Equal(t, "/* the flags */", *fields[1].Doc.Elements[1].Comment)
+}
+
+func TestRegisterRefArray(t *testing.T) {
+	device, err := Parse(`device test
+register Channel(1) {
+    value uint16;
+};
+register Main(2) {
+    channels [4]Channel;
+    count uint8;
+    more [count]Channel;
+};`)
+	require.NoError(t, err)
+	fields := device.Registers[1].Body.Fields()
+	assert.Equal(t, "Channel", fields[0].RefRegisterName())
+	assert.Equal(t, "", fields[1].RefRegisterName())
+	assert.Equal(t, "Channel", fields[2].RefRegisterName())
+
+	_, err = Parse(`device test
+register Main(1) {
+    channels [4]Unknown;
+};`)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "field 'channels' in register 'Main' references undefined register 'Unknown'")
+
+	_, err = Parse(`device test
+register A(1) {
+    bs [2]B;
+};
+register B(2) {
+    a A;
+};`)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "circular dependency detected")
+
+	_, err = Parse(`device test
+register Channel(1) {
+    value uint16;
+};
+register Main(2) {
+    channels [4]Channel @le;
+};`)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "field 'channels' in register 'Main' references register 'Channel' and cannot have endianness annotation '@le'")
+}
+
+func TestDeviceConstants(t *testing.T) {
+	device, err := Parse(`device test
+// protocol version
+const version = uint8(2);
+const maxPayload = uint16(0x100);
+enum Mode uint8 { A = 1 };
+register R(1) {
+    const limit = uint8(10);
+    v uint8;
+};`)
+	require.NoError(t, err)
+	require.Len(t, device.Constants, 2)
+	assert.Equal(t, "version", device.Constants[0].Name)
+	assert.Equal(t, int64(2), device.Constants[0].Value())
+	assert.Equal(t, "// protocol version", *device.Constants[0].Doc.Elements[0].Comment)
+	assert.Equal(t, "maxPayload", device.Constants[1].Name)
+	assert.Equal(t, int64(0x100), device.Constants[1].Value())
+	assert.Len(t, device.Registers[0].Body.Constants(), 1)
+
+	_, err = Parse(`device test
+const version = uint8(2);
+// duplicate
+const version = uint8(3);`)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "4:1: duplicate device constant 'version'")
+
+	_, err = Parse(`device test
+const limit = uint8(2);
+register R(1) {
+    const limit = uint8(10);
+};`)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "constant 'limit' in register 'R' has the same name as a device constant")
+
+	_, err = Parse(`device test
+register R(1) {
+    v uint8;
+};
+const limit = uint8(2);`)
+	require.Error(t, err, "the constants are declared before the registers")
+
+	// the device constants share the scope of the generated types
+	for _, tc := range []struct{ decl, kind string }{
+		{"register Ctl(1) {\n    v uint8;\n};", "a register"},
+		{"enum Ctl uint8 { A = 0 };", "an enum"},
+		{"register R(1) {\n    v uint8;\n};\nmessage Ctl {\n    R;\n};", "a message"},
+	} {
+		_, err = Parse("device test\nconst Ctl = uint8(1);\n" + tc.decl)
+		require.Error(t, err, tc.kind)
+		assert.Contains(t, err.Error(), "2:1: device constant 'Ctl' has the same name as "+tc.kind)
+	}
+}
+
+func TestErrorSourceLine(t *testing.T) {
+	_, err := Parse("device test\r\nregister R(1) {\r\n    v uint8 = x;\r\n};")
+	require.Error(t, err)
+	var perr *Error
+	require.ErrorAs(t, err, &perr)
+	assert.Equal(t, 3, perr.Pos.Line)
+	assert.Equal(t, "    v uint8 = x;", perr.Line)
+	assert.Contains(t, err.Error(), "3:5: unexpected token \"v\"")
+	assert.True(t, strings.HasSuffix(err.Error(), "\n    v uint8 = x;\n    ^"), err.Error())
+
+	// semantic errors point to the declaration, the leading comments are skipped
+	_, err = Parse(`device test
+register R(1) {
+    // the flags
+	flags uint8{a: 0-8};
+};`)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "4:14: bit field 'flags' in register 'R': bit range 0-8 exceeds size of base type 'uint8' (8 bits)")
+	assert.True(t, strings.HasSuffix(err.Error(), "\n\tflags uint8{a: 0-8};\n\t            ^"), err.Error())
+
+	_, err = Parse(`device test
+register A(1) {
+    v uint8;
+};
+  register B(1) {
+    v uint8;
+};`)
+	require.Error(t, err)
+	assert.True(t, strings.HasSuffix(err.Error(), "5:3: duplicate register number 1\n  register B(1) {\n  ^"), err.Error())
+
+	_, err = Format("device test\nregister R(1) {")
+	require.ErrorAs(t, err, &perr)
+	assert.Equal(t, "register R(1) {", perr.Line)
+}
+
+func TestFixedType(t *testing.T) {
+	device, err := Parse(`device test
+register R(1) {
+    temp fixed(int16, 4) @le;
+    ratio fixed(uint8, 8);
+};`)
+	require.NoError(t, err)
+	fields := device.Registers[0].Body.Fields()
+	require.NotNil(t, fields[0].Type.Fixed)
+	assert.Equal(t, "int16", fields[0].Type.Fixed.Base)
+	assert.Equal(t, 4, fields[0].Type.Fixed.Frac())
+	assert.True(t, fields[0].IsLittleEndian())
+	assert.Equal(t, 8, fields[1].Type.Fixed.Frac())
+
+	for _, tt := range []struct{ typ, err string }{
+		{"fixed(int8, 0)", "fractional bits 0 must be between 1 and 8 for the 'int8' base type"},
+		{"fixed(uint16, 17)", "fractional bits 17 must be between 1 and 16 for the 'uint16' base type"},
+	} {
+		_, err = Parse("device test\nregister R(1) {\n    v " + tt.typ + ";\n};")
+		require.Error(t, err, tt.typ)
+		assert.Contains(t, err.Error(), "fixed-point field 'v' in register 'R': "+tt.err)
+	}
+
+	_, err = Parse("device test\nregister R(1) {\n    v fixed(float32, 4);\n};")
+	require.Error(t, err)
+}
+
+func TestCRCType(t *testing.T) {
+	device, err := Parse(`device test
+register R(1) {
+    v uint16;
+    crc crc16(modbus) @le;
+};
+register S(2) {
+    crc crc32;
+};`)
+	require.NoError(t, err)
+	ct := device.Registers[0].Body.Fields()[1].Type.CRC
+	require.NotNil(t, ct)
+	assert.Equal(t, "modbus", ct.AlgorithmName())
+	assert.Equal(t, "uint16", ct.BaseType())
+	ct = device.Registers[1].Body.Fields()[0].Type.CRC
+	assert.Equal(t, "ieee", ct.AlgorithmName())
+	assert.Equal(t, "uint32", ct.BaseType())
+
+	for _, tt := range []struct{ body, err string }{
+		{"crc crc16;\n    v uint8;", "checksum field 'crc' in register 'R' must be the last field"},
+		{"crc crc32(modbus);", "checksum field 'crc' in register 'R': algorithm 'modbus' is not supported by 'crc32'"},
+		{"crc crc16(ieee);", "checksum field 'crc' in register 'R': algorithm 'ieee' is not supported by 'crc16'"},
+		{"reserved crc16;", "reserved field in register 'R' must be a built-in type"},
+	} {
+		_, err = Parse("device test\nregister R(1) {\n    " + tt.body + "\n};")
+		require.Error(t, err, tt.body)
+		assert.Contains(t, err.Error(), tt.err)
+	}
+}
+
+func TestMagicField(t *testing.T) {
+	device, err := Parse(`device test
+register R(1) {
+    sync = 0xAA55 uint16;
+    magic uint8;
+    tag: r = 200 int8 @le;
+};`)
+	require.NoError(t, err)
+	fields := device.Registers[0].Body.Fields()
+	require.True(t, fields[0].IsMagic())
+	assert.Equal(t, "sync", fields[0].Name)
+	assert.Equal(t, uint64(0xAA55), fields[0].MagicValue())
+	assert.False(t, fields[1].IsMagic(), "a field may be named magic")
+	assert.Equal(t, uint64(200), fields[2].MagicValue())
+	assert.Equal(t, "r", fields[2].Specifier)
+
+	for _, tt := range []struct{ body, err string }{
+		{"sync = 0x1FF uint8;", "magic field 'sync' in register 'R': value 0x1FF does not fit the 'uint8' type"},
+		{"sync = 1 float32;", "magic field 'sync' in register 'R' must be a built-in integer type"},
+		{"sync = 1 [2]uint8;", "magic field 'sync' in register 'R' must be a built-in integer type"},
+		{"sync = 1 uint8 [0..1];", "magic field 'sync' in register 'R' cannot have a range"},
+		{"reserved = 1 uint8;", "reserved field in register 'R' cannot have a magic value"},
+		{"n = 2 uint8;\n    data [n]uint8;", "variable-length array 'data' in register 'R' cannot be sized by magic field 'n'"},
+	} {
+		_, err = Parse("device test\nregister R(1) {\n    " + tt.body + "\n};")
+		require.Error(t, err, tt.body)
+		assert.Contains(t, err.Error(), tt.err)
+	}
+}
+
+func TestRegisterVersion(t *testing.T) {
+	device, err := Parse(`device test
+register R(1): w version 0x02 {
+    version uint8;
+};
+register S(2) {
+    value uint8;
+};`)
+	require.NoError(t, err)
+	r := device.Registers[0]
+	require.True(t, r.HasVersion())
+	assert.Equal(t, uint8(2), r.Version())
+	assert.Equal(t, "w", r.Specifier)
+	assert.Equal(t, "version", r.Body.Fields()[0].Name, "a field may be named version")
+	assert.False(t, device.Registers[1].HasVersion())
+
+	_, err = Parse("device test\nregister R(1) version 256 {\n    value uint8;\n};")
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "register 'R' version 256 is out of range, it must be between 0 and 255")
+}
+
+func TestSignedBitMember(t *testing.T) {
+	device, err := Parse(`device test
+register R(1) {
+    flags uint8{ready: 0, offset: signed 4-7};
+};`)
+	require.NoError(t, err)
+	bits := device.Registers[0].Body.Fields()[0].Type.Bitfield.Bits
+	assert.False(t, bits[0].Signed)
+	assert.True(t, bits[1].Signed)
+	assert.Equal(t, 4, bits[1].StartBit())
+
+	_, err = Parse(`device test
+register R(1) {
+    flags uint8{sign: signed 3};
+};`)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "signed member 'sign' must have at least 2 bits")
+
+	_, err = Parse(`device test
+register R(1) {
+    flags uint8{n: signed 0-3};
+    data [flags_n]uint8;
+};`)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "variable-length array 'data' in register 'R' size field 'flags_n' must be an unsigned bit member")
+}
+
+func TestBitFieldGroups(t *testing.T) {
+	device, err := Parse(`device test
+register R(1) {
+    control uint16{ctrl: {enable: 0, mode: 1-2}, reserved: 3-7, irq: {mask: 8-11, reserved: 12-15}};
+    data [control_ctrl_mode]uint8;
+};`)
+	require.NoError(t, err)
+	bf := device.Registers[0].Body.Fields()[0].Type.Bitfield
+	require.Len(t, bf.Items, 3)
+	assert.True(t, bf.Items[0].IsGroup())
+	assert.False(t, bf.Items[1].IsGroup())
+	require.Len(t, bf.Bits, 5)
+	assert.Equal(t, "ctrl_enable", bf.Bits[0].Name)
+	assert.Equal(t, "ctrl", bf.Bits[0].Group)
+	assert.Equal(t, "ctrl_mode", bf.Bits[1].Name)
+	assert.True(t, bf.Bits[2].Reserved)
+	assert.Empty(t, bf.Bits[2].Group)
+	assert.Equal(t, "irq_mask", bf.Bits[3].Name)
+	assert.Equal(t, 8, bf.Bits[3].StartBit())
+	assert.True(t, bf.Bits[4].Reserved)
+	assert.Equal(t, "irq", bf.Bits[4].Group)
+	assert.Empty(t, bf.Gaps())
+
+	tests := []struct {
+		name  string
+		input string
+		err   string
+	}{
+		{"groups overlap", `flags uint8{a: {x: 0-3}, b: {y: 3-4}};`, "members 'a_x' and 'b_y' overlap in bits 3"},
+		{"prefixed name", `flags uint8{a: {x: 0}, a_x: 1};`, "duplicate member 'a_x'"},
+		{"duplicate group", `flags uint8{a: {x: 0}, a: {y: 1}};`, "duplicate member 'a'"},
+		{"group and member", `flags uint8{a: 0, a: {y: 1}};`, "duplicate member 'a'"},
+		{"duplicate member", `flags uint8{a: 0, a: 1};`, "duplicate member 'a'"},
+		{"nested group", `flags uint8{a: {b: {c: 0}}};`, "group 'a' cannot contain group 'b'"},
+		{"reserved group", `flags uint8{reserved: {c: 0}};`, "reserved member cannot be a group"},
+		{"group range", `flags uint8{a: {x: 6-8}};`, "bit range 6-8 exceeds size of base type 'uint8'"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Parse("device test\nregister R(1) {\n    " + tt.input + "\n};")
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), tt.err)
+		})
+	}
+}
+
+func TestBitFieldSizes(t *testing.T) {
+	input := `device test
+register R(1) {
+    wide uint64{a: 0, b: 1-3};
+    padded uint32{a: 0-6, reserved: 7-31};
+    next uint16{a: 0, b: 15};
+    fit uint32{a: 0, b: 8-15};
+    byte uint8{a: 0};
+};`
+	device, err := Parse(input)
+	require.NoError(t, err)
+	fields := device.Registers[0].Body.Fields()
+	assert.Equal(t, "uint8", fields[0].Type.Bitfield.FitType())
+	assert.True(t, fields[0].Type.Bitfield.IsOversized())
+	assert.Equal(t, "uint8", fields[1].Type.Bitfield.FitType())
+	assert.True(t, fields[1].Type.Bitfield.IsOversized())
+	assert.False(t, fields[2].Type.Bitfield.IsOversized())
+	assert.Equal(t, "uint16", fields[3].Type.Bitfield.FitType())
+	assert.False(t, fields[3].Type.Bitfield.IsOversized())
+	assert.False(t, fields[4].Type.Bitfield.IsOversized())
+
+	sizes := AnalyzeBitfieldSizes(device)
+	require.Len(t, sizes, 2)
+	assert.Equal(t, BitfieldOversize{Register: "R", Field: "wide", Pos: fields[0].DeclPos(), Base: "uint64", Fit: "uint8"}, sizes[0])
+	assert.Equal(t, "padded", sizes[1].Field)
+
+	_, err = ParseWithOptions(input, ParseOptions{Strict: true})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "3:5: bit field 'wide' in register 'R': base type 'uint64' is oversized, the members fit 'uint8'")
+
+	_, err = ParseWithOptions(`device test
+register R(1) {
+    flags uint16{a: 0, b: 1-3, reserved: 4-15};
+};`, ParseOptions{Strict: true})
+	require.NoError(t, err)
+}
+
+func TestBitFieldGaps(t *testing.T) {
+	input := `device test
+register R(1) {
+    flags uint8{a: 0, b: 1, c: 4};
+    full uint8{low: 0-3, reserved: 4-6, high: 7};
+};`
+	device, err := Parse(input)
+	require.NoError(t, err)
+	fields := device.Registers[0].Body.Fields()
+	assert.Equal(t, []BitRange{{Start: 2, End: 3}, {Start: 5, End: 7}}, fields[0].Type.Bitfield.Gaps())
+	assert.Empty(t, fields[1].Type.Bitfield.Gaps())
+	assert.True(t, fields[1].Type.Bitfield.Bits[1].Reserved)
+	assert.Empty(t, fields[1].Type.Bitfield.Bits[1].Name)
+
+	gaps := AnalyzeBitfields(device)
+	require.Len(t, gaps, 1)
+	assert.Equal(t, "R", gaps[0].Register)
+	assert.Equal(t, "flags", gaps[0].Field)
+	assert.Equal(t, 3, gaps[0].Pos.Line)
+	assert.Equal(t, "2-3", gaps[0].Bits[0].String())
+	assert.Equal(t, "5-7", gaps[0].Bits[1].String())
+
+	_, err = ParseWithOptions(input, ParseOptions{Strict: true})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "3:5: bit field 'flags' in register 'R': bits 2-3, 5-7 are not used, cover them with a reserved member")
+
+	_, err = ParseWithOptions(`device test
+register R(1) {
+    flags uint8{a: 0, reserved: 1-6, b: 7};
+};`, ParseOptions{Strict: true})
+	require.NoError(t, err)
+
+	_, err = Parse(`device test
+register R(1) {
+    flags uint8{a: 0-3, reserved: 3-7};
+};`)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "members 'a' and 'reserved' overlap in bits 3")
+
+	_, err = Parse(`device test
+register R(1) {
+    flags uint8{reserved: signed 0-3};
+};`)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "reserved member cannot be signed")
+}
+
+func TestConstantLiterals(t *testing.T) {
+	device, err := Parse(`device test
+const Dec = uint8(10);
+const Hex = uint8(0xFF);
+const Bin = uint8(0b1010);
+enum Level int8 { LOW = -0b11, HIGH = 0B11 };
+register R(1) {
+    const Mask = uint16(0b1111000011110000);
+    v uint8;
+};`)
+	require.NoError(t, err)
+	assert.Equal(t, int64(10), device.Constants[0].Value())
+	assert.Equal(t, int64(255), device.Constants[1].Value())
+	assert.Equal(t, int64(10), device.Constants[2].Value())
+	assert.Equal(t, int64(-3), device.Enums[0].Members[0].Value())
+	assert.Equal(t, int64(3), device.Enums[0].Members[1].Value())
+	assert.Equal(t, int64(0xF0F0), device.Registers[0].Body.Constants()[0].Value())
+}
+
+func TestBitMemberErrorPosition(t *testing.T) {
+	_, err := Parse(`device test
+register R(1) {
+    flags uint8{a: 0, b: 5-3};
+};`)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "3:23: bit field 'flags' in register 'R': start bit 5 cannot be greater than end bit 3")
+
+	// the leading comments of the member are skipped
+	_, err = Parse(`device test
+register R(1) {
+    flags uint8{
+        a: 0-3,
+        // the second one
+        b: 2-4
+    };
+};`)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "6:9: bit field 'flags' in register 'R': members 'a' and 'b' overlap in bits 2-3")
+
+	_, err = Parse(`device test
+register R(1) {
+    flags uint16{a: 0, s: signed 9};
+};`)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "3:24: bit field 'flags' in register 'R': signed member 's' must have at least 2 bits")
+}
+
+func TestFieldRange(t *testing.T) {
+	device, err := Parse(`device test
+register R(1) {
+    duty uint8 [0..100];
+    offset int8 [-128..127] @le;
+    temp float32 [-40..85];
+    low int16 [-10..-5];
+    v uint16;
+};`)
+	require.NoError(t, err)
+	fields := device.Registers[0].Body.Fields()
+	assert.True(t, fields[0].HasRange())
+	assert.Equal(t, int64(0), fields[0].Min())
+	assert.Equal(t, int64(100), fields[0].Max())
+	assert.Equal(t, int64(-128), fields[1].Min())
+	assert.True(t, fields[1].IsLittleEndian())
+	assert.Equal(t, int64(-40), fields[2].Min())
+	assert.Equal(t, int64(-5), fields[3].Max())
+	assert.False(t, fields[4].HasRange())
+
+	for _, tc := range []struct{ decl, err string }{
+		{"duty uint8 [0..256];", "3:5: field 'duty' in register 'R': range 0..256 does not fit the 'uint8' type"},
+		{"duty uint8 [-1..10];", "range -1..10 does not fit the 'uint8' type"},
+		{"duty int8 [-129..0];", "range -129..0 does not fit the 'int8' type"},
+		{"duty uint8 [10..1];", "field 'duty' in register 'R': invalid range 10..1"},
+		{"duty string [0..10];", "field 'duty' in register 'R': the range requires a built-in number type"},
+	} {
+		_, err := Parse("device test\nregister R(1) {\n    " + tc.decl + "\n};")
+		require.Error(t, err, tc.decl)
+		assert.Contains(t, err.Error(), tc.err)
+	}
+}
+
+func TestMultiDimArray(t *testing.T) {
+	device, err := Parse(`device test
+register R(1) {
+    count uint8;
+    pixels [8][8]uint8;
+    rows [count][2][0x3]uint16;
+    values [4]uint16;
+};`)
+	require.NoError(t, err)
+	fields := device.Registers[0].Body.Fields()
+	assert.True(t, fields[1].Type.Array.IsMultiDim())
+	assert.Equal(t, []string{"8"}, fields[1].Type.Array.Dims)
+	assert.Equal(t, 8, fields[1].Type.Array.InnerCount())
+	assert.Equal(t, "count", *fields[2].Type.Array.Size.Variable)
+	assert.Equal(t, 6, fields[2].Type.Array.InnerCount())
+	assert.False(t, fields[3].Type.Array.IsMultiDim())
+	assert.Equal(t, 1, fields[3].Type.Array.InnerCount())
+
+	for _, tc := range []struct{ decl, err string }{
+		{"pixels [8][0]uint8;", "3:5: array 'pixels' in register 'R': dimension 0 must be a positive number"},
+		{"pixels [8][2]R;", "multi-dimensional array 'pixels' in register 'R' must have a built-in element type, got 'R'"},
+		{"pixels [8][count]uint8;", "unexpected token"},
+	} {
+		_, err := Parse("device test\nregister R(1) {\n    " + tc.decl + "\n};")
+		require.Error(t, err, tc.decl)
+		assert.Contains(t, err.Error(), tc.err)
+	}
+}
+
+func TestConstantArraySize(t *testing.T) {
+	device, err := Parse(`device test
+register R(1) {
+    one [1]uint8;
+    data [0x10]uint16;
+    max [65535]uint8;
+    grid [255][257]uint8;
+    reserved [2]uint8;
+};`)
+	require.NoError(t, err)
+	fields := device.Registers[0].Body.Fields()
+	assert.Equal(t, "1", *fields[0].Type.Array.Size.Constant)
+	assert.Equal(t, "0x10", *fields[1].Type.Array.Size.Constant)
+
+	for _, tc := range []struct{ decl, err string }{
+		{"data [0]uint8;", "3:5: array 'data' in register 'R': size 0 must be a positive number"},
+		{"reserved [0x0]uint8;", "reserved array in register 'R': size 0x0 must be a positive number"},
+		{"data [65536]uint8;", "3:5: array 'data' in register 'R' is too large, it cannot have more than 65535 elements"},
+		{"data [99999999999999999999]uint8;", "size 99999999999999999999 must be a positive number"},
+		{"grid [256][257]uint8;", "array 'grid' in register 'R' is too large"},
+		{"grid [4294967296][4294967296]uint8;", "array 'grid' in register 'R' is too large"},
+	} {
+		_, err := Parse("device test\nregister R(1) {\n    " + tc.decl + "\n};")
+		require.Error(t, err, tc.decl)
+		assert.Contains(t, err.Error(), tc.err)
+	}
+}
+
+func TestInt24Type(t *testing.T) {
+	device, err := Parse(`device test
+const MAX = uint24(0xFFFFFF);
+register R(1) {
+    sample int24 [-8388608..8388607];
+    color uint24 @le;
+    count uint24;
+    samples [count]int24;
+};`)
+	require.NoError(t, err)
+	assert.True(t, IsBuiltinType("int24"))
+	assert.True(t, IsBuiltinType("uint24"))
+	fields := device.Registers[0].Body.Fields()
+	assert.Equal(t, int64(-8388608), fields[0].Min())
+	assert.True(t, fields[1].IsLittleEndian())
+
+	for _, tc := range []struct{ decl, err string }{
+		{"flags uint24{a: 0};", "bit field 'flags' in register 'R' cannot use the 24-bit type 'uint24'"},
+		{"v int24 [-8388609..0];", "range -8388609..0 does not fit the 'int24' type"},
+		{"v uint24 [0..16777216];", "range 0..16777216 does not fit the 'uint24' type"},
+		{"n int24;\n    a [n]uint8;", "size field 'n' must be an unsigned integer, got 'int24'"},
+	} {
+		_, err := Parse("device test\nregister R(1) {\n    " + tc.decl + "\n};")
+		require.Error(t, err, tc.decl)
+		assert.Contains(t, err.Error(), tc.err)
+	}
+}
+
+func TestDeviceEndianness(t *testing.T) {
+	device, err := Parse(`device test @le
+register Inner(1) {
+    v uint16;
+};
+register R(2) {
+    a uint16;
+    b uint16 @be;
+    flags uint8{x: 0};
+    inner Inner;
+};`)
+	require.NoError(t, err)
+	assert.Equal(t, "le", device.Endianness)
+	assert.True(t, device.Registers[0].Body.Fields()[0].IsLittleEndian())
+	fields := device.Registers[1].Body.Fields()
+	assert.True(t, fields[0].IsLittleEndian())
+	assert.False(t, fields[1].IsLittleEndian(), "the annotation overrides the device byte order")
+	assert.True(t, fields[2].IsLittleEndian())
+	assert.Equal(t, "", fields[3].Endianness, "the referenced register defines its own encoding")
+
+	device, err = Parse("device test\nregister R(1) {\n    a uint16;\n};")
+	require.NoError(t, err)
+	assert.Equal(t, "", device.Endianness)
+	assert.False(t, device.Registers[0].Body.Fields()[0].IsLittleEndian(), "big-endian is the default")
+}
+
+func TestAllValidationErrors(t *testing.T) {
+	_, err := Parse(`device test
+register A(1) {
+    flags uint8{a: 0-8};
+    data [size]uint8;
+};
+register B(1):r {
+    v:w uint8;
+};`)
+	require.Error(t, err)
+	msg := err.Error()
+	assert.Contains(t, msg, "3:17: bit field 'flags' in register 'A': bit range 0-8 exceeds size of base type 'uint8' (8 bits)\n    flags uint8{a: 0-8};\n")
+	assert.Contains(t, msg, "4:5: variable-length array 'data' in register 'A' references undefined field 'size'\n")
+	assert.Contains(t, msg, "6:1: duplicate register number 1\nregister B(1):r {\n^")
+	assert.Contains(t, msg, "7:5: field 'v' in register 'B' cannot be write-only because register is read-only")
+
+	var perr *Error
+	require.ErrorAs(t, err, &perr)
+
+	// the syntax errors stop the parsing
+	_, err = Parse("device test\nregister A(1) {\n    v uint8 = x;\n};\nregister B(1) {\n};")
+	require.Error(t, err)
+	assert.NotContains(t, err.Error(), "duplicate register number")
+}
+
+func TestArraySizeFieldOrder(t *testing.T) {
+	_, err := Parse(`device test
+register R(1) {
+    data [count]uint8;
+    count uint8;
+};`)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "3:5: variable-length array 'data' in register 'R' references size field 'count' declared after it at 4:5, the size field must be declared before the array")
+
+	_, err = Parse(`device test
+register R(1) {
+    data [flags_len]uint8;
+    flags uint8{len: 0-3};
+};`)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "references size field 'flags_len' declared after it at 4:17")
+
+	_, err = Parse(`device test
+register R(1) {
+    flags uint8{len: 0-3};
+    data [flags]uint8;
+};`)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "references bit field 'flags', a bit field member is referenced as 'flags_<member>'")
+
+	_, err = Parse(`device test
+register R(1) {
+    flags uint8{len: 0-3};
+    data [flags_len]uint8;
+    more [flags_len]uint8;
+};`)
+	require.NoError(t, err)
+}
+
+func TestFloat16(t *testing.T) {
+	device, err := Parse(`device test
+register R(1) {
+    value float16;
+    values [3]float16 @le;
+    next uint8 @offset 8;
+};`)
+	require.NoError(t, err)
+	fields := device.Registers[0].Body.Fields()
+	size, ok := fields[0].WireSize()
+	assert.True(t, ok)
+	assert.Equal(t, 2, size)
+	size, ok = fields[1].WireSize()
+	assert.True(t, ok)
+	assert.Equal(t, 6, size)
+
+	_, err = Parse(`device test
+register R(1) {
+    flags float16{a: 0};
+};`)
+	require.Error(t, err, "the bit field base must be an unsigned integer")
+	assert.Contains(t, err.Error(), "3:5: unexpected token")
+
+	_, err = Parse(`device test
+register R(1) {
+    sync = 0x3C00 float16;
+};`)
+	require.Error(t, err, "the magic field must be an integer")
+}
+
+func TestFieldOffset(t *testing.T) {
+	device, err := Parse(`device test
+enum Mode uint16 { OFF = 0 };
+register R(1) {
+    id uint8;
+    status:r uint16 @offset 4 @le;
+    mode:w Mode @offset 0x8;
+    flags uint8{a: 0} @offset 10;
+    data [2]int24;
+    v float32 @offset 20;
+    count uint8;
+    items [count]uint8;
+};`)
+	require.NoError(t, err)
+	r := device.Registers[0]
+	fields := r.Body.Fields()
+	assert.False(t, fields[0].HasOffset())
+	assert.Equal(t, 4, fields[1].Offset())
+	assert.True(t, fields[1].IsLittleEndian())
+	assert.Equal(t, 8, fields[2].Offset())
+
+	// the encoding of a direction skips the fields of the other one, so the gap covers them
+	for _, tc := range []struct {
+		field       int
+		read, write int
+	}{
+		{0, 0, 0},
+		{1, 3, 0},
+		{2, 0, 7},
+		{3, 4, 0},
+		{5, 3, 3},
+		{7, 0, 0},
+	} {
+		assert.Equal(t, tc.read, r.Padding(fields[tc.field], true), fields[tc.field].Name)
+		assert.Equal(t, tc.write, r.Padding(fields[tc.field], false), fields[tc.field].Name)
+	}
+
+	size, ok := fields[4].WireSize()
+	assert.True(t, ok)
+	assert.Equal(t, 6, size)
+	_, ok = fields[7].WireSize()
+	assert.False(t, ok)
+
+	for _, tc := range []struct{ decl, err string }{
+		{"a uint16; b uint8 @offset 1;", "3:15: field 'b' in register 'R': offset 1 overlaps the preceding fields, which end at offset 2"},
+		{"a uint8 @offset 4; b uint8 @offset 4;", "field 'b' in register 'R': offset 4 overlaps the preceding fields, which end at offset 5"},
+		{"reserved uint8 @offset 65536;", "field 'reserved' in register 'R': offset 65536 must be between 0 and 65535"},
+		{"s string; b uint8 @offset 100;", "field 'b' in register 'R' cannot have an offset, the preceding field 's' has no constant size"},
+		{"b uint8 @le @offset 4;", "unexpected token"},
+	} {
+		_, err := Parse("device test\nregister R(1) {\n    " + tc.decl + "\n};")
+		require.Error(t, err, tc.decl)
+		assert.Contains(t, err.Error(), tc.err)
+	}
+}
+
+func TestFieldDefaults(t *testing.T) {
+	device, err := Parse(`device test
+enum Mode uint8 { OFF = 0, ON = 1 };
+register R(1) {
+    mode Mode = 1;
+    level uint8 [1..10] = 0x5;
+    offset int16 = -1_000;
+    flags uint8{ready: 0, busy: 1} = 0b10;
+    big uint64 = 0xFFFFFFFFFFFFFFFF;
+    plain uint8;
+};`)
+	require.NoError(t, err)
+	fields := device.Registers[0].Body.Fields()
+	for i, expected := range []int64{1, 5, -1000, 2, -1} {
+		require.True(t, fields[i].HasDefault(), fields[i].Name)
+		assert.Equal(t, expected, fields[i].Default(), fields[i].Name)
+	}
+	assert.False(t, fields[5].HasDefault())
+
+	formatted, err := Format(`device test
+register R(1) {
+    level uint8 [1..10] = 5 @offset 2;
+};`)
+	require.NoError(t, err)
+	assert.Contains(t, formatted, "level uint8 [1..10] = 5 @offset 2;")
+
+	for _, tc := range []struct {
+		field, err string
+	}{
+		{"v uint8 = 256;", "3:5: field 'v' in register 'R': default value 256 does not fit the 'uint8' type"},
+		{"v int8 = -129;", "default value -129 does not fit the 'int8' type"},
+		{"v uint16 = -1;", "default value -1 does not fit the 'uint16' type"},
+		{"v uint8{a: 0} = 0x100;", "default value 0x100 does not fit the 'uint8' type"},
+		{"v uint8 [1..10] = 11;", "default value 11 is out of range [1..10]"},
+		{"v float32 = 1;", "field 'v' in register 'R' cannot have a default value"},
+		{"v string = 1;", "field 'v' in register 'R' cannot have a default value"},
+		{"v [2]uint8 = 1;", "field 'v' in register 'R' cannot have a default value"},
+	} {
+		_, err := Parse("device test\nregister R(1) {\n    " + tc.field + "\n};")
+		require.Error(t, err, tc.field)
+		assert.Contains(t, err.Error(), tc.err, tc.field)
+	}
+}
+
+func TestConstantComments(t *testing.T) {
+	device, err := Parse(`device test
+// the version
+const version = uint8(2); // major only
+register R(1) {
+    // the lower limit
+    // of the level
+    const low = uint8(1); /* inclusive */
+    level uint8; // the level
+    /* the upper limit */
+    const high = uint8(10);
+    const plain = uint8(5);
+};`)
+	require.NoError(t, err)
+	version := device.Constants[0]
+	require.NotNil(t, version.Doc)
+	assert.Equal(t, "// the version", *version.Doc.Elements[0].Comment)
+	assert.Equal(t, "// major only", *version.TrailingComment)
+
+	constants := device.Registers[0].Body.Constants()
+	require.Len(t, constants, 3)
+	low, high, plain := constants[0], constants[1], constants[2]
+	require.NotNil(t, low.Doc)
+	require.Len(t, low.Doc.Elements, 2)
+	assert.Equal(t, "// the lower limit", *low.Doc.Elements[0].Comment)
+	assert.Equal(t, "// of the level", *low.Doc.Elements[1].Comment)
+	assert.Equal(t, "/* inclusive */", *low.TrailingComment)
+
+	// the comments around the field stay with the field and the next constant
+	level := device.Registers[0].Body.Fields()[0]
+	assert.Empty(t, level.Doc.Elements)
+	assert.Equal(t, "// the level", *level.TrailingComment)
+	require.NotNil(t, high.Doc)
+	assert.Equal(t, "/* the upper limit */", *high.Doc.Elements[0].Comment)
+	assert.Equal(t, "", *high.TrailingComment)
+	assert.Empty(t, plain.Doc.Elements)
+
+	formatted, err := Format(`device test
+const version = uint8(2);   // major only
+register R(1) {
+    const low = uint8(1); /* inclusive */
+    v uint8;
+};`)
+	require.NoError(t, err)
+	assert.Contains(t, formatted, "const version = uint8(2); // major only\n")
+	assert.Contains(t, formatted, "    const low = uint8(1); /* inclusive */\n")
+}
+
+func TestFieldConditions(t *testing.T) {
+	device, err := Parse(`device test
+register R(1) {
+    flags uint8{has_extra: 0, mode: 1-2};
+    more uint8;
+    extra uint16 @le if flags_has_extra;
+    count:r uint8 if more;
+    items:r [count]uint8 if more;
+    last uint8;
+};`)
+	require.NoError(t, err)
+	fields := device.Registers[0].Body.Fields()
+	assert.False(t, fields[0].HasCondition())
+	require.True(t, fields[2].HasCondition())
+	assert.Equal(t, "flags_has_extra", *fields[2].Condition)
+	assert.True(t, fields[2].IsLittleEndian())
+	assert.Equal(t, "more", *fields[3].Condition)
+
+	// the size of the conditional field depends on the condition
+	_, ok := fields[2].WireSize()
+	assert.False(t, ok)
+
+	formatted, err := Format(`device test
+register R(1) {
+    flags uint8{on: 0};
+    v uint16 @le if flags_on;
+};`)
+	require.NoError(t, err)
+	assert.Contains(t, formatted, "v uint16 @le if flags_on;")
+
+	for _, tc := range []struct{ decl, err string }{
+		{"v uint16 if flags_on;", "3:5: field 'v' in register 'R' references undefined condition 'flags_on'"},
+		{"v uint16 if f_on; f uint8{on: 0};", "field 'v' in register 'R': condition 'f_on' must be declared before the field"},
+		{"f uint8{on: 0-1}; v uint16 if f_on;", "field 'v' in register 'R': condition 'f_on' must be a single-bit member, it has bits 0-1"},
+		{"f uint16; v uint16 if f;", "field 'v' in register 'R': condition 'f' must be a single-bit member or a uint8 field"},
+		{"f = 1 uint8; v uint16 if f;", "condition 'f' must be a single-bit member or a uint8 field"},
+		{"f:r uint8; v uint16 if f;", "field 'v' in register 'R': condition 'f' must be sent in every direction the field is sent in"},
+		{"f:r uint8; v:w uint16 if f;", "condition 'f' must be sent in every direction the field is sent in"},
+		{"a uint8; f uint8 if a; v uint16 if f;", "field 'v' in register 'R': condition 'f' cannot be conditional itself"},
+		{"f uint8; reserved uint16 if f;", "reserved field in register 'R' cannot be conditional"},
+		{"f uint8; v = 1 uint16 if f;", "magic field 'v' in register 'R' cannot be conditional"},
+		{"f uint8; c crc16 if f;", "checksum field 'c' in register 'R' cannot be conditional"},
+		{"f uint8; v uint16 @offset 2 if f;", "field 'v' in register 'R' cannot have both an offset and a condition"},
+		{"f uint8; v uint16 if f; b uint8 @offset 8;", "field 'b' in register 'R' cannot have an offset, the preceding field 'v' has no constant size"},
+		{"f uint8; n uint8 if f; a [n]uint8;", "variable-length array 'a' in register 'R' must have the condition 'f' of its size field 'n'"},
+	} {
+		_, err := Parse("device test\nregister R(1) {\n    " + tc.decl + "\n};")
+		require.Error(t, err, tc.decl)
+		assert.Contains(t, err.Error(), tc.err, tc.decl)
+	}
+}
+
+func TestMessages(t *testing.T) {
+	device, err := Parse(`device test // the device
+// the frame
+message Frame {
+    // the first one
+    Control;
+    Status; // the last one
+};
+register Control(1) {
+    mode uint8;
+};
+register Status(2): r {
+    value uint16;
+};`)
+	require.NoError(t, err)
+	assert.Equal(t, "// the device", *device.TrailingComment)
+	require.Len(t, device.Messages, 1)
+	m := device.Messages[0]
+	assert.Equal(t, "Frame", m.Name)
+	assert.Equal(t, 3, m.DeclPos().Line)
+	require.Len(t, m.Members, 2)
+	assert.Equal(t, "Control", m.Members[0].Register)
+	assert.Equal(t, 5, m.Members[0].DeclPos().Line)
+	assert.Equal(t, "Status", m.Members[1].Register)
+	assert.Equal(t, "// the last one", *m.Members[1].TrailingComment)
+
+	// the message name is not a keyword
+	_, err = Parse("device test\nregister message(1) {\n    message uint8;\n};")
+	require.NoError(t, err)
+
+	const regs = "register R(1) {\n    a uint8;\n};\nenum E uint8 { A = 0 };\n"
+	for _, tc := range []struct {
+		decl string
+		err  string
+	}{
+		{"message M { X; };", "message 'M' references undefined register 'X'"},
+		{"message M { R; R; };", "message 'M' has duplicate register 'R'"},
+		{"message M { };", "message 'M' has no registers"},
+		{"message M { R; };\nmessage M { R; };", "duplicate message 'M'"},
+		{"message R { R; };", "message 'R' has the same name as a register"},
+		{"message E { R; };", "message 'E' has the same name as an enum"},
+		{"message uint8 { R; };", "message 'uint8' cannot have the name of a built-in type"},
+	} {
+		_, err := Parse("device test\n" + regs + tc.decl)
+		require.Error(t, err, tc.decl)
+		assert.Contains(t, err.Error(), tc.err, tc.decl)
+	}
+}